@@ -142,6 +142,9 @@ func runStart(cmd *cobra.Command, args []string) {
 		log.Errorf("cockroach server exited with error: %s", err)
 		return
 	}
+	// Hand off the node's leader leases before it stops serving, whether
+	// shutdown is triggered by a signal or via the quit endpoint.
+	stopper.AddDrainHook(s.DrainLeases)
 
 	if context.EphemeralSingleNode {
 		// TODO(tamird): pass this to BootstrapRange rather than doing it
@@ -112,6 +112,14 @@ func (b *Batch) fillResults() error {
 					row.setValue(&req.Value)
 					row.setTimestamp(t.Timestamp)
 				}
+			case *proto.CheckAndPutResponse:
+				req := call.Args.(*proto.CheckAndPutRequest)
+				row := &result.Rows[k]
+				row.Key = []byte(req.Key)
+				if result.Err == nil {
+					row.setValue(&req.Value)
+					row.setTimestamp(t.Timestamp)
+				}
 			case *proto.IncrementResponse:
 				row := &result.Rows[k]
 				row.Key = []byte(call.Args.(*proto.IncrementRequest).Key)
@@ -182,6 +190,7 @@ func (b *Batch) InternalAddCall(call proto.Call) {
 	case *proto.GetRequest,
 		*proto.PutRequest,
 		*proto.ConditionalPutRequest,
+		*proto.CheckAndPutRequest,
 		*proto.IncrementRequest,
 		*proto.DeleteRequest:
 		numRows = 1
@@ -45,6 +45,14 @@ type GCPolicy struct {
 	// garbage collected. Only older versions of values are garbage
 	// collected. Specifying <=0 mean older versions are never GC'd.
 	TTLSeconds int32 `protobuf:"varint,1,opt,name=ttl_seconds" json:"ttl_seconds"`
+	// TombstoneTTLSeconds, if positive, specifies the maximum age of a
+	// deletion tombstone heading the versions of a key, after which the
+	// tombstone and all versions it shadows are garbage collected even if
+	// TTLSeconds would retain them. This only happens if no read within
+	// TTLSeconds could observe any of the shadowed versions, that is if
+	// they are deletion tombstones themselves or shadowed by a version
+	// older than TTLSeconds.
+	TombstoneTTLSeconds int32 `protobuf:"varint,2,opt,name=tombstone_ttl_seconds" json:"tombstone_ttl_seconds"`
 }
 
 func (m *GCPolicy) Reset()         { *m = GCPolicy{} }
@@ -58,6 +66,13 @@ func (m *GCPolicy) GetTTLSeconds() int32 {
 	return 0
 }
 
+func (m *GCPolicy) GetTombstoneTTLSeconds() int32 {
+	if m != nil {
+		return m.TombstoneTTLSeconds
+	}
+	return 0
+}
+
 // ZoneConfig holds configuration that is needed for a range of KV pairs.
 type ZoneConfig struct {
 	// ReplicaAttrs is a slice of Attributes, each describing required attributes
@@ -69,6 +84,36 @@ type ZoneConfig struct {
 	// If GC policy is not set, uses the next highest, non-null policy
 	// in the zone config hierarchy, up to the default policy if necessary.
 	GC *GCPolicy `protobuf:"bytes,4,opt,name=gc" json:"gc,omitempty" yaml:"gc,omitempty"`
+	// MaxValueBytes is the maximum size of a single value written to the
+	// zone. If zero, the cluster default applies.
+	MaxValueBytes int64 `protobuf:"varint,5,opt,name=max_value_bytes" json:"max_value_bytes" yaml:"max_value_bytes,omitempty"`
+	// DisableResponseCache turns off the response cache for ranges in the
+	// zone, saving a write per mutation. Without the response cache, a write
+	// which is retried with the same ClientCmdID after its first attempt
+	// succeeded is executed again instead of replaying the earlier response,
+	// so it should only be set for zones whose writes are idempotent or
+	// retried safely at a higher layer.
+	DisableResponseCache bool `protobuf:"varint,6,opt,name=disable_response_cache" json:"disable_response_cache" yaml:"disable_response_cache,omitempty"`
+	// LeasePreference are the node attributes describing the locality
+	// preferred for the leader lease of ranges in the zone. Replicas on
+	// nodes lacking these attributes defer to a replica on a matching node,
+	// if one is available, when acquiring the lease.
+	LeasePreference cockroach_proto.Attributes `protobuf:"bytes,7,opt,name=lease_preference" json:"lease_preference" yaml:"lease_preference,omitempty"`
+	// LeasePreferences lists further localities preferred for the leader
+	// lease, in decreasing order of preference, after LeasePreference if it
+	// is set. Replicas on nodes matching a less preferred entry, or none,
+	// defer to a replica on a node matching a more preferred entry.
+	LeasePreferences []cockroach_proto.Attributes `protobuf:"bytes,8,rep,name=lease_preferences" json:"lease_preferences,omitempty" yaml:"lease_preferences,omitempty"`
+	// ValueChecksums makes ranges in the zone checksum every value written
+	// by a Put which doesn't carry a checksum already. Checksums are
+	// verified on reads, which fail with a replica corruption error if a
+	// stored value no longer matches its checksum.
+	ValueChecksums bool `protobuf:"varint,9,opt,name=value_checksums" json:"value_checksums" yaml:"value_checksums,omitempty"`
+	// QuorumAttrs are node attributes describing a locality of which at
+	// least one replica must have persisted a write before the write is
+	// acknowledged, in addition to the Raft majority which commits it.
+	// Writes to ranges without a replica in the locality fail.
+	QuorumAttrs cockroach_proto.Attributes `protobuf:"bytes,10,opt,name=quorum_attrs" json:"quorum_attrs" yaml:"quorum_attrs,omitempty"`
 }
 
 func (m *ZoneConfig) Reset()         { *m = ZoneConfig{} }
@@ -103,6 +148,48 @@ func (m *ZoneConfig) GetGC() *GCPolicy {
 	return nil
 }
 
+func (m *ZoneConfig) GetMaxValueBytes() int64 {
+	if m != nil {
+		return m.MaxValueBytes
+	}
+	return 0
+}
+
+func (m *ZoneConfig) GetDisableResponseCache() bool {
+	if m != nil {
+		return m.DisableResponseCache
+	}
+	return false
+}
+
+func (m *ZoneConfig) GetLeasePreference() cockroach_proto.Attributes {
+	if m != nil {
+		return m.LeasePreference
+	}
+	return cockroach_proto.Attributes{}
+}
+
+func (m *ZoneConfig) GetLeasePreferences() []cockroach_proto.Attributes {
+	if m != nil {
+		return m.LeasePreferences
+	}
+	return nil
+}
+
+func (m *ZoneConfig) GetValueChecksums() bool {
+	if m != nil {
+		return m.ValueChecksums
+	}
+	return false
+}
+
+func (m *ZoneConfig) GetQuorumAttrs() cockroach_proto.Attributes {
+	if m != nil {
+		return m.QuorumAttrs
+	}
+	return cockroach_proto.Attributes{}
+}
+
 // PrefixConfigMap contains a slice of prefix configs, sorted by
 // prefix. Along with various accessor methods, the config map
 // also contains additional prefix configs in the slice to
@@ -207,6 +294,9 @@ func (m *GCPolicy) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x8
 	i++
 	i = encodeVarintConfig(data, i, uint64(m.TTLSeconds))
+	data[i] = 0x10
+	i++
+	i = encodeVarintConfig(data, i, uint64(m.TombstoneTTLSeconds))
 	return i, nil
 }
 
@@ -253,6 +343,53 @@ func (m *ZoneConfig) MarshalTo(data []byte) (int, error) {
 		}
 		i += n1
 	}
+	data[i] = 0x28
+	i++
+	i = encodeVarintConfig(data, i, uint64(m.MaxValueBytes))
+	data[i] = 0x30
+	i++
+	if m.DisableResponseCache {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	data[i] = 0x3a
+	i++
+	i = encodeVarintConfig(data, i, uint64(m.LeasePreference.Size()))
+	n2, err := m.LeasePreference.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n2
+	if len(m.LeasePreferences) > 0 {
+		for _, msg := range m.LeasePreferences {
+			data[i] = 0x42
+			i++
+			i = encodeVarintConfig(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	data[i] = 0x48
+	i++
+	if m.ValueChecksums {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	data[i] = 0x52
+	i++
+	i = encodeVarintConfig(data, i, uint64(m.QuorumAttrs.Size()))
+	n3, err := m.QuorumAttrs.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n3
 	return i, nil
 }
 
@@ -316,11 +453,11 @@ func (m *PrefixConfig) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x1a
 	i++
 	i = encodeVarintConfig(data, i, uint64(m.Config.Size()))
-	n2, err := m.Config.MarshalTo(data[i:])
+	n4, err := m.Config.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n2
+	i += n4
 	return i, nil
 }
 
@@ -343,11 +480,11 @@ func (m *ConfigUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0xa
 		i++
 		i = encodeVarintConfig(data, i, uint64(m.Zone.Size()))
-		n3, err := m.Zone.MarshalTo(data[i:])
+		n5, err := m.Zone.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n3
+		i += n5
 	}
 	return i, nil
 }
@@ -413,6 +550,7 @@ func (m *GCPolicy) Size() (n int) {
 	var l int
 	_ = l
 	n += 1 + sovConfig(uint64(m.TTLSeconds))
+	n += 1 + sovConfig(uint64(m.TombstoneTTLSeconds))
 	return n
 }
 
@@ -431,6 +569,19 @@ func (m *ZoneConfig) Size() (n int) {
 		l = m.GC.Size()
 		n += 1 + l + sovConfig(uint64(l))
 	}
+	n += 1 + sovConfig(uint64(m.MaxValueBytes))
+	n += 2
+	l = m.LeasePreference.Size()
+	n += 1 + l + sovConfig(uint64(l))
+	if len(m.LeasePreferences) > 0 {
+		for _, e := range m.LeasePreferences {
+			l = e.Size()
+			n += 1 + l + sovConfig(uint64(l))
+		}
+	}
+	n += 2
+	l = m.QuorumAttrs.Size()
+	n += 1 + l + sovConfig(uint64(l))
 	return n
 }
 
@@ -548,6 +699,22 @@ func (m *GCPolicy) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TombstoneTTLSeconds", wireType)
+			}
+			m.TombstoneTTLSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.TombstoneTTLSeconds |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
@@ -683,6 +850,138 @@ func (m *ZoneConfig) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxValueBytes", wireType)
+			}
+			m.MaxValueBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.MaxValueBytes |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DisableResponseCache", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.DisableResponseCache = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeasePreference", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.LeasePreference.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeasePreferences", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LeasePreferences = append(m.LeasePreferences, cockroach_proto.Attributes{})
+			if err := m.LeasePreferences[len(m.LeasePreferences)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValueChecksums", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ValueChecksums = bool(v != 0)
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuorumAttrs", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.QuorumAttrs.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -270,6 +270,33 @@ func (g *Gossip) GetInfo(key string) ([]byte, error) {
 	return nil, util.Errorf("key %q does not exist or has expired", key)
 }
 
+// GetInfoTimestamp returns the wall time at which the info for key
+// was added by its originating node, or an error if the specified key
+// does not exist or has expired.
+func (g *Gossip) GetInfoTimestamp(key string) (time.Time, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if i := g.is.getInfo(key); i != nil && i.Value.Timestamp != nil {
+		return time.Unix(0, i.Value.Timestamp.WallTime), nil
+	}
+	return time.Time{}, util.Errorf("key %q does not exist or has expired", key)
+}
+
+// GetInfoTTL returns the time-to-live with which the info for key was
+// added by its originating node, or zero if the info never expires.
+// Returns an error if the specified key does not exist or has expired.
+func (g *Gossip) GetInfoTTL(key string) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if i := g.is.getInfo(key); i != nil && i.Value.Timestamp != nil {
+		if i.TTLStamp == math.MaxInt64 {
+			return 0, nil
+		}
+		return time.Duration(i.TTLStamp - i.Value.Timestamp.WallTime), nil
+	}
+	return 0, util.Errorf("key %q does not exist or has expired", key)
+}
+
 // GetInfoProto returns an info value by key or an error if specified
 // key does not exist or has expired.
 func (g *Gossip) GetInfoProto(key string, proto gogoproto.Message) error {
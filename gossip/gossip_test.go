@@ -44,6 +44,21 @@ func TestGossipInfoStore(t *testing.T) {
 	if _, err := g.GetInfo("s2"); err == nil {
 		t.Errorf("expected error fetching nonexistent key \"s2\"")
 	}
+	if ts, err := g.GetInfoTimestamp("s"); err != nil || ts.IsZero() {
+		t.Errorf("expected timestamp for key \"s\"; got %s, %v", ts, err)
+	}
+	if _, err := g.GetInfoTimestamp("s2"); err == nil {
+		t.Errorf("expected error fetching timestamp of nonexistent key \"s2\"")
+	}
+	if ttl, err := g.GetInfoTTL("s"); err != nil || ttl != time.Hour {
+		t.Errorf("expected TTL %s for key \"s\"; got %s, %v", time.Hour, ttl, err)
+	}
+	if err := g.AddInfo("s3", slice, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := g.GetInfoTTL("s3"); err != nil || ttl != 0 {
+		t.Errorf("expected no TTL for key \"s3\"; got %s, %v", ttl, err)
+	}
 }
 
 func TestGossipGetNextBootstrapAddress(t *testing.T) {
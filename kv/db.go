@@ -34,7 +34,9 @@ var allExternalMethods = [...]proto.Request{
 	proto.Get:            &proto.GetRequest{},
 	proto.Put:            &proto.PutRequest{},
 	proto.ConditionalPut: &proto.ConditionalPutRequest{},
+	proto.CheckAndPut:    &proto.CheckAndPutRequest{},
 	proto.Increment:      &proto.IncrementRequest{},
+	proto.MultiIncrement: &proto.MultiIncrementRequest{},
 	proto.Delete:         &proto.DeleteRequest{},
 	proto.DeleteRange:    &proto.DeleteRangeRequest{},
 	proto.Scan:           &proto.ScanRequest{},
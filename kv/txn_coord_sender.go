@@ -116,6 +116,18 @@ func (tm *txnMetadata) addKeyRange(start, end proto.Key) {
 	tm.keys.Add(key, nil)
 }
 
+// writtenIntents returns the number of intents left by a successful
+// transactional write, counting one per key written.
+func writtenIntents(reply proto.Response) int64 {
+	switch t := reply.(type) {
+	case *proto.DeleteRangeResponse:
+		return t.NumDeleted
+	case *proto.MultiIncrementResponse:
+		return int64(len(t.NewValues))
+	}
+	return 1
+}
+
 // setLastUpdate updates the wall time (in nanoseconds) since the most
 // recent client operation for this transaction through the coordinator.
 func (tm *txnMetadata) setLastUpdate(nowNanos int64) {
@@ -475,6 +487,9 @@ func (tc *TxnCoordSender) sendOne(ctx context.Context, call proto.Call) {
 					}
 				}
 				txnMeta.addKeyRange(header.Key, header.EndKey)
+				// Count the intents written, which is checked against
+				// the maximum on the transaction's next write.
+				txn.IntentCount = txnMeta.txn.IntentCount + writtenIntents(call.Reply)
 			}
 			// Update our record of this transaction.
 			if txnMeta != nil {
@@ -571,6 +586,15 @@ func updateForBatch(args proto.Request, bHeader proto.RequestHeader) error {
 	} else {
 		aHeader.Txn = bHeader.Txn
 	}
+	// Reads use the batch's read consistency unless they specify their
+	// own, so that a non-transactional batch may mix consistent and
+	// inconsistent reads. Transactional batches can't read inconsistently.
+	if proto.IsReadOnly(args) && aHeader.ReadConsistency == proto.CONSISTENT {
+		aHeader.ReadConsistency = bHeader.ReadConsistency
+	}
+	if aHeader.ReadConsistency == proto.INCONSISTENT && bHeader.Txn != nil {
+		return util.Errorf("cannot allow inconsistent reads within a transaction")
+	}
 	return nil
 }
 
@@ -585,6 +609,17 @@ func (tc *TxnCoordSender) sendBatch(ctx context.Context, batchArgs *proto.BatchR
 	// as needed.
 	// TODO(spencer): send calls in parallel.
 	batchReply.Txn = batchArgs.Txn
+	// A batch consisting only of conditional puts is sent in full even
+	// after a condition fails, so that all failed conditions are reported
+	// together. The error aborts the transaction, so none of the batch's
+	// writes take effect. Outside of a transaction, the batch runs in a
+	// transaction of its own.
+	collectCondFailures := isConditionalPutBatch(batchArgs)
+	if collectCondFailures && batchArgs.Txn == nil {
+		tc.sendConditionalPutBatch(batchArgs, batchReply)
+		return
+	}
+	var condFailures []proto.ConditionFailedError
 	for i := range batchArgs.Requests {
 		args := batchArgs.Requests[i].GetValue().(proto.Request)
 		if err := updateForBatch(args, batchArgs.RequestHeader); err != nil {
@@ -600,15 +635,59 @@ func (tc *TxnCoordSender) sendBatch(ctx context.Context, batchArgs *proto.BatchR
 			call.Reply = batchReply.Responses[i].GetValue().(proto.Response)
 		}
 		tc.sendOne(ctx, call)
-		// Amalgamate transaction updates and propagate first error, if applicable.
+		// Amalgamate transaction updates and execution statistics, and
+		// propagate first error, if applicable.
 		if batchReply.Txn != nil {
 			batchReply.Txn.Update(call.Reply.Header().Txn)
 		}
+		batchReply.AddStats(call.Reply.Header().Stats)
 		if call.Reply.Header().Error != nil {
+			if cErr, ok := call.Reply.Header().GoError().(*proto.ConditionFailedError); ok && collectCondFailures {
+				cErr.Index = int32(i)
+				condFailures = append(condFailures, *cErr)
+				continue
+			}
 			batchReply.Error = call.Reply.Header().Error
 			return
 		}
 	}
+	if len(condFailures) > 0 {
+		batchReply.Header().SetGoError(&proto.BatchConditionFailedError{Failures: condFailures})
+	}
+}
+
+// isConditionalPutBatch returns true if the batch is non-empty and
+// contains only conditional puts.
+func isConditionalPutBatch(batchArgs *proto.BatchRequest) bool {
+	for _, union := range batchArgs.Requests {
+		if _, ok := union.GetValue().(*proto.ConditionalPutRequest); !ok {
+			return false
+		}
+	}
+	return len(batchArgs.Requests) > 0
+}
+
+// sendConditionalPutBatch runs a non-transactional batch of conditional
+// puts in a transaction, so that its writes are applied only if all of
+// its conditions hold and no other writer can change a checked key
+// before the batch commits.
+func (tc *TxnCoordSender) sendConditionalPutBatch(batchArgs *proto.BatchRequest, batchReply *proto.BatchResponse) {
+	tmpDB := client.NewDBWithPriority(tc, batchArgs.GetUserPriority())
+	err := tmpDB.Txn(func(txn *client.Txn) error {
+		txn.SetDebugName("conditional put batch", 0)
+		batchReply.Reset()
+		b := &client.Batch{}
+		b.InternalAddCall(proto.Call{
+			Args:  gogoproto.Clone(batchArgs).(*proto.BatchRequest),
+			Reply: batchReply,
+		})
+		return txn.Run(b)
+	})
+	// The transaction is internal to the batch.
+	batchReply.Txn = nil
+	if err != nil && batchReply.Error == nil {
+		batchReply.Header().SetGoError(err)
+	}
 }
 
 // updateResponseTxn updates the response txn based on the response
@@ -30,7 +30,9 @@ import (
 
 	"github.com/cockroachdb/cockroach/client"
 	"github.com/cockroachdb/cockroach/proto"
+	"github.com/cockroachdb/cockroach/storage"
 	"github.com/cockroachdb/cockroach/storage/engine"
+	"github.com/cockroachdb/cockroach/testutils"
 	"github.com/cockroachdb/cockroach/util"
 	"github.com/cockroachdb/cockroach/util/caller"
 	"github.com/cockroachdb/cockroach/util/hlc"
@@ -267,6 +269,59 @@ func TestTxnCoordSenderKeyRanges(t *testing.T) {
 	}
 }
 
+// TestTxnCoordSenderIntentLimit verifies that the coordinator counts
+// the intents written by a transaction, one per key, and that writes
+// past the maximum number of intents fail with a TxnTooLargeError.
+func TestTxnCoordSenderIntentLimit(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	const maxIntents = 4
+	defer func(maxIntents int64) {
+		storage.TestStoreContext.MaxTxnIntents = maxIntents
+	}(storage.TestStoreContext.MaxTxnIntents)
+	storage.TestStoreContext.MaxTxnIntents = maxIntents
+
+	s := createTestDB(t)
+	defer s.Stop()
+	defer teardownHeartbeats(s.Sender)
+	for _, key := range []string{"a", "b"} {
+		if err := s.DB.Put(key, "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	txn := newTxn(s.Clock, proto.Key("a"))
+	send := func(args proto.Request, reply proto.Response) error {
+		if err := sendCall(s.Sender, proto.Call{Args: args, Reply: reply}); err != nil {
+			return err
+		}
+		txn.Update(reply.Header().Txn)
+		return nil
+	}
+	// A deletion of a key range counts one intent per key deleted.
+	if err := send(createDeleteRangeRequest(proto.Key("a"), proto.Key("c"), txn), &proto.DeleteRangeResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if txn.IntentCount != 2 {
+		t.Fatalf("expected an intent count of 2; got %d", txn.IntentCount)
+	}
+	// Writes up to the limit succeed.
+	for i, key := range []string{"c", "d"} {
+		if err := send(createPutRequest(proto.Key(key), []byte("value"), txn), &proto.PutResponse{}); err != nil {
+			t.Fatal(err)
+		}
+		if txn.IntentCount != int64(i+3) {
+			t.Fatalf("expected an intent count of %d; got %d", i+3, txn.IntentCount)
+		}
+	}
+	// Further writes fail.
+	err := send(createPutRequest(proto.Key("e"), []byte("value"), txn), &proto.PutResponse{})
+	if tErr, ok := err.(*proto.TxnTooLargeError); !ok {
+		t.Fatalf("expected a TxnTooLargeError; got %v", err)
+	} else if tErr.IntentCount != maxIntents || tErr.MaxIntents != maxIntents {
+		t.Errorf("expected %d of %d intents; got %+v", maxIntents, maxIntents, tErr)
+	}
+}
+
 // TestTxnCoordSenderMultipleTxns verifies correct operation with
 // multiple outstanding transactions.
 func TestTxnCoordSenderMultipleTxns(t *testing.T) {
@@ -683,6 +738,119 @@ func TestTxnCoordSenderBatchTransaction(t *testing.T) {
 	}
 }
 
+// TestTxnCoordSenderBatchReadConsistency verifies that the reads of a
+// batch inherit its read consistency unless they specify their own, and
+// that transactional batches can't contain inconsistent reads.
+func TestTxnCoordSenderBatchReadConsistency(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	stopper := stop.NewStopper()
+	defer stopper.Stop()
+	clock := hlc.NewClock(hlc.UnixNano)
+	var consistencies []proto.ReadConsistencyType
+	ts := NewTxnCoordSender(newTestSender(func(call proto.Call) {
+		consistencies = append(consistencies, call.Args.Header().ReadConsistency)
+	}), clock, false, nil, stopper)
+
+	testCases := []struct {
+		batch, arg, exp proto.ReadConsistencyType
+	}{
+		{proto.CONSISTENT, proto.CONSISTENT, proto.CONSISTENT},
+		{proto.CONSISTENT, proto.INCONSISTENT, proto.INCONSISTENT},
+		{proto.INCONSISTENT, proto.CONSISTENT, proto.INCONSISTENT},
+		{proto.INCONSISTENT, proto.INCONSISTENT, proto.INCONSISTENT},
+	}
+	for i, test := range testCases {
+		consistencies = nil
+		bArgs := &proto.BatchRequest{}
+		bArgs.ReadConsistency = test.batch
+		bArgs.Add(&proto.GetRequest{RequestHeader: proto.RequestHeader{Key: proto.Key("a"), ReadConsistency: test.arg}})
+		bArgs.Add(&proto.GetRequest{RequestHeader: proto.RequestHeader{Key: proto.Key("b"), ReadConsistency: proto.CONSENSUS}})
+		bReply := &proto.BatchResponse{}
+		ts.Send(context.Background(), proto.Call{Args: bArgs, Reply: bReply})
+		if err := bReply.GoError(); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if exp := []proto.ReadConsistencyType{test.exp, proto.CONSENSUS}; !reflect.DeepEqual(consistencies, exp) {
+			t.Errorf("%d: expected read consistencies %v; got %v", i, exp, consistencies)
+		}
+	}
+
+	// Inconsistent reads aren't allowed in transactional batches.
+	consistencies = nil
+	bArgs := &proto.BatchRequest{}
+	bArgs.Txn = &proto.Transaction{ID: []byte("txn")}
+	bArgs.Add(&proto.GetRequest{RequestHeader: proto.RequestHeader{Key: proto.Key("a"), ReadConsistency: proto.INCONSISTENT}})
+	bReply := &proto.BatchResponse{}
+	ts.Send(context.Background(), proto.Call{Args: bArgs, Reply: bReply})
+	if err := bReply.GoError(); !testutils.IsError(err, "inconsistent reads within a transaction") {
+		t.Errorf("expected inconsistent read to be refused; got %v", err)
+	}
+	if len(consistencies) != 0 {
+		t.Errorf("expected no call to be sent; got %d", len(consistencies))
+	}
+}
+
+// TestTxnCoordSenderConditionalPutBatch verifies that a batch of
+// conditional puts, both inside and outside of a transaction, reports
+// every failed condition along with its index and actual value, and that
+// none of its writes are applied.
+func TestTxnCoordSenderConditionalPutBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	s := createTestDB(t)
+	defer s.Stop()
+
+	for _, kv := range []struct{ key, value string }{{"a", "1"}, {"b", "2"}, {"c", "3"}} {
+		if err := s.DB.Put(kv.key, kv.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newBatch := func() *client.Batch {
+		b := &client.Batch{}
+		b.CPut("a", "x", "1")
+		b.CPut("b", "y", "wrong")
+		b.CPut("c", "z", "3")
+		b.CPut("d", "w", "missing")
+		return b
+	}
+	for i, run := range []func() error{
+		func() error {
+			return s.DB.Txn(func(txn *client.Txn) error {
+				return txn.Run(newBatch())
+			})
+		},
+		func() error {
+			return s.DB.Run(newBatch())
+		},
+	} {
+		err := run()
+		bErr, ok := err.(*proto.BatchConditionFailedError)
+		if !ok {
+			t.Fatalf("%d: expected BatchConditionFailedError; got %v", i, err)
+		}
+		if len(bErr.Failures) != 2 {
+			t.Fatalf("%d: expected 2 failures; got %s", i, bErr)
+		}
+		if f := bErr.Failures[0]; f.Index != 1 || f.ActualValue == nil || !bytes.Equal(f.ActualValue.Bytes, []byte("2")) {
+			t.Errorf("%d: expected failure of index 1 with actual value \"2\"; got %s", i, &f)
+		}
+		if f := bErr.Failures[1]; f.Index != 3 || f.ActualValue != nil {
+			t.Errorf("%d: expected failure of index 3 without actual value; got %s", i, &f)
+		}
+
+		// None of the conditional puts must have been applied.
+		for _, kv := range []struct{ key, value string }{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", ""}} {
+			gr, err := s.DB.Get(kv.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gr.ValueBytes(), []byte(kv.value)) {
+				t.Errorf("%d: expected %q for key %q; got %q", i, kv.value, kv.key, gr.ValueBytes())
+			}
+		}
+	}
+}
+
 // TestTxnDrainingNode tests that pending transactions tasks' intents are resolved
 // if they commit while draining, and that a NodeUnavailableError is received
 // when attempting to run a new transaction on a draining node.
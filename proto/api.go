@@ -18,8 +18,10 @@
 package proto
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
+	"sort"
 
 	"github.com/cockroachdb/cockroach/util/retry"
 	gogoproto "github.com/gogo/protobuf/proto"
@@ -166,9 +168,24 @@ func (rh *ResponseHeader) Combine(otherRH *ResponseHeader) {
 		if rh.Txn != nil && otherRH.GetTxn() == nil {
 			rh.Txn = nil
 		}
+		rh.AddStats(otherRH.GetStats())
+		rh.ReadSet = append(rh.ReadSet, otherRH.GetReadSet()...)
 	}
 }
 
+// AddStats accumulates the supplied execution statistics into the
+// header's.
+func (rh *ResponseHeader) AddStats(stats *ExecutionStats) {
+	if stats == nil {
+		return
+	}
+	if rh.Stats == nil {
+		rh.Stats = &ExecutionStats{}
+	}
+	rh.Stats.KeysScanned += stats.KeysScanned
+	rh.Stats.BytesRead += stats.BytesRead
+}
+
 // Combine implements the Combinable interface.
 func (sr *ScanResponse) Combine(c Response) {
 	otherSR := c.(*ScanResponse)
@@ -192,6 +209,12 @@ func (dr *DeleteRangeResponse) Combine(c Response) {
 	otherDR := c.(*DeleteRangeResponse)
 	if dr != nil {
 		dr.NumDeleted += otherDR.GetNumDeleted()
+		dr.Keys = append(dr.Keys, otherDR.GetKeys()...)
+		// Resuming from the first resume key may revisit keys already
+		// deleted in later ranges, which is harmless.
+		if dr.ResumeKey == nil {
+			dr.ResumeKey = otherDR.GetResumeKey()
+		}
 		dr.Header().Combine(otherDR.Header())
 	}
 }
@@ -322,6 +345,71 @@ func (br *BatchResponse) Add(reply Response) {
 	br.Responses = append(br.Responses, union)
 }
 
+// MarshalCanonical returns a deterministic encoding of the batch
+// response, intended for comparing the responses of different replicas
+// in consistency checks. Unlike Marshal, it sorts the repeated fields
+// which hold sets of values (the read sets and certain nodes found in
+// response headers, the intents of write intent errors and the keys
+// resolved by EndTransaction), so that responses which differ only in
+// the order of such values encode identically. The receiver is not
+// modified and the wire format used by Marshal is unaffected.
+func (br *BatchResponse) MarshalCanonical() ([]byte, error) {
+	data, err := br.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	// Work on a deep copy.
+	var c BatchResponse
+	if err := c.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	c.ResponseHeader.canonicalize()
+	for _, union := range c.Responses {
+		reply := union.GetValue().(Response)
+		reply.Header().canonicalize()
+		if etReply, ok := reply.(*EndTransactionResponse); ok {
+			sort.Sort(KeySlice(etReply.Resolved))
+		}
+	}
+	return c.Marshal()
+}
+
+// canonicalize sorts the set-valued repeated fields of the header; see
+// BatchResponse.MarshalCanonical.
+func (rh *ResponseHeader) canonicalize() {
+	sort.Sort(readSetByKey(rh.ReadSet))
+	if rh.Txn != nil {
+		sort.Sort(Int32Slice(rh.Txn.CertainNodes.Nodes))
+	}
+	if rh.Error != nil && rh.Error.Detail != nil && rh.Error.Detail.WriteIntent != nil {
+		sort.Sort(intentsByKey(rh.Error.Detail.WriteIntent.Intents))
+	}
+}
+
+// readSetByKey sorts read set entries by key and timestamp.
+type readSetByKey []ReadSetEntry
+
+func (s readSetByKey) Len() int      { return len(s) }
+func (s readSetByKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s readSetByKey) Less(i, j int) bool {
+	if c := bytes.Compare(s[i].Key, s[j].Key); c != 0 {
+		return c < 0
+	}
+	return s[i].Timestamp.Less(s[j].Timestamp)
+}
+
+// intentsByKey sorts intents by key and end key.
+type intentsByKey []Intent
+
+func (s intentsByKey) Len() int      { return len(s) }
+func (s intentsByKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s intentsByKey) Less(i, j int) bool {
+	if c := bytes.Compare(s[i].Key, s[j].Key); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(s[i].EndKey, s[j].EndKey) < 0
+}
+
 // Bounded is implemented by request types which have a bounded number of
 // result rows, such as Scan.
 type Bounded interface {
@@ -339,6 +427,24 @@ func (sr *ScanRequest) SetBound(bound int64) {
 	sr.MaxResults = bound
 }
 
+// Matches returns true if the key/value pair satisfies all of the
+// predicate's set conditions. A nil predicate matches everything.
+func (p *ScanPredicate) Matches(kv KeyValue) bool {
+	if p == nil {
+		return true
+	}
+	if p.KeyPrefix != nil && !bytes.HasPrefix(kv.Key, p.KeyPrefix) {
+		return false
+	}
+	if p.ValueStart != nil && bytes.Compare(kv.Value.Bytes, p.ValueStart) < 0 {
+		return false
+	}
+	if p.ValueEnd != nil && bytes.Compare(kv.Value.Bytes, p.ValueEnd) >= 0 {
+		return false
+	}
+	return true
+}
+
 // GetBound returns the MaxResults field in ReverseScanRequest.
 func (sr *ReverseScanRequest) GetBound() int64 {
 	return sr.GetMaxResults()
@@ -374,9 +480,15 @@ func (*PutRequest) Method() Method { return Put }
 // Method implements the Request interface.
 func (*ConditionalPutRequest) Method() Method { return ConditionalPut }
 
+// Method implements the Request interface.
+func (*CheckAndPutRequest) Method() Method { return CheckAndPut }
+
 // Method implements the Request interface.
 func (*IncrementRequest) Method() Method { return Increment }
 
+// Method implements the Request interface.
+func (*MultiIncrementRequest) Method() Method { return MultiIncrement }
+
 // Method implements the Request interface.
 func (*DeleteRequest) Method() Method { return Delete }
 
@@ -437,9 +549,15 @@ func (*PutRequest) CreateReply() Response { return &PutResponse{} }
 // CreateReply implements the Request interface.
 func (*ConditionalPutRequest) CreateReply() Response { return &ConditionalPutResponse{} }
 
+// CreateReply implements the Request interface.
+func (*CheckAndPutRequest) CreateReply() Response { return &CheckAndPutResponse{} }
+
 // CreateReply implements the Request interface.
 func (*IncrementRequest) CreateReply() Response { return &IncrementResponse{} }
 
+// CreateReply implements the Request interface.
+func (*MultiIncrementRequest) CreateReply() Response { return &MultiIncrementResponse{} }
+
 // CreateReply implements the Request interface.
 func (*DeleteRequest) CreateReply() Response { return &DeleteResponse{} }
 
@@ -496,7 +614,9 @@ func (*BatchRequest) CreateReply() Response { return &BatchResponse{} }
 func (*GetRequest) flags() int                { return isRead }
 func (*PutRequest) flags() int                { return isWrite | isTxnWrite }
 func (*ConditionalPutRequest) flags() int     { return isRead | isWrite | isTxnWrite }
+func (*CheckAndPutRequest) flags() int        { return isRead | isWrite | isTxnWrite }
 func (*IncrementRequest) flags() int          { return isRead | isWrite | isTxnWrite }
+func (*MultiIncrementRequest) flags() int     { return isRead | isWrite | isTxnWrite | isRange }
 func (*DeleteRequest) flags() int             { return isWrite | isTxnWrite }
 func (*DeleteRangeRequest) flags() int        { return isWrite | isTxnWrite | isRange }
 func (*ScanRequest) flags() int               { return isRead | isRange }
@@ -18,18 +18,25 @@
 		ClientCmdID
 		RequestHeader
 		ResponseHeader
+		ExecutionStats
+		ReadSetEntry
 		GetRequest
 		GetResponse
 		PutRequest
 		PutResponse
 		ConditionalPutRequest
 		ConditionalPutResponse
+		CheckAndPutRequest
+		CheckAndPutResponse
 		IncrementRequest
 		IncrementResponse
+		MultiIncrementRequest
+		MultiIncrementResponse
 		DeleteRequest
 		DeleteResponse
 		DeleteRangeRequest
 		DeleteRangeResponse
+		ScanPredicate
 		ScanRequest
 		ScanResponse
 		ReverseScanRequest
@@ -73,6 +80,8 @@ import math "math"
 import io "io"
 import fmt "fmt"
 
+import time "time"
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto1.Marshal
 var _ = math.Inf
@@ -248,6 +257,22 @@ type RequestHeader struct {
 	// operations. The default is CONSISTENT. This value is ignored for
 	// write operations.
 	ReadConsistency ReadConsistencyType `protobuf:"varint,9,opt,name=read_consistency,enum=cockroach.proto.ReadConsistencyType" json:"read_consistency"`
+	// RecordReadSet, if true, requests that the keys read by the request
+	// and the timestamps of the versions read be returned in the
+	// response's read set, so that a transaction coordinator can later
+	// verify that none of them changed. This value is ignored for
+	// requests which don't read.
+	RecordReadSet bool `protobuf:"varint,10,opt,name=record_read_set" json:"record_read_set"`
+	// SkipTimestampCacheUpdate, if true, prevents the request from being
+	// recorded in the timestamp cache. It is meant for internal writes
+	// which later writes need not be ordered after, such as lease
+	// heartbeats. This value is ignored for read-only requests.
+	SkipTimestampCacheUpdate bool `protobuf:"varint,11,opt,name=skip_timestamp_cache_update" json:"skip_timestamp_cache_update"`
+	// MaxStaleness, if positive, permits a non-transactional read to be
+	// served by a replica not holding the leader lease at a closed
+	// timestamp no more than MaxStaleness in the past. If no such timestamp
+	// is available, the read is served as usual by the lease holder.
+	MaxStaleness time.Duration `protobuf:"varint,12,opt,name=max_staleness,casttype=time.Duration" json:"max_staleness"`
 }
 
 func (m *RequestHeader) Reset()         { *m = RequestHeader{} }
@@ -319,6 +344,27 @@ func (m *RequestHeader) GetReadConsistency() ReadConsistencyType {
 	return CONSISTENT
 }
 
+func (m *RequestHeader) GetRecordReadSet() bool {
+	if m != nil {
+		return m.RecordReadSet
+	}
+	return false
+}
+
+func (m *RequestHeader) GetSkipTimestampCacheUpdate() bool {
+	if m != nil {
+		return m.SkipTimestampCacheUpdate
+	}
+	return false
+}
+
+func (m *RequestHeader) GetMaxStaleness() time.Duration {
+	if m != nil {
+		return m.MaxStaleness
+	}
+	return 0
+}
+
 // ResponseHeader is returned with every storage node response.
 type ResponseHeader struct {
 	// Error is non-nil if an error occurred.
@@ -335,6 +381,16 @@ type ResponseHeader struct {
 	// transaction. The transaction timestamp and/or priority may have
 	// been updated, depending on the outcome of the request.
 	Txn *Transaction `protobuf:"bytes,3,opt,name=txn" json:"txn,omitempty"`
+	// Stats describes the work performed to service the request. It is
+	// nil if the request read no data. For a BatchResponse, it holds the
+	// totals over all of the batch's requests.
+	Stats *ExecutionStats `protobuf:"bytes,4,opt,name=stats" json:"stats,omitempty"`
+	// ReadSet holds the keys read and the timestamps of the versions read
+	// if the request set RecordReadSet.
+	ReadSet []ReadSetEntry `protobuf:"bytes,5,rep,name=read_set" json:"read_set,omitempty"`
+	// Staleness is how far in the past of the serving replica's clock a
+	// read permitted to be stale by RequestHeader.MaxStaleness was served.
+	Staleness time.Duration `protobuf:"varint,6,opt,name=staleness,casttype=time.Duration" json:"staleness"`
 }
 
 func (m *ResponseHeader) Reset()         { *m = ResponseHeader{} }
@@ -362,6 +418,81 @@ func (m *ResponseHeader) GetTxn() *Transaction {
 	return nil
 }
 
+func (m *ResponseHeader) GetStats() *ExecutionStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+func (m *ResponseHeader) GetReadSet() []ReadSetEntry {
+	if m != nil {
+		return m.ReadSet
+	}
+	return nil
+}
+
+func (m *ResponseHeader) GetStaleness() time.Duration {
+	if m != nil {
+		return m.Staleness
+	}
+	return 0
+}
+
+// ExecutionStats holds counters describing the data read while
+// executing a request, for use in accounting for its cost.
+type ExecutionStats struct {
+	// KeysScanned is the number of MVCC keys read, including metadata,
+	// deletion tombstones and intents.
+	KeysScanned int64 `protobuf:"varint,1,opt,name=keys_scanned" json:"keys_scanned"`
+	// BytesRead is the number of key and value bytes read.
+	BytesRead int64 `protobuf:"varint,2,opt,name=bytes_read" json:"bytes_read"`
+}
+
+func (m *ExecutionStats) Reset()         { *m = ExecutionStats{} }
+func (m *ExecutionStats) String() string { return proto1.CompactTextString(m) }
+func (*ExecutionStats) ProtoMessage()    {}
+
+func (m *ExecutionStats) GetKeysScanned() int64 {
+	if m != nil {
+		return m.KeysScanned
+	}
+	return 0
+}
+
+func (m *ExecutionStats) GetBytesRead() int64 {
+	if m != nil {
+		return m.BytesRead
+	}
+	return 0
+}
+
+// A ReadSetEntry records a key read by a request and the timestamp of
+// the version which was read. The timestamp is zero if the key had no
+// value.
+type ReadSetEntry struct {
+	Key       Key       `protobuf:"bytes,1,opt,name=key,casttype=Key" json:"key,omitempty"`
+	Timestamp Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp"`
+}
+
+func (m *ReadSetEntry) Reset()         { *m = ReadSetEntry{} }
+func (m *ReadSetEntry) String() string { return proto1.CompactTextString(m) }
+func (*ReadSetEntry) ProtoMessage()    {}
+
+func (m *ReadSetEntry) GetKey() Key {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *ReadSetEntry) GetTimestamp() Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return Timestamp{}
+}
+
 // A GetRequest is the argument for the Get() method.
 type GetRequest struct {
 	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
@@ -459,6 +590,49 @@ func (m *ConditionalPutResponse) Reset()         { *m = ConditionalPutResponse{}
 func (m *ConditionalPutResponse) String() string { return proto1.CompactTextString(m) }
 func (*ConditionalPutResponse) ProtoMessage()    {}
 
+// A CheckAndPutRequest is the argument to the CheckAndPut() method.
+//
+// - Sets value if the key doesn't exist.
+// - Sets value if the latest version of the key is not newer than ExpTimestamp.
+// - Otherwise, returns a ConditionFailedError with the actual value of the key.
+type CheckAndPutRequest struct {
+	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
+	// The value to put.
+	Value Value `protobuf:"bytes,2,opt,name=value" json:"value"`
+	// The timestamp of the latest version of the key as observed by the
+	// caller, typically the timestamp of the value returned by a previous
+	// read. The put fails if a newer version has been written since.
+	ExpTimestamp Timestamp `protobuf:"bytes,3,opt,name=exp_timestamp" json:"exp_timestamp"`
+}
+
+func (m *CheckAndPutRequest) Reset()         { *m = CheckAndPutRequest{} }
+func (m *CheckAndPutRequest) String() string { return proto1.CompactTextString(m) }
+func (*CheckAndPutRequest) ProtoMessage()    {}
+
+func (m *CheckAndPutRequest) GetValue() Value {
+	if m != nil {
+		return m.Value
+	}
+	return Value{}
+}
+
+func (m *CheckAndPutRequest) GetExpTimestamp() Timestamp {
+	if m != nil {
+		return m.ExpTimestamp
+	}
+	return Timestamp{}
+}
+
+// A CheckAndPutResponse is the return value from the CheckAndPut()
+// method.
+type CheckAndPutResponse struct {
+	ResponseHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
+}
+
+func (m *CheckAndPutResponse) Reset()         { *m = CheckAndPutResponse{} }
+func (m *CheckAndPutResponse) String() string { return proto1.CompactTextString(m) }
+func (*CheckAndPutResponse) ProtoMessage()    {}
+
 // An IncrementRequest is the argument to the Increment() method. It
 // increments the value for key, and returns the new value. If no
 // value exists for a key, incrementing by 0 is not a noop, but will
@@ -500,6 +674,56 @@ func (m *IncrementResponse) GetNewValue() int64 {
 	return 0
 }
 
+// A MultiIncrementRequest is the argument to the MultiIncrement()
+// method. It increments the values of several keys atomically, each by
+// its own amount, as Increment would. All keys must lie within the
+// span [Key, EndKey) of the header, which must not extend beyond a
+// single range.
+type MultiIncrementRequest struct {
+	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
+	// The keys to increment.
+	Keys []Key `protobuf:"bytes,2,rep,name=keys,casttype=Key" json:"keys,omitempty"`
+	// The amounts by which to increment the keys, one per key.
+	Increments []int64 `protobuf:"varint,3,rep,name=increments" json:"increments,omitempty"`
+}
+
+func (m *MultiIncrementRequest) Reset()         { *m = MultiIncrementRequest{} }
+func (m *MultiIncrementRequest) String() string { return proto1.CompactTextString(m) }
+func (*MultiIncrementRequest) ProtoMessage()    {}
+
+func (m *MultiIncrementRequest) GetKeys() []Key {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *MultiIncrementRequest) GetIncrements() []int64 {
+	if m != nil {
+		return m.Increments
+	}
+	return nil
+}
+
+// A MultiIncrementResponse is the return value from the
+// MultiIncrement() method. The new values are in the order of the keys
+// of the request.
+type MultiIncrementResponse struct {
+	ResponseHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
+	NewValues      []int64 `protobuf:"varint,2,rep,name=new_values" json:"new_values,omitempty"`
+}
+
+func (m *MultiIncrementResponse) Reset()         { *m = MultiIncrementResponse{} }
+func (m *MultiIncrementResponse) String() string { return proto1.CompactTextString(m) }
+func (*MultiIncrementResponse) ProtoMessage()    {}
+
+func (m *MultiIncrementResponse) GetNewValues() []int64 {
+	if m != nil {
+		return m.NewValues
+	}
+	return nil
+}
+
 // A DeleteRequest is the argument to the Delete() method.
 type DeleteRequest struct {
 	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
@@ -525,6 +749,14 @@ type DeleteRangeRequest struct {
 	// If 0, *all* entries between Key (inclusive) and EndKey
 	// (exclusive) are deleted. Must be >= 0.
 	MaxEntriesToDelete int64 `protobuf:"varint,2,opt,name=max_entries_to_delete" json:"max_entries_to_delete"`
+	// If set, only keys whose latest version has a timestamp strictly
+	// less than predicate_timestamp are deleted. Keys which don't match
+	// do not count towards max_entries_to_delete.
+	PredicateTimestamp *Timestamp `protobuf:"bytes,3,opt,name=predicate_timestamp" json:"predicate_timestamp,omitempty"`
+	// If set, the deleted keys are returned in the response. The number
+	// of keys deleted is then bounded by the server, and a resume key is
+	// returned if the bound is reached.
+	ReturnKeys bool `protobuf:"varint,4,opt,name=return_keys" json:"return_keys"`
 }
 
 func (m *DeleteRangeRequest) Reset()         { *m = DeleteRangeRequest{} }
@@ -538,12 +770,32 @@ func (m *DeleteRangeRequest) GetMaxEntriesToDelete() int64 {
 	return 0
 }
 
+func (m *DeleteRangeRequest) GetPredicateTimestamp() *Timestamp {
+	if m != nil {
+		return m.PredicateTimestamp
+	}
+	return nil
+}
+
+func (m *DeleteRangeRequest) GetReturnKeys() bool {
+	if m != nil {
+		return m.ReturnKeys
+	}
+	return false
+}
+
 // A DeleteRangeResponse is the return value from the DeleteRange()
 // method.
 type DeleteRangeResponse struct {
 	ResponseHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
 	// Number of entries removed.
 	NumDeleted int64 `protobuf:"varint,2,opt,name=num_deleted" json:"num_deleted"`
+	// The deleted keys, if return_keys was set in the request.
+	Keys []Key `protobuf:"bytes,3,rep,name=keys,casttype=Key" json:"keys,omitempty"`
+	// If return_keys was set and the deletion stopped because a limit was
+	// reached, the key from which the deletion can be resumed. There may be
+	// no keys left to delete at or after it.
+	ResumeKey Key `protobuf:"bytes,4,opt,name=resume_key,casttype=Key" json:"resume_key,omitempty"`
 }
 
 func (m *DeleteRangeResponse) Reset()         { *m = DeleteRangeResponse{} }
@@ -557,6 +809,57 @@ func (m *DeleteRangeResponse) GetNumDeleted() int64 {
 	return 0
 }
 
+func (m *DeleteRangeResponse) GetKeys() []Key {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *DeleteRangeResponse) GetResumeKey() Key {
+	if m != nil {
+		return m.ResumeKey
+	}
+	return nil
+}
+
+// A ScanPredicate restricts the rows returned by a scan to those
+// matching all of its set conditions. It is evaluated on the server
+// while scanning, so that non-matching rows are never returned.
+type ScanPredicate struct {
+	// If set, only rows whose key has this prefix match.
+	KeyPrefix Key `protobuf:"bytes,1,opt,name=key_prefix,casttype=Key" json:"key_prefix,omitempty"`
+	// If set, only rows whose value bytes are >= value_start match.
+	ValueStart []byte `protobuf:"bytes,2,opt,name=value_start" json:"value_start,omitempty"`
+	// If set, only rows whose value bytes are < value_end match.
+	ValueEnd []byte `protobuf:"bytes,3,opt,name=value_end" json:"value_end,omitempty"`
+}
+
+func (m *ScanPredicate) Reset()         { *m = ScanPredicate{} }
+func (m *ScanPredicate) String() string { return proto1.CompactTextString(m) }
+func (*ScanPredicate) ProtoMessage()    {}
+
+func (m *ScanPredicate) GetKeyPrefix() Key {
+	if m != nil {
+		return m.KeyPrefix
+	}
+	return nil
+}
+
+func (m *ScanPredicate) GetValueStart() []byte {
+	if m != nil {
+		return m.ValueStart
+	}
+	return nil
+}
+
+func (m *ScanPredicate) GetValueEnd() []byte {
+	if m != nil {
+		return m.ValueEnd
+	}
+	return nil
+}
+
 // A ScanRequest is the argument to the Scan() method. It specifies the
 // start and end keys for an ascending scan of [start,end) and the maximum
 // number of results.
@@ -564,6 +867,9 @@ type ScanRequest struct {
 	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
 	// If 0, there is no limit on the number of retrieved entries. Must be >= 0.
 	MaxResults int64 `protobuf:"varint,2,opt,name=max_results" json:"max_results"`
+	// If set, only rows matching the predicate are returned. Rows which
+	// don't match do not count towards max_results.
+	Predicate *ScanPredicate `protobuf:"bytes,3,opt,name=predicate" json:"predicate,omitempty"`
 }
 
 func (m *ScanRequest) Reset()         { *m = ScanRequest{} }
@@ -577,6 +883,13 @@ func (m *ScanRequest) GetMaxResults() int64 {
 	return 0
 }
 
+func (m *ScanRequest) GetPredicate() *ScanPredicate {
+	if m != nil {
+		return m.Predicate
+	}
+	return nil
+}
+
 // A ScanResponse is the return value from the Scan() method.
 type ScanResponse struct {
 	ResponseHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
@@ -859,30 +1172,54 @@ func (m *RangeLookupResponse) GetRanges() []RangeDescriptor {
 // gossip protocol.
 type HeartbeatTxnRequest struct {
 	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
+	// If set, the transactions to heartbeat instead of the one in the
+	// header, all in a single command. Their keys must lie within
+	// [key, end_key).
+	Txns []Transaction `protobuf:"bytes,2,rep,name=txns" json:"txns,omitempty"`
 }
 
 func (m *HeartbeatTxnRequest) Reset()         { *m = HeartbeatTxnRequest{} }
 func (m *HeartbeatTxnRequest) String() string { return proto1.CompactTextString(m) }
 func (*HeartbeatTxnRequest) ProtoMessage()    {}
 
+func (m *HeartbeatTxnRequest) GetTxns() []Transaction {
+	if m != nil {
+		return m.Txns
+	}
+	return nil
+}
+
 // A HeartbeatTxnResponse is the return value from the HeartbeatTxn()
 // method. It returns the transaction info in the response header. The
 // returned transaction lets the coordinator know the disposition of
 // the transaction (i.e. aborted, committed, or pending).
 type HeartbeatTxnResponse struct {
 	ResponseHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
+	// If txns was set in the request, the updated transaction records,
+	// in request order.
+	Txns []Transaction `protobuf:"bytes,2,rep,name=txns" json:"txns,omitempty"`
 }
 
 func (m *HeartbeatTxnResponse) Reset()         { *m = HeartbeatTxnResponse{} }
 func (m *HeartbeatTxnResponse) String() string { return proto1.CompactTextString(m) }
 func (*HeartbeatTxnResponse) ProtoMessage()    {}
 
+func (m *HeartbeatTxnResponse) GetTxns() []Transaction {
+	if m != nil {
+		return m.Txns
+	}
+	return nil
+}
+
 // A GCRequest is arguments to the GC() method. It's sent by range
 // leaders after scanning range data to find expired MVCC values.
 type GCRequest struct {
 	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
 	GCMeta        GCMetadata        `protobuf:"bytes,2,opt,name=gc_meta" json:"gc_meta"`
 	Keys          []GCRequest_GCKey `protobuf:"bytes,3,rep,name=keys" json:"keys"`
+	// TxnKeys lists the keys of abandoned transaction records to remove.
+	// Records of transactions which are not aborted are left alone.
+	TxnKeys []Key `protobuf:"bytes,4,rep,name=txn_keys,casttype=Key" json:"txn_keys,omitempty"`
 }
 
 func (m *GCRequest) Reset()         { *m = GCRequest{} }
@@ -903,6 +1240,13 @@ func (m *GCRequest) GetKeys() []GCRequest_GCKey {
 	return nil
 }
 
+func (m *GCRequest) GetTxnKeys() []Key {
+	if m != nil {
+		return m.TxnKeys
+	}
+	return nil
+}
+
 type GCRequest_GCKey struct {
 	Key       Key       `protobuf:"bytes,1,opt,name=key,casttype=Key" json:"key,omitempty"`
 	Timestamp Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp"`
@@ -1132,6 +1476,11 @@ func (*TruncateLogResponse) ProtoMessage()    {}
 type LeaderLeaseRequest struct {
 	RequestHeader `protobuf:"bytes,1,opt,name=header,embedded=header" json:"header"`
 	Lease         Lease `protobuf:"bytes,2,opt,name=lease" json:"lease"`
+	// When the lease is transferred by its previous holder, the low water
+	// mark and the most recent entries of the previous holder's timestamp
+	// cache, with which the new holder initializes its own.
+	TimestampCacheLowWater Timestamp             `protobuf:"bytes,3,opt,name=timestamp_cache_low_water" json:"timestamp_cache_low_water"`
+	TimestampCache         []TimestampCacheEntry `protobuf:"bytes,4,rep,name=timestamp_cache" json:"timestamp_cache"`
 }
 
 func (m *LeaderLeaseRequest) Reset()         { *m = LeaderLeaseRequest{} }
@@ -1145,6 +1494,20 @@ func (m *LeaderLeaseRequest) GetLease() Lease {
 	return Lease{}
 }
 
+func (m *LeaderLeaseRequest) GetTimestampCacheLowWater() Timestamp {
+	if m != nil {
+		return m.TimestampCacheLowWater
+	}
+	return Timestamp{}
+}
+
+func (m *LeaderLeaseRequest) GetTimestampCache() []TimestampCacheEntry {
+	if m != nil {
+		return m.TimestampCache
+	}
+	return nil
+}
+
 // A LeaderLeaseResponse is the response to a LeaderLease()
 // operation.
 type LeaderLeaseResponse struct {
@@ -1178,6 +1541,8 @@ type RequestUnion struct {
 	Truncate           *TruncateLogRequest        `protobuf:"bytes,18,opt,name=truncate" json:"truncate,omitempty"`
 	LeaderLease        *LeaderLeaseRequest        `protobuf:"bytes,19,opt,name=leader_lease" json:"leader_lease,omitempty"`
 	ReverseScan        *ReverseScanRequest        `protobuf:"bytes,20,opt,name=reverse_scan" json:"reverse_scan,omitempty"`
+	CheckAndPut        *CheckAndPutRequest        `protobuf:"bytes,21,opt,name=check_and_put" json:"check_and_put,omitempty"`
+	MultiIncrement     *MultiIncrementRequest     `protobuf:"bytes,22,opt,name=multi_increment" json:"multi_increment,omitempty"`
 }
 
 func (m *RequestUnion) Reset()         { *m = RequestUnion{} }
@@ -1324,6 +1689,20 @@ func (m *RequestUnion) GetReverseScan() *ReverseScanRequest {
 	return nil
 }
 
+func (m *RequestUnion) GetCheckAndPut() *CheckAndPutRequest {
+	if m != nil {
+		return m.CheckAndPut
+	}
+	return nil
+}
+
+func (m *RequestUnion) GetMultiIncrement() *MultiIncrementRequest {
+	if m != nil {
+		return m.MultiIncrement
+	}
+	return nil
+}
+
 // A ResponseUnion contains exactly one of the optional responses.
 // The values added here must match those in RequestUnion.
 type ResponseUnion struct {
@@ -1347,6 +1726,8 @@ type ResponseUnion struct {
 	Truncate           *TruncateLogResponse        `protobuf:"bytes,18,opt,name=truncate" json:"truncate,omitempty"`
 	LeaderLease        *LeaderLeaseResponse        `protobuf:"bytes,19,opt,name=leader_lease" json:"leader_lease,omitempty"`
 	ReverseScan        *ReverseScanResponse        `protobuf:"bytes,20,opt,name=reverse_scan" json:"reverse_scan,omitempty"`
+	CheckAndPut        *CheckAndPutResponse        `protobuf:"bytes,21,opt,name=check_and_put" json:"check_and_put,omitempty"`
+	MultiIncrement     *MultiIncrementResponse     `protobuf:"bytes,22,opt,name=multi_increment" json:"multi_increment,omitempty"`
 }
 
 func (m *ResponseUnion) Reset()         { *m = ResponseUnion{} }
@@ -1493,6 +1874,20 @@ func (m *ResponseUnion) GetReverseScan() *ReverseScanResponse {
 	return nil
 }
 
+func (m *ResponseUnion) GetCheckAndPut() *CheckAndPutResponse {
+	if m != nil {
+		return m.CheckAndPut
+	}
+	return nil
+}
+
+func (m *ResponseUnion) GetMultiIncrement() *MultiIncrementResponse {
+	if m != nil {
+		return m.MultiIncrement
+	}
+	return nil
+}
+
 // A BatchRequest contains one or more requests to be executed in
 // parallel, or if applicable (based on write-only commands and
 // range-locality), as a single update.
@@ -1637,6 +2032,25 @@ func (m *RequestHeader) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x48
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ReadConsistency))
+	data[i] = 0x50
+	i++
+	if m.RecordReadSet {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	data[i] = 0x58
+	i++
+	if m.SkipTimestampCacheUpdate {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	data[i] = 0x60
+	i++
+	i = encodeVarintApi(data, i, uint64(m.MaxStaleness))
 	return i, nil
 }
 
@@ -1683,10 +2097,35 @@ func (m *ResponseHeader) MarshalTo(data []byte) (int, error) {
 		}
 		i += n7
 	}
+	if m.Stats != nil {
+		data[i] = 0x22
+		i++
+		i = encodeVarintApi(data, i, uint64(m.Stats.Size()))
+		n8, err := m.Stats.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n8
+	}
+	if len(m.ReadSet) > 0 {
+		for _, msg := range m.ReadSet {
+			data[i] = 0x2a
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	data[i] = 0x30
+	i++
+	i = encodeVarintApi(data, i, uint64(m.Staleness))
 	return i, nil
 }
 
-func (m *GetRequest) Marshal() (data []byte, err error) {
+func (m *ExecutionStats) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1696,23 +2135,21 @@ func (m *GetRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *GetRequest) MarshalTo(data []byte) (int, error) {
+func (m *ExecutionStats) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	data[i] = 0xa
+	data[i] = 0x8
 	i++
-	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n8, err := m.RequestHeader.MarshalTo(data[i:])
-	if err != nil {
-		return 0, err
-	}
-	i += n8
+	i = encodeVarintApi(data, i, uint64(m.KeysScanned))
+	data[i] = 0x10
+	i++
+	i = encodeVarintApi(data, i, uint64(m.BytesRead))
 	return i, nil
 }
 
-func (m *GetResponse) Marshal() (data []byte, err error) {
+func (m *ReadSetEntry) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1722,33 +2159,29 @@ func (m *GetResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *GetResponse) MarshalTo(data []byte) (int, error) {
+func (m *ReadSetEntry) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	data[i] = 0xa
+	if m.Key != nil {
+		data[i] = 0xa
+		i++
+		i = encodeVarintApi(data, i, uint64(len(m.Key)))
+		i += copy(data[i:], m.Key)
+	}
+	data[i] = 0x12
 	i++
-	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n9, err := m.ResponseHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.Timestamp.Size()))
+	n9, err := m.Timestamp.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
 	i += n9
-	if m.Value != nil {
-		data[i] = 0x12
-		i++
-		i = encodeVarintApi(data, i, uint64(m.Value.Size()))
-		n10, err := m.Value.MarshalTo(data[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n10
-	}
 	return i, nil
 }
 
-func (m *PutRequest) Marshal() (data []byte, err error) {
+func (m *GetRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1758,7 +2191,7 @@ func (m *PutRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *PutRequest) MarshalTo(data []byte) (int, error) {
+func (m *GetRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1766,23 +2199,15 @@ func (m *PutRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n11, err := m.RequestHeader.MarshalTo(data[i:])
-	if err != nil {
-		return 0, err
-	}
-	i += n11
-	data[i] = 0x12
-	i++
-	i = encodeVarintApi(data, i, uint64(m.Value.Size()))
-	n12, err := m.Value.MarshalTo(data[i:])
+	n10, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n12
+	i += n10
 	return i, nil
 }
 
-func (m *PutResponse) Marshal() (data []byte, err error) {
+func (m *GetResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1792,7 +2217,7 @@ func (m *PutResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *PutResponse) MarshalTo(data []byte) (int, error) {
+func (m *GetResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1800,15 +2225,25 @@ func (m *PutResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n13, err := m.ResponseHeader.MarshalTo(data[i:])
+	n11, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n13
+	i += n11
+	if m.Value != nil {
+		data[i] = 0x12
+		i++
+		i = encodeVarintApi(data, i, uint64(m.Value.Size()))
+		n12, err := m.Value.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n12
+	}
 	return i, nil
 }
 
-func (m *ConditionalPutRequest) Marshal() (data []byte, err error) {
+func (m *PutRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1818,7 +2253,7 @@ func (m *ConditionalPutRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ConditionalPutRequest) MarshalTo(data []byte) (int, error) {
+func (m *PutRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1826,33 +2261,23 @@ func (m *ConditionalPutRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n14, err := m.RequestHeader.MarshalTo(data[i:])
+	n13, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n14
+	i += n13
 	data[i] = 0x12
 	i++
 	i = encodeVarintApi(data, i, uint64(m.Value.Size()))
-	n15, err := m.Value.MarshalTo(data[i:])
+	n14, err := m.Value.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n15
-	if m.ExpValue != nil {
-		data[i] = 0x1a
-		i++
-		i = encodeVarintApi(data, i, uint64(m.ExpValue.Size()))
-		n16, err := m.ExpValue.MarshalTo(data[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n16
-	}
+	i += n14
 	return i, nil
 }
 
-func (m *ConditionalPutResponse) Marshal() (data []byte, err error) {
+func (m *PutResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1862,7 +2287,7 @@ func (m *ConditionalPutResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ConditionalPutResponse) MarshalTo(data []byte) (int, error) {
+func (m *PutResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1870,15 +2295,15 @@ func (m *ConditionalPutResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n17, err := m.ResponseHeader.MarshalTo(data[i:])
+	n15, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n17
+	i += n15
 	return i, nil
 }
 
-func (m *IncrementRequest) Marshal() (data []byte, err error) {
+func (m *ConditionalPutRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1888,7 +2313,7 @@ func (m *IncrementRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *IncrementRequest) MarshalTo(data []byte) (int, error) {
+func (m *ConditionalPutRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1896,18 +2321,33 @@ func (m *IncrementRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n18, err := m.RequestHeader.MarshalTo(data[i:])
+	n16, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n18
-	data[i] = 0x10
+	i += n16
+	data[i] = 0x12
 	i++
-	i = encodeVarintApi(data, i, uint64(m.Increment))
+	i = encodeVarintApi(data, i, uint64(m.Value.Size()))
+	n17, err := m.Value.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n17
+	if m.ExpValue != nil {
+		data[i] = 0x1a
+		i++
+		i = encodeVarintApi(data, i, uint64(m.ExpValue.Size()))
+		n18, err := m.ExpValue.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n18
+	}
 	return i, nil
 }
 
-func (m *IncrementResponse) Marshal() (data []byte, err error) {
+func (m *ConditionalPutResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1917,7 +2357,7 @@ func (m *IncrementResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *IncrementResponse) MarshalTo(data []byte) (int, error) {
+func (m *ConditionalPutResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1930,13 +2370,10 @@ func (m *IncrementResponse) MarshalTo(data []byte) (int, error) {
 		return 0, err
 	}
 	i += n19
-	data[i] = 0x10
-	i++
-	i = encodeVarintApi(data, i, uint64(m.NewValue))
 	return i, nil
 }
 
-func (m *DeleteRequest) Marshal() (data []byte, err error) {
+func (m *CheckAndPutRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1946,7 +2383,7 @@ func (m *DeleteRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *DeleteRequest) MarshalTo(data []byte) (int, error) {
+func (m *CheckAndPutRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1959,10 +2396,26 @@ func (m *DeleteRequest) MarshalTo(data []byte) (int, error) {
 		return 0, err
 	}
 	i += n20
+	data[i] = 0x12
+	i++
+	i = encodeVarintApi(data, i, uint64(m.Value.Size()))
+	n21, err := m.Value.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n21
+	data[i] = 0x1a
+	i++
+	i = encodeVarintApi(data, i, uint64(m.ExpTimestamp.Size()))
+	n22, err := m.ExpTimestamp.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n22
 	return i, nil
 }
 
-func (m *DeleteResponse) Marshal() (data []byte, err error) {
+func (m *CheckAndPutResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1972,7 +2425,7 @@ func (m *DeleteResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *DeleteResponse) MarshalTo(data []byte) (int, error) {
+func (m *CheckAndPutResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1980,15 +2433,15 @@ func (m *DeleteResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n21, err := m.ResponseHeader.MarshalTo(data[i:])
+	n23, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n21
+	i += n23
 	return i, nil
 }
 
-func (m *DeleteRangeRequest) Marshal() (data []byte, err error) {
+func (m *IncrementRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -1998,7 +2451,7 @@ func (m *DeleteRangeRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *DeleteRangeRequest) MarshalTo(data []byte) (int, error) {
+func (m *IncrementRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2006,18 +2459,18 @@ func (m *DeleteRangeRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n22, err := m.RequestHeader.MarshalTo(data[i:])
+	n24, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n22
+	i += n24
 	data[i] = 0x10
 	i++
-	i = encodeVarintApi(data, i, uint64(m.MaxEntriesToDelete))
+	i = encodeVarintApi(data, i, uint64(m.Increment))
 	return i, nil
 }
 
-func (m *DeleteRangeResponse) Marshal() (data []byte, err error) {
+func (m *IncrementResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2027,7 +2480,7 @@ func (m *DeleteRangeResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *DeleteRangeResponse) MarshalTo(data []byte) (int, error) {
+func (m *IncrementResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2035,18 +2488,18 @@ func (m *DeleteRangeResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n23, err := m.ResponseHeader.MarshalTo(data[i:])
+	n25, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n23
+	i += n25
 	data[i] = 0x10
 	i++
-	i = encodeVarintApi(data, i, uint64(m.NumDeleted))
+	i = encodeVarintApi(data, i, uint64(m.NewValue))
 	return i, nil
 }
 
-func (m *ScanRequest) Marshal() (data []byte, err error) {
+func (m *MultiIncrementRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2056,7 +2509,7 @@ func (m *ScanRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ScanRequest) MarshalTo(data []byte) (int, error) {
+func (m *MultiIncrementRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2064,18 +2517,30 @@ func (m *ScanRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n24, err := m.RequestHeader.MarshalTo(data[i:])
+	n26, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n24
-	data[i] = 0x10
-	i++
-	i = encodeVarintApi(data, i, uint64(m.MaxResults))
+	i += n26
+	if len(m.Keys) > 0 {
+		for _, b := range m.Keys {
+			data[i] = 0x12
+			i++
+			i = encodeVarintApi(data, i, uint64(len(b)))
+			i += copy(data[i:], b)
+		}
+	}
+	if len(m.Increments) > 0 {
+		for _, num := range m.Increments {
+			data[i] = 0x18
+			i++
+			i = encodeVarintApi(data, i, uint64(num))
+		}
+	}
 	return i, nil
 }
 
-func (m *ScanResponse) Marshal() (data []byte, err error) {
+func (m *MultiIncrementResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2085,7 +2550,7 @@ func (m *ScanResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ScanResponse) MarshalTo(data []byte) (int, error) {
+func (m *MultiIncrementResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2093,27 +2558,22 @@ func (m *ScanResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n25, err := m.ResponseHeader.MarshalTo(data[i:])
+	n27, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n25
-	if len(m.Rows) > 0 {
-		for _, msg := range m.Rows {
-			data[i] = 0x12
+	i += n27
+	if len(m.NewValues) > 0 {
+		for _, num := range m.NewValues {
+			data[i] = 0x10
 			i++
-			i = encodeVarintApi(data, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(data[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
+			i = encodeVarintApi(data, i, uint64(num))
 		}
 	}
 	return i, nil
 }
 
-func (m *ReverseScanRequest) Marshal() (data []byte, err error) {
+func (m *DeleteRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2123,7 +2583,7 @@ func (m *ReverseScanRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ReverseScanRequest) MarshalTo(data []byte) (int, error) {
+func (m *DeleteRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2131,18 +2591,15 @@ func (m *ReverseScanRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n26, err := m.RequestHeader.MarshalTo(data[i:])
+	n28, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n26
-	data[i] = 0x10
-	i++
-	i = encodeVarintApi(data, i, uint64(m.MaxResults))
+	i += n28
 	return i, nil
 }
 
-func (m *ReverseScanResponse) Marshal() (data []byte, err error) {
+func (m *DeleteResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2152,7 +2609,7 @@ func (m *ReverseScanResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ReverseScanResponse) MarshalTo(data []byte) (int, error) {
+func (m *DeleteResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2160,27 +2617,15 @@ func (m *ReverseScanResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n27, err := m.ResponseHeader.MarshalTo(data[i:])
+	n29, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n27
-	if len(m.Rows) > 0 {
-		for _, msg := range m.Rows {
-			data[i] = 0x12
-			i++
-			i = encodeVarintApi(data, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(data[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
-	}
+	i += n29
 	return i, nil
 }
 
-func (m *EndTransactionRequest) Marshal() (data []byte, err error) {
+func (m *DeleteRangeRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2190,7 +2635,7 @@ func (m *EndTransactionRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *EndTransactionRequest) MarshalTo(data []byte) (int, error) {
+func (m *DeleteRangeRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2198,45 +2643,36 @@ func (m *EndTransactionRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n28, err := m.RequestHeader.MarshalTo(data[i:])
+	n30, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n28
+	i += n30
 	data[i] = 0x10
 	i++
-	if m.Commit {
-		data[i] = 1
-	} else {
-		data[i] = 0
-	}
-	i++
-	if m.InternalCommitTrigger != nil {
+	i = encodeVarintApi(data, i, uint64(m.MaxEntriesToDelete))
+	if m.PredicateTimestamp != nil {
 		data[i] = 0x1a
 		i++
-		i = encodeVarintApi(data, i, uint64(m.InternalCommitTrigger.Size()))
-		n29, err := m.InternalCommitTrigger.MarshalTo(data[i:])
+		i = encodeVarintApi(data, i, uint64(m.PredicateTimestamp.Size()))
+		n31, err := m.PredicateTimestamp.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n29
+		i += n31
 	}
-	if len(m.Intents) > 0 {
-		for _, msg := range m.Intents {
-			data[i] = 0x22
-			i++
-			i = encodeVarintApi(data, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(data[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
+	data[i] = 0x20
+	i++
+	if m.ReturnKeys {
+		data[i] = 1
+	} else {
+		data[i] = 0
 	}
+	i++
 	return i, nil
 }
 
-func (m *EndTransactionResponse) Marshal() (data []byte, err error) {
+func (m *DeleteRangeResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2246,7 +2682,7 @@ func (m *EndTransactionResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *EndTransactionResponse) MarshalTo(data []byte) (int, error) {
+func (m *DeleteRangeResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2254,26 +2690,32 @@ func (m *EndTransactionResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n30, err := m.ResponseHeader.MarshalTo(data[i:])
+	n32, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n30
+	i += n32
 	data[i] = 0x10
 	i++
-	i = encodeVarintApi(data, i, uint64(m.CommitWait))
-	if len(m.Resolved) > 0 {
-		for _, b := range m.Resolved {
+	i = encodeVarintApi(data, i, uint64(m.NumDeleted))
+	if len(m.Keys) > 0 {
+		for _, b := range m.Keys {
 			data[i] = 0x1a
 			i++
 			i = encodeVarintApi(data, i, uint64(len(b)))
 			i += copy(data[i:], b)
 		}
 	}
+	if m.ResumeKey != nil {
+		data[i] = 0x22
+		i++
+		i = encodeVarintApi(data, i, uint64(len(m.ResumeKey)))
+		i += copy(data[i:], m.ResumeKey)
+	}
 	return i, nil
 }
 
-func (m *AdminSplitRequest) Marshal() (data []byte, err error) {
+func (m *ScanPredicate) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2283,29 +2725,33 @@ func (m *AdminSplitRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *AdminSplitRequest) MarshalTo(data []byte) (int, error) {
+func (m *ScanPredicate) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	data[i] = 0xa
-	i++
-	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n31, err := m.RequestHeader.MarshalTo(data[i:])
-	if err != nil {
-		return 0, err
+	if m.KeyPrefix != nil {
+		data[i] = 0xa
+		i++
+		i = encodeVarintApi(data, i, uint64(len(m.KeyPrefix)))
+		i += copy(data[i:], m.KeyPrefix)
 	}
-	i += n31
-	if m.SplitKey != nil {
+	if m.ValueStart != nil {
 		data[i] = 0x12
 		i++
-		i = encodeVarintApi(data, i, uint64(len(m.SplitKey)))
-		i += copy(data[i:], m.SplitKey)
+		i = encodeVarintApi(data, i, uint64(len(m.ValueStart)))
+		i += copy(data[i:], m.ValueStart)
+	}
+	if m.ValueEnd != nil {
+		data[i] = 0x1a
+		i++
+		i = encodeVarintApi(data, i, uint64(len(m.ValueEnd)))
+		i += copy(data[i:], m.ValueEnd)
 	}
 	return i, nil
 }
 
-func (m *AdminSplitResponse) Marshal() (data []byte, err error) {
+func (m *ScanRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2315,49 +2761,74 @@ func (m *AdminSplitResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *AdminSplitResponse) MarshalTo(data []byte) (int, error) {
+func (m *ScanRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n32, err := m.ResponseHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n33, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n32
-	return i, nil
-}
-
-func (m *AdminMergeRequest) Marshal() (data []byte, err error) {
-	size := m.Size()
-	data = make([]byte, size)
-	n, err := m.MarshalTo(data)
+	i += n33
+	data[i] = 0x10
+	i++
+	i = encodeVarintApi(data, i, uint64(m.MaxResults))
+	if m.Predicate != nil {
+		data[i] = 0x1a
+		i++
+		i = encodeVarintApi(data, i, uint64(m.Predicate.Size()))
+		n34, err := m.Predicate.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n34
+	}
+	return i, nil
+}
+
+func (m *ScanResponse) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
 	if err != nil {
 		return nil, err
 	}
 	return data[:n], nil
 }
 
-func (m *AdminMergeRequest) MarshalTo(data []byte) (int, error) {
+func (m *ScanResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n33, err := m.RequestHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n35, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n33
+	i += n35
+	if len(m.Rows) > 0 {
+		for _, msg := range m.Rows {
+			data[i] = 0x12
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
 	return i, nil
 }
 
-func (m *AdminMergeResponse) Marshal() (data []byte, err error) {
+func (m *ReverseScanRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2367,23 +2838,26 @@ func (m *AdminMergeResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *AdminMergeResponse) MarshalTo(data []byte) (int, error) {
+func (m *ReverseScanRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n34, err := m.ResponseHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n36, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n34
+	i += n36
+	data[i] = 0x10
+	i++
+	i = encodeVarintApi(data, i, uint64(m.MaxResults))
 	return i, nil
 }
 
-func (m *RangeLookupRequest) Marshal() (data []byte, err error) {
+func (m *ReverseScanResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2393,42 +2867,35 @@ func (m *RangeLookupRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *RangeLookupRequest) MarshalTo(data []byte) (int, error) {
+func (m *ReverseScanResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n35, err := m.RequestHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n37, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n35
-	data[i] = 0x10
-	i++
-	i = encodeVarintApi(data, i, uint64(m.MaxRanges))
-	data[i] = 0x18
-	i++
-	if m.IgnoreIntents {
-		data[i] = 1
-	} else {
-		data[i] = 0
-	}
-	i++
-	data[i] = 0x20
-	i++
-	if m.Reverse {
-		data[i] = 1
-	} else {
-		data[i] = 0
+	i += n37
+	if len(m.Rows) > 0 {
+		for _, msg := range m.Rows {
+			data[i] = 0x12
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
 	}
-	i++
 	return i, nil
 }
 
-func (m *RangeLookupResponse) Marshal() (data []byte, err error) {
+func (m *EndTransactionRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2438,22 +2905,40 @@ func (m *RangeLookupResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *RangeLookupResponse) MarshalTo(data []byte) (int, error) {
+func (m *EndTransactionRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n36, err := m.ResponseHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n38, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n36
-	if len(m.Ranges) > 0 {
-		for _, msg := range m.Ranges {
-			data[i] = 0x12
+	i += n38
+	data[i] = 0x10
+	i++
+	if m.Commit {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	if m.InternalCommitTrigger != nil {
+		data[i] = 0x1a
+		i++
+		i = encodeVarintApi(data, i, uint64(m.InternalCommitTrigger.Size()))
+		n39, err := m.InternalCommitTrigger.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n39
+	}
+	if len(m.Intents) > 0 {
+		for _, msg := range m.Intents {
+			data[i] = 0x22
 			i++
 			i = encodeVarintApi(data, i, uint64(msg.Size()))
 			n, err := msg.MarshalTo(data[i:])
@@ -2466,7 +2951,7 @@ func (m *RangeLookupResponse) MarshalTo(data []byte) (int, error) {
 	return i, nil
 }
 
-func (m *HeartbeatTxnRequest) Marshal() (data []byte, err error) {
+func (m *EndTransactionResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2476,23 +2961,34 @@ func (m *HeartbeatTxnRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *HeartbeatTxnRequest) MarshalTo(data []byte) (int, error) {
+func (m *EndTransactionResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n37, err := m.RequestHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n40, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n37
+	i += n40
+	data[i] = 0x10
+	i++
+	i = encodeVarintApi(data, i, uint64(m.CommitWait))
+	if len(m.Resolved) > 0 {
+		for _, b := range m.Resolved {
+			data[i] = 0x1a
+			i++
+			i = encodeVarintApi(data, i, uint64(len(b)))
+			i += copy(data[i:], b)
+		}
+	}
 	return i, nil
 }
 
-func (m *HeartbeatTxnResponse) Marshal() (data []byte, err error) {
+func (m *AdminSplitRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2502,23 +2998,29 @@ func (m *HeartbeatTxnResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *HeartbeatTxnResponse) MarshalTo(data []byte) (int, error) {
+func (m *AdminSplitRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n38, err := m.ResponseHeader.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n41, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n38
+	i += n41
+	if m.SplitKey != nil {
+		data[i] = 0x12
+		i++
+		i = encodeVarintApi(data, i, uint64(len(m.SplitKey)))
+		i += copy(data[i:], m.SplitKey)
+	}
 	return i, nil
 }
 
-func (m *GCRequest) Marshal() (data []byte, err error) {
+func (m *AdminSplitResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2528,43 +3030,23 @@ func (m *GCRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *GCRequest) MarshalTo(data []byte) (int, error) {
+func (m *AdminSplitResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
 	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n39, err := m.RequestHeader.MarshalTo(data[i:])
-	if err != nil {
-		return 0, err
-	}
-	i += n39
-	data[i] = 0x12
-	i++
-	i = encodeVarintApi(data, i, uint64(m.GCMeta.Size()))
-	n40, err := m.GCMeta.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n42, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n40
-	if len(m.Keys) > 0 {
-		for _, msg := range m.Keys {
-			data[i] = 0x1a
-			i++
-			i = encodeVarintApi(data, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(data[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
-	}
+	i += n42
 	return i, nil
 }
 
-func (m *GCRequest_GCKey) Marshal() (data []byte, err error) {
+func (m *AdminMergeRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2574,29 +3056,23 @@ func (m *GCRequest_GCKey) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *GCRequest_GCKey) MarshalTo(data []byte) (int, error) {
+func (m *AdminMergeRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if m.Key != nil {
-		data[i] = 0xa
-		i++
-		i = encodeVarintApi(data, i, uint64(len(m.Key)))
-		i += copy(data[i:], m.Key)
-	}
-	data[i] = 0x12
+	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.Timestamp.Size()))
-	n41, err := m.Timestamp.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n43, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n41
+	i += n43
 	return i, nil
 }
 
-func (m *GCResponse) Marshal() (data []byte, err error) {
+func (m *AdminMergeResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2606,7 +3082,7 @@ func (m *GCResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *GCResponse) MarshalTo(data []byte) (int, error) {
+func (m *AdminMergeResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2614,15 +3090,15 @@ func (m *GCResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n42, err := m.ResponseHeader.MarshalTo(data[i:])
+	n44, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n42
+	i += n44
 	return i, nil
 }
 
-func (m *PushTxnRequest) Marshal() (data []byte, err error) {
+func (m *RangeLookupRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2632,7 +3108,7 @@ func (m *PushTxnRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *PushTxnRequest) MarshalTo(data []byte) (int, error) {
+func (m *RangeLookupRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2640,33 +3116,25 @@ func (m *PushTxnRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n43, err := m.RequestHeader.MarshalTo(data[i:])
+	n45, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n43
-	data[i] = 0x12
+	i += n45
+	data[i] = 0x10
 	i++
-	i = encodeVarintApi(data, i, uint64(m.PusheeTxn.Size()))
-	n44, err := m.PusheeTxn.MarshalTo(data[i:])
-	if err != nil {
-		return 0, err
-	}
-	i += n44
-	data[i] = 0x1a
+	i = encodeVarintApi(data, i, uint64(m.MaxRanges))
+	data[i] = 0x18
 	i++
-	i = encodeVarintApi(data, i, uint64(m.Now.Size()))
-	n45, err := m.Now.MarshalTo(data[i:])
-	if err != nil {
-		return 0, err
+	if m.IgnoreIntents {
+		data[i] = 1
+	} else {
+		data[i] = 0
 	}
-	i += n45
-	data[i] = 0x20
 	i++
-	i = encodeVarintApi(data, i, uint64(m.PushType))
-	data[i] = 0x28
+	data[i] = 0x20
 	i++
-	if m.RangeLookup {
+	if m.Reverse {
 		data[i] = 1
 	} else {
 		data[i] = 0
@@ -2675,7 +3143,7 @@ func (m *PushTxnRequest) MarshalTo(data []byte) (int, error) {
 	return i, nil
 }
 
-func (m *PushTxnResponse) Marshal() (data []byte, err error) {
+func (m *RangeLookupResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2685,7 +3153,7 @@ func (m *PushTxnResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *PushTxnResponse) MarshalTo(data []byte) (int, error) {
+func (m *RangeLookupResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2698,20 +3166,22 @@ func (m *PushTxnResponse) MarshalTo(data []byte) (int, error) {
 		return 0, err
 	}
 	i += n46
-	if m.PusheeTxn != nil {
-		data[i] = 0x12
-		i++
-		i = encodeVarintApi(data, i, uint64(m.PusheeTxn.Size()))
-		n47, err := m.PusheeTxn.MarshalTo(data[i:])
-		if err != nil {
-			return 0, err
+	if len(m.Ranges) > 0 {
+		for _, msg := range m.Ranges {
+			data[i] = 0x12
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
 		}
-		i += n47
 	}
 	return i, nil
 }
 
-func (m *ResolveIntentRequest) Marshal() (data []byte, err error) {
+func (m *HeartbeatTxnRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2721,7 +3191,7 @@ func (m *ResolveIntentRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ResolveIntentRequest) MarshalTo(data []byte) (int, error) {
+func (m *HeartbeatTxnRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2729,15 +3199,27 @@ func (m *ResolveIntentRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n48, err := m.RequestHeader.MarshalTo(data[i:])
+	n47, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n48
-	return i, nil
-}
-
-func (m *ResolveIntentResponse) Marshal() (data []byte, err error) {
+	i += n47
+	if len(m.Txns) > 0 {
+		for _, msg := range m.Txns {
+			data[i] = 0x12
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *HeartbeatTxnResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2747,7 +3229,7 @@ func (m *ResolveIntentResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ResolveIntentResponse) MarshalTo(data []byte) (int, error) {
+func (m *HeartbeatTxnResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2755,15 +3237,27 @@ func (m *ResolveIntentResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n49, err := m.ResponseHeader.MarshalTo(data[i:])
+	n48, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n49
+	i += n48
+	if len(m.Txns) > 0 {
+		for _, msg := range m.Txns {
+			data[i] = 0x12
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
 	return i, nil
 }
 
-func (m *ResolveIntentRangeRequest) Marshal() (data []byte, err error) {
+func (m *GCRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2773,7 +3267,7 @@ func (m *ResolveIntentRangeRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ResolveIntentRangeRequest) MarshalTo(data []byte) (int, error) {
+func (m *GCRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2781,15 +3275,43 @@ func (m *ResolveIntentRangeRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n50, err := m.RequestHeader.MarshalTo(data[i:])
+	n49, err := m.RequestHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n49
+	data[i] = 0x12
+	i++
+	i = encodeVarintApi(data, i, uint64(m.GCMeta.Size()))
+	n50, err := m.GCMeta.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
 	i += n50
+	if len(m.Keys) > 0 {
+		for _, msg := range m.Keys {
+			data[i] = 0x1a
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.TxnKeys) > 0 {
+		for _, b := range m.TxnKeys {
+			data[i] = 0x22
+			i++
+			i = encodeVarintApi(data, i, uint64(len(b)))
+			i += copy(data[i:], b)
+		}
+	}
 	return i, nil
 }
 
-func (m *ResolveIntentRangeResponse) Marshal() (data []byte, err error) {
+func (m *GCRequest_GCKey) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2799,7 +3321,39 @@ func (m *ResolveIntentRangeResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *ResolveIntentRangeResponse) MarshalTo(data []byte) (int, error) {
+func (m *GCRequest_GCKey) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Key != nil {
+		data[i] = 0xa
+		i++
+		i = encodeVarintApi(data, i, uint64(len(m.Key)))
+		i += copy(data[i:], m.Key)
+	}
+	data[i] = 0x12
+	i++
+	i = encodeVarintApi(data, i, uint64(m.Timestamp.Size()))
+	n51, err := m.Timestamp.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n51
+	return i, nil
+}
+
+func (m *GCResponse) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *GCResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2807,15 +3361,15 @@ func (m *ResolveIntentRangeResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n51, err := m.ResponseHeader.MarshalTo(data[i:])
+	n52, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n51
+	i += n52
 	return i, nil
 }
 
-func (m *MergeRequest) Marshal() (data []byte, err error) {
+func (m *PushTxnRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2825,7 +3379,7 @@ func (m *MergeRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *MergeRequest) MarshalTo(data []byte) (int, error) {
+func (m *PushTxnRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2833,23 +3387,42 @@ func (m *MergeRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n52, err := m.RequestHeader.MarshalTo(data[i:])
+	n53, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n52
+	i += n53
 	data[i] = 0x12
 	i++
-	i = encodeVarintApi(data, i, uint64(m.Value.Size()))
-	n53, err := m.Value.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.PusheeTxn.Size()))
+	n54, err := m.PusheeTxn.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n53
+	i += n54
+	data[i] = 0x1a
+	i++
+	i = encodeVarintApi(data, i, uint64(m.Now.Size()))
+	n55, err := m.Now.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n55
+	data[i] = 0x20
+	i++
+	i = encodeVarintApi(data, i, uint64(m.PushType))
+	data[i] = 0x28
+	i++
+	if m.RangeLookup {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
 	return i, nil
 }
 
-func (m *MergeResponse) Marshal() (data []byte, err error) {
+func (m *PushTxnResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2859,7 +3432,7 @@ func (m *MergeResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *MergeResponse) MarshalTo(data []byte) (int, error) {
+func (m *PushTxnResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2867,15 +3440,25 @@ func (m *MergeResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n54, err := m.ResponseHeader.MarshalTo(data[i:])
+	n56, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n54
+	i += n56
+	if m.PusheeTxn != nil {
+		data[i] = 0x12
+		i++
+		i = encodeVarintApi(data, i, uint64(m.PusheeTxn.Size()))
+		n57, err := m.PusheeTxn.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n57
+	}
 	return i, nil
 }
 
-func (m *TruncateLogRequest) Marshal() (data []byte, err error) {
+func (m *ResolveIntentRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2885,7 +3468,7 @@ func (m *TruncateLogRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *TruncateLogRequest) MarshalTo(data []byte) (int, error) {
+func (m *ResolveIntentRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2893,18 +3476,41 @@ func (m *TruncateLogRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n55, err := m.RequestHeader.MarshalTo(data[i:])
+	n58, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n55
-	data[i] = 0x10
+	i += n58
+	return i, nil
+}
+
+func (m *ResolveIntentResponse) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *ResolveIntentResponse) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0xa
 	i++
-	i = encodeVarintApi(data, i, uint64(m.Index))
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n59, err := m.ResponseHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n59
 	return i, nil
 }
 
-func (m *TruncateLogResponse) Marshal() (data []byte, err error) {
+func (m *ResolveIntentRangeRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2914,7 +3520,33 @@ func (m *TruncateLogResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *TruncateLogResponse) MarshalTo(data []byte) (int, error) {
+func (m *ResolveIntentRangeRequest) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0xa
+	i++
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n60, err := m.RequestHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n60
+	return i, nil
+}
+
+func (m *ResolveIntentRangeResponse) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *ResolveIntentRangeResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2922,15 +3554,15 @@ func (m *TruncateLogResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n56, err := m.ResponseHeader.MarshalTo(data[i:])
+	n61, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n56
+	i += n61
 	return i, nil
 }
 
-func (m *LeaderLeaseRequest) Marshal() (data []byte, err error) {
+func (m *MergeRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2940,7 +3572,7 @@ func (m *LeaderLeaseRequest) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *LeaderLeaseRequest) MarshalTo(data []byte) (int, error) {
+func (m *MergeRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2948,23 +3580,23 @@ func (m *LeaderLeaseRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n57, err := m.RequestHeader.MarshalTo(data[i:])
+	n62, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n57
+	i += n62
 	data[i] = 0x12
 	i++
-	i = encodeVarintApi(data, i, uint64(m.Lease.Size()))
-	n58, err := m.Lease.MarshalTo(data[i:])
+	i = encodeVarintApi(data, i, uint64(m.Value.Size()))
+	n63, err := m.Value.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n58
+	i += n63
 	return i, nil
 }
 
-func (m *LeaderLeaseResponse) Marshal() (data []byte, err error) {
+func (m *MergeResponse) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -2974,7 +3606,7 @@ func (m *LeaderLeaseResponse) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *LeaderLeaseResponse) MarshalTo(data []byte) (int, error) {
+func (m *MergeResponse) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -2982,15 +3614,15 @@ func (m *LeaderLeaseResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n59, err := m.ResponseHeader.MarshalTo(data[i:])
+	n64, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n59
+	i += n64
 	return i, nil
 }
 
-func (m *RequestUnion) Marshal() (data []byte, err error) {
+func (m *TruncateLogRequest) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
 	n, err := m.MarshalTo(data)
@@ -3000,160 +3632,295 @@ func (m *RequestUnion) Marshal() (data []byte, err error) {
 	return data[:n], nil
 }
 
-func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
+func (m *TruncateLogRequest) MarshalTo(data []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if m.Get != nil {
-		data[i] = 0xa
-		i++
-		i = encodeVarintApi(data, i, uint64(m.Get.Size()))
-		n60, err := m.Get.MarshalTo(data[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n60
+	data[i] = 0xa
+	i++
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n65, err := m.RequestHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
 	}
-	if m.Put != nil {
-		data[i] = 0x12
-		i++
-		i = encodeVarintApi(data, i, uint64(m.Put.Size()))
-		n61, err := m.Put.MarshalTo(data[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n61
+	i += n65
+	data[i] = 0x10
+	i++
+	i = encodeVarintApi(data, i, uint64(m.Index))
+	return i, nil
+}
+
+func (m *TruncateLogResponse) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
 	}
-	if m.ConditionalPut != nil {
-		data[i] = 0x1a
-		i++
-		i = encodeVarintApi(data, i, uint64(m.ConditionalPut.Size()))
-		n62, err := m.ConditionalPut.MarshalTo(data[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n62
+	return data[:n], nil
+}
+
+func (m *TruncateLogResponse) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0xa
+	i++
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n66, err := m.ResponseHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n66
+	return i, nil
+}
+
+func (m *LeaderLeaseRequest) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *LeaderLeaseRequest) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0xa
+	i++
+	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
+	n67, err := m.RequestHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n67
+	data[i] = 0x12
+	i++
+	i = encodeVarintApi(data, i, uint64(m.Lease.Size()))
+	n68, err := m.Lease.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n68
+	data[i] = 0x1a
+	i++
+	i = encodeVarintApi(data, i, uint64(m.TimestampCacheLowWater.Size()))
+	n69, err := m.TimestampCacheLowWater.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n69
+	if len(m.TimestampCache) > 0 {
+		for _, msg := range m.TimestampCache {
+			data[i] = 0x22
+			i++
+			i = encodeVarintApi(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *LeaderLeaseResponse) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *LeaderLeaseResponse) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0xa
+	i++
+	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
+	n70, err := m.ResponseHeader.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n70
+	return i, nil
+}
+
+func (m *RequestUnion) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Get != nil {
+		data[i] = 0xa
+		i++
+		i = encodeVarintApi(data, i, uint64(m.Get.Size()))
+		n71, err := m.Get.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n71
+	}
+	if m.Put != nil {
+		data[i] = 0x12
+		i++
+		i = encodeVarintApi(data, i, uint64(m.Put.Size()))
+		n72, err := m.Put.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n72
+	}
+	if m.ConditionalPut != nil {
+		data[i] = 0x1a
+		i++
+		i = encodeVarintApi(data, i, uint64(m.ConditionalPut.Size()))
+		n73, err := m.ConditionalPut.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n73
 	}
 	if m.Increment != nil {
 		data[i] = 0x22
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Increment.Size()))
-		n63, err := m.Increment.MarshalTo(data[i:])
+		n74, err := m.Increment.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n63
+		i += n74
 	}
 	if m.Delete != nil {
 		data[i] = 0x2a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Delete.Size()))
-		n64, err := m.Delete.MarshalTo(data[i:])
+		n75, err := m.Delete.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n64
+		i += n75
 	}
 	if m.DeleteRange != nil {
 		data[i] = 0x32
 		i++
 		i = encodeVarintApi(data, i, uint64(m.DeleteRange.Size()))
-		n65, err := m.DeleteRange.MarshalTo(data[i:])
+		n76, err := m.DeleteRange.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n65
+		i += n76
 	}
 	if m.Scan != nil {
 		data[i] = 0x3a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Scan.Size()))
-		n66, err := m.Scan.MarshalTo(data[i:])
+		n77, err := m.Scan.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n66
+		i += n77
 	}
 	if m.EndTransaction != nil {
 		data[i] = 0x42
 		i++
 		i = encodeVarintApi(data, i, uint64(m.EndTransaction.Size()))
-		n67, err := m.EndTransaction.MarshalTo(data[i:])
+		n78, err := m.EndTransaction.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n67
+		i += n78
 	}
 	if m.AdminSplit != nil {
 		data[i] = 0x4a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.AdminSplit.Size()))
-		n68, err := m.AdminSplit.MarshalTo(data[i:])
+		n79, err := m.AdminSplit.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n68
+		i += n79
 	}
 	if m.AdminMerge != nil {
 		data[i] = 0x52
 		i++
 		i = encodeVarintApi(data, i, uint64(m.AdminMerge.Size()))
-		n69, err := m.AdminMerge.MarshalTo(data[i:])
+		n80, err := m.AdminMerge.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n69
+		i += n80
 	}
 	if m.HeartbeatTxn != nil {
 		data[i] = 0x5a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.HeartbeatTxn.Size()))
-		n70, err := m.HeartbeatTxn.MarshalTo(data[i:])
+		n81, err := m.HeartbeatTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n70
+		i += n81
 	}
 	if m.Gc != nil {
 		data[i] = 0x62
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Gc.Size()))
-		n71, err := m.Gc.MarshalTo(data[i:])
+		n82, err := m.Gc.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n71
+		i += n82
 	}
 	if m.PushTxn != nil {
 		data[i] = 0x6a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.PushTxn.Size()))
-		n72, err := m.PushTxn.MarshalTo(data[i:])
+		n83, err := m.PushTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n72
+		i += n83
 	}
 	if m.RangeLookup != nil {
 		data[i] = 0x72
 		i++
 		i = encodeVarintApi(data, i, uint64(m.RangeLookup.Size()))
-		n73, err := m.RangeLookup.MarshalTo(data[i:])
+		n84, err := m.RangeLookup.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n73
+		i += n84
 	}
 	if m.ResolveIntent != nil {
 		data[i] = 0x7a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ResolveIntent.Size()))
-		n74, err := m.ResolveIntent.MarshalTo(data[i:])
+		n85, err := m.ResolveIntent.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n74
+		i += n85
 	}
 	if m.ResolveIntentRange != nil {
 		data[i] = 0x82
@@ -3161,11 +3928,11 @@ func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ResolveIntentRange.Size()))
-		n75, err := m.ResolveIntentRange.MarshalTo(data[i:])
+		n86, err := m.ResolveIntentRange.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n75
+		i += n86
 	}
 	if m.Merge != nil {
 		data[i] = 0x8a
@@ -3173,11 +3940,11 @@ func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Merge.Size()))
-		n76, err := m.Merge.MarshalTo(data[i:])
+		n87, err := m.Merge.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n76
+		i += n87
 	}
 	if m.Truncate != nil {
 		data[i] = 0x92
@@ -3185,11 +3952,11 @@ func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Truncate.Size()))
-		n77, err := m.Truncate.MarshalTo(data[i:])
+		n88, err := m.Truncate.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n77
+		i += n88
 	}
 	if m.LeaderLease != nil {
 		data[i] = 0x9a
@@ -3197,11 +3964,11 @@ func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.LeaderLease.Size()))
-		n78, err := m.LeaderLease.MarshalTo(data[i:])
+		n89, err := m.LeaderLease.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n78
+		i += n89
 	}
 	if m.ReverseScan != nil {
 		data[i] = 0xa2
@@ -3209,11 +3976,35 @@ func (m *RequestUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ReverseScan.Size()))
-		n79, err := m.ReverseScan.MarshalTo(data[i:])
+		n90, err := m.ReverseScan.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n79
+		i += n90
+	}
+	if m.CheckAndPut != nil {
+		data[i] = 0xaa
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintApi(data, i, uint64(m.CheckAndPut.Size()))
+		n91, err := m.CheckAndPut.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n91
+	}
+	if m.MultiIncrement != nil {
+		data[i] = 0xb2
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintApi(data, i, uint64(m.MultiIncrement.Size()))
+		n92, err := m.MultiIncrement.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n92
 	}
 	return i, nil
 }
@@ -3237,151 +4028,151 @@ func (m *ResponseUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0xa
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Get.Size()))
-		n80, err := m.Get.MarshalTo(data[i:])
+		n93, err := m.Get.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n80
+		i += n93
 	}
 	if m.Put != nil {
 		data[i] = 0x12
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Put.Size()))
-		n81, err := m.Put.MarshalTo(data[i:])
+		n94, err := m.Put.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n81
+		i += n94
 	}
 	if m.ConditionalPut != nil {
 		data[i] = 0x1a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ConditionalPut.Size()))
-		n82, err := m.ConditionalPut.MarshalTo(data[i:])
+		n95, err := m.ConditionalPut.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n82
+		i += n95
 	}
 	if m.Increment != nil {
 		data[i] = 0x22
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Increment.Size()))
-		n83, err := m.Increment.MarshalTo(data[i:])
+		n96, err := m.Increment.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n83
+		i += n96
 	}
 	if m.Delete != nil {
 		data[i] = 0x2a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Delete.Size()))
-		n84, err := m.Delete.MarshalTo(data[i:])
+		n97, err := m.Delete.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n84
+		i += n97
 	}
 	if m.DeleteRange != nil {
 		data[i] = 0x32
 		i++
 		i = encodeVarintApi(data, i, uint64(m.DeleteRange.Size()))
-		n85, err := m.DeleteRange.MarshalTo(data[i:])
+		n98, err := m.DeleteRange.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n85
+		i += n98
 	}
 	if m.Scan != nil {
 		data[i] = 0x3a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Scan.Size()))
-		n86, err := m.Scan.MarshalTo(data[i:])
+		n99, err := m.Scan.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n86
+		i += n99
 	}
 	if m.EndTransaction != nil {
 		data[i] = 0x42
 		i++
 		i = encodeVarintApi(data, i, uint64(m.EndTransaction.Size()))
-		n87, err := m.EndTransaction.MarshalTo(data[i:])
+		n100, err := m.EndTransaction.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n87
+		i += n100
 	}
 	if m.AdminSplit != nil {
 		data[i] = 0x4a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.AdminSplit.Size()))
-		n88, err := m.AdminSplit.MarshalTo(data[i:])
+		n101, err := m.AdminSplit.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n88
+		i += n101
 	}
 	if m.AdminMerge != nil {
 		data[i] = 0x52
 		i++
 		i = encodeVarintApi(data, i, uint64(m.AdminMerge.Size()))
-		n89, err := m.AdminMerge.MarshalTo(data[i:])
+		n102, err := m.AdminMerge.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n89
+		i += n102
 	}
 	if m.HeartbeatTxn != nil {
 		data[i] = 0x5a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.HeartbeatTxn.Size()))
-		n90, err := m.HeartbeatTxn.MarshalTo(data[i:])
+		n103, err := m.HeartbeatTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n90
+		i += n103
 	}
 	if m.Gc != nil {
 		data[i] = 0x62
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Gc.Size()))
-		n91, err := m.Gc.MarshalTo(data[i:])
+		n104, err := m.Gc.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n91
+		i += n104
 	}
 	if m.PushTxn != nil {
 		data[i] = 0x6a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.PushTxn.Size()))
-		n92, err := m.PushTxn.MarshalTo(data[i:])
+		n105, err := m.PushTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n92
+		i += n105
 	}
 	if m.RangeLookup != nil {
 		data[i] = 0x72
 		i++
 		i = encodeVarintApi(data, i, uint64(m.RangeLookup.Size()))
-		n93, err := m.RangeLookup.MarshalTo(data[i:])
+		n106, err := m.RangeLookup.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n93
+		i += n106
 	}
 	if m.ResolveIntent != nil {
 		data[i] = 0x7a
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ResolveIntent.Size()))
-		n94, err := m.ResolveIntent.MarshalTo(data[i:])
+		n107, err := m.ResolveIntent.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n94
+		i += n107
 	}
 	if m.ResolveIntentRange != nil {
 		data[i] = 0x82
@@ -3389,11 +4180,11 @@ func (m *ResponseUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ResolveIntentRange.Size()))
-		n95, err := m.ResolveIntentRange.MarshalTo(data[i:])
+		n108, err := m.ResolveIntentRange.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n95
+		i += n108
 	}
 	if m.Merge != nil {
 		data[i] = 0x8a
@@ -3401,11 +4192,11 @@ func (m *ResponseUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Merge.Size()))
-		n96, err := m.Merge.MarshalTo(data[i:])
+		n109, err := m.Merge.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n96
+		i += n109
 	}
 	if m.Truncate != nil {
 		data[i] = 0x92
@@ -3413,11 +4204,11 @@ func (m *ResponseUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.Truncate.Size()))
-		n97, err := m.Truncate.MarshalTo(data[i:])
+		n110, err := m.Truncate.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n97
+		i += n110
 	}
 	if m.LeaderLease != nil {
 		data[i] = 0x9a
@@ -3425,11 +4216,11 @@ func (m *ResponseUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.LeaderLease.Size()))
-		n98, err := m.LeaderLease.MarshalTo(data[i:])
+		n111, err := m.LeaderLease.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n98
+		i += n111
 	}
 	if m.ReverseScan != nil {
 		data[i] = 0xa2
@@ -3437,11 +4228,35 @@ func (m *ResponseUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintApi(data, i, uint64(m.ReverseScan.Size()))
-		n99, err := m.ReverseScan.MarshalTo(data[i:])
+		n112, err := m.ReverseScan.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n99
+		i += n112
+	}
+	if m.CheckAndPut != nil {
+		data[i] = 0xaa
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintApi(data, i, uint64(m.CheckAndPut.Size()))
+		n113, err := m.CheckAndPut.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n113
+	}
+	if m.MultiIncrement != nil {
+		data[i] = 0xb2
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintApi(data, i, uint64(m.MultiIncrement.Size()))
+		n114, err := m.MultiIncrement.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n114
 	}
 	return i, nil
 }
@@ -3464,11 +4279,11 @@ func (m *BatchRequest) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.RequestHeader.Size()))
-	n100, err := m.RequestHeader.MarshalTo(data[i:])
+	n115, err := m.RequestHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n100
+	i += n115
 	if len(m.Requests) > 0 {
 		for _, msg := range m.Requests {
 			data[i] = 0x12
@@ -3502,11 +4317,11 @@ func (m *BatchResponse) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintApi(data, i, uint64(m.ResponseHeader.Size()))
-	n101, err := m.ResponseHeader.MarshalTo(data[i:])
+	n116, err := m.ResponseHeader.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n101
+	i += n116
 	if len(m.Responses) > 0 {
 		for _, msg := range m.Responses {
 			data[i] = 0x12
@@ -3583,6 +4398,9 @@ func (m *RequestHeader) Size() (n int) {
 		n += 1 + l + sovApi(uint64(l))
 	}
 	n += 1 + sovApi(uint64(m.ReadConsistency))
+	n += 2
+	n += 2
+	n += 1 + sovApi(uint64(m.MaxStaleness))
 	return n
 }
 
@@ -3599,6 +4417,37 @@ func (m *ResponseHeader) Size() (n int) {
 		l = m.Txn.Size()
 		n += 1 + l + sovApi(uint64(l))
 	}
+	if m.Stats != nil {
+		l = m.Stats.Size()
+		n += 1 + l + sovApi(uint64(l))
+	}
+	if len(m.ReadSet) > 0 {
+		for _, e := range m.ReadSet {
+			l = e.Size()
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
+	n += 1 + sovApi(uint64(m.Staleness))
+	return n
+}
+
+func (m *ExecutionStats) Size() (n int) {
+	var l int
+	_ = l
+	n += 1 + sovApi(uint64(m.KeysScanned))
+	n += 1 + sovApi(uint64(m.BytesRead))
+	return n
+}
+
+func (m *ReadSetEntry) Size() (n int) {
+	var l int
+	_ = l
+	if m.Key != nil {
+		l = len(m.Key)
+		n += 1 + l + sovApi(uint64(l))
+	}
+	l = m.Timestamp.Size()
+	n += 1 + l + sovApi(uint64(l))
 	return n
 }
 
@@ -3662,46 +4511,103 @@ func (m *ConditionalPutResponse) Size() (n int) {
 	return n
 }
 
-func (m *IncrementRequest) Size() (n int) {
+func (m *CheckAndPutRequest) Size() (n int) {
 	var l int
 	_ = l
 	l = m.RequestHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
-	n += 1 + sovApi(uint64(m.Increment))
+	l = m.Value.Size()
+	n += 1 + l + sovApi(uint64(l))
+	l = m.ExpTimestamp.Size()
+	n += 1 + l + sovApi(uint64(l))
 	return n
 }
 
-func (m *IncrementResponse) Size() (n int) {
+func (m *CheckAndPutResponse) Size() (n int) {
 	var l int
 	_ = l
 	l = m.ResponseHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
-	n += 1 + sovApi(uint64(m.NewValue))
 	return n
 }
 
-func (m *DeleteRequest) Size() (n int) {
+func (m *IncrementRequest) Size() (n int) {
 	var l int
 	_ = l
 	l = m.RequestHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
+	n += 1 + sovApi(uint64(m.Increment))
 	return n
 }
 
-func (m *DeleteResponse) Size() (n int) {
+func (m *IncrementResponse) Size() (n int) {
 	var l int
 	_ = l
 	l = m.ResponseHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
+	n += 1 + sovApi(uint64(m.NewValue))
 	return n
 }
 
-func (m *DeleteRangeRequest) Size() (n int) {
+func (m *MultiIncrementRequest) Size() (n int) {
 	var l int
 	_ = l
 	l = m.RequestHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
-	n += 1 + sovApi(uint64(m.MaxEntriesToDelete))
+	if len(m.Keys) > 0 {
+		for _, b := range m.Keys {
+			l = len(b)
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
+	if len(m.Increments) > 0 {
+		for _, e := range m.Increments {
+			n += 1 + sovApi(uint64(e))
+		}
+	}
+	return n
+}
+
+func (m *MultiIncrementResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = m.ResponseHeader.Size()
+	n += 1 + l + sovApi(uint64(l))
+	if len(m.NewValues) > 0 {
+		for _, e := range m.NewValues {
+			n += 1 + sovApi(uint64(e))
+		}
+	}
+	return n
+}
+
+func (m *DeleteRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = m.RequestHeader.Size()
+	n += 1 + l + sovApi(uint64(l))
+	return n
+}
+
+func (m *DeleteResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = m.ResponseHeader.Size()
+	n += 1 + l + sovApi(uint64(l))
+	return n
+}
+
+func (m *DeleteRangeRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = m.RequestHeader.Size()
+	n += 1 + l + sovApi(uint64(l))
+	n += 1 + sovApi(uint64(m.MaxEntriesToDelete))
+	if m.PredicateTimestamp != nil {
+		l = m.PredicateTimestamp.Size()
+		n += 1 + l + sovApi(uint64(l))
+	}
+	n += 2
 	return n
 }
 
@@ -3711,6 +4617,34 @@ func (m *DeleteRangeResponse) Size() (n int) {
 	l = m.ResponseHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
 	n += 1 + sovApi(uint64(m.NumDeleted))
+	if len(m.Keys) > 0 {
+		for _, b := range m.Keys {
+			l = len(b)
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
+	if m.ResumeKey != nil {
+		l = len(m.ResumeKey)
+		n += 1 + l + sovApi(uint64(l))
+	}
+	return n
+}
+
+func (m *ScanPredicate) Size() (n int) {
+	var l int
+	_ = l
+	if m.KeyPrefix != nil {
+		l = len(m.KeyPrefix)
+		n += 1 + l + sovApi(uint64(l))
+	}
+	if m.ValueStart != nil {
+		l = len(m.ValueStart)
+		n += 1 + l + sovApi(uint64(l))
+	}
+	if m.ValueEnd != nil {
+		l = len(m.ValueEnd)
+		n += 1 + l + sovApi(uint64(l))
+	}
 	return n
 }
 
@@ -3720,6 +4654,10 @@ func (m *ScanRequest) Size() (n int) {
 	l = m.RequestHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
 	n += 1 + sovApi(uint64(m.MaxResults))
+	if m.Predicate != nil {
+		l = m.Predicate.Size()
+		n += 1 + l + sovApi(uint64(l))
+	}
 	return n
 }
 
@@ -3860,6 +4798,12 @@ func (m *HeartbeatTxnRequest) Size() (n int) {
 	_ = l
 	l = m.RequestHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
+	if len(m.Txns) > 0 {
+		for _, e := range m.Txns {
+			l = e.Size()
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -3868,6 +4812,12 @@ func (m *HeartbeatTxnResponse) Size() (n int) {
 	_ = l
 	l = m.ResponseHeader.Size()
 	n += 1 + l + sovApi(uint64(l))
+	if len(m.Txns) > 0 {
+		for _, e := range m.Txns {
+			l = e.Size()
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -3884,6 +4834,12 @@ func (m *GCRequest) Size() (n int) {
 			n += 1 + l + sovApi(uint64(l))
 		}
 	}
+	if len(m.TxnKeys) > 0 {
+		for _, b := range m.TxnKeys {
+			l = len(b)
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -4007,6 +4963,14 @@ func (m *LeaderLeaseRequest) Size() (n int) {
 	n += 1 + l + sovApi(uint64(l))
 	l = m.Lease.Size()
 	n += 1 + l + sovApi(uint64(l))
+	l = m.TimestampCacheLowWater.Size()
+	n += 1 + l + sovApi(uint64(l))
+	if len(m.TimestampCache) > 0 {
+		for _, e := range m.TimestampCache {
+			l = e.Size()
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -4101,6 +5065,14 @@ func (m *RequestUnion) Size() (n int) {
 		l = m.ReverseScan.Size()
 		n += 2 + l + sovApi(uint64(l))
 	}
+	if m.CheckAndPut != nil {
+		l = m.CheckAndPut.Size()
+		n += 2 + l + sovApi(uint64(l))
+	}
+	if m.MultiIncrement != nil {
+		l = m.MultiIncrement.Size()
+		n += 2 + l + sovApi(uint64(l))
+	}
 	return n
 }
 
@@ -4187,6 +5159,14 @@ func (m *ResponseUnion) Size() (n int) {
 		l = m.ReverseScan.Size()
 		n += 2 + l + sovApi(uint64(l))
 	}
+	if m.CheckAndPut != nil {
+		l = m.CheckAndPut.Size()
+		n += 2 + l + sovApi(uint64(l))
+	}
+	if m.MultiIncrement != nil {
+		l = m.MultiIncrement.Size()
+		n += 2 + l + sovApi(uint64(l))
+	}
 	return n
 }
 
@@ -4292,6 +5272,12 @@ func (this *RequestUnion) GetValue() interface{} {
 	if this.ReverseScan != nil {
 		return this.ReverseScan
 	}
+	if this.CheckAndPut != nil {
+		return this.CheckAndPut
+	}
+	if this.MultiIncrement != nil {
+		return this.MultiIncrement
+	}
 	return nil
 }
 
@@ -4337,6 +5323,10 @@ func (this *RequestUnion) SetValue(value interface{}) bool {
 		this.LeaderLease = vt
 	case *ReverseScanRequest:
 		this.ReverseScan = vt
+	case *CheckAndPutRequest:
+		this.CheckAndPut = vt
+	case *MultiIncrementRequest:
+		this.MultiIncrement = vt
 	default:
 		return false
 	}
@@ -4403,6 +5393,12 @@ func (this *ResponseUnion) GetValue() interface{} {
 	if this.ReverseScan != nil {
 		return this.ReverseScan
 	}
+	if this.CheckAndPut != nil {
+		return this.CheckAndPut
+	}
+	if this.MultiIncrement != nil {
+		return this.MultiIncrement
+	}
 	return nil
 }
 
@@ -4448,6 +5444,10 @@ func (this *ResponseUnion) SetValue(value interface{}) bool {
 		this.LeaderLease = vt
 	case *ReverseScanResponse:
 		this.ReverseScan = vt
+	case *CheckAndPutResponse:
+		this.CheckAndPut = vt
+	case *MultiIncrementResponse:
+		this.MultiIncrement = vt
 	default:
 		return false
 	}
@@ -4759,6 +5759,610 @@ func (m *RequestHeader) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RecordReadSet", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RecordReadSet = bool(v != 0)
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkipTimestampCacheUpdate", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.SkipTimestampCacheUpdate = bool(v != 0)
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxStaleness", wireType)
+			}
+			m.MaxStaleness = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.MaxStaleness |= (time.Duration(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *ResponseHeader) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Error == nil {
+				m.Error = &Error{}
+			}
+			if err := m.Error.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Timestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Txn", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Txn == nil {
+				m.Txn = &Transaction{}
+			}
+			if err := m.Txn.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stats", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Stats == nil {
+				m.Stats = &ExecutionStats{}
+			}
+			if err := m.Stats.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadSet", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReadSet = append(m.ReadSet, ReadSetEntry{})
+			if err := m.ReadSet[len(m.ReadSet)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Staleness", wireType)
+			}
+			m.Staleness = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Staleness |= (time.Duration(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *ExecutionStats) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeysScanned", wireType)
+			}
+			m.KeysScanned = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.KeysScanned |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BytesRead", wireType)
+			}
+			m.BytesRead = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.BytesRead |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *ReadSetEntry) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Timestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *GetRequest) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestHeader", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.RequestHeader.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *GetResponse) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResponseHeader", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.ResponseHeader.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Value == nil {
+				m.Value = &Value{}
+			}
+			if err := m.Value.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -4785,7 +6389,7 @@ func (m *RequestHeader) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *ResponseHeader) Unmarshal(data []byte) error {
+func (m *PutRequest) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4806,7 +6410,7 @@ func (m *ResponseHeader) Unmarshal(data []byte) error {
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestHeader", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4827,16 +6431,13 @@ func (m *ResponseHeader) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Error == nil {
-				m.Error = &Error{}
-			}
-			if err := m.Error.Unmarshal(data[iNdEx:postIndex]); err != nil {
+			if err := m.RequestHeader.Unmarshal(data[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4857,13 +6458,58 @@ func (m *ResponseHeader) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := m.Timestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+			if err := m.Value.Unmarshal(data[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 3:
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *PutResponse) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Txn", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ResponseHeader", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4884,10 +6530,7 @@ func (m *ResponseHeader) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Txn == nil {
-				m.Txn = &Transaction{}
-			}
-			if err := m.Txn.Unmarshal(data[iNdEx:postIndex]); err != nil {
+			if err := m.ResponseHeader.Unmarshal(data[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -4917,7 +6560,7 @@ func (m *ResponseHeader) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *GetRequest) Unmarshal(data []byte) error {
+func (m *ConditionalPutRequest) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4963,6 +6606,63 @@ func (m *GetRequest) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Value.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpValue", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ExpValue == nil {
+				m.ExpValue = &Value{}
+			}
+			if err := m.ExpValue.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -4989,7 +6689,7 @@ func (m *GetRequest) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *GetResponse) Unmarshal(data []byte) error {
+func (m *ConditionalPutResponse) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5035,36 +6735,6 @@ func (m *GetResponse) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := data[iNdEx]
-				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthApi
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.Value == nil {
-				m.Value = &Value{}
-			}
-			if err := m.Value.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -5091,7 +6761,7 @@ func (m *GetResponse) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *PutRequest) Unmarshal(data []byte) error {
+func (m *CheckAndPutRequest) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5164,6 +6834,33 @@ func (m *PutRequest) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpTimestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.ExpTimestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -5190,7 +6887,7 @@ func (m *PutRequest) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *PutResponse) Unmarshal(data []byte) error {
+func (m *CheckAndPutResponse) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5262,7 +6959,7 @@ func (m *PutResponse) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *ConditionalPutRequest) Unmarshal(data []byte) error {
+func (m *IncrementRequest) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5309,62 +7006,21 @@ func (m *ConditionalPutRequest) Unmarshal(data []byte) error {
 			}
 			iNdEx = postIndex
 		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := data[iNdEx]
-				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthApi
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if err := m.Value.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ExpValue", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := data[iNdEx]
-				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthApi
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.ExpValue == nil {
-				m.ExpValue = &Value{}
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Increment", wireType)
 			}
-			if err := m.ExpValue.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
+			m.Increment = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Increment |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -5391,7 +7047,7 @@ func (m *ConditionalPutRequest) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *ConditionalPutResponse) Unmarshal(data []byte) error {
+func (m *IncrementResponse) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5437,6 +7093,22 @@ func (m *ConditionalPutResponse) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NewValue", wireType)
+			}
+			m.NewValue = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.NewValue |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
@@ -5463,7 +7135,7 @@ func (m *ConditionalPutResponse) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *IncrementRequest) Unmarshal(data []byte) error {
+func (m *MultiIncrementRequest) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5510,21 +7182,48 @@ func (m *IncrementRequest) Unmarshal(data []byte) error {
 			}
 			iNdEx = postIndex
 		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Keys", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Keys = append(m.Keys, make([]byte, postIndex-iNdEx))
+			copy(m.Keys[len(m.Keys)-1], data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Increment", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Increments", wireType)
 			}
-			m.Increment = 0
+			var v int64
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				m.Increment |= (int64(b) & 0x7F) << shift
+				v |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			m.Increments = append(m.Increments, v)
 		default:
 			var sizeOfWire int
 			for {
@@ -5551,7 +7250,7 @@ func (m *IncrementRequest) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *IncrementResponse) Unmarshal(data []byte) error {
+func (m *MultiIncrementResponse) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5599,20 +7298,21 @@ func (m *IncrementResponse) Unmarshal(data []byte) error {
 			iNdEx = postIndex
 		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field NewValue", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field NewValues", wireType)
 			}
-			m.NewValue = 0
+			var v int64
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				m.NewValue |= (int64(b) & 0x7F) << shift
+				v |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			m.NewValues = append(m.NewValues, v)
 		default:
 			var sizeOfWire int
 			for {
@@ -5845,6 +7545,53 @@ func (m *DeleteRangeRequest) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PredicateTimestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.PredicateTimestamp == nil {
+				m.PredicateTimestamp = &Timestamp{}
+			}
+			if err := m.PredicateTimestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReturnKeys", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ReturnKeys = bool(v != 0)
 		default:
 			var sizeOfWire int
 			for {
@@ -5933,6 +7680,177 @@ func (m *DeleteRangeResponse) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Keys", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Keys = append(m.Keys, make([]byte, postIndex-iNdEx))
+			copy(m.Keys[len(m.Keys)-1], data[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResumeKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ResumeKey = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipApi(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthApi
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *ScanPredicate) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeyPrefix", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.KeyPrefix = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValueStart", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ValueStart = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValueEnd", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ValueEnd = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -6021,6 +7939,36 @@ func (m *ScanRequest) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Predicate", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Predicate == nil {
+				m.Predicate = &ScanPredicate{}
+			}
+			if err := m.Predicate.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -7177,6 +9125,34 @@ func (m *HeartbeatTxnRequest) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Txns", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Txns = append(m.Txns, Transaction{})
+			if err := m.Txns[len(m.Txns)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -7249,6 +9225,34 @@ func (m *HeartbeatTxnResponse) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Txns", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Txns = append(m.Txns, Transaction{})
+			if err := m.Txns[len(m.Txns)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -7376,6 +9380,32 @@ func (m *GCRequest) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnKeys", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TxnKeys = append(m.TxnKeys, make([]byte, postIndex-iNdEx))
+			copy(m.TxnKeys[len(m.TxnKeys)-1], data[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -8524,6 +10554,61 @@ func (m *LeaderLeaseRequest) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimestampCacheLowWater", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.TimestampCacheLowWater.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimestampCache", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TimestampCache = append(m.TimestampCache, TimestampCacheEntry{})
+			if err := m.TimestampCache[len(m.TimestampCache)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -9241,6 +11326,66 @@ func (m *RequestUnion) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 21:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CheckAndPut", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CheckAndPut == nil {
+				m.CheckAndPut = &CheckAndPutRequest{}
+			}
+			if err := m.CheckAndPut.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 22:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MultiIncrement", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MultiIncrement == nil {
+				m.MultiIncrement = &MultiIncrementRequest{}
+			}
+			if err := m.MultiIncrement.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -9886,6 +12031,66 @@ func (m *ResponseUnion) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 21:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CheckAndPut", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CheckAndPut == nil {
+				m.CheckAndPut = &CheckAndPutResponse{}
+			}
+			if err := m.CheckAndPut.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 22:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MultiIncrement", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MultiIncrement == nil {
+				m.MultiIncrement = &MultiIncrementResponse{}
+			}
+			if err := m.MultiIncrement.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -18,6 +18,7 @@
 package proto
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"testing"
@@ -149,3 +150,60 @@ func TestSetGoErrorCopy(t *testing.T) {
 		t.Fatalf("SetGoError did not create a new error")
 	}
 }
+
+// TestBatchResponseMarshalCanonical verifies that batch responses which
+// differ only in the order of set-valued fields have the same canonical
+// encoding, while their regular encodings differ.
+func TestBatchResponseMarshalCanonical(t *testing.T) {
+	makeResponse := func(keys []string, nodes []int32) *BatchResponse {
+		br := &BatchResponse{}
+		br.Txn = &Transaction{Name: "test"}
+		br.Txn.CertainNodes.Nodes = nodes
+		etReply := &EndTransactionResponse{}
+		getReply := &GetResponse{}
+		for _, k := range keys {
+			etReply.Resolved = append(etReply.Resolved, Key(k))
+			getReply.ReadSet = append(getReply.ReadSet, ReadSetEntry{Key: Key(k)})
+		}
+		var intents []Intent
+		for _, k := range keys {
+			intents = append(intents, Intent{Key: Key(k)})
+		}
+		getReply.Error = &Error{
+			Message: "conflicting intents",
+			Detail:  &ErrorDetail{WriteIntent: &WriteIntentError{Intents: intents}},
+		}
+		br.Add(getReply)
+		br.Add(etReply)
+		return br
+	}
+	br1 := makeResponse([]string{"a", "b", "c"}, []int32{1, 2, 3})
+	br2 := makeResponse([]string{"c", "a", "b"}, []int32{3, 1, 2})
+
+	data1, err := br1.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := br2.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data1, data2) {
+		t.Fatal("expected regular encodings to differ")
+	}
+	canonical1, err := br1.MarshalCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical2, err := br2.MarshalCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(canonical1, canonical2) {
+		t.Errorf("expected identical canonical encodings:\n%x\n%x", canonical1, canonical2)
+	}
+	// The responses themselves are left untouched.
+	if data, err := br2.Marshal(); err != nil || !bytes.Equal(data, data2) {
+		t.Errorf("expected MarshalCanonical not to modify the response (%v)", err)
+	}
+}
@@ -75,6 +75,28 @@ func IncrementCall(key Key, increment int64) Call {
 	}
 }
 
+// MultiIncrementCall returns a Call object initialized to increment the
+// values at keys by the respective increments. The request spans the
+// keys, which must all lie within a single range.
+func MultiIncrementCall(keys []Key, increments []int64) Call {
+	args := &MultiIncrementRequest{
+		Keys:       keys,
+		Increments: increments,
+	}
+	for _, key := range keys {
+		if args.Key == nil || key.Less(args.Key) {
+			args.Key = key
+		}
+		if args.EndKey == nil || !key.Less(args.EndKey) {
+			args.EndKey = key.Next()
+		}
+	}
+	return Call{
+		Args:  args,
+		Reply: &MultiIncrementResponse{},
+	}
+}
+
 // PutCall returns a Call object initialized to put the value at key.
 func PutCall(key Key, value Value) Call {
 	value.InitChecksum(key)
@@ -111,6 +133,23 @@ func ConditionalPutCall(key Key, valueBytes, expValueBytes []byte) Call {
 	}
 }
 
+// CheckAndPutCall returns a Call object initialized to put value as a byte
+// slice at key if the latest version of key is no newer than expTimestamp.
+func CheckAndPutCall(key Key, valueBytes []byte, expTimestamp Timestamp) Call {
+	value := Value{Bytes: valueBytes}
+	value.InitChecksum(key)
+	return Call{
+		Args: &CheckAndPutRequest{
+			RequestHeader: RequestHeader{
+				Key: key,
+			},
+			Value:        value,
+			ExpTimestamp: expTimestamp,
+		},
+		Reply: &CheckAndPutResponse{},
+	}
+}
+
 // DeleteCall returns a Call object initialized to delete the value at key.
 func DeleteCall(key Key) Call {
 	return Call{
@@ -273,15 +273,28 @@ func (v *Value) InitChecksum(key []byte) {
 	}
 }
 
+// A ChecksumError is returned by Value.Verify when a value's contents
+// don't match its checksum.
+type ChecksumError struct {
+	Key      Key
+	Value    *Value
+	Computed uint32 // The checksum computed from the value's contents
+}
+
+// Error implements the error interface.
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("invalid checksum (%d) for key %s, value [% x]", e.Computed, e.Key, e.Value)
+}
+
 // Verify verifies the value's Checksum matches a newly-computed
 // checksum of the value's contents. If the value's Checksum is not
-// set the verification is a noop.
+// set the verification is a noop. A mismatch is returned as a
+// *ChecksumError.
 func (v *Value) Verify(key []byte) error {
 	if v.Checksum != nil {
 		cksum := v.computeChecksum(key)
 		if v.GetChecksum() != cksum {
-			return fmt.Errorf("invalid checksum (%d) for key %s, value [% x]",
-				cksum, Key(key), v)
+			return &ChecksumError{Key: Key(key), Value: v, Computed: cksum}
 		}
 	}
 	return nil
@@ -488,6 +501,9 @@ func (t *Transaction) Update(o *Transaction) {
 	t.CertainNodes = NodeList{Nodes: append(Int32Slice(nil),
 		o.CertainNodes.Nodes...)}
 	t.UpgradePriority(o.Priority)
+	if t.IntentCount < o.IntentCount {
+		t.IntentCount = o.IntentCount
+	}
 	if t.Writing && !o.Writing {
 		panic("r/w status regression")
 	}
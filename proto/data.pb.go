@@ -550,6 +550,16 @@ type Transaction struct {
 	// Writing is true if the transaction has previously executed a successful
 	// write request, i.e. a request that may have left intents (across retries).
 	Writing bool `protobuf:"varint,13,opt" json:"Writing"`
+	// Historical is true for a read-only transaction at a fixed timestamp
+	// in the past. Its reads observe no uncertainty, and replicas serve them
+	// without the leader lease or the timestamp cache if the timestamp has
+	// been closed. It may not write.
+	Historical bool `protobuf:"varint,14,opt,name=historical" json:"historical"`
+	// IntentCount is the number of intents written by the transaction, as
+	// counted by its coordinator. Every key written counts, so a key
+	// written repeatedly is counted repeatedly. It is kept in the
+	// transaction record by HeartbeatTxn and EndTransaction.
+	IntentCount int64 `protobuf:"varint,15,opt,name=intent_count" json:"intent_count"`
 }
 
 func (m *Transaction) Reset()      { *m = Transaction{} }
@@ -646,6 +656,20 @@ func (m *Transaction) GetWriting() bool {
 	return false
 }
 
+func (m *Transaction) GetHistorical() bool {
+	if m != nil {
+		return m.Historical
+	}
+	return false
+}
+
+func (m *Transaction) GetIntentCount() int64 {
+	if m != nil {
+		return m.IntentCount
+	}
+	return 0
+}
+
 // Lease contains information about leader leases including the
 // expiration and lease holder.
 type Lease struct {
@@ -657,6 +681,10 @@ type Lease struct {
 	Expiration Timestamp `protobuf:"bytes,2,opt,name=expiration" json:"expiration"`
 	// The Raft NodeID on which the would-be lease holder lives.
 	RaftNodeID RaftNodeID `protobuf:"varint,3,opt,name=raft_node_id,casttype=RaftNodeID" json:"raft_node_id"`
+	// The closed timestamp is the timestamp at or below which the lease
+	// holder promises not to accept any further writes. Replicas which do
+	// not hold the lease may serve consistent reads at or below it.
+	ClosedTimestamp Timestamp `protobuf:"bytes,4,opt,name=closed_timestamp" json:"closed_timestamp"`
 }
 
 func (m *Lease) Reset()      { *m = Lease{} }
@@ -683,6 +711,13 @@ func (m *Lease) GetRaftNodeID() RaftNodeID {
 	return 0
 }
 
+func (m *Lease) GetClosedTimestamp() Timestamp {
+	if m != nil {
+		return m.ClosedTimestamp
+	}
+	return Timestamp{}
+}
+
 // Intent is used to communicate the location of an intent.
 type Intent struct {
 	Key    Key         `protobuf:"bytes,1,opt,name=key,casttype=Key" json:"key,omitempty"`
@@ -715,6 +750,57 @@ func (m *Intent) GetTxn() Transaction {
 	return Transaction{}
 }
 
+// TimestampCacheEntry is a serialized entry of a range's timestamp
+// cache, recording the most recent read or write timestamp of a key
+// or key range.
+type TimestampCacheEntry struct {
+	Key       Key       `protobuf:"bytes,1,opt,name=key,casttype=Key" json:"key,omitempty"`
+	EndKey    Key       `protobuf:"bytes,2,opt,name=end_key,casttype=Key" json:"end_key,omitempty"`
+	Timestamp Timestamp `protobuf:"bytes,3,opt,name=timestamp" json:"timestamp"`
+	ReadOnly  bool      `protobuf:"varint,4,opt,name=read_only" json:"read_only"`
+	// The ID of the transaction which made the read or write, if any.
+	TxnID []byte `protobuf:"bytes,5,opt,name=txn_id" json:"txn_id,omitempty"`
+}
+
+func (m *TimestampCacheEntry) Reset()         { *m = TimestampCacheEntry{} }
+func (m *TimestampCacheEntry) String() string { return proto1.CompactTextString(m) }
+func (*TimestampCacheEntry) ProtoMessage()    {}
+
+func (m *TimestampCacheEntry) GetKey() Key {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *TimestampCacheEntry) GetEndKey() Key {
+	if m != nil {
+		return m.EndKey
+	}
+	return nil
+}
+
+func (m *TimestampCacheEntry) GetTimestamp() Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return Timestamp{}
+}
+
+func (m *TimestampCacheEntry) GetReadOnly() bool {
+	if m != nil {
+		return m.ReadOnly
+	}
+	return false
+}
+
+func (m *TimestampCacheEntry) GetTxnID() []byte {
+	if m != nil {
+		return m.TxnID
+	}
+	return nil
+}
+
 // GCMetadata holds information about the last complete key/value
 // garbage collection scan of a range.
 type GCMetadata struct {
@@ -723,6 +809,9 @@ type GCMetadata struct {
 	// The oldest unresolved write intent in nanoseconds since epoch.
 	// Null if there are no unresolved write intents.
 	OldestIntentNanos *int64 `protobuf:"varint,2,opt,name=oldest_intent_nanos" json:"oldest_intent_nanos,omitempty"`
+	// The timestamp in nanoseconds since the Unix epoch below which the
+	// last GC scan may have removed values.
+	ThresholdNanos int64 `protobuf:"varint,3,opt,name=threshold_nanos" json:"threshold_nanos"`
 }
 
 func (m *GCMetadata) Reset()         { *m = GCMetadata{} }
@@ -743,6 +832,13 @@ func (m *GCMetadata) GetOldestIntentNanos() int64 {
 	return 0
 }
 
+func (m *GCMetadata) GetThresholdNanos() int64 {
+	if m != nil {
+		return m.ThresholdNanos
+	}
+	return 0
+}
+
 func init() {
 	proto1.RegisterEnum("cockroach.proto.ReplicaChangeType", ReplicaChangeType_name, ReplicaChangeType_value)
 	proto1.RegisterEnum("cockroach.proto.IsolationType", IsolationType_name, IsolationType_value)
@@ -1233,6 +1329,17 @@ func (m *Transaction) MarshalTo(data []byte) (int, error) {
 		data[i] = 0
 	}
 	i++
+	data[i] = 0x70
+	i++
+	if m.Historical {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	data[i] = 0x78
+	i++
+	i = encodeVarintData(data, i, uint64(m.IntentCount))
 	return i, nil
 }
 
@@ -1270,6 +1377,14 @@ func (m *Lease) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x18
 	i++
 	i = encodeVarintData(data, i, uint64(m.RaftNodeID))
+	data[i] = 0x22
+	i++
+	i = encodeVarintData(data, i, uint64(m.ClosedTimestamp.Size()))
+	n20, err := m.ClosedTimestamp.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n20
 	return i, nil
 }
 
@@ -1303,11 +1418,63 @@ func (m *Intent) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x1a
 	i++
 	i = encodeVarintData(data, i, uint64(m.Txn.Size()))
-	n20, err := m.Txn.MarshalTo(data[i:])
+	n21, err := m.Txn.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n20
+	i += n21
+	return i, nil
+}
+
+func (m *TimestampCacheEntry) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *TimestampCacheEntry) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Key != nil {
+		data[i] = 0xa
+		i++
+		i = encodeVarintData(data, i, uint64(len(m.Key)))
+		i += copy(data[i:], m.Key)
+	}
+	if m.EndKey != nil {
+		data[i] = 0x12
+		i++
+		i = encodeVarintData(data, i, uint64(len(m.EndKey)))
+		i += copy(data[i:], m.EndKey)
+	}
+	data[i] = 0x1a
+	i++
+	i = encodeVarintData(data, i, uint64(m.Timestamp.Size()))
+	n22, err := m.Timestamp.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n22
+	data[i] = 0x20
+	i++
+	if m.ReadOnly {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	if m.TxnID != nil {
+		data[i] = 0x2a
+		i++
+		i = encodeVarintData(data, i, uint64(len(m.TxnID)))
+		i += copy(data[i:], m.TxnID)
+	}
 	return i, nil
 }
 
@@ -1334,6 +1501,9 @@ func (m *GCMetadata) MarshalTo(data []byte) (int, error) {
 		i++
 		i = encodeVarintData(data, i, uint64(*m.OldestIntentNanos))
 	}
+	data[i] = 0x18
+	i++
+	i = encodeVarintData(data, i, uint64(m.ThresholdNanos))
 	return i, nil
 }
 
@@ -1538,6 +1708,8 @@ func (m *Transaction) Size() (n int) {
 	l = m.CertainNodes.Size()
 	n += 1 + l + sovData(uint64(l))
 	n += 2
+	n += 2
+	n += 1 + sovData(uint64(m.IntentCount))
 	return n
 }
 
@@ -1549,6 +1721,8 @@ func (m *Lease) Size() (n int) {
 	l = m.Expiration.Size()
 	n += 1 + l + sovData(uint64(l))
 	n += 1 + sovData(uint64(m.RaftNodeID))
+	l = m.ClosedTimestamp.Size()
+	n += 1 + l + sovData(uint64(l))
 	return n
 }
 
@@ -1568,6 +1742,27 @@ func (m *Intent) Size() (n int) {
 	return n
 }
 
+func (m *TimestampCacheEntry) Size() (n int) {
+	var l int
+	_ = l
+	if m.Key != nil {
+		l = len(m.Key)
+		n += 1 + l + sovData(uint64(l))
+	}
+	if m.EndKey != nil {
+		l = len(m.EndKey)
+		n += 1 + l + sovData(uint64(l))
+	}
+	l = m.Timestamp.Size()
+	n += 1 + l + sovData(uint64(l))
+	n += 2
+	if m.TxnID != nil {
+		l = len(m.TxnID)
+		n += 1 + l + sovData(uint64(l))
+	}
+	return n
+}
+
 func (m *GCMetadata) Size() (n int) {
 	var l int
 	_ = l
@@ -1575,6 +1770,7 @@ func (m *GCMetadata) Size() (n int) {
 	if m.OldestIntentNanos != nil {
 		n += 1 + sovData(uint64(*m.OldestIntentNanos))
 	}
+	n += 1 + sovData(uint64(m.ThresholdNanos))
 	return n
 }
 
@@ -3094,6 +3290,39 @@ func (m *Transaction) Unmarshal(data []byte) error {
 				}
 			}
 			m.Writing = bool(v != 0)
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Historical", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Historical = bool(v != 0)
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IntentCount", wireType)
+			}
+			m.IntentCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.IntentCount |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
@@ -3209,6 +3438,33 @@ func (m *Lease) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClosedTimestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthData
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.ClosedTimestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -3357,6 +3613,170 @@ func (m *Intent) Unmarshal(data []byte) error {
 
 	return nil
 }
+func (m *TimestampCacheEntry) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthData
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthData
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EndKey = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthData
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Timestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadOnly", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ReadOnly = bool(v != 0)
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthData
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TxnID = append([]byte{}, data[iNdEx:postIndex]...)
+			iNdEx = postIndex
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipData(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthData
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
 func (m *GCMetadata) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
@@ -3409,6 +3829,22 @@ func (m *GCMetadata) Unmarshal(data []byte) error {
 				}
 			}
 			m.OldestIntentNanos = &v
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ThresholdNanos", wireType)
+			}
+			m.ThresholdNanos = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.ThresholdNanos |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
@@ -0,0 +1,196 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package proto
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+)
+
+// A DecodeError describes a failure to decode a protocol buffer message.
+// It identifies the (possibly nested) message type and field which could
+// not be decoded, and the byte offset at which decoding failed.
+type DecodeError struct {
+	Type   string // Name of the message type containing the field
+	Field  int32  // Field number; zero if the field key itself is corrupt
+	Offset int    // Offset of the field key within the outermost message
+	Err    error  // The underlying error
+}
+
+// Error implements the error interface.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("proto: cannot decode %s field %d at offset %d: %s", e.Type, e.Field, e.Offset, e.Err)
+}
+
+// UnmarshalWithDetails decodes data into msg, just like gogoproto.Unmarshal.
+// The generated unmarshalers report failures without saying where they
+// occurred; on failure, the data is walked again to locate the problem,
+// and a *DecodeError is returned.
+func UnmarshalWithDetails(data []byte, msg gogoproto.Message) error {
+	err := gogoproto.Unmarshal(data, msg)
+	if err == nil {
+		return nil
+	}
+	return locateDecodeError(data, 0, reflect.TypeOf(msg).Elem(), err)
+}
+
+// locateDecodeError walks the fields of the encoded message of the given
+// type, returning a DecodeError for the first field which can't be decoded.
+// Offsets are reported relative to base. If no field is found at fault,
+// the supplied cause is attributed to the end of the message.
+func locateDecodeError(data []byte, base int, typ reflect.Type, cause error) *DecodeError {
+	fields := messageFields(typ)
+	for i := 0; i < len(data); {
+		start := i
+		key, n := decodeWireVarint(data[i:])
+		if n == 0 {
+			return &DecodeError{Type: typ.Name(), Offset: base + start, Err: io.ErrUnexpectedEOF}
+		}
+		i += n
+		fieldNum, wireType := int32(key>>3), int(key&0x7)
+		newErr := func(err error) *DecodeError {
+			return &DecodeError{Type: typ.Name(), Field: fieldNum, Offset: base + start, Err: err}
+		}
+		field, known := fields[fieldNum]
+		if known && field.wireType != wireType {
+			return newErr(fmt.Errorf("wrong wireType = %d, expected %d", wireType, field.wireType))
+		}
+		switch wireType {
+		case 0:
+			if _, n = decodeWireVarint(data[i:]); n == 0 {
+				return newErr(io.ErrUnexpectedEOF)
+			}
+			i += n
+		case 1, 5:
+			size := 8
+			if wireType == 5 {
+				size = 4
+			}
+			if i+size > len(data) {
+				return newErr(io.ErrUnexpectedEOF)
+			}
+			i += size
+		case 2:
+			length, n := decodeWireVarint(data[i:])
+			if n == 0 || length > uint64(len(data)-i-n) {
+				return newErr(io.ErrUnexpectedEOF)
+			}
+			valueStart, valueEnd := i+n, i+n+int(length)
+			if known && field.msgType != nil {
+				value := data[valueStart:valueEnd]
+				msg := reflect.New(field.msgType).Interface().(gogoproto.Message)
+				if err := gogoproto.Unmarshal(value, msg); err != nil {
+					return locateDecodeError(value, base+valueStart, field.msgType, err)
+				}
+			}
+			i = valueEnd
+		default:
+			return newErr(fmt.Errorf("illegal wireType %d", wireType))
+		}
+	}
+	return &DecodeError{Type: typ.Name(), Offset: base + len(data), Err: cause}
+}
+
+// A wireField describes how a field of a message is encoded.
+type wireField struct {
+	wireType int
+	msgType  reflect.Type // Non-nil for embedded messages
+}
+
+var messageFieldsCache struct {
+	sync.Mutex
+	m map[reflect.Type]map[int32]wireField
+}
+
+// messageFields returns the wire encoding of the fields of the given
+// generated message type, keyed by field number, as declared by the
+// protobuf struct tags.
+func messageFields(typ reflect.Type) map[int32]wireField {
+	messageFieldsCache.Lock()
+	defer messageFieldsCache.Unlock()
+	if fields, ok := messageFieldsCache.m[typ]; ok {
+		return fields
+	}
+	fields := map[int32]wireField{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		parts := strings.Split(f.Tag.Get("protobuf"), ",")
+		if len(parts) < 2 {
+			continue
+		}
+		num, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		var field wireField
+		switch parts[0] {
+		case "varint", "zigzag32", "zigzag64":
+			field.wireType = 0
+		case "fixed64":
+			field.wireType = 1
+		case "bytes":
+			field.wireType = 2
+			ft := f.Type
+			if ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if _, ok := reflect.New(ft).Interface().(gogoproto.Message); ok {
+					field.msgType = ft
+				}
+			}
+		case "fixed32":
+			field.wireType = 5
+		default:
+			continue
+		}
+		for _, opt := range parts[2:] {
+			if opt == "packed" {
+				field.wireType = 2
+			}
+		}
+		fields[int32(num)] = field
+	}
+	if messageFieldsCache.m == nil {
+		messageFieldsCache.m = map[reflect.Type]map[int32]wireField{}
+	}
+	messageFieldsCache.m[typ] = fields
+	return fields
+}
+
+// decodeWireVarint decodes a varint from the start of data, returning the
+// value and the number of bytes consumed, which is zero if data does not
+// start with a complete varint.
+func decodeWireVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		b := data[i]
+		v |= uint64(b&0x7F) << (7 * uint(i))
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -19,6 +19,7 @@ package proto
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cockroachdb/cockroach/util/retry"
 )
@@ -231,3 +232,33 @@ func (e *OpRequiresTxnError) Error() string {
 func (e *ConditionFailedError) Error() string {
 	return fmt.Sprintf("unexpected value: %s", e.ActualValue)
 }
+
+// Error formats error.
+func (e *BatchConditionFailedError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i := range e.Failures {
+		failures[i] = fmt.Sprintf("%d: %s", e.Failures[i].Index, &e.Failures[i])
+	}
+	return fmt.Sprintf("conditions failed in batch: %s", strings.Join(failures, ", "))
+}
+
+// Error formats error.
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value of %d bytes for key %s exceeds maximum of %d bytes", e.ValueBytes, e.Key, e.MaxBytes)
+}
+
+// Error formats error.
+func (e *BatchTooLargeError) Error() string {
+	return fmt.Sprintf("batch of %d requests and %d bytes exceeds maximum of %d requests and %d bytes",
+		e.RequestCount, e.RequestBytes, e.MaxRequestCount, e.MaxRequestBytes)
+}
+
+// Error formats error.
+func (e *ReadBelowGCThresholdError) Error() string {
+	return fmt.Sprintf("read at %s is below the GC threshold %s", e.Timestamp, e.Threshold)
+}
+
+// Error formats error.
+func (e *TxnTooLargeError) Error() string {
+	return fmt.Sprintf("transaction has written %d intents; the maximum is %d", e.IntentCount, e.MaxIntents)
+}
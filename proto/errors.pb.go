@@ -334,6 +334,9 @@ func (*OpRequiresTxnError) ProtoMessage() {}
 // contain the actual value found.
 type ConditionFailedError struct {
 	ActualValue *Value `protobuf:"bytes,1,opt,name=actual_value" json:"actual_value,omitempty"`
+	// The index of the failed request within its batch. Only set on the
+	// failures listed by a BatchConditionFailedError.
+	Index int32 `protobuf:"varint,2,opt,name=index" json:"index"`
 }
 
 func (m *ConditionFailedError) Reset()      { *m = ConditionFailedError{} }
@@ -346,6 +349,13 @@ func (m *ConditionFailedError) GetActualValue() *Value {
 	return nil
 }
 
+func (m *ConditionFailedError) GetIndex() int32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
 // A LeaseRejectedError indicates that the requested replica could
 // not acquire the desired lease because of an existing leader lease.
 type LeaseRejectedError struct {
@@ -370,6 +380,148 @@ func (m *LeaseRejectedError) GetExisting() Lease {
 	return Lease{}
 }
 
+// A ValueTooLargeError indicates that a write was rejected because
+// the size of its value exceeds the maximum allowed for the key's zone.
+type ValueTooLargeError struct {
+	Key        Key   `protobuf:"bytes,1,opt,name=key,casttype=Key" json:"key,omitempty"`
+	ValueBytes int64 `protobuf:"varint,2,opt,name=value_bytes" json:"value_bytes"`
+	MaxBytes   int64 `protobuf:"varint,3,opt,name=max_bytes" json:"max_bytes"`
+}
+
+func (m *ValueTooLargeError) Reset()      { *m = ValueTooLargeError{} }
+func (*ValueTooLargeError) ProtoMessage() {}
+
+func (m *ValueTooLargeError) GetKey() Key {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *ValueTooLargeError) GetValueBytes() int64 {
+	if m != nil {
+		return m.ValueBytes
+	}
+	return 0
+}
+
+func (m *ValueTooLargeError) GetMaxBytes() int64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+// A BatchConditionFailedError indicates that one or more of the
+// conditional puts in a batch failed their condition. It lists every
+// failure along with the request's index in the batch.
+type BatchConditionFailedError struct {
+	Failures []ConditionFailedError `protobuf:"bytes,1,rep,name=failures" json:"failures,omitempty"`
+}
+
+func (m *BatchConditionFailedError) Reset()      { *m = BatchConditionFailedError{} }
+func (*BatchConditionFailedError) ProtoMessage() {}
+
+func (m *BatchConditionFailedError) GetFailures() []ConditionFailedError {
+	if m != nil {
+		return m.Failures
+	}
+	return nil
+}
+
+// A BatchTooLargeError indicates that a batch was rejected because it
+// holds more requests or more bytes than allowed. It carries both the
+// batch's size and the limits, so that a client can split the batch
+// into chunks within the limits and retry.
+type BatchTooLargeError struct {
+	RequestCount    int64 `protobuf:"varint,1,opt,name=request_count" json:"request_count"`
+	RequestBytes    int64 `protobuf:"varint,2,opt,name=request_bytes" json:"request_bytes"`
+	MaxRequestCount int64 `protobuf:"varint,3,opt,name=max_request_count" json:"max_request_count"`
+	MaxRequestBytes int64 `protobuf:"varint,4,opt,name=max_request_bytes" json:"max_request_bytes"`
+}
+
+func (m *BatchTooLargeError) Reset()      { *m = BatchTooLargeError{} }
+func (*BatchTooLargeError) ProtoMessage() {}
+
+func (m *BatchTooLargeError) GetRequestCount() int64 {
+	if m != nil {
+		return m.RequestCount
+	}
+	return 0
+}
+
+func (m *BatchTooLargeError) GetRequestBytes() int64 {
+	if m != nil {
+		return m.RequestBytes
+	}
+	return 0
+}
+
+func (m *BatchTooLargeError) GetMaxRequestCount() int64 {
+	if m != nil {
+		return m.MaxRequestCount
+	}
+	return 0
+}
+
+func (m *BatchTooLargeError) GetMaxRequestBytes() int64 {
+	if m != nil {
+		return m.MaxRequestBytes
+	}
+	return 0
+}
+
+// A ReadBelowGCThresholdError indicates that a read was rejected
+// because its timestamp is below the GC threshold of the range, so
+// that versions it would have to observe may have been garbage
+// collected already.
+type ReadBelowGCThresholdError struct {
+	Timestamp Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp"`
+	Threshold Timestamp `protobuf:"bytes,2,opt,name=threshold" json:"threshold"`
+}
+
+func (m *ReadBelowGCThresholdError) Reset()      { *m = ReadBelowGCThresholdError{} }
+func (*ReadBelowGCThresholdError) ProtoMessage() {}
+
+func (m *ReadBelowGCThresholdError) GetTimestamp() Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return Timestamp{}
+}
+
+func (m *ReadBelowGCThresholdError) GetThreshold() Timestamp {
+	if m != nil {
+		return m.Threshold
+	}
+	return Timestamp{}
+}
+
+// A TxnTooLargeError indicates that a transactional write was rejected
+// because the transaction has already written the maximum number of
+// intents allowed.
+type TxnTooLargeError struct {
+	IntentCount int64 `protobuf:"varint,1,opt,name=intent_count" json:"intent_count"`
+	MaxIntents  int64 `protobuf:"varint,2,opt,name=max_intents" json:"max_intents"`
+}
+
+func (m *TxnTooLargeError) Reset()      { *m = TxnTooLargeError{} }
+func (*TxnTooLargeError) ProtoMessage() {}
+
+func (m *TxnTooLargeError) GetIntentCount() int64 {
+	if m != nil {
+		return m.IntentCount
+	}
+	return 0
+}
+
+func (m *TxnTooLargeError) GetMaxIntents() int64 {
+	if m != nil {
+		return m.MaxIntents
+	}
+	return 0
+}
+
 // ErrorDetail is a union type containing all available errors.
 type ErrorDetail struct {
 	NotLeader                     *NotLeaderError                     `protobuf:"bytes,1,opt,name=not_leader" json:"not_leader,omitempty"`
@@ -386,6 +538,11 @@ type ErrorDetail struct {
 	ConditionFailed               *ConditionFailedError               `protobuf:"bytes,12,opt,name=condition_failed" json:"condition_failed,omitempty"`
 	LeaseRejected                 *LeaseRejectedError                 `protobuf:"bytes,13,opt,name=lease_rejected" json:"lease_rejected,omitempty"`
 	NodeUnavailable               *NodeUnavailableError               `protobuf:"bytes,14,opt,name=node_unavailable" json:"node_unavailable,omitempty"`
+	ValueTooLarge                 *ValueTooLargeError                 `protobuf:"bytes,15,opt,name=value_too_large" json:"value_too_large,omitempty"`
+	BatchConditionFailed          *BatchConditionFailedError          `protobuf:"bytes,16,opt,name=batch_condition_failed" json:"batch_condition_failed,omitempty"`
+	BatchTooLarge                 *BatchTooLargeError                 `protobuf:"bytes,17,opt,name=batch_too_large" json:"batch_too_large,omitempty"`
+	ReadBelowGCThreshold          *ReadBelowGCThresholdError          `protobuf:"bytes,18,opt,name=read_below_gc_threshold" json:"read_below_gc_threshold,omitempty"`
+	TxnTooLarge                   *TxnTooLargeError                   `protobuf:"bytes,19,opt,name=txn_too_large" json:"txn_too_large,omitempty"`
 }
 
 func (m *ErrorDetail) Reset()      { *m = ErrorDetail{} }
@@ -489,6 +646,41 @@ func (m *ErrorDetail) GetNodeUnavailable() *NodeUnavailableError {
 	return nil
 }
 
+func (m *ErrorDetail) GetValueTooLarge() *ValueTooLargeError {
+	if m != nil {
+		return m.ValueTooLarge
+	}
+	return nil
+}
+
+func (m *ErrorDetail) GetBatchConditionFailed() *BatchConditionFailedError {
+	if m != nil {
+		return m.BatchConditionFailed
+	}
+	return nil
+}
+
+func (m *ErrorDetail) GetBatchTooLarge() *BatchTooLargeError {
+	if m != nil {
+		return m.BatchTooLarge
+	}
+	return nil
+}
+
+func (m *ErrorDetail) GetReadBelowGCThreshold() *ReadBelowGCThresholdError {
+	if m != nil {
+		return m.ReadBelowGCThreshold
+	}
+	return nil
+}
+
+func (m *ErrorDetail) GetTxnTooLarge() *TxnTooLargeError {
+	if m != nil {
+		return m.TxnTooLarge
+	}
+	return nil
+}
+
 // Error is a generic representation including a string message
 // and information about retryability.
 type Error struct {
@@ -926,6 +1118,9 @@ func (m *ConditionFailedError) MarshalTo(data []byte) (int, error) {
 		}
 		i += n13
 	}
+	data[i] = 0x10
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.Index))
 	return i, nil
 }
 
@@ -963,6 +1158,154 @@ func (m *LeaseRejectedError) MarshalTo(data []byte) (int, error) {
 	return i, nil
 }
 
+func (m *ValueTooLargeError) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *ValueTooLargeError) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Key != nil {
+		data[i] = 0xa
+		i++
+		i = encodeVarintErrors(data, i, uint64(len(m.Key)))
+		i += copy(data[i:], m.Key)
+	}
+	data[i] = 0x10
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.ValueBytes))
+	data[i] = 0x18
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.MaxBytes))
+	return i, nil
+}
+
+func (m *BatchConditionFailedError) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *BatchConditionFailedError) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Failures) > 0 {
+		for _, msg := range m.Failures {
+			data[i] = 0xa
+			i++
+			i = encodeVarintErrors(data, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(data[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *BatchTooLargeError) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *BatchTooLargeError) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0x8
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.RequestCount))
+	data[i] = 0x10
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.RequestBytes))
+	data[i] = 0x18
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.MaxRequestCount))
+	data[i] = 0x20
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.MaxRequestBytes))
+	return i, nil
+}
+
+func (m *ReadBelowGCThresholdError) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *ReadBelowGCThresholdError) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0xa
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.Timestamp.Size()))
+	n16, err := m.Timestamp.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n16
+	data[i] = 0x12
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.Threshold.Size()))
+	n17, err := m.Threshold.MarshalTo(data[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n17
+	return i, nil
+}
+
+func (m *TxnTooLargeError) Marshal() (data []byte, err error) {
+	size := m.Size()
+	data = make([]byte, size)
+	n, err := m.MarshalTo(data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+func (m *TxnTooLargeError) MarshalTo(data []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	data[i] = 0x8
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.IntentCount))
+	data[i] = 0x10
+	i++
+	i = encodeVarintErrors(data, i, uint64(m.MaxIntents))
+	return i, nil
+}
+
 func (m *ErrorDetail) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
@@ -982,145 +1325,203 @@ func (m *ErrorDetail) MarshalTo(data []byte) (int, error) {
 		data[i] = 0xa
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.NotLeader.Size()))
-		n16, err := m.NotLeader.MarshalTo(data[i:])
+		n18, err := m.NotLeader.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n16
+		i += n18
 	}
 	if m.RangeNotFound != nil {
 		data[i] = 0x12
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.RangeNotFound.Size()))
-		n17, err := m.RangeNotFound.MarshalTo(data[i:])
+		n19, err := m.RangeNotFound.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n17
+		i += n19
 	}
 	if m.RangeKeyMismatch != nil {
 		data[i] = 0x1a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.RangeKeyMismatch.Size()))
-		n18, err := m.RangeKeyMismatch.MarshalTo(data[i:])
+		n20, err := m.RangeKeyMismatch.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n18
+		i += n20
 	}
 	if m.ReadWithinUncertaintyInterval != nil {
 		data[i] = 0x22
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.ReadWithinUncertaintyInterval.Size()))
-		n19, err := m.ReadWithinUncertaintyInterval.MarshalTo(data[i:])
+		n21, err := m.ReadWithinUncertaintyInterval.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n19
+		i += n21
 	}
 	if m.TransactionAborted != nil {
 		data[i] = 0x2a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.TransactionAborted.Size()))
-		n20, err := m.TransactionAborted.MarshalTo(data[i:])
+		n22, err := m.TransactionAborted.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n20
+		i += n22
 	}
 	if m.TransactionPush != nil {
 		data[i] = 0x32
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.TransactionPush.Size()))
-		n21, err := m.TransactionPush.MarshalTo(data[i:])
+		n23, err := m.TransactionPush.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n21
+		i += n23
 	}
 	if m.TransactionRetry != nil {
 		data[i] = 0x3a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.TransactionRetry.Size()))
-		n22, err := m.TransactionRetry.MarshalTo(data[i:])
+		n24, err := m.TransactionRetry.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n22
+		i += n24
 	}
 	if m.TransactionStatus != nil {
 		data[i] = 0x42
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.TransactionStatus.Size()))
-		n23, err := m.TransactionStatus.MarshalTo(data[i:])
+		n25, err := m.TransactionStatus.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n23
+		i += n25
 	}
 	if m.WriteIntent != nil {
 		data[i] = 0x4a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.WriteIntent.Size()))
-		n24, err := m.WriteIntent.MarshalTo(data[i:])
+		n26, err := m.WriteIntent.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n24
+		i += n26
 	}
 	if m.WriteTooOld != nil {
 		data[i] = 0x52
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.WriteTooOld.Size()))
-		n25, err := m.WriteTooOld.MarshalTo(data[i:])
+		n27, err := m.WriteTooOld.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n25
+		i += n27
 	}
 	if m.OpRequiresTxn != nil {
 		data[i] = 0x5a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.OpRequiresTxn.Size()))
-		n26, err := m.OpRequiresTxn.MarshalTo(data[i:])
+		n28, err := m.OpRequiresTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n26
+		i += n28
 	}
 	if m.ConditionFailed != nil {
 		data[i] = 0x62
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.ConditionFailed.Size()))
-		n27, err := m.ConditionFailed.MarshalTo(data[i:])
+		n29, err := m.ConditionFailed.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n27
+		i += n29
 	}
 	if m.LeaseRejected != nil {
 		data[i] = 0x6a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.LeaseRejected.Size()))
-		n28, err := m.LeaseRejected.MarshalTo(data[i:])
+		n30, err := m.LeaseRejected.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n28
+		i += n30
 	}
 	if m.NodeUnavailable != nil {
 		data[i] = 0x72
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.NodeUnavailable.Size()))
-		n29, err := m.NodeUnavailable.MarshalTo(data[i:])
+		n31, err := m.NodeUnavailable.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n29
+		i += n31
 	}
-	return i, nil
-}
-
+	if m.ValueTooLarge != nil {
+		data[i] = 0x7a
+		i++
+		i = encodeVarintErrors(data, i, uint64(m.ValueTooLarge.Size()))
+		n32, err := m.ValueTooLarge.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n32
+	}
+	if m.BatchConditionFailed != nil {
+		data[i] = 0x82
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintErrors(data, i, uint64(m.BatchConditionFailed.Size()))
+		n33, err := m.BatchConditionFailed.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n33
+	}
+	if m.BatchTooLarge != nil {
+		data[i] = 0x8a
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintErrors(data, i, uint64(m.BatchTooLarge.Size()))
+		n34, err := m.BatchTooLarge.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n34
+	}
+	if m.ReadBelowGCThreshold != nil {
+		data[i] = 0x92
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintErrors(data, i, uint64(m.ReadBelowGCThreshold.Size()))
+		n35, err := m.ReadBelowGCThreshold.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n35
+	}
+	if m.TxnTooLarge != nil {
+		data[i] = 0x9a
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintErrors(data, i, uint64(m.TxnTooLarge.Size()))
+		n36, err := m.TxnTooLarge.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n36
+	}
+	return i, nil
+}
+
 func (m *Error) Marshal() (data []byte, err error) {
 	size := m.Size()
 	data = make([]byte, size)
@@ -1152,11 +1553,11 @@ func (m *Error) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1a
 		i++
 		i = encodeVarintErrors(data, i, uint64(m.Detail.Size()))
-		n30, err := m.Detail.MarshalTo(data[i:])
+		n37, err := m.Detail.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n30
+		i += n37
 	}
 	data[i] = 0x20
 	i++
@@ -1321,6 +1722,7 @@ func (m *ConditionFailedError) Size() (n int) {
 		l = m.ActualValue.Size()
 		n += 1 + l + sovErrors(uint64(l))
 	}
+	n += 1 + sovErrors(uint64(m.Index))
 	return n
 }
 
@@ -1334,6 +1736,58 @@ func (m *LeaseRejectedError) Size() (n int) {
 	return n
 }
 
+func (m *ValueTooLargeError) Size() (n int) {
+	var l int
+	_ = l
+	if m.Key != nil {
+		l = len(m.Key)
+		n += 1 + l + sovErrors(uint64(l))
+	}
+	n += 1 + sovErrors(uint64(m.ValueBytes))
+	n += 1 + sovErrors(uint64(m.MaxBytes))
+	return n
+}
+
+func (m *BatchConditionFailedError) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Failures) > 0 {
+		for _, e := range m.Failures {
+			l = e.Size()
+			n += 1 + l + sovErrors(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *BatchTooLargeError) Size() (n int) {
+	var l int
+	_ = l
+	n += 1 + sovErrors(uint64(m.RequestCount))
+	n += 1 + sovErrors(uint64(m.RequestBytes))
+	n += 1 + sovErrors(uint64(m.MaxRequestCount))
+	n += 1 + sovErrors(uint64(m.MaxRequestBytes))
+	return n
+}
+
+func (m *ReadBelowGCThresholdError) Size() (n int) {
+	var l int
+	_ = l
+	l = m.Timestamp.Size()
+	n += 1 + l + sovErrors(uint64(l))
+	l = m.Threshold.Size()
+	n += 1 + l + sovErrors(uint64(l))
+	return n
+}
+
+func (m *TxnTooLargeError) Size() (n int) {
+	var l int
+	_ = l
+	n += 1 + sovErrors(uint64(m.IntentCount))
+	n += 1 + sovErrors(uint64(m.MaxIntents))
+	return n
+}
+
 func (m *ErrorDetail) Size() (n int) {
 	var l int
 	_ = l
@@ -1393,6 +1847,26 @@ func (m *ErrorDetail) Size() (n int) {
 		l = m.NodeUnavailable.Size()
 		n += 1 + l + sovErrors(uint64(l))
 	}
+	if m.ValueTooLarge != nil {
+		l = m.ValueTooLarge.Size()
+		n += 1 + l + sovErrors(uint64(l))
+	}
+	if m.BatchConditionFailed != nil {
+		l = m.BatchConditionFailed.Size()
+		n += 2 + l + sovErrors(uint64(l))
+	}
+	if m.BatchTooLarge != nil {
+		l = m.BatchTooLarge.Size()
+		n += 2 + l + sovErrors(uint64(l))
+	}
+	if m.ReadBelowGCThreshold != nil {
+		l = m.ReadBelowGCThreshold.Size()
+		n += 2 + l + sovErrors(uint64(l))
+	}
+	if m.TxnTooLarge != nil {
+		l = m.TxnTooLarge.Size()
+		n += 2 + l + sovErrors(uint64(l))
+	}
 	return n
 }
 
@@ -1466,6 +1940,21 @@ func (this *ErrorDetail) GetValue() interface{} {
 	if this.NodeUnavailable != nil {
 		return this.NodeUnavailable
 	}
+	if this.ValueTooLarge != nil {
+		return this.ValueTooLarge
+	}
+	if this.BatchConditionFailed != nil {
+		return this.BatchConditionFailed
+	}
+	if this.BatchTooLarge != nil {
+		return this.BatchTooLarge
+	}
+	if this.ReadBelowGCThreshold != nil {
+		return this.ReadBelowGCThreshold
+	}
+	if this.TxnTooLarge != nil {
+		return this.TxnTooLarge
+	}
 	return nil
 }
 
@@ -1499,6 +1988,16 @@ func (this *ErrorDetail) SetValue(value interface{}) bool {
 		this.LeaseRejected = vt
 	case *NodeUnavailableError:
 		this.NodeUnavailable = vt
+	case *ValueTooLargeError:
+		this.ValueTooLarge = vt
+	case *BatchConditionFailedError:
+		this.BatchConditionFailed = vt
+	case *BatchTooLargeError:
+		this.BatchTooLarge = vt
+	case *ReadBelowGCThresholdError:
+		this.ReadBelowGCThreshold = vt
+	case *TxnTooLargeError:
+		this.TxnTooLarge = vt
 	default:
 		return false
 	}
@@ -2580,6 +3079,22 @@ func (m *ConditionFailedError) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
+			}
+			m.Index = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Index |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
@@ -2705,7 +3220,7 @@ func (m *LeaseRejectedError) Unmarshal(data []byte) error {
 
 	return nil
 }
-func (m *ErrorDetail) Unmarshal(data []byte) error {
+func (m *ValueTooLargeError) Unmarshal(data []byte) error {
 	l := len(data)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2726,97 +3241,109 @@ func (m *ErrorDetail) Unmarshal(data []byte) error {
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field NotLeader", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthErrors
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.NotLeader == nil {
-				m.NotLeader = &NotLeaderError{}
-			}
-			if err := m.NotLeader.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Key = append([]byte{}, data[iNdEx:postIndex]...)
 			iNdEx = postIndex
 		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RangeNotFound", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValueBytes", wireType)
 			}
-			var msglen int
+			m.ValueBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.ValueBytes |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthErrors
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.RangeNotFound == nil {
-				m.RangeNotFound = &RangeNotFoundError{}
-			}
-			if err := m.RangeNotFound.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
 		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RangeKeyMismatch", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxBytes", wireType)
 			}
-			var msglen int
+			m.MaxBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.MaxBytes |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipErrors(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthErrors
 			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.RangeKeyMismatch == nil {
-				m.RangeKeyMismatch = &RangeKeyMismatchError{}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *BatchConditionFailedError) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			if err := m.RangeKeyMismatch.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-			iNdEx = postIndex
-		case 4:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReadWithinUncertaintyInterval", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Failures", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -2837,55 +3364,503 @@ func (m *ErrorDetail) Unmarshal(data []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ReadWithinUncertaintyInterval == nil {
-				m.ReadWithinUncertaintyInterval = &ReadWithinUncertaintyIntervalError{}
-			}
-			if err := m.ReadWithinUncertaintyInterval.Unmarshal(data[iNdEx:postIndex]); err != nil {
+			m.Failures = append(m.Failures, ConditionFailedError{})
+			if err := m.Failures[len(m.Failures)-1].Unmarshal(data[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 5:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TransactionAborted", wireType)
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
 			}
-			var msglen int
+			iNdEx -= sizeOfWire
+			skippy, err := skipErrors(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthErrors
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *BatchTooLargeError) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestCount", wireType)
+			}
+			m.RequestCount = 0
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.RequestCount |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthErrors
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.TransactionAborted == nil {
-				m.TransactionAborted = &TransactionAbortedError{}
-			}
-			if err := m.TransactionAborted.Unmarshal(data[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 6:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TransactionPush", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestBytes", wireType)
 			}
-			var msglen int
+			m.RequestBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
 				b := data[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.RequestBytes |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxRequestCount", wireType)
+			}
+			m.MaxRequestCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.MaxRequestCount |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxRequestBytes", wireType)
+			}
+			m.MaxRequestBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.MaxRequestBytes |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipErrors(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthErrors
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *ReadBelowGCThresholdError) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Timestamp.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Threshold", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Threshold.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipErrors(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthErrors
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *TxnTooLargeError) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IntentCount", wireType)
+			}
+			m.IntentCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.IntentCount |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxIntents", wireType)
+			}
+			m.MaxIntents = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.MaxIntents |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			var sizeOfWire int
+			for {
+				sizeOfWire++
+				wire >>= 7
+				if wire == 0 {
+					break
+				}
+			}
+			iNdEx -= sizeOfWire
+			skippy, err := skipErrors(data[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthErrors
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	return nil
+}
+func (m *ErrorDetail) Unmarshal(data []byte) error {
+	l := len(data)
+	iNdEx := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := data[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NotLeader", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.NotLeader == nil {
+				m.NotLeader = &NotLeaderError{}
+			}
+			if err := m.NotLeader.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RangeNotFound", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RangeNotFound == nil {
+				m.RangeNotFound = &RangeNotFoundError{}
+			}
+			if err := m.RangeNotFound.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RangeKeyMismatch", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RangeKeyMismatch == nil {
+				m.RangeKeyMismatch = &RangeKeyMismatchError{}
+			}
+			if err := m.RangeKeyMismatch.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadWithinUncertaintyInterval", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ReadWithinUncertaintyInterval == nil {
+				m.ReadWithinUncertaintyInterval = &ReadWithinUncertaintyIntervalError{}
+			}
+			if err := m.ReadWithinUncertaintyInterval.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TransactionAborted", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TransactionAborted == nil {
+				m.TransactionAborted = &TransactionAbortedError{}
+			}
+			if err := m.TransactionAborted.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TransactionPush", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -3144,6 +4119,156 @@ func (m *ErrorDetail) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ValueTooLarge", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ValueTooLarge == nil {
+				m.ValueTooLarge = &ValueTooLargeError{}
+			}
+			if err := m.ValueTooLarge.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BatchConditionFailed", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.BatchConditionFailed == nil {
+				m.BatchConditionFailed = &BatchConditionFailedError{}
+			}
+			if err := m.BatchConditionFailed.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BatchTooLarge", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.BatchTooLarge == nil {
+				m.BatchTooLarge = &BatchTooLargeError{}
+			}
+			if err := m.BatchTooLarge.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadBelowGCThreshold", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ReadBelowGCThreshold == nil {
+				m.ReadBelowGCThreshold = &ReadBelowGCThresholdError{}
+			}
+			if err := m.ReadBelowGCThreshold.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TxnTooLarge", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthErrors
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TxnTooLarge == nil {
+				m.TxnTooLarge = &TxnTooLargeError{}
+			}
+			if err := m.TxnTooLarge.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			var sizeOfWire int
 			for {
@@ -24,6 +24,12 @@ import (
 	gogoproto "github.com/gogo/protobuf/proto"
 )
 
+// RaftCommandVersion is the newest RaftCommand format version which
+// this node understands. It must be incremented whenever the semantics
+// of replicated commands change in a way which older nodes would
+// misinterpret.
+const RaftCommandVersion uint32 = 0
+
 // ToValue generates a Value message which contains an encoded copy of this
 // TimeSeriesData in its "bytes" field. The returned Value will also have its
 // "tag" string set to the TIME_SERIES constant.
@@ -45,13 +51,50 @@ func InternalTimeSeriesDataFromValue(value *Value) (*InternalTimeSeriesData, err
 		return nil, fmt.Errorf("value is not tagged as containing TimeSeriesData: %v", value)
 	}
 	var ts InternalTimeSeriesData
-	err := gogoproto.Unmarshal(value.Bytes, &ts)
+	err := UnmarshalWithDetails(value.Bytes, &ts)
 	if err != nil {
 		return nil, fmt.Errorf("TimeSeriesData could not be unmarshalled from value: %v %s", value, err)
 	}
 	return &ts, nil
 }
 
+// Rate returns the per-second rate of change of the cumulative series
+// ts, such as a counter, as a new collection with the same start
+// timestamp and sample duration. The value of a sample is taken to be
+// its average, which is simply its sum for a sample of a single
+// measurement. Each sample after the first yields a rate sample at the
+// same offset, holding the difference to the preceding sample divided
+// by the time between the two. Missing offsets are thus interpolated
+// over: the rate across a gap is spread evenly over its duration. A
+// decrease is taken to be a reset of the counter to zero, so the rate
+// is computed from the value after the reset alone.
+func (ts *InternalTimeSeriesData) Rate() (*InternalTimeSeriesData, error) {
+	if ts.SampleDurationNanos <= 0 {
+		return nil, fmt.Errorf("invalid sample duration %d", ts.SampleDurationNanos)
+	}
+	rate := &InternalTimeSeriesData{
+		StartTimestampNanos: ts.StartTimestampNanos,
+		SampleDurationNanos: ts.SampleDurationNanos,
+	}
+	for i := 1; i < len(ts.Samples); i++ {
+		prev, cur := ts.Samples[i-1], ts.Samples[i]
+		if cur.Offset <= prev.Offset {
+			return nil, fmt.Errorf("samples out of order: offset %d follows %d", cur.Offset, prev.Offset)
+		}
+		delta := cur.Average() - prev.Average()
+		if delta < 0 {
+			delta = cur.Average()
+		}
+		seconds := float64(int64(cur.Offset-prev.Offset)*ts.SampleDurationNanos) / 1e9
+		rate.Samples = append(rate.Samples, &InternalTimeSeriesSample{
+			Offset: cur.Offset,
+			Count:  1,
+			Sum:    delta / seconds,
+		})
+	}
+	return rate, nil
+}
+
 // Average returns the average value for this sample.
 func (samp *InternalTimeSeriesSample) Average() float64 {
 	if samp.Count == 0 {
@@ -75,3 +118,46 @@ func (samp *InternalTimeSeriesSample) Minimum() float64 {
 	}
 	return samp.GetMin()
 }
+
+// CompressKeys prefix compresses the keys of the snapshot's entries,
+// replacing each key by the suffix which follows the prefix it shares
+// with the key of the preceding entry. Entries are expected in key
+// order, as read from the range, for keys to share long prefixes. Keys
+// which are already compressed are left alone.
+func (m *RaftSnapshotData) CompressKeys() {
+	if m.PrefixCompressed {
+		return
+	}
+	var prev []byte
+	for _, kv := range m.KV {
+		key := kv.Key
+		n := 0
+		for n < len(prev) && n < len(key) && prev[n] == key[n] {
+			n++
+		}
+		kv.Key, kv.SharedPrefixLen = key[n:], uint32(n)
+		prev = key
+	}
+	m.PrefixCompressed = true
+}
+
+// DecompressKeys restores the full keys of the snapshot's entries if
+// they were prefix compressed by CompressKeys.
+func (m *RaftSnapshotData) DecompressKeys() error {
+	if !m.PrefixCompressed {
+		return nil
+	}
+	var prev []byte
+	for i, kv := range m.KV {
+		n := int(kv.SharedPrefixLen)
+		if n > len(prev) {
+			return fmt.Errorf("snapshot entry %d shares %d bytes with a preceding key of %d bytes", i, n, len(prev))
+		}
+		key := make([]byte, 0, n+len(kv.Key))
+		key = append(append(key, prev[:n]...), kv.Key...)
+		kv.Key, kv.SharedPrefixLen = key, 0
+		prev = key
+	}
+	m.PrefixCompressed = false
+	return nil
+}
@@ -71,6 +71,8 @@ type ResponseCacheEntry struct {
 	Merge              *MergeResponse              `protobuf:"bytes,12,opt,name=merge" json:"merge,omitempty"`
 	TruncateLog        *TruncateLogResponse        `protobuf:"bytes,13,opt,name=truncate_log" json:"truncate_log,omitempty"`
 	LeaderLease        *LeaderLeaseResponse        `protobuf:"bytes,14,opt,name=leader_lease" json:"leader_lease,omitempty"`
+	CheckAndPut        *CheckAndPutResponse        `protobuf:"bytes,15,opt,name=check_and_put" json:"check_and_put,omitempty"`
+	MultiIncrement     *MultiIncrementResponse     `protobuf:"bytes,16,opt,name=multi_increment" json:"multi_increment,omitempty"`
 	Batch              *BatchResponse              `protobuf:"bytes,30,opt,name=batch" json:"batch,omitempty"`
 }
 
@@ -176,6 +178,20 @@ func (m *ResponseCacheEntry) GetLeaderLease() *LeaderLeaseResponse {
 	return nil
 }
 
+func (m *ResponseCacheEntry) GetCheckAndPut() *CheckAndPutResponse {
+	if m != nil {
+		return m.CheckAndPut
+	}
+	return nil
+}
+
+func (m *ResponseCacheEntry) GetMultiIncrement() *MultiIncrementResponse {
+	if m != nil {
+		return m.MultiIncrement
+	}
+	return nil
+}
+
 func (m *ResponseCacheEntry) GetBatch() *BatchResponse {
 	if m != nil {
 		return m.Batch
@@ -205,6 +221,8 @@ type RaftCommandUnion struct {
 	TruncateLog        *TruncateLogRequest        `protobuf:"bytes,16,opt,name=truncate_log" json:"truncate_log,omitempty"`
 	Lease              *LeaderLeaseRequest        `protobuf:"bytes,17,opt,name=lease" json:"lease,omitempty"`
 	ReverseScan        *ReverseScanRequest        `protobuf:"bytes,18,opt,name=reverse_scan" json:"reverse_scan,omitempty"`
+	CheckAndPut        *CheckAndPutRequest        `protobuf:"bytes,19,opt,name=check_and_put" json:"check_and_put,omitempty"`
+	MultiIncrement     *MultiIncrementRequest     `protobuf:"bytes,20,opt,name=multi_increment" json:"multi_increment,omitempty"`
 	// Other requests. Allow a gap in tag numbers so the previous list can
 	// be copy/pasted from RequestUnion.
 	Batch *BatchRequest `protobuf:"bytes,30,opt,name=batch" json:"batch,omitempty"`
@@ -340,6 +358,20 @@ func (m *RaftCommandUnion) GetReverseScan() *ReverseScanRequest {
 	return nil
 }
 
+func (m *RaftCommandUnion) GetCheckAndPut() *CheckAndPutRequest {
+	if m != nil {
+		return m.CheckAndPut
+	}
+	return nil
+}
+
+func (m *RaftCommandUnion) GetMultiIncrement() *MultiIncrementRequest {
+	if m != nil {
+		return m.MultiIncrement
+	}
+	return nil
+}
+
 func (m *RaftCommandUnion) GetBatch() *BatchRequest {
 	if m != nil {
 		return m.Batch
@@ -353,6 +385,17 @@ type RaftCommand struct {
 	RangeID      RangeID          `protobuf:"varint,1,opt,name=range_id,casttype=RangeID" json:"range_id"`
 	OriginNodeID RaftNodeID       `protobuf:"varint,2,opt,name=origin_node_id,casttype=RaftNodeID" json:"origin_node_id"`
 	Cmd          RaftCommandUnion `protobuf:"bytes,3,opt,name=cmd" json:"cmd"`
+	// The version of the command format. Replicas reject commands whose
+	// version is newer than RaftCommandVersion. Zero is the original
+	// format.
+	Version uint32 `protobuf:"varint,4,opt,name=version" json:"version"`
+	// Whether the response cache is bypassed when the command is applied,
+	// as decided by the proposer from the range's zone config. Replicas
+	// must not decide this locally, or they could diverge.
+	SkipResponseCache bool `protobuf:"varint,5,opt,name=skip_response_cache" json:"skip_response_cache"`
+	// Whether the command is applied with the safety checks of hardened
+	// mode, as decided by the proposer from its store's settings.
+	Hardened bool `protobuf:"varint,6,opt,name=hardened" json:"hardened"`
 }
 
 func (m *RaftCommand) Reset()         { *m = RaftCommand{} }
@@ -380,6 +423,27 @@ func (m *RaftCommand) GetCmd() RaftCommandUnion {
 	return RaftCommandUnion{}
 }
 
+func (m *RaftCommand) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *RaftCommand) GetSkipResponseCache() bool {
+	if m != nil {
+		return m.SkipResponseCache
+	}
+	return false
+}
+
+func (m *RaftCommand) GetHardened() bool {
+	if m != nil {
+		return m.Hardened
+	}
+	return false
+}
+
 // InternalTimeSeriesData is a collection of data samples for some
 // measurable value, where each sample is taken over a uniform time
 // interval.
@@ -542,6 +606,9 @@ type RaftSnapshotData struct {
 	// The latest RangeDescriptor
 	RangeDescriptor RangeDescriptor              `protobuf:"bytes,1,opt,name=range_descriptor" json:"range_descriptor"`
 	KV              []*RaftSnapshotData_KeyValue `protobuf:"bytes,2,rep" json:"KV,omitempty"`
+	// PrefixCompressed is true if the keys of KV are prefix compressed;
+	// see KeyValue.SharedPrefixLen.
+	PrefixCompressed bool `protobuf:"varint,3,opt,name=prefix_compressed" json:"prefix_compressed"`
 }
 
 func (m *RaftSnapshotData) Reset()         { *m = RaftSnapshotData{} }
@@ -562,9 +629,20 @@ func (m *RaftSnapshotData) GetKV() []*RaftSnapshotData_KeyValue {
 	return nil
 }
 
+func (m *RaftSnapshotData) GetPrefixCompressed() bool {
+	if m != nil {
+		return m.PrefixCompressed
+	}
+	return false
+}
+
 type RaftSnapshotData_KeyValue struct {
 	Key   []byte `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
 	Value []byte `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	// SharedPrefixLen is the length of the prefix which the key shares
+	// with the key of the preceding entry if the snapshot's keys are prefix
+	// compressed, in which case Key holds only the remaining suffix.
+	SharedPrefixLen uint32 `protobuf:"varint,3,opt,name=shared_prefix_len" json:"shared_prefix_len"`
 }
 
 func (m *RaftSnapshotData_KeyValue) Reset()         { *m = RaftSnapshotData_KeyValue{} }
@@ -585,6 +663,13 @@ func (m *RaftSnapshotData_KeyValue) GetValue() []byte {
 	return nil
 }
 
+func (m *RaftSnapshotData_KeyValue) GetSharedPrefixLen() uint32 {
+	if m != nil {
+		return m.SharedPrefixLen
+	}
+	return 0
+}
+
 func init() {
 	proto1.RegisterEnum("cockroach.proto.InternalValueType", InternalValueType_name, InternalValueType_value)
 }
@@ -743,17 +828,39 @@ func (m *ResponseCacheEntry) MarshalTo(data []byte) (int, error) {
 		}
 		i += n14
 	}
+	if m.CheckAndPut != nil {
+		data[i] = 0x7a
+		i++
+		i = encodeVarintInternal(data, i, uint64(m.CheckAndPut.Size()))
+		n15, err := m.CheckAndPut.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n15
+	}
+	if m.MultiIncrement != nil {
+		data[i] = 0x82
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintInternal(data, i, uint64(m.MultiIncrement.Size()))
+		n16, err := m.MultiIncrement.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n16
+	}
 	if m.Batch != nil {
 		data[i] = 0xf2
 		i++
 		data[i] = 0x1
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Batch.Size()))
-		n15, err := m.Batch.MarshalTo(data[i:])
+		n17, err := m.Batch.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n15
+		i += n17
 	}
 	return i, nil
 }
@@ -777,151 +884,151 @@ func (m *RaftCommandUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0xa
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Get.Size()))
-		n16, err := m.Get.MarshalTo(data[i:])
+		n18, err := m.Get.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n16
+		i += n18
 	}
 	if m.Put != nil {
 		data[i] = 0x12
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Put.Size()))
-		n17, err := m.Put.MarshalTo(data[i:])
+		n19, err := m.Put.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n17
+		i += n19
 	}
 	if m.ConditionalPut != nil {
 		data[i] = 0x1a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.ConditionalPut.Size()))
-		n18, err := m.ConditionalPut.MarshalTo(data[i:])
+		n20, err := m.ConditionalPut.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n18
+		i += n20
 	}
 	if m.Increment != nil {
 		data[i] = 0x22
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Increment.Size()))
-		n19, err := m.Increment.MarshalTo(data[i:])
+		n21, err := m.Increment.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n19
+		i += n21
 	}
 	if m.Delete != nil {
 		data[i] = 0x2a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Delete.Size()))
-		n20, err := m.Delete.MarshalTo(data[i:])
+		n22, err := m.Delete.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n20
+		i += n22
 	}
 	if m.DeleteRange != nil {
 		data[i] = 0x32
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.DeleteRange.Size()))
-		n21, err := m.DeleteRange.MarshalTo(data[i:])
+		n23, err := m.DeleteRange.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n21
+		i += n23
 	}
 	if m.Scan != nil {
 		data[i] = 0x3a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Scan.Size()))
-		n22, err := m.Scan.MarshalTo(data[i:])
+		n24, err := m.Scan.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n22
+		i += n24
 	}
 	if m.EndTransaction != nil {
 		data[i] = 0x42
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.EndTransaction.Size()))
-		n23, err := m.EndTransaction.MarshalTo(data[i:])
+		n25, err := m.EndTransaction.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n23
+		i += n25
 	}
 	if m.RangeLookup != nil {
 		data[i] = 0x4a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.RangeLookup.Size()))
-		n24, err := m.RangeLookup.MarshalTo(data[i:])
+		n26, err := m.RangeLookup.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n24
+		i += n26
 	}
 	if m.HeartbeatTxn != nil {
 		data[i] = 0x52
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.HeartbeatTxn.Size()))
-		n25, err := m.HeartbeatTxn.MarshalTo(data[i:])
+		n27, err := m.HeartbeatTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n25
+		i += n27
 	}
 	if m.GC != nil {
 		data[i] = 0x5a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.GC.Size()))
-		n26, err := m.GC.MarshalTo(data[i:])
+		n28, err := m.GC.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n26
+		i += n28
 	}
 	if m.PushTxn != nil {
 		data[i] = 0x62
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.PushTxn.Size()))
-		n27, err := m.PushTxn.MarshalTo(data[i:])
+		n29, err := m.PushTxn.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n27
+		i += n29
 	}
 	if m.ResolveIntent != nil {
 		data[i] = 0x6a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.ResolveIntent.Size()))
-		n28, err := m.ResolveIntent.MarshalTo(data[i:])
+		n30, err := m.ResolveIntent.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n28
+		i += n30
 	}
 	if m.ResolveIntentRange != nil {
 		data[i] = 0x72
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.ResolveIntentRange.Size()))
-		n29, err := m.ResolveIntentRange.MarshalTo(data[i:])
+		n31, err := m.ResolveIntentRange.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n29
+		i += n31
 	}
 	if m.MergeResponse != nil {
 		data[i] = 0x7a
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.MergeResponse.Size()))
-		n30, err := m.MergeResponse.MarshalTo(data[i:])
+		n32, err := m.MergeResponse.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n30
+		i += n32
 	}
 	if m.TruncateLog != nil {
 		data[i] = 0x82
@@ -929,11 +1036,11 @@ func (m *RaftCommandUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.TruncateLog.Size()))
-		n31, err := m.TruncateLog.MarshalTo(data[i:])
+		n33, err := m.TruncateLog.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n31
+		i += n33
 	}
 	if m.Lease != nil {
 		data[i] = 0x8a
@@ -941,11 +1048,11 @@ func (m *RaftCommandUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Lease.Size()))
-		n32, err := m.Lease.MarshalTo(data[i:])
+		n34, err := m.Lease.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n32
+		i += n34
 	}
 	if m.ReverseScan != nil {
 		data[i] = 0x92
@@ -953,11 +1060,35 @@ func (m *RaftCommandUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.ReverseScan.Size()))
-		n33, err := m.ReverseScan.MarshalTo(data[i:])
+		n35, err := m.ReverseScan.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n33
+		i += n35
+	}
+	if m.CheckAndPut != nil {
+		data[i] = 0x9a
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintInternal(data, i, uint64(m.CheckAndPut.Size()))
+		n36, err := m.CheckAndPut.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n36
+	}
+	if m.MultiIncrement != nil {
+		data[i] = 0xa2
+		i++
+		data[i] = 0x1
+		i++
+		i = encodeVarintInternal(data, i, uint64(m.MultiIncrement.Size()))
+		n37, err := m.MultiIncrement.MarshalTo(data[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n37
 	}
 	if m.Batch != nil {
 		data[i] = 0xf2
@@ -965,11 +1096,11 @@ func (m *RaftCommandUnion) MarshalTo(data []byte) (int, error) {
 		data[i] = 0x1
 		i++
 		i = encodeVarintInternal(data, i, uint64(m.Batch.Size()))
-		n34, err := m.Batch.MarshalTo(data[i:])
+		n38, err := m.Batch.MarshalTo(data[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n34
+		i += n38
 	}
 	return i, nil
 }
@@ -998,11 +1129,30 @@ func (m *RaftCommand) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x1a
 	i++
 	i = encodeVarintInternal(data, i, uint64(m.Cmd.Size()))
-	n35, err := m.Cmd.MarshalTo(data[i:])
+	n39, err := m.Cmd.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n35
+	i += n39
+	data[i] = 0x20
+	i++
+	i = encodeVarintInternal(data, i, uint64(m.Version))
+	data[i] = 0x28
+	i++
+	if m.SkipResponseCache {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
+	data[i] = 0x30
+	i++
+	if m.Hardened {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
 	return i, nil
 }
 
@@ -1121,11 +1271,11 @@ func (m *RaftSnapshotData) MarshalTo(data []byte) (int, error) {
 	data[i] = 0xa
 	i++
 	i = encodeVarintInternal(data, i, uint64(m.RangeDescriptor.Size()))
-	n36, err := m.RangeDescriptor.MarshalTo(data[i:])
+	n40, err := m.RangeDescriptor.MarshalTo(data[i:])
 	if err != nil {
 		return 0, err
 	}
-	i += n36
+	i += n40
 	if len(m.KV) > 0 {
 		for _, msg := range m.KV {
 			data[i] = 0x12
@@ -1138,6 +1288,14 @@ func (m *RaftSnapshotData) MarshalTo(data []byte) (int, error) {
 			i += n
 		}
 	}
+	data[i] = 0x18
+	i++
+	if m.PrefixCompressed {
+		data[i] = 1
+	} else {
+		data[i] = 0
+	}
+	i++
 	return i, nil
 }
 
@@ -1168,6 +1326,9 @@ func (m *RaftSnapshotData_KeyValue) MarshalTo(data []byte) (int, error) {
 		i = encodeVarintInternal(data, i, uint64(len(m.Value)))
 		i += copy(data[i:], m.Value)
 	}
+	data[i] = 0x18
+	i++
+	i = encodeVarintInternal(data, i, uint64(m.SharedPrefixLen))
 	return i, nil
 }
 
@@ -1257,6 +1418,14 @@ func (m *ResponseCacheEntry) Size() (n int) {
 		l = m.LeaderLease.Size()
 		n += 1 + l + sovInternal(uint64(l))
 	}
+	if m.CheckAndPut != nil {
+		l = m.CheckAndPut.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.MultiIncrement != nil {
+		l = m.MultiIncrement.Size()
+		n += 2 + l + sovInternal(uint64(l))
+	}
 	if m.Batch != nil {
 		l = m.Batch.Size()
 		n += 2 + l + sovInternal(uint64(l))
@@ -1339,6 +1508,14 @@ func (m *RaftCommandUnion) Size() (n int) {
 		l = m.ReverseScan.Size()
 		n += 2 + l + sovInternal(uint64(l))
 	}
+	if m.CheckAndPut != nil {
+		l = m.CheckAndPut.Size()
+		n += 2 + l + sovInternal(uint64(l))
+	}
+	if m.MultiIncrement != nil {
+		l = m.MultiIncrement.Size()
+		n += 2 + l + sovInternal(uint64(l))
+	}
 	if m.Batch != nil {
 		l = m.Batch.Size()
 		n += 2 + l + sovInternal(uint64(l))
@@ -1353,6 +1530,9 @@ func (m *RaftCommand) Size() (n int) {
 	n += 1 + sovInternal(uint64(m.OriginNodeID))
 	l = m.Cmd.Size()
 	n += 1 + l + sovInternal(uint64(l))
+	n += 1 + sovInternal(uint64(m.Version))
+	n += 2
+	n += 2
 	return n
 }
 
@@ -1404,6 +1584,7 @@ func (m *RaftSnapshotData) Size() (n int) {
 			n += 1 + l + sovInternal(uint64(l))
 		}
 	}
+	n += 2
 	return n
 }
 
@@ -1418,6 +1599,7 @@ func (m *RaftSnapshotData_KeyValue) Size() (n int) {
 		l = len(m.Value)
 		n += 1 + l + sovInternal(uint64(l))
 	}
+	n += 1 + sovInternal(uint64(m.SharedPrefixLen))
 	return n
 }
 
@@ -1477,6 +1659,12 @@ func (this *ResponseCacheEntry) GetValue() interface{} {
 	if this.LeaderLease != nil {
 		return this.LeaderLease
 	}
+	if this.CheckAndPut != nil {
+		return this.CheckAndPut
+	}
+	if this.MultiIncrement != nil {
+		return this.MultiIncrement
+	}
 	if this.Batch != nil {
 		return this.Batch
 	}
@@ -1513,6 +1701,10 @@ func (this *ResponseCacheEntry) SetValue(value interface{}) bool {
 		this.TruncateLog = vt
 	case *LeaderLeaseResponse:
 		this.LeaderLease = vt
+	case *CheckAndPutResponse:
+		this.CheckAndPut = vt
+	case *MultiIncrementResponse:
+		this.MultiIncrement = vt
 	case *BatchResponse:
 		this.Batch = vt
 	default:
@@ -1575,6 +1767,12 @@ func (this *RaftCommandUnion) GetValue() interface{} {
 	if this.ReverseScan != nil {
 		return this.ReverseScan
 	}
+	if this.CheckAndPut != nil {
+		return this.CheckAndPut
+	}
+	if this.MultiIncrement != nil {
+		return this.MultiIncrement
+	}
 	if this.Batch != nil {
 		return this.Batch
 	}
@@ -1619,6 +1817,10 @@ func (this *RaftCommandUnion) SetValue(value interface{}) bool {
 		this.Lease = vt
 	case *ReverseScanRequest:
 		this.ReverseScan = vt
+	case *CheckAndPutRequest:
+		this.CheckAndPut = vt
+	case *MultiIncrementRequest:
+		this.MultiIncrement = vt
 	case *BatchRequest:
 		this.Batch = vt
 	default:
@@ -2065,6 +2267,66 @@ func (m *ResponseCacheEntry) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CheckAndPut", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CheckAndPut == nil {
+				m.CheckAndPut = &CheckAndPutResponse{}
+			}
+			if err := m.CheckAndPut.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MultiIncrement", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MultiIncrement == nil {
+				m.MultiIncrement = &MultiIncrementResponse{}
+			}
+			if err := m.MultiIncrement.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		case 30:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Batch", wireType)
@@ -2680,6 +2942,66 @@ func (m *RaftCommandUnion) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CheckAndPut", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CheckAndPut == nil {
+				m.CheckAndPut = &CheckAndPutRequest{}
+			}
+			if err := m.CheckAndPut.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MultiIncrement", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.MultiIncrement == nil {
+				m.MultiIncrement = &MultiIncrementRequest{}
+			}
+			if err := m.MultiIncrement.Unmarshal(data[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		case 30:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Batch", wireType)
@@ -2814,6 +3136,56 @@ func (m *RaftCommand) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.Version |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkipResponseCache", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.SkipResponseCache = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hardened", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Hardened = bool(v != 0)
 		default:
 			var sizeOfWire int
 			for {
@@ -3229,6 +3601,23 @@ func (m *RaftSnapshotData) Unmarshal(data []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PrefixCompressed", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.PrefixCompressed = bool(v != 0)
 		default:
 			var sizeOfWire int
 			for {
@@ -3324,6 +3713,22 @@ func (m *RaftSnapshotData_KeyValue) Unmarshal(data []byte) error {
 			}
 			m.Value = append([]byte{}, data[iNdEx:postIndex]...)
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SharedPrefixLen", wireType)
+			}
+			m.SharedPrefixLen = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.SharedPrefixLen |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
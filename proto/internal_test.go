@@ -19,6 +19,7 @@ package proto
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	gogoproto "github.com/gogo/protobuf/proto"
@@ -82,3 +83,170 @@ func TestTimeSeriesToValue(t *testing.T) {
 		t.Errorf("did not receive expected error when extracting TimeSeries from regular Byte value.")
 	}
 }
+
+// TestTimeSeriesRate verifies the rate of change computed from
+// cumulative time series, including gaps and counter resets.
+func TestTimeSeriesRate(t *testing.T) {
+	const start = 1415398729000000000
+	samples := func(values ...float64) []*InternalTimeSeriesSample {
+		var samples []*InternalTimeSeriesSample
+		for i := 0; i < len(values); i += 2 {
+			samples = append(samples, &InternalTimeSeriesSample{
+				Offset: int32(values[i]),
+				Count:  1,
+				Sum:    values[i+1],
+			})
+		}
+		return samples
+	}
+	testCases := []struct {
+		duration int64
+		input    []*InternalTimeSeriesSample
+		expected []*InternalTimeSeriesSample
+	}{
+		// No rate for fewer than two samples.
+		{1e9, nil, nil},
+		{1e9, samples(0, 5), nil},
+		// A steadily increasing counter has a constant rate.
+		{1e9, samples(0, 10, 1, 20, 2, 30, 3, 40), samples(1, 10, 2, 10, 3, 10)},
+		{5e8, samples(0, 10, 1, 20, 2, 30), samples(1, 20, 2, 20)},
+		// The rate across a gap is spread over its duration.
+		{1e9, samples(0, 10, 1, 20, 4, 50), samples(1, 10, 4, 10)},
+		// After a reset, the rate is computed from the new value.
+		{1e9, samples(0, 100, 1, 110, 2, 5, 3, 15), samples(1, 10, 2, 5, 3, 10)},
+	}
+	for i, test := range testCases {
+		ts := &InternalTimeSeriesData{
+			StartTimestampNanos: start,
+			SampleDurationNanos: test.duration,
+			Samples:             test.input,
+		}
+		rate, err := ts.Rate()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if rate.StartTimestampNanos != start || rate.SampleDurationNanos != test.duration {
+			t.Errorf("%d: expected start %d and duration %d; got %d and %d", i, int64(start),
+				test.duration, rate.StartTimestampNanos, rate.SampleDurationNanos)
+		}
+		if !reflect.DeepEqual(rate.Samples, test.expected) {
+			t.Errorf("%d: expected rate samples %v; got %v", i, test.expected, rate.Samples)
+		}
+	}
+
+	// Invalid input is rejected.
+	for i, ts := range []*InternalTimeSeriesData{
+		{SampleDurationNanos: 0, Samples: samples(0, 1, 1, 2)},
+		{SampleDurationNanos: 1e9, Samples: samples(1, 1, 1, 2)},
+		{SampleDurationNanos: 1e9, Samples: samples(2, 1, 1, 2)},
+	} {
+		if _, err := ts.Rate(); err == nil {
+			t.Errorf("%d: expected error for invalid series", i)
+		}
+	}
+}
+
+func TestUnmarshalWithDetails(t *testing.T) {
+	ts := &InternalTimeSeriesData{
+		StartTimestampNanos: 1,
+		SampleDurationNanos: 2,
+		Samples: []*InternalTimeSeriesSample{
+			{Offset: 1, Count: 1},
+		},
+	}
+	// The encoding consists of the two timestamp fields (4 bytes),
+	// followed by the key and length of the sample (2 bytes) and the
+	// sample itself (Offset, Count and Sum).
+	data, err := gogoproto.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalWithDetails(data, &InternalTimeSeriesData{}); err != nil {
+		t.Fatal(err)
+	}
+
+	wrongWireType := append([]byte(nil), data...)
+	wrongWireType[8] |= 2 // Sample.Count is now length-delimited.
+
+	testCases := []struct {
+		data   []byte
+		typ    string
+		field  int32
+		offset int
+	}{
+		// The sample's length runs past the end of the data.
+		{data[:len(data)-1], "InternalTimeSeriesData", 3, 4},
+		{wrongWireType, "InternalTimeSeriesSample", 6, 8},
+	}
+	for i, test := range testCases {
+		err := UnmarshalWithDetails(test.data, &InternalTimeSeriesData{})
+		decErr, ok := err.(*DecodeError)
+		if !ok {
+			t.Errorf("%d: expected a decode error, got %v", i, err)
+			continue
+		}
+		if decErr.Type != test.typ || decErr.Field != test.field || decErr.Offset != test.offset {
+			t.Errorf("%d: expected %s field %d at offset %d; got %s", i, test.typ, test.field, test.offset, decErr)
+		}
+	}
+}
+
+// TestRaftSnapshotDataCompressKeys verifies that prefix compressing the
+// keys of a snapshot shrinks it and that the keys decompress exactly.
+func TestRaftSnapshotDataCompressKeys(t *testing.T) {
+	var kvs []*RaftSnapshotData_KeyValue
+	for _, key := range []string{
+		"", "/table/51/1/apple", "/table/51/1/apricot", "/table/51/1/apricot",
+		"/table/51/1/banana", "/table/51/2/apple", "/table/52", "zzz",
+	} {
+		kvs = append(kvs, &RaftSnapshotData_KeyValue{Key: []byte(key), Value: []byte("value")})
+	}
+	snap := &RaftSnapshotData{}
+	for _, kv := range kvs {
+		snap.KV = append(snap.KV, &RaftSnapshotData_KeyValue{Key: kv.Key, Value: kv.Value})
+	}
+	uncompressed, err := gogoproto.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap.CompressKeys()
+	compressed, err := gogoproto.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(uncompressed) {
+		t.Errorf("expected compressed snapshot of %d bytes to be smaller than %d bytes",
+			len(compressed), len(uncompressed))
+	}
+
+	decoded := &RaftSnapshotData{}
+	if err := gogoproto.Unmarshal(compressed, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.PrefixCompressed {
+		t.Fatal("expected snapshot to be marked as prefix compressed")
+	}
+	if err := decoded.DecompressKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.KV) != len(kvs) {
+		t.Fatalf("expected %d entries; got %d", len(kvs), len(decoded.KV))
+	}
+	for i, kv := range decoded.KV {
+		if !bytes.Equal(kv.Key, kvs[i].Key) || !bytes.Equal(kv.Value, kvs[i].Value) {
+			t.Errorf("%d: expected %q=%q; got %q=%q", i, kvs[i].Key, kvs[i].Value, kv.Key, kv.Value)
+		}
+	}
+
+	// A shared prefix longer than the preceding key is rejected.
+	corrupt := &RaftSnapshotData{
+		KV: []*RaftSnapshotData_KeyValue{
+			{Key: []byte("a")},
+			{Key: []byte("b"), SharedPrefixLen: 2},
+		},
+		PrefixCompressed: true,
+	}
+	if err := corrupt.DecompressKeys(); err == nil {
+		t.Error("expected error decompressing corrupt keys")
+	}
+}
@@ -169,6 +169,13 @@ func (r *Replica) String() string {
 	return fmt.Sprintf("%d:%d", r.NodeID, r.StoreID)
 }
 
+// ReplicaDescriptor combines a replica with the address of its node.
+// The address is empty if it is not known.
+type ReplicaDescriptor struct {
+	Replica
+	Address util.UnresolvedAddr
+}
+
 // FractionUsed computes the fraction of storage capacity that is in use.
 func (sc StoreCapacity) FractionUsed() float64 {
 	if sc.Capacity == 0 {
@@ -198,6 +198,9 @@ type StoreCapacity struct {
 	Capacity   int64 `protobuf:"varint,1,opt" json:"Capacity"`
 	Available  int64 `protobuf:"varint,2,opt" json:"Available"`
 	RangeCount int32 `protobuf:"varint,3,opt" json:"RangeCount"`
+	// LeaseCount is the number of ranges whose leader lease is held by the
+	// store.
+	LeaseCount int32 `protobuf:"varint,4,opt" json:"LeaseCount"`
 }
 
 func (m *StoreCapacity) Reset()         { *m = StoreCapacity{} }
@@ -225,6 +228,13 @@ func (m *StoreCapacity) GetRangeCount() int32 {
 	return 0
 }
 
+func (m *StoreCapacity) GetLeaseCount() int32 {
+	if m != nil {
+		return m.LeaseCount
+	}
+	return 0
+}
+
 // NodeDescriptor holds details on node physical/network topology.
 type NodeDescriptor struct {
 	NodeID  NodeID                        `protobuf:"varint,1,opt,name=node_id,casttype=NodeID" json:"node_id"`
@@ -504,6 +514,9 @@ func (m *StoreCapacity) MarshalTo(data []byte) (int, error) {
 	data[i] = 0x18
 	i++
 	i = encodeVarintMetadata(data, i, uint64(m.RangeCount))
+	data[i] = 0x20
+	i++
+	i = encodeVarintMetadata(data, i, uint64(m.LeaseCount))
 	return i, nil
 }
 
@@ -698,6 +711,7 @@ func (m *StoreCapacity) Size() (n int) {
 	n += 1 + sovMetadata(uint64(m.Capacity))
 	n += 1 + sovMetadata(uint64(m.Available))
 	n += 1 + sovMetadata(uint64(m.RangeCount))
+	n += 1 + sovMetadata(uint64(m.LeaseCount))
 	return n
 }
 
@@ -1356,6 +1370,22 @@ func (m *StoreCapacity) Unmarshal(data []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaseCount", wireType)
+			}
+			m.LeaseCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := data[iNdEx]
+				iNdEx++
+				m.LeaseCount |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			var sizeOfWire int
 			for {
@@ -95,6 +95,12 @@ const (
 	TruncateLog
 	// LeaderLease requests a leader lease for a replica.
 	LeaderLease
+	// CheckAndPut sets the value for a key if the latest version of the
+	// key is no newer than the timestamp specified in the request.
+	CheckAndPut
+	// MultiIncrement increments the values at several keys atomically,
+	// each by its own amount, as Increment does.
+	MultiIncrement
 	// Batch implements batch processing of commands. This is a
 	// superset of the Batch method.
 	Batch
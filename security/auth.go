@@ -82,6 +82,22 @@ func GetCertificateUser(tlsState *tls.ConnectionState) (string, error) {
 	return tlsState.PeerCertificates[0].Subject.CommonName, nil
 }
 
+// GetCertificateSQLUser returns the SQL user authenticated by the client
+// certificate in tlsState for a request made on behalf of requestedUser.
+// The node certificate is used by cluster-internal clients which may act
+// on behalf of any user, so it maps to the requested user; any other
+// certificate maps to the user named by its common name.
+func GetCertificateSQLUser(tlsState *tls.ConnectionState, requestedUser string) (string, error) {
+	certUser, err := GetCertificateUser(tlsState)
+	if err != nil {
+		return "", err
+	}
+	if certUser == NodeUser {
+		return requestedUser, nil
+	}
+	return certUser, nil
+}
+
 // AuthenticationHook builds an authentication hook based on the
 // security mode and client certificate.
 // Must be called at connection time and passed the TLS state.
@@ -83,6 +83,32 @@ func TestGetCertificateUser(t *testing.T) {
 	}
 }
 
+func TestGetCertificateSQLUser(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	if _, err := security.GetCertificateSQLUser(nil, "foo"); err == nil {
+		t.Error("unexpected success")
+	}
+
+	testCases := []struct {
+		commonName, requestedUser, expUser string
+	}{
+		{"foo", "foo", "foo"},
+		{security.RootUser, security.RootUser, security.RootUser},
+		// The node certificate acts on behalf of the requested user and
+		// is never promoted to root.
+		{security.NodeUser, "foo", "foo"},
+		{security.NodeUser, security.RootUser, security.RootUser},
+	}
+	for _, tc := range testCases {
+		if user, err := security.GetCertificateSQLUser(makeFakeTLSState([]string{tc.commonName}, []int{1}), tc.requestedUser); err != nil {
+			t.Error(err)
+		} else if user != tc.expUser {
+			t.Errorf("certificate for %s requesting %s: expected user %s, got %s",
+				tc.commonName, tc.requestedUser, tc.expUser, user)
+		}
+	}
+}
+
 func TestAuthenticationHook(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	// Proto that does not implement GetUser.
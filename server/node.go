@@ -227,7 +227,9 @@ func (n *Node) start(rpcServer *rpc.Server, engines []engine.Engine,
 		&proto.GetRequest{},
 		&proto.PutRequest{},
 		&proto.ConditionalPutRequest{},
+		&proto.CheckAndPutRequest{},
 		&proto.IncrementRequest{},
+		&proto.MultiIncrementRequest{},
 		&proto.DeleteRequest{},
 		&proto.DeleteRangeRequest{},
 		&proto.ScanRequest{},
@@ -265,6 +265,20 @@ func (s *Server) writeSummaries() error {
 	return nil
 }
 
+// DrainLeases transfers the leader leases held by the node's stores to
+// peer replicas, so that ranges remain available while the node is
+// shutting down. See storage.Store.DrainLeases.
+func (s *Server) DrainLeases() {
+	if err := s.node.lSender.VisitStores(func(store *storage.Store) error {
+		if n := store.DrainLeases(); n > 0 {
+			log.Infof("store %d transferred %d leader leases", store.StoreID(), n)
+		}
+		return nil
+	}); err != nil {
+		log.Warningf("failed to drain leader leases: %s", err)
+	}
+}
+
 // Stop stops the server.
 func (s *Server) Stop() {
 	s.stopper.Stop()
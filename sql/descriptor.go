@@ -56,7 +56,7 @@ type descriptorProto interface {
 
 // checkPrivilege verifies that p.user has `privilege` on `descriptor`.
 func (p *planner) checkPrivilege(descriptor descriptorProto, privilege privilege.Kind) error {
-	if descriptor.GetPrivileges().CheckPrivilege(p.user, privilege) {
+	if descriptor.GetPrivileges().CheckPrivilege(p.user, privilege, nil /* no roles */) {
 		return nil
 	}
 	return fmt.Errorf("user %s does not have %s privilege on %s %s",
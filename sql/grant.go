@@ -42,6 +42,9 @@ func (p *planner) Grant(n *parser.Grant) (planNode, error) {
 	}
 
 	for _, grantee := range n.Grantees {
+		if err := validateUserName(grantee); err != nil {
+			return nil, err
+		}
 		descriptor.GetPrivileges().Grant(grantee, n.Privileges)
 	}
 
@@ -101,6 +101,18 @@ func (s HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Privilege checks operate on the identity authenticated by the
+	// client certificate. In insecure mode there is nothing to verify the
+	// requested user against, so it is used as is.
+	if !s.context.Insecure {
+		user, err := security.GetCertificateSQLUser(r.TLS, args.User)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		args.User = user
+	}
+
 	reply, code, err := s.execute(args)
 	if err != nil {
 		http.Error(w, err.Error(), code)
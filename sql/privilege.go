@@ -18,9 +18,13 @@
 package sql
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"sort"
+	"strings"
 
+	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/security"
 	"github.com/cockroachdb/cockroach/sql/privilege"
 )
@@ -129,56 +133,171 @@ func NewDefaultPrivilegeDescriptor() *PrivilegeDescriptor {
 // them into ALL?
 func (p *PrivilegeDescriptor) Grant(user string, privList privilege.List) {
 	userPriv := p.findOrCreateUser(user)
-	if isPrivilegeSet(userPriv.Privileges, privilege.ALL) {
-		// User already has 'ALL' privilege: no-op.
-		return
-	}
+	userPriv.Privileges = grantBits(userPriv.Privileges, privList.ToBitField())
+}
 
+// GrantMany is equivalent to calling Grant for each of the given users,
+// but merges all of them into the sorted list of users in a single pass.
+func (p *PrivilegeDescriptor) GrantMany(users []string, privList privilege.List) {
 	bits := privList.ToBitField()
+	merged := make([]*UserPrivileges, 0, len(p.Users)+len(users))
+	i := 0
+	for _, user := range uniqueSortedUsers(users) {
+		for ; i < len(p.Users) && p.Users[i].User < user; i++ {
+			merged = append(merged, p.Users[i])
+		}
+		var userPriv *UserPrivileges
+		if i < len(p.Users) && p.Users[i].User == user {
+			userPriv = p.Users[i]
+			i++
+		} else {
+			userPriv = &UserPrivileges{User: user}
+		}
+		userPriv.Privileges = grantBits(userPriv.Privileges, bits)
+		merged = append(merged, userPriv)
+	}
+	p.Users = append(merged, p.Users[i:]...)
+}
+
+// grantBits returns the privilege bitfield resulting from granting the
+// privileges in 'bits' to a user holding 'privs'.
+func grantBits(privs, bits uint32) uint32 {
+	if isPrivilegeSet(privs, privilege.ALL) {
+		// User already has 'ALL' privilege: no-op.
+		return privs
+	}
 	if isPrivilegeSet(bits, privilege.ALL) {
 		// Granting 'ALL' privilege: overwrite.
 		// TODO(marc): the grammar does not allow it, but we should
 		// check if other privileges are being specified and error out.
-		userPriv.Privileges = privilege.ALL.Mask()
-		return
+		return privilege.ALL.Mask()
 	}
-	userPriv.Privileges |= bits
+	return privs | bits
 }
 
-// Revoke removes privileges from this descriptor for a given list of users.
-func (p *PrivilegeDescriptor) Revoke(user string, privList privilege.List) {
-	userPriv, ok := p.findUser(user)
-	if !ok || userPriv.Privileges == 0 {
-		// Removing privileges from a user without privileges is a no-op.
-		return
-	}
-
-	bits := privList.ToBitField()
+// revokeBits returns the privilege bitfield resulting from revoking the
+// privileges in 'bits' from a user holding 'privs'.
+func revokeBits(privs, bits uint32) uint32 {
 	if isPrivilegeSet(bits, privilege.ALL) {
-		// Revoking 'ALL' privilege: remove user.
+		// Revoking 'ALL' privilege: remove everything.
 		// TODO(marc): the grammar does not allow it, but we should
 		// check if other privileges are being specified and error out.
-		p.removeUser(user)
-		return
+		return 0
 	}
-
-	if isPrivilegeSet(userPriv.Privileges, privilege.ALL) {
+	if isPrivilegeSet(privs, privilege.ALL) {
 		// User has 'ALL' privilege. Remove it and set
 		// all other privileges one.
-		userPriv.Privileges = 0
+		privs = 0
 		for _, v := range privilege.ByValue {
 			if v != privilege.ALL {
-				userPriv.Privileges |= v.Mask()
+				privs |= v.Mask()
 			}
 		}
 	}
-
 	// One doesn't see "AND NOT" very often.
-	userPriv.Privileges &^= bits
+	return privs &^ bits
+}
+
+// uniqueSortedUsers returns a sorted copy of the given users with
+// duplicates removed.
+func uniqueSortedUsers(users []string) []string {
+	sorted := append([]string(nil), users...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, user := range sorted {
+		if i == 0 || user != sorted[i-1] {
+			unique = append(unique, user)
+		}
+	}
+	return unique
+}
 
+// Revoke removes privileges from this descriptor for a given list of users.
+// It returns an error without modifying the descriptor if the root user
+// holds ALL privileges and would lose them, which Validate would reject.
+func (p *PrivilegeDescriptor) Revoke(user string, privList privilege.List) error {
+	userPriv, ok := p.findUser(user)
+	if !ok || userPriv.Privileges == 0 {
+		// Removing privileges from a user without privileges is a no-op.
+		return nil
+	}
+
+	if err := checkRootRevoke(user, userPriv.Privileges, privList); err != nil {
+		return err
+	}
+	userPriv.Privileges = revokeBits(userPriv.Privileges, privList.ToBitField())
 	if userPriv.Privileges == 0 {
 		p.removeUser(user)
 	}
+	return nil
+}
+
+// RevokeMany is equivalent to calling Revoke for each of the given
+// users, but removes the privileges in a single pass over the list of
+// users. If the privileges can't be revoked from one of the users, an
+// error is returned and the descriptor is not modified.
+func (p *PrivilegeDescriptor) RevokeMany(users []string, privList privilege.List) error {
+	sorted := uniqueSortedUsers(users)
+	if idx := sort.SearchStrings(sorted, security.RootUser); idx < len(sorted) && sorted[idx] == security.RootUser {
+		if userPriv, ok := p.findUser(security.RootUser); ok {
+			if err := checkRootRevoke(security.RootUser, userPriv.Privileges, privList); err != nil {
+				return err
+			}
+		}
+	}
+
+	bits := privList.ToBitField()
+	kept := p.Users[:0]
+	i := 0
+	for _, userPriv := range p.Users {
+		for ; i < len(sorted) && sorted[i] < userPriv.User; i++ {
+		}
+		// As in Revoke, users without privileges are left alone.
+		if i < len(sorted) && sorted[i] == userPriv.User && userPriv.Privileges != 0 {
+			if userPriv.Privileges = revokeBits(userPriv.Privileges, bits); userPriv.Privileges == 0 {
+				continue
+			}
+		}
+		kept = append(kept, userPriv)
+	}
+	for j := len(kept); j < len(p.Users); j++ {
+		p.Users[j] = nil
+	}
+	p.Users = kept
+	return nil
+}
+
+// checkRootRevoke returns an error if revoking 'privList' from 'user',
+// who holds 'privs', would leave the root user without ALL privileges.
+func checkRootRevoke(user string, privs uint32, privList privilege.List) error {
+	if user == security.RootUser && privList.ToBitField() != 0 && isPrivilegeSet(privs, privilege.ALL) {
+		// Revoking any privilege from ALL leaves root without ALL.
+		return fmt.Errorf("cannot revoke %s privileges from user %s, which must retain ALL privileges",
+			privList, security.RootUser)
+	}
+	return nil
+}
+
+// RenameUser moves the privileges of user 'oldName' to 'newName'. If
+// 'newName' already holds privileges, the privileges of both users are
+// merged. The root user can't be renamed, nor can another user be
+// renamed to root.
+func (p *PrivilegeDescriptor) RenameUser(oldName, newName string) error {
+	if oldName == security.RootUser || newName == security.RootUser {
+		return fmt.Errorf("cannot rename user %s to %s: user %s can't be renamed",
+			oldName, newName, security.RootUser)
+	}
+	userPriv, ok := p.findUser(oldName)
+	if !ok {
+		return fmt.Errorf("user %s does not have privileges", oldName)
+	}
+	if oldName == newName {
+		return nil
+	}
+	p.removeUser(oldName)
+	newPriv := p.findOrCreateUser(newName)
+	newPriv.Privileges = grantBits(newPriv.Privileges, userPriv.Privileges)
+	return nil
 }
 
 // Validate is called when writing a database or table descriptor.
@@ -239,9 +358,81 @@ func (p *PrivilegeDescriptor) Show() ([]UserPrivilegeString, error) {
 	return ret, nil
 }
 
-// CheckPrivilege returns true if 'user' has 'privilege' on this descriptor.
-func (p *PrivilegeDescriptor) CheckPrivilege(user string, priv privilege.Kind) bool {
-	userPriv, ok := p.findUser(user)
+// ShowByPrivilege returns, for every privilege, the sorted list of
+// users holding it. Users with ALL privileges are listed under every
+// privilege. Privileges held by nobody map to an empty list.
+func (p *PrivilegeDescriptor) ShowByPrivilege() map[privilege.Kind][]string {
+	ret := make(map[privilege.Kind][]string, len(privilege.ByValue))
+	for _, priv := range privilege.ByValue {
+		ret[priv] = []string{}
+	}
+	for _, userPriv := range p.Users {
+		for _, priv := range privilege.ByValue {
+			if isPrivilegeSet(userPriv.Privileges, privilege.ALL) || isPrivilegeSet(userPriv.Privileges, priv) {
+				ret[priv] = append(ret[priv], userPriv.User)
+			}
+		}
+	}
+	for _, users := range ret {
+		sort.Strings(users)
+	}
+	return ret
+}
+
+// rolePrefix distinguishes roles from users in the list of grantees.
+// User names starting with it are rejected, see validateUserName.
+const rolePrefix = "role:"
+
+// RoleName returns the grantee name under which privileges are granted
+// to and revoked from the given role.
+func RoleName(role string) string {
+	return rolePrefix + role
+}
+
+// IsRoleName returns true if the grantee name denotes a role rather
+// than a user.
+func IsRoleName(grantee string) bool {
+	return strings.HasPrefix(grantee, rolePrefix)
+}
+
+// validateUserName returns an error if the given user name starts with
+// the role prefix, as the user would otherwise be taken for a role.
+func validateUserName(user string) error {
+	if IsRoleName(user) {
+		return fmt.Errorf("user name %q must not start with %q", user, rolePrefix)
+	}
+	return nil
+}
+
+// A RoleResolver returns the roles the given user is a member of.
+type RoleResolver func(user string) []string
+
+// CheckPrivilege returns true if 'user' has 'privilege' on this descriptor,
+// either directly or through one of the roles returned by 'roles', which
+// may be nil if no roles are in use. A user name starting with the role
+// prefix has no privileges.
+func (p *PrivilegeDescriptor) CheckPrivilege(user string, priv privilege.Kind, roles RoleResolver) bool {
+	if validateUserName(user) != nil {
+		return false
+	}
+	if p.checkGrantee(user, priv) {
+		return true
+	}
+	if roles == nil {
+		return false
+	}
+	for _, role := range roles(user) {
+		if p.checkGrantee(RoleName(role), priv) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGrantee returns true if 'grantee' was granted 'privilege' on this
+// descriptor.
+func (p *PrivilegeDescriptor) checkGrantee(grantee string, priv privilege.Kind) bool {
+	userPriv, ok := p.findUser(grantee)
 	if !ok {
 		return false
 	}
@@ -251,3 +442,107 @@ func (p *PrivilegeDescriptor) CheckPrivilege(user string, priv privilege.Kind) b
 	}
 	return isPrivilegeSet(userPriv.Privileges, priv)
 }
+
+// RequiredPrivilege returns the privilege needed to execute the request
+// against data covered by a privilege descriptor. Reads need SELECT,
+// writes the privilege matching the kind of modification they make.
+// Requests which aren't confined to one kind of access, such as
+// transaction bookkeeping and admin commands, need ALL privileges. A
+// write which may either insert or update a key, as reported by
+// isUpsert, needs INSERT as returned here and UPDATE as well.
+func RequiredPrivilege(r proto.Request) privilege.Kind {
+	if proto.IsReadOnly(r) {
+		return privilege.SELECT
+	}
+	if proto.IsWrite(r) {
+		switch r.Method() {
+		case proto.ConditionalPut:
+			// A conditional put which expects no value can only insert, one
+			// which expects a value can only update.
+			if r.(*proto.ConditionalPutRequest).ExpValue == nil {
+				return privilege.INSERT
+			}
+			return privilege.UPDATE
+		case proto.Put, proto.CheckAndPut:
+			return privilege.INSERT
+		case proto.Increment, proto.MultiIncrement, proto.Merge:
+			return privilege.UPDATE
+		case proto.Delete, proto.DeleteRange:
+			return privilege.DELETE
+		}
+	}
+	return privilege.ALL
+}
+
+// isUpsert returns whether the request is a write which may either
+// insert or update a key.
+func isUpsert(r proto.Request) bool {
+	switch r.Method() {
+	case proto.Put, proto.CheckAndPut:
+		return true
+	}
+	return false
+}
+
+// A PermissionDeniedError indicates that a user lacks the privilege
+// required to execute a request.
+type PermissionDeniedError struct {
+	User      string
+	Privilege privilege.Kind
+	Method    proto.Method
+}
+
+// Error implements the error interface.
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf("user %s does not have %s privilege required for %s", e.User, e.Privilege, e.Method)
+}
+
+// CheckRequestPrivilege returns a PermissionDeniedError unless 'user'
+// holds the privilege required to execute the request on this
+// descriptor, as determined by RequiredPrivilege, and UPDATE as well if
+// the request is an upsert. Roles are resolved as in CheckPrivilege.
+func (p *PrivilegeDescriptor) CheckRequestPrivilege(user string, r proto.Request, roles RoleResolver) error {
+	privs := privilege.List{RequiredPrivilege(r)}
+	if isUpsert(r) {
+		privs = append(privs, privilege.UPDATE)
+	}
+	for _, priv := range privs {
+		if !p.CheckPrivilege(user, priv, roles) {
+			return &PermissionDeniedError{User: user, Privilege: priv, Method: r.Method()}
+		}
+	}
+	return nil
+}
+
+// privilegeFingerprintVersion is mixed into every fingerprint so that a
+// change to the fingerprint encoding invalidates cached fingerprints.
+const privilegeFingerprintVersion = 1
+
+// Fingerprint returns a sha256 hash of the effective privileges in this
+// descriptor. It is computed over the users sorted by name and their
+// privilege bitfields, with users holding no privileges skipped and a
+// bitfield containing ALL reduced to ALL alone, so that descriptors
+// granting the same effective privileges have the same fingerprint.
+func (p *PrivilegeDescriptor) Fingerprint() []byte {
+	users := make(userPrivilegeList, 0, len(p.Users))
+	for _, u := range p.Users {
+		if u.Privileges != 0 {
+			users = append(users, u)
+		}
+	}
+	sort.Sort(users)
+
+	h := sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	h.Write(buf[:binary.PutUvarint(buf[:], privilegeFingerprintVersion)])
+	for _, u := range users {
+		bits := u.Privileges
+		if isPrivilegeSet(bits, privilege.ALL) {
+			bits = privilege.ALL.Mask()
+		}
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(u.User)))])
+		h.Write([]byte(u.User))
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(bits))])
+	}
+	return h.Sum(nil)
+}
@@ -18,8 +18,15 @@
 package sql_test
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/security"
 	"github.com/cockroachdb/cockroach/sql"
 	"github.com/cockroachdb/cockroach/sql/privilege"
@@ -59,10 +66,6 @@ func TestPrivilege(t *testing.T) {
 		{"foo", nil, privilege.List{privilege.ALL},
 			[]sql.UserPrivilegeString{{security.RootUser, "ALL"}},
 		},
-		// Validate checks that root still has ALL privileges, but we do not call it here.
-		{security.RootUser, nil, privilege.List{privilege.ALL},
-			[]sql.UserPrivilegeString{},
-		},
 	}
 
 	for tcNum, tc := range testCases {
@@ -71,7 +74,9 @@ func TestPrivilege(t *testing.T) {
 				descriptor.Grant(tc.grantee, tc.grant)
 			}
 			if tc.revoke != nil {
-				descriptor.Revoke(tc.grantee, tc.revoke)
+				if err := descriptor.Revoke(tc.grantee, tc.revoke); err != nil {
+					t.Fatal(err)
+				}
 			}
 		}
 		show, err := descriptor.Show()
@@ -91,6 +96,111 @@ func TestPrivilege(t *testing.T) {
 	}
 }
 
+// TestPrivilegeShowByPrivilege verifies that users are grouped by the
+// privileges they hold, with ALL expanded to every privilege.
+func TestPrivilegeShowByPrivilege(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	descriptor := sql.NewDefaultPrivilegeDescriptor()
+	descriptor.Grant("foo", privilege.List{privilege.SELECT, privilege.INSERT})
+	descriptor.Grant("bar", privilege.List{privilege.SELECT})
+	descriptor.Grant("baz", privilege.List{privilege.ALL})
+
+	expected := map[privilege.Kind][]string{
+		privilege.ALL:    {"baz", security.RootUser},
+		privilege.CREATE: {"baz", security.RootUser},
+		privilege.DROP:   {"baz", security.RootUser},
+		privilege.GRANT:  {"baz", security.RootUser},
+		privilege.SELECT: {"bar", "baz", "foo", security.RootUser},
+		privilege.INSERT: {"baz", "foo", security.RootUser},
+		privilege.DELETE: {"baz", security.RootUser},
+		privilege.UPDATE: {"baz", security.RootUser},
+	}
+	if show := descriptor.ShowByPrivilege(); !reflect.DeepEqual(show, expected) {
+		t.Errorf("expected %v; got %v", expected, show)
+	}
+
+	// Privileges nobody holds map to empty lists.
+	descriptor = sql.NewPrivilegeDescriptor("foo", privilege.List{privilege.SELECT})
+	show := descriptor.ShowByPrivilege()
+	if len(show) != len(privilege.ByValue) {
+		t.Fatalf("expected an entry for each of the %d privileges; got %v", len(privilege.ByValue), show)
+	}
+	for priv, users := range show {
+		if priv == privilege.SELECT {
+			if !reflect.DeepEqual(users, []string{"foo"}) {
+				t.Errorf("expected [foo] to hold %s; got %v", priv, users)
+			}
+		} else if users == nil || len(users) != 0 {
+			t.Errorf("expected empty list for %s; got %#v", priv, users)
+		}
+	}
+}
+
+// TestPrivilegeGrantRevokeMany verifies that granting to and revoking
+// from many users at once has the same result as doing so one user at a
+// time.
+func TestPrivilegeGrantRevokeMany(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	var users []string
+	for i := 0; i < 200; i++ {
+		// Out of order, with duplicates.
+		users = append(users, fmt.Sprintf("user%03d", (i*37)%150))
+	}
+
+	single := sql.NewDefaultPrivilegeDescriptor()
+	bulk := sql.NewDefaultPrivilegeDescriptor()
+	for _, d := range []*sql.PrivilegeDescriptor{single, bulk} {
+		d.Grant("user010", privilege.List{privilege.ALL})
+		d.Grant("user020", privilege.List{privilege.DROP})
+		d.Grant("zed", privilege.List{privilege.INSERT})
+	}
+
+	for _, privList := range []privilege.List{
+		{privilege.SELECT},
+		{privilege.INSERT, privilege.UPDATE},
+		{privilege.ALL},
+	} {
+		for _, user := range users {
+			single.Grant(user, privList)
+		}
+		bulk.GrantMany(users, privList)
+		if !reflect.DeepEqual(single, bulk) {
+			t.Fatalf("grant %s: expected %+v; got %+v", privList, single, bulk)
+		}
+	}
+
+	for _, privList := range []privilege.List{
+		{privilege.UPDATE},
+		{privilege.ALL},
+	} {
+		for _, user := range users[:100] {
+			if err := single.Revoke(user, privList); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := bulk.RevokeMany(users[:100], privList); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(single, bulk) {
+			t.Fatalf("revoke %s: expected %+v; got %+v", privList, single, bulk)
+		}
+	}
+
+	// Revoking from root fails without changing anything.
+	before, err := bulk.Show()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bulk.RevokeMany([]string{"zed", security.RootUser}, privilege.List{privilege.INSERT}); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if after, err := bulk.Show(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(before, after) {
+		t.Errorf("expected descriptor to be unchanged; got %+v", after)
+	}
+}
+
 // TestPrivilegeValidate exercises validation for non-system descriptors.
 func TestPrivilegeValidate(t *testing.T) {
 	defer leaktest.AfterTest(t)
@@ -107,22 +217,105 @@ func TestPrivilegeValidate(t *testing.T) {
 	if err := descriptor.Validate(id); err != nil {
 		t.Fatal(err)
 	}
-	descriptor.Revoke(security.RootUser, privilege.List{privilege.SELECT})
+	// Root must keep ALL privileges.
+	descriptor = sql.NewPrivilegeDescriptor(security.RootUser, privilege.List{privilege.SELECT})
 	if err := descriptor.Validate(id); err == nil {
 		t.Fatal("unexpected success")
 	}
 	// TODO(marc): validate fails here because we do not aggregate
 	// privileges into ALL when all are set.
-	descriptor.Grant(security.RootUser, privilege.List{privilege.SELECT})
+	descriptor.Grant(security.RootUser, privilege.List{privilege.CREATE, privilege.DROP, privilege.GRANT,
+		privilege.INSERT, privilege.DELETE, privilege.UPDATE})
 	if err := descriptor.Validate(id); err == nil {
 		t.Fatal("unexpected success")
 	}
-	descriptor.Revoke(security.RootUser, privilege.List{privilege.ALL})
+	if err := descriptor.Revoke(security.RootUser, privilege.List{privilege.ALL}); err != nil {
+		t.Fatal(err)
+	}
 	if err := descriptor.Validate(id); err == nil {
 		t.Fatal("unexpected success")
 	}
 }
 
+// TestPrivilegeRevokeRoot verifies that revoking privileges which would
+// leave the root user without ALL privileges fails and leaves the
+// descriptor unchanged.
+func TestPrivilegeRevokeRoot(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	descriptor := sql.NewDefaultPrivilegeDescriptor()
+	for _, privList := range []privilege.List{
+		{privilege.ALL},
+		{privilege.SELECT},
+		{privilege.INSERT, privilege.DROP},
+	} {
+		err := descriptor.Revoke(security.RootUser, privList)
+		expected := "cannot revoke " + privList.String() +
+			" privileges from user root, which must retain ALL privileges"
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q; got %v", privList, expected, err)
+		}
+		if !descriptor.CheckPrivilege(security.RootUser, privilege.ALL, nil) {
+			t.Fatalf("%s: expected root to retain ALL privileges", privList)
+		}
+	}
+	// Revoking nothing is fine.
+	if err := descriptor.Revoke(security.RootUser, privilege.List{}); err != nil {
+		t.Error(err)
+	}
+	// Other users are unaffected.
+	descriptor.Grant("foo", privilege.List{privilege.ALL})
+	if err := descriptor.Revoke("foo", privilege.List{privilege.SELECT}); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestPrivilegeRenameUser verifies that renaming a user moves its
+// privileges, merging them with those of an existing user, and that
+// the root user can't be renamed.
+func TestPrivilegeRenameUser(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	descriptor := sql.NewDefaultPrivilegeDescriptor()
+	descriptor.Grant("bar", privilege.List{privilege.SELECT})
+	descriptor.Grant("foo", privilege.List{privilege.INSERT})
+	descriptor.Grant("qux", privilege.List{privilege.DROP})
+
+	// Simple rename, moving the user to the end of the list.
+	if err := descriptor.RenameUser("bar", "zed"); err != nil {
+		t.Fatal(err)
+	}
+	// Rename onto an existing user.
+	if err := descriptor.RenameUser("foo", "qux"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []sql.UserPrivilegeString{
+		{"qux", "DROP,INSERT"},
+		{security.RootUser, "ALL"},
+		{"zed", "SELECT"},
+	}
+	if actual, err := descriptor.Show(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %+v; got %+v", expected, actual)
+	}
+
+	for i, tc := range []struct {
+		oldName, newName string
+	}{
+		{security.RootUser, "foo"},
+		{"zed", security.RootUser},
+		{"foo", "bar"},
+	} {
+		if err := descriptor.RenameUser(tc.oldName, tc.newName); err == nil {
+			t.Errorf("%d: renaming %s to %s unexpectedly succeeded", i, tc.oldName, tc.newName)
+		}
+	}
+	if actual, err := descriptor.Show(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected descriptor to be unchanged; got %+v", actual)
+	}
+}
+
 // TestSystemPrivilegeValidate exercises validation for system descriptors.
 // We use 1 (the system database ID).
 func TestSystemPrivilegeValidate(t *testing.T) {
@@ -146,6 +339,11 @@ func TestSystemPrivilegeValidate(t *testing.T) {
 	if err := descriptor.Validate(id); err != nil {
 		t.Fatal(err)
 	}
+	revoke := func(user string, p privilege.Kind) {
+		if err := descriptor.Revoke(user, privilege.List{p}); err != nil {
+			t.Fatal(err)
+		}
+	}
 	for _, p := range privilege.ByValue {
 		if hasPrivilege(allowedPrivileges, p) {
 			// Grant allowed privileges. Either they are already
@@ -161,7 +359,7 @@ func TestSystemPrivilegeValidate(t *testing.T) {
 
 			// Remove allowed privileges. This fails for root,
 			// but passes for other users.
-			descriptor.Revoke(security.RootUser, privilege.List{p})
+			revoke(security.RootUser, p)
 			if err := descriptor.Validate(id); err == nil {
 				t.Fatal("unexpected success")
 			}
@@ -172,14 +370,26 @@ func TestSystemPrivilegeValidate(t *testing.T) {
 			if err := descriptor.Validate(id); err == nil {
 				t.Fatal("unexpected success")
 			}
-			descriptor.Revoke(security.RootUser, privilege.List{p})
+			if p == privilege.ALL {
+				// Root can't be stripped of ALL privileges, so reset them.
+				if err := descriptor.Revoke(security.RootUser, privilege.List{p}); err == nil {
+					t.Fatal("unexpected success")
+				}
+				for _, u := range descriptor.Users {
+					if u.User == security.RootUser {
+						u.Privileges = allowedPrivileges.ToBitField()
+					}
+				}
+			} else {
+				revoke(security.RootUser, p)
+			}
 			descriptor.Grant(security.RootUser, allowedPrivileges)
 
 			descriptor.Grant("foo", privilege.List{p})
 			if err := descriptor.Validate(id); err == nil {
 				t.Fatal("unexpected success")
 			}
-			descriptor.Revoke("foo", privilege.List{p})
+			revoke("foo", p)
 			descriptor.Grant("foo", allowedPrivileges)
 
 			// Revoking non-allowed privileges always succeeds,
@@ -187,13 +397,13 @@ func TestSystemPrivilegeValidate(t *testing.T) {
 			if p == privilege.ALL {
 				// We need to reset privileges as Revoke(ALL) will clear
 				// all bits.
-				descriptor.Revoke(security.RootUser, privilege.List{p})
+				revoke(security.RootUser, p)
 				if err := descriptor.Validate(id); err == nil {
 					t.Fatal("unexpected success")
 				}
 				descriptor.Grant(security.RootUser, allowedPrivileges)
 			} else {
-				descriptor.Revoke(security.RootUser, privilege.List{p})
+				revoke(security.RootUser, p)
 				if err := descriptor.Validate(id); err != nil {
 					t.Fatal(err)
 				}
@@ -201,9 +411,207 @@ func TestSystemPrivilegeValidate(t *testing.T) {
 		}
 
 		// We can always revoke anything from non-root users.
-		descriptor.Revoke("foo", privilege.List{p})
+		revoke("foo", p)
 		if err := descriptor.Validate(id); err != nil {
 			t.Fatal(err)
 		}
 	}
 }
+
+// TestCertificateUserPrivilege verifies that privilege checks for the
+// user resolved from a client certificate operate on that identity.
+func TestCertificateUserPrivilege(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	descriptor := sql.NewDefaultPrivilegeDescriptor()
+	descriptor.Grant("foo", privilege.List{privilege.SELECT})
+
+	testCases := []struct {
+		commonName    string
+		requestedUser string
+		priv          privilege.Kind
+		exp           bool
+	}{
+		{"foo", "foo", privilege.SELECT, true},
+		{"foo", "foo", privilege.DROP, false},
+		{"bar", "bar", privilege.SELECT, false},
+		{security.RootUser, security.RootUser, privilege.DROP, true},
+		// The node certificate has the privileges of the requested user.
+		{security.NodeUser, "foo", privilege.SELECT, true},
+		{security.NodeUser, "foo", privilege.DROP, false},
+		{security.NodeUser, security.RootUser, privilege.DROP, true},
+	}
+	for _, tc := range testCases {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: tc.commonName}}
+		tlsState := &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+		user, err := security.GetCertificateSQLUser(tlsState, tc.requestedUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok := descriptor.CheckPrivilege(user, tc.priv, nil); ok != tc.exp {
+			t.Errorf("certificate for %s (user %s): expected %s privilege %t, got %t",
+				tc.commonName, user, tc.priv, tc.exp, ok)
+		}
+	}
+}
+
+// TestPrivilegeRoles verifies that users inherit the privileges granted
+// to the roles they are members of.
+func TestPrivilegeRoles(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	descriptor := sql.NewDefaultPrivilegeDescriptor()
+	descriptor.Grant(sql.RoleName("readers"), privilege.List{privilege.SELECT})
+	descriptor.Grant(sql.RoleName("admins"), privilege.List{privilege.ALL})
+	descriptor.Grant("bar", privilege.List{privilege.INSERT})
+	if err := descriptor.Validate(sql.MaxReservedDescID + 1); err != nil {
+		t.Fatal(err)
+	}
+
+	members := map[string][]string{
+		"foo": {"readers"},
+		"bar": {"readers"},
+		"baz": {"admins"},
+	}
+	roles := func(user string) []string { return members[user] }
+
+	testCases := []struct {
+		user string
+		priv privilege.Kind
+		exp  bool
+	}{
+		{"foo", privilege.SELECT, true},
+		{"foo", privilege.INSERT, false},
+		{"bar", privilege.SELECT, true},
+		{"bar", privilege.INSERT, true},
+		{"baz", privilege.DROP, true},
+		{"qux", privilege.SELECT, false},
+	}
+	for _, tc := range testCases {
+		if ok := descriptor.CheckPrivilege(tc.user, tc.priv, roles); ok != tc.exp {
+			t.Errorf("%s: expected %s privilege %t, got %t", tc.user, tc.priv, tc.exp, ok)
+		}
+	}
+
+	// Without a resolver, only direct grants count.
+	if descriptor.CheckPrivilege("foo", privilege.SELECT, nil) {
+		t.Error("expected no SELECT privilege for foo without roles")
+	}
+	if !descriptor.CheckPrivilege("bar", privilege.INSERT, nil) {
+		t.Error("expected INSERT privilege for bar without roles")
+	}
+
+	// Revoking from the role revokes from its members.
+	if err := descriptor.Revoke(sql.RoleName("readers"), privilege.List{privilege.SELECT}); err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.CheckPrivilege("foo", privilege.SELECT, roles) {
+		t.Error("expected SELECT privilege to be revoked from foo")
+	}
+	if !sql.IsRoleName(sql.RoleName("readers")) || sql.IsRoleName("readers") {
+		t.Error("expected role names to be distinguished from user names")
+	}
+
+	// A user can't pose as a role by taking its grantee name.
+	if descriptor.CheckPrivilege(sql.RoleName("admins"), privilege.DROP, roles) {
+		t.Error("expected no privileges for a user named like a role")
+	}
+}
+
+// TestCheckRequestPrivilege verifies that requests are checked against
+// the privilege matching their method, and upserts against UPDATE too.
+func TestCheckRequestPrivilege(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	descriptor := sql.NewDefaultPrivilegeDescriptor()
+	descriptor.Grant("foo", privilege.List{privilege.UPDATE})
+
+	testCases := []struct {
+		req    proto.Request
+		priv   privilege.Kind
+		denied privilege.Kind // the privilege foo lacks, if any
+	}{
+		{&proto.GetRequest{}, privilege.SELECT, privilege.SELECT},
+		{&proto.ScanRequest{}, privilege.SELECT, privilege.SELECT},
+		{&proto.PutRequest{}, privilege.INSERT, privilege.INSERT},
+		{&proto.ConditionalPutRequest{}, privilege.INSERT, privilege.INSERT},
+		{&proto.ConditionalPutRequest{ExpValue: &proto.Value{}}, privilege.UPDATE, 0},
+		{&proto.CheckAndPutRequest{}, privilege.INSERT, privilege.INSERT},
+		{&proto.IncrementRequest{}, privilege.UPDATE, 0},
+		{&proto.DeleteRangeRequest{}, privilege.DELETE, privilege.DELETE},
+		{&proto.EndTransactionRequest{}, privilege.ALL, privilege.ALL},
+	}
+	for _, tc := range testCases {
+		if priv := sql.RequiredPrivilege(tc.req); priv != tc.priv {
+			t.Errorf("%s: expected %s privilege to be required; got %s", tc.req.Method(), tc.priv, priv)
+		}
+		// Root holds ALL privileges.
+		if err := descriptor.CheckRequestPrivilege(security.RootUser, tc.req, nil); err != nil {
+			t.Errorf("%s: unexpected error for root: %s", tc.req.Method(), err)
+		}
+		err := descriptor.CheckRequestPrivilege("foo", tc.req, nil)
+		if tc.denied == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", tc.req.Method(), err)
+			}
+			continue
+		}
+		if pErr, ok := err.(*sql.PermissionDeniedError); !ok {
+			t.Errorf("%s: expected PermissionDeniedError; got %v", tc.req.Method(), err)
+		} else if pErr.User != "foo" || pErr.Privilege != tc.denied || pErr.Method != tc.req.Method() {
+			t.Errorf("%s: unexpected error contents: %+v", tc.req.Method(), pErr)
+		}
+	}
+
+	// An upsert needs UPDATE as well as INSERT.
+	descriptor.Grant("bar", privilege.List{privilege.INSERT})
+	err := descriptor.CheckRequestPrivilege("bar", &proto.PutRequest{}, nil)
+	if pErr, ok := err.(*sql.PermissionDeniedError); !ok || pErr.Privilege != privilege.UPDATE {
+		t.Errorf("expected put to be denied for lack of UPDATE; got %v", err)
+	}
+}
+
+// TestPrivilegeFingerprint verifies that descriptors with the same
+// effective privileges have the same fingerprint, regardless of how they
+// were arrived at, and that a real change alters the fingerprint.
+func TestPrivilegeFingerprint(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	base := sql.NewDefaultPrivilegeDescriptor()
+	base.Grant("foo", privilege.List{privilege.SELECT, privilege.INSERT})
+	fp := base.Fingerprint()
+
+	// Same privileges granted in a different order and in several steps.
+	equiv := sql.NewDefaultPrivilegeDescriptor()
+	equiv.Grant("foo", privilege.List{privilege.INSERT})
+	equiv.Grant("foo", privilege.List{privilege.SELECT})
+	// Granting to and then revoking from another user leaves no trace.
+	equiv.Grant("bar", privilege.List{privilege.DROP})
+	if err := equiv.Revoke("bar", privilege.List{privilege.DROP}); err != nil {
+		t.Fatal(err)
+	}
+	// Granting a privilege root already has through ALL is a no-op.
+	equiv.Grant(security.RootUser, privilege.List{privilege.DROP})
+	if !bytes.Equal(fp, equiv.Fingerprint()) {
+		t.Errorf("expected equivalent descriptors to have the same fingerprint")
+	}
+
+	// Users appended out of order and with ALL plus redundant bits.
+	unsorted := &sql.PrivilegeDescriptor{
+		Users: []*sql.UserPrivileges{
+			{User: "foo", Privileges: privilege.List{privilege.INSERT, privilege.SELECT}.ToBitField()},
+			{User: "zed"},
+			{User: security.RootUser, Privileges: privilege.List{privilege.ALL, privilege.CREATE}.ToBitField()},
+		},
+	}
+	if !bytes.Equal(fp, unsorted.Fingerprint()) {
+		t.Errorf("expected fingerprint to be independent of user order and redundant bits")
+	}
+
+	if !bytes.Equal(fp, base.Fingerprint()) {
+		t.Errorf("expected fingerprint to be stable across calls")
+	}
+	base.Grant("foo", privilege.List{privilege.DELETE})
+	if bytes.Equal(fp, base.Fingerprint()) {
+		t.Errorf("expected fingerprint to change after a grant")
+	}
+}
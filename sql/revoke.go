@@ -42,7 +42,12 @@ func (p *planner) Revoke(n *parser.Revoke) (planNode, error) {
 	}
 
 	for _, grantee := range n.Grantees {
-		descriptor.GetPrivileges().Revoke(grantee, n.Privileges)
+		if err := validateUserName(grantee); err != nil {
+			return nil, err
+		}
+		if err := descriptor.GetPrivileges().Revoke(grantee, n.Privileges); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := descriptor.Validate(); err != nil {
@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/cockroachdb/cockroach/storage"
 	"github.com/cockroachdb/cockroach/storage/engine"
 	"github.com/cockroachdb/cockroach/testutils"
+	"github.com/cockroachdb/cockroach/util/hlc"
 	"github.com/cockroachdb/cockroach/util/leaktest"
 	"github.com/cockroachdb/cockroach/util/log"
 )
@@ -204,6 +206,138 @@ func TestStoreRangeMergeLastRange(t *testing.T) {
 	}
 }
 
+// TestStoreRangeMergeTooLarge verifies that a merge is refused if the
+// combined size of the ranges exceeds the maximum range size, and
+// proceeds otherwise.
+func TestStoreRangeMergeTooLarge(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, stopper := createTestStore(t)
+	defer stopper.Stop()
+
+	aDesc, bDesc, err := createSplitRanges(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pArgs := putArgs([]byte("ccc"), make([]byte, 1<<10), bDesc.RangeID, store.StoreID())
+	if _, err := store.ExecuteCmd(context.Background(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	rangeA := store.LookupReplica([]byte("a"), nil)
+
+	// The merged range would be too large: the value alone exceeds the
+	// maximum size.
+	const maxBytes = 1 << 10
+	rangeA.SetMaxBytes(maxBytes)
+	args := adminMergeArgs(proto.KeyMin, aDesc.RangeID, store.StoreID())
+	_, err = store.ExecuteCmd(context.Background(), &args)
+	if mErr, ok := err.(*storage.MergeTooLargeError); !ok {
+		t.Fatalf("expected MergeTooLargeError; got %v", err)
+	} else if mErr.Bytes <= maxBytes || mErr.MaxBytes != maxBytes ||
+		mErr.RangeID != aDesc.RangeID || mErr.SubsumedRangeID != bDesc.RangeID {
+		t.Errorf("unexpected error contents: %+v", mErr)
+	}
+	if store.LookupReplica([]byte("c"), nil).Desc().RangeID != bDesc.RangeID {
+		t.Fatal("expected ranges not to be merged")
+	}
+
+	// The merged range fits.
+	rangeA.SetMaxBytes(64 << 20)
+	if _, err := store.ExecuteCmd(context.Background(), &args); err != nil {
+		t.Fatal(err)
+	}
+	if store.LookupReplica([]byte("c"), nil).Desc().RangeID != aDesc.RangeID {
+		t.Fatal("expected ranges to be merged")
+	}
+}
+
+// TestStoreRangeMergeUnresolvableIntents verifies that a merge is
+// aborted if the subsumed range holds intents of a live transaction.
+func TestStoreRangeMergeUnresolvableIntents(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	ctx := storage.TestStoreContext
+	ctx.MergeIntentTimeout = 50 * time.Millisecond
+	store, stopper := createTestStoreWithEngine(t,
+		engine.NewInMem(proto.Attributes{}, 10<<20),
+		hlc.NewClock(hlc.NewManualClock(0).UnixNano),
+		true,
+		&ctx)
+	defer stopper.Stop()
+
+	aDesc, bDesc, err := createSplitRanges(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write an intent of a transaction which keeps heartbeating, as the
+	// clock never moves.
+	txn := proto.NewTransaction("test", proto.Key("ccc"), 1, proto.SERIALIZABLE, store.Clock().Now(), 0)
+	pArgs := putArgs([]byte("ccc"), []byte("value"), bDesc.RangeID, store.StoreID())
+	pArgs.Txn = txn
+	pArgs.Timestamp = txn.Timestamp
+	if _, err := store.ExecuteCmd(context.Background(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	args := adminMergeArgs(proto.KeyMin, aDesc.RangeID, store.StoreID())
+	_, err = store.ExecuteCmd(context.Background(), &args)
+	if mErr, ok := err.(*storage.MergeIntentsError); !ok {
+		t.Fatalf("expected MergeIntentsError; got %v", err)
+	} else if mErr.Intents == 0 || mErr.RangeID != aDesc.RangeID || mErr.SubsumedRangeID != bDesc.RangeID {
+		t.Errorf("unexpected error contents: %+v", mErr)
+	}
+	if store.LookupReplica([]byte("c"), nil).Desc().RangeID != bDesc.RangeID {
+		t.Fatal("expected ranges not to be merged")
+	}
+}
+
+// TestStoreRangeMergeResolvesIntents verifies that the intents of
+// abandoned transactions on the subsumed range are cleaned up and the
+// merge proceeds.
+func TestStoreRangeMergeResolvesIntents(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	manualClock := hlc.NewManualClock(0)
+	store, stopper := createTestStoreWithEngine(t,
+		engine.NewInMem(proto.Attributes{}, 10<<20),
+		hlc.NewClock(manualClock.UnixNano),
+		true,
+		nil)
+	defer stopper.Stop()
+
+	aDesc, bDesc, err := createSplitRanges(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"ccc", "ddd"} {
+		txn := proto.NewTransaction("test", proto.Key(key), 1, proto.SERIALIZABLE, store.Clock().Now(), 0)
+		pArgs := putArgs([]byte(key), []byte("value"), bDesc.RangeID, store.StoreID())
+		pArgs.Txn = txn
+		pArgs.Timestamp = txn.Timestamp
+		if _, err := store.ExecuteCmd(context.Background(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Let the transactions' heartbeats expire.
+	manualClock.Increment(2*storage.DefaultHeartbeatInterval.Nanoseconds() + 1)
+
+	args := adminMergeArgs(proto.KeyMin, aDesc.RangeID, store.StoreID())
+	if _, err := store.ExecuteCmd(context.Background(), &args); err != nil {
+		t.Fatal(err)
+	}
+	if store.LookupReplica([]byte("c"), nil).Desc().RangeID != aDesc.RangeID {
+		t.Fatal("expected ranges to be merged")
+	}
+	// The abandoned transactions were aborted.
+	for _, key := range []string{"ccc", "ddd"} {
+		if kv, err := store.DB().Get(key); err != nil {
+			t.Fatal(err)
+		} else if kv.Exists() {
+			t.Errorf("expected %s to be aborted; got %q", key, kv.ValueBytes())
+		}
+	}
+}
+
 // TestStoreRangeMergeNonConsecutive attempts to merge two ranges
 // that are not on same store.
 func TestStoreRangeMergeNonConsecutive(t *testing.T) {
@@ -406,3 +406,102 @@ func TestRangeLookupUseReverse(t *testing.T) {
 		}
 	}
 }
+
+// TestLeaderLeaseTransfer verifies that a transferred leader lease takes
+// the timestamp cache of the previous holder along, so that the new
+// holder pushes writes which conflict with reads served by the previous
+// holder, but no others.
+func TestLeaderLeaseTransfer(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	mtc := startMultiTestContext(t, 2)
+	defer mtc.Stop()
+	mtc.replicateRange(1, 0, 1)
+
+	// Serve a read on the first store, acquiring the lease.
+	mtc.manualClock.Increment(100)
+	readTS := mtc.clock.Now()
+	gArgs := getArgs([]byte("a"), 1, mtc.stores[0].StoreID())
+	gArgs.Timestamp = readTS
+	if _, err := mtc.stores[0].ExecuteCmd(context.Background(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	rng, err := mtc.stores[0].GetReplica(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtc.manualClock.Increment(100)
+	if err := rng.TransferLeaderLease(mtc.stores[1].RaftNodeID()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous holder redirects to the new one.
+	gArgs.Timestamp = mtc.clock.Now()
+	if _, err := mtc.stores[0].ExecuteCmd(context.Background(), &gArgs); err == nil {
+		t.Fatal("expected previous lease holder to reject read")
+	} else if _, ok := err.(*proto.NotLeaderError); !ok {
+		t.Fatalf("expected not leader error; got %s", err)
+	}
+
+	// A write to the key which was read is pushed above the read, but a
+	// write to another key at the same timestamp is not.
+	for _, test := range []struct {
+		key    string
+		pushed bool
+	}{
+		{"a", true},
+		{"b", false},
+	} {
+		pArgs := putArgs([]byte(test.key), []byte("value"), 1, mtc.stores[1].StoreID())
+		pArgs.Timestamp = readTS
+		reply, err := mtc.stores[1].ExecuteCmd(context.Background(), &pArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts := reply.Header().Timestamp; readTS.Less(ts) != test.pushed {
+			t.Errorf("%s: expected pushed=%t; got timestamp %s (read at %s)", test.key, test.pushed, ts, readTS)
+		}
+	}
+}
+
+// TestStoreDrainLeases verifies that draining a store transfers its
+// leader leases to a peer replica instead of letting them expire.
+func TestStoreDrainLeases(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	mtc := startMultiTestContext(t, 2)
+	defer mtc.Stop()
+	mtc.replicateRange(1, 0, 1)
+
+	// Serve a read on the first store, acquiring the lease.
+	mtc.manualClock.Increment(100)
+	gArgs := getArgs([]byte("a"), 1, mtc.stores[0].StoreID())
+	gArgs.Timestamp = mtc.clock.Now()
+	if _, err := mtc.stores[0].ExecuteCmd(context.Background(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+	rng, err := mtc.stores[0].GetReplica(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The peer is only considered once the store pool knows it's alive.
+	for _, s := range mtc.stores {
+		s.GossipStore()
+	}
+	util.SucceedsWithin(t, time.Second, func() error {
+		if n := mtc.stores[0].DrainLeases(); n != 1 {
+			return util.Errorf("expected 1 lease to be transferred; got %d", n)
+		}
+		return nil
+	})
+
+	holder, valid := rng.LeaseHolderHint()
+	if holder.StoreID != mtc.stores[1].StoreID() || !valid {
+		t.Errorf("expected valid lease held by store %d; got %+v (valid=%t)",
+			mtc.stores[1].StoreID(), holder, valid)
+	}
+	// Nothing is left to transfer.
+	if n := mtc.stores[0].DrainLeases(); n != 0 {
+		t.Errorf("expected no leases to be transferred; got %d", n)
+	}
+}
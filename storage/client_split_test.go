@@ -446,6 +446,108 @@ func TestStoreZoneUpdateAndRangeSplit(t *testing.T) {
 	}
 }
 
+// TestStoreZoneShrinkEnqueuesSplit verifies that lowering the zone's
+// range max bytes updates the max bytes of a range which doesn't
+// contain the zone's prefix and promptly enqueues it for split if it
+// now exceeds the limit, without waiting for the range scanner.
+func TestStoreZoneShrinkEnqueuesSplit(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, stopper := createTestStore(t)
+	defer stopper.Stop()
+
+	args := adminSplitArgs(proto.KeyMin, proto.Key("b"), 1, store.StoreID())
+	if _, err := store.ExecuteCmd(context.Background(), &args); err != nil {
+		t.Fatal(err)
+	}
+	rng := store.LookupReplica(proto.Key("b"), nil)
+	maxBytes := int64(1 << 16)
+	fillRange(store, rng.Desc().RangeID, proto.Key("b"), maxBytes, t)
+
+	// Lower the default zone's range max bytes. Only the first range
+	// contains the zone's prefix; the range starting at "b" must be
+	// enqueued because of its size alone.
+	zoneConfig := &config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{},
+			{},
+			{},
+		},
+		RangeMinBytes: 1 << 8,
+		RangeMaxBytes: maxBytes,
+	}
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.KeyMin)
+	if err := store.DB().Put(key, zoneConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.IsTrueWithin(func() bool {
+		return rng.GetMaxBytes() == zoneConfig.RangeMaxBytes
+	}, 50*time.Millisecond); err != nil {
+		t.Fatalf("failed to notice range max bytes update: %s", err)
+	}
+
+	// The scan interval is far longer than this, so a split means the
+	// range was enqueued by the zone update.
+	if err := util.IsTrueWithin(func() bool {
+		return !rng.Desc().EndKey.Equal(proto.KeyMax)
+	}, time.Second); err != nil {
+		t.Errorf("expected range to split within 1s")
+	}
+}
+
+// TestStoreVerifyDataBounds verifies that data of a split-off range
+// which is no longer owned by any replica on the store is reported as
+// out of bounds for the range on its left.
+func TestStoreVerifyDataBounds(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, stopper := createTestStore(t)
+	defer stopper.Stop()
+
+	splitKey := proto.Key("m")
+	args := adminSplitArgs(proto.KeyMin, splitKey, 1, store.StoreID())
+	if _, err := store.ExecuteCmd(context.Background(), &args); err != nil {
+		t.Fatal(err)
+	}
+	key := proto.Key("x")
+	if err := store.DB().Put(key, "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	rng := store.LookupReplica(proto.KeyMin, nil)
+	if extra, err := rng.VerifyDataBounds(); err != nil {
+		t.Fatal(err)
+	} else if extra != 0 {
+		t.Fatalf("expected no out of bounds keys; got %d", extra)
+	}
+
+	// Drop the right hand range without removing its data, which is now
+	// beyond the end of the left hand range.
+	if err := store.RemoveReplica(store.LookupReplica(splitKey, nil)); err != nil {
+		t.Fatal(err)
+	}
+	extraKeys, err := rng.OutOfBoundsKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, k := range extraKeys {
+		if k.Equal(key) {
+			found = true
+		}
+		if rng.Desc().ContainsKey(keys.KeyAddress(k)) {
+			t.Errorf("key %q is within the range's bounds", k)
+		}
+	}
+	if !found {
+		t.Errorf("expected %q among out of bounds keys %q", key, extraKeys)
+	}
+	if extra, err := rng.VerifyDataBounds(); err != nil {
+		t.Fatal(err)
+	} else if extra != len(extraKeys) {
+		t.Errorf("expected %d out of bounds keys; got %d", len(extraKeys), extra)
+	}
+}
+
 // TestStoreRangeSplitWithMaxBytesUpdate tests a scenario where a new
 // zone config that updates the max bytes is set and triggers a range
 // split.
@@ -611,3 +713,52 @@ func TestStoreRangeManySplits(t *testing.T) {
 		t.Errorf("expected splits not found: %s", err)
 	}
 }
+
+// TestStoreRangeIntentRanges verifies that the ranges spanned by a
+// transaction's intents are found, local or not, ordered by start key
+// and without duplicates.
+func TestStoreRangeIntentRanges(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, stopper := createTestStore(t)
+	defer stopper.Stop()
+
+	for _, splitKey := range []proto.Key{proto.Key("b"), proto.Key("d")} {
+		rng := store.LookupReplica(splitKey, nil)
+		args := adminSplitArgs(rng.Desc().StartKey, splitKey, rng.Desc().RangeID, store.StoreID())
+		if _, err := store.ExecuteCmd(context.Background(), &args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rngA := store.LookupReplica(proto.Key("a"), nil)
+	rngC := store.LookupReplica(proto.Key("c"), nil)
+	rngE := store.LookupReplica(proto.Key("e"), nil)
+
+	testCases := []struct {
+		intents  []proto.Intent
+		expected []*storage.Replica
+	}{
+		{nil, nil},
+		{[]proto.Intent{{Key: proto.Key("a")}, {Key: proto.Key("a1")}}, []*storage.Replica{rngA}},
+		{[]proto.Intent{{Key: proto.Key("c")}}, []*storage.Replica{rngC}},
+		{[]proto.Intent{{Key: proto.Key("e")}, {Key: proto.Key("a")}}, []*storage.Replica{rngA, rngE}},
+		{[]proto.Intent{{Key: proto.Key("a")}, {Key: proto.Key("c"), EndKey: proto.Key("e")}},
+			[]*storage.Replica{rngA, rngC, rngE}},
+		{[]proto.Intent{{Key: proto.Key("b"), EndKey: proto.Key("d")}}, []*storage.Replica{rngC}},
+	}
+	for i, test := range testCases {
+		descs, err := rngA.IntentRanges(test.intents)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		var rangeIDs, expected []proto.RangeID
+		for _, desc := range descs {
+			rangeIDs = append(rangeIDs, desc.RangeID)
+		}
+		for _, rng := range test.expected {
+			expected = append(expected, rng.Desc().RangeID)
+		}
+		if !reflect.DeepEqual(rangeIDs, expected) {
+			t.Errorf("%d: expected ranges %v; got %v", i, expected, rangeIDs)
+		}
+	}
+}
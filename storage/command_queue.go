@@ -20,6 +20,7 @@ package storage
 import (
 	"sync"
 
+	"github.com/cockroachdb/cockroach/keys"
 	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/util/cache"
 )
@@ -41,6 +42,12 @@ import (
 // doesn't include read-only on read-only overlapping commands as an
 // optimization.
 //
+// Both GetWait() and Add() take the priority of the command. To avoid
+// priority inversion, a command waiting in GetWait() raises the
+// priority of any lower-priority overlapping commands it waits on to
+// its own. Executing commands can check their effective priority via
+// Priority() and be notified of a boost via Boosted().
+//
 // Once commands complete, Remove() is invoked to remove the executing
 // command and decrement the counts on any pending WaitGroups,
 // possibly signaling waiting commands who were gated by the executing
@@ -53,6 +60,8 @@ type CommandQueue struct {
 
 type cmd struct {
 	readOnly bool
+	priority int32             // Effective priority, possibly inherited
+	boosted  chan struct{}     // Closed when priority is first raised
 	pending  []*sync.WaitGroup // Pending commands gated on cmd
 }
 
@@ -80,41 +89,93 @@ func (cq *CommandQueue) onEvicted(key, value interface{}) {
 // single key. The caller should call wg.Wait() to wait for
 // confirmation that all gating commands have completed or
 // failed. readOnly is true if the requester is a read-only command;
-// false for read-write.
-func (cq *CommandQueue) GetWait(start, end proto.Key, readOnly bool, wg *sync.WaitGroup) {
+// false for read-write. Overlapping commands with a priority lower
+// than the requester's inherit its priority.
+func (cq *CommandQueue) GetWait(start, end proto.Key, readOnly bool, priority int32, wg *sync.WaitGroup) {
+	for _, c := range cq.getConflicts(start, end, readOnly) {
+		c.pending = append(c.pending, wg)
+		wg.Add(1)
+		if c.priority < priority {
+			c.priority = priority
+			select {
+			case <-c.boosted:
+			default:
+				close(c.boosted)
+			}
+		}
+	}
+}
+
+// WouldWait returns true if a command affecting the specified key range
+// would have to wait for executing commands, that is, if GetWait would
+// add to the supplied wait group. Unlike GetWait, it has no side effects.
+func (cq *CommandQueue) WouldWait(start, end proto.Key, readOnly bool) bool {
+	return len(cq.getConflicts(start, end, readOnly)) > 0
+}
+
+// getConflicts returns the executing commands which overlap the specified
+// key range and conflict with a command of the given type.
+func (cq *CommandQueue) getConflicts(start, end proto.Key, readOnly bool) []*cmd {
 	// This gives us a memory-efficient end key if end is empty.
 	if len(end) == 0 {
 		end = start.Next()
 		start = end[:len(start)]
 	}
+	var conflicts []*cmd
 	for _, c := range cq.cache.GetOverlaps(start, end) {
 		c := c.Value.(*cmd)
-		// Only add to the wait group if one of the commands isn't read-only.
+		// Only conflicting if one of the commands isn't read-only.
 		if !readOnly || !c.readOnly {
-			c.pending = append(c.pending, wg)
-			wg.Add(1)
+			conflicts = append(conflicts, c)
 		}
 	}
+	return conflicts
 }
 
 // Add adds a command to the queue which affects the specified key
-// range. If end is empty, it is set to start.Next(), meaning the
-// command affects a single key. The returned interface is the key for
+// range with the given priority. If end is empty, it is set to
+// start.Next(), meaning the command affects a single key. The returned
+// interface is the key for
 // the command queue and must be re-supplied on subsequent invocation
 // of Remove().
 //
 // Add should be invoked after waiting on already-executing,
 // overlapping commands via the WaitGroup initialized through
 // GetWait().
-func (cq *CommandQueue) Add(start, end proto.Key, readOnly bool) interface{} {
+func (cq *CommandQueue) Add(start, end proto.Key, readOnly bool, priority int32) interface{} {
 	if len(end) == 0 {
 		end = start.Next()
 	}
 	key := cq.cache.NewKey(start, end)
-	cq.cache.Add(key, &cmd{readOnly: readOnly})
+	cq.cache.Add(key, &cmd{
+		readOnly: readOnly,
+		priority: priority,
+		boosted:  make(chan struct{}),
+	})
 	return key
 }
 
+// Priority returns the effective priority of the command associated
+// with the specified key, which is the highest of its own priority and
+// those of the commands waiting on it. Returns zero if the command has
+// already been removed.
+func (cq *CommandQueue) Priority(key interface{}) int32 {
+	if v, ok := cq.cache.Get(key); ok {
+		return v.(*cmd).priority
+	}
+	return 0
+}
+
+// Boosted returns a channel which is closed once the command
+// associated with the specified key inherits a higher priority from a
+// waiting command. Returns nil if the command has already been removed.
+func (cq *CommandQueue) Boosted(key interface{}) <-chan struct{} {
+	if v, ok := cq.cache.Get(key); ok {
+		return v.(*cmd).boosted
+	}
+	return nil
+}
+
 // Remove is invoked to signal that the command associated with the
 // specified key has completed and should be removed. Any pending
 // commands waiting on this command will be signaled if this is the
@@ -128,6 +189,18 @@ func (cq *CommandQueue) Remove(key interface{}) {
 	cq.cache.Del(key)
 }
 
+// Spans returns the key spans of the commands in the queue, ordered by
+// start key. A command affecting a single key has a span ending at the
+// key's successor.
+func (cq *CommandQueue) Spans() []keys.Span {
+	var spans []keys.Span
+	cq.cache.Do(func(k, v interface{}) {
+		key := k.(*cache.IntervalKey)
+		spans = append(spans, keys.Span{Start: key.Start().(proto.Key), End: key.End().(proto.Key)})
+	})
+	return spans
+}
+
 // Clear removes all executing commands, signaling any waiting commands.
 func (cq *CommandQueue) Clear() {
 	cq.cache.Clear()
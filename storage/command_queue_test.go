@@ -56,14 +56,14 @@ func TestCommandQueue(t *testing.T) {
 	wg := sync.WaitGroup{}
 
 	// Try a command with no overlapping already-running commands.
-	cq.GetWait(proto.Key("a"), nil, false, &wg)
+	cq.GetWait(proto.Key("a"), nil, false, 0, &wg)
 	wg.Wait()
-	cq.GetWait(proto.Key("a"), proto.Key("b"), false, &wg)
+	cq.GetWait(proto.Key("a"), proto.Key("b"), false, 0, &wg)
 	wg.Wait()
 
 	// Add a command and verify wait group is returned.
-	wk := cq.Add(proto.Key("a"), nil, false)
-	cq.GetWait(proto.Key("a"), nil, false, &wg)
+	wk := cq.Add(proto.Key("a"), nil, false, 0)
+	cq.GetWait(proto.Key("a"), nil, false, 0, &wg)
 	cmdDone := waitForCmd(&wg)
 	if testCmdDone(cmdDone, 1*time.Millisecond) {
 		t.Fatal("command should not finish with command outstanding")
@@ -79,12 +79,18 @@ func TestCommandQueueNoWaitOnReadOnly(t *testing.T) {
 	cq := NewCommandQueue()
 	wg := sync.WaitGroup{}
 	// Add a read-only command.
-	wk := cq.Add(proto.Key("a"), nil, true)
+	wk := cq.Add(proto.Key("a"), nil, true, 0)
 	// Verify no wait on another read-only command.
-	cq.GetWait(proto.Key("a"), nil, true, &wg)
+	if cq.WouldWait(proto.Key("a"), nil, true) {
+		t.Error("read-only command should not wait on read-only command")
+	}
+	cq.GetWait(proto.Key("a"), nil, true, 0, &wg)
 	wg.Wait()
+	if !cq.WouldWait(proto.Key("a"), nil, false) {
+		t.Error("read-write command should wait on read-only command")
+	}
 	// Verify wait with a read-write command.
-	cq.GetWait(proto.Key("a"), nil, false, &wg)
+	cq.GetWait(proto.Key("a"), nil, false, 0, &wg)
 	cmdDone := waitForCmd(&wg)
 	if testCmdDone(cmdDone, 1*time.Millisecond) {
 		t.Fatal("command should not finish with command outstanding")
@@ -101,10 +107,10 @@ func TestCommandQueueMultipleExecutingCommands(t *testing.T) {
 	wg := sync.WaitGroup{}
 
 	// Add multiple commands and add a command which overlaps them all.
-	wk1 := cq.Add(proto.Key("a"), nil, false)
-	wk2 := cq.Add(proto.Key("b"), proto.Key("c"), false)
-	wk3 := cq.Add(proto.Key("0"), proto.Key("d"), false)
-	cq.GetWait(proto.Key("a"), proto.Key("cc"), false, &wg)
+	wk1 := cq.Add(proto.Key("a"), nil, false, 0)
+	wk2 := cq.Add(proto.Key("b"), proto.Key("c"), false, 0)
+	wk3 := cq.Add(proto.Key("0"), proto.Key("d"), false, 0)
+	cq.GetWait(proto.Key("a"), proto.Key("cc"), false, 0, &wg)
 	cmdDone := waitForCmd(&wg)
 	cq.Remove(wk1)
 	if testCmdDone(cmdDone, 1*time.Millisecond) {
@@ -128,10 +134,10 @@ func TestCommandQueueMultiplePendingCommands(t *testing.T) {
 	wg3 := sync.WaitGroup{}
 
 	// Add a command which will overlap all commands.
-	wk := cq.Add(proto.Key("a"), proto.Key("d"), false)
-	cq.GetWait(proto.Key("a"), nil, false, &wg1)
-	cq.GetWait(proto.Key("b"), nil, false, &wg2)
-	cq.GetWait(proto.Key("c"), nil, false, &wg3)
+	wk := cq.Add(proto.Key("a"), proto.Key("d"), false, 0)
+	cq.GetWait(proto.Key("a"), nil, false, 0, &wg1)
+	cq.GetWait(proto.Key("b"), nil, false, 0, &wg2)
+	cq.GetWait(proto.Key("c"), nil, false, 0, &wg3)
 	cmdDone1 := waitForCmd(&wg1)
 	cmdDone2 := waitForCmd(&wg2)
 	cmdDone3 := waitForCmd(&wg3)
@@ -156,10 +162,10 @@ func TestCommandQueueClear(t *testing.T) {
 	wg2 := sync.WaitGroup{}
 
 	// Add multiple commands and commands which access each.
-	cq.Add(proto.Key("a"), nil, false)
-	cq.Add(proto.Key("b"), nil, false)
-	cq.GetWait(proto.Key("a"), nil, false, &wg1)
-	cq.GetWait(proto.Key("b"), nil, false, &wg2)
+	cq.Add(proto.Key("a"), nil, false, 0)
+	cq.Add(proto.Key("b"), nil, false, 0)
+	cq.GetWait(proto.Key("a"), nil, false, 0, &wg1)
+	cq.GetWait(proto.Key("b"), nil, false, 0, &wg2)
 	cmdDone1 := waitForCmd(&wg1)
 	cmdDone2 := waitForCmd(&wg2)
 
@@ -179,11 +185,72 @@ func TestCommandQueueClear(t *testing.T) {
 func TestCommandQueueExclusiveEnd(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	cq := NewCommandQueue()
-	cq.Add(proto.Key("a"), proto.Key("b"), false)
+	cq.Add(proto.Key("a"), proto.Key("b"), false, 0)
 
 	wg := sync.WaitGroup{}
-	cq.GetWait(proto.Key("b"), nil, false, &wg)
+	cq.GetWait(proto.Key("b"), nil, false, 0, &wg)
 	// Verify no wait on the second writer command on "b" since
 	// it does not overlap with the first command on ["a", "b").
 	wg.Wait()
 }
+
+// TestCommandQueuePriorityInheritance verifies that an executing
+// command inherits the priority of a higher-priority command waiting
+// on it, which allows a low-priority command that would otherwise
+// yield to finish early and unblock the waiter.
+func TestCommandQueuePriorityInheritance(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	var mu sync.Mutex // CommandQueue is not thread safe
+	cq := NewCommandQueue()
+
+	mu.Lock()
+	wk := cq.Add(proto.Key("a"), nil, false, 1)
+	boosted := cq.Boosted(wk)
+	mu.Unlock()
+
+	// The low-priority command yields for a long time unless it is boosted.
+	lowDone := make(chan struct{})
+	go func() {
+		select {
+		case <-boosted:
+		case <-time.After(10 * time.Second):
+		}
+		mu.Lock()
+		cq.Remove(wk)
+		mu.Unlock()
+		close(lowDone)
+	}()
+
+	// Neither a lower-priority waiter nor a non-conflicting one boosts
+	// the executing command.
+	mu.Lock()
+	wg1 := sync.WaitGroup{}
+	cq.GetWait(proto.Key("a"), nil, false, 0, &wg1)
+	wg2 := sync.WaitGroup{}
+	cq.GetWait(proto.Key("b"), nil, false, 5, &wg2)
+	if p := cq.Priority(wk); p != 1 {
+		t.Errorf("expected priority 1, got %d", p)
+	}
+	mu.Unlock()
+	if testCmdDone(lowDone, 10*time.Millisecond) {
+		t.Fatal("low-priority command should not have been boosted")
+	}
+
+	// A higher-priority waiter boosts it.
+	mu.Lock()
+	wg3 := sync.WaitGroup{}
+	cq.GetWait(proto.Key("a"), nil, false, 5, &wg3)
+	if p := cq.Priority(wk); p != 5 {
+		t.Errorf("expected inherited priority 5, got %d", p)
+	}
+	mu.Unlock()
+	if !testCmdDone(waitForCmd(&wg3), time.Second) {
+		t.Fatal("boosted command should have finished and unblocked the waiter")
+	}
+	<-lowDone
+	mu.Lock()
+	defer mu.Unlock()
+	if p := cq.Priority(wk); p != 0 {
+		t.Errorf("expected no priority for removed command, got %d", p)
+	}
+}
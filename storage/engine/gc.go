@@ -28,17 +28,21 @@ import (
 // policy allows either the union or intersection of maximum # of
 // versions and maximum age.
 type GarbageCollector struct {
-	expiration proto.Timestamp
-	policy     config.GCPolicy
+	expiration          proto.Timestamp
+	tombstoneExpiration proto.Timestamp
+	policy              config.GCPolicy
 }
 
 // NewGarbageCollector allocates and returns a new GC, with expiration
-// computed based on current time and policy.TTLSeconds.
+// computed based on current time and policy.TTLSeconds, and tombstone
+// expiration computed based on policy.TombstoneTTLSeconds.
 func NewGarbageCollector(now proto.Timestamp, policy config.GCPolicy) *GarbageCollector {
 	ttlNanos := int64(policy.TTLSeconds) * 1E9
+	tombstoneTTLNanos := int64(policy.TombstoneTTLSeconds) * 1E9
 	return &GarbageCollector{
-		expiration: proto.Timestamp{WallTime: now.WallTime - ttlNanos},
-		policy:     policy,
+		expiration:          proto.Timestamp{WallTime: now.WallTime - ttlNanos},
+		tombstoneExpiration: proto.Timestamp{WallTime: now.WallTime - tombstoneTTLNanos},
+		policy:              policy,
 	}
 }
 
@@ -93,3 +97,47 @@ func (gc *GarbageCollector) Filter(keys []proto.EncodedKey, values [][]byte) pro
 	}
 	return delTS
 }
+
+// FilterTombstone returns the timestamp of the most recent of the
+// supplied values of a key if it's a deletion tombstone older than the
+// policy's tombstone TTL which no read within the GC TTL could need, in
+// which case the tombstone and all values it shadows should be garbage
+// collected. That is the case if every older value is either a deletion
+// tombstone itself or shadowed by a version older than the GC TTL, so
+// that removing them doesn't change the result of any read which isn't
+// refused for being below the GC threshold. Otherwise, returns
+// proto.ZeroTimestamp.
+func (gc *GarbageCollector) FilterTombstone(keys []proto.EncodedKey, values [][]byte) proto.Timestamp {
+	if gc.policy.TombstoneTTLSeconds <= 0 || len(keys) == 0 {
+		return proto.ZeroTimestamp
+	}
+	var tombstoneTS, prevTS proto.Timestamp
+	for i, key := range keys {
+		_, ts, isValue := MVCCDecodeKey(key)
+		if !isValue {
+			log.Errorf("unexpected MVCC metadata encountered: %q", key)
+			return proto.ZeroTimestamp
+		}
+		// Values shadowed by a version older than the GC TTL aren't
+		// visible to any read at or above the GC threshold.
+		if i > 0 && gc.policy.TTLSeconds > 0 && prevTS.Less(gc.expiration) {
+			break
+		}
+		mvccVal := MVCCValue{}
+		if err := gogoproto.Unmarshal(values[i], &mvccVal); err != nil {
+			log.Errorf("unable to unmarshal MVCC value %q: %v", key, err)
+			return proto.ZeroTimestamp
+		}
+		if !mvccVal.Deleted {
+			return proto.ZeroTimestamp
+		}
+		if i == 0 {
+			if !ts.Less(gc.tombstoneExpiration) {
+				return proto.ZeroTimestamp
+			}
+			tombstoneTS = ts
+		}
+		prevTS = ts
+	}
+	return tombstoneTS
+}
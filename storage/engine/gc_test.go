@@ -90,3 +90,36 @@ func TestGarbageCollectorFilter(t *testing.T) {
 		}
 	}
 }
+
+// TestGarbageCollectorFilterTombstone verifies that a deletion tombstone
+// heading a key's values is marked for deletion once older than the
+// tombstone TTL, unless it shadows a value visible within the GC TTL.
+func TestGarbageCollectorFilterTombstone(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	n := serializedMVCCValue(false, t)
+	d := serializedMVCCValue(true, t)
+	testData := []struct {
+		policy   config.GCPolicy
+		time     proto.Timestamp
+		values   [][]byte
+		expDelTS proto.Timestamp
+	}{
+		{config.GCPolicy{TTLSeconds: 10}, makeTS(5E9, 0), [][]byte{d, d}, proto.ZeroTimestamp},
+		{config.GCPolicy{TTLSeconds: 10, TombstoneTTLSeconds: 1}, makeTS(2E9, 0), [][]byte{d, d}, proto.ZeroTimestamp},
+		{config.GCPolicy{TTLSeconds: 10, TombstoneTTLSeconds: 1}, makeTS(3E9, 0), [][]byte{d, d}, proto.ZeroTimestamp},
+		{config.GCPolicy{TTLSeconds: 10, TombstoneTTLSeconds: 1}, makeTS(3E9+1, 0), [][]byte{d, d}, makeTS(2E9, 0)},
+		{config.GCPolicy{TTLSeconds: 10, TombstoneTTLSeconds: 1}, makeTS(3E9+1, 0), [][]byte{d, n}, proto.ZeroTimestamp},
+		{config.GCPolicy{TTLSeconds: 1, TombstoneTTLSeconds: 1}, makeTS(3E9, 0), [][]byte{d, n}, proto.ZeroTimestamp},
+		{config.GCPolicy{TTLSeconds: 1, TombstoneTTLSeconds: 1}, makeTS(3E9+1, 0), [][]byte{d, n}, makeTS(2E9, 0)},
+		{config.GCPolicy{TombstoneTTLSeconds: 1}, makeTS(5E9, 0), [][]byte{d, d}, makeTS(2E9, 0)},
+		{config.GCPolicy{TombstoneTTLSeconds: 1}, makeTS(5E9, 0), [][]byte{d, n}, proto.ZeroTimestamp},
+		{config.GCPolicy{TTLSeconds: 10, TombstoneTTLSeconds: 1}, makeTS(5E9, 0), [][]byte{n, d}, proto.ZeroTimestamp},
+	}
+	for i, test := range testData {
+		gc := NewGarbageCollector(test.time, test.policy)
+		delTS := gc.FilterTombstone(bKeys, test.values)
+		if !delTS.Equal(test.expDelTS) {
+			t.Errorf("%d: expected deletion timestamp %s; got %s", i, test.expDelTS, delTS)
+		}
+	}
+}
@@ -454,6 +454,13 @@ var getBufferPool = sync.Pool{
 // ignored for reading the value (but returned via the proto.Intent slice);
 // the previous value (if any) is read instead.
 func MVCCGet(engine Engine, key proto.Key, timestamp proto.Timestamp, consistent bool, txn *proto.Transaction) (*proto.Value, []proto.Intent, error) {
+	return MVCCGetWithStats(engine, key, timestamp, consistent, txn, nil /* stats */)
+}
+
+// MVCCGetWithStats is like MVCCGet, but if stats is not nil, the keys
+// and bytes read from the engine are added to it.
+func MVCCGetWithStats(engine Engine, key proto.Key, timestamp proto.Timestamp, consistent bool, txn *proto.Transaction,
+	stats *proto.ExecutionStats) (*proto.Value, []proto.Intent, error) {
 	if len(key) == 0 {
 		return nil, nil, emptyKeyError()
 	}
@@ -478,12 +485,22 @@ func MVCCGet(engine Engine, key proto.Key, timestamp proto.Timestamp, consistent
 	defer getBufferPool.Put(buf)
 
 	metaKey := mvccEncodeKey(buf.key[0:0], key)
-	ok, _, _, err := engine.GetProto(metaKey, &buf.meta)
+	ok, keyBytes, valBytes, err := engine.GetProto(metaKey, &buf.meta)
 	if err != nil || !ok {
 		return nil, nil, err
 	}
+	countRead(stats, keyBytes, valBytes)
+
+	return mvccGetInternal(engine, key, metaKey, timestamp, consistent, txn, getValue, buf, stats)
+}
 
-	return mvccGetInternal(engine, key, metaKey, timestamp, consistent, txn, getValue, buf)
+// countRead adds a key/value pair read from the engine to stats, unless
+// stats is nil.
+func countRead(stats *proto.ExecutionStats, keyBytes, valBytes int64) {
+	if stats != nil {
+		stats.KeysScanned++
+		stats.BytesRead += keyBytes + valBytes
+	}
 }
 
 // getValueFunc fetches a version of a key between start and end.
@@ -500,10 +517,12 @@ type getValueFunc func(engine Engine, start, end proto.EncodedKey,
 // intents (regardless of the actual status of their transaction) and read the
 // most recent non-intent value instead. In the event that an inconsistent read
 // does encounter an intent (currently there can only be one), it is returned
-// via the proto.Intent slice, in addition to the result.
+// via the proto.Intent slice, in addition to the result. The version read,
+// if any, is counted in stats unless stats is nil; the caller accounts for
+// the metadata.
 func mvccGetInternal(engine Engine, key proto.Key, metaKey proto.EncodedKey,
 	timestamp proto.Timestamp, consistent bool, txn *proto.Transaction,
-	getValue getValueFunc, buf *getBuffer) (*proto.Value, []proto.Intent, error) {
+	getValue getValueFunc, buf *getBuffer, stats *proto.ExecutionStats) (*proto.Value, []proto.Intent, error) {
 	if !consistent && txn != nil {
 		return nil, nil, util.Errorf("cannot allow inconsistent reads within a transaction")
 	}
@@ -621,6 +640,7 @@ func mvccGetInternal(engine Engine, key proto.Key, metaKey proto.EncodedKey,
 	if valueKey == nil {
 		return nil, ignoredIntents, nil
 	}
+	countRead(stats, int64(len(valueKey)), int64(value.Size()))
 
 	_, ts, isValue := MVCCDecodeKey(valueKey)
 	if !isValue {
@@ -894,6 +914,45 @@ func MVCCConditionalPut(engine Engine, ms *MVCCStats, key proto.Key, timestamp p
 	return MVCCPut(engine, ms, key, timestamp, value, txn)
 }
 
+// MVCCCheckAndPut sets the value for a specified key only if the latest
+// version of the key is not newer than expTimestamp. If it is, returns a
+// ConditionFailedError containing the actual value.
+//
+// Unlike MVCCConditionalPut, the condition check reads the most recent
+// version of the key regardless of the operational timestamp, so that a
+// write by another client is detected even if it happened at a
+// timestamp later than this request's. Deletions count as versions: the
+// actual value is nil if the latest version is a deletion tombstone.
+func MVCCCheckAndPut(engine Engine, ms *MVCCStats, key proto.Key, timestamp proto.Timestamp, value proto.Value,
+	expTimestamp proto.Timestamp, txn *proto.Transaction) error {
+	if len(key) == 0 {
+		return emptyKeyError()
+	}
+	// The metadata is read directly because MVCCGet hides tombstones,
+	// and a newer deletion has to fail the check just like a newer put.
+	meta := &MVCCMetadata{}
+	ok, _, _, err := engine.GetProto(MVCCEncodeKey(key), meta)
+	if err != nil {
+		return err
+	}
+	if ok && !meta.IsInline() {
+		if meta.HasWriteIntentError(txn) {
+			return &proto.WriteIntentError{Intents: []proto.Intent{{Key: key, Txn: *meta.Txn}}}
+		}
+		if expTimestamp.Less(meta.Timestamp) {
+			existVal, _, err := MVCCGet(engine, key, proto.MaxTimestamp, true /* consistent */, txn)
+			if err != nil {
+				return err
+			}
+			return &proto.ConditionFailedError{
+				ActualValue: existVal,
+			}
+		}
+	}
+
+	return MVCCPut(engine, ms, key, timestamp, value, txn)
+}
+
 // MVCCMerge implements a merge operation. Merge adds integer values,
 // concatenates undifferentiated byte slice values, and efficiently
 // combines time series observations if the proto.Value tag value
@@ -921,22 +980,33 @@ func MVCCMerge(engine Engine, ms *MVCCStats, key proto.Key, value proto.Value) e
 // MVCCDeleteRange deletes the range of key/value pairs specified by
 // start and end keys. Specify max=0 for unbounded deletes.
 func MVCCDeleteRange(engine Engine, ms *MVCCStats, key, endKey proto.Key, max int64, timestamp proto.Timestamp, txn *proto.Transaction) (int64, error) {
+	keys, err := MVCCFilteredDeleteRange(engine, ms, key, endKey, max, timestamp, txn, nil /* filter */, nil /* stats */)
+	return int64(len(keys)), err
+}
+
+// MVCCFilteredDeleteRange is like MVCCDeleteRange, but only deletes the
+// keys whose latest value filter returns true for. Keys which are
+// filtered out do not count towards max. Returns the deleted keys. If
+// stats is not nil, the keys and bytes scanned are added to it.
+func MVCCFilteredDeleteRange(engine Engine, ms *MVCCStats, key, endKey proto.Key, max int64, timestamp proto.Timestamp,
+	txn *proto.Transaction, filter func(proto.KeyValue) bool, stats *proto.ExecutionStats) ([]proto.Key, error) {
 	// In order to detect the potential write intent by another
 	// concurrent transaction with a newer timestamp, we need
 	// to use the max timestamp for scan.
-	kvs, _, err := MVCCScan(engine, key, endKey, max, proto.MaxTimestamp, true /* consistent */, txn)
+	kvs, _, _, err := mvccScanInternal(engine, key, endKey, max, 0 /* maxIntents */, proto.MaxTimestamp,
+		true /* consistent */, txn, false /* !reverse */, filter, stats)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	num := int64(0)
+	keys := make([]proto.Key, 0, len(kvs))
 	for _, kv := range kvs {
 		if err := MVCCDelete(engine, ms, kv.Key, timestamp, txn); err != nil {
-			return num, err
+			return keys, err
 		}
-		num++
+		keys = append(keys, kv.Key)
 	}
-	return num, nil
+	return keys, nil
 }
 
 func getScanMetaKey(iter Iterator, encEndKey proto.EncodedKey) (proto.Key, proto.EncodedKey, error) {
@@ -981,12 +1051,19 @@ func getReverseScanMetaKey(iter Iterator, encEndKey proto.EncodedKey) (proto.Key
 
 // mvccScanInternal scans the key range [start,end) up to some maximum number
 // of results. Specify max=0 for unbounded scans. Specify reverse=true to scan
-// in descending instead of ascending order.
-func mvccScanInternal(engine Engine, key, endKey proto.Key, max int64, timestamp proto.Timestamp,
-	consistent bool, txn *proto.Transaction, reverse bool) ([]proto.KeyValue, []proto.Intent, error) {
+// in descending instead of ascending order. If filter is not nil, only rows
+// for which it returns true are returned and counted towards max. At most
+// maxIntents skipped intents are collected and the data read is counted
+// in stats, see mvccIterateInternal.
+func mvccScanInternal(engine Engine, key, endKey proto.Key, max int64, maxIntents int, timestamp proto.Timestamp,
+	consistent bool, txn *proto.Transaction, reverse bool, filter func(proto.KeyValue) bool,
+	stats *proto.ExecutionStats) ([]proto.KeyValue, []proto.Intent, bool, error) {
 	res := []proto.KeyValue{}
-	intents, err := MVCCIterate(engine, key, endKey, timestamp, consistent, txn, reverse,
+	intents, moreIntents, err := mvccIterateInternal(engine, key, endKey, timestamp, consistent, txn, reverse, maxIntents, stats,
 		func(kv proto.KeyValue) (bool, error) {
+			if filter != nil && !filter(kv) {
+				return false, nil
+			}
 			res = append(res, kv)
 			if max != 0 && max == int64(len(res)) {
 				return true, nil
@@ -995,25 +1072,51 @@ func mvccScanInternal(engine Engine, key, endKey proto.Key, max int64, timestamp
 		})
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
-	return res, intents, nil
+	return res, intents, moreIntents, nil
 }
 
 // MVCCScan scans the key range [start,end) key up to some maximum number of
 // results in ascending order. Specify max=0 for unbounded scans.
 func MVCCScan(engine Engine, key, endKey proto.Key, max int64, timestamp proto.Timestamp,
 	consistent bool, txn *proto.Transaction) ([]proto.KeyValue, []proto.Intent, error) {
-	return mvccScanInternal(engine, key, endKey, max, timestamp,
-		consistent, txn, false /* !reverse */)
+	kvs, intents, _, err := mvccScanInternal(engine, key, endKey, max, 0 /* maxIntents */, timestamp,
+		consistent, txn, false /* !reverse */, nil /* filter */, nil /* stats */)
+	return kvs, intents, err
+}
+
+// MVCCFilteredScan is like MVCCScan, but only returns the rows for which
+// filter returns true. Rows which are filtered out do not count towards
+// max.
+func MVCCFilteredScan(engine Engine, key, endKey proto.Key, max int64, timestamp proto.Timestamp,
+	consistent bool, txn *proto.Transaction, filter func(proto.KeyValue) bool) ([]proto.KeyValue, []proto.Intent, error) {
+	kvs, intents, _, err := mvccScanInternal(engine, key, endKey, max, 0 /* maxIntents */, timestamp,
+		consistent, txn, false /* !reverse */, filter, nil /* stats */)
+	return kvs, intents, err
 }
 
 // MVCCReverseScan scans the key range [start,end) key up to some maximum number of
 // results in descending order. Specify max=0 for unbounded scans.
 func MVCCReverseScan(engine Engine, key, endKey proto.Key, max int64, timestamp proto.Timestamp,
 	consistent bool, txn *proto.Transaction) ([]proto.KeyValue, []proto.Intent, error) {
-	return mvccScanInternal(engine, key, endKey, max, timestamp,
-		consistent, txn, true /* reverse */)
+	kvs, intents, _, err := mvccScanInternal(engine, key, endKey, max, 0 /* maxIntents */, timestamp,
+		consistent, txn, true /* reverse */, nil /* filter */, nil /* stats */)
+	return kvs, intents, err
+}
+
+// MVCCLimitedIntentScan is like MVCCFilteredScan, or like MVCCReverseScan
+// with a filter if reverse is true, but collects at most maxIntents of
+// the intents it skips over. Specify maxIntents=0 to collect all of
+// them. The returned bool is true if further intents were skipped
+// without being collected. If stats is not nil, the keys and bytes read
+// from the engine are added to it, including those of metadata,
+// deletion tombstones and intents.
+func MVCCLimitedIntentScan(engine Engine, key, endKey proto.Key, max int64, maxIntents int, timestamp proto.Timestamp,
+	consistent bool, txn *proto.Transaction, reverse bool, filter func(proto.KeyValue) bool,
+	stats *proto.ExecutionStats) ([]proto.KeyValue, []proto.Intent, bool, error) {
+	return mvccScanInternal(engine, key, endKey, max, maxIntents, timestamp,
+		consistent, txn, reverse, filter, stats)
 }
 
 // MVCCIterate iterates over the key range [start,end). At each step of the
@@ -1022,11 +1125,24 @@ func MVCCReverseScan(engine Engine, key, endKey proto.Key, max int64, timestamp
 // reverse is flag set the iterator will be moved in reverse order.
 func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Timestamp,
 	consistent bool, txn *proto.Transaction, reverse bool, f func(proto.KeyValue) (bool, error)) ([]proto.Intent, error) {
+	intents, _, err := mvccIterateInternal(engine, startKey, endKey, timestamp, consistent, txn, reverse,
+		0 /* maxIntents */, nil /* stats */, f)
+	return intents, err
+}
+
+// mvccIterateInternal implements MVCCIterate. At most maxIntents of the
+// intents skipped by an inconsistent iteration are collected, or all of
+// them if maxIntents is 0; the returned bool is true if further intents
+// were skipped without being collected. Unless stats is nil, every
+// metadata and version key read from the engine is counted in it.
+func mvccIterateInternal(engine Engine, startKey, endKey proto.Key, timestamp proto.Timestamp,
+	consistent bool, txn *proto.Transaction, reverse bool, maxIntents int, stats *proto.ExecutionStats,
+	f func(proto.KeyValue) (bool, error)) ([]proto.Intent, bool, error) {
 	if !consistent && txn != nil {
-		return nil, util.Errorf("cannot allow inconsistent reads within a transaction")
+		return nil, false, util.Errorf("cannot allow inconsistent reads within a transaction")
 	}
 	if len(endKey) == 0 {
-		return nil, emptyKeyError()
+		return nil, false, emptyKeyError()
 	}
 
 	buf := getBufferPool.Get().(*getBuffer)
@@ -1074,7 +1190,7 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 	if reverse {
 		iter.SeekReverse(encKey)
 		if !iter.Valid() {
-			return nil, iter.Error()
+			return nil, false, iter.Error()
 		}
 
 		// If the key doesn't exist, the iterator is at the next key that does
@@ -1088,12 +1204,13 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 	}
 
 	if !iter.Valid() {
-		return nil, iter.Error()
+		return nil, false, iter.Error()
 	}
 
 	// A slice to gather all encountered intents we skipped, in case of
 	// inconsistent iteration.
 	var intents []proto.Intent
+	var moreIntents bool
 	// Gathers up all the intents from WriteIntentErrors. We only get those if
 	// the scan is consistent.
 	var wiErr error
@@ -1101,7 +1218,7 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 	for {
 		key, metaKey, err := getMetaKey(iter, encEndKey)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		// Exceeding the boundary.
 		if key == nil && metaKey == nil {
@@ -1109,14 +1226,19 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 		}
 
 		if err := iter.ValueProto(&buf.meta); err != nil {
-			return nil, err
+			return nil, false, err
+		}
+		countRead(stats, int64(len(metaKey)), int64(buf.meta.Size()))
+		value, newIntents, err := mvccGetInternal(engine, key, metaKey, timestamp, consistent, txn, getValue, buf, stats)
+		if maxIntents > 0 && len(intents)+len(newIntents) > maxIntents {
+			newIntents = newIntents[:maxIntents-len(intents)]
+			moreIntents = true
 		}
-		value, newIntents, err := mvccGetInternal(engine, key, metaKey, timestamp, consistent, txn, getValue, buf)
 		intents = append(intents, newIntents...)
 		if value != nil {
 			done, err := f(proto.KeyValue{Key: key, Value: *value})
 			if err != nil {
-				return nil, err
+				return nil, false, err
 			}
 			if done {
 				break
@@ -1133,7 +1255,7 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 					wiErr.(*proto.WriteIntentError).Intents = append(wiErr.(*proto.WriteIntentError).Intents, tErr.Intents...)
 				}
 			default:
-				return nil, err
+				return nil, false, err
 			}
 		}
 		if reverse {
@@ -1141,7 +1263,7 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 			iter.Seek(metaKey)
 			if !iter.Valid() {
 				if err := iter.Error(); err != nil {
-					return nil, err
+					return nil, false, err
 				}
 				break
 			}
@@ -1150,7 +1272,7 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 			iter.Prev()
 			if !iter.Valid() {
 				if err := iter.Error(); err != nil {
-					return nil, err
+					return nil, false, err
 				}
 				break
 			}
@@ -1159,14 +1281,14 @@ func MVCCIterate(engine Engine, startKey, endKey proto.Key, timestamp proto.Time
 			iter.Seek(mvccEncodeKey(keyBuf, key.Next()))
 			if !iter.Valid() {
 				if err := iter.Error(); err != nil {
-					return nil, err
+					return nil, false, err
 				}
 				break
 			}
 		}
 
 	}
-	return intents, wiErr
+	return intents, moreIntents, wiErr
 }
 
 // MVCCResolveWriteIntent either commits or aborts (rolls back) an
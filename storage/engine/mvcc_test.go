@@ -1067,6 +1067,51 @@ func TestMVCCScanInconsistent(t *testing.T) {
 	}
 }
 
+// TestMVCCLimitedIntentScan verifies that an inconsistent scan collects
+// no more than the requested number of skipped intents, in scan order,
+// and indicates whether it skipped further ones.
+func TestMVCCLimitedIntentScan(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	engine := createTestEngine()
+	defer engine.Close()
+
+	if err := MVCCPut(engine, nil, testKey1, makeTS(1, 0), value1, txn1); err != nil {
+		t.Fatal(err)
+	}
+	if err := MVCCPut(engine, nil, testKey2, makeTS(1, 0), value2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := MVCCPut(engine, nil, testKey3, makeTS(1, 0), value3, txn2); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		maxIntents int
+		reverse    bool
+		expIntents []proto.Intent
+		expMore    bool
+	}{
+		{0, false, []proto.Intent{{Key: testKey1, Txn: *txn1}, {Key: testKey3, Txn: *txn2}}, false},
+		{1, false, []proto.Intent{{Key: testKey1, Txn: *txn1}}, true},
+		{1, true, []proto.Intent{{Key: testKey3, Txn: *txn2}}, true},
+		{2, false, []proto.Intent{{Key: testKey1, Txn: *txn1}, {Key: testKey3, Txn: *txn2}}, false},
+	}
+	for i, test := range testCases {
+		kvs, intents, more, err := MVCCLimitedIntentScan(engine, testKey1, testKey4, 0, test.maxIntents,
+			makeTS(2, 0), false, nil, test.reverse, nil, nil)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if len(kvs) != 1 || !kvs[0].Key.Equal(testKey2) {
+			t.Errorf("%d: expected only %q to be returned; got %+v", i, testKey2, kvs)
+		}
+		if !reflect.DeepEqual(intents, test.expIntents) || more != test.expMore {
+			t.Errorf("%d: expected intents %+v and more=%t; got %+v and more=%t",
+				i, test.expIntents, test.expMore, intents, more)
+		}
+	}
+}
+
 func TestMVCCDeleteRange(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	engine := createTestEngine()
@@ -1346,6 +1391,46 @@ func TestMVCCConditionalPutOldTimestamp(t *testing.T) {
 	}
 }
 
+// TestMVCCCheckAndPut verifies that CheckAndPut fails if the latest
+// version of the key, including a deletion tombstone, is newer than the
+// expected timestamp.
+func TestMVCCCheckAndPut(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	engine := createTestEngine()
+	defer engine.Close()
+
+	// A missing key passes the check.
+	if err := MVCCCheckAndPut(engine, nil, testKey1, makeTS(1, 0), value1, makeTS(0, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	// So does a key whose latest version isn't newer than expected.
+	if err := MVCCCheckAndPut(engine, nil, testKey1, makeTS(2, 0), value2, makeTS(1, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer version fails the check.
+	err := MVCCCheckAndPut(engine, nil, testKey1, makeTS(3, 0), value3, makeTS(1, 0), nil)
+	if cErr, ok := err.(*proto.ConditionFailedError); !ok {
+		t.Fatalf("expected ConditionFailedError; got %v", err)
+	} else if cErr.ActualValue == nil || !bytes.Equal(cErr.ActualValue.Bytes, value2.Bytes) {
+		t.Errorf("expected actual value %q; got %v", value2.Bytes, cErr.ActualValue)
+	}
+
+	// So does a newer deletion, which has no actual value.
+	if err := MVCCDelete(engine, nil, testKey1, makeTS(3, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	err = MVCCCheckAndPut(engine, nil, testKey1, makeTS(4, 0), value3, makeTS(2, 0), nil)
+	if cErr, ok := err.(*proto.ConditionFailedError); !ok {
+		t.Fatalf("expected ConditionFailedError; got %v", err)
+	} else if cErr.ActualValue != nil {
+		t.Errorf("expected no actual value; got %v", cErr.ActualValue)
+	}
+	if err := MVCCCheckAndPut(engine, nil, testKey1, makeTS(4, 0), value3, makeTS(3, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestMVCCAbortTxn(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	engine := createTestEngine()
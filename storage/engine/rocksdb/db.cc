@@ -155,6 +155,10 @@ const cockroach::proto::ResponseHeader* GetResponseHeader(const cockroach::proto
     return &rcEntry.truncate_log().header();
   } else if (rcEntry.has_leader_lease()) {
     return &rcEntry.leader_lease().header();
+  } else if (rcEntry.has_check_and_put()) {
+    return &rcEntry.check_and_put().header();
+  } else if (rcEntry.has_multi_increment()) {
+    return &rcEntry.multi_increment().header();
   } else if (rcEntry.has_batch()) {
     return &rcEntry.batch().header();
   }
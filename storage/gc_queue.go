@@ -18,12 +18,15 @@
 package storage
 
 import (
+	"bytes"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/cockroachdb/cockroach/client"
 	"github.com/cockroachdb/cockroach/config"
+	"github.com/cockroachdb/cockroach/keys"
 	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/storage/engine"
 	"github.com/cockroachdb/cockroach/util"
@@ -45,6 +48,12 @@ const (
 	// intentAgeThreshold is the threshold after which an extant intent
 	// will be resolved.
 	intentAgeThreshold = 2 * time.Hour // 2 hour
+	// txnRecordAgeThreshold is the time since the last heartbeat after
+	// which the transaction owning a record is considered abandoned, at
+	// which point it is aborted, its intents on the range are resolved
+	// and the record is removed. This matches the threshold at which
+	// conflicting transactions may abort it.
+	txnRecordAgeThreshold = 2 * DefaultHeartbeatInterval
 )
 
 // gcQueue manages a queue of replicas slated to be scanned in their
@@ -55,6 +64,8 @@ const (
 //    as implemented going forward).
 //  - Resolve extant write intents and determine oldest non-resolvable
 //    intent.
+//  - Abort transactions whose records show they were abandoned by
+//    their coordinator, resolve their intents and remove the records.
 //
 // The shouldQueue function combines the need for both tasks into a
 // single priority. If any task is overdue, shouldQueue returns true.
@@ -121,11 +132,27 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 	}
 
 	gcMeta := proto.NewGCMetadata(now.WallTime)
+	if policy.TTLSeconds > 0 {
+		gcMeta.ThresholdNanos = now.WallTime - int64(policy.TTLSeconds)*1e9
+	}
+	// The threshold never moves back, for instance when the GC TTL is
+	// raised, since versions below it may have been removed already.
+	prevThreshold, err := repl.GCThreshold()
+	if err != nil {
+		return err
+	}
+	if prevThreshold.WallTime > gcMeta.ThresholdNanos {
+		gcMeta.ThresholdNanos = prevThreshold.WallTime
+	}
 	gc := engine.NewGarbageCollector(now, policy)
 
 	// Compute intent expiration (intent age at which we attempt to resolve).
 	intentExp := now
 	intentExp.WallTime -= intentAgeThreshold.Nanoseconds()
+	// Compute transaction record expiration (heartbeat age at which we
+	// consider the transaction abandoned).
+	txnExp := now
+	txnExp.WallTime -= txnRecordAgeThreshold.Nanoseconds()
 
 	gcArgs := &proto.GCRequest{
 		RequestHeader: proto.RequestHeader{
@@ -139,9 +166,12 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 	var keys []proto.EncodedKey
 	var vals [][]byte
 
-	// Maps from txn ID to txn and intent key slice.
+	// Maps from txn ID to txn and intent key slice. Only transactions
+	// in txnMap are pushed and have their intents resolved.
 	txnMap := map[string]*proto.Transaction{}
 	intentMap := map[string][]proto.Intent{}
+	// Maps from txn ID to the key of an abandoned transaction record.
+	txnKeyMap := map[string]proto.Key{}
 
 	// updateOldestIntent atomically updates the oldest intent.
 	updateOldestIntent := func(intentNanos int64) {
@@ -157,6 +187,21 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 	// resolution and values after the MVCC metadata, and possible
 	// intent, are sent for garbage collection.
 	processKeysAndValues := func() {
+		// Transaction records are stored inline, as a single key.
+		if len(keys) == 1 && isTransactionKey(expBaseKey) {
+			meta := &engine.MVCCMetadata{}
+			txn := &proto.Transaction{}
+			if err := gogoproto.Unmarshal(vals[0], meta); err != nil || meta.Value == nil {
+				log.Errorf("unable to unmarshal MVCC metadata for txn record %q: %v", keys[0], err)
+			} else if err := gogoproto.Unmarshal(meta.Value.Bytes, txn); err != nil {
+				log.Errorf("unable to unmarshal txn record %q: %s", keys[0], err)
+			} else if isAbandoned(txn, txnExp) {
+				id := string(txn.ID)
+				txnMap[id] = txn
+				txnKeyMap[id] = expBaseKey
+			}
+			return
+		}
 		// If there's more than a single value for the key, possibly send for GC.
 		if len(keys) > 1 {
 			meta := &engine.MVCCMetadata{}
@@ -168,11 +213,14 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 				startIdx := 1
 				if meta.Txn != nil {
 					// Keep track of intent to resolve if older than the intent
-					// expiration threshold.
+					// expiration threshold. Younger intents are resolved too if
+					// their transaction's record shows it has been abandoned.
+					id := string(meta.Txn.ID)
+					intentMap[id] = append(intentMap[id], proto.Intent{Key: expBaseKey})
 					if meta.Timestamp.Less(intentExp) {
-						id := string(meta.Txn.ID)
-						txnMap[id] = meta.Txn
-						intentMap[id] = append(intentMap[id], proto.Intent{Key: expBaseKey})
+						if _, ok := txnMap[id]; !ok {
+							txnMap[id] = meta.Txn
+						}
 					} else {
 						updateOldestIntent(meta.Txn.OrigTimestamp.WallTime)
 					}
@@ -180,7 +228,11 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 					startIdx = 2
 				}
 				// See if any values may be GC'd.
-				if gcTS := gc.Filter(keys[startIdx:], vals[startIdx:]); !gcTS.Equal(proto.ZeroTimestamp) {
+				gcTS := gc.Filter(keys[startIdx:], vals[startIdx:])
+				if tombstoneTS := gc.FilterTombstone(keys[startIdx:], vals[startIdx:]); gcTS.Less(tombstoneTS) {
+					gcTS = tombstoneTS
+				}
+				if !gcTS.Equal(proto.ZeroTimestamp) {
 					// TODO(spencer): need to split the requests up into
 					// multiple requests in the event that more than X keys
 					// are added to the request.
@@ -214,18 +266,18 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 	// Handle last collected set of keys/vals.
 	processKeysAndValues()
 
-	// Set start and end keys.
-	if len(gcArgs.Keys) == 0 {
-		return nil
-	}
-	gcArgs.Key = gcArgs.Keys[0].Key
-	gcArgs.EndKey = gcArgs.Keys[len(gcArgs.Keys)-1].Key.Next()
-
 	// Process push transactions in parallel.
 	var wg sync.WaitGroup
-	for _, txn := range txnMap {
+	for id, txn := range txnMap {
+		// Transactions found through their records are pushed with the
+		// lowest priority, so that the push succeeds only if they are
+		// still abandoned.
+		userPriority := int32(proto.MaxPriority)
+		if _, ok := txnKeyMap[id]; ok {
+			userPriority = -1
+		}
 		wg.Add(1)
-		go gcq.pushTxn(repl, now, txn, updateOldestIntent, &wg)
+		go gcq.pushTxn(repl, now, txn, userPriority, updateOldestIntent, &wg)
 	}
 	wg.Wait()
 
@@ -244,6 +296,34 @@ func (gcq *gcQueue) process(now proto.Timestamp, repl *Replica) error {
 		repl.resolveIntents(repl.context(), intents)
 	}
 
+	// Remove the records of abandoned transactions which are now aborted.
+	for id, txnKey := range txnKeyMap {
+		if txnMap[id].Status == proto.ABORTED {
+			gcArgs.TxnKeys = append(gcArgs.TxnKeys, txnKey)
+		}
+	}
+	sort.Sort(proto.KeySlice(gcArgs.TxnKeys))
+
+	// Set start and end keys to span both the GC'ed keys and the removed
+	// transaction records. Both are sorted.
+	if len(gcArgs.Keys) == 0 && len(gcArgs.TxnKeys) == 0 {
+		return nil
+	}
+	var first, last proto.Key
+	if len(gcArgs.Keys) > 0 {
+		first, last = gcArgs.Keys[0].Key, gcArgs.Keys[len(gcArgs.Keys)-1].Key
+	}
+	if l := len(gcArgs.TxnKeys); l > 0 {
+		if first == nil || gcArgs.TxnKeys[0].Less(first) {
+			first = gcArgs.TxnKeys[0]
+		}
+		if last == nil || last.Less(gcArgs.TxnKeys[l-1]) {
+			last = gcArgs.TxnKeys[l-1]
+		}
+	}
+	gcArgs.Key = first
+	gcArgs.EndKey = last.Next()
+
 	// Send GC request through range.
 	gcMeta.OldestIntentNanos = gogoproto.Int64(oldestIntentNanos)
 	gcArgs.GCMeta = *gcMeta
@@ -266,11 +346,12 @@ func (gcq *gcQueue) timer() time.Duration {
 	return gcQueueTimerDuration
 }
 
-// pushTxn attempts to abort the txn via push. If the transaction
-// cannot be aborted, the oldestIntentNanos value is atomically
-// updated to the min of oldestIntentNanos and the intent's
-// timestamp. The wait group is signaled on completion.
-func (gcq *gcQueue) pushTxn(repl *Replica, now proto.Timestamp, txn *proto.Transaction, updateOldestIntent func(int64), wg *sync.WaitGroup) {
+// pushTxn attempts to abort the txn via push with the supplied user
+// priority. If the transaction cannot be aborted, the oldestIntentNanos
+// value is atomically updated to the min of oldestIntentNanos and the
+// intent's timestamp. The wait group is signaled on completion.
+func (gcq *gcQueue) pushTxn(repl *Replica, now proto.Timestamp, txn *proto.Transaction, userPriority int32,
+	updateOldestIntent func(int64), wg *sync.WaitGroup) {
 	defer wg.Done() // signal wait group always on completion
 	if log.V(1) {
 		log.Infof("pushing txn %s ts=%s", txn, txn.OrigTimestamp)
@@ -281,7 +362,7 @@ func (gcq *gcQueue) pushTxn(repl *Replica, now proto.Timestamp, txn *proto.Trans
 		RequestHeader: proto.RequestHeader{
 			Timestamp:    now,
 			Key:          txn.Key,
-			UserPriority: gogoproto.Int32(proto.MaxPriority),
+			UserPriority: gogoproto.Int32(userPriority),
 			Txn:          nil,
 		},
 		Now:       now,
@@ -300,6 +381,32 @@ func (gcq *gcQueue) pushTxn(repl *Replica, now proto.Timestamp, txn *proto.Trans
 	*txn = *pushReply.PusheeTxn
 }
 
+// isTransactionKey returns whether the key is the key of a transaction
+// record.
+func isTransactionKey(key proto.Key) bool {
+	if !bytes.HasPrefix(key, keys.LocalRangePrefix) {
+		return false
+	}
+	_, suffix, _ := keys.DecodeRangeKey(key)
+	return suffix.Equal(keys.LocalTransactionSuffix)
+}
+
+// isAbandoned returns whether the transaction record shows that the
+// transaction's coordinator has given up on it: the transaction is not
+// committed and was last heartbeat before the supplied expiration. If
+// the record has never been heartbeat, the transaction's timestamp is
+// used instead, as is done by PushTxn.
+func isAbandoned(txn *proto.Transaction, expiration proto.Timestamp) bool {
+	if txn.Status == proto.COMMITTED {
+		return false
+	}
+	heartbeat := txn.Timestamp
+	if txn.LastHeartbeat != nil {
+		heartbeat = *txn.LastHeartbeat
+	}
+	return heartbeat.Less(expiration)
+}
+
 // lookupGCPolicy queries the gossip prefix config map based on the
 // supplied replica's start key. It queries all matching config prefixes
 // and then iterates from most specific to least, returning the first
@@ -18,6 +18,7 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"math"
 	"testing"
@@ -293,6 +294,115 @@ func TestGCQueueProcess(t *testing.T) {
 	}
 }
 
+// TestGCQueueTombstoneTTL verifies that deletion tombstones older than
+// the zone's tombstone TTL are removed even if the GC TTL would retain
+// them, unless they shadow values which historical reads may observe.
+func TestGCQueueTombstoneTTL(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	zoneConfig := config.ZoneConfig{
+		ReplicaAttrs:  []proto.Attributes{},
+		RangeMinBytes: 1 << 10,
+		RangeMaxBytes: 1 << 18,
+		GC: &config.GCPolicy{
+			// Old versions are never garbage collected otherwise.
+			TombstoneTTLSeconds: 60, // 1 minute
+		},
+	}
+	pcc, err := config.NewPrefixConfigMap([]config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &zoneConfig),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	if err := tc.rng.rm.Gossip().AddInfoProto(gossip.KeyConfigZone, pcc, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	const now int64 = 48 * 60 * 60 * 1E9 // 2d past the epoch
+	tc.manualClock.Set(now)
+	ts1 := makeTS(now-10*60*1E9, 0) // 10m old
+	ts2 := makeTS(now-5*60*1E9, 0)  // 5m old
+	ts3 := makeTS(now-30*1E9, 0)    // 30s old
+	key1 := proto.Key("a")
+	key2 := proto.Key("b")
+	key3 := proto.Key("c")
+
+	// Key1 is deleted twice, longer ago than the tombstone TTL, and key2
+	// more recently. Key3's value is deleted longer ago than the tombstone
+	// TTL, but remains visible to reads within the GC TTL.
+	for _, datum := range []struct {
+		key   proto.Key
+		ts    proto.Timestamp
+		isDel bool
+	}{
+		{key1, ts1, true},
+		{key1, ts2, true},
+		{key2, ts1, true},
+		{key2, ts3, true},
+		{key3, ts1, false},
+		{key3, ts2, true},
+	} {
+		if datum.isDel {
+			dArgs := deleteArgs(datum.key, tc.rng.Desc().RangeID, tc.store.StoreID())
+			dArgs.Timestamp = datum.ts
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &dArgs); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			pArgs := putArgs(datum.key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+			pArgs.Timestamp = datum.ts
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	gcQ := newGCQueue()
+	if err := gcQ.process(tc.clock.Now(), tc.rng); err != nil {
+		t.Fatal(err)
+	}
+
+	expKVs := []struct {
+		key proto.Key
+		ts  proto.Timestamp
+	}{
+		{key2, proto.ZeroTimestamp},
+		{key2, ts3},
+		{key2, ts1},
+		{key3, proto.ZeroTimestamp},
+		{key3, ts2},
+		{key3, ts1},
+	}
+	kvs, err := engine.Scan(tc.store.Engine(), engine.MVCCEncodeKey(key1), engine.MVCCEncodeKey(keys.TableDataPrefix), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != len(expKVs) {
+		t.Fatalf("expected length %d; got %d", len(expKVs), len(kvs))
+	}
+	for i, kv := range kvs {
+		key, ts, _ := engine.MVCCDecodeKey(kv.Key)
+		if !key.Equal(expKVs[i].key) || !ts.Equal(expKVs[i].ts) {
+			t.Errorf("%d: expected %q at %s; got %q at %s", i, expKVs[i].key, expKVs[i].ts, key, ts)
+		}
+	}
+
+	// A read between key3's write and deletion still observes the value.
+	gArgs := getArgs(key3, tc.rng.Desc().RangeID, tc.store.StoreID())
+	gArgs.Timestamp = makeTS(now-7*60*1E9, 0)
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gReply := reply.(*proto.GetResponse); gReply.Value == nil || !bytes.Equal(gReply.Value.Bytes, []byte("value")) {
+		t.Errorf("expected to read key3's value; got %+v", gReply.Value)
+	}
+}
+
 // TestGCQueueIntentResolution verifies intent resolution with many
 // intents spanning just two transactions.
 func TestGCQueueIntentResolution(t *testing.T) {
@@ -351,6 +461,86 @@ func TestGCQueueIntentResolution(t *testing.T) {
 	}
 }
 
+// TestGCQueueTransactionRecords verifies that the records of abandoned
+// transactions are removed after the transactions are aborted and their
+// intents resolved, while records of live or committed transactions are
+// left alone.
+func TestGCQueueTransactionRecords(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	const now int64 = 48 * 60 * 60 * 1E9 // 2d past the epoch
+	tc.manualClock.Set(now)
+	staleTS := makeTS(now-txnRecordAgeThreshold.Nanoseconds()-1, 0)
+	freshTS := makeTS(now-1, 0)
+
+	testCases := []struct {
+		status    proto.TransactionStatus
+		heartbeat proto.Timestamp
+		expGC     bool
+	}{
+		{proto.PENDING, staleTS, true},
+		{proto.ABORTED, staleTS, true},
+		{proto.COMMITTED, staleTS, false},
+		{proto.PENDING, freshTS, false},
+	}
+	var txns []*proto.Transaction
+	for i, test := range testCases {
+		key := proto.Key(fmt.Sprintf("%d", i))
+		txn := newTransaction(fmt.Sprintf("txn%d", i), key, 1, proto.SERIALIZABLE, tc.clock)
+		heartbeat := test.heartbeat
+		txn.Status = test.status
+		txn.OrigTimestamp = heartbeat
+		txn.Timestamp = heartbeat
+		txn.LastHeartbeat = &heartbeat
+		txns = append(txns, txn)
+		if txn.Status == proto.PENDING {
+			// Write an intent on the transaction's key.
+			pArgs := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+			pArgs.Timestamp = txn.Timestamp
+			pArgs.Txn = txn
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+				t.Fatalf("%d: could not put data: %s", i, err)
+			}
+		}
+		txnKey := keys.TransactionKey(txn.Key, txn.ID)
+		if err := engine.MVCCPutProto(tc.engine, nil, txnKey, proto.ZeroTimestamp, nil, txn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gcQ := newGCQueue()
+	if err := gcQ.process(tc.clock.Now(), tc.rng); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, test := range testCases {
+		txn := txns[i]
+		txnKey := keys.TransactionKey(txn.Key, txn.ID)
+		ok, err := engine.MVCCGetProto(tc.engine, txnKey, proto.ZeroTimestamp, true, nil, &proto.Transaction{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok == test.expGC {
+			t.Errorf("%d: expected record GC'ed=%t; record exists=%t", i, test.expGC, ok)
+		}
+		if test.status != proto.PENDING {
+			continue
+		}
+		// The intent of the aborted transaction must be gone, the live
+		// transaction's intent must remain.
+		_, intents, err := engine.MVCCGet(tc.engine, txn.Key, proto.MaxTimestamp, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasIntent := len(intents) > 0; hasIntent == test.expGC {
+			t.Errorf("%d: expected intent resolved=%t; has intent=%t", i, test.expGC, hasIntent)
+		}
+	}
+}
+
 // TestGCQueueLookupGCPolicy verifies the hierarchical lookup of GC
 // policy in the event that the longest matching key prefix does not
 // have a zone configured.
@@ -0,0 +1,149 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License. See the AUTHORS file
+// for names of contributors.
+
+package storage
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cockroach/proto"
+)
+
+const (
+	// hotKeySketchDepth and hotKeySketchWidth are the dimensions of the
+	// count-min sketch used to estimate access counts.
+	hotKeySketchDepth = 4
+	hotKeySketchWidth = 256
+	// hotKeyCount is the number of hot keys tracked per replica.
+	hotKeyCount = 8
+	// hotKeyWindow is the interval after which all access counts are
+	// halved, so that the counts reflect recent accesses.
+	hotKeyWindow = 1 * time.Minute
+)
+
+// A HotKey is a key or key span which was accessed frequently.
+type HotKey struct {
+	Key    proto.Key
+	EndKey proto.Key
+	// Count is the approximate number of accesses, with accesses
+	// decaying by half every hotKeyWindow.
+	Count uint32
+}
+
+// hotKeyTracker maintains an approximate top-K of the most accessed
+// keys and key spans. Access counts are estimated by a count-min sketch
+// of fixed size, so memory use is bounded regardless of the number of
+// distinct keys. hotKeyTracker is safe for concurrent use.
+type hotKeyTracker struct {
+	sync.Mutex
+	sketch    [hotKeySketchDepth][hotKeySketchWidth]uint32
+	top       []HotKey // Sorted by descending count
+	lastDecay int64    // Time of the last decay in unix nanos
+}
+
+// record registers an access to the given key or key span at the
+// given time in unix nanos.
+func (t *hotKeyTracker) record(key, endKey proto.Key, now int64) {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(endKey)
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)
+
+	t.Lock()
+	defer t.Unlock()
+	t.maybeDecay(now)
+	var count uint32
+	for i := range t.sketch {
+		idx := (h1 + uint32(i)*h2) % hotKeySketchWidth
+		t.sketch[i][idx]++
+		if c := t.sketch[i][idx]; i == 0 || c < count {
+			count = c
+		}
+	}
+
+	pos := -1
+	for i := range t.top {
+		if bytes.Equal(t.top[i].Key, key) && bytes.Equal(t.top[i].EndKey, endKey) {
+			pos = i
+			break
+		}
+	}
+	switch {
+	case pos >= 0:
+		t.top[pos].Count = count
+	case len(t.top) < hotKeyCount:
+		t.top = append(t.top, HotKey{Key: append(proto.Key(nil), key...),
+			EndKey: append(proto.Key(nil), endKey...), Count: count})
+		pos = len(t.top) - 1
+	case t.top[len(t.top)-1].Count < count:
+		pos = len(t.top) - 1
+		t.top[pos] = HotKey{Key: append(proto.Key(nil), key...),
+			EndKey: append(proto.Key(nil), endKey...), Count: count}
+	default:
+		return
+	}
+	// Restore the ordering by moving the updated entry forward.
+	for ; pos > 0 && t.top[pos-1].Count < t.top[pos].Count; pos-- {
+		t.top[pos-1], t.top[pos] = t.top[pos], t.top[pos-1]
+	}
+}
+
+// maybeDecay halves all counts for every hotKeyWindow which has passed
+// since the last decay. Entries whose count drops to zero are removed.
+func (t *hotKeyTracker) maybeDecay(now int64) {
+	windows := (now - t.lastDecay) / int64(hotKeyWindow)
+	if windows <= 0 {
+		return
+	}
+	t.lastDecay += windows * int64(hotKeyWindow)
+	shift := uint(32)
+	if windows < 32 {
+		shift = uint(windows)
+	}
+	for i := range t.sketch {
+		for j := range t.sketch[i] {
+			t.sketch[i][j] = uint32(uint64(t.sketch[i][j]) >> shift)
+		}
+	}
+	top := t.top[:0]
+	for _, hk := range t.top {
+		if hk.Count = uint32(uint64(hk.Count) >> shift); hk.Count > 0 {
+			top = append(top, hk)
+		}
+	}
+	t.top = top
+}
+
+// get returns a copy of the hot keys, hottest first.
+func (t *hotKeyTracker) get() []HotKey {
+	t.Lock()
+	defer t.Unlock()
+	hotKeys := append([]HotKey(nil), t.top...)
+	sort.Stable(hotKeysByCount(hotKeys))
+	return hotKeys
+}
+
+// hotKeysByCount sorts hot keys by descending count.
+type hotKeysByCount []HotKey
+
+func (h hotKeysByCount) Len() int           { return len(h) }
+func (h hotKeysByCount) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h hotKeysByCount) Less(i, j int) bool { return h[i].Count > h[j].Count }
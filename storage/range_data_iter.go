@@ -18,6 +18,8 @@
 package storage
 
 import (
+	"bytes"
+
 	"github.com/cockroachdb/cockroach/keys"
 	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/storage/engine"
@@ -44,6 +46,18 @@ type rangeDataIterator struct {
 }
 
 func newRangeDataIterator(d *proto.RangeDescriptor, e engine.Engine) *rangeDataIterator {
+	ri := &rangeDataIterator{
+		ranges: makeRangeKeyRanges(d),
+		iter:   e.NewIterator(),
+	}
+	ri.iter.Seek(ri.ranges[ri.curIndex].start)
+	ri.advance()
+	return ri
+}
+
+// makeRangeKeyRanges returns the key ranges which comprise all of the
+// data of the given range.
+func makeRangeKeyRanges(d *proto.RangeDescriptor) []keyRange {
 	// The first range in the keyspace starts at KeyMin, which includes the node-local
 	// space. We need the original StartKey to find the range metadata, but the
 	// actual data starts at LocalMax.
@@ -51,26 +65,31 @@ func newRangeDataIterator(d *proto.RangeDescriptor, e engine.Engine) *rangeDataI
 	if d.StartKey.Equal(proto.KeyMin) {
 		dataStartKey = keys.LocalMax
 	}
-	ri := &rangeDataIterator{
-		ranges: []keyRange{
-			{
-				start: engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangeIDPrefix, encoding.EncodeUvarint(nil, uint64(d.RangeID)))),
-				end:   engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangeIDPrefix, encoding.EncodeUvarint(nil, uint64(d.RangeID+1)))),
-			},
-			{
-				start: engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangePrefix, encoding.EncodeBytes(nil, d.StartKey))),
-				end:   engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangePrefix, encoding.EncodeBytes(nil, d.EndKey))),
-			},
-			{
-				start: engine.MVCCEncodeKey(dataStartKey),
-				end:   engine.MVCCEncodeKey(d.EndKey),
-			},
+	return []keyRange{
+		{
+			start: engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangeIDPrefix, encoding.EncodeUvarint(nil, uint64(d.RangeID)))),
+			end:   engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangeIDPrefix, encoding.EncodeUvarint(nil, uint64(d.RangeID+1)))),
+		},
+		{
+			start: engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangePrefix, encoding.EncodeBytes(nil, d.StartKey))),
+			end:   engine.MVCCEncodeKey(keys.MakeKey(keys.LocalRangePrefix, encoding.EncodeBytes(nil, d.EndKey))),
+		},
+		{
+			start: engine.MVCCEncodeKey(dataStartKey),
+			end:   engine.MVCCEncodeKey(d.EndKey),
 		},
-		iter: e.NewIterator(),
 	}
-	ri.iter.Seek(ri.ranges[ri.curIndex].start)
-	ri.advance()
-	return ri
+}
+
+// rangeDataContainsKey returns whether the given encoded key is part of
+// the data of the given range.
+func rangeDataContainsKey(d *proto.RangeDescriptor, key proto.EncodedKey) bool {
+	for _, kr := range makeRangeKeyRanges(d) {
+		if bytes.Compare(kr.start, key) <= 0 && bytes.Compare(key, kr.end) < 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // Close closes the underlying iterator.
@@ -25,9 +25,13 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"math/rand"
+	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 	"unsafe"
 
@@ -40,10 +44,13 @@ import (
 	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/storage/engine"
 	"github.com/cockroachdb/cockroach/util"
+	"github.com/cockroachdb/cockroach/util/encoding"
 	"github.com/cockroachdb/cockroach/util/hlc"
 	"github.com/cockroachdb/cockroach/util/log"
+	"github.com/cockroachdb/cockroach/util/retry"
 	"github.com/cockroachdb/cockroach/util/stop"
 	"github.com/cockroachdb/cockroach/util/tracer"
+	"github.com/coreos/etcd/raft"
 	gogoproto "github.com/gogo/protobuf/proto"
 )
 
@@ -55,6 +62,10 @@ const (
 	// it may be aborted by conflicting txns.
 	DefaultHeartbeatInterval = 5 * time.Second
 
+	// DefaultMaxValueBytes is the maximum size of a value written to a
+	// range whose zone config doesn't specify one.
+	DefaultMaxValueBytes = 8 << 20 // 8M
+
 	// clusterIDGossipTTL is time-to-live for cluster ID. The cluster ID
 	// serves as the sentinel gossip key which informs a node whether or
 	// not it's connected to the primary gossip network and not just a
@@ -65,8 +76,13 @@ const (
 	// clusterIDGossipInterval is the approximate interval at which the
 	// sentinel info is gossiped.
 	clusterIDGossipInterval = clusterIDGossipTTL / 2
+	// firstRangeGossipMinInterval is the minimum age of the gossiped
+	// first range descriptor before an unchanged descriptor is gossiped
+	// again. It is shorter than clusterIDGossipInterval so that the
+	// periodic gossip by the lease holder isn't skipped due to jitter.
+	firstRangeGossipMinInterval = clusterIDGossipInterval / 2
 
-	// configGossipTTL is the time-to-live for configuration maps.
+	// configGossipTTL is the default time-to-live for configuration maps.
 	configGossipTTL = 0 // does not expire
 	// configGossipInterval is the interval at which range leaders gossip
 	// their config maps. Even if config maps do not expire, we still
@@ -100,20 +116,100 @@ const (
 
 	// DefaultLeaderLeaseDuration is the default duration of the leader lease.
 	DefaultLeaderLeaseDuration = time.Second
+
+	// maxTransferredTSCacheEntries is the maximum number of timestamp cache
+	// entries handed to the new holder on a leader lease transfer. Older
+	// entries are folded into the transferred low water mark.
+	maxTransferredTSCacheEntries = 1000
+
+	// maxLeaseAcquisitionDelay bounds the randomized delay a replica
+	// waits before requesting a leader lease; see leaseAcquisitionDelay.
+	maxLeaseAcquisitionDelay = 20 * time.Millisecond
+
+	// leasePreferenceDelay is the additional delay a replica waits before
+	// requesting a leader lease for every place it ranks below another
+	// replica in the zone's lease preferences, giving replicas in more
+	// preferred localities the chance to acquire it first; see
+	// leasePreferenceLag.
+	leasePreferenceDelay = 100 * time.Millisecond
+
+	// maxWriteTooOldRetries is the number of times a non-transactional
+	// write which fails with a WriteTooOldError is retried at a higher
+	// timestamp before the error is returned to the client.
+	maxWriteTooOldRetries = 3
+
+	// resolveIntentBatchMaxCount and resolveIntentBatchMaxBytes bound the
+	// batches in which resolveIntents sends intent resolutions to other
+	// ranges, so that resolving the intents of a large transaction does
+	// not result in oversized Raft commands.
+	resolveIntentBatchMaxCount = 100
+	resolveIntentBatchMaxBytes = 256 << 10
+
+	// maxDeleteRangeReturnedKeys bounds the number of keys deleted by a
+	// DeleteRange request which asks for the deleted keys to be
+	// returned, so that the response stays reasonably small.
+	maxDeleteRangeReturnedKeys = 10000
+
+	// maxConcurrentBatchReads is the maximum number of requests of a
+	// read-only batch which are executed concurrently.
+	maxConcurrentBatchReads = 8
 )
 
+// leaderLeaseRetryOptions configures the backoff between successive
+// failed attempts of a replica to acquire the leader lease. This keeps
+// a replica which keeps losing a contended lease from flooding Raft
+// with lease requests.
+var leaderLeaseRetryOptions = retry.Options{
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     DefaultLeaderLeaseDuration,
+	Multiplier:     2,
+}
+
+// commitRetryOptions configures the backoff between attempts to commit
+// the batch of an applied command after transient engine errors.
+var commitRetryOptions = retry.Options{
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+}
+
+// quorumAckRetryOptions configures the backoff between checks of the
+// follower progress while a write waits for a replica in the locality
+// required by its zone.
+var quorumAckRetryOptions = retry.Options{
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     100 * time.Millisecond,
+	Multiplier:     2,
+}
+
+// mergeIntentRetryOptions configures the backoff between attempts to
+// resolve the intents on a range which is about to be subsumed.
+var mergeIntentRetryOptions = retry.Options{
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+	Multiplier:     2,
+}
+
+// transientEngineErrnos are the system errors which indicate an engine
+// failure that may clear up by itself.
+var transientEngineErrnos = []syscall.Errno{syscall.ENOSPC, syscall.EAGAIN, syscall.EINTR, syscall.EBUSY}
+
 // configDescriptor describes administrative configuration maps
 // affecting ranges of the key-value map by key prefix.
 type configDescriptor struct {
 	keyPrefix proto.Key         // Range key prefix
 	gossipKey string            // Gossip key
 	configI   gogoproto.Message // Config struct interface
+	// Gossip time-to-live; zero if the config map never expires. A
+	// finite TTL must exceed twice configGossipInterval since the map
+	// is re-gossiped only once half of its TTL has passed.
+	ttl time.Duration
 }
 
 // configDescriptors is an array containing the
 // zone configuration descriptors.
 var configDescriptors = [...]*configDescriptor{
-	{keys.ConfigZonePrefix, gossip.KeyConfigZone, &config.ZoneConfig{}},
+	{keys.ConfigZonePrefix, gossip.KeyConfigZone, &config.ZoneConfig{}, configGossipTTL},
 }
 
 // tsCacheMethods specifies the set of methods which affect the
@@ -122,7 +218,9 @@ var tsCacheMethods = [...]bool{
 	proto.Get:                true,
 	proto.Put:                true,
 	proto.ConditionalPut:     true,
+	proto.CheckAndPut:        true,
 	proto.Increment:          true,
+	proto.MultiIncrement:     true,
 	proto.Scan:               true,
 	proto.Delete:             true,
 	proto.DeleteRange:        true,
@@ -140,12 +238,40 @@ func usesTimestampCache(r proto.Request) bool {
 	return tsCacheMethods[m]
 }
 
+// closedTimestampMethods specifies the set of methods which write new
+// versioned values at their request timestamp and must therefore not
+// be applied at or below the closed timestamp of the leader lease.
+// Intent resolution is deliberately absent: intents written before a
+// timestamp was closed can still be resolved.
+var closedTimestampMethods = [...]bool{
+	proto.Put:            true,
+	proto.ConditionalPut: true,
+	proto.CheckAndPut:    true,
+	proto.Increment:      true,
+	proto.MultiIncrement: true,
+	proto.Delete:         true,
+	proto.DeleteRange:    true,
+}
+
+// violatesClosedTimestamp returns true if the request would write a
+// value at or below the supplied closed timestamp.
+func violatesClosedTimestamp(r proto.Request, closed proto.Timestamp) bool {
+	m := r.Method()
+	if m < 0 || m >= proto.Method(len(closedTimestampMethods)) || !closedTimestampMethods[m] {
+		return false
+	}
+	return !closed.Less(r.Header().Timestamp)
+}
+
 // A pendingCmd holds a done channel for a command sent to Raft. Once
 // committed to the Raft log, the command is executed and the result returned
 // via the done channel.
 type pendingCmd struct {
-	ctx  context.Context
-	done chan proto.ResponseWithError // Used to signal waiting RPC handler
+	ctx        context.Context
+	done       chan proto.ResponseWithError // Used to signal waiting RPC handler
+	proposedAt int64                        // Physical time of the proposal in unix nanos
+	index      uint64                       // Raft log index; set before signaling done
+	idKey      cmdIDKey                     // Key in the replica's pendingCmds
 }
 
 // A rangeManager is an interface satisfied by Store through which ranges
@@ -158,12 +284,26 @@ type rangeManager interface {
 	Clock() *hlc.Clock
 	Engine() engine.Engine
 	DB() *client.DB
+	RaftStatus(proto.RangeID) *raft.Status
 	allocator() allocator
 	Gossip() *gossip.Gossip
 	splitQueue() *splitQueue
 	rangeGCQueue() *rangeGCQueue
 	Stopper() *stop.Stopper
 	EventFeed() StoreEventFeed
+	applyHook() ApplyHook
+	tsCacheExcludedPrefixes() []proto.Key
+	maxIntentsPerRead() int
+	maxTxnIntents() int64
+	maxBatchRequests() int
+	maxBatchBytes() int64
+	maxInflightProposals() int
+	commitGracePeriod() time.Duration
+	mergeIntentTimeout() time.Duration
+	quorumAckTimeout() time.Duration
+	verifyResponseCache() bool
+	hardenedMode() bool
+	compressSnapshotKeys() bool
 	Context(context.Context) context.Context
 	resolveWriteIntentError(context.Context, *proto.WriteIntentError, *Replica, proto.Request, proto.PushTxnType) error
 
@@ -172,6 +312,8 @@ type rangeManager interface {
 	MergeRange(subsumingRng *Replica, updatedEndKey proto.Key, subsumedRangeID proto.RangeID) error
 	NewRangeDescriptor(start, end proto.Key, replicas []proto.Replica) (*proto.RangeDescriptor, error)
 	NewSnapshot() engine.Engine
+	compactRange(start, end proto.EncodedKey) error
+	runAsyncIntentResolution(action func()) bool
 	ProposeRaftCommand(cmdIDKey, proto.RaftCommand) <-chan error
 	RemoveReplica(rng *Replica) error
 	Tracer() *tracer.Tracer
@@ -179,6 +321,173 @@ type rangeManager interface {
 	processRangeDescriptorUpdate(rng *Replica) error
 }
 
+// LeaseEventType describes what happened to a leader lease.
+type LeaseEventType int
+
+const (
+	// LeaseAcquired is recorded when a replica obtains a lease which was
+	// previously held by another replica (or by none).
+	LeaseAcquired LeaseEventType = iota
+	// LeaseExtended is recorded when the holder of a lease renews it.
+	LeaseExtended
+	// LeaseTransferred is recorded when the previous holder handed the
+	// lease over to another replica.
+	LeaseTransferred
+	// LeaseRejected is recorded when this replica's request for the
+	// lease was rejected in favor of an existing lease.
+	LeaseRejected
+)
+
+func (t LeaseEventType) String() string {
+	switch t {
+	case LeaseAcquired:
+		return "acquired"
+	case LeaseExtended:
+		return "extended"
+	case LeaseTransferred:
+		return "transferred"
+	case LeaseRejected:
+		return "rejected"
+	}
+	return fmt.Sprintf("LeaseEventType(%d)", int(t))
+}
+
+// A LeaseEvent is an entry in a replica's lease history.
+type LeaseEvent struct {
+	Type LeaseEventType
+	// Timestamp is the time of the local clock at which the event
+	// was recorded.
+	Timestamp proto.Timestamp
+	// Lease is the new lease or, for rejections, the existing lease
+	// which caused the request to be rejected.
+	Lease proto.Lease
+}
+
+// leaseHistorySize is the number of most recent lease events retained
+// by each replica.
+const leaseHistorySize = 16
+
+// leaseHistory is a ring buffer of the most recent lease events.
+type leaseHistory struct {
+	sync.Mutex
+	events [leaseHistorySize]LeaseEvent
+	next   int // Index at which the next event is stored
+	count  int // Number of valid events
+}
+
+// add records the event, evicting the oldest one if the buffer is full.
+func (h *leaseHistory) add(e LeaseEvent) {
+	h.Lock()
+	defer h.Unlock()
+	h.events[h.next] = e
+	h.next = (h.next + 1) % leaseHistorySize
+	if h.count < leaseHistorySize {
+		h.count++
+	}
+}
+
+// get returns a copy of the recorded events, oldest first.
+func (h *leaseHistory) get() []LeaseEvent {
+	h.Lock()
+	defer h.Unlock()
+	events := make([]LeaseEvent, 0, h.count)
+	for i := h.count; i > 0; i-- {
+		events = append(events, h.events[(h.next-i+leaseHistorySize)%leaseHistorySize])
+	}
+	return events
+}
+
+// LatencyStats summarizes a series of latency samples.
+type LatencyStats struct {
+	// Count is the number of samples.
+	Count int64
+	// Total, Max and Last are the sum, the maximum and the most recent
+	// of the samples.
+	Total, Max, Last time.Duration
+}
+
+// Mean returns the average of the samples, or zero if there are none.
+func (s LatencyStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// latencyTracker accumulates LatencyStats. It is safe for concurrent use.
+type latencyTracker struct {
+	sync.Mutex
+	stats LatencyStats
+}
+
+// record adds a sample. Negative samples, which are caused by the clock
+// moving backwards, are recorded as zero.
+func (t *latencyTracker) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.stats.Count++
+	t.stats.Total += d
+	t.stats.Last = d
+	if d > t.stats.Max {
+		t.stats.Max = d
+	}
+}
+
+// get returns the accumulated stats.
+func (t *latencyTracker) get() LatencyStats {
+	t.Lock()
+	defer t.Unlock()
+	return t.stats
+}
+
+// qpsInterval is the interval over which qpsTracker measures the rate
+// of commands.
+const qpsInterval = 10 * time.Second
+
+// qpsTracker measures the rate of commands over consecutive intervals
+// of qpsInterval. It is safe for concurrent use.
+type qpsTracker struct {
+	sync.Mutex
+	start int64   // Start of the current interval in unix nanos
+	count int64   // Number of commands in the current interval
+	rate  float64 // Commands per second in the last complete interval
+}
+
+// record counts a command at the given time in unix nanos.
+func (t *qpsTracker) record(now int64) {
+	t.Lock()
+	defer t.Unlock()
+	t.maybeRoll(now)
+	t.count++
+}
+
+// get returns the rate of commands, in commands per second, over the
+// last complete interval before the given time in unix nanos.
+func (t *qpsTracker) get(now int64) float64 {
+	t.Lock()
+	defer t.Unlock()
+	t.maybeRoll(now)
+	return t.rate
+}
+
+// maybeRoll starts a new interval if the current one has ended. If it
+// ended more than an interval ago, the last complete interval saw no
+// commands at all.
+func (t *qpsTracker) maybeRoll(now int64) {
+	elapsed := now - t.start
+	if elapsed < int64(qpsInterval) {
+		return
+	}
+	t.rate = 0
+	if elapsed < 2*int64(qpsInterval) {
+		t.rate = float64(t.count) / time.Duration(elapsed).Seconds()
+	}
+	t.start, t.count = now, 0
+}
+
 // A Replica is a contiguous keyspace with writes managed via an
 // instance of the Raft consensus algorithm. Many ranges may exist
 // in a store and they are unlikely to be contiguous. Ranges are
@@ -190,6 +499,8 @@ type Replica struct {
 	rm       rangeManager   // Makes some store methods available
 	stats    *rangeStats    // Range statistics
 	maxBytes int64          // Max bytes before split.
+	// Max size of a written value; zero for the default. Updated atomically.
+	maxValueBytes int64
 	// Last index persisted to the raft log (not necessarily committed).
 	// Updated atomically.
 	lastIndex uint64
@@ -199,12 +510,51 @@ type Replica struct {
 	systemDBHash []byte         // sha256 hash of the system config @ last gossip
 	lease        unsafe.Pointer // Information for leader lease, updated atomically
 	llMu         sync.Mutex     // Synchronizes readers' requests for leader lease
+	leaseRetry   retry.Retry    // Backoff for failed lease requests; protected by llMu
 	respCache    *ResponseCache // Provides idempotence for retries
+	// Non-zero if the response cache is disabled by the range's zone.
+	// Updated atomically.
+	respCacheDisabled int32
+	// Non-zero if values written to the range are checksummed, as set by
+	// the range's zone. Updated atomically.
+	valueChecksums int32
+	// Node attributes preferred for the leader lease by the range's zone.
+	// Updated atomically.
+	leasePreferences unsafe.Pointer // *[]proto.Attributes
+	// Node attributes of the locality required to persist writes by the
+	// range's zone. Updated atomically.
+	quorumAttrs unsafe.Pointer // *proto.Attributes
+	// Target of an ongoing leader lease transfer, or zero; protected by llMu.
+	leaseTransferTarget proto.RaftNodeID
+	// Recent lease events for debugging; see LeaseHistory.
+	leaseHistory leaseHistory
+	// Non-zero once the replica has encountered corruption. Updated
+	// atomically.
+	corrupt int32
+	// Most frequently accessed keys; see HotKeys.
+	hotKeys hotKeyTracker
+	// Rate of commands; see MetricsSnapshot.
+	qps qpsTracker
+	// Time from proposal to application of local commands; see
+	// ReplicationLatency.
+	replLatency latencyTracker
+	// Writes pushed by the timestamp cache and WriteTooOldErrors
+	// encountered; see ContentionStats. Updated atomically.
+	tsCachePushes, writeTooOldErrors int64
+	// Number of writes whose response diverged when executed again in
+	// response cache verification mode. Updated atomically.
+	respCacheDivergences int64
+	// Summary of the last applied command; see LastApplied. Updated
+	// atomically.
+	lastApplied unsafe.Pointer // *AppliedCommandInfo
 
 	sync.RWMutex                 // Protects the following fields:
 	cmdQ         *CommandQueue   // Enforce at most one command is running per key(s)
 	tsCache      *TimestampCache // Most recent timestamps for keys / key ranges
 	pendingCmds  map[cmdIDKey]*pendingCmd
+	writesPaused bool // Reject new writes; see PauseWrites
+	// Closed and replaced whenever an updated config map is gossiped.
+	configGossiped chan struct{}
 }
 
 // NewReplica initializes the replica using the given metadata.
@@ -215,9 +565,15 @@ func NewReplica(desc *proto.RangeDescriptor, rm rangeManager) (*Replica, error)
 		tsCache:     NewTimestampCache(rm.Clock()),
 		respCache:   NewResponseCache(desc.RangeID),
 		pendingCmds: map[cmdIDKey]*pendingCmd{},
+
+		configGossiped: make(chan struct{}),
 	}
 	r.setDescWithoutProcessUpdate(desc)
 
+	leaseRetryOpts := leaderLeaseRetryOptions
+	leaseRetryOpts.Stopper = rm.Stopper()
+	r.leaseRetry = retry.Start(leaseRetryOpts)
+
 	lastIndex, err := r.loadLastIndex()
 	if err != nil {
 		return nil, err
@@ -289,6 +645,197 @@ func (r *Replica) SetMaxBytes(maxBytes int64) {
 	atomic.StoreInt64(&r.maxBytes, maxBytes)
 }
 
+// updateMaxBytes sets the maximum byte limit before split in response
+// to a zone config update. If the limit shrank, the range is promptly
+// checked against it and added to the split queue if now oversized,
+// instead of waiting for the next scanner pass to notice.
+func (r *Replica) updateMaxBytes(maxBytes int64) {
+	prevMaxBytes := r.GetMaxBytes()
+	r.SetMaxBytes(maxBytes)
+	if maxBytes < prevMaxBytes {
+		r.maybeAddToSplitQueue()
+	}
+}
+
+// GetMaxValueBytes atomically gets the maximum size of a value written
+// to the range, falling back to DefaultMaxValueBytes if the range's zone
+// does not specify one.
+func (r *Replica) GetMaxValueBytes() int64 {
+	if maxValueBytes := atomic.LoadInt64(&r.maxValueBytes); maxValueBytes > 0 {
+		return maxValueBytes
+	}
+	return DefaultMaxValueBytes
+}
+
+// SetMaxValueBytes atomically sets the maximum size of a value written
+// to the range. Zero selects DefaultMaxValueBytes.
+func (r *Replica) SetMaxValueBytes(maxValueBytes int64) {
+	atomic.StoreInt64(&r.maxValueBytes, maxValueBytes)
+}
+
+// SetResponseCacheDisabled atomically sets whether write commands
+// proposed by this replica bypass the response cache when applied. See
+// config.ZoneConfig.DisableResponseCache for the implications.
+func (r *Replica) SetResponseCacheDisabled(disabled bool) {
+	var v int32
+	if disabled {
+		v = 1
+	}
+	atomic.StoreInt32(&r.respCacheDisabled, v)
+}
+
+// SetValueChecksums atomically sets whether values written to the range
+// are checksummed. See config.ZoneConfig.ValueChecksums.
+func (r *Replica) SetValueChecksums(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&r.valueChecksums, v)
+}
+
+// valueChecksumsEnabled returns whether values written to the range are
+// checksummed.
+func (r *Replica) valueChecksumsEnabled() bool {
+	return atomic.LoadInt32(&r.valueChecksums) != 0
+}
+
+// SetQuorumAttrs atomically sets the node attributes of the locality in
+// which a replica must persist each write before it is acknowledged. See
+// config.ZoneConfig.QuorumAttrs.
+func (r *Replica) SetQuorumAttrs(attrs proto.Attributes) {
+	atomic.StorePointer(&r.quorumAttrs, unsafe.Pointer(&attrs))
+}
+
+// getQuorumAttrs returns the node attributes of the locality required
+// to persist writes, which are empty if there's no such requirement.
+func (r *Replica) getQuorumAttrs() proto.Attributes {
+	if attrs := (*proto.Attributes)(atomic.LoadPointer(&r.quorumAttrs)); attrs != nil {
+		return *attrs
+	}
+	return proto.Attributes{}
+}
+
+// SetLeasePreference atomically sets the node attributes preferred for
+// the leader lease of the range. See config.ZoneConfig.LeasePreference.
+func (r *Replica) SetLeasePreference(attrs proto.Attributes) {
+	r.SetLeasePreferences([]proto.Attributes{attrs})
+}
+
+// SetLeasePreferences atomically sets the node attributes preferred for
+// the leader lease of the range, in decreasing order of preference. See
+// config.ZoneConfig.LeasePreferences.
+func (r *Replica) SetLeasePreferences(prefs []proto.Attributes) {
+	atomic.StorePointer(&r.leasePreferences, unsafe.Pointer(&prefs))
+}
+
+// getLeasePreferences returns the node attributes preferred for the
+// leader lease of the range, in decreasing order of preference.
+func (r *Replica) getLeasePreferences() []proto.Attributes {
+	if prefs := (*[]proto.Attributes)(atomic.LoadPointer(&r.leasePreferences)); prefs != nil {
+		return *prefs
+	}
+	return nil
+}
+
+// zoneLeasePreferences returns the lease preferences of the zone in
+// decreasing order of preference: LeasePreference, if set, followed by
+// LeasePreferences.
+func zoneLeasePreferences(zone *config.ZoneConfig) []proto.Attributes {
+	var prefs []proto.Attributes
+	if len(zone.LeasePreference.Attrs) > 0 {
+		prefs = append(prefs, zone.LeasePreference)
+	}
+	return append(prefs, zone.LeasePreferences...)
+}
+
+// responseCacheDisabled returns whether the response cache is disabled
+// for this range.
+func (r *Replica) responseCacheDisabled() bool {
+	return atomic.LoadInt32(&r.respCacheDisabled) != 0
+}
+
+// checkValueSize returns a ValueTooLargeError if the command is a put
+// whose value exceeds the range's maximum value size. System keys are
+// exempt.
+func (r *Replica) checkValueSize(args proto.Request) error {
+	var value *proto.Value
+	switch tArgs := args.(type) {
+	case *proto.PutRequest:
+		value = &tArgs.Value
+	case *proto.ConditionalPutRequest:
+		value = &tArgs.Value
+	case *proto.CheckAndPutRequest:
+		value = &tArgs.Value
+	default:
+		return nil
+	}
+	key := args.Header().Key
+	if key.Less(keys.SystemMax) {
+		return nil
+	}
+	if size, maxSize := int64(len(value.Bytes)), r.GetMaxValueBytes(); size > maxSize {
+		return &proto.ValueTooLargeError{Key: key, ValueBytes: size, MaxBytes: maxSize}
+	}
+	return nil
+}
+
+// checkTxnIntents returns a TxnTooLargeError if the command is a
+// transactional write of a transaction which has already written the
+// maximum number of intents, or commits a transaction which has written
+// more than that. The count is the one carried by the transaction in the
+// request, which its coordinator keeps and records in the transaction
+// record. Like the value size, the limit is checked before the command
+// is proposed, as replicas may not agree on it.
+func (r *Replica) checkTxnIntents(args proto.Request) error {
+	txn := args.Header().Txn
+	maxIntents := r.rm.maxTxnIntents()
+	if txn == nil || maxIntents < 0 {
+		return nil
+	}
+	exceeded := proto.IsTransactionWrite(args) && txn.IntentCount >= maxIntents
+	if et, ok := args.(*proto.EndTransactionRequest); ok && et.Commit {
+		exceeded = txn.IntentCount > maxIntents
+	}
+	if exceeded {
+		return &proto.TxnTooLargeError{IntentCount: txn.IntentCount, MaxIntents: maxIntents}
+	}
+	return nil
+}
+
+// verifyValueChecksum returns a ChecksumError if the command is a put
+// whose value carries a checksum which doesn't match its contents.
+// Such values must never be written: a mismatch found when reading a
+// stored value is taken to be corruption of the replica.
+func (r *Replica) verifyValueChecksum(args proto.Request) error {
+	switch tArgs := args.(type) {
+	case *proto.PutRequest:
+		return tArgs.Value.Verify(tArgs.Key)
+	case *proto.ConditionalPutRequest:
+		return tArgs.Value.Verify(tArgs.Key)
+	case *proto.CheckAndPutRequest:
+		return tArgs.Value.Verify(tArgs.Key)
+	}
+	return nil
+}
+
+// maybeChecksumValue sets the checksum of the value written by a put if
+// the range's zone asks for value checksums and the value doesn't carry
+// a checksum already.
+func (r *Replica) maybeChecksumValue(args proto.Request) {
+	if !r.valueChecksumsEnabled() {
+		return
+	}
+	switch tArgs := args.(type) {
+	case *proto.PutRequest:
+		tArgs.Value.InitChecksum(tArgs.Key)
+	case *proto.ConditionalPutRequest:
+		tArgs.Value.InitChecksum(tArgs.Key)
+	case *proto.CheckAndPutRequest:
+		tArgs.Value.InitChecksum(tArgs.Key)
+	}
+}
+
 // IsFirstRange returns true if this is the first range.
 func (r *Replica) IsFirstRange() bool {
 	return bytes.Equal(r.Desc().StartKey, proto.KeyMin)
@@ -307,6 +854,44 @@ func (r *Replica) getLease() *proto.Lease {
 	return (*proto.Lease)(atomic.LoadPointer(&r.lease))
 }
 
+// LeaseHolderHint returns the replica holding the leader lease of the
+// range as last known to this replica, and whether that lease is
+// currently valid. An expired lease still yields its holder as a hint,
+// since the holder is likely to renew it. No lease is requested. The
+// zero Replica is returned if the holder isn't part of the range.
+func (r *Replica) LeaseHolderHint() (proto.Replica, bool) {
+	lease := r.getLease()
+	_, storeID := proto.DecodeRaftNodeID(lease.RaftNodeID)
+	_, holder := r.Desc().FindReplica(storeID)
+	if lease.RaftNodeID == 0 || holder == nil {
+		return proto.Replica{}, false
+	}
+	return *holder, lease.Covers(r.rm.Clock().Now())
+}
+
+// LeaseHistory returns the most recent leader lease events of this
+// replica, oldest first. At most leaseHistorySize events are retained.
+func (r *Replica) LeaseHistory() []LeaseEvent {
+	return r.leaseHistory.get()
+}
+
+// HotKeys returns the keys and key spans most frequently accessed by
+// commands on this replica recently, hottest first. The counts are
+// approximate; see hotKeyTracker.
+func (r *Replica) HotKeys() []HotKey {
+	return r.hotKeys.get()
+}
+
+// recordLeaseEvent adds an event of the given type for the lease to the
+// replica's lease history.
+func (r *Replica) recordLeaseEvent(typ LeaseEventType, lease proto.Lease) {
+	r.leaseHistory.add(LeaseEvent{
+		Type:      typ,
+		Timestamp: r.rm.Clock().Now(),
+		Lease:     lease,
+	})
+}
+
 // newNotLeaderError returns a NotLeaderError intialized with the
 // replica for the holder (if any) of the given lease.
 func (r *Replica) newNotLeaderError(l *proto.Lease, originNode proto.RaftNodeID) error {
@@ -332,8 +917,17 @@ func (r *Replica) requestLeaderLease(timestamp proto.Timestamp) error {
 	duration := int64(DefaultLeaderLeaseDuration)
 	// Prepare a Raft command to get a leader lease for this replica.
 	expiration := timestamp.Add(duration, 0)
+	return r.proposeLeaderLease(r.newLeaderLeaseRequest(timestamp, proto.Lease{
+		Start:      timestamp,
+		Expiration: expiration,
+		RaftNodeID: r.rm.RaftNodeID(),
+	}))
+}
+
+// newLeaderLeaseRequest returns a request for the given lease.
+func (r *Replica) newLeaderLeaseRequest(timestamp proto.Timestamp, lease proto.Lease) *proto.LeaderLeaseRequest {
 	desc := r.Desc()
-	args := &proto.LeaderLeaseRequest{
+	return &proto.LeaderLeaseRequest{
 		RequestHeader: proto.RequestHeader{
 			Key:       desc.StartKey,
 			Timestamp: timestamp,
@@ -343,18 +937,20 @@ func (r *Replica) requestLeaderLease(timestamp proto.Timestamp) error {
 			},
 			RangeID: desc.RangeID,
 		},
-		Lease: proto.Lease{
-			Start:      timestamp,
-			Expiration: expiration,
-			RaftNodeID: r.rm.RaftNodeID(),
-		},
+		Lease: lease,
 	}
+}
+
+// proposeLeaderLease proposes the given lease request to Raft and waits
+// for the resulting command to be applied.
+func (r *Replica) proposeLeaderLease(args *proto.LeaderLeaseRequest) error {
 	// Send lease request directly to raft in order to skip unnecessary
 	// checks from normal request machinery, (e.g. the command queue).
 	// Note that the command itself isn't traced, but usually the caller
 	// waiting for the result has an active Trace.
 	errChan, pendingCmd := r.proposeRaftCommand(r.context(), args)
 	if err := <-errChan; err != nil {
+		r.abandonRaftCommand(pendingCmd)
 		return err
 	}
 	// Next if the command was committed, wait for the range to apply it.
@@ -367,6 +963,9 @@ func (r *Replica) requestLeaderLease(timestamp proto.Timestamp) error {
 // returning NotLeaderError. If the lease is expired, a renewal is
 // synchronously requested. This method uses the leader lease mutex
 // to guarantee only one request to grant the lease is pending.
+// Successive failed requests are spaced out by an exponential backoff
+// which is reset once a request succeeds. The mutex isn't held while
+// waiting before a request; the lease is checked again afterwards.
 //
 // TODO(spencer): implement threshold regrants to avoid latency in
 //  the presence of read or write pressure sufficiently close to the
@@ -383,23 +982,67 @@ func (r *Replica) redirectOnOrAcquireLeaderLease(trace *tracer.Trace, timestamp
 
 	raftNodeID := r.rm.RaftNodeID()
 
-	if lease := r.getLease(); lease.Covers(timestamp) {
-		if lease.OwnedBy(raftNodeID) {
-			// Happy path: We have an active lease, nothing to do.
-			return nil
+	for waited := false; ; waited = true {
+		if r.leaseTransferTarget != 0 {
+			// The lease is being handed off; redirect to the new holder.
+			return r.newNotLeaderError(&proto.Lease{RaftNodeID: r.leaseTransferTarget}, raftNodeID)
+		}
+		lease := r.getLease()
+		if lease.Covers(timestamp) {
+			if lease.OwnedBy(raftNodeID) {
+				// Happy path: We have an active lease, nothing to do.
+				return nil
+			}
+			// If lease is currently held by another, redirect to holder.
+			return r.newNotLeaderError(lease, raftNodeID)
+		}
+		if waited {
+			break
+		}
+		// Back off if our previous attempt failed. The first attempt after
+		// a success (or on a fresh replica) proceeds immediately.
+		delay := r.leaseRetry.NextBackoff()
+		// Unless we're renewing our own lease, give replicas on stores with
+		// fewer leases or in the preferred locality a head start, and
+		// redirect if one of them wins.
+		if !lease.OwnedBy(raftNodeID) {
+			delay += r.leaseAcquisitionDelay()
+			// Replicas further down the lease preferences wait longer, so
+			// that the most preferred available replica wins.
+			delay += time.Duration(r.leasePreferenceLag()) * leasePreferenceDelay
+		}
+		if delay <= 0 {
+			break
+		}
+		// Other commands may check the lease, or find it acquired by
+		// another replica, while this one waits.
+		r.llMu.Unlock()
+		epochDone := trace.Epoch("wait before requesting leader lease")
+		var stopping bool
+		select {
+		case <-time.After(delay):
+		case <-r.rm.Stopper().ShouldStop():
+			stopping = true
+		}
+		epochDone()
+		r.llMu.Lock()
+		if stopping {
+			return util.Errorf("node is stopping")
 		}
-		// If lease is currently held by another, redirect to holder.
-		return r.newNotLeaderError(lease, raftNodeID)
 	}
 	defer trace.Epoch("request leader lease")()
 	// Otherwise, no active lease: Request renewal.
 	err := r.requestLeaderLease(timestamp)
+	if err == nil {
+		r.leaseRetry.Reset()
+	}
 
 	// Getting a LeaseRejectedError back means someone else got there first;
 	// we can redirect if they cover our timestamp. Note that it can't be us,
 	// since we're holding a lock here, and even if it were it would be a rare
 	// extra round-trip.
-	if _, ok := err.(*proto.LeaseRejectedError); ok {
+	if rErr, ok := err.(*proto.LeaseRejectedError); ok {
+		r.recordLeaseEvent(LeaseRejected, rErr.Existing)
 		if lease := r.getLease(); lease.Covers(timestamp) {
 			return r.newNotLeaderError(lease, raftNodeID)
 		}
@@ -407,6 +1050,355 @@ func (r *Replica) redirectOnOrAcquireLeaderLease(trace *tracer.Trace, timestamp
 	return err
 }
 
+// leaseAcquisitionDelay returns a randomized delay to wait before
+// requesting the leader lease, weighted by the number of leases held
+// by this replica's store relative to the cluster mean as gossiped in
+// the store descriptors. No delay applies if the store pool has not
+// heard from this store yet.
+func (r *Replica) leaseAcquisitionDelay() time.Duration {
+	storePool := r.rm.allocator().storePool
+	if storePool == nil {
+		return 0
+	}
+	desc := storePool.getStoreDescriptor(r.rm.StoreID())
+	if desc == nil {
+		return 0
+	}
+	sl := storePool.getStoreList(proto.Attributes{}, false)
+	return weightedLeaseDelay(desc.Capacity.LeaseCount, sl.leases.mean, rand.Float64())
+}
+
+// deferToPreferredReplica returns whether this replica should hold back
+// on requesting the leader lease so that a replica in a locality more
+// preferred by the range's zone can acquire it. See leasePreferenceLag.
+func (r *Replica) deferToPreferredReplica() bool {
+	return r.leasePreferenceLag() > 0
+}
+
+// leasePreferenceLag returns by how many places this replica's node
+// ranks below the most preferred other replica in the lease preferences
+// of the range's zone. A node ranks at the first preference whose
+// attributes it has, or after all of them if it matches none. Only
+// replicas on live stores are considered, with node attributes as
+// gossiped in the store descriptors. Zero means that this replica is
+// among the most preferred available ones and need not defer.
+func (r *Replica) leasePreferenceLag() int {
+	prefs := r.getLeasePreferences()
+	if len(prefs) == 0 {
+		return 0
+	}
+	storePool := r.rm.allocator().storePool
+	if storePool == nil {
+		return 0
+	}
+	storeID := r.rm.StoreID()
+	rank := len(prefs)
+	if desc := storePool.getStoreDescriptor(storeID); desc != nil {
+		rank = leasePreferenceRank(prefs, desc.Node.Attrs)
+	}
+	best := rank
+	rangeDesc := r.Desc()
+	for _, desc := range storePool.getStoreList(proto.Attributes{}, false).stores {
+		if desc.StoreID == storeID {
+			continue
+		}
+		if _, rep := rangeDesc.FindReplica(desc.StoreID); rep == nil {
+			continue
+		}
+		if other := leasePreferenceRank(prefs, desc.Node.Attrs); other < best {
+			best = other
+		}
+	}
+	return rank - best
+}
+
+// leasePreferenceRank returns the index of the first of the lease
+// preferences matched by the given node attributes, or the number of
+// preferences if none matches.
+func leasePreferenceRank(prefs []proto.Attributes, attrs proto.Attributes) int {
+	for i, pref := range prefs {
+		if pref.IsSubset(attrs) {
+			return i
+		}
+	}
+	return len(prefs)
+}
+
+// weightedLeaseDelay scales maxLeaseAcquisitionDelay by random, a
+// number in [0, 1), and by the share leaseCount makes up of leaseCount
+// plus meanLeaseCount. Replicas on heavily loaded stores thus tend to
+// wait longer and lose the race for a lease to replicas on lightly
+// loaded ones. A store holding no leases does not wait at all.
+func weightedLeaseDelay(leaseCount int32, meanLeaseCount float64, random float64) time.Duration {
+	if leaseCount <= 0 {
+		return 0
+	}
+	weight := float64(leaseCount) / (float64(leaseCount) + meanLeaseCount)
+	return time.Duration(random * weight * float64(maxLeaseAcquisitionDelay))
+}
+
+// TransferLeaderLease hands the leader lease held by this replica to the
+// replica on the given Raft node. This replica stops serving requests,
+// waits for those in flight to complete and then cuts its lease short so
+// that the target's lease can begin right away. The most recent entries
+// of the timestamp cache are carried along with the target's lease, which
+// spares the new holder from conservatively pushing every write above the
+// expiration of the previous lease.
+func (r *Replica) TransferLeaderLease(target proto.RaftNodeID) error {
+	raftNodeID := r.rm.RaftNodeID()
+	r.llMu.Lock()
+	lease := r.getLease()
+	if !lease.OwnedBy(raftNodeID) || !lease.Covers(r.rm.Clock().Now()) {
+		r.llMu.Unlock()
+		return r.newNotLeaderError(lease, raftNodeID)
+	}
+	if target == raftNodeID {
+		r.llMu.Unlock()
+		return nil
+	}
+	_, targetStoreID := proto.DecodeRaftNodeID(target)
+	if _, replica := r.Desc().FindReplica(targetStoreID); replica == nil {
+		r.llMu.Unlock()
+		return util.Errorf("cannot transfer leader lease of range %d to node %d which holds no replica",
+			r.Desc().RangeID, target)
+	}
+	r.leaseTransferTarget = target
+	r.llMu.Unlock()
+	defer func() {
+		r.llMu.Lock()
+		r.leaseTransferTarget = 0
+		r.llMu.Unlock()
+	}()
+
+	// No new commands can pass the lease check now. Wait for all those that
+	// did, so that the timestamp cache reflects every request served under
+	// this lease.
+	var wg sync.WaitGroup
+	r.Lock()
+	r.cmdQ.GetWait(proto.KeyMin, proto.KeyMax, false /* !readOnly */, 0, &wg)
+	r.Unlock()
+	wg.Wait()
+
+	r.Lock()
+	lowWater, entries := r.tsCache.Export(maxTransferredTSCacheEntries)
+	r.Unlock()
+	// Writes at or below the closed timestamp must remain impossible.
+	lowWater.Forward(lease.ClosedTimestamp)
+
+	// Cut our own lease short, then propose the target's.
+	now := r.rm.Clock().Now()
+	shortened := *lease
+	shortened.Expiration = now.Next()
+	if err := r.proposeLeaderLease(r.newLeaderLeaseRequest(now, shortened)); err != nil {
+		return err
+	}
+	args := r.newLeaderLeaseRequest(now, proto.Lease{
+		Start:      shortened.Expiration,
+		Expiration: shortened.Expiration.Add(int64(DefaultLeaderLeaseDuration), 0),
+		RaftNodeID: target,
+	})
+	args.TimestampCacheLowWater = lowWater
+	args.TimestampCache = entries
+	return r.proposeLeaderLease(args)
+}
+
+// drainLeaderLease transfers the leader lease, if held by this
+// replica, to a peer replica on a store which the store pool considers
+// alive. The lease is left to expire if there is no such peer. It
+// returns true if the lease was transferred.
+func (r *Replica) drainLeaderLease() (bool, error) {
+	raftNodeID := r.rm.RaftNodeID()
+	if lease := r.getLease(); !lease.OwnedBy(raftNodeID) || !lease.Covers(r.rm.Clock().Now()) {
+		return false, nil
+	}
+	storePool := r.rm.allocator().storePool
+	if storePool == nil {
+		return false, nil
+	}
+	alive := map[proto.StoreID]struct{}{}
+	for _, desc := range storePool.getStoreList(proto.Attributes{}, false).stores {
+		alive[desc.StoreID] = struct{}{}
+	}
+	for _, rep := range r.Desc().Replicas {
+		if rep.StoreID == r.rm.StoreID() {
+			continue
+		}
+		if _, ok := alive[rep.StoreID]; ok {
+			return true, r.TransferLeaderLease(proto.MakeRaftNodeID(rep.NodeID, rep.StoreID))
+		}
+	}
+	return false, nil
+}
+
+// removalCandidate returns the replica which is least valuable to the
+// range and should be removed when the range is over-replicated. A
+// replica on a store which the store pool doesn't consider alive is
+// chosen first; removing it doesn't reduce the number of live replicas,
+// so the range's ability to reach a quorum is never weakened. Among live
+// replicas, the one sharing its locality (the attributes of its node)
+// with the most other replicas is preferred, to retain the range's
+// diversity across fault domains, and then the one on the most loaded
+// store as gossiped in the store descriptors.
+func (r *Replica) removalCandidate() (proto.Replica, error) {
+	desc := r.Desc()
+	if len(desc.Replicas) <= 1 {
+		return proto.Replica{}, util.Errorf("cannot remove the only replica of range %d", desc.RangeID)
+	}
+	storePool := r.rm.allocator().storePool
+	if storePool == nil {
+		return proto.Replica{}, util.Errorf("no store pool available to select a replica of range %d for removal", desc.RangeID)
+	}
+	sl := storePool.getStoreList(proto.Attributes{}, false)
+	alive := map[proto.StoreID]*proto.StoreDescriptor{}
+	for _, storeDesc := range sl.stores {
+		alive[storeDesc.StoreID] = storeDesc
+	}
+	// This replica's own store is alive, even if it has yet to be gossiped.
+	if _, ok := alive[r.rm.StoreID()]; !ok {
+		alive[r.rm.StoreID()] = &proto.StoreDescriptor{StoreID: r.rm.StoreID()}
+	}
+
+	localities := map[string]int{}
+	for _, rep := range desc.Replicas {
+		storeDesc, ok := alive[rep.StoreID]
+		if !ok {
+			return rep, nil
+		}
+		localities[storeDesc.Node.Attrs.SortedString()]++
+	}
+
+	// Use range counts instead of capacities if the cluster has mean
+	// fraction used below a threshold level, as allocator.removeTarget does.
+	load := func(storeDesc *proto.StoreDescriptor) float64 {
+		if sl.used.mean < minFractionUsedThreshold {
+			return float64(storeDesc.Capacity.RangeCount)
+		}
+		return storeDesc.Capacity.FractionUsed()
+	}
+	var worst proto.Replica
+	var worstShared int
+	var worstLoad float64
+	for i, rep := range desc.Replicas {
+		storeDesc := alive[rep.StoreID]
+		shared, l := localities[storeDesc.Node.Attrs.SortedString()], load(storeDesc)
+		if i == 0 || shared > worstShared || (shared == worstShared && l > worstLoad) {
+			worst, worstShared, worstLoad = rep, shared, l
+		}
+	}
+	return worst, nil
+}
+
+// CloseTimestamp promises that this replica, as the holder of the
+// leader lease, will not accept any further writes at or below the
+// supplied timestamp. The promise is replicated through Raft as part
+// of the leader lease so that replicas which don't hold the lease can
+// serve consistent reads at or below the closed timestamp without
+// contacting the lease holder. Closing a timestamp at or below the
+// current closed timestamp is a no-op.
+func (r *Replica) CloseTimestamp(timestamp proto.Timestamp) error {
+	r.llMu.Lock()
+	defer r.llMu.Unlock()
+
+	raftNodeID := r.rm.RaftNodeID()
+	now := r.rm.Clock().Now()
+	lease := r.getLease()
+	if !lease.OwnedBy(raftNodeID) || !lease.Covers(now) {
+		return r.newNotLeaderError(lease, raftNodeID)
+	}
+	if !lease.ClosedTimestamp.Less(timestamp) {
+		return nil
+	}
+	if !timestamp.Less(now) {
+		return util.Errorf("cannot close timestamp %s which is not in the past (now %s)", timestamp, now)
+	}
+	// Push all writes which are yet to check the timestamp cache above the
+	// closed timestamp. Those which already did are rejected when they are
+	// applied after the lease; see applyRaftCommandInBatch.
+	r.Lock()
+	r.tsCache.SetLowWater(timestamp)
+	r.Unlock()
+
+	closedLease := *lease
+	closedLease.ClosedTimestamp = timestamp
+	return r.proposeLeaderLease(r.newLeaderLeaseRequest(now, closedLease))
+}
+
+// maybeCloseTimestamp closes the timestamp which lags the current time
+// by the supplied duration if this replica holds the leader lease.
+func (r *Replica) maybeCloseTimestamp(lag time.Duration) error {
+	if lease := r.getLease(); !lease.OwnedBy(r.rm.RaftNodeID()) || !r.isInitialized() {
+		return nil
+	}
+	closed := r.rm.Clock().Now()
+	closed.WallTime -= lag.Nanoseconds()
+	closed.Logical = 0
+	err := r.CloseTimestamp(closed)
+	if _, ok := err.(*proto.NotLeaderError); ok {
+		// We lost the lease in the meantime; the new holder takes over.
+		return nil
+	}
+	return err
+}
+
+// isHistoricalRead returns true if the read-only request reads at or below
+// the closed timestamp of the current lease. No write can be applied at
+// such a timestamp any more, so the read can't conflict with any write and
+// can be served by any replica without holding the leader lease.
+func (r *Replica) isHistoricalRead(header *proto.RequestHeader) bool {
+	closed := r.getLease().ClosedTimestamp
+	if closed.Less(header.Timestamp) {
+		return false
+	}
+	// Transactional reads must also be certain about all values within
+	// their uncertainty interval, which historical transactions don't have.
+	return header.Txn == nil || header.Txn.Historical || !closed.Less(header.Txn.MaxTimestamp)
+}
+
+// staleReadTimestamp returns the closed timestamp at which a read
+// tolerating the given staleness can be served by this replica without
+// the leader lease, along with how far it lags this replica's clock.
+// It returns false if this replica holds the leader lease, in which
+// case the read is best served fresh, or if no timestamp recent enough
+// has been closed.
+func (r *Replica) staleReadTimestamp(maxStaleness time.Duration) (proto.Timestamp, time.Duration, bool) {
+	now := r.rm.Clock().Now()
+	lease := r.getLease()
+	if lease.OwnedBy(r.rm.RaftNodeID()) && lease.Covers(now) {
+		return proto.ZeroTimestamp, 0, false
+	}
+	closed := lease.ClosedTimestamp
+	if closed.Equal(proto.ZeroTimestamp) {
+		return proto.ZeroTimestamp, 0, false
+	}
+	staleness := time.Duration(now.WallTime - closed.WallTime)
+	if staleness > maxStaleness {
+		return proto.ZeroTimestamp, 0, false
+	}
+	return closed, staleness, true
+}
+
+// checkGCThreshold returns an error if a read at the given timestamp might
+// miss versions which have been removed by garbage collection.
+func (r *Replica) checkGCThreshold(timestamp proto.Timestamp) error {
+	// GC TTLs are specified in whole seconds, so the threshold lags the
+	// clock of the replica which last ran GC by at least a second. Avoid
+	// looking it up for recent reads.
+	clock := r.rm.Clock()
+	recent := clock.Now()
+	recent.WallTime -= (time.Second - clock.MaxOffset()).Nanoseconds()
+	if recent.Less(timestamp) {
+		return nil
+	}
+	threshold, err := r.GCThreshold()
+	if err != nil {
+		return err
+	}
+	if timestamp.Less(threshold) {
+		return &proto.ReadBelowGCThresholdError{Timestamp: timestamp, Threshold: threshold}
+	}
+	return nil
+}
+
 // WaitForLeaderLease is used from unittests to wait until this range
 // has the leader lease.
 func (r *Replica) WaitForLeaderLease(t util.Tester) {
@@ -446,6 +1438,41 @@ func (r *Replica) setDescWithoutProcessUpdate(desc *proto.RangeDescriptor) {
 	atomic.StorePointer(&r.desc, unsafe.Pointer(desc))
 }
 
+// reconcileDescriptor reads the authoritative descriptor of the range,
+// stored under the range's start key, through the DB and updates the
+// replica's in-memory descriptor if it differs, for instance if this
+// replica missed a replica change. Only changes which leave the range's
+// bounds alone are reconciled: the store indexes its replicas by key,
+// and a change of bounds is left to the split or merge which made it.
+// Returns whether the descriptor was updated.
+func (r *Replica) reconcileDescriptor(ctx context.Context) (bool, error) {
+	desc := r.Desc()
+	kv, err := r.rm.DB().Get(keys.RangeDescriptorKey(desc.StartKey))
+	if err != nil {
+		return false, err
+	}
+	if !kv.Exists() {
+		return false, util.Errorf("no descriptor found for range %d at key %q", desc.RangeID, desc.StartKey)
+	}
+	var newDesc proto.RangeDescriptor
+	if err := kv.ValueProto(&newDesc); err != nil {
+		return false, err
+	}
+	if newDesc.RangeID != desc.RangeID {
+		return false, util.Errorf("descriptor at key %q belongs to range %d, not %d",
+			desc.StartKey, newDesc.RangeID, desc.RangeID)
+	}
+	if gogoproto.Equal(desc, &newDesc) {
+		return false, nil
+	}
+	if !newDesc.StartKey.Equal(desc.StartKey) || !newDesc.EndKey.Equal(desc.EndKey) {
+		return false, util.Errorf("descriptor of range %d spans [%q, %q), not [%q, %q); refusing to reconcile its bounds",
+			desc.RangeID, newDesc.StartKey, newDesc.EndKey, desc.StartKey, desc.EndKey)
+	}
+	log.Infoc(ctx, "updating stale descriptor of range %d: %+v -> %+v", desc.RangeID, desc, &newDesc)
+	return true, r.setDesc(&newDesc)
+}
+
 // GetReplica returns the replica for this range from the range descriptor.
 // Returns nil if the replica is not found.
 func (r *Replica) GetReplica() *proto.Replica {
@@ -459,10 +1486,22 @@ func (r *Replica) GetMVCCStats() engine.MVCCStats {
 }
 
 // ContainsKey returns whether this range contains the specified key.
+// Range-local keys are mapped to their addressing key first, so that
+// e.g. the transaction record of a key is contained by the range which
+// contains the key itself.
 func (r *Replica) ContainsKey(key proto.Key) bool {
 	return containsKey(*r.Desc(), key)
 }
 
+// ContainsRawKey returns whether the literal key lies within the bounds
+// of this range's descriptor. Unlike ContainsKey, no address translation
+// takes place, which makes it suitable for checking raw engine keys.
+// Range-local keys sort before all global keys and are thus not
+// contained by any range other than one starting at KeyMin.
+func (r *Replica) ContainsRawKey(key proto.Key) bool {
+	return r.Desc().ContainsKey(key)
+}
+
 func containsKey(desc proto.RangeDescriptor, key proto.Key) bool {
 	return desc.ContainsKey(keys.KeyAddress(key))
 }
@@ -477,6 +1516,13 @@ func containsKeyRange(desc proto.RangeDescriptor, start, end proto.Key) bool {
 	return desc.ContainsKeyRange(keys.KeyAddress(start), keys.KeyAddress(end))
 }
 
+// KeySpan returns the span of keys [StartKey, EndKey) covered by this
+// range.
+func (r *Replica) KeySpan() keys.Span {
+	desc := r.Desc()
+	return keys.Span{Start: desc.StartKey, End: desc.EndKey}
+}
+
 // GetGCMetadata reads the latest GC metadata for this range.
 func (r *Replica) GetGCMetadata() (*proto.GCMetadata, error) {
 	key := keys.RangeGCMetadataKey(r.Desc().RangeID)
@@ -488,6 +1534,18 @@ func (r *Replica) GetGCMetadata() (*proto.GCMetadata, error) {
 	return gcMeta, nil
 }
 
+// GCThreshold returns the timestamp below which versions may have been
+// removed by garbage collection, as recorded by the latest GC scan. Data
+// is only guaranteed to exist at or above this timestamp, so historical
+// reads and backups below it must be rejected.
+func (r *Replica) GCThreshold() (proto.Timestamp, error) {
+	gcMeta, err := r.GetGCMetadata()
+	if err != nil {
+		return proto.ZeroTimestamp, err
+	}
+	return proto.Timestamp{WallTime: gcMeta.ThresholdNanos}, nil
+}
+
 // GetLastVerificationTimestamp reads the timestamp at which the range's
 // data was last verified.
 func (r *Replica) GetLastVerificationTimestamp() (proto.Timestamp, error) {
@@ -513,21 +1571,55 @@ func (r *Replica) SetLastVerificationTimestamp(timestamp proto.Timestamp) error
 // either along the read-only execution path or the read-write Raft
 // command queue.
 func (r *Replica) AddCmd(ctx context.Context, args proto.Request) (proto.Response, error) {
+	return r.addCmd(ctx, args, false /* !nonBlocking */)
+}
+
+// TryAddCmd is like AddCmd, but fails with a CommandQueueBusyError
+// instead of waiting if the command overlaps any executing command in
+// the command queue. This is intended for best-effort background
+// operations which would rather give up than delay foreground traffic.
+func (r *Replica) TryAddCmd(ctx context.Context, args proto.Request) (proto.Response, error) {
+	return r.addCmd(ctx, args, true /* nonBlocking */)
+}
+
+func (r *Replica) addCmd(ctx context.Context, args proto.Request, nonBlocking bool) (proto.Response, error) {
 	// TODO(tschottdorf) Some (internal) requests go here directly, so they
 	// won't be traced.
 	trace := tracer.FromCtx(ctx)
+	// An empty batch is a no-op; reply right away without engaging the
+	// leader lease or the command queue.
+	if bArgs, ok := args.(*proto.BatchRequest); ok && len(bArgs.Requests) == 0 {
+		reply := &proto.BatchResponse{}
+		reply.Timestamp = bArgs.Timestamp
+		if reply.Timestamp.Equal(proto.ZeroTimestamp) {
+			reply.Timestamp = r.rm.Clock().Now()
+		}
+		return reply, nil
+	}
+	if err := r.checkBatchSize(args); err != nil {
+		return nil, err
+	}
+	if !proto.IsAdmin(args) {
+		header := args.Header()
+		now := r.rm.Clock().PhysicalNow()
+		r.hotKeys.record(header.Key, header.EndKey, now)
+		r.qps.record(now)
+	}
 	// Differentiate between admin, read-only and read-write.
 	var reply proto.Response
 	var err error
-	if proto.IsAdmin(args) {
+	if bArgs, ok := args.(*proto.BatchRequest); ok && isReadOnlyBatch(bArgs) {
+		defer trace.Epoch("read-only batch path")()
+		reply, err = r.addReadOnlyBatch(ctx, bArgs, nonBlocking)
+	} else if proto.IsAdmin(args) {
 		defer trace.Epoch("admin path")()
 		reply, err = r.addAdminCmd(ctx, args)
 	} else if proto.IsReadOnly(args) {
 		defer trace.Epoch("read-only path")()
-		reply, err = r.addReadOnlyCmd(ctx, args)
+		reply, err = r.addReadOnlyCmd(ctx, args, nonBlocking)
 	} else if proto.IsWrite(args) {
 		defer trace.Epoch("read-write path")()
-		reply, err = r.addWriteCmd(ctx, args, nil)
+		reply, err = r.addWriteCmd(ctx, args, nil, nonBlocking)
 	} else {
 		panic(fmt.Sprintf("don't know how to handle command %T", args))
 	}
@@ -535,6 +1627,26 @@ func (r *Replica) AddCmd(ctx context.Context, args proto.Request) (proto.Respons
 	return reply, err
 }
 
+// checkBatchSize returns a BatchTooLargeError if the command is a batch
+// holding more requests or more bytes than the store allows.
+func (r *Replica) checkBatchSize(args proto.Request) error {
+	bArgs, ok := args.(*proto.BatchRequest)
+	if !ok {
+		return nil
+	}
+	count, size := int64(len(bArgs.Requests)), int64(bArgs.Size())
+	maxCount, maxSize := int64(r.rm.maxBatchRequests()), r.rm.maxBatchBytes()
+	if count > maxCount || size > maxSize {
+		return &proto.BatchTooLargeError{
+			RequestCount:    count,
+			RequestBytes:    size,
+			MaxRequestCount: maxCount,
+			MaxRequestBytes: maxSize,
+		}
+	}
+	return nil
+}
+
 func (r *Replica) checkCmdHeader(header *proto.RequestHeader) error {
 	if !r.ContainsKeyRange(header.Key, header.EndKey) {
 		return proto.NewRangeKeyMismatchError(header.Key, header.EndKey, r.Desc())
@@ -545,14 +1657,26 @@ func (r *Replica) checkCmdHeader(header *proto.RequestHeader) error {
 // beginCmd waits for any overlapping, already-executing commands via
 // the command queue and adds itself to the queue to gate follow-on
 // commands which overlap its key range. This method will block if
-// there are any overlapping commands already in the queue. Returns
-// the command queue insertion key, to be supplied to subsequent
-// invocation of endCmd().
-func (r *Replica) beginCmd(header *proto.RequestHeader, readOnly bool) interface{} {
+// there are any overlapping commands already in the queue, unless
+// nonBlocking is set, in which case a CommandQueueBusyError is
+// returned without adding the command to the queue. Writes fail with a
+// WritesPausedError while writes are paused. Returns the
+// command queue insertion key, to be supplied to subsequent invocation
+// of endCmd().
+func (r *Replica) beginCmd(header *proto.RequestHeader, readOnly, nonBlocking bool) (interface{}, error) {
 	r.Lock()
+	if !readOnly && r.writesPaused {
+		r.Unlock()
+		return nil, &WritesPausedError{RangeID: r.Desc().RangeID}
+	}
+	if nonBlocking && r.cmdQ.WouldWait(header.Key, header.EndKey, readOnly) {
+		r.Unlock()
+		return nil, &CommandQueueBusyError{Key: header.Key, EndKey: header.EndKey}
+	}
 	var wg sync.WaitGroup
-	r.cmdQ.GetWait(header.Key, header.EndKey, readOnly, &wg)
-	cmdKey := r.cmdQ.Add(header.Key, header.EndKey, readOnly)
+	priority := cmdPriority(header)
+	r.cmdQ.GetWait(header.Key, header.EndKey, readOnly, priority, &wg)
+	cmdKey := r.cmdQ.Add(header.Key, header.EndKey, readOnly, priority)
 	r.Unlock()
 	wg.Wait()
 	// Update the incoming timestamp if unset. Wait until after any
@@ -562,20 +1686,108 @@ func (r *Replica) beginCmd(header *proto.RequestHeader, readOnly bool) interface
 	if header.Timestamp.Equal(proto.ZeroTimestamp) {
 		header.Timestamp = r.rm.Clock().Now()
 	}
-	return cmdKey
+	return cmdKey, nil
+}
+
+// ActiveCommandSpans returns the key spans of the commands currently
+// held in the command queue, ordered by start key. These are the
+// commands executing or waiting to execute, which new overlapping
+// commands have to wait for.
+func (r *Replica) ActiveCommandSpans() []keys.Span {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cmdQ.Spans()
+}
+
+// PauseWrites quiesces writes to the range, for instance to take a
+// consistent backup. New writes fail with a WritesPausedError until
+// ResumeWrites is called, while reads continue to be served. PauseWrites
+// returns once all writes in flight have completed.
+func (r *Replica) PauseWrites() {
+	var wg sync.WaitGroup
+	r.Lock()
+	r.writesPaused = true
+	// Reads don't wait for one another, so this waits for the writes only.
+	r.cmdQ.GetWait(proto.KeyMin, proto.KeyMax, true /* readOnly */, 0, &wg)
+	r.Unlock()
+	wg.Wait()
+}
+
+// ResumeWrites lets writes to the range proceed after PauseWrites.
+func (r *Replica) ResumeWrites() {
+	r.Lock()
+	r.writesPaused = false
+	r.Unlock()
+}
+
+// cmdPriority returns the priority with which the command with the
+// given header enters the command queue: that of its transaction, if
+// any, and the explicit priority given by a negative user priority
+// otherwise. Non-transactional commands without an explicit priority
+// only pick one when they push a transaction (see PushTxn) and enter
+// the queue with the lowest priority.
+func cmdPriority(header *proto.RequestHeader) int32 {
+	if header.Txn != nil {
+		return header.Txn.Priority
+	}
+	if userPriority := header.GetUserPriority(); userPriority < 0 {
+		return -userPriority
+	}
+	return 0
+}
+
+// inheritPriority raises the priority with which the command with the
+// given header pushes conflicting transactions to the supplied priority,
+// inherited from a command waiting on it in the command queue. The
+// transaction is copied so that the caller's isn't modified.
+func inheritPriority(header *proto.RequestHeader, priority int32) {
+	if priority <= cmdPriority(header) {
+		return
+	}
+	if header.Txn != nil {
+		txn := gogoproto.Clone(header.Txn).(*proto.Transaction)
+		txn.UpgradePriority(priority)
+		header.Txn = txn
+		return
+	}
+	header.UserPriority = gogoproto.Int32(-priority)
 }
 
 // endCmd removes a pending command from the command queue.
 func (r *Replica) endCmd(cmdKey interface{}, args proto.Request, err error, readOnly bool) {
 	r.Lock()
-	if err == nil && usesTimestampCache(args) {
-		header := args.Header()
+	// A command which blocks a higher-priority waiter and failed on a
+	// conflicting intent pushes the intent's transaction with the
+	// inherited priority, so that it isn't stalled in turn by a
+	// lower-priority transaction while the waiter is held up.
+	if _, ok := err.(*proto.WriteIntentError); ok {
+		inheritPriority(args.Header(), r.cmdQ.Priority(cmdKey))
+	}
+	// Only writes may skip the timestamp cache: a read which isn't
+	// recorded could have its value overwritten below its timestamp.
+	if header := args.Header(); err == nil && usesTimestampCache(args) &&
+		(readOnly || !header.SkipTimestampCacheUpdate) &&
+		!spanHasPrefix(r.rm.tsCacheExcludedPrefixes(), header.Key, header.EndKey) {
 		r.tsCache.Add(header.Key, header.EndKey, header.Timestamp, header.Txn.GetID(), readOnly)
 	}
 	r.cmdQ.Remove(cmdKey)
 	r.Unlock()
 }
 
+// spanHasPrefix returns true if the span [key, endKey), or the single
+// key if endKey is empty, lies entirely within one of the prefixes.
+func spanHasPrefix(prefixes []proto.Key, key, endKey proto.Key) bool {
+	for _, prefix := range prefixes {
+		if !bytes.HasPrefix(key, prefix) {
+			continue
+		}
+		if len(endKey) == 0 || !prefix.PrefixEnd().Less(endKey) {
+			return true
+		}
+	}
+	return false
+}
+
 // addAdminCmd executes the command directly. There is no interaction
 // with the command queue or the timestamp cache, as admin commands
 // are not meant to consistently access or modify the underlying data.
@@ -607,7 +1819,7 @@ func (r *Replica) addAdminCmd(ctx context.Context, args proto.Request) (proto.Re
 // addReadOnlyCmd updates the read timestamp cache and waits for any
 // overlapping writes currently processing through Raft ahead of us to
 // clear via the read queue.
-func (r *Replica) addReadOnlyCmd(ctx context.Context, args proto.Request) (proto.Response, error) {
+func (r *Replica) addReadOnlyCmd(ctx context.Context, args proto.Request, nonBlocking bool) (proto.Response, error) {
 	header := args.Header()
 
 	if err := r.checkCmdHeader(header); err != nil {
@@ -623,16 +1835,64 @@ func (r *Replica) addReadOnlyCmd(ctx context.Context, args proto.Request) (proto
 		if header.Timestamp.Equal(proto.ZeroTimestamp) {
 			header.Timestamp = r.rm.Clock().Now()
 		}
-		reply, intents, err := r.executeCmd(r.rm.Engine(), nil, args)
-		r.handleSkippedIntents(args, intents) // even on error
+		if err := r.checkGCThreshold(header.Timestamp); err != nil {
+			return nil, err
+		}
+		execDone := traceCmd(tracer.FromCtx(ctx), args)
+		reply, intents, moreIntents, err := r.executeCmd(r.rm.Engine(), nil, args)
+		execDone()
+		r.handleSkippedIntents(args, intents, moreIntents) // even on error
 		return reply, err
 	} else if header.ReadConsistency == proto.CONSENSUS {
 		return nil, util.Errorf("consensus reads not implemented")
 	}
 
+	// Historical transactions read at a fixed timestamp, without an
+	// uncertainty interval.
+	if header.Txn != nil && header.Txn.Historical && header.Timestamp.Less(header.Txn.MaxTimestamp) {
+		header.Txn = gogoproto.Clone(header.Txn).(*proto.Transaction)
+		header.Txn.MaxTimestamp = header.Timestamp
+	}
+
+	// Non-transactional reads which tolerate staleness are moved back
+	// to the closed timestamp if it is recent enough, so that they're
+	// served below as historical reads without the leader lease.
+	var staleness time.Duration
+	if header.MaxStaleness > 0 && header.Txn == nil {
+		closed, s, ok := r.staleReadTimestamp(header.MaxStaleness)
+		if ok && (header.Timestamp.Equal(proto.ZeroTimestamp) || closed.Less(header.Timestamp)) {
+			header.Timestamp = closed
+			staleness = s
+		}
+	}
+
+	// Reads at an explicit timestamp in the past are served at that
+	// timestamp; versions must not have been garbage collected yet.
+	if !header.Timestamp.Equal(proto.ZeroTimestamp) {
+		if err := r.checkGCThreshold(header.Timestamp); err != nil {
+			return nil, err
+		}
+		// Historical reads can't conflict with any write, so neither
+		// the command queue nor the timestamp cache (nor the leader
+		// lease) are involved.
+		if r.isHistoricalRead(header) {
+			execDone := traceCmd(tracer.FromCtx(ctx), args)
+			reply, intents, moreIntents, err := r.executeCmd(r.rm.Engine(), nil, args)
+			execDone()
+			if reply != nil {
+				reply.Header().Staleness = staleness
+			}
+			r.handleSkippedIntents(args, intents, moreIntents) // even on error
+			return reply, err
+		}
+	}
+
 	// Add the read to the command queue to gate subsequent
 	// overlapping commands until this command completes.
-	cmdKey := r.beginCmd(header, true)
+	cmdKey, err := r.beginCmd(header, true, nonBlocking)
+	if err != nil {
+		return nil, err
+	}
 
 	// This replica must have leader lease to process a consistent read.
 	if err := r.redirectOnOrAcquireLeaderLease(tracer.FromCtx(ctx), header.Timestamp); err != nil {
@@ -641,15 +1901,148 @@ func (r *Replica) addReadOnlyCmd(ctx context.Context, args proto.Request) (proto
 	}
 
 	// Execute read-only command.
-	reply, intents, err := r.executeCmd(r.rm.Engine(), nil, args)
+	execDone := traceCmd(tracer.FromCtx(ctx), args)
+	reply, intents, moreIntents, err := r.executeCmd(r.rm.Engine(), nil, args)
+	execDone()
 
 	// Only update the timestamp cache if the command succeeded.
 	r.endCmd(cmdKey, args, err, true /* readOnly */)
 
-	r.handleSkippedIntents(args, intents) // even on error
+	r.handleSkippedIntents(args, intents, moreIntents) // even on error
 	return reply, err
 }
 
+// isReadOnlyBatch returns true if the batch consists of read-only
+// requests only.
+func isReadOnlyBatch(bArgs *proto.BatchRequest) bool {
+	for _, union := range bArgs.Requests {
+		if args := union.GetValue().(proto.Request); !proto.IsReadOnly(args) || proto.IsAdmin(args) {
+			return false
+		}
+	}
+	return true
+}
+
+// addReadOnlyBatch executes the requests of a read-only batch via
+// addReadOnlyCmd and returns their responses in request order. Requests
+// which don't specify a timestamp or transaction inherit those of the
+// batch, so that all of them read the same snapshot. Likewise, requests
+// read with the batch's read consistency unless they specify their own;
+// inconsistent reads bypass the command queue while the others wait for
+// conflicting commands. Requests with
+// overlapping key spans are executed one after the other in request
+// order, while independent requests are executed concurrently, up to
+// maxConcurrentBatchReads at a time. If any request fails, the error of
+// the first failed request is returned.
+func (r *Replica) addReadOnlyBatch(ctx context.Context, bArgs *proto.BatchRequest, nonBlocking bool) (proto.Response, error) {
+	if bArgs.Timestamp.Equal(proto.ZeroTimestamp) && bArgs.Txn == nil {
+		bArgs.Timestamp = r.rm.Clock().Now()
+	}
+	reqs := make([]proto.Request, len(bArgs.Requests))
+	for i, union := range bArgs.Requests {
+		reqs[i] = union.GetValue().(proto.Request)
+		header := reqs[i].Header()
+		if header.Timestamp.Equal(proto.ZeroTimestamp) {
+			header.Timestamp = bArgs.Timestamp
+		}
+		if header.Txn == nil {
+			header.Txn = bArgs.Txn
+		}
+		if header.ReadConsistency == proto.CONSISTENT {
+			header.ReadConsistency = bArgs.ReadConsistency
+		}
+	}
+
+	// Traces aren't safe for concurrent use, so the requests of the
+	// batch are executed untraced.
+	execCtx := tracer.ToCtx(ctx, nil)
+	replies := make([]proto.Response, len(reqs))
+	errs := make([]error, len(reqs))
+	sem := make(chan struct{}, maxConcurrentBatchReads)
+	var wg sync.WaitGroup
+	for _, group := range overlappingRequests(reqs) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(group []int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, i := range group {
+				replies[i], errs[i] = r.addReadOnlyCmd(execCtx, reqs[i], nonBlocking)
+			}
+		}(group)
+	}
+	wg.Wait()
+
+	reply := &proto.BatchResponse{}
+	reply.Timestamp = bArgs.Timestamp
+	for i := range reqs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		reply.Add(replies[i])
+		reply.AddStats(replies[i].Header().Stats)
+	}
+	return reply, nil
+}
+
+// overlappingRequests partitions the given requests into groups such
+// that requests whose key spans overlap end up in the same group. Each
+// group holds request indexes in ascending order.
+func overlappingRequests(reqs []proto.Request) [][]int {
+	byKey := make([]int, len(reqs))
+	for i := range byKey {
+		byKey[i] = i
+	}
+	sort.Sort(requestsByKey{reqs: reqs, idxs: byKey})
+
+	var groups [][]int
+	var groupEnd proto.Key
+	for _, i := range byKey {
+		header := reqs[i].Header()
+		end := header.EndKey
+		if end == nil {
+			end = header.Key.Next()
+		}
+		if len(groups) == 0 || !header.Key.Less(groupEnd) {
+			groups = append(groups, nil)
+			groupEnd = end
+		} else if groupEnd.Less(end) {
+			groupEnd = end
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], i)
+	}
+	for _, group := range groups {
+		sort.Ints(group)
+	}
+	return groups
+}
+
+// requestsByKey sorts request indexes by the start key of the requests.
+type requestsByKey struct {
+	reqs []proto.Request
+	idxs []int
+}
+
+func (r requestsByKey) Len() int      { return len(r.idxs) }
+func (r requestsByKey) Swap(i, j int) { r.idxs[i], r.idxs[j] = r.idxs[j], r.idxs[i] }
+func (r requestsByKey) Less(i, j int) bool {
+	return r.reqs[r.idxs[i]].Header().Key.Less(r.reqs[r.idxs[j]].Header().Key)
+}
+
+// traceCmd begins an epoch in the supplied trace for the execution of
+// a request, naming the request's method and key span so that a trace
+// shows which request was slow. The returned function ends the epoch.
+// Nothing is recorded (or formatted) without a trace.
+func traceCmd(trace *tracer.Trace, args proto.Request) func() {
+	if trace == nil {
+		return func() {}
+	}
+	header := args.Header()
+	return trace.Epoch(fmt.Sprintf("executing %s [%s,%s)", args.Method(), header.Key, header.EndKey))
+}
+
 // addWriteCmd first adds the keys affected by this command as pending writes
 // to the command queue. Next, the timestamp cache is checked to determine if
 // any newer accesses to this command's affected keys have been made. If so,
@@ -657,8 +2050,9 @@ func (r *Replica) addReadOnlyCmd(ctx context.Context, args proto.Request) (proto
 // to Raft. Upon completion, the write is removed from the read queue and any
 // error returned. If a WaitGroup is supplied, it is signaled when the command
 // enters Raft or the function returns with a preprocessing error, whichever
-// happens earlier.
-func (r *Replica) addWriteCmd(ctx context.Context, args proto.Request, wg *sync.WaitGroup) (proto.Response, error) {
+// happens earlier. If nonBlocking is set, a CommandQueueBusyError is
+// returned instead of waiting for overlapping commands.
+func (r *Replica) addWriteCmd(ctx context.Context, args proto.Request, wg *sync.WaitGroup, nonBlocking bool) (proto.Response, error) {
 	signal := func() {
 		if wg != nil {
 			wg.Done()
@@ -676,6 +2070,28 @@ func (r *Replica) addWriteCmd(ctx context.Context, args proto.Request, wg *sync.
 		return nil, err
 	}
 
+	if header.Txn != nil && header.Txn.Historical {
+		return nil, util.Errorf("cannot write in historical transaction %s", header.Txn)
+	}
+
+	// Oversized values are rejected before they reach the Raft log. This
+	// can't happen when the command is applied: the limit comes from the
+	// gossiped zone configs, which replicas may not agree on.
+	if err := r.checkValueSize(args); err != nil {
+		return nil, err
+	}
+	// Values with a bad checksum are rejected up front, which lets reads
+	// treat a checksum mismatch as corruption of the stored value. For the
+	// same reason as above, values are checksummed here rather than when
+	// the command is applied.
+	if err := r.verifyValueChecksum(args); err != nil {
+		return nil, err
+	}
+	r.maybeChecksumValue(args)
+	if err := r.checkTxnIntents(args); err != nil {
+		return nil, err
+	}
+
 	trace := tracer.FromCtx(ctx)
 
 	// Add the write to the command queue to gate subsequent overlapping
@@ -684,8 +2100,11 @@ func (r *Replica) addWriteCmd(ctx context.Context, args proto.Request, wg *sync.
 	// timestamp cache is only updated after preceding commands have
 	// been run to successful completion.
 	qDone := trace.Epoch("command queue")
-	cmdKey := r.beginCmd(header, false)
+	cmdKey, err := r.beginCmd(header, false, nonBlocking)
 	qDone()
+	if err != nil {
+		return nil, err
+	}
 
 	// This replica must have leader lease to process a write.
 	if err := r.redirectOnOrAcquireLeaderLease(trace, header.Timestamp); err != nil {
@@ -693,71 +2112,209 @@ func (r *Replica) addWriteCmd(ctx context.Context, args proto.Request, wg *sync.
 		return nil, err
 	}
 
-	// Two important invariants of Cockroach: 1) encountering a more
-	// recently written value means transaction restart. 2) values must
-	// be written with a greater timestamp than the most recent read to
-	// the same key. Check the timestamp cache for reads/writes which
-	// are at least as recent as the timestamp of this write. For
-	// writes, send WriteTooOldError; for reads, update the write's
-	// timestamp. When the write returns, the updated timestamp will
-	// inform the final commit timestamp.
-	if usesTimestampCache(args) {
-		r.Lock()
-		rTS, wTS := r.tsCache.GetMax(header.Key, header.EndKey, header.Txn.GetID())
-		r.Unlock()
+	// A write which can't be acknowledged in the locality required by the
+	// range's zone isn't proposed at all.
+	quorumIDs, err := r.quorumReplicas()
+	if err != nil {
+		r.endCmd(cmdKey, args, err, false /* !readOnly */)
+		return nil, err
+	}
+
+	defer trace.Epoch("raft")()
 
-		// Always push the timestamp forward if there's been a read which
-		// occurred after our txn timestamp.
-		if !rTS.Less(header.Timestamp) {
-			header.Timestamp = rTS.Next()
-		}
-		// If there's a newer write timestamp...
-		if !wTS.Less(header.Timestamp) {
-			// If we're in a txn, we still go ahead and try the write since
-			// we want to avoid restarting the transaction in the event that
-			// there isn't an intent or the intent can be pushed by us.
-			//
-			// If we're not in a txn, it's trivial to just advance our timestamp.
-			if header.Txn == nil {
-				header.Timestamp = wTS.Next()
+	var reply proto.Response
+	var index uint64 // Raft log index at which the command was applied
+	for retries := 0; ; retries++ {
+		// Two important invariants of Cockroach: 1) encountering a more
+		// recently written value means transaction restart. 2) values must
+		// be written with a greater timestamp than the most recent read to
+		// the same key. Check the timestamp cache for reads/writes which
+		// are at least as recent as the timestamp of this write. For
+		// writes, send WriteTooOldError; for reads, update the write's
+		// timestamp. When the write returns, the updated timestamp will
+		// inform the final commit timestamp.
+		if usesTimestampCache(args) {
+			r.Lock()
+			rTS, wTS := r.tsCache.GetMax(header.Key, header.EndKey, header.Txn.GetID())
+			r.Unlock()
+			origTS := header.Timestamp
+
+			// Always push the timestamp forward if there's been a read which
+			// occurred after our txn timestamp.
+			if !rTS.Less(header.Timestamp) {
+				header.Timestamp = rTS.Next()
+			}
+			// If there's a newer write timestamp...
+			if !wTS.Less(header.Timestamp) {
+				// If we're in a txn, we still go ahead and try the write since
+				// we want to avoid restarting the transaction in the event that
+				// there isn't an intent or the intent can be pushed by us.
+				//
+				// If we're not in a txn, it's trivial to just advance our timestamp.
+				if header.Txn == nil {
+					header.Timestamp = wTS.Next()
+				}
+			}
+			if !header.Timestamp.Equal(origTS) {
+				atomic.AddInt64(&r.tsCachePushes, 1)
 			}
 		}
-	}
 
-	defer trace.Epoch("raft")()
+		errChan, pendingCmd := r.proposeRaftCommand(ctx, args)
 
-	errChan, pendingCmd := r.proposeRaftCommand(ctx, args)
+		signal()
 
-	signal()
+		// First wait for raft to commit or abort the command.
+		reply = nil
+		if err = <-errChan; err == nil {
+			// Next if the command was committed, wait for the range to apply it.
+			respWithErr := <-pendingCmd.done
+			reply, err = respWithErr.Reply, respWithErr.Err
+			index = pendingCmd.index
+		} else {
+			r.abandonRaftCommand(pendingCmd)
+		}
 
-	// First wait for raft to commit or abort the command.
-	var err error
-	var reply proto.Response
-	if err = <-errChan; err == nil {
-		// Next if the command was committed, wait for the range to apply it.
-		respWithErr := <-pendingCmd.done
-		reply, err = respWithErr.Reply, respWithErr.Err
+		// A non-transactional write may still run into a newer write
+		// which landed after the timestamp cache was consulted. Rather
+		// than failing, retry it at a higher timestamp a few times.
+		wtoErr, ok := err.(*proto.WriteTooOldError)
+		if ok {
+			atomic.AddInt64(&r.writeTooOldErrors, 1)
+		}
+		if !ok || header.Txn != nil || retries >= maxWriteTooOldRetries {
+			break
+		}
+		header.Timestamp.Forward(wtoErr.ExistingTimestamp.Next())
+		// The failed attempt's error is stored in the response cache
+		// under its CmdID, so the retry needs an ID of its own. It's
+		// derived from the original so that a replay of the client's
+		// command finds the result of each attempt in turn instead of
+		// executing it again.
+		if !header.CmdID.IsEmpty() {
+			header.CmdID.Random++
+		}
+		trace.Event("retrying after WriteTooOldError")
+	}
+
+	// A transactional write whose timestamp was pushed above returns the
+	// transaction at the new timestamp, which the coordinator adopts as
+	// the transaction's timestamp.
+	if err == nil && header.Txn != nil && reply.Header().Txn == nil &&
+		header.Txn.Timestamp.Less(header.Timestamp) {
+		txn := gogoproto.Clone(header.Txn).(*proto.Transaction)
+		txn.Timestamp = header.Timestamp
+		reply.Header().Txn = txn
 	}
 
 	// As for reads, update timestamp cache with the timestamp
 	// of this write on success. This ensures a strictly higher
 	// timestamp for successive writes to the same key or key range.
 	r.endCmd(cmdKey, args, err, false /* !readOnly */)
+
+	// The write is committed and applied, but it's only acknowledged once
+	// persisted in the locality required by the range's zone, if any.
+	// Overlapping commands don't need to wait for this.
+	if err == nil {
+		if err = r.waitForQuorumAttrs(ctx, index, quorumIDs); err != nil {
+			return nil, err
+		}
+	}
 	return reply, err
 }
 
+// quorumReplicas returns the Raft IDs of the replicas on nodes matching
+// the range's quorum attributes, whose progress a write has to wait for
+// before it's acknowledged. None are returned if the range has no quorum
+// attributes or if this replica's node matches them. Node attributes are
+// taken from the store descriptors gossiped to the store pool. The check
+// happens before a write is proposed: a QuorumAckError is returned if no
+// replica of the range matches the attributes, or if only other replicas
+// do and this replica isn't the Raft leader, which is the only replica
+// to track the progress of the others. Leader leases and Raft leadership
+// are independent, so a lease holder which isn't the Raft leader fails
+// writes until leadership moves to it; retrying them on this replica
+// doesn't help, so the error isn't retryable.
+func (r *Replica) quorumReplicas() ([]proto.RaftNodeID, error) {
+	attrs := r.getQuorumAttrs()
+	if len(attrs.Attrs) == 0 {
+		return nil, nil
+	}
+	desc := r.Desc()
+	var matching []proto.RaftNodeID
+	if storePool := r.rm.allocator().storePool; storePool != nil {
+		for _, rep := range desc.Replicas {
+			storeDesc := storePool.getStoreDescriptor(rep.StoreID)
+			if storeDesc == nil || !attrs.IsSubset(storeDesc.Node.Attrs) {
+				continue
+			}
+			if rep.StoreID == r.rm.StoreID() {
+				return nil, nil
+			}
+			matching = append(matching, proto.MakeRaftNodeID(rep.NodeID, rep.StoreID))
+		}
+	}
+	if len(matching) == 0 {
+		return nil, &QuorumAckError{RangeID: desc.RangeID, Attrs: attrs}
+	}
+	if r.FollowerProgress() == nil {
+		return nil, &QuorumAckError{RangeID: desc.RangeID, Attrs: attrs,
+			Replicas: len(matching), NotRaftLeader: true}
+	}
+	return matching, nil
+}
+
+// waitForQuorumAttrs waits until one of the given replicas, as returned
+// by quorumReplicas, has persisted the Raft log up to the given index.
+// Their progress, as tracked by this replica as the Raft leader, is
+// polled with backoff. A QuorumAckError is returned if no replica
+// persists the index within the store's quorum ack timeout, or if this
+// replica loses Raft leadership in the meantime. The write has been
+// committed and applied by then; the error only reports that it wasn't
+// acknowledged in the required locality.
+func (r *Replica) waitForQuorumAttrs(ctx context.Context, index uint64, matching []proto.RaftNodeID) error {
+	if len(matching) == 0 {
+		return nil
+	}
+	qErr := &QuorumAckError{RangeID: r.Desc().RangeID, Index: index,
+		Attrs: r.getQuorumAttrs(), Replicas: len(matching)}
+
+	trace := tracer.FromCtx(ctx)
+	defer trace.Epoch("waiting for quorum attributes")()
+	deadline := time.Now().Add(r.rm.quorumAckTimeout())
+	retryOpts := quorumAckRetryOptions
+	retryOpts.Stopper = r.rm.Stopper()
+	for backoff := retry.Start(retryOpts); time.Now().Before(deadline) && backoff.Next(); {
+		progress := r.FollowerProgress()
+		if progress == nil {
+			qErr.NotRaftLeader = true
+			return qErr
+		}
+		for _, id := range matching {
+			if progress[id] >= index {
+				return nil
+			}
+		}
+	}
+	return qErr
+}
+
 // proposeRaftCommand prepares necessary pending command struct and
 // initializes a client command ID if one hasn't been. It then
 // proposes the command to Raft and returns the error channel and
 // pending command struct for receiving.
 func (r *Replica) proposeRaftCommand(ctx context.Context, args proto.Request) (<-chan error, *pendingCmd) {
 	pendingCmd := &pendingCmd{
-		ctx:  ctx,
-		done: make(chan proto.ResponseWithError, 1),
+		ctx:        ctx,
+		done:       make(chan proto.ResponseWithError, 1),
+		proposedAt: r.rm.Clock().PhysicalNow(),
 	}
 	raftCmd := proto.RaftCommand{
-		RangeID:      r.Desc().RangeID,
-		OriginNodeID: r.rm.RaftNodeID(),
+		RangeID:           r.Desc().RangeID,
+		OriginNodeID:      r.rm.RaftNodeID(),
+		Version:           proto.RaftCommandVersion,
+		SkipResponseCache: r.responseCacheDisabled(),
+		Hardened:          r.rm.hardenedMode(),
 	}
 	cmdID := args.Header().GetOrCreateCmdID(r.rm.Clock().PhysicalNow())
 	ok := raftCmd.Cmd.SetValue(args)
@@ -765,7 +2322,29 @@ func (r *Replica) proposeRaftCommand(ctx context.Context, args proto.Request) (<
 		log.Fatalc(ctx, "unknown command type %T", args)
 	}
 	idKey := makeCmdIDKey(cmdID)
+	pendingCmd.idKey = idKey
+	// The range may have been split or merged since the command's header
+	// was checked, for instance while the command waited in the command
+	// queue. Rather than proposing a command which no longer addresses
+	// this range, return an error so that the client retries it on the
+	// range now holding its keys.
+	if err := r.checkCmdHeader(args.Header()); err != nil {
+		errChan := make(chan error, 1)
+		errChan <- err
+		return errChan, pendingCmd
+	}
 	r.Lock()
+	// Bound the proposals in flight so that a busy range can't crowd out
+	// the others on the store. Lease requests are exempt since the range
+	// can't make progress without a lease. Proposals which Raft aborts
+	// must be released with abandonRaftCommand.
+	if max := r.rm.maxInflightProposals(); max > 0 && len(r.pendingCmds) >= max &&
+		args.Method() != proto.LeaderLease {
+		r.Unlock()
+		errChan := make(chan error, 1)
+		errChan <- &ProposalQuotaError{RangeID: raftCmd.RangeID, MaxInflight: max}
+		return errChan, pendingCmd
+	}
 	r.pendingCmds[idKey] = pendingCmd
 	r.Unlock()
 	errChan := r.rm.ProposeRaftCommand(idKey, raftCmd)
@@ -773,6 +2352,18 @@ func (r *Replica) proposeRaftCommand(ctx context.Context, args proto.Request) (<
 	return errChan, pendingCmd
 }
 
+// abandonRaftCommand removes a command whose proposal Raft aborted, as
+// signaled by an error on the channel returned by proposeRaftCommand,
+// from the pending commands, so that it no longer counts against the
+// range's proposals in flight.
+func (r *Replica) abandonRaftCommand(cmd *pendingCmd) {
+	r.Lock()
+	defer r.Unlock()
+	if r.pendingCmds[cmd.idKey] == cmd {
+		delete(r.pendingCmds, cmd.idKey)
+	}
+}
+
 // processRaftCommand processes a raft command by unpacking the command
 // struct to get args and reply and then applying the command to the
 // state machine via applyRaftCommand(). The error result is sent on
@@ -793,20 +2384,32 @@ func (r *Replica) processRaftCommand(idKey cmdIDKey, index uint64, raftCmd proto
 	if cmd != nil {
 		// We initiated this command, so use the caller-supplied context.
 		ctx = cmd.ctx
+		// The command is about to be applied; execution is not part of
+		// the replication latency.
+		r.replLatency.record(time.Duration(r.rm.Clock().PhysicalNow() - cmd.proposedAt))
 	} else {
 		// TODO(tschottdorf): consider the Trace situation here.
 		ctx = r.context()
 	}
 
 	execDone := tracer.FromCtx(ctx).Epoch(fmt.Sprintf("applying %s", args.Method()))
-	// applyRaftCommand will return "expected" errors, but may also indicate
-	// replica corruption (as of now, signaled by a replicaCorruptionError).
-	// We feed its return through maybeSetCorrupt to act when that happens.
-	reply, err := r.applyRaftCommand(ctx, index, proto.RaftNodeID(raftCmd.OriginNodeID), args)
+	var err error
+	if rangeID := r.Desc().RangeID; raftCmd.RangeID != rangeID {
+		// A command for another range was routed to this replica. Applying
+		// it would write foreign data, so treat it as corruption instead.
+		err = newReplicaCorruptionError(util.Errorf("command for range %d delivered to range %d",
+			raftCmd.RangeID, rangeID))
+	} else {
+		// applyRaftCommand will return "expected" errors, but may also indicate
+		// replica corruption (as of now, signaled by a replicaCorruptionError).
+		reply, err = r.applyRaftCommand(ctx, index, raftCmd, args)
+	}
+	// We feed the error through maybeSetCorrupt to act on corruption.
 	err = r.maybeSetCorrupt(err)
 	execDone()
 
 	if cmd != nil {
+		cmd.index = index
 		cmd.done <- proto.ResponseWithError{Reply: reply, Err: err}
 	} else if err != nil && log.V(1) {
 		log.Errorc(r.context(), "error executing raft command %s: %s", args.Method(), err)
@@ -815,11 +2418,30 @@ func (r *Replica) processRaftCommand(idKey cmdIDKey, index uint64, raftCmd proto
 	return err
 }
 
+// drainPendingCmds delivers the supplied error to every command which
+// is still waiting on its done channel and closes the channel. It is
+// used when the replica is removed and the pending commands will never
+// be applied locally. Commands are removed from pendingCmds under the
+// lock, so each one is completed either here or by processRaftCommand,
+// never by both.
+func (r *Replica) drainPendingCmds(err error) {
+	r.Lock()
+	cmds := r.pendingCmds
+	r.pendingCmds = map[cmdIDKey]*pendingCmd{}
+	r.Unlock()
+
+	for _, cmd := range cmds {
+		cmd.done <- proto.ResponseWithError{Err: err}
+		close(cmd.done)
+	}
+}
+
 // applyRaftCommand applies a raft command from the replicated log to the
 // underlying state machine (i.e. the engine).
 // When certain critical operations fail, a replicaCorruptionError may be
 // returned and must be handled by the caller.
-func (r *Replica) applyRaftCommand(ctx context.Context, index uint64, originNode proto.RaftNodeID, args proto.Request) (proto.Response, error) {
+func (r *Replica) applyRaftCommand(ctx context.Context, index uint64, raftCmd proto.RaftCommand,
+	args proto.Request) (proto.Response, error) {
 	if index <= 0 {
 		log.Fatalc(ctx, "raft command index is <= 0")
 	}
@@ -833,18 +2455,29 @@ func (r *Replica) applyRaftCommand(ctx context.Context, index uint64, originNode
 	// Call the helper, which returns a batch containing data written
 	// during command execution and any associated error.
 	ms := engine.MVCCStats{}
-	batch, reply, rErr := r.applyRaftCommandInBatch(ctx, index, originNode, args, &ms)
+	batch, reply, rErr := r.applyRaftCommandInBatch(ctx, index, raftCmd, args, &ms)
 	defer batch.Close()
 
 	// Advance the last applied index and commit the batch.
 	if err := setAppliedIndex(batch, r.Desc().RangeID, index); err != nil {
 		log.Fatalc(ctx, "setting applied index in a batch should never fail: %s", err)
 	}
-	if err := batch.Commit(); err != nil {
+	if err := r.commitAppliedBatch(batch); err != nil {
 		rErr = newReplicaCorruptionError(util.Errorf("could not commit batch"), err, rErr)
 	} else {
 		// Update cached appliedIndex if we were able to set the applied index on disk.
 		atomic.StoreUint64(&r.appliedIndex, index)
+		info := &AppliedCommandInfo{
+			Index:     index,
+			Method:    args.Method(),
+			Key:       args.Header().Key,
+			EndKey:    args.Header().EndKey,
+			Timestamp: args.Header().Timestamp,
+		}
+		if rErr != nil {
+			info.Error = rErr.Error()
+		}
+		atomic.StorePointer(&r.lastApplied, unsafe.Pointer(info))
 	}
 
 	// On successful write commands, flush to event feed, and handle other
@@ -852,6 +2485,10 @@ func (r *Replica) applyRaftCommand(ctx context.Context, index uint64, originNode
 	if rErr == nil && proto.IsWrite(args) {
 		// Publish update to event feed.
 		r.rm.EventFeed().updateRange(r, args.Method(), &ms)
+		// Notify the store's apply hook, if any.
+		if hook := r.rm.applyHook(); hook != nil {
+			hook(r.Desc().RangeID, index, args)
+		}
 		// If the commit succeeded, potentially add range to split queue.
 		r.maybeAddToSplitQueue()
 		// Maybe update gossip configs if the command is not part of a transaction.
@@ -868,14 +2505,64 @@ func (r *Replica) applyRaftCommand(ctx context.Context, index uint64, originNode
 	return reply, rErr
 }
 
+// commitAppliedBatch commits the batch of an applied command. Transient
+// engine errors are retried with backoff for up to the store's commit
+// grace period, so that a condition such as a full disk which is cleared
+// up in time doesn't get the replica marked as corrupt for good. Raft
+// commands are applied in order, so applying further commands waits
+// meanwhile. Other errors are returned right away.
+func (r *Replica) commitAppliedBatch(batch engine.Engine) error {
+	err := batch.Commit()
+	if err == nil || !isTransientEngineError(err) {
+		return err
+	}
+	deadline := time.Now().Add(r.rm.commitGracePeriod())
+	retryOpts := commitRetryOptions
+	retryOpts.Stopper = r.rm.Stopper()
+	for backoff := retry.Start(retryOpts); time.Now().Before(deadline) && backoff.Next(); {
+		log.Warningc(r.context(), "retrying commit of batch after transient error: %s", err)
+		if err = batch.Commit(); err == nil || !isTransientEngineError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isTransientEngineError returns whether err is an engine error which
+// may clear up by itself, such as the disk running out of space. RocksDB
+// reports system errors by their message only.
+func isTransientEngineError(err error) bool {
+	if pErr, ok := err.(*os.PathError); ok {
+		err = pErr.Err
+	}
+	msg := strings.ToLower(err.Error())
+	for _, errno := range transientEngineErrnos {
+		if strings.Contains(msg, errno.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
 // applyRaftCommandInBatch executes the command in a batch engine and
-// returns the batch containing the results. The caller is responsible
-// for committing the batch, even on error.
-func (r *Replica) applyRaftCommandInBatch(ctx context.Context, index uint64, originNode proto.RaftNodeID,
+// returns the batch containing the results. args is the request held
+// by raftCmd. The caller is responsible for committing the batch, even
+// on error.
+func (r *Replica) applyRaftCommandInBatch(ctx context.Context, index uint64, raftCmd proto.RaftCommand,
 	args proto.Request, ms *engine.MVCCStats) (engine.Engine, proto.Response, error) {
+	originNode, version := raftCmd.OriginNodeID, raftCmd.Version
 	// Create a new batch for the command to ensure all or nothing semantics.
 	batch := r.rm.Engine().NewBatch()
 
+	// Refuse to execute commands in a format newer than this node
+	// understands; they were proposed by a node running a newer version
+	// and may have different semantics. The command still consumes its
+	// log index, but nothing other than the applied index is written.
+	if version > proto.RaftCommandVersion {
+		return batch, nil, util.Errorf("cannot apply %s command of version %d; this node supports up to version %d",
+			args.Method(), version, proto.RaftCommandVersion)
+	}
+
 	if lease := r.getLease(); args.Method() != proto.LeaderLease &&
 		(!lease.OwnedBy(originNode) || !lease.Covers(args.Header().Timestamp)) {
 		// Verify the leader lease is held, unless this command is trying to
@@ -897,8 +2584,11 @@ func (r *Replica) applyRaftCommandInBatch(ctx context.Context, index uint64, ori
 		return batch, nil, r.newNotLeaderError(lease, originNode)
 	}
 
-	// Check the response cache to ensure idempotency.
-	if proto.IsWrite(args) {
+	// Check the response cache to ensure idempotency, unless the proposer
+	// disabled it for this range, in which case retried writes execute
+	// again.
+	useRespCache := proto.IsWrite(args) && !raftCmd.SkipResponseCache
+	if useRespCache {
 		if replyWithErr, readErr := r.respCache.GetResponse(batch, args.Header().CmdID); readErr != nil {
 			return batch, nil, newReplicaCorruptionError(util.Errorf("could not read from response cache"), readErr)
 		} else if replyWithErr.Reply != nil {
@@ -911,8 +2601,43 @@ func (r *Replica) applyRaftCommandInBatch(ctx context.Context, index uint64, ori
 		}
 	}
 
-	// Execute the command.
-	reply, intents, rErr := r.executeCmd(batch, ms, args)
+	if closed := r.getLease().ClosedTimestamp; violatesClosedTimestamp(args, closed) {
+		// The lease holder has promised not to accept writes at or below
+		// the closed timestamp, but this write was proposed before the
+		// promise was made. The client retries at a higher timestamp.
+		return batch, nil, &proto.WriteTooOldError{
+			Timestamp:         args.Header().Timestamp,
+			ExistingTimestamp: closed,
+		}
+	}
+
+	// Execute the command. In hardened mode, as decided by the proposer,
+	// the keys written by a write are recorded, and the write is refused
+	// if its outcome would corrupt the replica. Commands with side effects
+	// beyond the batch can't be undone once executed; only their MVCC
+	// stats are checked, and a violation halts the node before the batch
+	// is committed.
+	hardened := proto.IsWrite(args) && raftCmd.Hardened
+	var execBatch engine.Engine = batch
+	var recorder *keyRecordingEngine
+	if hardened && !hasSideEffects(args) {
+		recorder = &keyRecordingEngine{Engine: batch}
+		execBatch = recorder
+	}
+	execDone := traceCmd(tracer.FromCtx(ctx), args)
+	reply, intents, moreIntents, rErr := r.executeCmd(execBatch, ms, args)
+	execDone()
+	if hardened && rErr == nil {
+		if recorder == nil {
+			if err := r.checkApplyInvariants(args, *ms, nil); err != nil {
+				log.Fatalc(ctx, "applying command with side effects: %s", err)
+			}
+		} else if err := r.checkApplyInvariants(args, *ms, recorder.keys); err != nil {
+			// Discard the write; only the applied index is committed.
+			batch.Close()
+			return r.rm.Engine().NewBatch(), nil, newReplicaCorruptionError(err)
+		}
+	}
 	// Regardless of error, add result to the response cache if this is
 	// a write method. This must be done as part of the execution of
 	// raft commands so that every replica maintains the same responses
@@ -932,21 +2657,181 @@ func (r *Replica) applyRaftCommandInBatch(ctx context.Context, index uint64, ori
 		if reply == nil {
 			reply = args.CreateReply()
 		}
-		if err := r.respCache.PutResponse(batch, args.Header().CmdID,
-			proto.ResponseWithError{Reply: reply, Err: rErr}); err != nil {
-			log.Fatalc(ctx, "putting a response cache entry in a batch should never fail: %s", err)
+		if useRespCache && r.rm.verifyResponseCache() && !args.Header().CmdID.IsEmpty() {
+			r.verifyResponse(ctx, args, reply, rErr)
+		}
+		if useRespCache {
+			if err := r.respCache.PutResponse(batch, args.Header().CmdID,
+				proto.ResponseWithError{Reply: reply, Err: rErr}); err != nil {
+				log.Fatalc(ctx, "putting a response cache entry in a batch should never fail: %s", err)
+			}
 		}
 	}
 
 	// On the replica on which this command originated, resolve skipped intents
 	// asynchronously - even on failure.
 	if originNode == r.rm.RaftNodeID() {
-		r.handleSkippedIntents(args, intents)
+		r.handleSkippedIntents(args, intents, moreIntents)
 	}
 
 	return batch, reply, rErr
 }
 
+// verifyResponse executes the given write a second time against the
+// state it was originally executed on and logs an error if the outcome
+// differs from the given reply and error, which are about to be stored
+// in the response cache. Such a divergence means that the command is not
+// deterministic, so a retry answered from the response cache on another
+// replica might see a different response than the original execution.
+// Commands with side effects beyond the batch, as determined by
+// hasSideEffects, are skipped since executing them again would repeat
+// those side effects.
+func (r *Replica) verifyResponse(ctx context.Context, args proto.Request, reply proto.Response, rErr error) {
+	if hasSideEffects(args) {
+		return
+	}
+	batch := r.rm.Engine().NewBatch()
+	defer batch.Close()
+	ms := engine.MVCCStats{}
+	verifyReply, _, _, verifyErr := r.executeCmd(batch, &ms, args)
+	if verifyReply == nil {
+		verifyReply = args.CreateReply()
+	}
+	var divergence string
+	switch {
+	case (rErr == nil) != (verifyErr == nil) || (rErr != nil && rErr.Error() != verifyErr.Error()):
+		divergence = fmt.Sprintf("error %v, then %v", rErr, verifyErr)
+	case !reflect.DeepEqual(reply, verifyReply):
+		divergence = fmt.Sprintf("response %+v, then %+v", reply, verifyReply)
+	}
+	if divergence != "" {
+		atomic.AddInt64(&r.respCacheDivergences, 1)
+		log.Errorc(ctx, "non-deterministic execution of %s %+v: %s", args.Method(), args.Header().CmdID, divergence)
+	}
+}
+
+// checkApplyInvariants verifies that the outcome of the given write,
+// described by the MVCC stats delta and the keys it wrote, doesn't
+// violate the replica's invariants: the MVCC stats must not become
+// negative, and no key outside the range may be written.
+func (r *Replica) checkApplyInvariants(args proto.Request, ms engine.MVCCStats, keys []proto.EncodedKey) error {
+	stats := r.stats.GetMVCC()
+	stats.Add(&ms)
+	if hasNegativeMVCCStats(stats) {
+		return util.Errorf("%s would make MVCC stats negative: %+v", args.Method(), stats)
+	}
+	desc := r.Desc()
+	for _, key := range keys {
+		if !rangeDataContainsKey(desc, key) {
+			return util.Errorf("%s would write key %s outside of range %d", args.Method(), key, desc.RangeID)
+		}
+	}
+	return nil
+}
+
+// hasNegativeMVCCStats returns whether any of the byte or count values
+// of the given stats is negative.
+func hasNegativeMVCCStats(ms engine.MVCCStats) bool {
+	for _, v := range []int64{ms.LiveBytes, ms.KeyBytes, ms.ValBytes, ms.IntentBytes,
+		ms.LiveCount, ms.KeyCount, ms.ValCount, ms.IntentCount, ms.SysBytes, ms.SysCount} {
+		if v < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// keyRecordingEngine wraps an engine and records the keys written
+// through it.
+type keyRecordingEngine struct {
+	engine.Engine
+	keys []proto.EncodedKey
+}
+
+// Put implements the engine.Engine interface.
+func (e *keyRecordingEngine) Put(key proto.EncodedKey, value []byte) error {
+	e.keys = append(e.keys, key)
+	return e.Engine.Put(key, value)
+}
+
+// Clear implements the engine.Engine interface.
+func (e *keyRecordingEngine) Clear(key proto.EncodedKey) error {
+	e.keys = append(e.keys, key)
+	return e.Engine.Clear(key)
+}
+
+// Merge implements the engine.Engine interface.
+func (e *keyRecordingEngine) Merge(key proto.EncodedKey, value []byte) error {
+	e.keys = append(e.keys, key)
+	return e.Engine.Merge(key, value)
+}
+
+// hasSideEffects returns whether executing the command affects more
+// than the batch it's executed in. Leader lease and admin commands, and
+// EndTransaction commands carrying a commit trigger, update the
+// replica's or the store's in-memory state, so they must only be
+// executed when they're applied.
+func hasSideEffects(args proto.Request) bool {
+	if args.Method() == proto.LeaderLease || proto.IsAdmin(args) {
+		return true
+	}
+	et, ok := args.(*proto.EndTransactionRequest)
+	return ok && et.InternalCommitTrigger != nil
+}
+
+// ReplayCommand executes the given raft command as if it were applied
+// at the given log index and returns its result, for reproducing
+// apply-time behavior while debugging. The command is executed in a
+// scratch batch which is discarded afterwards, so neither the engine
+// nor the replica's in-memory state (applied index, stats, caches) is
+// modified. Commands with side effects beyond the batch, as determined
+// by hasSideEffects, are refused. Unlike applyRaftCommand, the leader
+// lease is not checked, so that commands captured under an earlier
+// lease can be replayed.
+func (r *Replica) ReplayCommand(raftCmd proto.RaftCommand, index uint64) (*proto.BatchResponse, error) {
+	if index == 0 {
+		return nil, util.Errorf("cannot replay command at index 0")
+	}
+	desc := r.Desc()
+	if raftCmd.RangeID != desc.RangeID {
+		return nil, util.Errorf("cannot replay command for range %d on range %d", raftCmd.RangeID, desc.RangeID)
+	}
+	if raftCmd.Version > proto.RaftCommandVersion {
+		return nil, util.Errorf("cannot replay command of version %d; this node supports up to version %d",
+			raftCmd.Version, proto.RaftCommandVersion)
+	}
+	args, ok := raftCmd.Cmd.GetValue().(proto.Request)
+	if !ok {
+		return nil, util.Errorf("raft command does not contain a request")
+	}
+	if hasSideEffects(args) {
+		return nil, util.Errorf("cannot replay %s command with side effects", args.Method())
+	}
+
+	batch := r.rm.Engine().NewBatch()
+	defer batch.Close()
+	if err := setAppliedIndex(batch, desc.RangeID, index); err != nil {
+		return nil, err
+	}
+	if proto.IsWrite(args) && !raftCmd.SkipResponseCache {
+		if replyWithErr, err := r.respCache.GetResponse(batch, args.Header().CmdID); err != nil {
+			return nil, err
+		} else if replyWithErr.Reply != nil {
+			br := &proto.BatchResponse{}
+			br.Add(replyWithErr.Reply)
+			return br, replyWithErr.Err
+		}
+	}
+	ms := engine.MVCCStats{}
+	reply, _, _, err := r.executeCmd(batch, &ms, args)
+	if reply == nil {
+		reply = args.CreateReply()
+	}
+	br := &proto.BatchResponse{}
+	br.Add(reply)
+	return br, err
+}
+
 // getLeaseForGossip tries to obtain a leader lease. Only one of the replicas
 // should gossip; the bool returned indicates whether it's us.
 func (r *Replica) getLeaseForGossip(ctx context.Context) (bool, error) {
@@ -979,8 +2864,11 @@ func (r *Replica) getLeaseForGossip(ctx context.Context) (bool, error) {
 }
 
 // maybeGossipFirstRange adds the sentinel and first range metadata to gossip
-// if this is the first range and a leader lease can be obtained. The Store
-// calls this periodically on first range replicas.
+// if this is the first range and a leader lease can be obtained. The cluster
+// ID is gossiped by all replicas of the first range. The first range
+// descriptor is only gossiped if it has changed or hasn't been gossiped
+// within firstRangeGossipMinInterval, for example by a previous lease holder.
+// The Store calls this periodically on first range replicas.
 func (r *Replica) maybeGossipFirstRange() error {
 	if !r.IsFirstRange() {
 		return nil
@@ -1008,15 +2896,42 @@ func (r *Replica) maybeGossipFirstRange() error {
 	if err := r.rm.Gossip().AddInfo(gossip.KeySentinel, []byte(r.rm.ClusterID()), clusterIDGossipTTL); err != nil {
 		log.Errorc(ctx, "failed to gossip cluster ID: %s", err)
 	}
+	descBytes, err := gogoproto.Marshal(desc)
+	if err != nil {
+		return err
+	}
+	if r.firstRangeGossipedRecently(descBytes) {
+		if log.V(1) {
+			log.Infoc(ctx, "skipping gossip of recently gossiped first range from store %d, range %d",
+				r.rm.StoreID(), desc.RangeID)
+		}
+		return nil
+	}
 	if log.V(1) {
 		log.Infoc(ctx, "gossiping first range from store %d, range %d", r.rm.StoreID(), desc.RangeID)
 	}
-	if err := r.rm.Gossip().AddInfoProto(gossip.KeyFirstRangeDescriptor, desc, configGossipTTL); err != nil {
+	if err := r.rm.Gossip().AddInfo(gossip.KeyFirstRangeDescriptor, descBytes, configGossipTTL); err != nil {
 		log.Errorc(ctx, "failed to gossip first range metadata: %s", err)
 	}
 	return nil
 }
 
+// firstRangeGossipedRecently returns true if the first range descriptor
+// currently in gossip matches the supplied encoded descriptor and was
+// added less than firstRangeGossipMinInterval ago.
+func (r *Replica) firstRangeGossipedRecently(descBytes []byte) bool {
+	g := r.rm.Gossip()
+	gossiped, err := g.GetInfo(gossip.KeyFirstRangeDescriptor)
+	if err != nil || !bytes.Equal(gossiped, descBytes) {
+		return false
+	}
+	ts, err := g.GetInfoTimestamp(gossip.KeyFirstRangeDescriptor)
+	if err != nil {
+		return false
+	}
+	return time.Since(ts) < firstRangeGossipMinInterval
+}
+
 // maybeGossipConfigs gossips those configuration maps for which the supplied
 // function returns true and whose contents are marked dirty. Configuration
 // maps include zones. The store is in charge of
@@ -1063,19 +2978,71 @@ func (r *Replica) maybeGossipConfigsLocked(match func(configPrefix proto.Key) bo
 			if r.configHashes == nil {
 				r.configHashes = map[int][]byte{}
 			}
-			if prevHash, ok := r.configHashes[i]; !ok || !bytes.Equal(prevHash, hash) {
+			if prevHash, ok := r.configHashes[i]; !ok || !bytes.Equal(prevHash, hash) || r.configGossipExpiring(cd) {
 				r.configHashes[i] = hash
 				if log.V(1) {
 					log.Infoc(ctx, "gossiping %s config from store %d, range %d", cd.gossipKey, r.rm.StoreID(), r.Desc().RangeID)
 				}
-				if err := r.rm.Gossip().AddInfoProto(cd.gossipKey, configMap, 0); err != nil {
+				if err := r.rm.Gossip().AddInfoProto(cd.gossipKey, configMap, cd.ttl); err != nil {
 					log.Errorc(ctx, "failed to gossip %s configMap: %s", cd.gossipKey, err)
 				}
+				close(r.configGossiped)
+				r.configGossiped = make(chan struct{})
 			}
 		}
 	}
 }
 
+// configGossipExpiring returns true if the config map described by cd
+// is gossiped with a finite TTL and more than half of it has passed
+// since the map was last gossiped (or the map isn't in gossip at all).
+func (r *Replica) configGossipExpiring(cd *configDescriptor) bool {
+	if cd.ttl == 0 {
+		return false
+	}
+	gossiped, err := r.rm.Gossip().GetInfoTimestamp(cd.gossipKey)
+	return err != nil || time.Since(gossiped) > cd.ttl/2
+}
+
+// WaitForConfigGossip blocks until the config map stored under the
+// config descriptor with the given gossip key (e.g. gossip.KeyConfigZone)
+// has been gossiped by this replica in its current form, or until the
+// context is canceled. It is intended for tests and tooling which need
+// to synchronize with the gossip of a config update.
+func (r *Replica) WaitForConfigGossip(ctx context.Context, key string) error {
+	idx := -1
+	for i, cd := range configDescriptors {
+		if cd.gossipKey == key {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return util.Errorf("unknown config gossip key %q", key)
+	}
+	cd := configDescriptors[idx]
+	for {
+		// Load the config map and grab the notification channel under
+		// the same lock so that no gossip can slip in between.
+		r.RLock()
+		_, hash, err := loadConfigMap(r.rm.Engine(), cd.keyPrefix, cd.configI)
+		gossiped := bytes.Equal(r.configHashes[idx], hash)
+		ch := r.configGossiped
+		r.RUnlock()
+		if err != nil {
+			return err
+		}
+		if gossiped {
+			return nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // maybeGossipSystemConfig scans the entire SystemDB span and gossips it.
 // The first call is on NewReplica. Further calls come from the trigger
 // on an EndTransactionRequest.
@@ -1121,12 +3088,31 @@ func (r *Replica) maybeGossipSystemConfigLocked() {
 	}
 }
 
-func (r *Replica) handleSkippedIntents(args proto.Request, intents []proto.Intent) {
+// SystemConfig loads the system config directly from this replica's
+// data, independent of the copy in gossip. The replica must contain the
+// SystemDB span. Note that unless this replica holds the leader lease,
+// the returned config may lag behind the most recent updates.
+func (r *Replica) SystemConfig() (*config.SystemConfig, error) {
+	if !r.ContainsKeyRange(keys.SystemDBSpan.Start, keys.SystemDBSpan.End) {
+		return nil, util.Errorf("range %d does not contain the system config span", r.Desc().RangeID)
+	}
+	cfg, _, err := loadSystemConfig(r.rm.Engine())
+	return cfg, err
+}
+
+// handleSkippedIntents asynchronously resolves the intents which the
+// given command stepped over. moreIntents indicates that the command
+// stepped over further intents which were not collected; these are
+// left for subsequent commands to run into.
+func (r *Replica) handleSkippedIntents(args proto.Request, intents []proto.Intent, moreIntents bool) {
 	if len(intents) == 0 {
 		return
 	}
 
 	ctx := r.context()
+	if moreIntents && log.V(1) {
+		log.Infoc(ctx, "%s skipped more than %d intents; resolving only those", args.Method(), len(intents))
+	}
 	stopper := r.rm.Stopper()
 	// TODO(tschottdorf): There's a chance that #1684 will make a comeback
 	// since intent resolution on commit has since moved to EndTransaction,
@@ -1178,6 +3164,119 @@ func newChainedError(errs ...error) *chainedError {
 	return ce
 }
 
+// A CommandQueueBusyError indicates that a command submitted via
+// TryAddCmd overlapped a command already executing on the replica.
+type CommandQueueBusyError struct {
+	Key, EndKey proto.Key
+}
+
+// Error implements the error interface.
+func (e *CommandQueueBusyError) Error() string {
+	return fmt.Sprintf("command queue busy for key range %s-%s", e.Key, e.EndKey)
+}
+
+// CanRetry implements the retry.Retryable interface.
+func (e *CommandQueueBusyError) CanRetry() bool { return true }
+
+// A WritesPausedError indicates that a write was submitted to a range
+// whose writes are paused for maintenance; see Replica.PauseWrites.
+type WritesPausedError struct {
+	RangeID proto.RangeID
+}
+
+// Error implements the error interface.
+func (e *WritesPausedError) Error() string {
+	return fmt.Sprintf("writes to range %d are paused", e.RangeID)
+}
+
+// CanRetry implements the retry.Retryable interface.
+func (e *WritesPausedError) CanRetry() bool { return true }
+
+// A ProposalQuotaError indicates that a command was not proposed
+// because the range already has the maximum number of proposals in
+// flight.
+type ProposalQuotaError struct {
+	RangeID     proto.RangeID
+	MaxInflight int
+}
+
+// Error implements the error interface.
+func (e *ProposalQuotaError) Error() string {
+	return fmt.Sprintf("range %d has reached its limit of %d proposals in flight", e.RangeID, e.MaxInflight)
+}
+
+// CanRetry implements the retry.Retryable interface.
+func (e *ProposalQuotaError) CanRetry() bool { return true }
+
+// A MergeTooLargeError indicates that a merge was refused because the
+// combined size of the ranges would exceed the zone's maximum range
+// size.
+type MergeTooLargeError struct {
+	RangeID, SubsumedRangeID proto.RangeID
+	Bytes, MaxBytes          int64
+}
+
+// Error implements the error interface.
+func (e *MergeTooLargeError) Error() string {
+	return fmt.Sprintf("merge of range %d into %d refused: combined size of %d bytes exceeds maximum of %d bytes",
+		e.SubsumedRangeID, e.RangeID, e.Bytes, e.MaxBytes)
+}
+
+// A MergeIntentsError indicates that a merge was aborted because the
+// open intents on the subsumed range could not be resolved in time.
+type MergeIntentsError struct {
+	RangeID, SubsumedRangeID proto.RangeID
+	Intents                  int
+}
+
+// Error implements the error interface.
+func (e *MergeIntentsError) Error() string {
+	return fmt.Sprintf("merge of range %d into %d aborted: %d intents could not be resolved",
+		e.SubsumedRangeID, e.RangeID, e.Intents)
+}
+
+// CanRetry implements the retry.Retryable interface.
+func (e *MergeIntentsError) CanRetry() bool { return true }
+
+// A QuorumAckError indicates that a write to a range whose zone sets
+// QuorumAttrs wasn't acknowledged because no replica in the required
+// locality persisted it in time, because the range has no replica in
+// that locality, or because the replica which received the write isn't
+// the Raft leader. If Index is set, the write has been committed and
+// applied, and a retry of the same command is answered from the response
+// cache once it's acknowledged. Otherwise, the write wasn't proposed.
+type QuorumAckError struct {
+	RangeID proto.RangeID
+	// Index is the Raft log index of the write, if it was proposed.
+	Index uint64
+	Attrs proto.Attributes
+	// Replicas is the number of replicas in the required locality.
+	Replicas int
+	// NotRaftLeader is set if the replica which received the write isn't
+	// the Raft leader, and so can't track the progress of the replicas in
+	// the required locality.
+	NotRaftLeader bool
+}
+
+// Error implements the error interface.
+func (e *QuorumAckError) Error() string {
+	if e.Replicas == 0 {
+		return fmt.Sprintf("range %d has no replica with attributes %s required to acknowledge writes",
+			e.RangeID, e.Attrs)
+	}
+	if e.NotRaftLeader {
+		return fmt.Sprintf("replica of range %d is not the Raft leader and can't track the %d replicas with attributes %s required to acknowledge writes",
+			e.RangeID, e.Replicas, e.Attrs)
+	}
+	return fmt.Sprintf("write at index %d of range %d was not persisted by any of %d replicas with attributes %s",
+		e.Index, e.RangeID, e.Replicas, e.Attrs)
+}
+
+// CanRetry implements the retry.Retryable interface. Without a replica
+// in the required locality, or while the replica which received the
+// write isn't the Raft leader, retries are futile.
+func (e *QuorumAckError) CanRetry() bool { return e.Replicas > 0 && !e.NotRaftLeader }
+
 // A replicaCorruptionError indicates that the replica has experienced an error
 // which puts its integrity at risk.
 type replicaCorruptionError struct {
@@ -1210,12 +3309,77 @@ func newReplicaCorruptionError(err ...error) *replicaCorruptionError {
 func (r *Replica) maybeSetCorrupt(err error) error {
 	if cErr, ok := err.(*replicaCorruptionError); ok && cErr != nil {
 		log.Errorc(r.context(), "stalling replica due to: %s", cErr.error)
+		atomic.StoreInt32(&r.corrupt, 1)
 		cErr.processed = true
 		return cErr
 	}
 	return err
 }
 
+// IntentRanges returns the descriptors of the ranges spanned by the
+// given intents, such as those of an EndTransaction request, ordered by
+// start key and without duplicates. A transaction coordinator can use
+// the result to resolve the intents on each of the ranges in parallel.
+// Ranges other than this replica's are looked up in the range metadata.
+func (r *Replica) IntentRanges(intents []proto.Intent) ([]proto.RangeDescriptor, error) {
+	seen := map[proto.RangeID]struct{}{}
+	var descs []proto.RangeDescriptor
+	for _, intent := range intents {
+		key := keys.KeyAddress(intent.Key)
+		endKey := key.Next()
+		if len(intent.EndKey) > 0 {
+			endKey = keys.KeyAddress(intent.EndKey)
+		}
+		for key.Less(endKey) {
+			desc, err := r.lookupRangeDescriptor(key)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := seen[desc.RangeID]; !ok {
+				seen[desc.RangeID] = struct{}{}
+				descs = append(descs, *desc)
+			}
+			key = desc.EndKey
+		}
+	}
+	sort.Sort(rangeDescriptorsByStartKey(descs))
+	return descs, nil
+}
+
+// lookupRangeDescriptor returns the descriptor of the range containing
+// the given key address, reading it from the range metadata unless the
+// key belongs to this replica.
+func (r *Replica) lookupRangeDescriptor(key proto.Key) (*proto.RangeDescriptor, error) {
+	if desc := r.Desc(); desc.ContainsKey(key) {
+		return desc, nil
+	}
+	startKey, endKey := keys.MetaScanBounds(keys.RangeMetaKey(key))
+	rows, err := r.rm.DB().Scan(startKey, endKey, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, util.Errorf("no range descriptor found for key %s", key)
+	}
+	desc := &proto.RangeDescriptor{}
+	if err := rows[0].ValueProto(desc); err != nil {
+		return nil, err
+	}
+	if !desc.ContainsKey(key) {
+		return nil, util.Errorf("range %d found for key %s does not contain it", desc.RangeID, key)
+	}
+	return desc, nil
+}
+
+// rangeDescriptorsByStartKey sorts range descriptors by start key.
+type rangeDescriptorsByStartKey []proto.RangeDescriptor
+
+func (r rangeDescriptorsByStartKey) Len() int      { return len(r) }
+func (r rangeDescriptorsByStartKey) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r rangeDescriptorsByStartKey) Less(i, j int) bool {
+	return r[i].StartKey.Less(r[j].StartKey)
+}
+
 // resolveIntents resolves the given intents. For those which are local to the
 // range, we submit directly to the range-local Raft instance; the call returns
 // as soon as all resolve commands have been **proposed** (not executed). This
@@ -1231,7 +3395,7 @@ func (r *Replica) resolveIntents(ctx context.Context, intents []proto.Intent) {
 	trace.Event("resolving intents [async]")
 	var wg sync.WaitGroup
 
-	bArgs := &proto.BatchRequest{}
+	var external []proto.Request
 	for i := range intents {
 		intent := intents[i] // avoids a race in `i, intent := range ...`
 		var resolveArgs proto.Request
@@ -1257,9 +3421,9 @@ func (r *Replica) resolveIntents(ctx context.Context, intents []proto.Intent) {
 		}
 
 		// If the intent isn't (completely) local, we'll need to send an external request.
-		// We'll batch them all up and send at the end.
+		// We'll batch them up and send them at the end.
 		if !local {
-			bArgs.Add(resolveArgs)
+			external = append(external, resolveArgs)
 			continue
 		}
 
@@ -1272,11 +3436,11 @@ func (r *Replica) resolveIntents(ctx context.Context, intents []proto.Intent) {
 		action := func() {
 			// Trace this under the ID of the intent owner.
 			ctx := tracer.ToCtx(ctx, r.rm.Tracer().NewTrace(resolveArgs.Header().Txn))
-			if _, err := r.addWriteCmd(ctx, resolveArgs, &wg); err != nil && log.V(1) {
+			if _, err := r.addWriteCmd(ctx, resolveArgs, &wg, false /* !nonBlocking */); err != nil && log.V(1) {
 				log.Warningc(ctx, "resolve for key %s failed: %s", intent.Key, err)
 			}
 		}
-		if !r.rm.Stopper().RunAsyncTask(action) {
+		if !r.rm.runAsyncIntentResolution(action) {
 			// Still run the task. Our caller already has a task and going async
 			// here again is merely for performance, but some intents need to
 			// be resolved because they might block other tasks. See #1684.
@@ -1285,25 +3449,31 @@ func (r *Replica) resolveIntents(ctx context.Context, intents []proto.Intent) {
 			action()
 		}
 	}
-	// Resolve all of the intents which aren't local to the Range. This is a
-	// no-op if all are local.
-	b := &client.Batch{}
-	b.InternalAddCall(proto.Call{Args: bArgs, Reply: &proto.BatchResponse{}})
-	action := func() {
-		// TODO(tschottdorf): no tracing here yet. Probably useful at some point,
-		// but needs a) the corresponding interface and b) facilities for tracing
-		// multiple tracees at the same time (batch full of possibly individual
-		// txns).
-		if err := r.rm.DB().Run(b); err != nil {
-			if log.V(1) {
-				log.Infoc(ctx, "%s", err)
+	// Resolve all of the intents which aren't local to the Range, in batches
+	// of bounded size which are sent one after the other. This is a no-op
+	// if all are local.
+	if batches := batchResolveRequests(external, resolveIntentBatchMaxCount,
+		resolveIntentBatchMaxBytes); len(batches) > 0 {
+		action := func() {
+			// TODO(tschottdorf): no tracing here yet. Probably useful at some point,
+			// but needs a) the corresponding interface and b) facilities for tracing
+			// multiple tracees at the same time (batch full of possibly individual
+			// txns).
+			for _, bArgs := range batches {
+				b := &client.Batch{}
+				b.InternalAddCall(proto.Call{Args: bArgs, Reply: &proto.BatchResponse{}})
+				if err := r.rm.DB().Run(b); err != nil {
+					if log.V(1) {
+						log.Infoc(ctx, "%s", err)
+					}
+				}
 			}
 		}
-	}
-	if !r.rm.Stopper().RunAsyncTask(action) {
-		// As with local intents, try async to not keep the caller waiting, but
-		// when draining just go ahead and do it synchronously. See #1684.
-		action()
+		if !r.rm.runAsyncIntentResolution(action) {
+			// As with local intents, try async to not keep the caller waiting, but
+			// when draining just go ahead and do it synchronously. See #1684.
+			action()
+		}
 	}
 
 	// Wait until all the local `ResolveIntent`s have been submitted to raft.
@@ -1311,6 +3481,27 @@ func (r *Replica) resolveIntents(ctx context.Context, intents []proto.Intent) {
 	wg.Wait()
 }
 
+// batchResolveRequests splits the supplied intent resolution requests
+// into batches holding at most maxCount requests and, unless a single
+// request exceeds it by itself, at most maxBytes of encoded requests.
+// The order of the requests is preserved.
+func batchResolveRequests(reqs []proto.Request, maxCount, maxBytes int) []*proto.BatchRequest {
+	var batches []*proto.BatchRequest
+	var bArgs *proto.BatchRequest
+	var size int
+	for _, args := range reqs {
+		argsSize := gogoproto.Size(args)
+		if bArgs == nil || len(bArgs.Requests) >= maxCount || size+argsSize > maxBytes {
+			bArgs = &proto.BatchRequest{}
+			batches = append(batches, bArgs)
+			size = 0
+		}
+		bArgs.Add(args)
+		size += argsSize
+	}
+	return batches
+}
+
 // loadConfigMap scans the config entries under keyPrefix and
 // instantiates/returns a config map and its sha256 hash. Prefix
 // configuration maps include zones.
@@ -1355,6 +3546,405 @@ func loadSystemConfig(eng engine.Engine) (*config.SystemConfig, []byte, error) {
 	return cfg, sha.Sum(nil), err
 }
 
+// effectiveZoneConfig returns the zone config which applies to the
+// range: the config of the most specific zone prefix matching the range's
+// start key in the gossiped prefix config map. A range which straddles a
+// zone boundary is subject to the config at its start key until the
+// split queue splits it along the boundary. The returned config is a
+// copy which the caller may modify.
+func (r *Replica) effectiveZoneConfig() (*config.ZoneConfig, error) {
+	g := r.rm.Gossip()
+	if g == nil {
+		return nil, util.Errorf("unable to lookup zone config for range %s: gossip not available", r)
+	}
+	zoneMap, err := g.GetZoneConfig()
+	if err != nil {
+		return nil, util.Errorf("unable to lookup zone config for range %s: %s", r, err)
+	}
+	prefixConfig := zoneMap.MatchByPrefix(r.Desc().StartKey)
+	// The gossiped map is shared; don't hand out a reference into it.
+	zone := prefixConfig.Config.GetValue().(*config.ZoneConfig)
+	return gogoproto.Clone(zone).(*config.ZoneConfig), nil
+}
+
+// ReplicationStatus returns the number of replicas in the range
+// descriptor, the number desired by the range's zone config and whether
+// the range is under-replicated as a result. It is computed from the
+// replica's descriptor and the locally gossiped zone configs; if no zone
+// config is available, the desired count is reported as zero.
+func (r *Replica) ReplicationStatus() (current int, desired int, underReplicated bool) {
+	current = len(r.Desc().Replicas)
+	if r.rm.Gossip() == nil {
+		return current, 0, false
+	}
+	zone, err := r.effectiveZoneConfig()
+	if err != nil {
+		if log.V(1) {
+			log.Infof("%s: unable to determine desired replication: %s", r, err)
+		}
+		return current, 0, false
+	}
+	desired = len(zone.ReplicaAttrs)
+	return current, desired, current < desired
+}
+
+// RangeHealth summarizes the health of a range as seen by one of its
+// replicas. See Replica.HealthCheck.
+type RangeHealth struct {
+	// LeaseValid is true if some replica holds a leader lease which
+	// covers the current time.
+	LeaseValid bool
+	// LeaseHolder is the Raft node holding the most recent lease, valid
+	// or not, or zero if there is none.
+	LeaseHolder proto.RaftNodeID
+	// UnderReplicated is true if the range has fewer replicas than its
+	// zone config calls for.
+	UnderReplicated bool
+	// Corrupt is true if the replica has encountered corruption.
+	Corrupt bool
+	// ApplyLag is the number of Raft log entries which have been
+	// persisted but not yet applied by the replica.
+	ApplyLag uint64
+	// Healthy is true if the lease is valid, the range is fully
+	// replicated and not corrupt, and the replica isn't lagging behind
+	// its log by more than maxHealthyApplyLag entries.
+	Healthy bool
+}
+
+// maxHealthyApplyLag is the maximum number of persisted but unapplied
+// Raft log entries for a replica to be considered healthy.
+const maxHealthyApplyLag = 1000
+
+// ReplicationLatency returns statistics on the time between proposing
+// a command on this replica and starting to apply it, which includes
+// Raft replication but not the execution of the command.
+func (r *Replica) ReplicationLatency() LatencyStats {
+	return r.replLatency.get()
+}
+
+// FollowerProgress returns, for each follower of this replica, the last
+// Raft log index the follower is known to have persisted, keyed by the
+// follower's Raft node ID. Only the Raft leader tracks the progress of
+// the followers; nil is returned if this replica isn't the leader.
+func (r *Replica) FollowerProgress() map[proto.RaftNodeID]uint64 {
+	status := r.rm.RaftStatus(r.Desc().RangeID)
+	if status == nil || status.SoftState.RaftState != raft.StateLeader {
+		return nil
+	}
+	progress := make(map[proto.RaftNodeID]uint64, len(status.Progress))
+	for id, p := range status.Progress {
+		if id != status.ID {
+			progress[proto.RaftNodeID(id)] = p.Match
+		}
+	}
+	return progress
+}
+
+// ContentionStats counts the conflicts encountered by writes to a
+// replica, which indicate contention on the range.
+type ContentionStats struct {
+	// TimestampCachePushes is the number of writes whose timestamp was
+	// pushed forward by the timestamp cache, because of a more recent
+	// read or write of an overlapping key.
+	TimestampCachePushes int64
+	// WriteTooOldErrors is the number of WriteTooOldErrors encountered by
+	// writes, including those which were retried at a higher timestamp.
+	WriteTooOldErrors int64
+}
+
+// ContentionStats returns the contention counters of this replica.
+func (r *Replica) ContentionStats() ContentionStats {
+	return ContentionStats{
+		TimestampCachePushes: atomic.LoadInt64(&r.tsCachePushes),
+		WriteTooOldErrors:    atomic.LoadInt64(&r.writeTooOldErrors),
+	}
+}
+
+// HealthCheck returns the health of the range as seen by this replica,
+// combining the state of the leader lease, replication, corruption and
+// the progress of applying the Raft log.
+func (r *Replica) HealthCheck() RangeHealth {
+	var h RangeHealth
+	lease := r.getLease()
+	h.LeaseHolder = lease.RaftNodeID
+	h.LeaseValid = lease.RaftNodeID != 0 && lease.Covers(r.rm.Clock().Now())
+	_, _, h.UnderReplicated = r.ReplicationStatus()
+	h.Corrupt = atomic.LoadInt32(&r.corrupt) != 0
+	lastIndex := atomic.LoadUint64(&r.lastIndex)
+	if appliedIndex := atomic.LoadUint64(&r.appliedIndex); appliedIndex < lastIndex {
+		h.ApplyLag = lastIndex - appliedIndex
+	}
+	h.Healthy = h.LeaseValid && !h.UnderReplicated && !h.Corrupt &&
+		h.ApplyLag <= maxHealthyApplyLag
+	return h
+}
+
+// RangeMetrics is a snapshot of the metrics of a replica. See
+// Replica.MetricsSnapshot.
+type RangeMetrics struct {
+	RangeID proto.RangeID
+	// MVCCStats are the MVCC stats of the range.
+	MVCCStats engine.MVCCStats
+	// PendingCmds is the number of commands proposed by the replica
+	// which haven't been applied yet.
+	PendingCmds int
+	// LastIndex is the last index persisted to the Raft log and
+	// AppliedIndex the last one applied by the replica.
+	LastIndex, AppliedIndex uint64
+	// Lease is the most recent leader lease known to the replica, and
+	// LeaseValid is true if it covers the current time.
+	Lease      proto.Lease
+	LeaseValid bool
+	// QPS is the rate of commands, in commands per second, over the last
+	// complete interval of qpsInterval.
+	QPS float64
+	// Contention counts the conflicts encountered by writes.
+	Contention ContentionStats
+}
+
+// MetricsSnapshot returns the metrics of this replica in a single
+// snapshot, for monitoring which wants all of them at once. Each metric
+// is read atomically, but metrics updated independently of each other
+// may be slightly skewed against one another.
+func (r *Replica) MetricsSnapshot() RangeMetrics {
+	m := RangeMetrics{
+		RangeID:    r.Desc().RangeID,
+		MVCCStats:  r.GetMVCCStats(),
+		Lease:      *r.getLease(),
+		Contention: r.ContentionStats(),
+	}
+	m.LeaseValid = m.Lease.RaftNodeID != 0 && m.Lease.Covers(r.rm.Clock().Now())
+	m.QPS = r.qps.get(r.rm.Clock().PhysicalNow())
+	m.LastIndex = atomic.LoadUint64(&r.lastIndex)
+	m.AppliedIndex = atomic.LoadUint64(&r.appliedIndex)
+	r.RLock()
+	m.PendingCmds = len(r.pendingCmds)
+	r.RUnlock()
+	return m
+}
+
+// AppliedCommandInfo summarizes a command applied by a replica. See
+// Replica.LastApplied.
+type AppliedCommandInfo struct {
+	// Index is the Raft log index of the command.
+	Index     uint64
+	Method    proto.Method
+	Key       proto.Key
+	EndKey    proto.Key
+	Timestamp proto.Timestamp
+	// Error is the message of the error the command failed with, if any.
+	Error string
+}
+
+// LastApplied returns a summary of the command most recently applied by
+// this replica, for debugging a misbehaving range. Only the summary is
+// retained, not the command itself. The zero value is returned if no
+// command has been applied since the replica was created.
+func (r *Replica) LastApplied() AppliedCommandInfo {
+	if info := (*AppliedCommandInfo)(atomic.LoadPointer(&r.lastApplied)); info != nil {
+		return *info
+	}
+	return AppliedCommandInfo{}
+}
+
+// ScanIntents synchronously scans the range for write intents which
+// were written more than maxAge ago and returns them. Where the owning
+// transaction's record lives on this range, the returned intent carries
+// the record (and thus its current status); otherwise it carries the
+// transaction as recorded in the intent. ScanIntents reads from a
+// snapshot and never resolves or pushes anything.
+func (r *Replica) ScanIntents(maxAge time.Duration) ([]proto.Intent, error) {
+	snap := r.rm.Engine().NewSnapshot()
+	defer snap.Close()
+	desc := r.Desc()
+	iter := newRangeDataIterator(desc, snap)
+	defer iter.Close()
+
+	threshold := r.rm.Clock().Now()
+	threshold.WallTime -= maxAge.Nanoseconds()
+
+	var intents []proto.Intent
+	for ; iter.Valid(); iter.Next() {
+		key, _, isValue := engine.MVCCDecodeKey(iter.Key())
+		if isValue {
+			continue
+		}
+		var meta engine.MVCCMetadata
+		if err := iter.ValueProto(&meta); err != nil {
+			return nil, err
+		}
+		if meta.Txn == nil || !meta.Timestamp.Less(threshold) {
+			continue
+		}
+		txn := *meta.Txn
+		if desc.ContainsKey(keys.KeyAddress(txn.Key)) {
+			var record proto.Transaction
+			txnKey := keys.TransactionKey(txn.Key, txn.ID)
+			if ok, err := engine.MVCCGetProto(snap, txnKey, proto.ZeroTimestamp, true, nil, &record); err != nil {
+				return nil, err
+			} else if ok {
+				txn = record
+			}
+		}
+		intents = append(intents, proto.Intent{Key: key, Txn: txn})
+	}
+	return intents, iter.Error()
+}
+
+// CompactRange compacts the storage engine over the range's key span
+// [StartKey, EndKey), for example to promptly reclaim the space held by
+// tombstones after a large deletion. It may be called concurrently with
+// normal traffic. Compactions are rate-limited per store, so the call
+// may block while other compactions complete.
+func (r *Replica) CompactRange() error {
+	desc := r.Desc()
+	return r.rm.compactRange(engine.MVCCEncodeKey(desc.StartKey), engine.MVCCEncodeKey(desc.EndKey))
+}
+
+// DataBounds returns the first and last user keys actually stored in
+// the range, which may lie well within the bounds of its descriptor.
+// Range-local metadata keys are not considered, while keys which are
+// deleted but not yet garbage collected are. Empty keys are returned if
+// the range holds no user data.
+func (r *Replica) DataBounds() (first, last proto.Key, err error) {
+	snap := r.rm.NewSnapshot()
+	defer snap.Close()
+	desc := r.Desc()
+	dataStartKey := desc.StartKey
+	if dataStartKey.Equal(proto.KeyMin) {
+		dataStartKey = keys.LocalMax
+	}
+	encStart := engine.MVCCEncodeKey(dataStartKey)
+	encEnd := engine.MVCCEncodeKey(desc.EndKey)
+
+	// The range data iterator visits the range-ID local keys, the
+	// range-local keys and the user keys in that order, and each seek
+	// past the end of one of them moves it to the start of the next.
+	iter := newRangeDataIterator(desc, snap)
+	defer iter.Close()
+	for iter.Seek(encStart); iter.Valid() && iter.Key().Less(encStart); iter.Seek(encStart) {
+	}
+	if !iter.Valid() {
+		return nil, nil, iter.Error()
+	}
+	first, _, _ = engine.MVCCDecodeKey(iter.Key())
+
+	// The range data iterator can't iterate in reverse, so find the last
+	// key with a plain iterator positioned before the range's EndKey.
+	revIter := snap.NewIterator()
+	defer revIter.Close()
+	revIter.SeekReverse(encEnd)
+	if revIter.Valid() && !revIter.Key().Less(encEnd) {
+		revIter.Prev()
+	}
+	if !revIter.Valid() || revIter.Key().Less(encStart) {
+		return nil, nil, revIter.Error()
+	}
+	last, _, _ = engine.MVCCDecodeKey(revIter.Key())
+	return first, last, nil
+}
+
+// VerifyDataBounds checks for data which was left behind by a botched
+// split or merge: keys addressed beyond the range's EndKey which no
+// other replica on the store claims. Such keys are invisible to all
+// replicas and indicate that the descriptor and the data disagree. It
+// returns the number of offending keys.
+func (r *Replica) VerifyDataBounds() (extraKeys int, err error) {
+	err = r.visitOutOfBoundsKeys(func(proto.Key) {
+		extraKeys++
+	})
+	return extraKeys, err
+}
+
+// OutOfBoundsKeys is like VerifyDataBounds, but returns the offending
+// keys instead of counting them.
+func (r *Replica) OutOfBoundsKeys() ([]proto.Key, error) {
+	var extra []proto.Key
+	err := r.visitOutOfBoundsKeys(func(key proto.Key) {
+		extra = append(extra, key)
+	})
+	return extra, err
+}
+
+// visitOutOfBoundsKeys invokes visit once for each key found by
+// VerifyDataBounds.
+func (r *Replica) visitOutOfBoundsKeys(visit func(proto.Key)) error {
+	snap := r.rm.NewSnapshot()
+	defer snap.Close()
+	desc := r.Desc()
+	// Iterate as if the range extended to the end of the keyspace. The
+	// replicas owning parts of that span are skipped over below.
+	wideDesc := *desc
+	wideDesc.EndKey = proto.KeyMax
+	iter := newRangeDataIterator(&wideDesc, snap)
+	defer iter.Close()
+
+	var lastKey proto.Key
+	for iter.Valid() {
+		key, _, _ := engine.MVCCDecodeKey(iter.Key())
+		if bytes.HasPrefix(key, keys.LocalRangeIDPrefix) || key.Equal(lastKey) {
+			iter.Next()
+			continue
+		}
+		lastKey = key
+		addr := keys.KeyAddress(key)
+		if desc.ContainsKey(addr) {
+			iter.Next()
+			continue
+		}
+		if other := r.rm.LookupReplica(addr, nil); other != nil {
+			// Skip the remainder of the other replica's span.
+			endKey := other.Desc().EndKey
+			if bytes.HasPrefix(key, keys.LocalRangePrefix) {
+				endKey = keys.MakeKey(keys.LocalRangePrefix, encoding.EncodeBytes(nil, endKey))
+			}
+			iter.Seek(engine.MVCCEncodeKey(endKey))
+			continue
+		}
+		visit(key)
+		iter.Next()
+	}
+	return iter.Error()
+}
+
+// ReplicaAddresses returns the replicas of the range joined with the
+// addresses of their nodes as gossiped in the node descriptors.
+// Replicas whose node descriptor is not (yet) available via gossip are
+// included with an empty address.
+func (r *Replica) ReplicaAddresses() ([]proto.ReplicaDescriptor, error) {
+	g := r.rm.Gossip()
+	desc := r.Desc()
+	replicas := make([]proto.ReplicaDescriptor, len(desc.Replicas))
+	for i, rep := range desc.Replicas {
+		replicas[i].Replica = rep
+		if g == nil {
+			continue
+		}
+		nodeDescBytes, err := g.GetInfo(gossip.MakeNodeIDKey(rep.NodeID))
+		if err != nil {
+			// Not gossiped yet (or expired).
+			continue
+		}
+		var nodeDesc proto.NodeDescriptor
+		if err := gogoproto.Unmarshal(nodeDescBytes, &nodeDesc); err != nil {
+			return nil, err
+		}
+		replicas[i].Address = nodeDesc.Address
+	}
+	return replicas, nil
+}
+
+// ResponseCacheChecksum returns a sha256 checksum of the entries in
+// this range's response cache, computed on a consistent snapshot of
+// the engine. Since the response cache is written as part of applying
+// Raft commands, divergent checksums across replicas of the same range
+// indicate a replication bug.
+func (r *Replica) ResponseCacheChecksum() ([]byte, error) {
+	snap := r.rm.NewSnapshot()
+	defer snap.Close()
+	return r.respCache.Checksum(snap)
+}
+
 // maybeAddToSplitQueue checks whether the current size of the range
 // exceeds the max size specified in the zone config. If yes, the
 // range is added to the split queue.
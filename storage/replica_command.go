@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/cockroachdb/cockroach/client"
@@ -33,31 +34,35 @@ import (
 	"github.com/cockroachdb/cockroach/storage/engine"
 	"github.com/cockroachdb/cockroach/util"
 	"github.com/cockroachdb/cockroach/util/log"
+	"github.com/cockroachdb/cockroach/util/retry"
 	gogoproto "github.com/gogo/protobuf/proto"
 )
 
 // executeCmd switches over the method and multiplexes to execute the
 // appropriate storage API command. It returns the response, an error,
-// and a slice of intents that were skipped during execution.
-// If an error is returned, any returned intents should still be resolved.
-func (r *Replica) executeCmd(batch engine.Engine, ms *engine.MVCCStats, args proto.Request) (proto.Response, []proto.Intent, error) {
+// and a slice of intents that were skipped during execution. A scan
+// collects at most maxIntentsPerRead intents; the returned bool is true
+// if it skipped over further ones. If an error is returned, any returned
+// intents should still be resolved.
+func (r *Replica) executeCmd(batch engine.Engine, ms *engine.MVCCStats, args proto.Request) (proto.Response, []proto.Intent, bool, error) {
 	// Verify key is contained within range here to catch any range split
 	// or merge activity.
 	header := args.Header()
 
 	if err := r.checkCmdHeader(header); err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	// If a unittest filter was installed, check for an injected error; otherwise, continue.
 	if TestingCommandFilter != nil {
 		if err := TestingCommandFilter(args); err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 	}
 
 	var reply proto.Response
 	var intents []proto.Intent
+	var moreIntents bool
 	var err error
 	switch tArgs := args.(type) {
 	case *proto.GetRequest:
@@ -72,10 +77,18 @@ func (r *Replica) executeCmd(batch engine.Engine, ms *engine.MVCCStats, args pro
 		var resp proto.ConditionalPutResponse
 		resp, err = r.ConditionalPut(batch, ms, *tArgs)
 		reply = &resp
+	case *proto.CheckAndPutRequest:
+		var resp proto.CheckAndPutResponse
+		resp, err = r.CheckAndPut(batch, ms, *tArgs)
+		reply = &resp
 	case *proto.IncrementRequest:
 		var resp proto.IncrementResponse
 		resp, err = r.Increment(batch, ms, *tArgs)
 		reply = &resp
+	case *proto.MultiIncrementRequest:
+		var resp proto.MultiIncrementResponse
+		resp, err = r.MultiIncrement(batch, ms, *tArgs)
+		reply = &resp
 	case *proto.DeleteRequest:
 		var resp proto.DeleteResponse
 		resp, err = r.Delete(batch, ms, *tArgs)
@@ -86,11 +99,11 @@ func (r *Replica) executeCmd(batch engine.Engine, ms *engine.MVCCStats, args pro
 		reply = &resp
 	case *proto.ScanRequest:
 		var resp proto.ScanResponse
-		resp, intents, err = r.Scan(batch, *tArgs)
+		resp, intents, moreIntents, err = r.Scan(batch, *tArgs)
 		reply = &resp
 	case *proto.ReverseScanRequest:
 		var resp proto.ReverseScanResponse
-		resp, intents, err = r.ReverseScan(batch, *tArgs)
+		resp, intents, moreIntents, err = r.ReverseScan(batch, *tArgs)
 		reply = &resp
 	case *proto.EndTransactionRequest:
 		var resp proto.EndTransactionResponse
@@ -148,6 +161,14 @@ func (r *Replica) executeCmd(batch engine.Engine, ms *engine.MVCCStats, args pro
 
 	// Propagate the request timestamp (which may have changed).
 	reply.Header().Timestamp = header.Timestamp
+	if header.RecordReadSet {
+		reply.Header().ReadSet = readSet(args, reply)
+	}
+
+	// A value which doesn't match its checksum was corrupted at rest.
+	if cErr, ok := err.(*proto.ChecksumError); ok && proto.IsReadOnly(args) {
+		err = r.maybeSetCorrupt(newReplicaCorruptionError(cErr))
+	}
 
 	// A ReadWithinUncertaintyIntervalError contains the timestamp of the value
 	// that provoked the conflict. However, we forward the timestamp to the
@@ -165,15 +186,57 @@ func (r *Replica) executeCmd(batch engine.Engine, ms *engine.MVCCStats, args pro
 		tErr.ExistingTimestamp.Forward(r.rm.Clock().Now())
 	}
 
-	return reply, intents, err
+	return reply, intents, moreIntents, err
+}
+
+// readStats returns the given statistics of the data read to produce
+// a reply, or nil if no data was read.
+func readStats(stats proto.ExecutionStats) *proto.ExecutionStats {
+	if stats.KeysScanned == 0 {
+		return nil
+	}
+	return &stats
+}
+
+// readSet returns the keys read to produce the reply to the given
+// command along with the timestamps of the versions read. A key read by
+// a Get which had no value is included with a zero timestamp, as a
+// later write to it invalidates the read as well.
+func readSet(args proto.Request, reply proto.Response) []proto.ReadSetEntry {
+	var rows []proto.KeyValue
+	switch t := reply.(type) {
+	case *proto.GetResponse:
+		entry := proto.ReadSetEntry{Key: args.Header().Key}
+		if t.Value != nil && t.Value.Timestamp != nil {
+			entry.Timestamp = *t.Value.Timestamp
+		}
+		return []proto.ReadSetEntry{entry}
+	case *proto.ScanResponse:
+		rows = t.Rows
+	case *proto.ReverseScanResponse:
+		rows = t.Rows
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	entries := make([]proto.ReadSetEntry, len(rows))
+	for i, kv := range rows {
+		entries[i].Key = kv.Key
+		if kv.Value.Timestamp != nil {
+			entries[i].Timestamp = *kv.Value.Timestamp
+		}
+	}
+	return entries
 }
 
 // Get returns the value for a specified key.
 func (r *Replica) Get(batch engine.Engine, args proto.GetRequest) (proto.GetResponse, []proto.Intent, error) {
 	var reply proto.GetResponse
 
-	val, intents, err := engine.MVCCGet(batch, args.Key, args.Timestamp, args.ReadConsistency == proto.CONSISTENT, args.Txn)
+	var stats proto.ExecutionStats
+	val, intents, err := engine.MVCCGetWithStats(batch, args.Key, args.Timestamp, args.ReadConsistency == proto.CONSISTENT, args.Txn, &stats)
 	reply.Value = val
+	reply.Stats = readStats(stats)
 	return reply, intents, err
 }
 
@@ -193,6 +256,15 @@ func (r *Replica) ConditionalPut(batch engine.Engine, ms *engine.MVCCStats, args
 	return reply, engine.MVCCConditionalPut(batch, ms, args.Key, args.Timestamp, args.Value, args.ExpValue, args.Txn)
 }
 
+// CheckAndPut sets the value for a specified key only if the latest
+// version of the key is no newer than the expected timestamp. If not,
+// the return value contains the actual value.
+func (r *Replica) CheckAndPut(batch engine.Engine, ms *engine.MVCCStats, args proto.CheckAndPutRequest) (proto.CheckAndPutResponse, error) {
+	var reply proto.CheckAndPutResponse
+
+	return reply, engine.MVCCCheckAndPut(batch, ms, args.Key, args.Timestamp, args.Value, args.ExpTimestamp, args.Txn)
+}
+
 // Increment increments the value (interpreted as varint64 encoded) and
 // returns the newly incremented value (encoded as varint64). If no value
 // exists for the key, zero is incremented.
@@ -204,6 +276,31 @@ func (r *Replica) Increment(batch engine.Engine, ms *engine.MVCCStats, args prot
 	return reply, err
 }
 
+// MultiIncrement increments the values of several keys, each by its own
+// amount, and returns the new values. The increments are applied
+// atomically: they share the command's batch, which is discarded if
+// any of them fails.
+func (r *Replica) MultiIncrement(batch engine.Engine, ms *engine.MVCCStats, args proto.MultiIncrementRequest) (proto.MultiIncrementResponse, error) {
+	var reply proto.MultiIncrementResponse
+
+	if len(args.Keys) != len(args.Increments) {
+		return reply, util.Errorf("%d keys but %d increments", len(args.Keys), len(args.Increments))
+	}
+	newValues := make([]int64, 0, len(args.Keys))
+	for i, key := range args.Keys {
+		if key.Less(args.Key) || !key.Less(args.EndKey) {
+			return reply, util.Errorf("key %q outside of the request span [%q, %q)", key, args.Key, args.EndKey)
+		}
+		newVal, err := engine.MVCCIncrement(batch, ms, key, args.Timestamp, args.Txn, args.Increments[i])
+		if err != nil {
+			return reply, err
+		}
+		newValues = append(newValues, newVal)
+	}
+	reply.NewValues = newValues
+	return reply, nil
+}
+
 // Delete deletes the key and value specified by key.
 func (r *Replica) Delete(batch engine.Engine, ms *engine.MVCCStats, args proto.DeleteRequest) (proto.DeleteResponse, error) {
 	var reply proto.DeleteResponse
@@ -212,34 +309,69 @@ func (r *Replica) Delete(batch engine.Engine, ms *engine.MVCCStats, args proto.D
 }
 
 // DeleteRange deletes the range of key/value pairs specified by
-// start and end keys.
+// start and end keys. If a predicate timestamp is specified, only keys
+// whose latest version predates it are deleted. If the request asks for
+// the deleted keys to be returned, at most maxDeleteRangeReturnedKeys
+// keys are deleted and a resume key is returned when a limit is reached.
 func (r *Replica) DeleteRange(batch engine.Engine, ms *engine.MVCCStats, args proto.DeleteRangeRequest) (proto.DeleteRangeResponse, error) {
 	var reply proto.DeleteRangeResponse
 
-	numDel, err := engine.MVCCDeleteRange(batch, ms, args.Key, args.EndKey, args.MaxEntriesToDelete, args.Timestamp, args.Txn)
-	reply.NumDeleted = numDel
+	var filter func(proto.KeyValue) bool
+	if args.PredicateTimestamp != nil {
+		predTS := *args.PredicateTimestamp
+		filter = func(kv proto.KeyValue) bool {
+			return kv.Value.Timestamp != nil && kv.Value.Timestamp.Less(predTS)
+		}
+	}
+	max := args.MaxEntriesToDelete
+	if args.ReturnKeys && (max == 0 || max > maxDeleteRangeReturnedKeys) {
+		max = maxDeleteRangeReturnedKeys
+	}
+	var stats proto.ExecutionStats
+	keys, err := engine.MVCCFilteredDeleteRange(batch, ms, args.Key, args.EndKey, max, args.Timestamp, args.Txn, filter, &stats)
+	reply.NumDeleted = int64(len(keys))
+	reply.Stats = readStats(stats)
+	if args.ReturnKeys {
+		reply.Keys = keys
+		if max > 0 && int64(len(keys)) == max {
+			reply.ResumeKey = keys[len(keys)-1].Next()
+		}
+	}
 	return reply, err
 }
 
 // Scan scans the key range specified by start key through end key in ascending
-// order up to some maximum number of results.
-func (r *Replica) Scan(batch engine.Engine, args proto.ScanRequest) (proto.ScanResponse, []proto.Intent, error) {
+// order up to some maximum number of results. At most maxIntentsPerRead
+// skipped intents are returned; the returned bool is true if there were
+// more.
+func (r *Replica) Scan(batch engine.Engine, args proto.ScanRequest) (proto.ScanResponse, []proto.Intent, bool, error) {
 	var reply proto.ScanResponse
 
-	rows, intents, err := engine.MVCCScan(batch, args.Key, args.EndKey, args.MaxResults, args.Timestamp, args.ReadConsistency == proto.CONSISTENT, args.Txn)
+	var filter func(proto.KeyValue) bool
+	if args.Predicate != nil {
+		filter = args.Predicate.Matches
+	}
+	var stats proto.ExecutionStats
+	rows, intents, moreIntents, err := engine.MVCCLimitedIntentScan(batch, args.Key, args.EndKey, args.MaxResults,
+		r.rm.maxIntentsPerRead(), args.Timestamp, args.ReadConsistency == proto.CONSISTENT, args.Txn, false /* !reverse */, filter, &stats)
 	reply.Rows = rows
-	return reply, intents, err
+	reply.Stats = readStats(stats)
+	return reply, intents, moreIntents, err
 }
 
 // ReverseScan scans the key range specified by start key through end key in
-// descending order up to some maximum number of results.
-func (r *Replica) ReverseScan(batch engine.Engine, args proto.ReverseScanRequest) (proto.ReverseScanResponse, []proto.Intent, error) {
+// descending order up to some maximum number of results. At most
+// maxIntentsPerRead skipped intents are returned; the returned bool is
+// true if there were more.
+func (r *Replica) ReverseScan(batch engine.Engine, args proto.ReverseScanRequest) (proto.ReverseScanResponse, []proto.Intent, bool, error) {
 	var reply proto.ReverseScanResponse
 
-	rows, intents, err := engine.MVCCReverseScan(batch, args.Key, args.EndKey, args.MaxResults, args.Timestamp,
-		args.ReadConsistency == proto.CONSISTENT, args.Txn)
+	var stats proto.ExecutionStats
+	rows, intents, moreIntents, err := engine.MVCCLimitedIntentScan(batch, args.Key, args.EndKey, args.MaxResults,
+		r.rm.maxIntentsPerRead(), args.Timestamp, args.ReadConsistency == proto.CONSISTENT, args.Txn, true /* reverse */, nil /* filter */, &stats)
 	reply.Rows = rows
-	return reply, intents, err
+	reply.Stats = readStats(stats)
+	return reply, intents, moreIntents, err
 }
 
 // EndTransaction either commits or aborts (rolls back) an extant
@@ -321,6 +453,11 @@ func (r *Replica) EndTransaction(batch engine.Engine, ms *engine.MVCCStats, args
 	// record timestamp as the final commit timestamp.
 	reply.Txn.Timestamp.Forward(args.Timestamp)
 
+	// Record the final intent count kept by the coordinator.
+	if reply.Txn.IntentCount < args.Txn.IntentCount {
+		reply.Txn.IntentCount = args.Txn.IntentCount
+	}
+
 	// Set transaction status to COMMITTED or ABORTED as per the
 	// args.Commit parameter.
 	if args.Commit {
@@ -650,49 +787,81 @@ func (r *Replica) RangeLookup(batch engine.Engine, args proto.RangeLookupRequest
 
 // HeartbeatTxn updates the transaction status and heartbeat
 // timestamp after receiving transaction heartbeat messages from
-// coordinator. Returns the updated transaction.
+// coordinator. Returns the updated transaction. If the request lists
+// several transactions, each of their records is updated and returned
+// in turn, so that a coordinator can heartbeat all of its transactions
+// anchored in this range with a single command.
 func (r *Replica) HeartbeatTxn(batch engine.Engine, ms *engine.MVCCStats, args proto.HeartbeatTxnRequest) (proto.HeartbeatTxnResponse, error) {
 	var reply proto.HeartbeatTxnResponse
 
+	if len(args.Txns) > 0 {
+		for i := range args.Txns {
+			txn := &args.Txns[i]
+			if !txn.Key.Equal(args.Key) && (txn.Key.Less(args.Key) || !txn.Key.Less(args.EndKey)) {
+				return reply, util.Errorf("txn key %s is outside of request span [%s,%s)", txn.Key, args.Key, args.EndKey)
+			}
+			updated, err := heartbeatTxn(batch, ms, txn, args.Timestamp)
+			if err != nil {
+				return reply, err
+			}
+			reply.Txns = append(reply.Txns, updated)
+		}
+		return reply, nil
+	}
+
 	if args.Txn == nil {
 		return reply, util.Errorf("no transaction specified to HeartbeatTxn")
 	}
 	if !bytes.Equal(args.Key, args.Txn.Key) {
 		return reply, util.Errorf("request key %s should match txn key %s", args.Key, args.Txn.Key)
 	}
+	txn, err := heartbeatTxn(batch, ms, args.Txn, args.Timestamp)
+	if err != nil {
+		return reply, err
+	}
+	reply.Txn = &txn
+	return reply, nil
+}
 
-	key := keys.TransactionKey(args.Txn.Key, args.Txn.ID)
+// heartbeatTxn moves the last heartbeat of the given transaction's
+// record forward to the given timestamp if the transaction is pending,
+// creating the record if it doesn't exist. The intent count reported by
+// the coordinator is recorded as well. Returns the record.
+func heartbeatTxn(batch engine.Engine, ms *engine.MVCCStats, reqTxn *proto.Transaction, timestamp proto.Timestamp) (proto.Transaction, error) {
+	key := keys.TransactionKey(reqTxn.Key, reqTxn.ID)
 
 	var txn proto.Transaction
 	if ok, err := engine.MVCCGetProto(batch, key, proto.ZeroTimestamp, true, nil, &txn); err != nil {
-		return reply, err
+		return txn, err
 	} else if !ok {
 		// If no existing transaction record was found, initialize to a
-		// shallow copy of the transaction in the request header. We copy
-		// to avoid mutating the original below.
-		txn = *args.Txn
+		// shallow copy of the transaction in the request. We copy to
+		// avoid mutating the original below.
+		txn = *reqTxn
 	}
 
 	if txn.Status == proto.PENDING {
 		if txn.LastHeartbeat == nil {
 			txn.LastHeartbeat = &proto.Timestamp{}
 		}
-		if txn.LastHeartbeat.Less(args.Header().Timestamp) {
-			*txn.LastHeartbeat = args.Header().Timestamp
+		if txn.LastHeartbeat.Less(timestamp) {
+			*txn.LastHeartbeat = timestamp
+		}
+		if txn.IntentCount < reqTxn.IntentCount {
+			txn.IntentCount = reqTxn.IntentCount
 		}
 		if err := engine.MVCCPutProto(batch, ms, key, proto.ZeroTimestamp, nil, &txn); err != nil {
-			return reply, err
+			return txn, err
 		}
 	}
-
-	reply.Txn = &txn
-	return reply, nil
+	return txn, nil
 }
 
 // GC iterates through the list of keys to garbage collect
 // specified in the arguments. MVCCGarbageCollect is invoked on each
-// listed key along with the expiration timestamp. The GC metadata
-// specified in the args is persisted after GC.
+// listed key along with the expiration timestamp. Listed transaction
+// records are removed if their transaction has been aborted. The GC
+// metadata specified in the args is persisted after GC.
 func (r *Replica) GC(batch engine.Engine, ms *engine.MVCCStats, args proto.GCRequest) (proto.GCResponse, error) {
 	var reply proto.GCResponse
 
@@ -701,6 +870,20 @@ func (r *Replica) GC(batch engine.Engine, ms *engine.MVCCStats, args proto.GCReq
 		return reply, err
 	}
 
+	// Remove abandoned transaction records. The status is checked again
+	// here since the record may have changed after the GC queue read it.
+	for _, key := range args.TxnKeys {
+		var txn proto.Transaction
+		if ok, err := engine.MVCCGetProto(batch, key, proto.ZeroTimestamp, true, nil, &txn); err != nil {
+			return reply, err
+		} else if !ok || txn.Status != proto.ABORTED {
+			continue
+		}
+		if err := engine.MVCCDelete(batch, ms, key, proto.ZeroTimestamp, nil); err != nil {
+			return reply, err
+		}
+	}
+
 	// Store the GC metadata for this range.
 	key := keys.RangeGCMetadataKey(r.Desc().RangeID)
 	if err := engine.MVCCPutProto(batch, ms, key, proto.ZeroTimestamp, nil, &args.GCMeta); err != nil {
@@ -1013,6 +1196,9 @@ func (r *Replica) LeaderLease(batch engine.Engine, ms *engine.MVCCStats, args pr
 	}
 
 	args.Lease.Start = effectiveStart
+	// The closed timestamp only ever ratchets forward, regardless of
+	// which replica holds the lease.
+	args.Lease.ClosedTimestamp.Forward(prevLease.ClosedTimestamp)
 
 	rangeID := r.Desc().RangeID
 
@@ -1022,13 +1208,29 @@ func (r *Replica) LeaderLease(batch engine.Engine, ms *engine.MVCCStats, args pr
 	}
 	atomic.StorePointer(&r.lease, unsafe.Pointer(&args.Lease))
 
+	switch {
+	case isExtension:
+		r.recordLeaseEvent(LeaseExtended, args.Lease)
+	case !args.TimestampCacheLowWater.Equal(proto.ZeroTimestamp):
+		r.recordLeaseEvent(LeaseTransferred, args.Lease)
+	default:
+		r.recordLeaseEvent(LeaseAcquired, args.Lease)
+	}
+
 	// If this replica is a new holder of the lease, update the
 	// low water mark in the timestamp cache. We add the maximum
 	// clock offset to account for any difference in clocks
 	// between the expiration (set by a remote node) and this
 	// node.
+	//
+	// If the previous holder transferred the lease, it handed over
+	// its timestamp cache instead, which is at least as accurate.
 	if r.getLease().RaftNodeID == r.rm.RaftNodeID() && prevLease.RaftNodeID != r.getLease().RaftNodeID {
-		r.tsCache.SetLowWater(prevLease.Expiration.Add(int64(r.rm.Clock().MaxOffset()), 0))
+		if args.TimestampCacheLowWater.Equal(proto.ZeroTimestamp) {
+			r.tsCache.SetLowWater(prevLease.Expiration.Add(int64(r.rm.Clock().MaxOffset()), 0))
+		} else {
+			r.tsCache.Import(args.TimestampCacheLowWater, args.TimestampCache)
+		}
 		log.Infof("range %d: new leader lease %s", rangeID, args.Lease)
 	}
 
@@ -1039,6 +1241,18 @@ func (r *Replica) LeaderLease(batch engine.Engine, ms *engine.MVCCStats, args pr
 	return reply, nil
 }
 
+// computeSplitKeyBySize returns the key which splits the range into two
+// halves of roughly equal size in bytes, as opposed to equal key counts.
+// The range is walked in key order on a snapshot, accumulating the size
+// of keys and values, and the first valid split key at which the
+// cumulative size is closest to half of the range's total is chosen.
+func (r *Replica) computeSplitKeyBySize() (proto.Key, error) {
+	desc := r.Desc()
+	snap := r.rm.NewSnapshot()
+	defer snap.Close()
+	return engine.MVCCFindSplitKey(snap, desc.RangeID, desc.StartKey, desc.EndKey)
+}
+
 // AdminSplit divides the range into into two ranges, using either
 // args.SplitKey (if provided) or an internally computed key that aims to
 // roughly equipartition the range by size. The split is done inside of
@@ -1061,9 +1275,7 @@ func (r *Replica) AdminSplit(args proto.AdminSplitRequest, desc *proto.RangeDesc
 	// other commands.
 	splitKey := proto.Key(args.SplitKey)
 	if len(splitKey) == 0 {
-		snap := r.rm.NewSnapshot()
-		defer snap.Close()
-		foundSplitKey, err := engine.MVCCFindSplitKey(snap, desc.RangeID, desc.StartKey, desc.EndKey)
+		foundSplitKey, err := r.computeSplitKeyBySize()
 		if err != nil {
 			return reply, util.Errorf("unable to determine split key: %s", err)
 		}
@@ -1264,6 +1476,25 @@ func (r *Replica) AdminMerge(args proto.AdminMergeRequest, desc *proto.RangeDesc
 		return reply, util.Errorf("The two ranges replicas are not collocate")
 	}
 
+	// Refuse merges resulting in a range above the zone's maximum size,
+	// which would only be split again.
+	if maxBytes := r.GetMaxBytes(); maxBytes > 0 {
+		if size := r.stats.GetSize() + subsumedRng.stats.GetSize(); size > maxBytes {
+			return reply, &MergeTooLargeError{
+				RangeID:         desc.RangeID,
+				SubsumedRangeID: subsumedDesc.RangeID,
+				Bytes:           size,
+				MaxBytes:        maxBytes,
+			}
+		}
+	}
+
+	// The subsumed range must not carry open intents into the merge, so
+	// resolve them first.
+	if err := r.resolveMergeIntents(&args, subsumedRng); err != nil {
+		return reply, err
+	}
+
 	// Init updated version of existing range descriptor.
 	updatedDesc := *desc
 	updatedDesc.EndKey = subsumedDesc.EndKey
@@ -1271,6 +1502,16 @@ func (r *Replica) AdminMerge(args proto.AdminMergeRequest, desc *proto.RangeDesc
 	log.Infof("initiating a merge of %s into %s", subsumedRng, r)
 
 	if err := r.rm.DB().Txn(func(txn *client.Txn) error {
+		// New intents may have been written to the subsumed range since
+		// they were resolved above. Reading its keyspace in the merge
+		// transaction pushes or conflicts with them, and records the read
+		// in the timestamp cache so that later writes land above the
+		// merge. Only writes arriving between this read and the commit
+		// can still carry intents into the merged range.
+		if _, err := txn.Scan(subsumedDesc.StartKey, subsumedDesc.EndKey, 0); err != nil {
+			return err
+		}
+
 		// Update the range descriptor for the receiving range.
 		b := &client.Batch{}
 		desc1Key := keys.RangeDescriptorKey(updatedDesc.StartKey)
@@ -1315,6 +1556,47 @@ func (r *Replica) AdminMerge(args proto.AdminMergeRequest, desc *proto.RangeDesc
 	return reply, nil
 }
 
+// resolveMergeIntents resolves the open intents on the range about to
+// be subsumed by this one. Intents of finished or abandoned transactions
+// are cleaned up; those of live transactions are retried with backoff
+// until the store's merge intent timeout passes, at which point a
+// MergeIntentsError is returned. This happens before the merge
+// transaction starts, so new intents may arrive in the meantime; the
+// merge transaction reads the subsumed range's keyspace to catch them.
+func (r *Replica) resolveMergeIntents(args proto.Request, subsumedRng *Replica) error {
+	ctx := r.context()
+	deadline := time.Now().Add(r.rm.mergeIntentTimeout())
+	retryOpts := mergeIntentRetryOptions
+	retryOpts.Stopper = r.rm.Stopper()
+	var intents []proto.Intent
+	for backoff := retry.Start(retryOpts); backoff.Next(); {
+		// Intents may have been written at timestamps up to the maximum
+		// clock offset ahead of our clock, so look that far ahead.
+		var err error
+		if intents, err = subsumedRng.ScanIntents(-r.rm.Clock().MaxOffset()); err != nil {
+			return err
+		}
+		if len(intents) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		// Pushes of live transactions fail; their intents show up again
+		// on the next scan.
+		if err := r.rm.resolveWriteIntentError(ctx, &proto.WriteIntentError{
+			Intents: intents,
+		}, subsumedRng, args, proto.CLEANUP_TXN); log.V(1) {
+			log.Infoc(ctx, "resolving %d intents before merge: %s", len(intents), err)
+		}
+	}
+	return &MergeIntentsError{
+		RangeID:         r.Desc().RangeID,
+		SubsumedRangeID: subsumedRng.Desc().RangeID,
+		Intents:         len(intents),
+	}
+}
+
 // mergeTrigger is called on a successful commit of an AdminMerge
 // transaction. It recomputes stats for the receiving range.
 func (r *Replica) mergeTrigger(batch engine.Engine, merge *proto.MergeTrigger) error {
@@ -171,6 +171,60 @@ func (r *Replica) FirstIndex() (uint64, error) {
 	return ts.Index + 1, nil
 }
 
+// RaftLogSize returns the approximate number of bytes occupied on disk
+// by the entries of the Raft log which have not yet been truncated. The
+// sum covers the encoded keys and values of all entries between the
+// truncated index and the last index; entries are not decoded.
+func (r *Replica) RaftLogSize() (int64, error) {
+	ts, err := r.raftTruncatedState()
+	if err != nil {
+		return 0, err
+	}
+	lastIndex := atomic.LoadUint64(&r.lastIndex)
+	if lastIndex <= ts.Index {
+		return 0, nil
+	}
+	rangeID := r.Desc().RangeID
+	start := engine.MVCCEncodeKey(keys.RaftLogKey(rangeID, ts.Index+1))
+	end := engine.MVCCEncodeKey(keys.RaftLogKey(rangeID, lastIndex+1))
+	var size int64
+	if err := r.rm.Engine().Iterate(start, end, func(kv proto.RawKeyValue) (bool, error) {
+		size += int64(len(kv.Key) + len(kv.Value))
+		return false, nil
+	}); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// maxRaftLogEntriesRead is the maximum number of entries returned by a
+// single call to RaftLogEntries.
+const maxRaftLogEntriesRead = 1000
+
+// RaftLogEntries returns the decoded entries of the Raft log with
+// indexes in [lo, hi) for debugging purposes. The interval is clamped
+// to the entries which have neither been truncated nor lie beyond the
+// last index, and at most maxRaftLogEntriesRead entries are returned.
+func (r *Replica) RaftLogEntries(lo, hi uint64) ([]raftpb.Entry, error) {
+	ts, err := r.raftTruncatedState()
+	if err != nil {
+		return nil, err
+	}
+	if lo <= ts.Index {
+		lo = ts.Index + 1
+	}
+	if end := atomic.LoadUint64(&r.lastIndex) + 1; hi > end {
+		hi = end
+	}
+	if hi > lo+maxRaftLogEntriesRead {
+		hi = lo + maxRaftLogEntriesRead
+	}
+	if lo >= hi {
+		return nil, nil
+	}
+	return r.Entries(lo, hi, 0)
+}
+
 // loadAppliedIndex retrieves the applied index from the supplied engine.
 func (r *Replica) loadAppliedIndex(eng engine.Engine) (uint64, error) {
 	var appliedIndex uint64
@@ -268,6 +322,11 @@ func (r *Replica) Snapshot() (raftpb.Snapshot, error) {
 		snapData.KV = append(snapData.KV,
 			&proto.RaftSnapshotData_KeyValue{Key: iter.Key(), Value: iter.Value()})
 	}
+	// Keys are read in order and share long prefixes, such as those of a
+	// table or of the range-local keys.
+	if r.rm.compressSnapshotKeys() {
+		snapData.CompressKeys()
+	}
 
 	data, err := gogoproto.Marshal(&snapData)
 	if err != nil {
@@ -347,11 +406,16 @@ func (r *Replica) updateRangeInfo() error {
 	// copying maxBytes from the original range does not work
 	// since the original range and the new range might belong
 	// to different zones.
-	zone, err := lookupZoneConfig(r.rm.Gossip(), r)
+	zone, err := r.effectiveZoneConfig()
 	if err != nil {
 		return util.Errorf("failed to lookup zone config for Range %s: %s", r, err)
 	}
 	r.SetMaxBytes(zone.RangeMaxBytes)
+	r.SetMaxValueBytes(zone.MaxValueBytes)
+	r.SetResponseCacheDisabled(zone.DisableResponseCache)
+	r.SetLeasePreferences(zoneLeasePreferences(zone))
+	r.SetValueChecksums(zone.ValueChecksums)
+	r.SetQuorumAttrs(zone.QuorumAttrs)
 
 	// No need to update configHashes. It will be set when a leader lease calls
 	// maybeGossipConfigs.
@@ -362,10 +426,13 @@ func (r *Replica) updateRangeInfo() error {
 // ApplySnapshot implements the multiraft.WriteableGroupStorage interface.
 func (r *Replica) ApplySnapshot(snap raftpb.Snapshot) error {
 	snapData := proto.RaftSnapshotData{}
-	err := gogoproto.Unmarshal(snap.Data, &snapData)
+	err := proto.UnmarshalWithDetails(snap.Data, &snapData)
 	if err != nil {
 		return err
 	}
+	if err := snapData.DecompressKeys(); err != nil {
+		return err
+	}
 
 	rangeID := r.Desc().RangeID
 
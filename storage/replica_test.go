@@ -22,10 +22,12 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -45,7 +47,9 @@ import (
 	"github.com/cockroachdb/cockroach/util"
 	"github.com/cockroachdb/cockroach/util/hlc"
 	"github.com/cockroachdb/cockroach/util/leaktest"
+	"github.com/cockroachdb/cockroach/util/retry"
 	"github.com/cockroachdb/cockroach/util/stop"
+	"github.com/cockroachdb/cockroach/util/tracer"
 	"github.com/cockroachdb/cockroach/util/uuid"
 	"github.com/coreos/etcd/raft"
 	gogoproto "github.com/gogo/protobuf/proto"
@@ -104,6 +108,7 @@ type testContext struct {
 	rng           *Replica
 	rangeID       proto.RangeID
 	gossip        *gossip.Gossip
+	storePool     *StorePool
 	engine        engine.Engine
 	manualClock   *hlc.ManualClock
 	clock         *hlc.Clock
@@ -142,6 +147,7 @@ func (tc *testContext) Start(t testing.TB) {
 		ctx.Gossip = tc.gossip
 		ctx.Transport = tc.transport
 		ctx.EventFeed = tc.feed
+		ctx.StorePool = tc.storePool
 		// Create a test sender without setting a store. This is to deal with the
 		// circular dependency between the test sender and the store. The actual
 		// store will be passed to the sender after it is created and bootstrapped.
@@ -276,6 +282,41 @@ func TestRangeContains(t *testing.T) {
 		keys.RangeDescriptorKey([]byte("b"))) {
 		t.Errorf("expected range to contain key transaction range \"aa\"-\"b\"")
 	}
+
+	// Raw containment doesn't translate range-local keys.
+	if !r.ContainsRawKey(proto.Key("aa")) {
+		t.Errorf("expected range to contain raw key \"aa\"")
+	}
+	if r.ContainsRawKey(keys.RangeDescriptorKey([]byte("aa"))) {
+		t.Errorf("expected range not to contain raw range descriptor key for \"aa\"")
+	}
+	if r.ContainsRawKey(proto.Key("b")) {
+		t.Errorf("expected range not to contain raw key \"b\"")
+	}
+}
+
+// TestRangeKeySpan verifies that a range's key span matches the bounds
+// of its descriptor.
+func TestRangeKeySpan(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	rng2 := createRange(tc.store, 2, proto.Key("a"), proto.Key("b"))
+	for i, test := range []struct {
+		rng      *Replica
+		expStart proto.Key
+		expEnd   proto.Key
+	}{
+		{tc.rng, proto.KeyMin, proto.KeyMax},
+		{rng2, proto.Key("a"), proto.Key("b")},
+	} {
+		span := test.rng.KeySpan()
+		if !span.Start.Equal(test.expStart) || !span.End.Equal(test.expEnd) {
+			t.Errorf("%d: expected span [%q,%q); got [%q,%q)", i, test.expStart, test.expEnd, span.Start, span.End)
+		}
+	}
 }
 
 func setLeaderLease(t *testing.T, r *Replica, l *proto.Lease) {
@@ -291,6 +332,22 @@ func setLeaderLease(t *testing.T, r *Replica, l *proto.Lease) {
 	}
 }
 
+// ExportTSCache returns the low water mark and all entries of the
+// replica's timestamp cache.
+func (r *Replica) ExportTSCache() (proto.Timestamp, []proto.TimestampCacheEntry) {
+	r.Lock()
+	defer r.Unlock()
+	return r.tsCache.Export(math.MaxInt32)
+}
+
+// ImportTSCache replaces the state of the replica's timestamp cache
+// with the supplied low water mark and entries.
+func (r *Replica) ImportTSCache(lowWater proto.Timestamp, entries []proto.TimestampCacheEntry) {
+	r.Lock()
+	defer r.Unlock()
+	r.tsCache.Import(lowWater, entries)
+}
+
 // TestRangeReadConsistency verifies behavior of the range under
 // different read consistencies. Note that this unittest plays
 // fast and loose with granting leader leases.
@@ -439,2667 +496,7058 @@ func TestRangeLeaderLease(t *testing.T) {
 	}
 }
 
-func TestRangeNotLeaderError(t *testing.T) {
+// TestRangeLeaseHolderHint verifies that the hint reflects the current
+// lease holder and whether its lease is valid.
+func TestRangeLeaseHolderHint(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	desc := *tc.rng.Desc()
+	desc.Replicas = append(append([]proto.Replica(nil), desc.Replicas...),
+		proto.Replica{NodeID: 2, StoreID: 2})
+	tc.rng.setDescWithoutProcessUpdate(&desc)
+
+	verify := func(expHolder proto.StoreID, expValid bool) {
+		holder, valid := tc.rng.LeaseHolderHint()
+		if holder.StoreID != expHolder || valid != expValid {
+			t.Errorf("expected holder on store %d (valid=%t); got %+v (valid=%t)",
+				expHolder, expValid, holder, valid)
+		}
+	}
+	verify(tc.store.StoreID(), true)
+
+	// Another replica acquires the lease once ours has expired.
+	tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1))
+	verify(tc.store.StoreID(), false)
 	now := tc.clock.Now()
 	setLeaderLease(t, tc.rng, &proto.Lease{
 		Start:      now,
 		Expiration: now.Add(10, 0),
 		RaftNodeID: proto.MakeRaftNodeID(2, 2),
 	})
+	verify(2, true)
+	tc.manualClock.Increment(11)
+	verify(2, false)
 
-	header := proto.RequestHeader{
-		Key:       proto.Key("a"),
-		RangeID:   tc.rng.Desc().RangeID,
-		Replica:   proto.Replica{StoreID: tc.store.StoreID()},
-		Timestamp: now,
-	}
-	testCases := []proto.Request{
-		// Admin split covers admin commands.
-		&proto.AdminSplitRequest{
-			RequestHeader: header,
-			SplitKey:      proto.Key("a"),
-		},
-		// Get covers read-only commands.
-		&proto.GetRequest{
-			RequestHeader: header,
-		},
-		// Put covers read-write commands.
-		&proto.PutRequest{
-			RequestHeader: header,
-			Value: proto.Value{
-				Bytes: []byte("value"),
-			},
-		},
-	}
-
-	for i, test := range testCases {
-		_, err := tc.rng.AddCmd(tc.rng.context(), test)
-
-		if _, ok := err.(*proto.NotLeaderError); !ok {
-			t.Errorf("%d: expected not leader error: %s", i, err)
-		}
+	// This replica reacquires the lease.
+	if err := tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now()); err != nil {
+		t.Fatal(err)
 	}
+	verify(tc.store.StoreID(), true)
 }
 
-// TestRangeGossipConfigsOnLease verifies that config info is gossiped
-// upon acquisition of the leader lease.
-func TestRangeGossipConfigsOnLease(t *testing.T) {
+// TestRangeLeaseHistory verifies that lease acquisitions, extensions,
+// transfers and rejections are recorded in order in the replica's
+// lease history, and that only the most recent events are retained.
+func TestRangeLeaseHistory(t *testing.T) {
 	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Add a zone config for a new key prefix.
-	db1Zone := config.ZoneConfig{
-		ReplicaAttrs: []proto.Attributes{
-			{Attrs: []string{"dc1", "ssd"}},
-			{Attrs: []string{"dc2", "ssd"}},
-		},
-	}
-	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
-	if err := engine.MVCCPutProto(tc.engine, nil, key, proto.MinTimestamp, nil, &db1Zone); err != nil {
-		t.Fatal(err)
-	}
-
-	verifyZone := func() bool {
-		configMap, err := tc.gossip.GetZoneConfig()
-		if err != nil {
-			t.Fatal(err)
-		}
-		expConfigs := []config.PrefixConfig{
-			config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
-			config.MakePrefixConfig(proto.Key("/db1"), nil, &db1Zone),
-			config.MakePrefixConfig(proto.Key("/db2"), proto.KeyMin, &testDefaultZoneConfig),
-		}
-		return reflect.DeepEqual(configMap.Configs, expConfigs)
-	}
-
-	// If this actually failed, we would have gossiped from MVCCPutProto.
-	// Unlikely, but why not check.
-	if verifyZone() {
-		t.Errorf("not expecting gossip of new config until new lease is acquired")
-	}
+	base := len(tc.rng.LeaseHistory())
+	otherNodeID := proto.MakeRaftNodeID(2, 2)
+	ownNodeID := tc.store.RaftNodeID()
 
-	// Expire our own lease which we automagically acquired due to being
-	// first range and config holder.
-	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	// Let the initial lease expire and hand the lease to another replica.
+	tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1))
 	now := tc.clock.Now()
-
-	// Give lease to someone else.
 	setLeaderLease(t, tc.rng, &proto.Lease{
 		Start:      now,
 		Expiration: now.Add(10, 0),
-		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+		RaftNodeID: otherNodeID,
 	})
-
-	// Expire that lease.
-	tc.manualClock.Increment(11 + int64(tc.clock.MaxOffset())) // advance time
-	now = tc.clock.Now()
-
-	// Give lease to this range.
+	// The other replica extends its lease.
 	setLeaderLease(t, tc.rng, &proto.Lease{
-		Start:      now.Add(11, 0),
+		Start:      now,
 		Expiration: now.Add(20, 0),
-		RaftNodeID: tc.store.RaftNodeID(),
+		RaftNodeID: otherNodeID,
 	})
-	if !verifyZone() {
-		t.Errorf("expected gossip of new config")
+	// The other replica transfers the lease to us.
+	args := tc.rng.newLeaderLeaseRequest(now, proto.Lease{
+		Start:      now.Add(20, 0),
+		Expiration: now.Add(30, 0),
+		RaftNodeID: ownNodeID,
+	})
+	args.TimestampCacheLowWater = now
+	if err := tc.rng.proposeLeaderLease(args); err != nil {
+		t.Fatal(err)
+	}
+	// Once our lease expires, our request for a new one is rejected.
+	tc.manualClock.Increment(31)
+	rejectedBy := proto.Lease{RaftNodeID: otherNodeID}
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.LeaderLeaseRequest); ok {
+			return &proto.LeaseRejectedError{Existing: rejectedBy}
+		}
+		return nil
+	}
+	if err := tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now()); err == nil {
+		t.Fatal("expected lease request to be rejected")
 	}
-}
-
-// TestRangeTSCacheLowWaterOnLease verifies that the low water mark is
-// set on the timestamp cache when the node is granted the leader
-// lease after not holding it and it is not set when the node is
-// granted the leader lease when it was the last holder.
-func TestRangeTSCacheLowWaterOnLease(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	tc := testContext{}
-	tc.Start(t)
-	defer tc.Stop()
-	tc.clock.SetMaxOffset(maxClockOffset)
-
-	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
-	now := proto.Timestamp{WallTime: tc.manualClock.UnixNano()}
-
-	baseRTS, _ := tc.rng.tsCache.GetMax(proto.Key("a"), nil /* end */, nil /* txn */)
-	baseLowWater := baseRTS.WallTime
 
-	testCases := []struct {
-		nodeID      proto.RaftNodeID
-		start       proto.Timestamp
-		expiration  proto.Timestamp
-		expLowWater int64
+	expected := []struct {
+		typ    LeaseEventType
+		holder proto.RaftNodeID
 	}{
-		// Grant the lease fresh.
-		{tc.store.RaftNodeID(), now, now.Add(10, 0), baseLowWater},
-		// Renew the lease.
-		{tc.store.RaftNodeID(), now.Add(15, 0), now.Add(30, 0), baseLowWater},
-		// Renew the lease but shorten expiration.
-		{tc.store.RaftNodeID(), now.Add(16, 0), now.Add(25, 0), baseLowWater},
-		// Lease is held by another.
-		{proto.MakeRaftNodeID(2, 2), now.Add(29, 0), now.Add(50, 0), baseLowWater},
-		// Lease is regranted to this replica.
-		{tc.store.RaftNodeID(), now.Add(60, 0), now.Add(70, 0), now.Add(50, 0).WallTime + int64(maxClockOffset) + baseLowWater},
+		{LeaseAcquired, otherNodeID},
+		{LeaseExtended, otherNodeID},
+		{LeaseTransferred, ownNodeID},
+		{LeaseRejected, otherNodeID},
+	}
+	history := tc.rng.LeaseHistory()
+	if len(history) != base+len(expected) {
+		t.Fatalf("expected %d lease events, got %+v", base+len(expected), history)
+	}
+	for i, e := range expected {
+		if ev := history[base+i]; ev.Type != e.typ || ev.Lease.RaftNodeID != e.holder {
+			t.Errorf("%d: expected %s lease event for node %d, got %s for node %d",
+				i, e.typ, e.holder, ev.Type, ev.Lease.RaftNodeID)
+		}
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Timestamp.Less(history[i-1].Timestamp) {
+			t.Errorf("%d: lease event timestamps out of order: %s < %s",
+				i, history[i].Timestamp, history[i-1].Timestamp)
+		}
 	}
 
-	for i, test := range testCases {
-		setLeaderLease(t, tc.rng, &proto.Lease{
-			Start:      test.start,
-			Expiration: test.expiration,
-			RaftNodeID: test.nodeID,
-		})
-		// Verify expected low water mark.
-		rTS, wTS := tc.rng.tsCache.GetMax(proto.Key("a"), nil, nil)
-		if rTS.WallTime != test.expLowWater || wTS.WallTime != test.expLowWater {
-			t.Errorf("%d: expected low water %d; got %d, %d", i, test.expLowWater, rTS.WallTime, wTS.WallTime)
+	// Only the most recent events are retained.
+	for i := 0; i < leaseHistorySize; i++ {
+		tc.rng.recordLeaseEvent(LeaseExtended, proto.Lease{})
+	}
+	history = tc.rng.LeaseHistory()
+	if len(history) != leaseHistorySize {
+		t.Fatalf("expected %d lease events, got %d", leaseHistorySize, len(history))
+	}
+	for i, ev := range history {
+		if ev.Type != LeaseExtended {
+			t.Errorf("%d: expected only extensions to remain, got %s", i, ev.Type)
 		}
 	}
 }
 
-// TestRangeGossipFirstRange verifies that the first range gossips its
-// location and the cluster ID.
-func TestRangeGossipFirstRange(t *testing.T) {
+// TestRangeWriteTooOldRetry verifies that a non-transactional write
+// which fails with a WriteTooOldError on apply is retried at a higher
+// timestamp a bounded number of times, and that transactional writes
+// are not retried.
+func TestRangeWriteTooOldRetry(t *testing.T) {
 	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	for _, key := range []string{gossip.KeyClusterID, gossip.KeyFirstRangeDescriptor, gossip.KeySentinel} {
-		bytes, err := tc.gossip.GetInfo(key)
-		if err != nil {
-			t.Errorf("missing first range gossip of key %s", key)
+
+	key := proto.Key("a")
+	var mu sync.Mutex
+	var attempts []proto.Timestamp
+	var cmdIDs []proto.ClientCmdID
+	var failures int
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.PutRequest); !ok || !args.Header().Key.Equal(key) {
+			return nil
 		}
-		if key == gossip.KeyFirstRangeDescriptor {
-			var rangeDesc proto.RangeDescriptor
-			if err := gogoproto.Unmarshal(bytes, &rangeDesc); err != nil {
-				t.Fatal(err)
-			}
+		mu.Lock()
+		defer mu.Unlock()
+		ts := args.Header().Timestamp
+		attempts = append(attempts, ts)
+		cmdIDs = append(cmdIDs, args.Header().CmdID)
+		if len(attempts) > failures {
+			return nil
 		}
-		if key == gossip.KeyClusterID && len(bytes) == 0 {
-			t.Errorf("expected non-empty gossiped cluster ID, got %q", bytes)
+		// Pretend that a competing write landed just above us.
+		return &proto.WriteTooOldError{Timestamp: ts, ExistingTimestamp: ts.Add(10, 0)}
+	}
+	reset := func(n int) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = nil
+		cmdIDs = nil
+		failures = n
+	}
+
+	// Transient conflicts are retried transparently. The command carries
+	// a CmdID, so each retry must be executed under an ID of its own
+	// instead of hitting the error cached for the previous attempt.
+	reset(2)
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	pArgs.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attempts))
+	}
+	for i := 1; i < len(cmdIDs); i++ {
+		if cmdIDs[i] == cmdIDs[i-1] {
+			t.Errorf("%d: expected a fresh CmdID for the retry, got %+v", i, cmdIDs[i])
 		}
-		if key == gossip.KeySentinel && len(bytes) == 0 {
-			t.Errorf("expected non-empty gossiped sentinel, got %q", bytes)
+	}
+	for i := 1; i < len(attempts); i++ {
+		if !attempts[i-1].Add(10, 0).Less(attempts[i]) {
+			t.Errorf("%d: expected retry above the conflicting write at %s, got %s",
+				i, attempts[i-1].Add(10, 0), attempts[i])
 		}
 	}
-}
+	if ts := reply.Header().Timestamp; !ts.Equal(attempts[2]) {
+		t.Errorf("expected reply timestamp %s, got %s", attempts[2], ts)
+	}
+	mu.Unlock()
 
-// TestRangeGossipAllConfigs verifies that all config types are gossiped.
-func TestRangeGossipAllConfigs(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	tc := testContext{}
-	tc.Start(t)
-	defer tc.Stop()
-	testData := []struct {
-		gossipKey string
-		configs   []config.PrefixConfig
-	}{
-		{gossip.KeyConfigZone, []config.PrefixConfig{config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig)}},
+	// Persistent conflicts surface once the retries are exhausted.
+	reset(maxWriteTooOldRetries + 1)
+	pArgs = putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Fatal("expected WriteTooOldError")
+	} else if _, ok := err.(*proto.WriteTooOldError); !ok {
+		t.Fatalf("expected WriteTooOldError, got %s", err)
 	}
-	for _, test := range testData {
-		_, err := tc.gossip.GetInfo(test.gossipKey)
-		if err != nil {
-			t.Fatal(err)
-		}
+	mu.Lock()
+	if len(attempts) != maxWriteTooOldRetries+1 {
+		t.Errorf("expected %d attempts, got %d", maxWriteTooOldRetries+1, len(attempts))
 	}
+	mu.Unlock()
+
+	// Transactional writes are never retried.
+	reset(1)
+	pArgs = putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	pArgs.Timestamp = pArgs.Txn.Timestamp
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Fatal("expected WriteTooOldError")
+	}
+	mu.Lock()
+	if len(attempts) != 1 {
+		t.Errorf("expected a single attempt for a transactional write, got %d", len(attempts))
+	}
+	mu.Unlock()
 }
 
-// TestRangeGossipConfigWithMultipleKeyPrefixes verifies that multiple
-// key prefixes for a config are gossiped.
-func TestRangeGossipConfigWithMultipleKeyPrefixes(t *testing.T) {
+// TestRangeLeaderLeaseBackoff verifies that repeated failures to obtain the
+// leader lease are spaced out by a growing backoff, and that the backoff is
+// reset once the lease is acquired.
+func TestRangeLeaderLeaseBackoff(t *testing.T) {
 	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	// Add a zone for a new key prefix.
-	db1Zone := &config.ZoneConfig{
-		ReplicaAttrs: []proto.Attributes{
-			{Attrs: []string{"dc1", "ssd"}},
-			{Attrs: []string{"dc2", "ssd"}},
-		},
-	}
-	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
-	data, err := gogoproto.Marshal(db1Zone)
-	if err != nil {
-		t.Fatal(err)
+
+	tc.rng.llMu.Lock()
+	tc.rng.leaseRetry = retry.Start(retry.Options{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	})
+	tc.rng.llMu.Unlock()
+
+	var mu sync.Mutex
+	var attempts []time.Time
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.LeaderLeaseRequest); ok {
+			mu.Lock()
+			attempts = append(attempts, time.Now())
+			mu.Unlock()
+			return &proto.LeaseRejectedError{}
+		}
+		return nil
 	}
-	req := proto.PutRequest{
-		RequestHeader: proto.RequestHeader{Key: key, Timestamp: proto.MinTimestamp},
-		Value:         proto.Value{Bytes: data},
+
+	// Let the initial lease expire.
+	tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1000))
+
+	const numAttempts = 5
+	for i := 0; i < numAttempts; i++ {
+		err := tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now())
+		if _, ok := err.(*proto.LeaseRejectedError); !ok {
+			t.Fatalf("%d: expected LeaseRejectedError, got %v", i, err)
+		}
 	}
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &req); err != nil {
-		t.Fatal(err)
+	mu.Lock()
+	if len(attempts) != numAttempts {
+		t.Fatalf("expected %d lease requests, got %d", numAttempts, len(attempts))
+	}
+	for i := 2; i < len(attempts); i++ {
+		prev, cur := attempts[i-1].Sub(attempts[i-2]), attempts[i].Sub(attempts[i-1])
+		if cur <= prev {
+			t.Errorf("%d: expected backoff to grow, but %s <= %s", i, cur, prev)
+		}
 	}
+	mu.Unlock()
 
-	configMap, err := tc.gossip.GetZoneConfig()
-	if err != nil {
+	// Acquiring the lease resets the backoff.
+	TestingCommandFilter = nil
+	if err := tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now()); err != nil {
 		t.Fatal(err)
 	}
-	expConfigs := []config.PrefixConfig{
-		config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
-		config.MakePrefixConfig(proto.Key("/db1"), nil, db1Zone),
-		config.MakePrefixConfig(proto.Key("/db2"), proto.KeyMin, &testDefaultZoneConfig),
-	}
-	if !reflect.DeepEqual(configMap.Configs, expConfigs) {
-		t.Errorf("expected gossiped configs to be equal %s vs %s", configMap, expConfigs)
+	tc.rng.llMu.Lock()
+	defer tc.rng.llMu.Unlock()
+	if a := tc.rng.leaseRetry.CurrentAttempt(); a != 0 {
+		t.Errorf("expected backoff to be reset after lease acquisition, at attempt %d", a)
 	}
 }
 
-// TestRangeGossipConfigUpdates verifies that writes to the
-// zones cause the updated configs to be re-gossiped.
-func TestRangeGossipConfigUpdates(t *testing.T) {
+// TestRangeLeaderLeaseBackoffUnlocked verifies that the leader lease
+// mutex isn't held while a lease request is backing off, and that the
+// lease is checked again once the backoff has passed.
+func TestRangeLeaderLeaseBackoffUnlocked(t *testing.T) {
 	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	// Add a zone for a new key prefix.
-	db1Zone := &config.ZoneConfig{
-		ReplicaAttrs: []proto.Attributes{
-			{Attrs: []string{"dc1", "ssd"}},
-			{Attrs: []string{"dc2", "ssd"}},
-		},
-	}
 
-	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
-	data, err := gogoproto.Marshal(db1Zone)
-	if err != nil {
-		t.Fatal(err)
-	}
-	req := proto.PutRequest{
-		RequestHeader: proto.RequestHeader{Key: key, Timestamp: proto.MinTimestamp},
-		Value:         proto.Value{Bytes: data},
+	tc.rng.llMu.Lock()
+	tc.rng.leaseRetry = retry.Start(retry.Options{
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	})
+	tc.rng.llMu.Unlock()
+
+	var requests int32
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.LeaderLeaseRequest); ok {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				return &proto.LeaseRejectedError{}
+			}
+		}
+		return nil
 	}
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &req); err != nil {
-		t.Fatal(err)
+	// Let the initial lease expire, and fail the first request.
+	tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1000))
+	if err := tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now()); err == nil {
+		t.Fatal("expected the first lease request to fail")
 	}
 
-	configMap, err := tc.gossip.GetZoneConfig()
-	if err != nil {
+	// The next request backs off without holding the mutex.
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	locked := make(chan struct{})
+	go func() {
+		tc.rng.llMu.Lock()
+		tc.rng.llMu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(100 * time.Millisecond):
+		t.Error("leader lease mutex held during backoff")
+	}
+	if err := <-errChan; err != nil {
 		t.Fatal(err)
 	}
-	expConfigs := []config.PrefixConfig{
-		config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
-		config.MakePrefixConfig(proto.Key("/db1"), nil, db1Zone),
-		config.MakePrefixConfig(proto.Key("/db2"), proto.KeyMin, &testDefaultZoneConfig),
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected 2 lease requests; got %d", n)
 	}
-	if !reflect.DeepEqual(configMap.Configs, expConfigs) {
-		t.Errorf("expected gossiped configs to be equal %s vs %s", configMap, expConfigs)
+}
+
+// TestWeightedLeaseDelay verifies that replicas on stores holding more
+// leases wait longer on average before requesting a leader lease.
+func TestWeightedLeaseDelay(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	const meanLeaseCount = 10
+	if d := weightedLeaseDelay(0, meanLeaseCount, 0.99); d != 0 {
+		t.Errorf("expected no delay for a store without leases; got %s", d)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	const samples = 1000
+	var light, heavy time.Duration
+	for i := 0; i < samples; i++ {
+		random := rnd.Float64()
+		for _, d := range []time.Duration{
+			weightedLeaseDelay(2, meanLeaseCount, random),
+			weightedLeaseDelay(50, meanLeaseCount, random),
+		} {
+			if d < 0 || d > maxLeaseAcquisitionDelay {
+				t.Fatalf("delay %s out of bounds [0, %s]", d, maxLeaseAcquisitionDelay)
+			}
+		}
+		light += weightedLeaseDelay(2, meanLeaseCount, rnd.Float64())
+		heavy += weightedLeaseDelay(50, meanLeaseCount, rnd.Float64())
+	}
+	if light >= heavy {
+		t.Errorf("expected heavily loaded store to wait longer on average; %s >= %s",
+			light/samples, heavy/samples)
 	}
 }
 
-// TestRangeNoGossipConfig verifies that certain commands (e.g.,
-// reads, writes in uncommitted transactions) do not trigger gossip.
-func TestRangeNoGossipConfig(t *testing.T) {
+// TestRangeLeasePreference verifies that a replica outside the locality
+// preferred for the leader lease defers to a replica in that locality,
+// which wins the lease, and acquires the lease itself right away if no
+// replica in the preferred locality is available.
+func TestRangeLeasePreference(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	tc := testContext{}
+	tc := testContext{stopper: stop.NewStopper()}
+	rpcContext := rpc.NewContext(&base.Context{}, hlc.NewClock(hlc.UnixNano), tc.stopper)
+	tc.gossip = gossip.New(rpcContext, gossip.TestInterval, gossip.TestBootstrap)
+	tc.storePool = NewStorePool(tc.gossip, TestTimeUntilStoreDeadOff, tc.stopper)
 	tc.Start(t)
 	defer tc.Stop()
-
-	// Add a zone for a new key prefix.
-	db1Zone := &config.ZoneConfig{
-		ReplicaAttrs: []proto.Attributes{
-			{Attrs: []string{"dc1", "ssd"}},
-			{Attrs: []string{"dc2", "ssd"}},
+	tc.store.replicateQueue.SetDisabled(true)
+
+	// Add a second replica in another locality.
+	desc := *tc.rng.Desc()
+	desc.Replicas = append(append([]proto.Replica(nil), desc.Replicas...),
+		proto.Replica{NodeID: 2, StoreID: 2})
+	tc.rng.setDescWithoutProcessUpdate(&desc)
+	newStoreGossiper(tc.gossip).gossipStores([]*proto.StoreDescriptor{
+		{
+			StoreID: 1,
+			Node:    proto.NodeDescriptor{NodeID: 1, Attrs: proto.Attributes{Attrs: []string{"us-west"}}},
 		},
-	}
-	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
-	rangeID := proto.RangeID(1)
+		{
+			StoreID: 2,
+			Node:    proto.NodeDescriptor{NodeID: 2, Attrs: proto.Attributes{Attrs: []string{"us-east"}}},
+		},
+	}, t)
 
-	txn := newTransaction("test", key, 1 /* userPriority */, proto.SERIALIZABLE, tc.clock)
-	data, err := gogoproto.Marshal(db1Zone)
-	if err != nil {
-		t.Fatal(err)
+	// Without a preference, or if no replica matches it, there's no need
+	// to defer.
+	if tc.rng.deferToPreferredReplica() {
+		t.Error("expected no deferral without a lease preference")
+	}
+	tc.rng.SetLeasePreference(proto.Attributes{Attrs: []string{"eu"}})
+	if tc.rng.deferToPreferredReplica() {
+		t.Error("expected no deferral without an available preferred replica")
+	}
+	tc.rng.SetLeasePreference(proto.Attributes{Attrs: []string{"us-west"}})
+	if tc.rng.deferToPreferredReplica() {
+		t.Error("expected no deferral for a replica in the preferred locality")
 	}
-	req1 := putArgs(key, data, rangeID, tc.store.StoreID())
-	req1.Txn = txn
-	req1.Timestamp = txn.Timestamp
 
-	req2 := endTxnArgs(txn, true /* commit */, rangeID, tc.store.StoreID())
-	req2.Timestamp = txn.Timestamp
-	req2.Intents = []proto.Intent{{Key: key}}
+	// Let the current lease expire and prefer the other replica. While
+	// this replica holds back, the preferred replica acquires the lease,
+	// to which this replica then redirects.
+	tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1))
+	tc.rng.SetLeasePreference(proto.Attributes{Attrs: []string{"us-east"}})
+	if !tc.rng.deferToPreferredReplica() {
+		t.Fatal("expected deferral to the replica in the preferred locality")
+	}
+	now := tc.clock.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tc.rng.redirectOnOrAcquireLeaderLease(nil, now)
+	}()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(int64(DefaultLeaderLeaseDuration), 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
+	err := <-errChan
+	if lErr, ok := err.(*proto.NotLeaderError); !ok {
+		t.Fatalf("expected redirect to the preferred replica; got %v", err)
+	} else if lErr.Leader == nil || lErr.Leader.StoreID != 2 {
+		t.Fatalf("expected redirect to store 2; got %+v", lErr.Leader)
+	}
+}
 
-	req3 := getArgs(key, rangeID, tc.store.StoreID())
-	req3.Timestamp = txn.Timestamp
+// TestRangeLeasePreferences verifies that with an ordered list of lease
+// preferences, a replica defers to the replicas on nodes matching more
+// preferred attributes, the most preferred of which wins the lease.
+func TestRangeLeasePreferences(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{stopper: stop.NewStopper()}
+	rpcContext := rpc.NewContext(&base.Context{}, hlc.NewClock(hlc.UnixNano), tc.stopper)
+	tc.gossip = gossip.New(rpcContext, gossip.TestInterval, gossip.TestBootstrap)
+	tc.storePool = NewStorePool(tc.gossip, TestTimeUntilStoreDeadOff, tc.stopper)
+	tc.Start(t)
+	defer tc.Stop()
+	tc.store.replicateQueue.SetDisabled(true)
+
+	desc := *tc.rng.Desc()
+	desc.Replicas = append(append([]proto.Replica(nil), desc.Replicas...),
+		proto.Replica{NodeID: 2, StoreID: 2},
+		proto.Replica{NodeID: 3, StoreID: 3})
+	tc.rng.setDescWithoutProcessUpdate(&desc)
+	newStoreGossiper(tc.gossip).gossipStores([]*proto.StoreDescriptor{
+		{
+			StoreID: 1,
+			Node:    proto.NodeDescriptor{NodeID: 1, Attrs: proto.Attributes{Attrs: []string{"hdd"}}},
+		},
+		{
+			StoreID: 2,
+			Node:    proto.NodeDescriptor{NodeID: 2, Attrs: proto.Attributes{Attrs: []string{"ssd"}}},
+		},
+		{
+			StoreID: 3,
+			Node:    proto.NodeDescriptor{NodeID: 3, Attrs: proto.Attributes{Attrs: []string{"fast", "ssd"}}},
+		},
+	}, t)
 
-	for i, req := range []proto.Request{&req1, &req2, &req3} {
-		if _, err := tc.store.ExecuteCmd(tc.rng.context(), req); err != nil {
-			t.Fatal(err)
+	zone := &config.ZoneConfig{
+		LeasePreferences: []proto.Attributes{
+			{Attrs: []string{"fast", "ssd"}},
+			{Attrs: []string{"ssd"}},
+			{Attrs: []string{"hdd"}},
+		},
+	}
+	testCases := []struct {
+		zone   *config.ZoneConfig
+		expLag int
+	}{
+		// This replica ranks last, two places below the replica on store 3.
+		{zone, 2},
+		// The single preference takes precedence over the list.
+		{&config.ZoneConfig{LeasePreference: proto.Attributes{Attrs: []string{"hdd"}},
+			LeasePreferences: zone.LeasePreferences}, 0},
+		// No other replica matches any preference better than this one.
+		{&config.ZoneConfig{LeasePreferences: []proto.Attributes{{Attrs: []string{"hdd"}}}}, 0},
+		{&config.ZoneConfig{}, 0},
+	}
+	for i, test := range testCases {
+		tc.rng.SetLeasePreferences(zoneLeasePreferences(test.zone))
+		if lag := tc.rng.leasePreferenceLag(); lag != test.expLag {
+			t.Errorf("%d: expected lease preference lag %d; got %d", i, test.expLag, lag)
 		}
+	}
 
-		// Information for db1 is not gossiped.
-		configMap, err := tc.gossip.GetZoneConfig()
-		if err != nil {
-			t.Fatal(err)
-		}
-		expConfigs := []config.PrefixConfig{
-			config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
-		}
-		if !reflect.DeepEqual(configMap.Configs, expConfigs) {
-			t.Errorf("%d: expected gossiped configs to be equal %s vs %s",
-				i, configMap, expConfigs)
-		}
+	// Let the current lease expire. While this replica holds back, the
+	// replica on the most preferred node acquires the lease, to which
+	// this replica then redirects.
+	tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1))
+	tc.rng.SetLeasePreferences(zoneLeasePreferences(zone))
+	now := tc.clock.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tc.rng.redirectOnOrAcquireLeaderLease(nil, now)
+	}()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(int64(DefaultLeaderLeaseDuration), 0),
+		RaftNodeID: proto.MakeRaftNodeID(3, 3),
+	})
+	err := <-errChan
+	if lErr, ok := err.(*proto.NotLeaderError); !ok {
+		t.Fatalf("expected redirect to the most preferred replica; got %v", err)
+	} else if lErr.Leader == nil || lErr.Leader.StoreID != 3 {
+		t.Fatalf("expected redirect to store 3; got %+v", lErr.Leader)
 	}
 }
 
-// TestRangeNoGossipFromNonLeader verifies that a non-leader replica
-// does not gossip configurations.
-func TestRangeNoGossipFromNonLeader(t *testing.T) {
+// TestRangeRemovalCandidate verifies that the replica chosen for removal
+// from an over-replicated range is one on a dead store if there is any,
+// and otherwise one whose locality is shared by other replicas, on the
+// most loaded such store.
+func TestRangeRemovalCandidate(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	tc := testContext{}
+	tc := testContext{stopper: stop.NewStopper()}
+	rpcContext := rpc.NewContext(&base.Context{}, hlc.NewClock(hlc.UnixNano), tc.stopper)
+	tc.gossip = gossip.New(rpcContext, gossip.TestInterval, gossip.TestBootstrap)
+	tc.storePool = NewStorePool(tc.gossip, TestTimeUntilStoreDeadOff, tc.stopper)
 	tc.Start(t)
 	defer tc.Stop()
-
-	// Add a zone for a new key prefix. Set the config in a transaction
-	// to avoid gossip.
-	db1Zone := &config.ZoneConfig{
-		ReplicaAttrs: []proto.Attributes{
-			{Attrs: []string{"dc1", "ssd"}},
-			{Attrs: []string{"dc2", "ssd"}},
+	tc.store.replicateQueue.SetDisabled(true)
+
+	if _, err := tc.rng.removalCandidate(); err == nil {
+		t.Error("expected error selecting the only replica for removal")
+	}
+
+	// Two replicas share a locality. The one on the more loaded store is
+	// removed, even though the replica in another locality is on a store
+	// which is more loaded still.
+	desc := *tc.rng.Desc()
+	desc.Replicas = append(append([]proto.Replica(nil), desc.Replicas...),
+		proto.Replica{NodeID: 2, StoreID: 2},
+		proto.Replica{NodeID: 3, StoreID: 3},
+		proto.Replica{NodeID: 4, StoreID: 4})
+	tc.rng.setDescWithoutProcessUpdate(&desc)
+	newStoreGossiper(tc.gossip).gossipStores([]*proto.StoreDescriptor{
+		{
+			StoreID:  1,
+			Node:     proto.NodeDescriptor{NodeID: 1, Attrs: proto.Attributes{Attrs: []string{"us-east"}}},
+			Capacity: proto.StoreCapacity{Capacity: 100, Available: 80},
 		},
-	}
-	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
-	rangeID := proto.RangeID(1)
-
-	txn := newTransaction("test", key, 1 /* userPriority */, proto.SERIALIZABLE, tc.clock)
-	data, err := gogoproto.Marshal(db1Zone)
+		{
+			StoreID:  2,
+			Node:     proto.NodeDescriptor{NodeID: 2, Attrs: proto.Attributes{Attrs: []string{"us-east"}}},
+			Capacity: proto.StoreCapacity{Capacity: 100, Available: 50},
+		},
+		{
+			StoreID:  3,
+			Node:     proto.NodeDescriptor{NodeID: 3, Attrs: proto.Attributes{Attrs: []string{"us-west"}}},
+			Capacity: proto.StoreCapacity{Capacity: 100, Available: 10},
+		},
+		{
+			StoreID:  4,
+			Node:     proto.NodeDescriptor{NodeID: 4, Attrs: proto.Attributes{Attrs: []string{"eu"}}},
+			Capacity: proto.StoreCapacity{Capacity: 100, Available: 70},
+		},
+	}, t)
+	rep, err := tc.rng.removalCandidate()
 	if err != nil {
 		t.Fatal(err)
 	}
-	req1 := putArgs(key, data, rangeID, tc.store.StoreID())
-	req1.Txn = txn
-	req1.Timestamp = txn.Timestamp
-	if _, err := tc.store.ExecuteCmd(tc.rng.context(), &req1); err != nil {
-		t.Fatal(err)
+	if rep.StoreID != 2 {
+		t.Errorf("expected replica on store 2 to be removed; got %+v", rep)
 	}
-	req2 := endTxnArgs(txn, true /* commit */, rangeID, tc.store.StoreID())
-	req2.Timestamp = txn.Timestamp
-	req2.Intents = []proto.Intent{{Key: key}}
-	if _, err := tc.store.ExecuteCmd(tc.rng.context(), &req2); err != nil {
+
+	// A replica on a store which isn't alive is removed first.
+	desc.Replicas = append(desc.Replicas, proto.Replica{NodeID: 5, StoreID: 5})
+	tc.rng.setDescWithoutProcessUpdate(&desc)
+	if rep, err = tc.rng.removalCandidate(); err != nil {
 		t.Fatal(err)
 	}
-	// Execute a get to resolve the intent.
-	req3 := getArgs(key, rangeID, tc.store.StoreID())
-	req3.Timestamp = txn.Timestamp
-	if _, err := tc.store.ExecuteCmd(tc.rng.context(), &req3); err != nil {
-		t.Fatal(err)
+	if rep.StoreID != 5 {
+		t.Errorf("expected replica on dead store 5 to be removed; got %+v", rep)
 	}
+}
 
-	// Increment the clock's timestamp to expire the leader lease.
-	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration) + 1)
-	if lease := tc.rng.getLease(); lease.Covers(tc.clock.Now()) {
-		t.Fatal("leader lease should have been expired")
+// TestRangeClosedTimestamp verifies that once the lease holder closes a
+// timestamp, writes at or below it are pushed or rejected, and that the
+// closed timestamp survives a change of lease holder, allowing replicas
+// other than the holder to serve consistent reads at or below it.
+func TestRangeClosedTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
 	}
 
-	// Make sure the information for db1 is not gossiped.
-	tc.rng.maybeGossipConfigs(func(configPrefix proto.Key) bool {
-		return tc.rng.ContainsKey(configPrefix)
-	})
-	configMap, err := tc.gossip.GetZoneConfig()
-	if err != nil {
+	tc.manualClock.Increment(100)
+	closed := tc.clock.Now()
+	tc.manualClock.Increment(100)
+	if err := tc.rng.CloseTimestamp(closed); err != nil {
 		t.Fatal(err)
 	}
-	expConfigs := []config.PrefixConfig{
-		config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
+	if lease := tc.rng.getLease(); !lease.ClosedTimestamp.Equal(closed) {
+		t.Fatalf("expected closed timestamp %s; got %s", closed, lease.ClosedTimestamp)
 	}
-	if !reflect.DeepEqual(configMap.Configs, expConfigs) {
-		t.Errorf("expected gossiped configs to be equal %s vs %s",
-			configMap, expConfigs)
+	// Timestamps in the future can't be closed.
+	if err := tc.rng.CloseTimestamp(tc.clock.Now().Add(1, 0)); err == nil {
+		t.Fatal("expected error closing a future timestamp")
 	}
-}
 
-// getArgs returns a GetRequest and GetResponse pair addressed to
-// the default replica for the specified key.
-func getArgs(key []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.GetRequest {
-	return proto.GetRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     key,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-		},
+	// A write at the closed timestamp is pushed by the timestamp cache.
+	pArgs.Timestamp = closed
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := reply.Header().Timestamp; !closed.Less(ts) {
+		t.Errorf("expected write to be pushed above %s; got %s", closed, ts)
 	}
-}
 
-// putArgs returns a PutRequest and PutResponse pair addressed to
-// the default replica for the specified key / value.
-func putArgs(key, value []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.PutRequest {
-	return proto.PutRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:       key,
-			Timestamp: proto.MinTimestamp,
-			RangeID:   rangeID,
-			Replica:   proto.Replica{StoreID: storeID},
-		},
-		Value: proto.Value{
-			Bytes: value,
-		},
+	// A write which bypasses the timestamp cache is rejected on apply.
+	pArgs.Timestamp = closed
+	errChan, pendingCmd := tc.rng.proposeRaftCommand(tc.rng.context(), &pArgs)
+	if err = <-errChan; err == nil {
+		err = (<-pendingCmd.done).Err
+	}
+	if _, ok := err.(*proto.WriteTooOldError); !ok {
+		t.Errorf("expected write too old error; got %v", err)
 	}
-}
 
-// deleteArgs returns a DeleteRequest and DeleteResponse pair.
-func deleteArgs(key proto.Key, rangeID proto.RangeID, storeID proto.StoreID) proto.DeleteRequest {
-	return proto.DeleteRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     key,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-		},
+	// Hand the lease to another node. The closed timestamp is retained.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
+	if lease := tc.rng.getLease(); !lease.ClosedTimestamp.Equal(closed) {
+		t.Fatalf("expected closed timestamp %s; got %s", closed, lease.ClosedTimestamp)
 	}
-}
 
-// readOrWriteArgs returns either get or put arguments depending on
-// value of "read". Get for true; Put for false. Returns method
-// selected and args & reply.
-func readOrWriteArgs(key proto.Key, read bool, rangeID proto.RangeID, storeID proto.StoreID) proto.Request {
-	if read {
-		gArgs := getArgs(key, rangeID, storeID)
-		return &gArgs
+	// Consistent reads at or below the closed timestamp are served locally;
+	// reads above it are redirected to the lease holder.
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = closed
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Errorf("expected follower read to succeed; got %s", err)
+	}
+	gArgs.Timestamp = closed.Next()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err == nil {
+		t.Error("expected read above closed timestamp to fail")
+	} else if _, ok := err.(*proto.NotLeaderError); !ok {
+		t.Errorf("expected not leader error; got %s", err)
 	}
-	pArgs := putArgs(key, []byte("value"), rangeID, storeID)
-	return &pArgs
 }
 
-// incrementArgs returns an IncrementRequest and IncrementResponse pair
-// addressed to the default replica for the specified key / value.
-func incrementArgs(key []byte, inc int64, rangeID proto.RangeID, storeID proto.StoreID) proto.IncrementRequest {
-	return proto.IncrementRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     key,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-		},
-		Increment: inc,
-	}
-}
+// TestRangeBoundedStalenessRead verifies that a replica not holding the
+// leader lease serves reads at the closed timestamp if the read tolerates
+// that much staleness, and redirects them to the lease holder otherwise.
+func TestRangeBoundedStalenessRead(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-func scanArgs(start, end []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.ScanRequest {
-	return proto.ScanRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     start,
-			EndKey:  end,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-		},
+	key := proto.Key("a")
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
 	}
-}
-
-// endTxnArgs returns request/response pair for EndTransaction RPC
-// addressed to the default replica for the specified key.
-func endTxnArgs(txn *proto.Transaction, commit bool, rangeID proto.RangeID, storeID proto.StoreID) proto.EndTransactionRequest {
-	return proto.EndTransactionRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     txn.Key,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-			Txn:     txn,
-		},
-		Commit: commit,
+	tc.manualClock.Increment(100)
+	closed := tc.clock.Now()
+	tc.manualClock.Increment(100)
+	if err := tc.rng.CloseTimestamp(closed); err != nil {
+		t.Fatal(err)
 	}
-}
 
-// pushTxnArgs returns a request/response pair for PushTxn RPC
-// addressed to the default replica for the specified key.
-func pushTxnArgs(pusher, pushee *proto.Transaction, pushType proto.PushTxnType, rangeID proto.RangeID, storeID proto.StoreID) proto.PushTxnRequest {
-	return proto.PushTxnRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:       pushee.Key,
-			Timestamp: pusher.Timestamp,
-			RangeID:   rangeID,
-			Replica:   proto.Replica{StoreID: storeID},
-			Txn:       pusher,
-		},
-		Now:       pusher.Timestamp,
-		PusheeTxn: *pushee,
-		PushType:  pushType,
+	// Hand the lease to another node.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
+
+	// A read tolerating the staleness of the closed timestamp is served
+	// at that timestamp.
+	expStaleness := time.Duration(now.WallTime - closed.WallTime)
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = now
+	gArgs.MaxStaleness = expStaleness
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+	if err != nil {
+		t.Fatalf("expected bounded staleness read to succeed; got %s", err)
+	}
+	gReply := reply.(*proto.GetResponse)
+	if gReply.Value == nil || !bytes.Equal(gReply.Value.Bytes, []byte("value")) {
+		t.Errorf("expected value %q; got %+v", "value", gReply.Value)
+	}
+	if staleness := gReply.Staleness; staleness != expStaleness {
+		t.Errorf("expected staleness %s; got %s", expStaleness, staleness)
 	}
-}
 
-// heartbeatArgs returns request/response pair for HeartbeatTxn RPC.
-func heartbeatArgs(txn *proto.Transaction, rangeID proto.RangeID, storeID proto.StoreID) proto.HeartbeatTxnRequest {
-	return proto.HeartbeatTxnRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     txn.Key,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-			Txn:     txn,
-		},
+	// A read which doesn't tolerate that much staleness is redirected.
+	gArgs = getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = now
+	gArgs.MaxStaleness = expStaleness - 1
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err == nil {
+		t.Error("expected read with tight staleness bound to fail")
+	} else if _, ok := err.(*proto.NotLeaderError); !ok {
+		t.Errorf("expected not leader error; got %s", err)
 	}
 }
 
-// internalMergeArgs returns a MergeRequest and MergeResponse
-// pair addressed to the default replica for the specified key. The request will
-// contain the given proto.Value.
-func internalMergeArgs(key []byte, value proto.Value, rangeID proto.RangeID, storeID proto.StoreID) proto.MergeRequest {
-	return proto.MergeRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:     key,
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-		},
-		Value: value,
+// TestRangeHistoricalTransaction verifies that reads of a historical
+// transaction at a closed timestamp are served by a replica not holding
+// the leader lease, disregarding the transaction's uncertainty interval,
+// and that such a transaction may not write.
+func TestRangeHistoricalTransaction(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	tc.manualClock.Increment(100)
+	closed := tc.clock.Now()
+	tc.manualClock.Increment(100)
+	if err := tc.rng.CloseTimestamp(closed); err != nil {
+		t.Fatal(err)
 	}
-}
 
-func truncateLogArgs(index uint64, rangeID proto.RangeID, storeID proto.StoreID) proto.TruncateLogRequest {
-	return proto.TruncateLogRequest{
-		RequestHeader: proto.RequestHeader{
-			RangeID: rangeID,
-			Replica: proto.Replica{StoreID: storeID},
-		},
-		Index: index,
+	// Hand the lease to another node.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
+	lease := &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
 	}
-}
+	setLeaderLease(t, tc.rng, lease)
 
-// getSerializedMVCCValue produces a byte slice of the serialized
-// mvcc value. If value is nil, MVCCValue.Deleted is set to true;
-// otherwise MVCCValue.Value is set to value.
-func getSerializedMVCCValue(value *proto.Value) []byte {
-	mvccVal := &engine.MVCCValue{}
-	if value != nil {
-		mvccVal.Value = value
-	} else {
-		mvccVal.Deleted = true
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	txn.Timestamp = closed
+	txn.OrigTimestamp = closed
+	txn.MaxTimestamp = now
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = closed
+	gArgs.Txn = txn
+
+	// The uncertainty interval of a regular transaction extends beyond the
+	// closed timestamp, so its read is redirected to the lease holder.
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err == nil {
+		t.Error("expected transactional read to fail")
+	} else if _, ok := err.(*proto.NotLeaderError); !ok {
+		t.Errorf("expected not leader error; got %s", err)
 	}
-	data, err := gogoproto.Marshal(&engine.MVCCValue{Value: value})
+
+	txn.Historical = true
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
 	if err != nil {
-		panic("unexpected marshal error")
+		t.Fatalf("expected historical transaction read to succeed; got %s", err)
+	}
+	if v := reply.(*proto.GetResponse).Value; v == nil || !bytes.Equal(v.Bytes, []byte("value")) {
+		t.Errorf("expected value %q; got %+v", "value", v)
+	}
+	if l := tc.rng.getLease(); l.RaftNodeID != lease.RaftNodeID {
+		t.Errorf("expected lease to remain with %d; got %d", lease.RaftNodeID, l.RaftNodeID)
+	}
+
+	pArgs.Timestamp = closed
+	pArgs.Txn = txn
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Error("expected write in historical transaction to fail")
 	}
-	return data
 }
 
-// TestAcquireLeaderLease verifies that the leader lease is acquired
-// for read and write methods.
-func TestAcquireLeaderLease(t *testing.T) {
+// TestRangeHistoricalRead verifies that reads at an explicit timestamp in
+// the past observe the values as of that timestamp, unless the timestamp
+// is below the range's GC threshold.
+func TestRangeHistoricalRead(t *testing.T) {
 	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-	gArgs := getArgs([]byte("a"), 1, 0)
-	pArgs := putArgs([]byte("b"), []byte("1"), 1, 0)
-
-	testCases := []proto.Request{&gArgs, &pArgs}
-
-	for i, test := range testCases {
-		tc := testContext{}
-		tc.Start(t)
-		// This is a single-replica test; since we're automatically pushing back
-		// the start of a lease as far as possible, and since there is an auto-
-		// matic lease for us at the beginning, we'll basically create a lease from
-		// then on.
-		expStart := tc.rng.getLease().Expiration
-		tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1000))
-
-		test.Header().Timestamp = tc.clock.Now()
-
-		if _, err := tc.rng.AddCmd(tc.rng.context(), test); err != nil {
+	key := proto.Key("a")
+	var timestamps []proto.Timestamp
+	for _, v := range []string{"v1", "v2"} {
+		tc.manualClock.Increment(int64(time.Second))
+		pArgs := putArgs(key, []byte(v), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+		if err != nil {
 			t.Fatal(err)
 		}
-		if held, expired := hasLease(tc.rng, test.Header().Timestamp); !held || expired {
-			t.Fatalf("%d: expected lease acquisition", i)
+		timestamps = append(timestamps, reply.Header().Timestamp)
+	}
+	tc.manualClock.Increment(int64(2 * time.Second))
+
+	read := func(ts proto.Timestamp) ([]byte, error) {
+		gArgs := getArgs(key, 1, tc.store.StoreID())
+		gArgs.Timestamp = ts
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+		if err != nil {
+			return nil, err
 		}
-		lease := tc.rng.getLease()
-		// The lease may start earlier than our request timestamp, but the
-		// expiration will still be measured relative to it.
-		expExpiration := test.Header().Timestamp.Add(int64(DefaultLeaderLeaseDuration), 0)
-		if !lease.Start.Equal(expStart) || !lease.Expiration.Equal(expExpiration) {
-			t.Errorf("%d: unexpected lease timing %s, %s; expected %s, %s", i,
-				lease.Start, lease.Expiration, expStart, expExpiration)
+		return reply.(*proto.GetResponse).Value.Bytes, nil
+	}
+	for i, exp := range []string{"v1", "v2"} {
+		if val, err := read(timestamps[i]); err != nil {
+			t.Fatal(err)
+		} else if string(val) != exp {
+			t.Errorf("%d: expected %q; got %q", i, exp, val)
 		}
-		tc.Stop()
+	}
+
+	// Move the GC threshold past the first write.
+	gcMeta := proto.NewGCMetadata(tc.clock.Now().WallTime)
+	gcMeta.ThresholdNanos = timestamps[0].WallTime + 1
+	if err := engine.MVCCPutProto(tc.engine, nil, keys.RangeGCMetadataKey(tc.rng.Desc().RangeID),
+		proto.ZeroTimestamp, nil, gcMeta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read(timestamps[0]); err == nil {
+		t.Error("expected GC threshold error")
+	} else if _, ok := err.(*proto.ReadBelowGCThresholdError); !ok {
+		t.Errorf("expected GC threshold error; got %T: %s", err, err)
+	}
+	if val, err := read(timestamps[1]); err != nil {
+		t.Fatal(err)
+	} else if string(val) != "v2" {
+		t.Errorf("expected %q; got %q", "v2", val)
 	}
 }
 
-// TestRangeUpdateTSCache verifies that reads and writes update the
-// timestamp cache.
-func TestRangeUpdateTSCache(t *testing.T) {
+// TestRangeReadBelowGCThreshold verifies that consistent and
+// inconsistent reads below the GC threshold of the range fail with a
+// ReadBelowGCThresholdError, while reads above it succeed.
+func TestRangeReadBelowGCThreshold(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	// Set clock to time 1s and do the read.
-	t0 := 1 * time.Second
-	tc.manualClock.Set(t0.Nanoseconds())
-	gArgs := getArgs([]byte("a"), 1, tc.store.StoreID())
-	gArgs.Timestamp = tc.clock.Now()
 
-	_, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+	tc.manualClock.Increment(int64(time.Second))
+	pArgs := putArgs(proto.Key("a"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	tc.manualClock.Increment(int64(5 * time.Second))
 
-	if err != nil {
-		t.Error(err)
+	threshold := proto.Timestamp{WallTime: tc.clock.Now().WallTime - int64(2*time.Second)}
+	gcMeta := proto.NewGCMetadata(tc.clock.Now().WallTime)
+	gcMeta.ThresholdNanos = threshold.WallTime
+	if err := engine.MVCCPutProto(tc.engine, nil, keys.RangeGCMetadataKey(tc.rng.Desc().RangeID),
+		proto.ZeroTimestamp, nil, gcMeta); err != nil {
+		t.Fatal(err)
 	}
-	// Set clock to time 2s for write.
-	t1 := 2 * time.Second
-	tc.manualClock.Set(t1.Nanoseconds())
-	pArgs := putArgs([]byte("b"), []byte("1"), 1, tc.store.StoreID())
-	pArgs.Timestamp = tc.clock.Now()
 
-	_, err = tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	below := threshold.Add(-1, 0)
+	above := threshold.Add(1, 0)
+	for _, consistency := range []proto.ReadConsistencyType{proto.CONSISTENT, proto.INCONSISTENT} {
+		sArgs := scanArgs(proto.Key("a"), proto.Key("b"), 1, tc.store.StoreID())
+		sArgs.ReadConsistency = consistency
+		sArgs.Timestamp = below
+		_, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+		if gcErr, ok := err.(*proto.ReadBelowGCThresholdError); !ok {
+			t.Errorf("%s: expected a ReadBelowGCThresholdError; got %v", consistency, err)
+		} else if !gcErr.Timestamp.Equal(below) || !gcErr.Threshold.Equal(threshold) {
+			t.Errorf("%s: expected timestamp %s and threshold %s; got %+v", consistency, below, threshold, gcErr)
+		}
 
-	if err != nil {
-		t.Error(err)
+		sArgs.Timestamp = above
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+		if err != nil {
+			t.Fatalf("%s: %s", consistency, err)
+		}
+		if rows := reply.(*proto.ScanResponse).Rows; len(rows) != 1 {
+			t.Errorf("%s: expected a single row; got %+v", consistency, rows)
+		}
 	}
-	// Verify the timestamp cache has rTS=1s and wTS=0s for "a".
-	rTS, wTS := tc.rng.tsCache.GetMax(proto.Key("a"), nil, nil)
-	if rTS.WallTime != t0.Nanoseconds() || wTS.WallTime != 0 {
-		t.Errorf("expected rTS=1s and wTS=0s, but got %s, %s", rTS, wTS)
+}
+
+// TestRangeGCThreshold verifies that the GC threshold is reported as
+// recorded in the range's GC metadata.
+func TestRangeGCThreshold(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// No GC has taken place yet.
+	if threshold, err := tc.rng.GCThreshold(); err != nil {
+		t.Fatal(err)
+	} else if !threshold.Equal(proto.ZeroTimestamp) {
+		t.Errorf("expected zero GC threshold; got %s", threshold)
 	}
-	// Verify the timestamp cache has rTS=0s and wTS=2s for "b".
-	rTS, wTS = tc.rng.tsCache.GetMax(proto.Key("b"), nil, nil)
-	if rTS.WallTime != 0 || wTS.WallTime != t1.Nanoseconds() {
-		t.Errorf("expected rTS=0s and wTS=2s, but got %s, %s", rTS, wTS)
+
+	gcMeta := proto.NewGCMetadata(100)
+	gcMeta.ThresholdNanos = 10
+	if err := engine.MVCCPutProto(tc.engine, nil, keys.RangeGCMetadataKey(tc.rng.Desc().RangeID),
+		proto.ZeroTimestamp, nil, gcMeta); err != nil {
+		t.Fatal(err)
 	}
-	// Verify another key ("c") has 0sec in timestamp cache.
-	rTS, wTS = tc.rng.tsCache.GetMax(proto.Key("c"), nil, nil)
-	if rTS.WallTime != 0 || wTS.WallTime != 0 {
-		t.Errorf("expected rTS=0s and wTS=0s, but got %s %s", rTS, wTS)
+	if threshold, err := tc.rng.GCThreshold(); err != nil {
+		t.Fatal(err)
+	} else if exp := makeTS(10, 0); !threshold.Equal(exp) {
+		t.Errorf("expected GC threshold %s; got %s", exp, threshold)
 	}
 }
 
-// TestRangeCommandQueue verifies that reads/writes must wait for
-// pending commands to complete through Raft before being executed on
-// range.
-func TestRangeCommandQueue(t *testing.T) {
+// TestRangeExecutionStats verifies that read commands report the number
+// of keys and bytes they read from the engine, including those of keys
+// which don't yield a row, and that a batch reports the sum over its
+// requests.
+func TestRangeExecutionStats(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	defer func() { TestingCommandFilter = nil }()
-
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Intercept commands with matching command IDs and block them.
-	blockingStart := make(chan struct{})
-	blockingDone := make(chan struct{})
-	TestingCommandFilter = func(args proto.Request) error {
-		if args.Header().GetUserPriority() == 42 {
-			blockingStart <- struct{}{}
-			<-blockingDone
+	const numKeys = 20
+	value := []byte("value")
+	var rowBytes int64
+	for i := 0; i < numKeys; i++ {
+		key := proto.Key(fmt.Sprintf("a%02d", i))
+		pArgs := putArgs(key, value, 1, tc.store.StoreID())
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
 		}
-		return nil
+		rowBytes += int64(len(key) + len(value))
 	}
-
-	// Test all four combinations of reads & writes waiting.
-	testCases := []struct {
-		cmd1Read, cmd2Read bool
-		expWait            bool
-	}{
-		// Read/read doesn't wait.
-		{true, true, false},
-		// All other combinations must wait.
-		{true, false, true},
-		{false, true, true},
-		{false, false, true},
+	// Overwrite a key, whose old version isn't read, and delete another
+	// one, leaving a tombstone which is.
+	pArgs := putArgs(proto.Key("a00"), value, 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	pArgs = putArgs(proto.Key("a20"), value, 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	dArgs := deleteArgs(proto.Key("a20"), 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &dArgs); err != nil {
+		t.Fatal(err)
 	}
 
-	for i, test := range testCases {
-		key1 := proto.Key(fmt.Sprintf("key1-%d", i))
-		key2 := proto.Key(fmt.Sprintf("key2-%d", i))
-		// Asynchronously put a value to the rng with blocking enabled.
-		cmd1Done := make(chan struct{})
-		go func() {
-			args := readOrWriteArgs(key1, test.cmd1Read, tc.rng.Desc().RangeID, tc.store.StoreID())
-			args.Header().UserPriority = gogoproto.Int32(42)
-
-			_, err := tc.rng.AddCmd(tc.rng.context(), args)
-
-			if err != nil {
-				t.Fatalf("test %d: %s", i, err)
-			}
-			close(cmd1Done)
-		}()
-		// Wait for cmd1 to get into the command queue.
-		<-blockingStart
-
-		// First, try a command for same key as cmd1 to verify it blocks.
-		cmd2Done := make(chan struct{})
-		go func() {
-			args := readOrWriteArgs(key1, test.cmd2Read, tc.rng.Desc().RangeID, tc.store.StoreID())
-
-			_, err := tc.rng.AddCmd(tc.rng.context(), args)
-
-			if err != nil {
-				t.Fatalf("test %d: %s", i, err)
-			}
-			close(cmd2Done)
-		}()
+	// Each key is read as its metadata and the version visible to the
+	// scan, which for the deleted key is the tombstone.
+	const expKeys = 2 * (numKeys + 1)
+	sArgs := scanArgs(proto.Key("a"), proto.Key("b"), 1, tc.store.StoreID())
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := reply.(*proto.ScanResponse).Rows; len(rows) != numKeys {
+		t.Fatalf("expected %d rows; got %d", numKeys, len(rows))
+	}
+	stats := reply.Header().Stats
+	if stats == nil {
+		t.Fatal("expected scan to report execution stats")
+	}
+	if stats.KeysScanned != expKeys || stats.BytesRead <= rowBytes {
+		t.Errorf("expected %d keys and more than %d bytes read; got %+v", expKeys, rowBytes, stats)
+	}
 
-		// Next, try read for a non-impacted key--should go through immediately.
-		cmd3Done := make(chan struct{})
-		go func() {
-			args := readOrWriteArgs(key2, true, tc.rng.Desc().RangeID, tc.store.StoreID())
+	// A batch accumulates the statistics of its requests.
+	bArgs := &proto.BatchRequest{}
+	bArgs.RangeID = 1
+	bArgs.Replica = proto.Replica{StoreID: tc.store.StoreID()}
+	for i := 0; i < 2; i++ {
+		sArgs := scanArgs(proto.Key("a"), proto.Key("b"), 1, tc.store.StoreID())
+		bArgs.Add(&sArgs)
+	}
+	reply, err = tc.rng.AddCmd(tc.rng.context(), bArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bStats := reply.Header().Stats; bStats == nil || *bStats != (proto.ExecutionStats{
+		KeysScanned: 2 * stats.KeysScanned,
+		BytesRead:   2 * stats.BytesRead,
+	}) {
+		t.Errorf("expected batch stats to double %+v; got %+v", stats, bStats)
+	}
 
-			_, err := tc.rng.AddCmd(tc.rng.context(), args)
+	// Writes which read nothing report no statistics.
+	pArgs = putArgs(proto.Key("b"), value, 1, tc.store.StoreID())
+	if reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	} else if stats := reply.Header().Stats; stats != nil {
+		t.Errorf("expected no execution stats for put; got %+v", stats)
+	}
+}
 
-			if err != nil {
-				t.Fatalf("test %d: %s", i, err)
-			}
-			close(cmd3Done)
-		}()
+// TestRangeScanPredicate verifies that a scan with a predicate returns
+// only the matching rows and that non-matching rows don't count towards
+// the scan's max results.
+func TestRangeScanPredicate(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-		if test.expWait {
-			// Verify cmd3 finishes but not cmd2.
-			select {
-			case <-cmd2Done:
-				t.Fatalf("test %d: should not have been able to execute cmd2", i)
-			case <-cmd3Done:
-				// success.
-			case <-cmd1Done:
-				t.Fatalf("test %d: should not have been able execute cmd1 while blocked", i)
-			case <-time.After(500 * time.Millisecond):
-				t.Fatalf("test %d: waited 500ms for cmd3 of key2", i)
-			}
-		} else {
-			select {
-			case <-cmd2Done:
-				// success.
-			case <-cmd1Done:
-				t.Fatalf("test %d: should not have been able to execute cmd1 while blocked", i)
-			case <-time.After(500 * time.Millisecond):
-				t.Fatalf("test %d: waited 500ms for cmd2 of key1", i)
-			}
-			<-cmd3Done
+	for _, kv := range []struct{ key, value string }{
+		{"a1", "1"}, {"a2", "5"}, {"b1", "2"}, {"b2", "7"},
+	} {
+		pArgs := putArgs(proto.Key(kv.key), []byte(kv.value), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
 		}
+	}
 
-		blockingDone <- struct{}{}
-		select {
-		case <-cmd2Done:
-			// success.
-		case <-time.After(500 * time.Millisecond):
-			t.Fatalf("test %d: waited 500ms for cmd2 of key1", i)
+	testCases := []struct {
+		predicate  *proto.ScanPredicate
+		maxResults int64
+		expKeys    []string
+	}{
+		{nil, 0, []string{"a1", "a2", "b1", "b2"}},
+		{&proto.ScanPredicate{KeyPrefix: proto.Key("a")}, 0, []string{"a1", "a2"}},
+		{&proto.ScanPredicate{KeyPrefix: proto.Key("b")}, 1, []string{"b1"}},
+		{&proto.ScanPredicate{ValueStart: []byte("2"), ValueEnd: []byte("6")}, 0, []string{"a2", "b1"}},
+		{&proto.ScanPredicate{KeyPrefix: proto.Key("b"), ValueStart: []byte("5")}, 0, []string{"b2"}},
+		{&proto.ScanPredicate{KeyPrefix: proto.Key("c")}, 0, nil},
+	}
+	for i, test := range testCases {
+		sArgs := scanArgs(proto.Key("a"), proto.Key("c"), 1, tc.store.StoreID())
+		sArgs.Timestamp = tc.clock.Now()
+		sArgs.MaxResults = test.maxResults
+		sArgs.Predicate = test.predicate
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		var scanned []string
+		for _, kv := range reply.(*proto.ScanResponse).Rows {
+			scanned = append(scanned, string(kv.Key))
+		}
+		if !reflect.DeepEqual(scanned, test.expKeys) {
+			t.Errorf("%d: expected keys %v; got %v", i, test.expKeys, scanned)
 		}
 	}
 }
 
-// TestRangeCommandQueueInconsistent verifies that inconsistent reads need
-// not wait for pending commands to complete through Raft.
-func TestRangeCommandQueueInconsistent(t *testing.T) {
+// TestRangeDeleteRangePredicateTimestamp verifies that a DeleteRange
+// with a predicate timestamp deletes only the keys whose latest version
+// predates it.
+func TestRangeDeleteRangePredicateTimestamp(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	defer func() { TestingCommandFilter = nil }()
-
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	key := proto.Key("key1")
-	blockingStart := make(chan struct{})
-	blockingDone := make(chan struct{})
-	TestingCommandFilter = func(args proto.Request) error {
-		if args.Header().CmdID.Random == 1 {
-			blockingStart <- struct{}{}
-			<-blockingDone
+	// Write "a" and "b" at time 1, "c" at time 3 and overwrite "b" at
+	// time 3 so that its latest version is newer than the predicate.
+	for _, kv := range []struct {
+		key  string
+		wall int64
+	}{
+		{"a", 1}, {"b", 1}, {"c", 3}, {"b", 3},
+	} {
+		pArgs := putArgs(proto.Key(kv.key), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = makeTS(kv.wall, 0)
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
 		}
-		return nil
 	}
-	cmd1Done := make(chan struct{})
-	go func() {
-		args := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
-		args.CmdID.Random = 1
 
-		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	predTS := makeTS(2, 0)
+	dArgs := proto.DeleteRangeRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       proto.Key("a"),
+			EndKey:    proto.Key("d"),
+			RangeID:   1,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Timestamp: makeTS(4, 0),
+		},
+		PredicateTimestamp: &predTS,
+	}
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &dArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num := reply.(*proto.DeleteRangeResponse).NumDeleted; num != 1 {
+		t.Errorf("expected 1 key deleted; got %d", num)
+	}
 
-		if err != nil {
-			t.Fatal(err)
-		}
-		close(cmd1Done)
-	}()
-	// Wait for cmd1 to get into the command queue.
-	<-blockingStart
+	sArgs := scanArgs(proto.Key("a"), proto.Key("d"), 1, tc.store.StoreID())
+	sArgs.Timestamp = makeTS(5, 0)
+	sReply, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scanned []string
+	for _, kv := range sReply.(*proto.ScanResponse).Rows {
+		scanned = append(scanned, string(kv.Key))
+	}
+	if expKeys := []string{"b", "c"}; !reflect.DeepEqual(scanned, expKeys) {
+		t.Errorf("expected keys %v; got %v", expKeys, scanned)
+	}
+}
 
-	// An inconsistent read to the key won't wait.
-	cmd2Done := make(chan struct{})
-	go func() {
-		args := getArgs(key, tc.rng.Desc().RangeID, tc.store.StoreID())
-		args.ReadConsistency = proto.INCONSISTENT
+// TestRangeDeleteRangeReturnKeys verifies that a DeleteRange which asks
+// for the deleted keys returns them, along with a resume key if the
+// deletion was cut short by a limit.
+func TestRangeDeleteRangeReturnKeys(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		pArgs := putArgs(proto.Key(key), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
 
+	deleteRange := func(key, endKey proto.Key, max int64) *proto.DeleteRangeResponse {
+		dArgs := proto.DeleteRangeRequest{
+			RequestHeader: proto.RequestHeader{
+				Key:       key,
+				EndKey:    endKey,
+				RangeID:   1,
+				Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+				Timestamp: tc.clock.Now(),
+			},
+			MaxEntriesToDelete: max,
+			ReturnKeys:         true,
+		}
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &dArgs)
 		if err != nil {
 			t.Fatal(err)
 		}
-		close(cmd2Done)
-	}()
+		return reply.(*proto.DeleteRangeResponse)
+	}
 
-	select {
-	case <-cmd2Done:
-		// success.
-	case <-cmd1Done:
-		t.Fatalf("cmd1 should have been blocked")
-	case <-time.After(500 * time.Millisecond):
-		t.Fatalf("waited 500ms for cmd2 of key")
+	// Unbounded deletion of [a, c) returns both keys and no resume key.
+	reply := deleteRange(proto.Key("a"), proto.Key("c"), 0)
+	if expKeys := []proto.Key{proto.Key("a"), proto.Key("b")}; !reflect.DeepEqual(reply.Keys, expKeys) {
+		t.Errorf("expected keys %v; got %v", expKeys, reply.Keys)
+	}
+	if reply.NumDeleted != 2 || reply.ResumeKey != nil {
+		t.Errorf("expected 2 keys deleted and no resume key; got %d, %q", reply.NumDeleted, reply.ResumeKey)
 	}
 
-	blockingDone <- struct{}{}
-	select {
-	case <-cmd1Done:
-		// success.
-	case <-time.After(500 * time.Millisecond):
-		t.Fatalf("waited 500ms for cmd2 of key")
+	// A bounded deletion stops at the bound and returns a resume key,
+	// from which the deletion picks up the remaining keys.
+	reply = deleteRange(proto.Key("a"), proto.Key("z"), 2)
+	if expKeys := []proto.Key{proto.Key("c"), proto.Key("d")}; !reflect.DeepEqual(reply.Keys, expKeys) {
+		t.Errorf("expected keys %v; got %v", expKeys, reply.Keys)
+	}
+	if expResume := proto.Key("d").Next(); !reply.ResumeKey.Equal(expResume) {
+		t.Fatalf("expected resume key %q; got %q", expResume, reply.ResumeKey)
+	}
+	reply = deleteRange(reply.ResumeKey, proto.Key("z"), 2)
+	if expKeys := []proto.Key{proto.Key("e")}; !reflect.DeepEqual(reply.Keys, expKeys) {
+		t.Errorf("expected keys %v; got %v", expKeys, reply.Keys)
+	}
+	if reply.ResumeKey != nil {
+		t.Errorf("expected no resume key; got %q", reply.ResumeKey)
 	}
 }
 
-// TestRangeUseTSCache verifies that write timestamps are upgraded
-// based on the read timestamp cache.
-func TestRangeUseTSCache(t *testing.T) {
+// TestRangeReadOnlyBatch verifies that the responses to a read-only
+// batch, whose requests are executed concurrently, are returned in
+// request order, and that overlapping requests see the same data.
+func TestRangeReadOnlyBatch(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	// Set clock to time 1s and do the read.
-	t0 := 1 * time.Second
-	tc.manualClock.Set(t0.Nanoseconds())
-	args := getArgs([]byte("a"), 1, tc.store.StoreID())
-	args.Timestamp = tc.clock.Now()
 
-	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
-
-	if err != nil {
-		t.Error(err)
+	const numKeys = 50
+	for i := 0; i < numKeys; i++ {
+		key := proto.Key(fmt.Sprintf("key%02d", i))
+		pArgs := putArgs(key, []byte(fmt.Sprintf("value%02d", i)), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
 	}
-	pArgs := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
 
-	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	// Gets in descending key order, interleaved with a scan which
+	// overlaps some of them.
+	bArgs := &proto.BatchRequest{}
+	bArgs.RangeID = 1
+	bArgs.Replica = proto.Replica{StoreID: tc.store.StoreID()}
+	for i := numKeys - 1; i >= 0; i-- {
+		gArgs := getArgs(proto.Key(fmt.Sprintf("key%02d", i)), 1, tc.store.StoreID())
+		bArgs.Add(&gArgs)
+		if i == numKeys/2 {
+			sArgs := scanArgs(proto.Key("key10"), proto.Key("key13"), 1, tc.store.StoreID())
+			bArgs.Add(&sArgs)
+		}
+	}
+	reply, err := tc.rng.AddCmd(tc.rng.context(), bArgs)
 	if err != nil {
 		t.Fatal(err)
 	}
-	pReply := reply.(*proto.PutResponse)
-	if pReply.Timestamp.WallTime != tc.clock.Timestamp().WallTime {
-		t.Errorf("expected write timestamp to upgrade to 1s; got %s", pReply.Timestamp)
+	br := reply.(*proto.BatchResponse)
+	if len(br.Responses) != numKeys+1 {
+		t.Fatalf("expected %d responses; got %d", numKeys+1, len(br.Responses))
+	}
+	for i, union := range br.Responses {
+		req := bArgs.Requests[i].GetValue().(proto.Request)
+		switch resp := union.GetValue().(type) {
+		case *proto.GetResponse:
+			expValue := "value" + strings.TrimPrefix(string(req.Header().Key), "key")
+			if resp.Value == nil || string(resp.Value.Bytes) != expValue {
+				t.Errorf("%d: expected %s for %s; got %+v", i, expValue, req.Header().Key, resp.Value)
+			}
+		case *proto.ScanResponse:
+			if _, ok := req.(*proto.ScanRequest); !ok {
+				t.Fatalf("%d: expected a response to %s; got a scan response", i, req.Method())
+			}
+			if len(resp.Rows) != 3 {
+				t.Errorf("%d: expected 3 rows; got %d", i, len(resp.Rows))
+			}
+		default:
+			t.Fatalf("%d: unexpected response %T", i, resp)
+		}
 	}
 }
 
-// TestRangeNoTSCacheInconsistent verifies that the timestamp cache
-// is no affected by inconsistent reads.
-func TestRangeNoTSCacheInconsistent(t *testing.T) {
+// TestRangeReadOnlyBatchMixedConsistency verifies that a
+// non-transactional read-only batch may mix consistent and inconsistent
+// reads, with only the inconsistent ones bypassing the command queue,
+// and that a transactional batch can't read inconsistently.
+func TestRangeReadOnlyBatchMixedConsistency(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	// Set clock to time 1s and do the read.
-	t0 := 1 * time.Second
-	tc.manualClock.Set(t0.Nanoseconds())
-	args := getArgs([]byte("a"), 1, tc.store.StoreID())
-	args.Timestamp = tc.clock.Now()
-	args.ReadConsistency = proto.INCONSISTENT
 
-	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	for _, k := range []string{"a", "b"} {
+		pArgs := putArgs(proto.Key(k), []byte("value-"+k), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
 
+	// Occupy the command queue on "a" as an ongoing write would.
+	pArgs := putArgs(proto.Key("a"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	cmdKey, err := tc.rng.beginCmd(&pArgs.RequestHeader, false, false)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	pArgs := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+	defer tc.rng.endCmd(cmdKey, &pArgs, nil, false)
 
-	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	newBatch := func(consistency proto.ReadConsistencyType) *proto.BatchRequest {
+		bArgs := &proto.BatchRequest{}
+		bArgs.RangeID = 1
+		bArgs.Replica = proto.Replica{StoreID: tc.store.StoreID()}
+		gArgsA := getArgs(proto.Key("a"), 1, tc.store.StoreID())
+		gArgsA.ReadConsistency = consistency
+		gArgsB := getArgs(proto.Key("b"), 1, tc.store.StoreID())
+		bArgs.Add(&gArgsA)
+		bArgs.Add(&gArgsB)
+		return bArgs
+	}
+
+	// The inconsistent read of "a" doesn't wait for the write, while
+	// the consistent read of "b" doesn't conflict with it.
+	reply, err := tc.rng.TryAddCmd(tc.rng.context(), newBatch(proto.INCONSISTENT))
 	if err != nil {
 		t.Fatal(err)
 	}
-	pReply := reply.(*proto.PutResponse)
-	if pReply.Timestamp.WallTime == tc.clock.Timestamp().WallTime {
-		t.Errorf("expected write timestamp not to upgrade to 1s; got %s", pReply.Timestamp)
+	for i, union := range reply.(*proto.BatchResponse).Responses {
+		expValue := []string{"value-a", "value-b"}[i]
+		if v := union.GetValue().(*proto.GetResponse).Value; v == nil || string(v.Bytes) != expValue {
+			t.Errorf("%d: expected %s; got %+v", i, expValue, v)
+		}
+	}
+
+	// A consistent read of "a" has to wait for the write.
+	if _, err := tc.rng.TryAddCmd(tc.rng.context(), newBatch(proto.CONSISTENT)); err == nil {
+		t.Fatal("expected consistent read to wait for the write")
+	} else if _, ok := err.(*CommandQueueBusyError); !ok {
+		t.Fatalf("expected CommandQueueBusyError; got %v", err)
+	}
+
+	// Transactional batches can't read inconsistently.
+	bArgs := newBatch(proto.INCONSISTENT)
+	bArgs.Txn = newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+	if _, err := tc.rng.TryAddCmd(tc.rng.context(), bArgs); !testutils.IsError(err, "inconsistent reads within a transaction") {
+		t.Fatalf("expected inconsistent read to be refused; got %v", err)
 	}
 }
 
-// TestRangeNoTSCacheUpdateOnFailure verifies that read and write
-// commands do not update the timestamp cache if they result in
-// failure.
-func TestRangeNoTSCacheUpdateOnFailure(t *testing.T) {
+// TestRangeReadSet verifies that a transactional scan which requests a
+// read set returns exactly the keys scanned, along with the timestamps
+// of the versions read.
+func TestRangeReadSet(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Test for both read & write attempts.
-	for i, read := range []bool{true, false} {
-		key := proto.Key(fmt.Sprintf("key-%d", i))
-
-		// Start by laying down an intent to trip up future read or write to same key.
-		pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
-		pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pArgs.Timestamp = pArgs.Txn.Timestamp
-
-		reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
-		if err != nil {
-			t.Fatalf("test %d: %s", i, err)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		pArgs := putArgs(proto.Key(k), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
 		}
-		pReply := reply.(*proto.PutResponse)
-
-		// Now attempt read or write.
-		args := readOrWriteArgs(key, read, tc.rng.Desc().RangeID, tc.store.StoreID())
-		args.Header().Timestamp = tc.clock.Now() // later timestamp
+	}
 
-		if _, err := tc.rng.AddCmd(tc.rng.context(), args); err == nil {
-			t.Errorf("test %d: expected failure", i)
+	sArgs := scanArgs(proto.Key("b"), proto.Key("z"), 1, tc.store.StoreID())
+	sArgs.Txn = newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
+	sArgs.Timestamp = sArgs.Txn.Timestamp
+	sArgs.RecordReadSet = true
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sReply := reply.(*proto.ScanResponse)
+	if len(sReply.Rows) != 3 || len(sReply.ReadSet) != len(sReply.Rows) {
+		t.Fatalf("expected a read set entry for each of 3 rows; got rows %+v, read set %+v", sReply.Rows, sReply.ReadSet)
+	}
+	for i, kv := range sReply.Rows {
+		entry := sReply.ReadSet[i]
+		if !entry.Key.Equal(kv.Key) || !entry.Timestamp.Equal(*kv.Value.Timestamp) {
+			t.Errorf("%d: expected read set entry for %q at %s; got %+v", i, kv.Key, kv.Value.Timestamp, entry)
 		}
+	}
 
-		// Write the intent again -- should not have its timestamp upgraded!
-
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
-			t.Fatalf("test %d: %s", i, err)
-		}
-		if !pReply.Timestamp.Equal(pArgs.Timestamp) {
-			t.Errorf("expected timestamp not to advance %s != %s", pReply.Timestamp, pArgs.Timestamp)
-		}
+	// Without the flag, no read set is returned.
+	sArgs.RecordReadSet = false
+	if reply, err = tc.rng.AddCmd(tc.rng.context(), &sArgs); err != nil {
+		t.Fatal(err)
+	}
+	if rs := reply.Header().ReadSet; len(rs) != 0 {
+		t.Errorf("expected no read set; got %+v", rs)
 	}
 }
 
-// TestRangeNoTimestampIncrementWithinTxn verifies that successive
-// read the write commands within the same transaction do not cause
-// the write to receive an incremented timestamp.
-func TestRangeNoTimestampIncrementWithinTxn(t *testing.T) {
+// TestRangeComputeSplitKeyBySize writes two large values followed by
+// many small ones and verifies that the computed split key balances the
+// halves by bytes rather than by key count.
+func TestRangeComputeSplitKeyBySize(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Test for both read & write attempts.
-	key := proto.Key("a")
-	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-
-	// Start with a read to warm the timestamp cache.
-	gArgs := getArgs(key, 1, tc.store.StoreID())
-	gArgs.Txn = txn
-	gArgs.Timestamp = txn.Timestamp
+	values := map[string][]byte{"a": make([]byte, 1<<15), "b": make([]byte, 1<<15)}
+	for _, k := range []string{"c", "d", "e", "f", "g", "h", "i", "j"} {
+		values[k] = []byte("small")
+	}
+	for k, v := range values {
+		pArgs := putArgs(proto.Key(k), v, 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+	// A split by key count would fall around "e"; by size, each half
+	// must hold one of the two large values.
+	splitKey, err := tc.rng.computeSplitKeyBySize()
+	if err != nil {
 		t.Fatal(err)
 	}
+	if !splitKey.Equal(proto.Key("b")) {
+		t.Errorf("expected split key %q; got %q", "b", splitKey)
+	}
+}
 
-	// Now try a write and verify timestamp isn't incremented.
-	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
-	pArgs.Txn = txn
-	pArgs.Timestamp = pArgs.Txn.Timestamp
+// TestRangeEffectiveZoneConfig verifies that a range is subject to the
+// zone config of the most specific zone prefix matching it, and that
+// modifying the returned config doesn't affect the gossiped one.
+func TestRangeEffectiveZoneConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	zones := []config.ZoneConfig{
+		{RangeMaxBytes: 1 << 20},
+		{RangeMaxBytes: 2 << 20},
+		{RangeMaxBytes: 3 << 20},
+	}
+	pcc, err := config.NewPrefixConfigMap([]config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &zones[0]),
+		config.MakePrefixConfig(proto.Key("a"), nil, &zones[1]),
+		config.MakePrefixConfig(proto.Key("ab"), nil, &zones[2]),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	pReply := reply.(*proto.PutResponse)
-	if !pReply.Timestamp.Equal(pArgs.Timestamp) {
-		t.Errorf("expected timestamp to remain %s; got %s", pArgs.Timestamp, pReply.Timestamp)
+	if err := tc.gossip.AddInfoProto(gossip.KeyConfigZone, pcc, 0); err != nil {
+		t.Fatal(err)
 	}
 
-	// Finally, try a non-transactional write and verify timestamp is incremented.
-	pArgs.Txn = nil
-	expTS := pArgs.Timestamp
-	expTS.Logical++
+	testCases := []struct {
+		start, end proto.Key
+		expMax     int64
+	}{
+		{proto.Key("0"), proto.Key("1"), 1 << 20},
+		{proto.Key("a"), proto.Key("aa"), 2 << 20},
+		{proto.Key("aa"), proto.Key("ab"), 2 << 20},
+		{proto.Key("ab"), proto.Key("abc"), 3 << 20},
+		{proto.Key("abc"), proto.Key("ac"), 3 << 20},
+		{proto.Key("ac"), proto.Key("b"), 2 << 20},
+		{proto.Key("b"), proto.Key("c"), 1 << 20},
+	}
+	for i, test := range testCases {
+		desc := &proto.RangeDescriptor{
+			RangeID:  proto.RangeID(2 + i),
+			StartKey: test.start,
+			EndKey:   test.end,
+			Replicas: []proto.Replica{{NodeID: 1, StoreID: 1}},
+		}
+		rng, err := NewReplica(desc, tc.store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zone, err := rng.effectiveZoneConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zone.RangeMaxBytes != test.expMax {
+			t.Errorf("%d: expected range max bytes %d for [%q,%q); got %d",
+				i, test.expMax, test.start, test.end, zone.RangeMaxBytes)
+		}
+	}
 
-	if reply, err = tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
-		t.Errorf("expected write intent error")
+	zone, err := tc.rng.effectiveZoneConfig()
+	if err != nil {
+		t.Fatal(err)
 	}
-	pReply = reply.(*proto.PutResponse)
-	if !pReply.Timestamp.Equal(expTS) {
-		t.Errorf("expected timestamp to increment to %s; got %s", expTS, pReply.Timestamp)
+	zone.RangeMaxBytes = 1
+	if zone, err = tc.rng.effectiveZoneConfig(); err != nil {
+		t.Fatal(err)
+	} else if zone.RangeMaxBytes != 1<<20 {
+		t.Errorf("expected gossiped zone config to be unchanged; got range max bytes %d", zone.RangeMaxBytes)
 	}
 }
 
-// TestRangeIdempotence verifies that a retry increment with
-// same client command ID receives same reply.
-func TestRangeIdempotence(t *testing.T) {
+// TestRangeReplicationStatus verifies that a range with fewer replicas
+// than its zone config calls for is reported as under-replicated.
+func TestRangeReplicationStatus(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Run the same increment 100 times, 50 with identical command IDs,
-	// interleaved with 50 using a sequence of different command IDs.
-	const numIncs = 100
-	var wg sync.WaitGroup
-	var count int64
-	incFunc := func(idx int) {
-		args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
-		args.Header().Timestamp = tc.clock.Now()
-		if idx%2 == 0 {
-			args.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
-		} else {
-			args.CmdID = proto.ClientCmdID{WallTime: 1, Random: int64(idx + 100)}
+	zone := config.ZoneConfig{
+		ReplicaAttrs:  []proto.Attributes{{}, {}, {}},
+		RangeMinBytes: 1 << 10,
+		RangeMaxBytes: 1 << 18,
+	}
+	pcc, err := config.NewPrefixConfigMap([]config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &zone),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.gossip.AddInfoProto(gossip.KeyConfigZone, pcc, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, replicas := range []int{2, 3} {
+		desc := &proto.RangeDescriptor{
+			RangeID:  proto.RangeID(2 + i),
+			StartKey: proto.Key("a"),
+			EndKey:   proto.Key("b"),
 		}
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
-		reply := resp.(*proto.IncrementResponse)
+		for j := 1; j <= replicas; j++ {
+			desc.Replicas = append(desc.Replicas, proto.Replica{NodeID: proto.NodeID(j), StoreID: proto.StoreID(j)})
+		}
+		rng, err := NewReplica(desc, tc.store)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if idx%2 == 0 && reply.NewValue != 1 {
-			t.Errorf("expected all incremented values to be 1; got %d", reply.NewValue)
-		} else if idx%2 == 1 {
-			atomic.AddInt64(&count, reply.NewValue)
+		current, desired, under := rng.ReplicationStatus()
+		if expUnder := replicas < 3; current != replicas || desired != 3 || under != expUnder {
+			t.Errorf("%d: expected (%d, 3, %t); got (%d, %d, %t)", i, replicas, expUnder, current, desired, under)
 		}
-		wg.Done()
-	}
-
-	wg.Add(numIncs)
-	// The assertions for the even-numbered half of this test look for a
-	// hardcoded value of 1, so we have to make sure the first iteration
-	// that actually runs is even-numbered so that the cached response
-	// for that command ID has a value of 1. Otherwise, N odd-numbered
-	// operations may get scheduled first, run with a different command
-	// ID, and cause even-numbered operations to report something other
-	// than 1, depending on the value of N.
-	incFunc(0)
-	for i := 1; i < numIncs; i++ {
-		go incFunc(i)
-	}
-	// Wait for all to complete.
-	wg.Wait()
-
-	// Verify that all non-repeated client commands incremented the
-	// counter starting at 2 all the way to 51 (sum of sequence = 1325).
-	if count != 1325 {
-		t.Errorf("expected sum of all increments to be 1325; got %d", count)
 	}
 }
 
-// TestRangeResponseCacheReadError verifies that an error is returned to the
-// client in the event that a response cache entry is found but is not
-// decodable.
-func TestRangeResponseCacheReadError(t *testing.T) {
+// TestRangeHealthCheck verifies that HealthCheck reports a healthy
+// range as such and flags lease, replication and corruption problems.
+func TestRangeHealthCheck(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
-	args.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
-
-	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	h := tc.rng.HealthCheck()
+	if !h.Healthy || !h.LeaseValid || h.LeaseHolder != tc.store.RaftNodeID() ||
+		h.UnderReplicated || h.Corrupt {
+		t.Fatalf("expected healthy range, got %+v", h)
+	}
 
-	if err != nil {
+	// Let the lease expire.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	if h := tc.rng.HealthCheck(); h.Healthy || h.LeaseValid {
+		t.Errorf("expected expired lease to be reported, got %+v", h)
+	}
+	if err := tc.rng.redirectOnOrAcquireLeaderLease(nil, tc.clock.Now()); err != nil {
 		t.Fatal(err)
 	}
+	if h := tc.rng.HealthCheck(); !h.Healthy {
+		t.Fatalf("expected healthy range after acquiring lease, got %+v", h)
+	}
 
-	// Overwrite repsonse cache entry with garbage for the last op.
-	key := keys.ResponseCacheKey(tc.rng.Desc().RangeID, &args.CmdID)
-	err = engine.MVCCPut(tc.engine, nil, key, proto.ZeroTimestamp, proto.Value{Bytes: []byte("\xff")}, nil)
+	// Require more replicas than the range has.
+	zone := config.ZoneConfig{
+		ReplicaAttrs:  []proto.Attributes{{}, {}, {}},
+		RangeMinBytes: 1 << 10,
+		RangeMaxBytes: 1 << 18,
+	}
+	pcc, err := config.NewPrefixConfigMap([]config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &zone),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Now try increment again and verify error.
-	_, err = tc.rng.AddCmd(tc.rng.context(), &args)
-	if err == nil {
+	if err := tc.gossip.AddInfoProto(gossip.KeyConfigZone, pcc, 0); err != nil {
 		t.Fatal(err)
 	}
+	if h := tc.rng.HealthCheck(); h.Healthy || !h.UnderReplicated || !h.LeaseValid {
+		t.Errorf("expected under-replicated range, got %+v", h)
+	}
+
+	// Induce corruption.
+	tc.rng.maybeSetCorrupt(newReplicaCorruptionError(util.Errorf("boom")))
+	if h := tc.rng.HealthCheck(); h.Healthy || !h.Corrupt {
+		t.Errorf("expected corrupt range, got %+v", h)
+	}
 }
 
-// TestRangeResponseCacheStoredError verifies that if a cached entry contains
-// an error, that error is returned.
-func TestRangeResponseCacheStoredError(t *testing.T) {
+// TestRangeHotKeys verifies that a key which is accessed far more often
+// than others is reported as the hottest key, and that access counts
+// decay over time.
+func TestRangeHotKeys(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	cmdID := proto.ClientCmdID{WallTime: 1, Random: 1}
-	// Write an error into the response cache.
-	pastReply := proto.IncrementResponse{}
-	pastError := errors.New("boom")
-	var expError error = &proto.Error{Message: pastError.Error()}
-	_ = tc.rng.respCache.PutResponse(tc.engine, cmdID,
-		proto.ResponseWithError{Reply: &pastReply, Err: pastError})
+	hotKey := proto.Key("hot")
+	for i := 0; i < 100; i++ {
+		key := hotKey
+		if i%4 == 0 {
+			key = proto.Key(fmt.Sprintf("cold-%d", i))
+		}
+		args := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hotKeys := tc.rng.HotKeys()
+	if len(hotKeys) == 0 || len(hotKeys) > hotKeyCount {
+		t.Fatalf("expected between 1 and %d hot keys, got %+v", hotKeyCount, hotKeys)
+	}
+	if !hotKeys[0].Key.Equal(hotKey) || hotKeys[0].Count < 75 {
+		t.Fatalf("expected %q to be the hottest key with at least 75 accesses, got %+v", hotKey, hotKeys)
+	}
+	for i := 1; i < len(hotKeys); i++ {
+		if hotKeys[i].Count > hotKeys[i-1].Count {
+			t.Errorf("%d: hot keys not sorted by count: %+v", i, hotKeys)
+		}
+	}
 
-	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
-	args.CmdID = cmdID
-	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
-	if err == nil {
-		t.Fatal("expected to see cached error but got nil")
-	} else if ge, ok := err.(*proto.Error); !ok {
-		t.Fatalf("expected proto.Error but got %s", err)
-	} else if !reflect.DeepEqual(ge, expError) {
-		t.Fatalf("expected <%T> %+v but got <%T> %+v", expError, expError, ge, ge)
+	// Counts are halved once per window.
+	count := hotKeys[0].Count
+	tc.manualClock.Increment(int64(2 * hotKeyWindow))
+	args := getArgs(hotKey, 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+		t.Fatal(err)
+	}
+	if hotKeys := tc.rng.HotKeys(); len(hotKeys) == 0 || hotKeys[0].Count != count/4+1 {
+		t.Errorf("expected decayed count %d for %q, got %+v", count/4+1, hotKey, hotKeys)
 	}
 }
 
-// TestEndTransactionWithMalformedSplitTrigger verifies an
-// EndTransaction call with a malformed commit trigger fails.
-func TestEndTransactionWithMalformedSplitTrigger(t *testing.T) {
+// TestRangeMaxIntentsPerRead verifies that a read collects no more than
+// the configured number of skipped intents and flags that there are
+// more.
+func TestRangeMaxIntentsPerRead(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
+	tc.store.ctx.MaxIntentsPerRead = 5
 
-	key := proto.Key("foo")
-	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-	pArgs := putArgs(key, []byte("only here to make this a rw transaction"), tc.rng.Desc().RangeID, tc.store.StoreID())
-	pArgs.Txn = txn
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
-		t.Fatal(err)
-	}
-
-	args := endTxnArgs(txn, true /* commit */, 1, tc.store.StoreID())
-	args.Timestamp = txn.Timestamp
-	// Make an EndTransaction request which would fail if not
-	// stripped. In this case, we set the start key to "bar" for a
-	// split of the default range; start key must be "" in this case.
-	args.InternalCommitTrigger = &proto.InternalCommitTrigger{
-		SplitTrigger: &proto.SplitTrigger{
-			UpdatedDesc: proto.RangeDescriptor{StartKey: proto.Key("bar")},
-		},
+	const numIntents = 10
+	for i := 0; i < numIntents; i++ {
+		key := proto.Key(fmt.Sprintf("key-%02d", i))
+		pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pArgs.Timestamp = pArgs.Txn.Timestamp
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); !testutils.IsError(err, "range does not match splits") {
-		t.Errorf("expected range does not match splits error; got %s", err)
+	for _, maxIntents := range []int{5, numIntents} {
+		tc.store.ctx.MaxIntentsPerRead = maxIntents
+		sArgs := scanArgs(proto.Key("key-"), proto.Key("key-\xff"), 1, tc.store.StoreID())
+		sArgs.ReadConsistency = proto.INCONSISTENT
+		sArgs.Timestamp = tc.clock.Now()
+		_, intents, more, err := tc.rng.executeCmd(tc.rng.rm.Engine(), nil, &sArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expMore := maxIntents < numIntents; len(intents) != maxIntents || more != expMore {
+			t.Errorf("max %d: expected %d intents and more=%t, got %d and more=%t",
+				maxIntents, maxIntents, expMore, len(intents), more)
+		}
 	}
 }
 
-// TestEndTransactionBeforeHeartbeat verifies that a transaction
-// can be committed/aborted before being heartbeat.
-func TestEndTransactionBeforeHeartbeat(t *testing.T) {
+// TestRangeBatchTooLarge verifies that batches exceeding the maximum
+// number of requests or bytes are rejected with a BatchTooLargeError
+// describing the batch and the limits, before any of their requests is
+// executed.
+func TestRangeBatchTooLarge(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	// Don't automatically GC the Txn record: We want to heartbeat the
-	// committed Transaction and compare it against our expectations.
-	// When it's removed, the heartbeat would recreate it.
-	defer withoutTxnAutoGC()()
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	key := []byte("a")
-	for _, commit := range []bool{true, false} {
-		txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		args := endTxnArgs(txn, commit, 1, tc.store.StoreID())
-		args.Timestamp = txn.Timestamp
+	bArgs := &proto.BatchRequest{}
+	bArgs.RangeID = tc.rng.Desc().RangeID
+	bArgs.Timestamp = tc.clock.Now()
+	for i := 0; i < 4; i++ {
+		pArgs := putArgs(proto.Key(fmt.Sprintf("a%d", i)), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = bArgs.Timestamp
+		bArgs.Add(&pArgs)
+	}
+	size := int64(bArgs.Size())
 
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
-		if err != nil {
-			t.Error(err)
+	testCases := []struct {
+		maxRequests int
+		maxBytes    int64
+		expErr      bool
+	}{
+		{3, size, true},
+		{4, size - 1, true},
+		{4, size, false},
+	}
+	for i, test := range testCases {
+		tc.store.ctx.MaxBatchRequests = test.maxRequests
+		tc.store.ctx.MaxBatchBytes = test.maxBytes
+		if !test.expErr {
+			if err := tc.rng.checkBatchSize(bArgs); err != nil {
+				t.Errorf("%d: unexpected error: %s", i, err)
+			}
+			continue
 		}
-		reply := resp.(*proto.EndTransactionResponse)
-		expStatus := proto.COMMITTED
-		if !commit {
-			expStatus = proto.ABORTED
+		_, err := tc.rng.AddCmd(tc.rng.context(), bArgs)
+		bErr, ok := err.(*proto.BatchTooLargeError)
+		if !ok {
+			t.Errorf("%d: expected BatchTooLargeError; got %v", i, err)
+			continue
 		}
-		if reply.Txn.Status != expStatus {
-			t.Errorf("expected transaction status to be %s; got %s", expStatus, reply.Txn.Status)
+		expErr := proto.BatchTooLargeError{
+			RequestCount:    4,
+			RequestBytes:    size,
+			MaxRequestCount: int64(test.maxRequests),
+			MaxRequestBytes: test.maxBytes,
 		}
+		if *bErr != expErr {
+			t.Errorf("%d: expected %+v; got %+v", i, expErr, *bErr)
+		}
+	}
 
-		// Try a heartbeat to the already-committed transaction; should get
-		// committed txn back, but without last heartbeat timestamp set.
-		hbArgs := heartbeatArgs(txn, 1, tc.store.StoreID())
-
-		resp, err = tc.rng.AddCmd(tc.rng.context(), &hbArgs)
+	// None of the puts of the rejected batches was applied.
+	for i := 0; i < 4; i++ {
+		gArgs := getArgs(proto.Key(fmt.Sprintf("a%d", i)), 1, tc.store.StoreID())
+		gArgs.Timestamp = tc.clock.Now()
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		hbReply := resp.(*proto.HeartbeatTxnResponse)
-		if hbReply.Txn.Status != expStatus || hbReply.Txn.LastHeartbeat != nil {
-			t.Errorf("unexpected heartbeat reply contents: %+v", hbReply)
+		if v := reply.(*proto.GetResponse).Value; v != nil {
+			t.Errorf("expected put %d of the rejected batch not to be applied; got %+v", i, v)
 		}
 	}
 }
 
-// TestEndTransactionAfterHeartbeat verifies that a transaction
-// can be committed/aborted after being heartbeat.
-func TestEndTransactionAfterHeartbeat(t *testing.T) {
+// TestRangeProposeAfterDescriptorChange verifies that a command whose
+// keys leave the range after it was admitted to the command queue is not
+// proposed, and fails with a retryable RangeKeyMismatchError instead.
+func TestRangeProposeAfterDescriptorChange(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	key := []byte("a")
-	for _, commit := range []bool{true, false} {
-		txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-
-		// Start out with a heartbeat to the transaction.
-		hbArgs := heartbeatArgs(txn, 1, tc.store.StoreID())
-		hbArgs.Timestamp = txn.Timestamp
-
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs)
-		if err != nil {
-			t.Error(err)
-		}
-		hbReply := resp.(*proto.HeartbeatTxnResponse)
-		if hbReply.Txn.Status != proto.PENDING || hbReply.Txn.LastHeartbeat == nil {
-			t.Errorf("unexpected heartbeat reply contents: %+v", hbReply)
-		}
+	pArgs := putArgs(proto.Key("m"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	cmdKey, err := tc.rng.beginCmd(&pArgs.RequestHeader, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		args := endTxnArgs(txn, commit, 1, tc.store.StoreID())
-		args.Timestamp = txn.Timestamp
+	// Shrink the range as a concurrent split would have.
+	origDesc := tc.rng.Desc()
+	desc := *origDesc
+	desc.EndKey = proto.Key("m")
+	tc.rng.setDescWithoutProcessUpdate(&desc)
 
-		resp, err = tc.rng.AddCmd(tc.rng.context(), &args)
-		if err != nil {
-			t.Error(err)
-		}
-		reply := resp.(*proto.EndTransactionResponse)
-		expStatus := proto.COMMITTED
-		if !commit {
-			expStatus = proto.ABORTED
-		}
-		if reply.Txn.Status != expStatus {
-			t.Errorf("expected transaction status to be %s; got %s", expStatus, reply.Txn.Status)
-		}
-		if reply.Txn.LastHeartbeat == nil || !reply.Txn.LastHeartbeat.Equal(*hbReply.Txn.LastHeartbeat) {
-			t.Errorf("expected heartbeats to remain equal: %+v != %+v",
-				reply.Txn.LastHeartbeat, hbReply.Txn.LastHeartbeat)
-		}
+	errChan, _ := tc.rng.proposeRaftCommand(tc.rng.context(), &pArgs)
+	err = <-errChan
+	tc.rng.endCmd(cmdKey, &pArgs, err, false)
+	tc.rng.setDescWithoutProcessUpdate(origDesc)
+	if mErr, ok := err.(*proto.RangeKeyMismatchError); !ok {
+		t.Fatalf("expected range key mismatch error; got %v", err)
+	} else if !mErr.CanRetry() {
+		t.Errorf("expected error to be retryable")
+	}
+	tc.rng.Lock()
+	pending := len(tc.rng.pendingCmds)
+	tc.rng.Unlock()
+	if pending != 0 {
+		t.Errorf("expected no pending commands; got %d", pending)
 	}
 }
 
-// TestEndTransactionWithPushedTimestamp verifies that txn can be
-// ended (both commit or abort) correctly when the commit timestamp is
-// greater than the transaction timestamp, depending on the isolation
-// level.
-func TestEndTransactionWithPushedTimestamp(t *testing.T) {
+// TestRangeActiveCommandSpans verifies that a command held in the
+// command queue is reported among the active command spans, and that
+// it doesn't block commands on a disjoint span.
+func TestRangeActiveCommandSpans(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	testCases := []struct {
-		commit    bool
-		isolation proto.IsolationType
-		expErr    bool
-	}{
-		{true, proto.SERIALIZABLE, true},
-		{true, proto.SNAPSHOT, false},
-		{false, proto.SERIALIZABLE, false},
-		{false, proto.SNAPSHOT, false},
+	if spans := tc.rng.ActiveCommandSpans(); len(spans) != 0 {
+		t.Fatalf("expected no active command spans; got %v", spans)
 	}
-	key := []byte("a")
-	for _, test := range testCases {
-		txn := newTransaction("test", key, 1, test.isolation, tc.clock)
-		// End the transaction with args timestamp moved forward in time.
-		args := endTxnArgs(txn, test.commit, 1, tc.store.StoreID())
-		tc.manualClock.Set(1)
-		args.Timestamp = tc.clock.Now()
 
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	dArgs := proto.DeleteRangeRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       proto.Key("a"),
+			EndKey:    proto.Key("c"),
+			RangeID:   1,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Timestamp: tc.clock.Now(),
+		},
+	}
+	cmdKey, err := tc.rng.beginCmd(&dArgs.RequestHeader, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expSpans := []keys.Span{{Start: proto.Key("a"), End: proto.Key("c")}}
+	if spans := tc.rng.ActiveCommandSpans(); !reflect.DeepEqual(spans, expSpans) {
+		t.Errorf("expected active command spans %v; got %v", expSpans, spans)
+	}
 
-		if test.expErr {
-			if err == nil {
-				t.Errorf("expected error")
-			}
-			if _, ok := err.(*proto.TransactionRetryError); !ok {
-				t.Errorf("expected retry error; got %s", err)
-			}
-		} else {
-			if err != nil {
-				t.Errorf("unexpected error: %s", err)
-			}
-			expStatus := proto.COMMITTED
-			if !test.commit {
-				expStatus = proto.ABORTED
-			}
-			reply := resp.(*proto.EndTransactionResponse)
-			if reply.Txn.Status != expStatus {
-				t.Errorf("expected transaction status to be %s; got %s", expStatus, reply.Txn.Status)
-			}
+	// A write to a disjoint key isn't blocked.
+	done := make(chan error, 1)
+	go func() {
+		pArgs := putArgs(proto.Key("x"), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		_, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
 		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write to a disjoint span was blocked")
+	}
+
+	tc.rng.endCmd(cmdKey, &dArgs, nil, false)
+	if spans := tc.rng.ActiveCommandSpans(); len(spans) != 0 {
+		t.Errorf("expected no active command spans; got %v", spans)
 	}
 }
 
-// TestEndTransactionWithIncrementedEpoch verifies that txn ended with
-// a higher epoch (and priority) correctly assumes the higher epoch.
-func TestEndTransactionWithIncrementedEpoch(t *testing.T) {
+// TestRangeProposalQuota verifies that proposals fail with a retryable
+// error once a replica has the maximum number of proposals in flight,
+// without affecting other replicas, and succeed again once the
+// proposals in flight have been applied or abandoned.
+func TestRangeProposalQuota(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	key := []byte("a")
-	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-
-	// Start out with a heartbeat to the transaction.
-	hbArgs := heartbeatArgs(txn, 1, tc.store.StoreID())
-	hbArgs.Timestamp = txn.Timestamp
+	otherRng := splitTestRange(tc.store, proto.KeyMin, proto.Key("m"), t)
 
-	_, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs)
-	if err != nil {
-		t.Error(err)
+	// Occupy the only slot with a proposal which is never applied.
+	tc.store.ctx.MaxInflightProposals = 1
+	idKey := makeCmdIDKey(proto.ClientCmdID{WallTime: 1, Random: 1})
+	cmd := &pendingCmd{
+		ctx:   context.Background(),
+		done:  make(chan proto.ResponseWithError, 1),
+		idKey: idKey,
 	}
+	tc.rng.Lock()
+	tc.rng.pendingCmds[idKey] = cmd
+	tc.rng.Unlock()
 
-	// Now end the txn with increased epoch and priority.
-	args := endTxnArgs(txn, true, 1, tc.store.StoreID())
-	args.Timestamp = txn.Timestamp
-	args.Txn.Epoch = txn.Epoch + 1
-	args.Txn.Priority = txn.Priority + 1
-
-	resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
-	if err != nil {
-		t.Error(err)
+	pArgs := putArgs(proto.Key("a"), []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	_, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if qErr, ok := err.(*ProposalQuotaError); !ok {
+		t.Fatalf("expected ProposalQuotaError; got %v", err)
+	} else if !qErr.CanRetry() || qErr.MaxInflight != 1 {
+		t.Errorf("unexpected error contents: %+v", qErr)
 	}
-	reply := resp.(*proto.EndTransactionResponse)
-	if reply.Txn.Status != proto.COMMITTED {
-		t.Errorf("expected transaction status to be COMMITTED; got %s", reply.Txn.Status)
+
+	// The other replica is unaffected.
+	pArgs = putArgs(proto.Key("n"), []byte("value"), otherRng.Desc().RangeID, tc.store.StoreID())
+	if _, err := otherRng.AddCmd(otherRng.context(), &pArgs); err != nil {
+		t.Fatal(err)
 	}
-	if reply.Txn.Epoch != txn.Epoch {
-		t.Errorf("expected epoch to equal %d; got %d", txn.Epoch, reply.Txn.Epoch)
+
+	// Abandoning another command with the same ID doesn't free the slot.
+	tc.rng.abandonRaftCommand(&pendingCmd{idKey: idKey})
+	tc.rng.Lock()
+	n := len(tc.rng.pendingCmds)
+	tc.rng.Unlock()
+	if n != 1 {
+		t.Fatalf("expected the proposal to remain in flight; got %d pending commands", n)
 	}
-	if reply.Txn.Priority != txn.Priority {
-		t.Errorf("expected priority to equal %d; got %d", txn.Priority, reply.Txn.Priority)
+
+	// Once the proposal is abandoned, as after Raft aborts it, proposals
+	// succeed again.
+	tc.rng.abandonRaftCommand(cmd)
+	pArgs = putArgs(proto.Key("a"), []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
 	}
 }
 
-// TestEndTransactionWithErrors verifies various error conditions
-// are checked such as transaction already being committed or
-// aborted, or timestamp or epoch regression.
-func TestEndTransactionWithErrors(t *testing.T) {
+// TestRangeScanIntents writes intents of varying ages and verifies
+// that ScanIntents reports only those older than the requested age and
+// leaves them in place.
+func TestRangeScanIntents(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	regressTS := tc.clock.Now()
-	tc.manualClock.Set(1)
-	txn := newTransaction("test", proto.Key(""), 1, proto.SERIALIZABLE, tc.clock)
-
-	testCases := []struct {
-		key          proto.Key
-		existStatus  proto.TransactionStatus
-		existEpoch   int32
-		existTS      proto.Timestamp
-		expErrRegexp string
-	}{
-		{proto.Key("a"), proto.COMMITTED, txn.Epoch, txn.Timestamp, "txn \"test\" id=.*: already committed"},
-		{proto.Key("b"), proto.ABORTED, txn.Epoch, txn.Timestamp, "txn aborted \"test\" id=.*"},
-		{proto.Key("c"), proto.PENDING, txn.Epoch + 1, txn.Timestamp, "txn \"test\" id=.*: epoch regression: 0"},
-		{proto.Key("d"), proto.PENDING, txn.Epoch, regressTS, "txn \"test\" id=.*: timestamp regression: 0.000000001,0"},
-	}
-	for _, test := range testCases {
-		// Establish existing txn state by writing directly to range engine.
-		var existTxn proto.Transaction
-		gogoproto.Merge(&existTxn, txn)
-		existTxn.Key = test.key
-		existTxn.Status = test.existStatus
-		existTxn.Epoch = test.existEpoch
-		existTxn.Timestamp = test.existTS
-		txnKey := keys.TransactionKey(test.key, txn.ID)
-		if err := engine.MVCCPutProto(tc.rng.rm.Engine(), nil, txnKey, proto.ZeroTimestamp,
-			nil, &existTxn); err != nil {
+	// Lay down intents written 30s, 20s and 5s ago.
+	ages := []time.Duration{30 * time.Second, 20 * time.Second, 5 * time.Second}
+	start := tc.manualClock.UnixNano()
+	var txns []*proto.Transaction
+	for i, age := range ages {
+		tc.manualClock.Set(start + (ages[0] - age).Nanoseconds())
+		key := proto.Key(fmt.Sprintf("key-%d", i))
+		pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pArgs.Timestamp = pArgs.Txn.Timestamp
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
 			t.Fatal(err)
 		}
-
-		// End the transaction, verify expected error.
-		txn.Key = test.key
-		args := endTxnArgs(txn, true, 1, tc.store.StoreID())
-		args.Timestamp = txn.Timestamp
-
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); !testutils.IsError(err, test.expErrRegexp) {
-			t.Errorf("expected %s to match %s", err, test.expErrRegexp)
-		}
+		txns = append(txns, pArgs.Txn)
 	}
-}
-
-// TestEndTransactionGC verifies that a transaction record is immediately
-// garbage-collected upon EndTransaction iff all of the supplied intents are
-// local relative to the transaction record's location.
-func TestEndTransactionGC(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	tc := testContext{}
-	tc.Start(t)
-	defer tc.Stop()
+	tc.manualClock.Set(start + ages[0].Nanoseconds())
 
-	splitKey := proto.Key("c")
-	splitTestRange(tc.store, splitKey, splitKey, t)
-	for i, test := range []struct {
-		intents []proto.Intent
-		expGC   bool
+	for _, test := range []struct {
+		maxAge  time.Duration
+		expKeys []proto.Key
 	}{
-		// Range inside.
-		{[]proto.Intent{{Key: proto.Key("a"), EndKey: proto.Key("b")}}, true},
-		// Two intents inside.
-		{[]proto.Intent{{Key: proto.Key("a")}, {Key: proto.Key("b")}}, true},
-		// Intent range spilling over right endpoint.
-		{[]proto.Intent{{Key: proto.Key("a"), EndKey: splitKey.Next()}}, false},
-		// Intent range completely outside.
-		{[]proto.Intent{{Key: splitKey, EndKey: proto.Key("q")}}, false},
-		// Intent inside and outside.
-		{[]proto.Intent{{Key: proto.Key("a")}, {Key: splitKey}}, false},
+		{time.Minute, nil},
+		{10 * time.Second, []proto.Key{proto.Key("key-0"), proto.Key("key-1")}},
+		{time.Second, []proto.Key{proto.Key("key-0"), proto.Key("key-1"), proto.Key("key-2")}},
 	} {
-		txn := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
-		args := endTxnArgs(txn, true, 1, tc.store.StoreID())
-		args.Timestamp = txn.Timestamp
-		args.Intents = test.intents
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+		intents, err := tc.rng.ScanIntents(test.maxAge)
+		if err != nil {
 			t.Fatal(err)
 		}
-		var readTxn proto.Transaction
-		txnKey := keys.TransactionKey(txn.Key, txn.ID)
-		ok, err := engine.MVCCGetProto(tc.rng.rm.Engine(), txnKey, proto.ZeroTimestamp,
-			true /* consistent */, nil /* txn */, &readTxn)
+		if len(intents) != len(test.expKeys) {
+			t.Fatalf("max age %s: expected %d intents; got %+v", test.maxAge, len(test.expKeys), intents)
+		}
+		for i, intent := range intents {
+			if !intent.Key.Equal(test.expKeys[i]) || !bytes.Equal(intent.Txn.ID, txns[i].ID) {
+				t.Errorf("max age %s: expected intent on %q by txn %q; got %+v", test.maxAge, test.expKeys[i], txns[i].ID, intent)
+			}
+		}
+	}
+
+	// Scanning must not have resolved anything.
+	for _, txn := range txns {
+		_, intents, err := engine.MVCCGet(tc.engine, txn.Key, tc.clock.Now(), false, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !ok != test.expGC {
-			t.Errorf("%d: unexpected gc'ed: %t", i, !ok)
+		if len(intents) != 1 {
+			t.Errorf("expected intent on %q to remain after scan; got %+v", txn.Key, intents)
 		}
 	}
 }
 
-// TestEndTransactionResolveOnlyLocalIntents verifies that an end transaction
-// request resolves only local intents.
-func TestEndTransactionResolveOnlyLocalIntents(t *testing.T) {
+// TestRangeMaxValueBytes verifies that puts of values larger than the
+// range's maximum value size are rejected, except for system keys.
+func TestRangeMaxValueBytes(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Split the range and create an intent in each range.
-	// The keys of the two intents are next to each other.
-	key := proto.Key("a")
-	splitKey := key.Next()
-	newRng := splitTestRange(tc.store, splitKey, splitKey, t)
+	if max := tc.rng.GetMaxValueBytes(); max != DefaultMaxValueBytes {
+		t.Errorf("expected default max value bytes %d; got %d", DefaultMaxValueBytes, max)
+	}
+	const maxBytes = 100
+	tc.rng.SetMaxValueBytes(maxBytes)
 
-	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-	pArgs := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
-	pArgs.Txn = txn
+	key := proto.Key("a")
+	pArgs := putArgs(key, make([]byte, maxBytes), 1, tc.store.StoreID())
 	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
 		t.Fatal(err)
 	}
-
-	pArgs = putArgs(splitKey, []byte("value"), newRng.Desc().RangeID, tc.store.StoreID())
-	pArgs.Txn = txn
-	if _, err := newRng.AddCmd(newRng.context(), &pArgs); err != nil {
-		t.Fatal(err)
+	cpArgs := cPutArgs(key, make([]byte, maxBytes+1), make([]byte, maxBytes), 1, tc.store.StoreID())
+	pArgs = putArgs(key, make([]byte, maxBytes+1), 1, tc.store.StoreID())
+	for _, args := range []proto.Request{&pArgs, &cpArgs} {
+		_, err := tc.rng.AddCmd(tc.rng.context(), args)
+		if vErr, ok := err.(*proto.ValueTooLargeError); !ok {
+			t.Errorf("%s: expected value too large error; got %v", args.Method(), err)
+		} else if !vErr.Key.Equal(key) || vErr.ValueBytes != maxBytes+1 || vErr.MaxBytes != maxBytes {
+			t.Errorf("%s: unexpected error contents %+v", args.Method(), vErr)
+		}
 	}
 
-	// End the transaction and resolve the intents.
-	args := endTxnArgs(txn, true /* commit */, 1, tc.store.StoreID())
-	args.Timestamp = txn.Timestamp
-	args.Intents = []proto.Intent{{Key: key, EndKey: splitKey.Next()}}
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+	// System keys are exempt.
+	pArgs = putArgs(keys.MakeKey(keys.SystemPrefix, proto.Key("a")), make([]byte, maxBytes+1), 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
 		t.Fatal(err)
 	}
-
-	// Check if the intent in the other range has not yet been resolved.
-	gArgs := getArgs(splitKey, newRng.Desc().RangeID, tc.store.StoreID())
-	_, err := newRng.AddCmd(newRng.context(), &gArgs)
-	if _, ok := err.(*proto.WriteIntentError); !ok {
-		t.Errorf("expected write intent error, but got %s", err)
-	}
 }
 
-// TestPushTxnBadKey verifies that args.Key equals args.PusheeTxn.ID.
-func TestPushTxnBadKey(t *testing.T) {
+// TestRangeEmptyBatch verifies that an empty batch is answered with an
+// empty response carrying a timestamp, without requesting the leader
+// lease or entering the command queue.
+func TestRangeEmptyBatch(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	pusher := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
-	pushee := newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
-
-	args := pushTxnArgs(pusher, pushee, proto.ABORT_TXN, 1, tc.store.StoreID())
-	args.Key = pusher.Key
+	// Another replica holds the lease, and a command is executing on
+	// the whole range.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
+	tc.rng.Lock()
+	cmdKey := tc.rng.cmdQ.Add(proto.KeyMin, proto.KeyMax, false, 0)
+	tc.rng.Unlock()
+	defer func() {
+		tc.rng.Lock()
+		tc.rng.cmdQ.Remove(cmdKey)
+		tc.rng.Unlock()
+	}()
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); !testutils.IsError(err, ".*should match pushee.*") {
-		t.Errorf("unexpected error %s", err)
+	for i, ts := range []proto.Timestamp{now, proto.ZeroTimestamp} {
+		bArgs := &proto.BatchRequest{}
+		bArgs.RangeID = tc.rng.Desc().RangeID
+		bArgs.Timestamp = ts
+		reply, err := tc.rng.AddCmd(tc.rng.context(), bArgs)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		bReply, ok := reply.(*proto.BatchResponse)
+		if !ok || bReply == nil {
+			t.Fatalf("%d: expected a batch response, got %+v", i, reply)
+		}
+		if len(bReply.Responses) != 0 {
+			t.Errorf("%d: expected no responses, got %+v", i, bReply.Responses)
+		}
+		if bReply.Timestamp.Equal(proto.ZeroTimestamp) {
+			t.Errorf("%d: expected response timestamp to be set", i)
+		} else if !ts.Equal(proto.ZeroTimestamp) && !bReply.Timestamp.Equal(ts) {
+			t.Errorf("%d: expected response timestamp %s, got %s", i, ts, bReply.Timestamp)
+		}
 	}
 }
 
-// TestPushTxnAlreadyCommittedOrAborted verifies success
-// (noop) in event that pushee is already committed or aborted.
-func TestPushTxnAlreadyCommittedOrAborted(t *testing.T) {
+func TestRangeNotLeaderError(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	// This test simulates running into an open intent and resolving it
-	// using the Txn table. If we auto-gc'ed entries here, the entry would
-	// be deleted and the intents resolved instantaneously on successful
-	// commit (since they're on the same Range). Could split the range and have
-	// non-local intents if we ever wanted to get rid of this.
-	defer withoutTxnAutoGC()()
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	for i, status := range []proto.TransactionStatus{proto.COMMITTED, proto.ABORTED} {
-		key := proto.Key(fmt.Sprintf("key-%d", i))
-		pusher := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pushee := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pusher.Priority = 1
-		pushee.Priority = 2 // pusher will lose, meaning we shouldn't push unless pushee is already ended.
-
-		// End the pushee's transaction.
-		etArgs := endTxnArgs(pushee, status == proto.COMMITTED, 1, tc.store.StoreID())
-		etArgs.Timestamp = pushee.Timestamp
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
 
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &etArgs); err != nil {
-			t.Fatal(err)
-		}
+	header := proto.RequestHeader{
+		Key:       proto.Key("a"),
+		RangeID:   tc.rng.Desc().RangeID,
+		Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+		Timestamp: now,
+	}
+	testCases := []proto.Request{
+		// Admin split covers admin commands.
+		&proto.AdminSplitRequest{
+			RequestHeader: header,
+			SplitKey:      proto.Key("a"),
+		},
+		// Get covers read-only commands.
+		&proto.GetRequest{
+			RequestHeader: header,
+		},
+		// Put covers read-write commands.
+		&proto.PutRequest{
+			RequestHeader: header,
+			Value: proto.Value{
+				Bytes: []byte("value"),
+			},
+		},
+	}
 
-		// Now try to push what's already committed or aborted.
-		args := pushTxnArgs(pusher, pushee, proto.ABORT_TXN, 1, tc.store.StoreID())
+	for i, test := range testCases {
+		_, err := tc.rng.AddCmd(tc.rng.context(), test)
 
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
-		if err != nil {
-			t.Fatal(err)
-		}
-		reply := resp.(*proto.PushTxnResponse)
-		if reply.PusheeTxn.Status != status {
-			t.Errorf("expected push txn to return with status == %s; got %+v", status, reply.PusheeTxn)
+		if _, ok := err.(*proto.NotLeaderError); !ok {
+			t.Errorf("%d: expected not leader error: %s", i, err)
 		}
 	}
 }
 
-// TestPushTxnUpgradeExistingTxn verifies that pushing
-// a transaction record with a new epoch upgrades the pushee's
-// epoch and timestamp if greater. In all test cases, the
-// priorities are set such that the push will succeed.
-func TestPushTxnUpgradeExistingTxn(t *testing.T) {
+// TestRangeGossipConfigsOnLease verifies that config info is gossiped
+// upon acquisition of the leader lease.
+func TestRangeGossipConfigsOnLease(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	ts1 := proto.Timestamp{WallTime: 1}
-	ts2 := proto.Timestamp{WallTime: 2}
-	testCases := []struct {
-		startEpoch, epoch, expEpoch int32
-		startTS, ts, expTS          proto.Timestamp
-	}{
-		// Move epoch forward.
-		{0, 1, 1, ts1, ts1, ts1},
-		// Move timestamp forward.
-		{0, 0, 0, ts1, ts2, ts2},
-		// Move epoch backwards (has no effect).
-		{1, 0, 1, ts1, ts1, ts1},
-		// Move timestamp backwards (has no effect).
-		{0, 0, 0, ts2, ts1, ts2},
-		// Move both epoch & timestamp forward.
-		{0, 1, 1, ts1, ts2, ts2},
-		// Move both epoch & timestamp backward (has no effect).
-		{1, 0, 1, ts2, ts1, ts2},
+	// Add a zone config for a new key prefix.
+	db1Zone := config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{Attrs: []string{"dc1", "ssd"}},
+			{Attrs: []string{"dc2", "ssd"}},
+		},
+	}
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
+	if err := engine.MVCCPutProto(tc.engine, nil, key, proto.MinTimestamp, nil, &db1Zone); err != nil {
+		t.Fatal(err)
 	}
 
-	for i, test := range testCases {
-		key := proto.Key(fmt.Sprintf("key-%d", i))
-		pusher := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pushee := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pushee.Priority = 1
-		pusher.Priority = 2 // Pusher will win.
-
-		// First, establish "start" of existing pushee's txn via heartbeat.
-		pushee.Epoch = test.startEpoch
-		pushee.Timestamp = test.startTS
-		hbArgs := heartbeatArgs(pushee, 1, tc.store.StoreID())
-		hbArgs.Timestamp = pushee.Timestamp
-
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs); err != nil {
+	verifyZone := func() bool {
+		configMap, err := tc.gossip.GetZoneConfig()
+		if err != nil {
 			t.Fatal(err)
 		}
+		expConfigs := []config.PrefixConfig{
+			config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
+			config.MakePrefixConfig(proto.Key("/db1"), nil, &db1Zone),
+			config.MakePrefixConfig(proto.Key("/db2"), proto.KeyMin, &testDefaultZoneConfig),
+		}
+		return reflect.DeepEqual(configMap.Configs, expConfigs)
+	}
 
-		// Now, attempt to push the transaction using updated values for epoch & timestamp.
-		pushee.Epoch = test.epoch
-		pushee.Timestamp = test.ts
-		args := pushTxnArgs(pusher, pushee, proto.ABORT_TXN, 1, tc.store.StoreID())
+	// If this actually failed, we would have gossiped from MVCCPutProto.
+	// Unlikely, but why not check.
+	if verifyZone() {
+		t.Errorf("not expecting gossip of new config until new lease is acquired")
+	}
 
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
-		if err != nil {
-			t.Fatal(err)
-		}
-		reply := resp.(*proto.PushTxnResponse)
-		expTxn := gogoproto.Clone(pushee).(*proto.Transaction)
-		expTxn.Epoch = test.expEpoch
-		expTxn.Timestamp = test.expTS
-		expTxn.Status = proto.ABORTED
-		expTxn.LastHeartbeat = &test.startTS
-		expTxn.Writing = true // always set for physical txn table entries
+	// Expire our own lease which we automagically acquired due to being
+	// first range and config holder.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
 
-		if !reflect.DeepEqual(expTxn, reply.PusheeTxn) {
-			t.Errorf("unexpected push txn in trial %d; expected %+v, got %+v", i, expTxn, reply.PusheeTxn)
-		}
+	// Give lease to someone else.
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
+
+	// Expire that lease.
+	tc.manualClock.Increment(11 + int64(tc.clock.MaxOffset())) // advance time
+	now = tc.clock.Now()
+
+	// Give lease to this range.
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now.Add(11, 0),
+		Expiration: now.Add(20, 0),
+		RaftNodeID: tc.store.RaftNodeID(),
+	})
+	if !verifyZone() {
+		t.Errorf("expected gossip of new config")
 	}
 }
 
-// TestPushTxnHeartbeatTimeout verifies that a txn which
-// hasn't been heartbeat within 2x the heartbeat interval can be
-// pushed/aborted.
-func TestPushTxnHeartbeatTimeout(t *testing.T) {
+// TestRangeTSCacheLowWaterOnLease verifies that the low water mark is
+// set on the timestamp cache when the node is granted the leader
+// lease after not holding it and it is not set when the node is
+// granted the leader lease when it was the last holder.
+func TestRangeTSCacheLowWaterOnLease(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
+	tc.clock.SetMaxOffset(maxClockOffset)
+
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := proto.Timestamp{WallTime: tc.manualClock.UnixNano()}
+
+	baseRTS, _ := tc.rng.tsCache.GetMax(proto.Key("a"), nil /* end */, nil /* txn */)
+	baseLowWater := baseRTS.WallTime
 
-	ts := proto.Timestamp{WallTime: 1}
-	ns := DefaultHeartbeatInterval.Nanoseconds()
 	testCases := []struct {
-		heartbeat   *proto.Timestamp // nil indicates no heartbeat
-		currentTime int64            // nanoseconds
-		pushType    proto.PushTxnType
-		expSuccess  bool
+		nodeID      proto.RaftNodeID
+		start       proto.Timestamp
+		expiration  proto.Timestamp
+		expLowWater int64
 	}{
-		{nil, 1, proto.PUSH_TIMESTAMP, false}, // using 0 as time is awkward
-		{nil, 1, proto.ABORT_TXN, false},
-		{nil, 1, proto.CLEANUP_TXN, false},
-		{nil, ns, proto.PUSH_TIMESTAMP, false},
-		{nil, ns, proto.ABORT_TXN, false},
-		{nil, ns, proto.CLEANUP_TXN, false},
-		{nil, ns*2 - 1, proto.PUSH_TIMESTAMP, false},
-		{nil, ns*2 - 1, proto.ABORT_TXN, false},
-		{nil, ns*2 - 1, proto.CLEANUP_TXN, false},
-		{nil, ns * 2, proto.PUSH_TIMESTAMP, false},
-		{nil, ns * 2, proto.ABORT_TXN, false},
-		{nil, ns * 2, proto.CLEANUP_TXN, false},
-		{&ts, ns*2 + 1, proto.PUSH_TIMESTAMP, false},
-		{&ts, ns*2 + 1, proto.ABORT_TXN, false},
-		{&ts, ns*2 + 1, proto.CLEANUP_TXN, false},
-		{&ts, ns*2 + 2, proto.PUSH_TIMESTAMP, true},
-		{&ts, ns*2 + 2, proto.ABORT_TXN, true},
-		{&ts, ns*2 + 2, proto.CLEANUP_TXN, true},
+		// Grant the lease fresh.
+		{tc.store.RaftNodeID(), now, now.Add(10, 0), baseLowWater},
+		// Renew the lease.
+		{tc.store.RaftNodeID(), now.Add(15, 0), now.Add(30, 0), baseLowWater},
+		// Renew the lease but shorten expiration.
+		{tc.store.RaftNodeID(), now.Add(16, 0), now.Add(25, 0), baseLowWater},
+		// Lease is held by another.
+		{proto.MakeRaftNodeID(2, 2), now.Add(29, 0), now.Add(50, 0), baseLowWater},
+		// Lease is regranted to this replica.
+		{tc.store.RaftNodeID(), now.Add(60, 0), now.Add(70, 0), now.Add(50, 0).WallTime + int64(maxClockOffset) + baseLowWater},
 	}
 
 	for i, test := range testCases {
-		key := proto.Key(fmt.Sprintf("key-%d", i))
-		pushee := newTransaction(fmt.Sprintf("test-%d", i), key, 1, proto.SERIALIZABLE, tc.clock)
-		pusher := newTransaction("pusher", key, 1, proto.SERIALIZABLE, tc.clock)
-		pushee.Priority = 2
-		pusher.Priority = 1 // Pusher won't win based on priority.
-
-		// First, establish "start" of existing pushee's txn via heartbeat.
-		if test.heartbeat != nil {
-			hbArgs := heartbeatArgs(pushee, 1, tc.store.StoreID())
-			hbArgs.Timestamp = *test.heartbeat
-
-			if _, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs); err != nil {
-				t.Fatal(err)
-			}
-		}
-
-		// Now, attempt to push the transaction with clock set to "currentTime".
-		tc.manualClock.Set(test.currentTime)
-		args := pushTxnArgs(pusher, pushee, test.pushType, 1, tc.store.StoreID())
-		// Avoid logical ticks here, they make the borderline cases hard to
-		// test.
-		args.Timestamp = proto.Timestamp{WallTime: test.currentTime}
-		args.Now = args.Timestamp
-		args.Timestamp.Logical = 0
-
-		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
-
-		if test.expSuccess != (err == nil) {
-			t.Errorf("expected success on trial %d? %t; got err %s", i, test.expSuccess, err)
-		}
-		if err != nil {
-			if _, ok := err.(*proto.TransactionPushError); !ok {
-				t.Errorf("expected txn push error: %s", err)
-			}
+		setLeaderLease(t, tc.rng, &proto.Lease{
+			Start:      test.start,
+			Expiration: test.expiration,
+			RaftNodeID: test.nodeID,
+		})
+		// Verify expected low water mark.
+		rTS, wTS := tc.rng.tsCache.GetMax(proto.Key("a"), nil, nil)
+		if rTS.WallTime != test.expLowWater || wTS.WallTime != test.expLowWater {
+			t.Errorf("%d: expected low water %d; got %d, %d", i, test.expLowWater, rTS.WallTime, wTS.WallTime)
 		}
 	}
 }
 
-// TestPushTxnPriorities verifies that txns with lower
-// priority are pushed; if priorities are equal, then the txns
-// are ordered by txn timestamp, with the more recent timestamp
-// being pushable.
-func TestPushTxnPriorities(t *testing.T) {
+// TestRangeReplicaAddresses verifies that the replicas of a range are
+// joined with the gossiped addresses of their nodes, and that replicas
+// whose node address isn't known are returned without an address.
+func TestRangeReplicaAddresses(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	ts1 := proto.Timestamp{WallTime: 1}
-	ts2 := proto.Timestamp{WallTime: 2}
-	testCases := []struct {
-		pusherPriority, pusheePriority int32
-		pusherTS, pusheeTS             proto.Timestamp
-		pushType                       proto.PushTxnType
-		expSuccess                     bool
-	}{
-		// Pusher has higher priority succeeds.
-		{2, 1, ts1, ts1, proto.ABORT_TXN, true},
-		// Pusher has lower priority fails.
-		{1, 2, ts1, ts1, proto.ABORT_TXN, false},
-		{1, 2, ts1, ts1, proto.PUSH_TIMESTAMP, false},
-		// Pusher has lower priority fails, even with older txn timestamp.
-		{1, 2, ts1, ts2, proto.ABORT_TXN, false},
-		// Pusher has lower priority, but older txn timestamp allows success if !abort.
-		{1, 2, ts1, ts2, proto.PUSH_TIMESTAMP, true},
-		// With same priorities, older txn timestamp succeeds.
-		{1, 1, ts1, ts2, proto.ABORT_TXN, true},
-		// With same priorities, same txn timestamp fails.
-		{1, 1, ts1, ts1, proto.ABORT_TXN, false},
-		{1, 1, ts1, ts1, proto.PUSH_TIMESTAMP, false},
-		// With same priorities, newer txn timestamp fails.
-		{1, 1, ts2, ts1, proto.ABORT_TXN, false},
-		{1, 1, ts2, ts1, proto.PUSH_TIMESTAMP, false},
-		// When confirming, priority never wins.
-		{2, 1, ts1, ts1, proto.CLEANUP_TXN, false},
-		{1, 2, ts1, ts1, proto.CLEANUP_TXN, false},
+	addr := util.MakeUnresolvedAddr("tcp", "node1:26257")
+	if err := tc.gossip.SetNodeDescriptor(&proto.NodeDescriptor{NodeID: 1, Address: addr}); err != nil {
+		t.Fatal(err)
 	}
-
-	for i, test := range testCases {
-		key := proto.Key(fmt.Sprintf("key-%d", i))
-		pusher := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pushee := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-		pusher.Priority = test.pusherPriority
-		pushee.Priority = test.pusheePriority
-		pusher.Timestamp = test.pusherTS
-		pushee.Timestamp = test.pusheeTS
-
-		// Now, attempt to push the transaction with intent epoch set appropriately.
-		args := pushTxnArgs(pusher, pushee, test.pushType, 1, tc.store.StoreID())
-
-		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
-
-		if test.expSuccess != (err == nil) {
-			t.Errorf("expected success on trial %d? %t; got err %s", i, test.expSuccess, err)
-		}
-		if err != nil {
-			if _, ok := err.(*proto.TransactionPushError); !ok {
-				t.Errorf("expected txn push error: %s", err)
-			}
-		}
+	desc := &proto.RangeDescriptor{
+		RangeID:  2,
+		StartKey: proto.Key("a"),
+		EndKey:   proto.Key("b"),
+		Replicas: []proto.Replica{
+			{NodeID: 1, StoreID: 1, ReplicaID: 1},
+			{NodeID: 2, StoreID: 2, ReplicaID: 2},
+		},
 	}
-}
-
-// TestPushTxnPushTimestamp verifies that with args.Abort is
-// false (i.e. for read/write conflict), the pushed txn keeps status
-// PENDING, but has its txn Timestamp moved forward to the pusher's
-// txn Timestamp + 1.
-func TestPushTxnPushTimestamp(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	tc := testContext{}
-	tc.Start(t)
-	defer tc.Stop()
-
-	pusher := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
-	pushee := newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
-	pusher.Priority = 2
-	pushee.Priority = 1 // pusher will win
-	pusher.Timestamp = proto.Timestamp{WallTime: 50, Logical: 25}
-	pushee.Timestamp = proto.Timestamp{WallTime: 5, Logical: 1}
-
-	// Now, push the transaction with args.Abort=false.
-	args := pushTxnArgs(pusher, pushee, proto.PUSH_TIMESTAMP, 1, tc.store.StoreID())
-
-	resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	rng, err := NewReplica(desc, tc.store)
 	if err != nil {
-		t.Errorf("unexpected error on push: %s", err)
-	}
-	expTS := pusher.Timestamp
-	expTS.Logical++
-	reply := resp.(*proto.PushTxnResponse)
-	if !reply.PusheeTxn.Timestamp.Equal(expTS) {
-		t.Errorf("expected timestamp to be pushed to %+v; got %+v", expTS, reply.PusheeTxn.Timestamp)
-	}
-	if reply.PusheeTxn.Status != proto.PENDING {
-		t.Errorf("expected pushed txn to have status PENDING; got %s", reply.PusheeTxn.Status)
+		t.Fatal(err)
 	}
-}
-
-// TestPushTxnPushTimestampAlreadyPushed verifies that pushing
-// a timestamp forward which is already far enough forward is a simple
-// noop. We do this by ensuring that priorities would otherwise make
-// pushing impossible.
-func TestPushTxnPushTimestampAlreadyPushed(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	tc := testContext{}
-	tc.Start(t)
-	defer tc.Stop()
-
-	pusher := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
-	pushee := newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
-	pusher.Priority = 1
-	pushee.Priority = 2 // pusher will lose
-	pusher.Timestamp = proto.Timestamp{WallTime: 50, Logical: 0}
-	pushee.Timestamp = proto.Timestamp{WallTime: 50, Logical: 1}
-
-	// Now, push the transaction with args.Abort=false.
-	args := pushTxnArgs(pusher, pushee, proto.PUSH_TIMESTAMP, 1, tc.store.StoreID())
 
-	resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	replicas, err := rng.ReplicaAddresses()
 	if err != nil {
-		t.Errorf("unexpected error on push: %s", err)
+		t.Fatal(err)
 	}
-	reply := resp.(*proto.PushTxnResponse)
-	if !reply.PusheeTxn.Timestamp.Equal(pushee.Timestamp) {
-		t.Errorf("expected timestamp to be equal to original %+v; got %+v", pushee.Timestamp, reply.PusheeTxn.Timestamp)
+	expReplicas := []proto.ReplicaDescriptor{
+		{Replica: desc.Replicas[0], Address: addr},
+		{Replica: desc.Replicas[1]},
 	}
-	if reply.PusheeTxn.Status != proto.PENDING {
-		t.Errorf("expected pushed txn to have status PENDING; got %s", reply.PusheeTxn.Status)
+	if !reflect.DeepEqual(replicas, expReplicas) {
+		t.Errorf("expected replicas %+v; got %+v", expReplicas, replicas)
 	}
 }
 
-// TestRangeResolveIntentRange verifies resolving a range of intents.
-func TestRangeResolveIntentRange(t *testing.T) {
+// TestRangeCompactRange verifies that compacting a range after deleting
+// a span of its keys completes and leaves the visible data unchanged.
+func TestRangeCompactRange(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Put two values transactionally.
-	txn := &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: tc.clock.Now()}
-	for _, key := range []proto.Key{proto.Key("a"), proto.Key("b")} {
-		pArgs := putArgs(key, []byte("value1"), 1, tc.store.StoreID())
-		pArgs.Txn = txn
+	value := bytes.Repeat([]byte("v"), 1<<10)
+	for i := 0; i < 100; i++ {
+		pArgs := putArgs(proto.Key(fmt.Sprintf("a%03d", i)), value, 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
 		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
 			t.Fatal(err)
 		}
 	}
-
-	// Resolve the intents.
-	rArgs := &proto.ResolveIntentRangeRequest{
+	pArgs := putArgs(proto.Key("b"), value, 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	dArgs := proto.DeleteRangeRequest{
 		RequestHeader: proto.RequestHeader{
-			Timestamp: txn.Timestamp,
 			Key:       proto.Key("a"),
-			EndKey:    proto.Key("c"),
-			RangeID:   tc.rng.Desc().RangeID,
+			EndKey:    proto.Key("b"),
+			RangeID:   1,
 			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
-			Txn:       txn,
+			Timestamp: tc.clock.Now(),
 		},
 	}
-	rArgs.Txn.Status = proto.COMMITTED
-	if _, err := tc.rng.AddCmd(tc.rng.context(), rArgs); err != nil {
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &dArgs); err != nil {
 		t.Fatal(err)
 	}
 
-	// Do a consistent scan to verify intents have been cleared.
-	sArgs := scanArgs(proto.Key("a"), proto.Key("c"), 1, tc.store.StoreID())
-	reply, err := tc.store.ExecuteCmd(context.Background(), &sArgs)
-	if err != nil {
-		t.Fatalf("unexpected error on scan: %s", err)
-	}
-	sReply := reply.(*proto.ScanResponse)
-	if len(sReply.Rows) != 2 {
-		t.Errorf("expected 2 rows; got %v", sReply.Rows)
+	if err := tc.rng.CompactRange(); err != nil {
+		t.Fatal(err)
 	}
-}
 
-func verifyRangeStats(eng engine.Engine, rangeID proto.RangeID, expMS engine.MVCCStats, t *testing.T) {
-	var ms engine.MVCCStats
-	if err := engine.MVCCGetRangeStats(eng, rangeID, &ms); err != nil {
+	sArgs := scanArgs(proto.Key("a"), proto.Key("c"), 1, tc.store.StoreID())
+	sArgs.Timestamp = tc.clock.Now()
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &sArgs)
+	if err != nil {
 		t.Fatal(err)
 	}
-	if !reflect.DeepEqual(expMS, ms) {
-		t.Errorf("expected stats %+v; got %+v", expMS, ms)
+	rows := reply.(*proto.ScanResponse).Rows
+	if len(rows) != 1 || !rows[0].Key.Equal(proto.Key("b")) || !bytes.Equal(rows[0].Value.Bytes, value) {
+		t.Errorf("expected only key \"b\" after compaction; got %d rows", len(rows))
 	}
 }
 
-// TestRangeStatsComputation verifies that commands executed against a
-// range update the range stat counters. The stat values are
-// empirically derived; we're really just testing that they increment
-// in the right ways, not the exact amounts. If the encodings change,
-// will need to update this test.
-func TestRangeStatsComputation(t *testing.T) {
+// TestRangeDataBounds verifies that DataBounds reports the first and
+// last user keys stored in a sparsely populated range, ignoring
+// range-local keys and keys outside the range.
+func TestRangeDataBounds(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	tc := testContext{
-		bootstrapMode: bootstrapRangeOnly,
-	}
+	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Put a value.
-	pArgs := putArgs([]byte("a"), []byte("value1"), 1, tc.store.StoreID())
-	pArgs.Timestamp = tc.clock.Now()
-
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+	desc := &proto.RangeDescriptor{
+		RangeID:  2,
+		StartKey: proto.Key("b"),
+		EndKey:   proto.Key("y"),
+		Replicas: []proto.Replica{{NodeID: 1, StoreID: 1, ReplicaID: 1}},
+	}
+	rng, err := NewReplica(desc, tc.store)
+	if err != nil {
 		t.Fatal(err)
 	}
-	expMS := engine.MVCCStats{LiveBytes: 39, KeyBytes: 15, ValBytes: 24, IntentBytes: 0, LiveCount: 1, KeyCount: 1, ValCount: 1, IntentCount: 0, SysBytes: 58, SysCount: 1}
-	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
 
-	// Put a 2nd value transactionally.
-	pArgs = putArgs([]byte("b"), []byte("value2"), 1, tc.store.StoreID())
-	pArgs.Timestamp = tc.clock.Now()
-	pArgs.Txn = &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: pArgs.Timestamp}
+	put := func(key proto.Key) {
+		if err := engine.MVCCPut(tc.engine, nil, key, makeTS(1, 0), proto.Value{Bytes: []byte("value")}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Keys outside the range and range-local keys don't count.
+	put(proto.Key("a"))
+	put(proto.Key("y"))
+	put(keys.RangeDescriptorKey(proto.Key("b")))
+	put(keys.TransactionKey(proto.Key("x"), []byte("txn")))
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+	if first, last, err := rng.DataBounds(); err != nil {
 		t.Fatal(err)
+	} else if first != nil || last != nil {
+		t.Errorf("expected empty bounds for empty range; got [%s, %s]", first, last)
 	}
-	expMS = engine.MVCCStats{LiveBytes: 130, KeyBytes: 30, ValBytes: 100, IntentBytes: 24, LiveCount: 2, KeyCount: 2, ValCount: 2, IntentCount: 1, SysBytes: 58, SysCount: 1}
-	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
 
-	// Resolve the 2nd value.
-	rArgs := &proto.ResolveIntentRequest{
-		RequestHeader: proto.RequestHeader{
-			Timestamp: pArgs.Txn.Timestamp,
-			Key:       pArgs.Key,
-			RangeID:   tc.rng.Desc().RangeID,
-			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
-			Txn:       pArgs.Txn,
-		},
+	for _, key := range []string{"k", "d", "q"} {
+		put(proto.Key(key))
 	}
-	rArgs.Txn.Status = proto.COMMITTED
-
-	if _, err := tc.rng.AddCmd(tc.rng.context(), rArgs); err != nil {
+	first, last, err := rng.DataBounds()
+	if err != nil {
 		t.Fatal(err)
 	}
-	expMS = engine.MVCCStats{LiveBytes: 78, KeyBytes: 30, ValBytes: 48, IntentBytes: 0, LiveCount: 2, KeyCount: 2, ValCount: 2, IntentCount: 0, SysBytes: 58, SysCount: 1}
-	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
+	if !first.Equal(proto.Key("d")) || !last.Equal(proto.Key("q")) {
+		t.Errorf("expected bounds [d, q]; got [%s, %s]", first, last)
+	}
+}
 
-	// Delete the 1st value.
-	dArgs := deleteArgs([]byte("a"), 1, tc.store.StoreID())
-	dArgs.Timestamp = tc.clock.Now()
+// TestRangeResponseCacheChecksum verifies that two replicas with
+// identical response cache entries compute matching checksums, and
+// that the checksums differ once the caches diverge.
+func TestRangeResponseCacheChecksum(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc1 := testContext{}
+	tc1.Start(t)
+	defer tc1.Stop()
+	tc2 := testContext{}
+	tc2.Start(t)
+	defer tc2.Stop()
+
+	checksums := func() ([]byte, []byte) {
+		sum1, err := tc1.rng.ResponseCacheChecksum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum2, err := tc2.rng.ResponseCacheChecksum()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum1, sum2
+	}
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &dArgs); err != nil {
+	for i := int64(1); i <= 3; i++ {
+		cmdID := proto.ClientCmdID{WallTime: i, Random: i}
+		reply := proto.IncrementResponse{NewValue: i}
+		for _, tc := range []*testContext{&tc1, &tc2} {
+			if err := tc.rng.respCache.PutResponse(tc.engine, cmdID,
+				proto.ResponseWithError{Reply: &reply}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if sum1, sum2 := checksums(); !bytes.Equal(sum1, sum2) {
+		t.Errorf("expected matching checksums; got %x and %x", sum1, sum2)
+	}
+
+	// Diverge the second replica's response cache.
+	cmdID := proto.ClientCmdID{WallTime: 4, Random: 4}
+	if err := tc2.rng.respCache.PutResponse(tc2.engine, cmdID,
+		proto.ResponseWithError{Reply: &proto.IncrementResponse{NewValue: 4}}); err != nil {
 		t.Fatal(err)
 	}
-	expMS = engine.MVCCStats{LiveBytes: 39, KeyBytes: 42, ValBytes: 50, IntentBytes: 0, LiveCount: 1, KeyCount: 2, ValCount: 3, IntentCount: 0, SysBytes: 58, SysCount: 1}
-	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
+	if sum1, sum2 := checksums(); bytes.Equal(sum1, sum2) {
+		t.Errorf("expected checksums to differ; got %x for both", sum1)
+	}
 }
 
-// TestMerge verifies that the Merge command is behaving as
-// expected. Merge semantics for different data types are tested more
-// robustly at the engine level; this test is intended only to show
-// that values passed to Merge are being merged.
-func TestMerge(t *testing.T) {
+// TestRangeGossipFirstRange verifies that the first range gossips its
+// location and the cluster ID.
+func TestRangeGossipFirstRange(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
+	for _, key := range []string{gossip.KeyClusterID, gossip.KeyFirstRangeDescriptor, gossip.KeySentinel} {
+		bytes, err := tc.gossip.GetInfo(key)
+		if err != nil {
+			t.Errorf("missing first range gossip of key %s", key)
+		}
+		if key == gossip.KeyFirstRangeDescriptor {
+			var rangeDesc proto.RangeDescriptor
+			if err := gogoproto.Unmarshal(bytes, &rangeDesc); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if key == gossip.KeyClusterID && len(bytes) == 0 {
+			t.Errorf("expected non-empty gossiped cluster ID, got %q", bytes)
+		}
+		if key == gossip.KeySentinel && len(bytes) == 0 {
+			t.Errorf("expected non-empty gossiped sentinel, got %q", bytes)
+		}
+	}
+}
 
-	key := []byte("mergedkey")
-	stringArgs := []string{"a", "b", "c", "d"}
-	stringExpected := "abcd"
-
-	for _, str := range stringArgs {
-		mergeArgs := internalMergeArgs(key, proto.Value{Bytes: []byte(str)}, 1, tc.store.StoreID())
+// TestRangeGossipFirstRangeOncePerInterval verifies that the cluster
+// ID is gossiped by every replica of the first range, while an
+// unchanged first range descriptor isn't gossiped again within the
+// interval, neither by the lease holder nor by other replicas.
+func TestRangeGossipFirstRangeOncePerInterval(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &mergeArgs); err != nil {
-			t.Fatalf("unexpected error from Merge: %s", err.Error())
+	infoTimestamp := func(key string) time.Time {
+		ts, err := tc.gossip.GetInfoTimestamp(key)
+		if err != nil {
+			t.Fatal(err)
 		}
+		return ts
 	}
+	clusterIDTS := infoTimestamp(gossip.KeyClusterID)
+	descTS := infoTimestamp(gossip.KeyFirstRangeDescriptor)
 
-	getArgs := getArgs(key, 1, tc.store.StoreID())
+	// Gossip again as the lease holder.
+	if err := tc.rng.maybeGossipFirstRange(); err != nil {
+		t.Fatal(err)
+	}
+	if ts := infoTimestamp(gossip.KeyClusterID); !clusterIDTS.Before(ts) {
+		t.Errorf("expected cluster ID to be gossiped again; timestamp %s not after %s", ts, clusterIDTS)
+	}
+	if ts := infoTimestamp(gossip.KeyFirstRangeDescriptor); !ts.Equal(descTS) {
+		t.Errorf("expected first range descriptor not to be gossiped again; timestamp %s != %s", ts, descTS)
+	}
 
-	reply, err := tc.rng.AddCmd(tc.rng.context(), &getArgs)
-	if err != nil {
-		t.Fatalf("unexpected error from Get: %s", err)
+	// Hand the lease to another replica; this replica still gossips the
+	// cluster ID, but not the first range descriptor.
+	clusterIDTS = infoTimestamp(gossip.KeyClusterID)
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration + 1))
+	now := tc.clock.Now()
+	setLeaderLease(t, tc.rng, &proto.Lease{
+		Start:      now,
+		Expiration: now.Add(10, 0),
+		RaftNodeID: proto.MakeRaftNodeID(2, 2),
+	})
+	if err := tc.rng.maybeGossipFirstRange(); err != nil {
+		t.Fatal(err)
 	}
-	resp := reply.(*proto.GetResponse)
-	if resp.Value == nil {
-		t.Fatal("GetResponse had nil value")
+	if ts := infoTimestamp(gossip.KeyClusterID); !clusterIDTS.Before(ts) {
+		t.Errorf("expected cluster ID to be gossiped by non-lease holder; timestamp %s not after %s", ts, clusterIDTS)
 	}
-	if a, e := resp.Value.Bytes, []byte(stringExpected); !bytes.Equal(a, e) {
-		t.Errorf("Get did not return expected value: %s != %s", string(a), e)
+	if ts := infoTimestamp(gossip.KeyFirstRangeDescriptor); !ts.Equal(descTS) {
+		t.Errorf("expected first range descriptor not to be gossiped by non-lease holder; timestamp %s != %s", ts, descTS)
 	}
 }
 
-// TestTruncateLog verifies that the TruncateLog command removes a
-// prefix of the raft logs (modifying FirstIndex() and making them
-// inaccessible via Entries()).
-func TestTruncateLog(t *testing.T) {
+// TestRangeGossipAllConfigs verifies that all config types are gossiped.
+func TestRangeGossipAllConfigs(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-
-	// Populate the log with 10 entries. Save the LastIndex after each write.
-	var indexes []uint64
-	for i := 0; i < 10; i++ {
-		args := incrementArgs([]byte("a"), int64(i), 1, tc.store.StoreID())
-
-		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
-			t.Fatal(err)
-		}
-		idx, err := tc.rng.LastIndex()
+	testData := []struct {
+		gossipKey string
+		configs   []config.PrefixConfig
+	}{
+		{gossip.KeyConfigZone, []config.PrefixConfig{config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig)}},
+	}
+	for _, test := range testData {
+		_, err := tc.gossip.GetInfo(test.gossipKey)
 		if err != nil {
 			t.Fatal(err)
 		}
-		indexes = append(indexes, idx)
 	}
+}
 
-	// Discard the first half of the log
-	truncateArgs := truncateLogArgs(indexes[5], 1, tc.store.StoreID())
+// TestRangeSystemConfig verifies that SystemConfig returns the system
+// config stored in a range covering the SystemDB span, and fails on a
+// range which doesn't.
+func TestRangeSystemConfig(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &truncateArgs); err != nil {
+	key := keys.MakeKey(keys.SystemDBSpan.Start, proto.Key("foo"))
+	pArgs := putArgs(key, []byte("bar"), 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
 		t.Fatal(err)
 	}
-
-	// FirstIndex has changed.
-	firstIndex, err := tc.rng.FirstIndex()
+	cfg, err := tc.rng.SystemConfig()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if firstIndex != indexes[5] {
-		t.Errorf("expected firstIndex == %d, got %d", indexes[5], firstIndex)
+	var found bool
+	for _, kv := range cfg.Values {
+		if kv.Key.Equal(key) {
+			found = bytes.Equal(kv.Value.Bytes, []byte("bar"))
+		}
+	}
+	if !found {
+		t.Errorf("expected system config to contain %q, got %+v", key, cfg.Values)
 	}
 
-	// We can still get what remains of the log.
-	entries, err := tc.rng.Entries(indexes[5], indexes[9], math.MaxUint64)
+	// A range outside of the SystemDB span has no system config.
+	rng, err := NewReplica(&proto.RangeDescriptor{
+		RangeID:  2,
+		StartKey: proto.Key("a"),
+		EndKey:   proto.Key("b"),
+		Replicas: []proto.Replica{{NodeID: 1, StoreID: 1}},
+	}, tc.store)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(entries) != int(indexes[9]-indexes[5]) {
-		t.Errorf("expected %d entries, got %d", indexes[9]-indexes[5], len(entries))
+	if _, err := rng.SystemConfig(); err == nil {
+		t.Error("expected error loading system config from a range without the SystemDB span")
 	}
+}
 
-	// But any range that includes the truncated entries returns an error.
-	_, err = tc.rng.Entries(indexes[4], indexes[9], math.MaxUint64)
-	if err != raft.ErrUnavailable {
-		t.Errorf("expected ErrUnavailable, got %s", err)
-	}
+// TestRangeGossipConfigTTL verifies that config maps are gossiped with
+// the TTL of their config descriptor and that an unchanged map is not
+// gossiped again while its TTL is far from expiring.
+func TestRangeGossipConfigTTL(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	cd := configDescriptors[0]
+	defer func(ttl time.Duration) { cd.ttl = ttl }(cd.ttl)
+	cd.ttl = time.Hour
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-	// The term of the last truncated entry is still available.
-	term, err := tc.rng.Term(indexes[4])
+	if ttl, err := tc.gossip.GetInfoTTL(cd.gossipKey); err != nil || ttl != cd.ttl {
+		t.Fatalf("expected %s to be gossiped with TTL %s; got %s, %v", cd.gossipKey, cd.ttl, ttl, err)
+	}
+	ts, err := tc.gossip.GetInfoTimestamp(cd.gossipKey)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if term == 0 {
-		t.Errorf("invalid term 0 for truncated entry")
-	}
-
-	// The terms of older entries are gone.
-	_, err = tc.rng.Term(indexes[3])
-	if err != raft.ErrUnavailable {
-		t.Errorf("expected ErrUnavailable, got %s", err)
+	tc.rng.maybeGossipConfigs(func(proto.Key) bool { return true })
+	if ts2, err := tc.gossip.GetInfoTimestamp(cd.gossipKey); err != nil || !ts2.Equal(ts) {
+		t.Errorf("expected %s not to be gossiped again; timestamp %s != %s (%v)", cd.gossipKey, ts2, ts, err)
 	}
 }
 
-func TestRaftStorage(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	var eng engine.Engine
-	storagetest.RunTests(t,
-		func(t *testing.T) storagetest.WriteableStorage {
-			eng = engine.NewInMem(proto.Attributes{Attrs: []string{"dc1", "mem"}}, 1<<20)
-			// Fake store to house the engine.
-			store := &Store{
-				ctx: StoreContext{
-					Clock: hlc.NewClock(hlc.UnixNano),
-				},
-				engine: eng,
-			}
-			rng, err := NewReplica(&proto.RangeDescriptor{
-				RangeID:  1,
-				StartKey: proto.KeyMin,
-				EndKey:   proto.KeyMax,
-			}, store)
-			if err != nil {
-				t.Fatal(err)
-			}
-			return rng
-		},
-		func(t *testing.T, r storagetest.WriteableStorage) {
-			eng.Close()
-		})
-}
-
-// TestConditionFailedError tests that a ConditionFailedError correctly
-// bubbles up from MVCC to Range.
-func TestConditionFailedError(t *testing.T) {
+// TestRangeGossipConfigWithMultipleKeyPrefixes verifies that multiple
+// key prefixes for a config are gossiped.
+func TestRangeGossipConfigWithMultipleKeyPrefixes(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-
-	key := []byte("k")
-	value := []byte("quack")
-	pArgs := putArgs(key, value, 1, tc.store.StoreID())
-
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+	// Add a zone for a new key prefix.
+	db1Zone := &config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{Attrs: []string{"dc1", "ssd"}},
+			{Attrs: []string{"dc2", "ssd"}},
+		},
+	}
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
+	data, err := gogoproto.Marshal(db1Zone)
+	if err != nil {
 		t.Fatal(err)
 	}
-	args := proto.ConditionalPutRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:       key,
-			Timestamp: proto.MinTimestamp,
-			RangeID:   1,
-			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
-		},
-		Value: proto.Value{
-			Bytes: value,
-		},
-		ExpValue: &proto.Value{
-			Bytes: []byte("moo"),
-		},
+	req := proto.PutRequest{
+		RequestHeader: proto.RequestHeader{Key: key, Timestamp: proto.MinTimestamp},
+		Value:         proto.Value{Bytes: data},
 	}
 
-	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &req); err != nil {
+		t.Fatal(err)
+	}
 
-	if cErr, ok := err.(*proto.ConditionFailedError); err == nil || !ok {
-		t.Fatalf("expected ConditionFailedError, got %T with content %+v",
-			err, err)
-	} else if v := cErr.ActualValue; v == nil || !bytes.Equal(v.Bytes, value) {
-		t.Errorf("ConditionFailedError with bytes %q expected, but got %+v",
-			value, v)
+	configMap, err := tc.gossip.GetZoneConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expConfigs := []config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
+		config.MakePrefixConfig(proto.Key("/db1"), nil, db1Zone),
+		config.MakePrefixConfig(proto.Key("/db2"), proto.KeyMin, &testDefaultZoneConfig),
+	}
+	if !reflect.DeepEqual(configMap.Configs, expConfigs) {
+		t.Errorf("expected gossiped configs to be equal %s vs %s", configMap, expConfigs)
 	}
 }
 
-// TestReplicaSetsEqual tests to ensure that intersectReplicaSets
-// returns the correct responses.
-func TestReplicaSetsEqual(t *testing.T) {
+// TestRangeGossipConfigUpdates verifies that writes to the
+// zones cause the updated configs to be re-gossiped.
+func TestRangeGossipConfigUpdates(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	testData := []struct {
-		expected bool
-		a        []proto.Replica
-		b        []proto.Replica
-	}{
-		{true, []proto.Replica{}, []proto.Replica{}},
-		{true, createReplicaSets([]proto.StoreID{1}), createReplicaSets([]proto.StoreID{1})},
-		{true, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{1, 2})},
-		{true, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{2, 1})},
-		{false, createReplicaSets([]proto.StoreID{1}), createReplicaSets([]proto.StoreID{2})},
-		{false, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{2})},
-		{false, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{1})},
-		{false, createReplicaSets([]proto.StoreID{}), createReplicaSets([]proto.StoreID{1})},
-		{true, createReplicaSets([]proto.StoreID{1, 2, 3}), createReplicaSets([]proto.StoreID{2, 3, 1})},
-		{true, createReplicaSets([]proto.StoreID{1, 1}), createReplicaSets([]proto.StoreID{1, 1})},
-		{false, createReplicaSets([]proto.StoreID{1, 1}), createReplicaSets([]proto.StoreID{1, 1, 1})},
-		{true, createReplicaSets([]proto.StoreID{1, 2, 3, 1, 2, 3}), createReplicaSets([]proto.StoreID{1, 1, 2, 2, 3, 3})},
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	// Add a zone for a new key prefix.
+	db1Zone := &config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{Attrs: []string{"dc1", "ssd"}},
+			{Attrs: []string{"dc2", "ssd"}},
+		},
 	}
-	for _, test := range testData {
-		if replicaSetsEqual(test.a, test.b) != test.expected {
-			t.Fatalf("unexpected replica intersection: %+v", test)
-		}
+
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
+	data, err := gogoproto.Marshal(db1Zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := proto.PutRequest{
+		RequestHeader: proto.RequestHeader{Key: key, Timestamp: proto.MinTimestamp},
+		Value:         proto.Value{Bytes: data},
+	}
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	configMap, err := tc.gossip.GetZoneConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expConfigs := []config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
+		config.MakePrefixConfig(proto.Key("/db1"), nil, db1Zone),
+		config.MakePrefixConfig(proto.Key("/db2"), proto.KeyMin, &testDefaultZoneConfig),
+	}
+	if !reflect.DeepEqual(configMap.Configs, expConfigs) {
+		t.Errorf("expected gossiped configs to be equal %s vs %s", configMap, expConfigs)
 	}
 }
 
-func TestAppliedIndex(t *testing.T) {
+// TestRangeNoGossipConfig verifies that certain commands (e.g.,
+// reads, writes in uncommitted transactions) do not trigger gossip.
+func TestRangeNoGossipConfig(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	var appliedIndex uint64
-	var sum int64
-	for i := int64(1); i <= 10; i++ {
-		args := incrementArgs([]byte("a"), i, 1, tc.store.StoreID())
+	// Add a zone for a new key prefix.
+	db1Zone := &config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{Attrs: []string{"dc1", "ssd"}},
+			{Attrs: []string{"dc2", "ssd"}},
+		},
+	}
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
+	rangeID := proto.RangeID(1)
 
-		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
-		if err != nil {
+	txn := newTransaction("test", key, 1 /* userPriority */, proto.SERIALIZABLE, tc.clock)
+	data, err := gogoproto.Marshal(db1Zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req1 := putArgs(key, data, rangeID, tc.store.StoreID())
+	req1.Txn = txn
+	req1.Timestamp = txn.Timestamp
+
+	req2 := endTxnArgs(txn, true /* commit */, rangeID, tc.store.StoreID())
+	req2.Timestamp = txn.Timestamp
+	req2.Intents = []proto.Intent{{Key: key}}
+
+	req3 := getArgs(key, rangeID, tc.store.StoreID())
+	req3.Timestamp = txn.Timestamp
+
+	for i, req := range []proto.Request{&req1, &req2, &req3} {
+		if _, err := tc.store.ExecuteCmd(tc.rng.context(), req); err != nil {
 			t.Fatal(err)
 		}
-		reply := resp.(*proto.IncrementResponse)
-		sum += i
 
-		if reply.NewValue != sum {
-			t.Errorf("expected %d, got %d", sum, reply.NewValue)
+		// Information for db1 is not gossiped.
+		configMap, err := tc.gossip.GetZoneConfig()
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		newAppliedIndex := atomic.LoadUint64(&tc.rng.appliedIndex)
-		if newAppliedIndex <= appliedIndex {
-			t.Errorf("appliedIndex did not advance. Was %d, now %d", appliedIndex, newAppliedIndex)
+		expConfigs := []config.PrefixConfig{
+			config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
+		}
+		if !reflect.DeepEqual(configMap.Configs, expConfigs) {
+			t.Errorf("%d: expected gossiped configs to be equal %s vs %s",
+				i, configMap, expConfigs)
 		}
-		appliedIndex = newAppliedIndex
 	}
 }
 
-// TestReplicaCorruption verifies that a replicaCorruptionError correctly marks
-// the range as corrupt.
-func TestReplicaCorruption(t *testing.T) {
+// TestRangeNoGossipFromNonLeader verifies that a non-leader replica
+// does not gossip configurations.
+func TestRangeNoGossipFromNonLeader(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	args := putArgs(proto.Key("test"), []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
-	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	// Add a zone for a new key prefix. Set the config in a transaction
+	// to avoid gossip.
+	db1Zone := &config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{Attrs: []string{"dc1", "ssd"}},
+			{Attrs: []string{"dc2", "ssd"}},
+		},
+	}
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
+	rangeID := proto.RangeID(1)
+
+	txn := newTransaction("test", key, 1 /* userPriority */, proto.SERIALIZABLE, tc.clock)
+	data, err := gogoproto.Marshal(db1Zone)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Set the stored applied index sky high.
-	newIndex := 2*atomic.LoadUint64(&tc.rng.appliedIndex) + 1
-	atomic.StoreUint64(&tc.rng.appliedIndex, newIndex)
-	// Not really needed, but let's be thorough.
-	err = setAppliedIndex(tc.rng.rm.Engine(), tc.rng.Desc().RangeID, newIndex)
+	req1 := putArgs(key, data, rangeID, tc.store.StoreID())
+	req1.Txn = txn
+	req1.Timestamp = txn.Timestamp
+	if _, err := tc.store.ExecuteCmd(tc.rng.context(), &req1); err != nil {
+		t.Fatal(err)
+	}
+	req2 := endTxnArgs(txn, true /* commit */, rangeID, tc.store.StoreID())
+	req2.Timestamp = txn.Timestamp
+	req2.Intents = []proto.Intent{{Key: key}}
+	if _, err := tc.store.ExecuteCmd(tc.rng.context(), &req2); err != nil {
+		t.Fatal(err)
+	}
+	// Execute a get to resolve the intent.
+	req3 := getArgs(key, rangeID, tc.store.StoreID())
+	req3.Timestamp = txn.Timestamp
+	if _, err := tc.store.ExecuteCmd(tc.rng.context(), &req3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Increment the clock's timestamp to expire the leader lease.
+	tc.manualClock.Increment(int64(DefaultLeaderLeaseDuration) + 1)
+	if lease := tc.rng.getLease(); lease.Covers(tc.clock.Now()) {
+		t.Fatal("leader lease should have been expired")
+	}
+
+	// Make sure the information for db1 is not gossiped.
+	tc.rng.maybeGossipConfigs(func(configPrefix proto.Key) bool {
+		return tc.rng.ContainsKey(configPrefix)
+	})
+	configMap, err := tc.gossip.GetZoneConfig()
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Should mark replica corrupt (and panic as a result) since we messed
-	// with the applied index.
-	_, err = tc.rng.AddCmd(tc.rng.context(), &args)
+	expConfigs := []config.PrefixConfig{
+		config.MakePrefixConfig(proto.KeyMin, nil, &testDefaultZoneConfig),
+	}
+	if !reflect.DeepEqual(configMap.Configs, expConfigs) {
+		t.Errorf("expected gossiped configs to be equal %s vs %s",
+			configMap, expConfigs)
+	}
+}
 
-	if err == nil || !strings.Contains(err.Error(), "replica corruption (processed=true)") {
-		t.Fatalf("unexpected error: %s", err)
+// TestRangeWaitForConfigGossip verifies that WaitForConfigGossip
+// blocks until an updated config has been gossiped and that it returns
+// when its context is canceled.
+func TestRangeWaitForConfigGossip(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// The initial config has already been gossiped.
+	if err := tc.rng.WaitForConfigGossip(context.Background(), gossip.KeyConfigZone); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.rng.WaitForConfigGossip(context.Background(), "unknown"); err == nil {
+		t.Error("expected error waiting for unknown config key")
+	}
+
+	// Write a zone directly to the engine so that it isn't gossiped.
+	db1Zone := &config.ZoneConfig{
+		ReplicaAttrs: []proto.Attributes{
+			{Attrs: []string{"dc1", "ssd"}},
+		},
+	}
+	key := keys.MakeKey(keys.ConfigZonePrefix, proto.Key("/db1"))
+	if err := engine.MVCCPutProto(tc.engine, nil, key, proto.MinTimestamp, nil, db1Zone); err != nil {
+		t.Fatal(err)
+	}
+
+	// A canceled context unblocks the wait.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tc.rng.WaitForConfigGossip(ctx, gossip.KeyConfigZone); err != context.Canceled {
+		t.Errorf("expected %s; got %v", context.Canceled, err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tc.rng.WaitForConfigGossip(context.Background(), gossip.KeyConfigZone)
+	}()
+	select {
+	case err := <-errChan:
+		t.Fatalf("wait returned before config was gossiped: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	tc.rng.maybeGossipConfigs(func(configPrefix proto.Key) bool {
+		return tc.rng.ContainsKey(configPrefix)
+	})
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return after config was gossiped")
+	}
+}
+
+// getArgs returns a GetRequest and GetResponse pair addressed to
+// the default replica for the specified key.
+func getArgs(key []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.GetRequest {
+	return proto.GetRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     key,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+		},
+	}
+}
+
+// putArgs returns a PutRequest and PutResponse pair addressed to
+// the default replica for the specified key / value.
+func putArgs(key, value []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.PutRequest {
+	return proto.PutRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       key,
+			Timestamp: proto.MinTimestamp,
+			RangeID:   rangeID,
+			Replica:   proto.Replica{StoreID: storeID},
+		},
+		Value: proto.Value{
+			Bytes: value,
+		},
+	}
+}
+
+// cPutArgs returns a ConditionalPutRequest to the default replica
+// for the specified key, value and expected value.
+func cPutArgs(key, value, expValue []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.ConditionalPutRequest {
+	return proto.ConditionalPutRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       key,
+			Timestamp: proto.MinTimestamp,
+			RangeID:   rangeID,
+			Replica:   proto.Replica{StoreID: storeID},
+		},
+		Value: proto.Value{
+			Bytes: value,
+		},
+		ExpValue: &proto.Value{
+			Bytes: expValue,
+		},
+	}
+}
+
+// deleteArgs returns a DeleteRequest and DeleteResponse pair.
+func deleteArgs(key proto.Key, rangeID proto.RangeID, storeID proto.StoreID) proto.DeleteRequest {
+	return proto.DeleteRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     key,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+		},
+	}
+}
+
+// readOrWriteArgs returns either get or put arguments depending on
+// value of "read". Get for true; Put for false. Returns method
+// selected and args & reply.
+func readOrWriteArgs(key proto.Key, read bool, rangeID proto.RangeID, storeID proto.StoreID) proto.Request {
+	if read {
+		gArgs := getArgs(key, rangeID, storeID)
+		return &gArgs
+	}
+	pArgs := putArgs(key, []byte("value"), rangeID, storeID)
+	return &pArgs
+}
+
+// incrementArgs returns an IncrementRequest and IncrementResponse pair
+// addressed to the default replica for the specified key / value.
+func incrementArgs(key []byte, inc int64, rangeID proto.RangeID, storeID proto.StoreID) proto.IncrementRequest {
+	return proto.IncrementRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     key,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+		},
+		Increment: inc,
+	}
+}
+
+func scanArgs(start, end []byte, rangeID proto.RangeID, storeID proto.StoreID) proto.ScanRequest {
+	return proto.ScanRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     start,
+			EndKey:  end,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+		},
+	}
+}
+
+// endTxnArgs returns request/response pair for EndTransaction RPC
+// addressed to the default replica for the specified key.
+func endTxnArgs(txn *proto.Transaction, commit bool, rangeID proto.RangeID, storeID proto.StoreID) proto.EndTransactionRequest {
+	return proto.EndTransactionRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     txn.Key,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+			Txn:     txn,
+		},
+		Commit: commit,
+	}
+}
+
+// pushTxnArgs returns a request/response pair for PushTxn RPC
+// addressed to the default replica for the specified key.
+func pushTxnArgs(pusher, pushee *proto.Transaction, pushType proto.PushTxnType, rangeID proto.RangeID, storeID proto.StoreID) proto.PushTxnRequest {
+	return proto.PushTxnRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       pushee.Key,
+			Timestamp: pusher.Timestamp,
+			RangeID:   rangeID,
+			Replica:   proto.Replica{StoreID: storeID},
+			Txn:       pusher,
+		},
+		Now:       pusher.Timestamp,
+		PusheeTxn: *pushee,
+		PushType:  pushType,
+	}
+}
+
+// heartbeatArgs returns request/response pair for HeartbeatTxn RPC.
+func heartbeatArgs(txn *proto.Transaction, rangeID proto.RangeID, storeID proto.StoreID) proto.HeartbeatTxnRequest {
+	return proto.HeartbeatTxnRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     txn.Key,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+			Txn:     txn,
+		},
+	}
+}
+
+// internalMergeArgs returns a MergeRequest and MergeResponse
+// pair addressed to the default replica for the specified key. The request will
+// contain the given proto.Value.
+func internalMergeArgs(key []byte, value proto.Value, rangeID proto.RangeID, storeID proto.StoreID) proto.MergeRequest {
+	return proto.MergeRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:     key,
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+		},
+		Value: value,
+	}
+}
+
+func truncateLogArgs(index uint64, rangeID proto.RangeID, storeID proto.StoreID) proto.TruncateLogRequest {
+	return proto.TruncateLogRequest{
+		RequestHeader: proto.RequestHeader{
+			RangeID: rangeID,
+			Replica: proto.Replica{StoreID: storeID},
+		},
+		Index: index,
+	}
+}
+
+// getSerializedMVCCValue produces a byte slice of the serialized
+// mvcc value. If value is nil, MVCCValue.Deleted is set to true;
+// otherwise MVCCValue.Value is set to value.
+func getSerializedMVCCValue(value *proto.Value) []byte {
+	mvccVal := &engine.MVCCValue{}
+	if value != nil {
+		mvccVal.Value = value
+	} else {
+		mvccVal.Deleted = true
+	}
+	data, err := gogoproto.Marshal(&engine.MVCCValue{Value: value})
+	if err != nil {
+		panic("unexpected marshal error")
+	}
+	return data
+}
+
+// TestAcquireLeaderLease verifies that the leader lease is acquired
+// for read and write methods.
+func TestAcquireLeaderLease(t *testing.T) {
+	defer leaktest.AfterTest(t)
+
+	gArgs := getArgs([]byte("a"), 1, 0)
+	pArgs := putArgs([]byte("b"), []byte("1"), 1, 0)
+
+	testCases := []proto.Request{&gArgs, &pArgs}
+
+	for i, test := range testCases {
+		tc := testContext{}
+		tc.Start(t)
+		// This is a single-replica test; since we're automatically pushing back
+		// the start of a lease as far as possible, and since there is an auto-
+		// matic lease for us at the beginning, we'll basically create a lease from
+		// then on.
+		expStart := tc.rng.getLease().Expiration
+		tc.manualClock.Set(int64(DefaultLeaderLeaseDuration + 1000))
+
+		test.Header().Timestamp = tc.clock.Now()
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), test); err != nil {
+			t.Fatal(err)
+		}
+		if held, expired := hasLease(tc.rng, test.Header().Timestamp); !held || expired {
+			t.Fatalf("%d: expected lease acquisition", i)
+		}
+		lease := tc.rng.getLease()
+		// The lease may start earlier than our request timestamp, but the
+		// expiration will still be measured relative to it.
+		expExpiration := test.Header().Timestamp.Add(int64(DefaultLeaderLeaseDuration), 0)
+		if !lease.Start.Equal(expStart) || !lease.Expiration.Equal(expExpiration) {
+			t.Errorf("%d: unexpected lease timing %s, %s; expected %s, %s", i,
+				lease.Start, lease.Expiration, expStart, expExpiration)
+		}
+		tc.Stop()
+	}
+}
+
+// TestRangeUpdateTSCache verifies that reads and writes update the
+// timestamp cache.
+func TestRangeUpdateTSCache(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	// Set clock to time 1s and do the read.
+	t0 := 1 * time.Second
+	tc.manualClock.Set(t0.Nanoseconds())
+	gArgs := getArgs([]byte("a"), 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+
+	_, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+
+	if err != nil {
+		t.Error(err)
+	}
+	// Set clock to time 2s for write.
+	t1 := 2 * time.Second
+	tc.manualClock.Set(t1.Nanoseconds())
+	pArgs := putArgs([]byte("b"), []byte("1"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+
+	_, err = tc.rng.AddCmd(tc.rng.context(), &pArgs)
+
+	if err != nil {
+		t.Error(err)
+	}
+	// Verify the timestamp cache has rTS=1s and wTS=0s for "a".
+	rTS, wTS := tc.rng.tsCache.GetMax(proto.Key("a"), nil, nil)
+	if rTS.WallTime != t0.Nanoseconds() || wTS.WallTime != 0 {
+		t.Errorf("expected rTS=1s and wTS=0s, but got %s, %s", rTS, wTS)
+	}
+	// Verify the timestamp cache has rTS=0s and wTS=2s for "b".
+	rTS, wTS = tc.rng.tsCache.GetMax(proto.Key("b"), nil, nil)
+	if rTS.WallTime != 0 || wTS.WallTime != t1.Nanoseconds() {
+		t.Errorf("expected rTS=0s and wTS=2s, but got %s, %s", rTS, wTS)
+	}
+	// Verify another key ("c") has 0sec in timestamp cache.
+	rTS, wTS = tc.rng.tsCache.GetMax(proto.Key("c"), nil, nil)
+	if rTS.WallTime != 0 || wTS.WallTime != 0 {
+		t.Errorf("expected rTS=0s and wTS=0s, but got %s %s", rTS, wTS)
+	}
+}
+
+// TestRangeSkipTimestampCacheUpdate verifies that writes which set
+// SkipTimestampCacheUpdate are not recorded in the timestamp cache,
+// while other writes and all reads are.
+func TestRangeSkipTimestampCacheUpdate(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	t0 := 1 * time.Second
+	tc.manualClock.Set(t0.Nanoseconds())
+	pArgs := putArgs([]byte("a"), []byte("1"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	pArgs.SkipTimestampCacheUpdate = true
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	pArgs = putArgs([]byte("b"), []byte("1"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, wTS := tc.rng.tsCache.GetMax(proto.Key("a"), nil, nil); wTS.WallTime != 0 {
+		t.Errorf("expected flagged write not to update the timestamp cache; got wTS=%s", wTS)
+	}
+	if _, wTS := tc.rng.tsCache.GetMax(proto.Key("b"), nil, nil); wTS.WallTime != t0.Nanoseconds() {
+		t.Errorf("expected wTS=1s for unflagged write; got %s", wTS)
+	}
+
+	// Reads are recorded regardless of the flag.
+	gArgs := getArgs([]byte("c"), 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	gArgs.SkipTimestampCacheUpdate = true
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+	if rTS, _ := tc.rng.tsCache.GetMax(proto.Key("c"), nil, nil); rTS.WallTime != t0.Nanoseconds() {
+		t.Errorf("expected rTS=1s for flagged read; got %s", rTS)
+	}
+
+	// The flagged write's command queue entry was removed all the same.
+	tc.rng.Lock()
+	wait := tc.rng.cmdQ.WouldWait(proto.KeyMin, proto.KeyMax, false)
+	tc.rng.Unlock()
+	if wait {
+		t.Error("expected empty command queue")
+	}
+}
+
+// TestRangeCommandQueue verifies that reads/writes must wait for
+// pending commands to complete through Raft before being executed on
+// range.
+func TestRangeCommandQueue(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
+
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Intercept commands with matching command IDs and block them.
+	blockingStart := make(chan struct{})
+	blockingDone := make(chan struct{})
+	TestingCommandFilter = func(args proto.Request) error {
+		if args.Header().GetUserPriority() == 42 {
+			blockingStart <- struct{}{}
+			<-blockingDone
+		}
+		return nil
+	}
+
+	// Test all four combinations of reads & writes waiting.
+	testCases := []struct {
+		cmd1Read, cmd2Read bool
+		expWait            bool
+	}{
+		// Read/read doesn't wait.
+		{true, true, false},
+		// All other combinations must wait.
+		{true, false, true},
+		{false, true, true},
+		{false, false, true},
+	}
+
+	for i, test := range testCases {
+		key1 := proto.Key(fmt.Sprintf("key1-%d", i))
+		key2 := proto.Key(fmt.Sprintf("key2-%d", i))
+		// Asynchronously put a value to the rng with blocking enabled.
+		cmd1Done := make(chan struct{})
+		go func() {
+			args := readOrWriteArgs(key1, test.cmd1Read, tc.rng.Desc().RangeID, tc.store.StoreID())
+			args.Header().UserPriority = gogoproto.Int32(42)
+
+			_, err := tc.rng.AddCmd(tc.rng.context(), args)
+
+			if err != nil {
+				t.Fatalf("test %d: %s", i, err)
+			}
+			close(cmd1Done)
+		}()
+		// Wait for cmd1 to get into the command queue.
+		<-blockingStart
+
+		// First, try a command for same key as cmd1 to verify it blocks.
+		cmd2Done := make(chan struct{})
+		go func() {
+			args := readOrWriteArgs(key1, test.cmd2Read, tc.rng.Desc().RangeID, tc.store.StoreID())
+
+			_, err := tc.rng.AddCmd(tc.rng.context(), args)
+
+			if err != nil {
+				t.Fatalf("test %d: %s", i, err)
+			}
+			close(cmd2Done)
+		}()
+
+		// Next, try read for a non-impacted key--should go through immediately.
+		cmd3Done := make(chan struct{})
+		go func() {
+			args := readOrWriteArgs(key2, true, tc.rng.Desc().RangeID, tc.store.StoreID())
+
+			_, err := tc.rng.AddCmd(tc.rng.context(), args)
+
+			if err != nil {
+				t.Fatalf("test %d: %s", i, err)
+			}
+			close(cmd3Done)
+		}()
+
+		if test.expWait {
+			// Verify cmd3 finishes but not cmd2.
+			select {
+			case <-cmd2Done:
+				t.Fatalf("test %d: should not have been able to execute cmd2", i)
+			case <-cmd3Done:
+				// success.
+			case <-cmd1Done:
+				t.Fatalf("test %d: should not have been able execute cmd1 while blocked", i)
+			case <-time.After(500 * time.Millisecond):
+				t.Fatalf("test %d: waited 500ms for cmd3 of key2", i)
+			}
+		} else {
+			select {
+			case <-cmd2Done:
+				// success.
+			case <-cmd1Done:
+				t.Fatalf("test %d: should not have been able to execute cmd1 while blocked", i)
+			case <-time.After(500 * time.Millisecond):
+				t.Fatalf("test %d: waited 500ms for cmd2 of key1", i)
+			}
+			<-cmd3Done
+		}
+
+		blockingDone <- struct{}{}
+		select {
+		case <-cmd2Done:
+			// success.
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("test %d: waited 500ms for cmd2 of key1", i)
+		}
+	}
+}
+
+// TestRangeTryAddCmd verifies that TryAddCmd fails immediately, without
+// entering the command queue, when an overlapping command is executing.
+func TestRangeTryAddCmd(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
+
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	blockingStart := make(chan struct{})
+	blockingDone := make(chan struct{})
+	TestingCommandFilter = func(args proto.Request) error {
+		if args.Header().GetUserPriority() == 42 {
+			blockingStart <- struct{}{}
+			<-blockingDone
+		}
+		return nil
+	}
+
+	key1, key2 := proto.Key("key1"), proto.Key("key2")
+	cmd1Done := make(chan error)
+	go func() {
+		args := putArgs(key1, []byte("value"), 1, tc.store.StoreID())
+		args.UserPriority = gogoproto.Int32(42)
+		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		cmd1Done <- err
+	}()
+	<-blockingStart
+
+	// Both reads and writes of the busy key fail without waiting.
+	for _, read := range []bool{true, false} {
+		args := readOrWriteArgs(key1, read, tc.rng.Desc().RangeID, tc.store.StoreID())
+		errChan := make(chan error)
+		go func() {
+			_, err := tc.rng.TryAddCmd(tc.rng.context(), args)
+			errChan <- err
+		}()
+		select {
+		case err := <-errChan:
+			if _, ok := err.(*CommandQueueBusyError); !ok {
+				t.Errorf("read=%t: expected command queue busy error; got %v", read, err)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("read=%t: TryAddCmd blocked on overlapping command", read)
+		}
+	}
+
+	// A read of a non-overlapping key goes through. (A write would be
+	// stuck behind the blocked command in Raft.)
+	args := readOrWriteArgs(key2, true, tc.rng.Desc().RangeID, tc.store.StoreID())
+	if _, err := tc.rng.TryAddCmd(tc.rng.context(), args); err != nil {
+		t.Fatal(err)
+	}
+
+	blockingDone <- struct{}{}
+	if err := <-cmd1Done; err != nil {
+		t.Fatal(err)
+	}
+	// The failed attempts left nothing behind in the command queue.
+	args = readOrWriteArgs(key1, false, tc.rng.Desc().RangeID, tc.store.StoreID())
+	if _, err := tc.rng.TryAddCmd(tc.rng.context(), args); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRangeCommandQueueInconsistent verifies that inconsistent reads need
+// not wait for pending commands to complete through Raft.
+func TestRangeCommandQueueInconsistent(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
+
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("key1")
+	blockingStart := make(chan struct{})
+	blockingDone := make(chan struct{})
+	TestingCommandFilter = func(args proto.Request) error {
+		if args.Header().CmdID.Random == 1 {
+			blockingStart <- struct{}{}
+			<-blockingDone
+		}
+		return nil
+	}
+	cmd1Done := make(chan struct{})
+	go func() {
+		args := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+		args.CmdID.Random = 1
+
+		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		close(cmd1Done)
+	}()
+	// Wait for cmd1 to get into the command queue.
+	<-blockingStart
+
+	// An inconsistent read to the key won't wait.
+	cmd2Done := make(chan struct{})
+	go func() {
+		args := getArgs(key, tc.rng.Desc().RangeID, tc.store.StoreID())
+		args.ReadConsistency = proto.INCONSISTENT
+
+		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		close(cmd2Done)
+	}()
+
+	select {
+	case <-cmd2Done:
+		// success.
+	case <-cmd1Done:
+		t.Fatalf("cmd1 should have been blocked")
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("waited 500ms for cmd2 of key")
+	}
+
+	blockingDone <- struct{}{}
+	select {
+	case <-cmd1Done:
+		// success.
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("waited 500ms for cmd2 of key")
+	}
+}
+
+// TestRangeCommandQueuePriorityInheritance verifies that a command
+// which blocks a higher-priority waiter and fails on a conflicting
+// intent pushes with the waiter's priority.
+func TestRangeCommandQueuePriorityInheritance(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	txn.Priority = 1
+	for i, test := range []struct {
+		txn         *proto.Transaction
+		waiter      int32
+		err         error
+		expPriority int32
+	}{
+		// A higher-priority waiter boosts the transaction.
+		{txn, 5, &proto.WriteIntentError{}, 5},
+		// A lower-priority waiter doesn't.
+		{txn, 0, &proto.WriteIntentError{}, 1},
+		// Only commands failing on an intent push with the boost.
+		{txn, 5, nil, 1},
+		// A non-transactional command pushes with an explicit priority.
+		{nil, 5, &proto.WriteIntentError{}, 5},
+	} {
+		args := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		args.Txn = test.txn
+		cmdKey, err := tc.rng.beginCmd(&args.RequestHeader, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		tc.rng.Lock()
+		tc.rng.cmdQ.GetWait(key, nil, false, test.waiter, &wg)
+		tc.rng.Unlock()
+		tc.rng.endCmd(cmdKey, &args, test.err, false)
+		wg.Wait()
+
+		if priority := cmdPriority(&args.RequestHeader); priority != test.expPriority {
+			t.Errorf("%d: expected push priority %d; got %d", i, test.expPriority, priority)
+		}
+	}
+	// The caller's transaction is never modified.
+	if txn.Priority != 1 {
+		t.Errorf("expected transaction priority to remain 1; got %d", txn.Priority)
+	}
+}
+
+// TestRangeUseTSCache verifies that write timestamps are upgraded
+// based on the read timestamp cache.
+func TestRangeUseTSCache(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	// Set clock to time 1s and do the read.
+	t0 := 1 * time.Second
+	tc.manualClock.Set(t0.Nanoseconds())
+	args := getArgs([]byte("a"), 1, tc.store.StoreID())
+	args.Timestamp = tc.clock.Now()
+
+	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+	if err != nil {
+		t.Error(err)
+	}
+	pArgs := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pReply := reply.(*proto.PutResponse)
+	if pReply.Timestamp.WallTime != tc.clock.Timestamp().WallTime {
+		t.Errorf("expected write timestamp to upgrade to 1s; got %s", pReply.Timestamp)
+	}
+}
+
+// TestRangeImportTSCache verifies that an imported timestamp cache,
+// including its low water mark, pushes subsequent writes and that
+// exporting it yields the imported state.
+func TestRangeImportTSCache(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	t0 := 1 * time.Second
+	tc.manualClock.Set(t0.Nanoseconds())
+	lowWater := tc.clock.Now()
+	future := lowWater.Add(int64(5*time.Second), 0)
+	imported := []proto.TimestampCacheEntry{
+		{Key: proto.Key("a"), EndKey: proto.Key("a").Next(), Timestamp: future, ReadOnly: true},
+	}
+	tc.rng.ImportTSCache(lowWater, imported)
+	if lw, exported := tc.rng.ExportTSCache(); !lw.Equal(lowWater) || !reflect.DeepEqual(exported, imported) {
+		t.Errorf("expected exported cache %s %+v; got %s %+v", lowWater, imported, lw, exported)
+	}
+
+	// A write to "a" is pushed past the imported read timestamp.
+	pArgs := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := reply.(*proto.PutResponse).Timestamp; !ts.Equal(future.Next()) {
+		t.Errorf("expected write to \"a\" to be pushed to %s; got %s", future.Next(), ts)
+	}
+
+	// A write to "b" below the low water mark is pushed past it.
+	pArgs = putArgs([]byte("b"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = proto.Timestamp{WallTime: 1}
+	reply, err = tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := reply.(*proto.PutResponse).Timestamp; !ts.Equal(lowWater.Next()) {
+		t.Errorf("expected write to \"b\" to be pushed to %s; got %s", lowWater.Next(), ts)
+	}
+}
+
+// TestRangeTxnWritePushedByTSCache verifies that a transactional write
+// pushed by a later read returns the transaction at the pushed timestamp.
+func TestRangeTxnWritePushedByTSCache(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	// Read the key at a later timestamp than the transaction's.
+	tc.manualClock.Increment(100)
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Txn = txn
+	pArgs.Timestamp = txn.Timestamp
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expTS := gArgs.Timestamp.Next()
+	replyHeader := reply.Header()
+	if !replyHeader.Timestamp.Equal(expTS) {
+		t.Errorf("expected reply timestamp %s; got %s", expTS, replyHeader.Timestamp)
+	}
+	if replyHeader.Txn == nil || !replyHeader.Txn.Timestamp.Equal(expTS) {
+		t.Fatalf("expected reply txn at timestamp %s; got %+v", expTS, replyHeader.Txn)
+	}
+	if !replyHeader.Txn.OrigTimestamp.Equal(txn.OrigTimestamp) {
+		t.Errorf("expected original timestamp %s to be kept; got %s",
+			txn.OrigTimestamp, replyHeader.Txn.OrigTimestamp)
+	}
+}
+
+// TestRangeTxnReadOwnIntent verifies that a transaction reading a key
+// it has written sees its own intent.
+func TestRangeTxnReadOwnIntent(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Txn = txn
+	pArgs.Timestamp = txn.Timestamp
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Txn = txn
+	gArgs.Timestamp = txn.Timestamp
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := reply.(*proto.GetResponse).Value; val == nil || !bytes.Equal(val.Bytes, []byte("value")) {
+		t.Errorf("expected to read own write; got %+v", val)
+	}
+}
+
+// TestRangePauseWrites verifies that pausing writes waits for writes in
+// flight and rejects new writes while reads are still served, and that
+// writes proceed again once resumed.
+func TestRangePauseWrites(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Block a write while it's being applied.
+	blockedKey := proto.Key("b")
+	applying := make(chan struct{})
+	unblock := make(chan struct{})
+	defer func() { TestingCommandFilter = nil }()
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.PutRequest); ok && args.Header().Key.Equal(blockedKey) {
+			close(applying)
+			<-unblock
+		}
+		return nil
+	}
+	writeErr := make(chan error, 1)
+	go func() {
+		pArgs := putArgs(blockedKey, []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		_, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+		writeErr <- err
+	}()
+	<-applying
+
+	// Pausing must wait for the blocked write.
+	paused := make(chan struct{})
+	go func() {
+		tc.rng.PauseWrites()
+		close(paused)
+	}()
+	select {
+	case <-paused:
+		t.Fatal("writes paused while a write was in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(unblock)
+	if err := <-writeErr; err != nil {
+		t.Fatal(err)
+	}
+	<-paused
+
+	key := proto.Key("a")
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Fatal("expected write to be rejected while paused")
+	} else if _, ok := err.(*WritesPausedError); !ok {
+		t.Fatalf("expected WritesPausedError; got %s", err)
+	}
+	gArgs := getArgs(blockedKey, 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatalf("expected read to succeed while paused; got %s", err)
+	}
+
+	tc.rng.ResumeWrites()
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatalf("expected write to succeed after resuming; got %s", err)
+	}
+}
+
+// TestRangeNoTSCacheInconsistent verifies that the timestamp cache
+// is no affected by inconsistent reads.
+func TestRangeNoTSCacheInconsistent(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	// Set clock to time 1s and do the read.
+	t0 := 1 * time.Second
+	tc.manualClock.Set(t0.Nanoseconds())
+	args := getArgs([]byte("a"), 1, tc.store.StoreID())
+	args.Timestamp = tc.clock.Now()
+	args.ReadConsistency = proto.INCONSISTENT
+
+	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+	if err != nil {
+		t.Error(err)
+	}
+	pArgs := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pReply := reply.(*proto.PutResponse)
+	if pReply.Timestamp.WallTime == tc.clock.Timestamp().WallTime {
+		t.Errorf("expected write timestamp not to upgrade to 1s; got %s", pReply.Timestamp)
+	}
+}
+
+// TestRangeNoTSCacheUpdateOnFailure verifies that read and write
+// commands do not update the timestamp cache if they result in
+// failure.
+func TestRangeNoTSCacheUpdateOnFailure(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Test for both read & write attempts.
+	for i, read := range []bool{true, false} {
+		key := proto.Key(fmt.Sprintf("key-%d", i))
+
+		// Start by laying down an intent to trip up future read or write to same key.
+		pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pArgs.Timestamp = pArgs.Txn.Timestamp
+
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+		if err != nil {
+			t.Fatalf("test %d: %s", i, err)
+		}
+		pReply := reply.(*proto.PutResponse)
+
+		// Now attempt read or write.
+		args := readOrWriteArgs(key, read, tc.rng.Desc().RangeID, tc.store.StoreID())
+		args.Header().Timestamp = tc.clock.Now() // later timestamp
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), args); err == nil {
+			t.Errorf("test %d: expected failure", i)
+		}
+
+		// Write the intent again -- should not have its timestamp upgraded!
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatalf("test %d: %s", i, err)
+		}
+		if !pReply.Timestamp.Equal(pArgs.Timestamp) {
+			t.Errorf("expected timestamp not to advance %s != %s", pReply.Timestamp, pArgs.Timestamp)
+		}
+	}
+}
+
+// TestRangeNoTimestampIncrementWithinTxn verifies that successive
+// read the write commands within the same transaction do not cause
+// the write to receive an incremented timestamp.
+func TestRangeNoTimestampIncrementWithinTxn(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Test for both read & write attempts.
+	key := proto.Key("a")
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+
+	// Start with a read to warm the timestamp cache.
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Txn = txn
+	gArgs.Timestamp = txn.Timestamp
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Now try a write and verify timestamp isn't incremented.
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Txn = txn
+	pArgs.Timestamp = pArgs.Txn.Timestamp
+
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pReply := reply.(*proto.PutResponse)
+	if !pReply.Timestamp.Equal(pArgs.Timestamp) {
+		t.Errorf("expected timestamp to remain %s; got %s", pArgs.Timestamp, pReply.Timestamp)
+	}
+
+	// Finally, try a non-transactional write and verify timestamp is incremented.
+	pArgs.Txn = nil
+	expTS := pArgs.Timestamp
+	expTS.Logical++
+
+	if reply, err = tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Errorf("expected write intent error")
+	}
+	pReply = reply.(*proto.PutResponse)
+	if !pReply.Timestamp.Equal(expTS) {
+		t.Errorf("expected timestamp to increment to %s; got %s", expTS, pReply.Timestamp)
+	}
+}
+
+// TestRangeIdempotence verifies that a retry increment with
+// same client command ID receives same reply.
+func TestRangeIdempotence(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Run the same increment 100 times, 50 with identical command IDs,
+	// interleaved with 50 using a sequence of different command IDs.
+	const numIncs = 100
+	var wg sync.WaitGroup
+	var count int64
+	incFunc := func(idx int) {
+		args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
+		args.Header().Timestamp = tc.clock.Now()
+		if idx%2 == 0 {
+			args.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
+		} else {
+			args.CmdID = proto.ClientCmdID{WallTime: 1, Random: int64(idx + 100)}
+		}
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		reply := resp.(*proto.IncrementResponse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idx%2 == 0 && reply.NewValue != 1 {
+			t.Errorf("expected all incremented values to be 1; got %d", reply.NewValue)
+		} else if idx%2 == 1 {
+			atomic.AddInt64(&count, reply.NewValue)
+		}
+		wg.Done()
+	}
+
+	wg.Add(numIncs)
+	// The assertions for the even-numbered half of this test look for a
+	// hardcoded value of 1, so we have to make sure the first iteration
+	// that actually runs is even-numbered so that the cached response
+	// for that command ID has a value of 1. Otherwise, N odd-numbered
+	// operations may get scheduled first, run with a different command
+	// ID, and cause even-numbered operations to report something other
+	// than 1, depending on the value of N.
+	incFunc(0)
+	for i := 1; i < numIncs; i++ {
+		go incFunc(i)
+	}
+	// Wait for all to complete.
+	wg.Wait()
+
+	// Verify that all non-repeated client commands incremented the
+	// counter starting at 2 all the way to 51 (sum of sequence = 1325).
+	if count != 1325 {
+		t.Errorf("expected sum of all increments to be 1325; got %d", count)
+	}
+}
+
+// TestRangeResponseCacheReadError verifies that an error is returned to the
+// client in the event that a response cache entry is found but is not
+// decodable.
+func TestRangeResponseCacheReadError(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
+	args.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
+
+	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite repsonse cache entry with garbage for the last op.
+	key := keys.ResponseCacheKey(tc.rng.Desc().RangeID, &args.CmdID)
+	err = engine.MVCCPut(tc.engine, nil, key, proto.ZeroTimestamp, proto.Value{Bytes: []byte("\xff")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Now try increment again and verify error.
+	_, err = tc.rng.AddCmd(tc.rng.context(), &args)
+	if err == nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRangeResponseCacheStoredError verifies that if a cached entry contains
+// an error, that error is returned.
+func TestRangeResponseCacheStoredError(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	cmdID := proto.ClientCmdID{WallTime: 1, Random: 1}
+	// Write an error into the response cache.
+	pastReply := proto.IncrementResponse{}
+	pastError := errors.New("boom")
+	var expError error = &proto.Error{Message: pastError.Error()}
+	_ = tc.rng.respCache.PutResponse(tc.engine, cmdID,
+		proto.ResponseWithError{Reply: &pastReply, Err: pastError})
+
+	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
+	args.CmdID = cmdID
+	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	if err == nil {
+		t.Fatal("expected to see cached error but got nil")
+	} else if ge, ok := err.(*proto.Error); !ok {
+		t.Fatalf("expected proto.Error but got %s", err)
+	} else if !reflect.DeepEqual(ge, expError) {
+		t.Fatalf("expected <%T> %+v but got <%T> %+v", expError, expError, ge, ge)
+	}
+}
+
+// TestRangeTraceRequests verifies that the execution of reads and writes
+// is recorded in the request's trace along with method and key span.
+func TestRangeTraceRequests(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	txn := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+	trace := tracer.NewTracer(nil, "test").NewTrace(txn)
+	ctx := tracer.ToCtx(tc.rng.context(), trace)
+
+	pArgs := putArgs(proto.Key("a"), []byte("value"), 1, tc.store.StoreID())
+	sArgs := scanArgs(proto.Key("a"), proto.Key("c"), 1, tc.store.StoreID())
+	for _, args := range []proto.Request{&pArgs, &sArgs} {
+		if _, err := tc.rng.AddCmd(ctx, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, args := range []proto.Request{&pArgs, &sArgs} {
+		header := args.Header()
+		expName := fmt.Sprintf("executing %s [%s,%s)", args.Method(), header.Key, header.EndKey)
+		found := false
+		for _, item := range trace.Content {
+			if item.Name == expName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected trace event %q in trace:\n%s", expName, trace)
+		}
+	}
+}
+
+// TestRangeResponseCacheDisabled verifies that no response cache entries
+// are written when the response cache is disabled, and that a retried
+// write consequently executes again.
+func TestRangeResponseCacheDisabled(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	tc.rng.SetResponseCacheDisabled(true)
+
+	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
+	args.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
+	for i := int64(1); i <= 2; i++ {
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nv := resp.(*proto.IncrementResponse).NewValue; nv != i {
+			t.Errorf("%d: expected incremented value %d; got %d", i, i, nv)
+		}
+	}
+
+	replyWithErr, err := tc.rng.respCache.GetResponse(tc.engine, args.CmdID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replyWithErr.Reply != nil {
+		t.Errorf("expected no response cache entry; got %+v", replyWithErr)
+	}
+}
+
+// TestRangeResponseCacheDisabledByProposer verifies that whether a
+// command uses the response cache is decided by its proposer and not by
+// the replica applying it, so that all replicas apply it the same way.
+func TestRangeResponseCacheDisabledByProposer(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Acquire the leader lease.
+	pArgs := putArgs([]byte("b"), []byte("value"), 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	// The applying replica's own setting is ignored.
+	tc.rng.SetResponseCacheDisabled(true)
+
+	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
+	args.CmdID = proto.ClientCmdID{WallTime: 1, Random: 1}
+	args.Timestamp = tc.clock.Now()
+	for _, skip := range []bool{false, true} {
+		raftCmd := proto.RaftCommand{
+			RangeID:           1,
+			OriginNodeID:      tc.store.RaftNodeID(),
+			SkipResponseCache: skip,
+		}
+		raftCmd.Cmd.SetValue(&args)
+		index := atomic.LoadUint64(&tc.rng.appliedIndex) + 1
+		ms := engine.MVCCStats{}
+		batch, _, err := tc.rng.applyRaftCommandInBatch(tc.rng.context(), index, raftCmd, &args, &ms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replyWithErr, err := tc.rng.respCache.GetResponse(batch, args.CmdID)
+		batch.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached := replyWithErr.Reply != nil; cached == skip {
+			t.Errorf("skip=%t: expected response cache entry %t; got %t", skip, !skip, cached)
+		}
+	}
+}
+
+// TestEndTransactionWithMalformedSplitTrigger verifies an
+// EndTransaction call with a malformed commit trigger fails.
+func TestEndTransactionWithMalformedSplitTrigger(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("foo")
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	pArgs := putArgs(key, []byte("only here to make this a rw transaction"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	pArgs.Txn = txn
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	args := endTxnArgs(txn, true /* commit */, 1, tc.store.StoreID())
+	args.Timestamp = txn.Timestamp
+	// Make an EndTransaction request which would fail if not
+	// stripped. In this case, we set the start key to "bar" for a
+	// split of the default range; start key must be "" in this case.
+	args.InternalCommitTrigger = &proto.InternalCommitTrigger{
+		SplitTrigger: &proto.SplitTrigger{
+			UpdatedDesc: proto.RangeDescriptor{StartKey: proto.Key("bar")},
+		},
+	}
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); !testutils.IsError(err, "range does not match splits") {
+		t.Errorf("expected range does not match splits error; got %s", err)
+	}
+}
+
+// TestEndTransactionBeforeHeartbeat verifies that a transaction
+// can be committed/aborted before being heartbeat.
+func TestEndTransactionBeforeHeartbeat(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	// Don't automatically GC the Txn record: We want to heartbeat the
+	// committed Transaction and compare it against our expectations.
+	// When it's removed, the heartbeat would recreate it.
+	defer withoutTxnAutoGC()()
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := []byte("a")
+	for _, commit := range []bool{true, false} {
+		txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		args := endTxnArgs(txn, commit, 1, tc.store.StoreID())
+		args.Timestamp = txn.Timestamp
+
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		if err != nil {
+			t.Error(err)
+		}
+		reply := resp.(*proto.EndTransactionResponse)
+		expStatus := proto.COMMITTED
+		if !commit {
+			expStatus = proto.ABORTED
+		}
+		if reply.Txn.Status != expStatus {
+			t.Errorf("expected transaction status to be %s; got %s", expStatus, reply.Txn.Status)
+		}
+
+		// Try a heartbeat to the already-committed transaction; should get
+		// committed txn back, but without last heartbeat timestamp set.
+		hbArgs := heartbeatArgs(txn, 1, tc.store.StoreID())
+
+		resp, err = tc.rng.AddCmd(tc.rng.context(), &hbArgs)
+		if err != nil {
+			t.Error(err)
+		}
+		hbReply := resp.(*proto.HeartbeatTxnResponse)
+		if hbReply.Txn.Status != expStatus || hbReply.Txn.LastHeartbeat != nil {
+			t.Errorf("unexpected heartbeat reply contents: %+v", hbReply)
+		}
+	}
+}
+
+// TestEndTransactionAfterHeartbeat verifies that a transaction
+// can be committed/aborted after being heartbeat.
+func TestEndTransactionAfterHeartbeat(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := []byte("a")
+	for _, commit := range []bool{true, false} {
+		txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+
+		// Start out with a heartbeat to the transaction.
+		hbArgs := heartbeatArgs(txn, 1, tc.store.StoreID())
+		hbArgs.Timestamp = txn.Timestamp
+
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs)
+		if err != nil {
+			t.Error(err)
+		}
+		hbReply := resp.(*proto.HeartbeatTxnResponse)
+		if hbReply.Txn.Status != proto.PENDING || hbReply.Txn.LastHeartbeat == nil {
+			t.Errorf("unexpected heartbeat reply contents: %+v", hbReply)
+		}
+
+		args := endTxnArgs(txn, commit, 1, tc.store.StoreID())
+		args.Timestamp = txn.Timestamp
+
+		resp, err = tc.rng.AddCmd(tc.rng.context(), &args)
+		if err != nil {
+			t.Error(err)
+		}
+		reply := resp.(*proto.EndTransactionResponse)
+		expStatus := proto.COMMITTED
+		if !commit {
+			expStatus = proto.ABORTED
+		}
+		if reply.Txn.Status != expStatus {
+			t.Errorf("expected transaction status to be %s; got %s", expStatus, reply.Txn.Status)
+		}
+		if reply.Txn.LastHeartbeat == nil || !reply.Txn.LastHeartbeat.Equal(*hbReply.Txn.LastHeartbeat) {
+			t.Errorf("expected heartbeats to remain equal: %+v != %+v",
+				reply.Txn.LastHeartbeat, hbReply.Txn.LastHeartbeat)
+		}
+	}
+}
+
+// TestBatchHeartbeatTxn verifies that a single HeartbeatTxn command can
+// heartbeat several transactions, updating and returning each of their
+// records.
+func TestBatchHeartbeatTxn(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	var txns []proto.Transaction
+	for _, key := range []string{"a", "b", "c"} {
+		txns = append(txns, *newTransaction("test", proto.Key(key), 1, proto.SERIALIZABLE, tc.clock))
+	}
+	// Commit the last transaction; its record must not be heartbeat.
+	etArgs := endTxnArgs(&txns[2], true, 1, tc.store.StoreID())
+	etArgs.Timestamp = txns[2].Timestamp
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &etArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	hbArgs := proto.HeartbeatTxnRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       proto.Key("a"),
+			EndKey:    proto.Key("d"),
+			RangeID:   1,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Timestamp: tc.clock.Now(),
+		},
+		Txns: txns,
+	}
+	resp, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hbReply := resp.(*proto.HeartbeatTxnResponse)
+	if len(hbReply.Txns) != len(txns) {
+		t.Fatalf("expected %d transactions; got %d", len(txns), len(hbReply.Txns))
+	}
+	for i, txn := range txns {
+		var record proto.Transaction
+		if ok, err := engine.MVCCGetProto(tc.engine, keys.TransactionKey(txn.Key, txn.ID),
+			proto.ZeroTimestamp, true, nil, &record); err != nil || !ok {
+			t.Fatalf("%d: failed to read transaction record: %t, %v", i, ok, err)
+		}
+		if reply := hbReply.Txns[i]; !bytes.Equal(reply.ID, record.ID) || reply.Status != record.Status ||
+			!reflect.DeepEqual(reply.LastHeartbeat, record.LastHeartbeat) {
+			t.Errorf("%d: expected reply %+v to match record %+v", i, reply, record)
+		}
+		if i < 2 {
+			if record.Status != proto.PENDING || record.LastHeartbeat == nil ||
+				!record.LastHeartbeat.Equal(hbArgs.Timestamp) {
+				t.Errorf("%d: expected pending record heartbeat at %s; got %+v", i, hbArgs.Timestamp, record)
+			}
+		} else if record.Status != proto.COMMITTED || record.LastHeartbeat != nil {
+			t.Errorf("%d: expected committed record without heartbeat; got %+v", i, record)
+		}
+	}
+
+	// Transactions outside of the request's span are refused.
+	hbArgs.EndKey = proto.Key("c")
+	hbArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs); !testutils.IsError(err, "outside of request span") {
+		t.Errorf("expected error for transaction outside of request span; got %v", err)
+	}
+}
+
+// TestEndTransactionWithPushedTimestamp verifies that txn can be
+// ended (both commit or abort) correctly when the commit timestamp is
+// greater than the transaction timestamp, depending on the isolation
+// level.
+func TestEndTransactionWithPushedTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	testCases := []struct {
+		commit    bool
+		isolation proto.IsolationType
+		expErr    bool
+	}{
+		{true, proto.SERIALIZABLE, true},
+		{true, proto.SNAPSHOT, false},
+		{false, proto.SERIALIZABLE, false},
+		{false, proto.SNAPSHOT, false},
+	}
+	key := []byte("a")
+	for _, test := range testCases {
+		txn := newTransaction("test", key, 1, test.isolation, tc.clock)
+		// End the transaction with args timestamp moved forward in time.
+		args := endTxnArgs(txn, test.commit, 1, tc.store.StoreID())
+		tc.manualClock.Set(1)
+		args.Timestamp = tc.clock.Now()
+
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+		if test.expErr {
+			if err == nil {
+				t.Errorf("expected error")
+			}
+			if _, ok := err.(*proto.TransactionRetryError); !ok {
+				t.Errorf("expected retry error; got %s", err)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			expStatus := proto.COMMITTED
+			if !test.commit {
+				expStatus = proto.ABORTED
+			}
+			reply := resp.(*proto.EndTransactionResponse)
+			if reply.Txn.Status != expStatus {
+				t.Errorf("expected transaction status to be %s; got %s", expStatus, reply.Txn.Status)
+			}
+		}
+	}
+}
+
+// TestEndTransactionWithIncrementedEpoch verifies that txn ended with
+// a higher epoch (and priority) correctly assumes the higher epoch.
+func TestEndTransactionWithIncrementedEpoch(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := []byte("a")
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+
+	// Start out with a heartbeat to the transaction.
+	hbArgs := heartbeatArgs(txn, 1, tc.store.StoreID())
+	hbArgs.Timestamp = txn.Timestamp
+
+	_, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Now end the txn with increased epoch and priority.
+	args := endTxnArgs(txn, true, 1, tc.store.StoreID())
+	args.Timestamp = txn.Timestamp
+	args.Txn.Epoch = txn.Epoch + 1
+	args.Txn.Priority = txn.Priority + 1
+
+	resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	if err != nil {
+		t.Error(err)
+	}
+	reply := resp.(*proto.EndTransactionResponse)
+	if reply.Txn.Status != proto.COMMITTED {
+		t.Errorf("expected transaction status to be COMMITTED; got %s", reply.Txn.Status)
+	}
+	if reply.Txn.Epoch != txn.Epoch {
+		t.Errorf("expected epoch to equal %d; got %d", txn.Epoch, reply.Txn.Epoch)
+	}
+	if reply.Txn.Priority != txn.Priority {
+		t.Errorf("expected priority to equal %d; got %d", txn.Priority, reply.Txn.Priority)
+	}
+}
+
+// TestEndTransactionWithErrors verifies various error conditions
+// are checked such as transaction already being committed or
+// aborted, or timestamp or epoch regression.
+func TestEndTransactionWithErrors(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	regressTS := tc.clock.Now()
+	tc.manualClock.Set(1)
+	txn := newTransaction("test", proto.Key(""), 1, proto.SERIALIZABLE, tc.clock)
+
+	testCases := []struct {
+		key          proto.Key
+		existStatus  proto.TransactionStatus
+		existEpoch   int32
+		existTS      proto.Timestamp
+		expErrRegexp string
+	}{
+		{proto.Key("a"), proto.COMMITTED, txn.Epoch, txn.Timestamp, "txn \"test\" id=.*: already committed"},
+		{proto.Key("b"), proto.ABORTED, txn.Epoch, txn.Timestamp, "txn aborted \"test\" id=.*"},
+		{proto.Key("c"), proto.PENDING, txn.Epoch + 1, txn.Timestamp, "txn \"test\" id=.*: epoch regression: 0"},
+		{proto.Key("d"), proto.PENDING, txn.Epoch, regressTS, "txn \"test\" id=.*: timestamp regression: 0.000000001,0"},
+	}
+	for _, test := range testCases {
+		// Establish existing txn state by writing directly to range engine.
+		var existTxn proto.Transaction
+		gogoproto.Merge(&existTxn, txn)
+		existTxn.Key = test.key
+		existTxn.Status = test.existStatus
+		existTxn.Epoch = test.existEpoch
+		existTxn.Timestamp = test.existTS
+		txnKey := keys.TransactionKey(test.key, txn.ID)
+		if err := engine.MVCCPutProto(tc.rng.rm.Engine(), nil, txnKey, proto.ZeroTimestamp,
+			nil, &existTxn); err != nil {
+			t.Fatal(err)
+		}
+
+		// End the transaction, verify expected error.
+		txn.Key = test.key
+		args := endTxnArgs(txn, true, 1, tc.store.StoreID())
+		args.Timestamp = txn.Timestamp
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); !testutils.IsError(err, test.expErrRegexp) {
+			t.Errorf("expected %s to match %s", err, test.expErrRegexp)
+		}
+	}
+}
+
+// TestEndTransactionGC verifies that a transaction record is immediately
+// garbage-collected upon EndTransaction iff all of the supplied intents are
+// local relative to the transaction record's location.
+func TestEndTransactionGC(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	splitKey := proto.Key("c")
+	splitTestRange(tc.store, splitKey, splitKey, t)
+	for i, test := range []struct {
+		intents []proto.Intent
+		expGC   bool
+	}{
+		// Range inside.
+		{[]proto.Intent{{Key: proto.Key("a"), EndKey: proto.Key("b")}}, true},
+		// Two intents inside.
+		{[]proto.Intent{{Key: proto.Key("a")}, {Key: proto.Key("b")}}, true},
+		// Intent range spilling over right endpoint.
+		{[]proto.Intent{{Key: proto.Key("a"), EndKey: splitKey.Next()}}, false},
+		// Intent range completely outside.
+		{[]proto.Intent{{Key: splitKey, EndKey: proto.Key("q")}}, false},
+		// Intent inside and outside.
+		{[]proto.Intent{{Key: proto.Key("a")}, {Key: splitKey}}, false},
+	} {
+		txn := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+		args := endTxnArgs(txn, true, 1, tc.store.StoreID())
+		args.Timestamp = txn.Timestamp
+		args.Intents = test.intents
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+			t.Fatal(err)
+		}
+		var readTxn proto.Transaction
+		txnKey := keys.TransactionKey(txn.Key, txn.ID)
+		ok, err := engine.MVCCGetProto(tc.rng.rm.Engine(), txnKey, proto.ZeroTimestamp,
+			true /* consistent */, nil /* txn */, &readTxn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok != test.expGC {
+			t.Errorf("%d: unexpected gc'ed: %t", i, !ok)
+		}
+	}
+}
+
+// TestEndTransactionResolveOnlyLocalIntents verifies that an end transaction
+// request resolves only local intents.
+func TestEndTransactionResolveOnlyLocalIntents(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Split the range and create an intent in each range.
+	// The keys of the two intents are next to each other.
+	key := proto.Key("a")
+	splitKey := key.Next()
+	newRng := splitTestRange(tc.store, splitKey, splitKey, t)
+
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	pArgs := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	pArgs.Txn = txn
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	pArgs = putArgs(splitKey, []byte("value"), newRng.Desc().RangeID, tc.store.StoreID())
+	pArgs.Txn = txn
+	if _, err := newRng.AddCmd(newRng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// End the transaction and resolve the intents.
+	args := endTxnArgs(txn, true /* commit */, 1, tc.store.StoreID())
+	args.Timestamp = txn.Timestamp
+	args.Intents = []proto.Intent{{Key: key, EndKey: splitKey.Next()}}
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check if the intent in the other range has not yet been resolved.
+	gArgs := getArgs(splitKey, newRng.Desc().RangeID, tc.store.StoreID())
+	_, err := newRng.AddCmd(newRng.context(), &gArgs)
+	if _, ok := err.(*proto.WriteIntentError); !ok {
+		t.Errorf("expected write intent error, but got %s", err)
+	}
+}
+
+// TestPushTxnBadKey verifies that args.Key equals args.PusheeTxn.ID.
+func TestPushTxnBadKey(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	pusher := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+	pushee := newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
+
+	args := pushTxnArgs(pusher, pushee, proto.ABORT_TXN, 1, tc.store.StoreID())
+	args.Key = pusher.Key
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &args); !testutils.IsError(err, ".*should match pushee.*") {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+// TestPushTxnAlreadyCommittedOrAborted verifies success
+// (noop) in event that pushee is already committed or aborted.
+func TestPushTxnAlreadyCommittedOrAborted(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	// This test simulates running into an open intent and resolving it
+	// using the Txn table. If we auto-gc'ed entries here, the entry would
+	// be deleted and the intents resolved instantaneously on successful
+	// commit (since they're on the same Range). Could split the range and have
+	// non-local intents if we ever wanted to get rid of this.
+	defer withoutTxnAutoGC()()
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	for i, status := range []proto.TransactionStatus{proto.COMMITTED, proto.ABORTED} {
+		key := proto.Key(fmt.Sprintf("key-%d", i))
+		pusher := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pushee := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pusher.Priority = 1
+		pushee.Priority = 2 // pusher will lose, meaning we shouldn't push unless pushee is already ended.
+
+		// End the pushee's transaction.
+		etArgs := endTxnArgs(pushee, status == proto.COMMITTED, 1, tc.store.StoreID())
+		etArgs.Timestamp = pushee.Timestamp
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &etArgs); err != nil {
+			t.Fatal(err)
+		}
+
+		// Now try to push what's already committed or aborted.
+		args := pushTxnArgs(pusher, pushee, proto.ABORT_TXN, 1, tc.store.StoreID())
+
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := resp.(*proto.PushTxnResponse)
+		if reply.PusheeTxn.Status != status {
+			t.Errorf("expected push txn to return with status == %s; got %+v", status, reply.PusheeTxn)
+		}
+	}
+}
+
+// TestPushTxnUpgradeExistingTxn verifies that pushing
+// a transaction record with a new epoch upgrades the pushee's
+// epoch and timestamp if greater. In all test cases, the
+// priorities are set such that the push will succeed.
+func TestPushTxnUpgradeExistingTxn(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	ts1 := proto.Timestamp{WallTime: 1}
+	ts2 := proto.Timestamp{WallTime: 2}
+	testCases := []struct {
+		startEpoch, epoch, expEpoch int32
+		startTS, ts, expTS          proto.Timestamp
+	}{
+		// Move epoch forward.
+		{0, 1, 1, ts1, ts1, ts1},
+		// Move timestamp forward.
+		{0, 0, 0, ts1, ts2, ts2},
+		// Move epoch backwards (has no effect).
+		{1, 0, 1, ts1, ts1, ts1},
+		// Move timestamp backwards (has no effect).
+		{0, 0, 0, ts2, ts1, ts2},
+		// Move both epoch & timestamp forward.
+		{0, 1, 1, ts1, ts2, ts2},
+		// Move both epoch & timestamp backward (has no effect).
+		{1, 0, 1, ts2, ts1, ts2},
+	}
+
+	for i, test := range testCases {
+		key := proto.Key(fmt.Sprintf("key-%d", i))
+		pusher := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pushee := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pushee.Priority = 1
+		pusher.Priority = 2 // Pusher will win.
+
+		// First, establish "start" of existing pushee's txn via heartbeat.
+		pushee.Epoch = test.startEpoch
+		pushee.Timestamp = test.startTS
+		hbArgs := heartbeatArgs(pushee, 1, tc.store.StoreID())
+		hbArgs.Timestamp = pushee.Timestamp
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs); err != nil {
+			t.Fatal(err)
+		}
+
+		// Now, attempt to push the transaction using updated values for epoch & timestamp.
+		pushee.Epoch = test.epoch
+		pushee.Timestamp = test.ts
+		args := pushTxnArgs(pusher, pushee, proto.ABORT_TXN, 1, tc.store.StoreID())
+
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := resp.(*proto.PushTxnResponse)
+		expTxn := gogoproto.Clone(pushee).(*proto.Transaction)
+		expTxn.Epoch = test.expEpoch
+		expTxn.Timestamp = test.expTS
+		expTxn.Status = proto.ABORTED
+		expTxn.LastHeartbeat = &test.startTS
+		expTxn.Writing = true // always set for physical txn table entries
+
+		if !reflect.DeepEqual(expTxn, reply.PusheeTxn) {
+			t.Errorf("unexpected push txn in trial %d; expected %+v, got %+v", i, expTxn, reply.PusheeTxn)
+		}
+	}
+}
+
+// TestPushTxnHeartbeatTimeout verifies that a txn which
+// hasn't been heartbeat within 2x the heartbeat interval can be
+// pushed/aborted.
+func TestPushTxnHeartbeatTimeout(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	ts := proto.Timestamp{WallTime: 1}
+	ns := DefaultHeartbeatInterval.Nanoseconds()
+	testCases := []struct {
+		heartbeat   *proto.Timestamp // nil indicates no heartbeat
+		currentTime int64            // nanoseconds
+		pushType    proto.PushTxnType
+		expSuccess  bool
+	}{
+		{nil, 1, proto.PUSH_TIMESTAMP, false}, // using 0 as time is awkward
+		{nil, 1, proto.ABORT_TXN, false},
+		{nil, 1, proto.CLEANUP_TXN, false},
+		{nil, ns, proto.PUSH_TIMESTAMP, false},
+		{nil, ns, proto.ABORT_TXN, false},
+		{nil, ns, proto.CLEANUP_TXN, false},
+		{nil, ns*2 - 1, proto.PUSH_TIMESTAMP, false},
+		{nil, ns*2 - 1, proto.ABORT_TXN, false},
+		{nil, ns*2 - 1, proto.CLEANUP_TXN, false},
+		{nil, ns * 2, proto.PUSH_TIMESTAMP, false},
+		{nil, ns * 2, proto.ABORT_TXN, false},
+		{nil, ns * 2, proto.CLEANUP_TXN, false},
+		{&ts, ns*2 + 1, proto.PUSH_TIMESTAMP, false},
+		{&ts, ns*2 + 1, proto.ABORT_TXN, false},
+		{&ts, ns*2 + 1, proto.CLEANUP_TXN, false},
+		{&ts, ns*2 + 2, proto.PUSH_TIMESTAMP, true},
+		{&ts, ns*2 + 2, proto.ABORT_TXN, true},
+		{&ts, ns*2 + 2, proto.CLEANUP_TXN, true},
+	}
+
+	for i, test := range testCases {
+		key := proto.Key(fmt.Sprintf("key-%d", i))
+		pushee := newTransaction(fmt.Sprintf("test-%d", i), key, 1, proto.SERIALIZABLE, tc.clock)
+		pusher := newTransaction("pusher", key, 1, proto.SERIALIZABLE, tc.clock)
+		pushee.Priority = 2
+		pusher.Priority = 1 // Pusher won't win based on priority.
+
+		// First, establish "start" of existing pushee's txn via heartbeat.
+		if test.heartbeat != nil {
+			hbArgs := heartbeatArgs(pushee, 1, tc.store.StoreID())
+			hbArgs.Timestamp = *test.heartbeat
+
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Now, attempt to push the transaction with clock set to "currentTime".
+		tc.manualClock.Set(test.currentTime)
+		args := pushTxnArgs(pusher, pushee, test.pushType, 1, tc.store.StoreID())
+		// Avoid logical ticks here, they make the borderline cases hard to
+		// test.
+		args.Timestamp = proto.Timestamp{WallTime: test.currentTime}
+		args.Now = args.Timestamp
+		args.Timestamp.Logical = 0
+
+		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+		if test.expSuccess != (err == nil) {
+			t.Errorf("expected success on trial %d? %t; got err %s", i, test.expSuccess, err)
+		}
+		if err != nil {
+			if _, ok := err.(*proto.TransactionPushError); !ok {
+				t.Errorf("expected txn push error: %s", err)
+			}
+		}
+	}
+}
+
+// TestPushTxnPriorities verifies that txns with lower
+// priority are pushed; if priorities are equal, then the txns
+// are ordered by txn timestamp, with the more recent timestamp
+// being pushable.
+func TestPushTxnPriorities(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	ts1 := proto.Timestamp{WallTime: 1}
+	ts2 := proto.Timestamp{WallTime: 2}
+	testCases := []struct {
+		pusherPriority, pusheePriority int32
+		pusherTS, pusheeTS             proto.Timestamp
+		pushType                       proto.PushTxnType
+		expSuccess                     bool
+	}{
+		// Pusher has higher priority succeeds.
+		{2, 1, ts1, ts1, proto.ABORT_TXN, true},
+		// Pusher has lower priority fails.
+		{1, 2, ts1, ts1, proto.ABORT_TXN, false},
+		{1, 2, ts1, ts1, proto.PUSH_TIMESTAMP, false},
+		// Pusher has lower priority fails, even with older txn timestamp.
+		{1, 2, ts1, ts2, proto.ABORT_TXN, false},
+		// Pusher has lower priority, but older txn timestamp allows success if !abort.
+		{1, 2, ts1, ts2, proto.PUSH_TIMESTAMP, true},
+		// With same priorities, older txn timestamp succeeds.
+		{1, 1, ts1, ts2, proto.ABORT_TXN, true},
+		// With same priorities, same txn timestamp fails.
+		{1, 1, ts1, ts1, proto.ABORT_TXN, false},
+		{1, 1, ts1, ts1, proto.PUSH_TIMESTAMP, false},
+		// With same priorities, newer txn timestamp fails.
+		{1, 1, ts2, ts1, proto.ABORT_TXN, false},
+		{1, 1, ts2, ts1, proto.PUSH_TIMESTAMP, false},
+		// When confirming, priority never wins.
+		{2, 1, ts1, ts1, proto.CLEANUP_TXN, false},
+		{1, 2, ts1, ts1, proto.CLEANUP_TXN, false},
+	}
+
+	for i, test := range testCases {
+		key := proto.Key(fmt.Sprintf("key-%d", i))
+		pusher := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pushee := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+		pusher.Priority = test.pusherPriority
+		pushee.Priority = test.pusheePriority
+		pusher.Timestamp = test.pusherTS
+		pushee.Timestamp = test.pusheeTS
+
+		// Now, attempt to push the transaction with intent epoch set appropriately.
+		args := pushTxnArgs(pusher, pushee, test.pushType, 1, tc.store.StoreID())
+
+		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+		if test.expSuccess != (err == nil) {
+			t.Errorf("expected success on trial %d? %t; got err %s", i, test.expSuccess, err)
+		}
+		if err != nil {
+			if _, ok := err.(*proto.TransactionPushError); !ok {
+				t.Errorf("expected txn push error: %s", err)
+			}
+		}
+	}
+}
+
+// TestPushTxnPushTimestamp verifies that with args.Abort is
+// false (i.e. for read/write conflict), the pushed txn keeps status
+// PENDING, but has its txn Timestamp moved forward to the pusher's
+// txn Timestamp + 1.
+func TestPushTxnPushTimestamp(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	pusher := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+	pushee := newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
+	pusher.Priority = 2
+	pushee.Priority = 1 // pusher will win
+	pusher.Timestamp = proto.Timestamp{WallTime: 50, Logical: 25}
+	pushee.Timestamp = proto.Timestamp{WallTime: 5, Logical: 1}
+
+	// Now, push the transaction with args.Abort=false.
+	args := pushTxnArgs(pusher, pushee, proto.PUSH_TIMESTAMP, 1, tc.store.StoreID())
+
+	resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	if err != nil {
+		t.Errorf("unexpected error on push: %s", err)
+	}
+	expTS := pusher.Timestamp
+	expTS.Logical++
+	reply := resp.(*proto.PushTxnResponse)
+	if !reply.PusheeTxn.Timestamp.Equal(expTS) {
+		t.Errorf("expected timestamp to be pushed to %+v; got %+v", expTS, reply.PusheeTxn.Timestamp)
+	}
+	if reply.PusheeTxn.Status != proto.PENDING {
+		t.Errorf("expected pushed txn to have status PENDING; got %s", reply.PusheeTxn.Status)
+	}
+}
+
+// TestPushTxnPushTimestampAlreadyPushed verifies that pushing
+// a timestamp forward which is already far enough forward is a simple
+// noop. We do this by ensuring that priorities would otherwise make
+// pushing impossible.
+func TestPushTxnPushTimestampAlreadyPushed(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	pusher := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+	pushee := newTransaction("test", proto.Key("b"), 1, proto.SERIALIZABLE, tc.clock)
+	pusher.Priority = 1
+	pushee.Priority = 2 // pusher will lose
+	pusher.Timestamp = proto.Timestamp{WallTime: 50, Logical: 0}
+	pushee.Timestamp = proto.Timestamp{WallTime: 50, Logical: 1}
+
+	// Now, push the transaction with args.Abort=false.
+	args := pushTxnArgs(pusher, pushee, proto.PUSH_TIMESTAMP, 1, tc.store.StoreID())
+
+	resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	if err != nil {
+		t.Errorf("unexpected error on push: %s", err)
+	}
+	reply := resp.(*proto.PushTxnResponse)
+	if !reply.PusheeTxn.Timestamp.Equal(pushee.Timestamp) {
+		t.Errorf("expected timestamp to be equal to original %+v; got %+v", pushee.Timestamp, reply.PusheeTxn.Timestamp)
+	}
+	if reply.PusheeTxn.Status != proto.PENDING {
+		t.Errorf("expected pushed txn to have status PENDING; got %s", reply.PusheeTxn.Status)
+	}
+}
+
+// TestRangeResolveIntentRange verifies resolving a range of intents.
+func TestRangeResolveIntentRange(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Put two values transactionally.
+	txn := &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: tc.clock.Now()}
+	for _, key := range []proto.Key{proto.Key("a"), proto.Key("b")} {
+		pArgs := putArgs(key, []byte("value1"), 1, tc.store.StoreID())
+		pArgs.Txn = txn
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Resolve the intents.
+	rArgs := &proto.ResolveIntentRangeRequest{
+		RequestHeader: proto.RequestHeader{
+			Timestamp: txn.Timestamp,
+			Key:       proto.Key("a"),
+			EndKey:    proto.Key("c"),
+			RangeID:   tc.rng.Desc().RangeID,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Txn:       txn,
+		},
+	}
+	rArgs.Txn.Status = proto.COMMITTED
+	if _, err := tc.rng.AddCmd(tc.rng.context(), rArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Do a consistent scan to verify intents have been cleared.
+	sArgs := scanArgs(proto.Key("a"), proto.Key("c"), 1, tc.store.StoreID())
+	reply, err := tc.store.ExecuteCmd(context.Background(), &sArgs)
+	if err != nil {
+		t.Fatalf("unexpected error on scan: %s", err)
+	}
+	sReply := reply.(*proto.ScanResponse)
+	if len(sReply.Rows) != 2 {
+		t.Errorf("expected 2 rows; got %v", sReply.Rows)
+	}
+}
+
+// TestBatchResolveRequests verifies that a large number of intent
+// resolutions is split into batches bounded by request count and size.
+func TestBatchResolveRequests(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	txn := &proto.Transaction{ID: uuid.NewUUID4(), Status: proto.COMMITTED}
+	const numIntents = 5000
+	var reqs []proto.Request
+	for i := 0; i < numIntents; i++ {
+		reqs = append(reqs, &proto.ResolveIntentRequest{
+			RequestHeader: proto.RequestHeader{
+				Key: proto.Key(fmt.Sprintf("key-%05d", i)),
+				Txn: txn,
+			},
+		})
+	}
+	reqSize := gogoproto.Size(reqs[0])
+
+	testCases := []struct {
+		maxCount, maxBytes, expBatches int
+	}{
+		{100, math.MaxInt32, numIntents / 100},
+		{math.MaxInt32, 50 * reqSize, numIntents / 50},
+		{30, 50 * reqSize, (numIntents + 29) / 30},
+		// Requests exceeding the size limit by themselves get their own batch.
+		{100, 1, numIntents},
+	}
+	for i, test := range testCases {
+		batches := batchResolveRequests(reqs, test.maxCount, test.maxBytes)
+		if len(batches) != test.expBatches {
+			t.Errorf("%d: expected %d batches; got %d", i, test.expBatches, len(batches))
+		}
+		var count int
+		for j, bArgs := range batches {
+			if l := len(bArgs.Requests); l > test.maxCount {
+				t.Errorf("%d.%d: batch of %d requests exceeds limit of %d", i, j, l, test.maxCount)
+			}
+			var size int
+			for _, union := range bArgs.Requests {
+				args := union.GetValue().(proto.Request)
+				if !args.Header().Key.Equal(reqs[count].Header().Key) {
+					t.Fatalf("%d.%d: expected key %s; got %s", i, j, reqs[count].Header().Key, args.Header().Key)
+				}
+				size += gogoproto.Size(args)
+				count++
+			}
+			if size > test.maxBytes && len(bArgs.Requests) > 1 {
+				t.Errorf("%d.%d: batch of %d bytes exceeds limit of %d", i, j, size, test.maxBytes)
+			}
+		}
+		if count != numIntents {
+			t.Errorf("%d: expected %d requests in batches; got %d", i, numIntents, count)
+		}
+	}
+}
+
+func verifyRangeStats(eng engine.Engine, rangeID proto.RangeID, expMS engine.MVCCStats, t *testing.T) {
+	var ms engine.MVCCStats
+	if err := engine.MVCCGetRangeStats(eng, rangeID, &ms); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expMS, ms) {
+		t.Errorf("expected stats %+v; got %+v", expMS, ms)
+	}
+}
+
+// TestRangeStatsComputation verifies that commands executed against a
+// range update the range stat counters. The stat values are
+// empirically derived; we're really just testing that they increment
+// in the right ways, not the exact amounts. If the encodings change,
+// will need to update this test.
+func TestRangeStatsComputation(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{
+		bootstrapMode: bootstrapRangeOnly,
+	}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Put a value.
+	pArgs := putArgs([]byte("a"), []byte("value1"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	expMS := engine.MVCCStats{LiveBytes: 39, KeyBytes: 15, ValBytes: 24, IntentBytes: 0, LiveCount: 1, KeyCount: 1, ValCount: 1, IntentCount: 0, SysBytes: 58, SysCount: 1}
+	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
+
+	// Put a 2nd value transactionally.
+	pArgs = putArgs([]byte("b"), []byte("value2"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	pArgs.Txn = &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: pArgs.Timestamp}
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	expMS = engine.MVCCStats{LiveBytes: 130, KeyBytes: 30, ValBytes: 100, IntentBytes: 24, LiveCount: 2, KeyCount: 2, ValCount: 2, IntentCount: 1, SysBytes: 58, SysCount: 1}
+	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
+
+	// Resolve the 2nd value.
+	rArgs := &proto.ResolveIntentRequest{
+		RequestHeader: proto.RequestHeader{
+			Timestamp: pArgs.Txn.Timestamp,
+			Key:       pArgs.Key,
+			RangeID:   tc.rng.Desc().RangeID,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Txn:       pArgs.Txn,
+		},
+	}
+	rArgs.Txn.Status = proto.COMMITTED
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), rArgs); err != nil {
+		t.Fatal(err)
+	}
+	expMS = engine.MVCCStats{LiveBytes: 78, KeyBytes: 30, ValBytes: 48, IntentBytes: 0, LiveCount: 2, KeyCount: 2, ValCount: 2, IntentCount: 0, SysBytes: 58, SysCount: 1}
+	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
+
+	// Delete the 1st value.
+	dArgs := deleteArgs([]byte("a"), 1, tc.store.StoreID())
+	dArgs.Timestamp = tc.clock.Now()
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &dArgs); err != nil {
+		t.Fatal(err)
+	}
+	expMS = engine.MVCCStats{LiveBytes: 39, KeyBytes: 42, ValBytes: 50, IntentBytes: 0, LiveCount: 1, KeyCount: 2, ValCount: 3, IntentCount: 0, SysBytes: 58, SysCount: 1}
+	verifyRangeStats(tc.engine, tc.rng.Desc().RangeID, expMS, t)
+}
+
+// TestMerge verifies that the Merge command is behaving as
+// expected. Merge semantics for different data types are tested more
+// robustly at the engine level; this test is intended only to show
+// that values passed to Merge are being merged.
+func TestMerge(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := []byte("mergedkey")
+	stringArgs := []string{"a", "b", "c", "d"}
+	stringExpected := "abcd"
+
+	for _, str := range stringArgs {
+		mergeArgs := internalMergeArgs(key, proto.Value{Bytes: []byte(str)}, 1, tc.store.StoreID())
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &mergeArgs); err != nil {
+			t.Fatalf("unexpected error from Merge: %s", err.Error())
+		}
+	}
+
+	getArgs := getArgs(key, 1, tc.store.StoreID())
+
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &getArgs)
+	if err != nil {
+		t.Fatalf("unexpected error from Get: %s", err)
+	}
+	resp := reply.(*proto.GetResponse)
+	if resp.Value == nil {
+		t.Fatal("GetResponse had nil value")
+	}
+	if a, e := resp.Value.Bytes, []byte(stringExpected); !bytes.Equal(a, e) {
+		t.Errorf("Get did not return expected value: %s != %s", string(a), e)
+	}
+}
+
+// TestTruncateLog verifies that the TruncateLog command removes a
+// prefix of the raft logs (modifying FirstIndex() and making them
+// inaccessible via Entries()).
+func TestTruncateLog(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Populate the log with 10 entries. Save the LastIndex after each write.
+	var indexes []uint64
+	for i := 0; i < 10; i++ {
+		args := incrementArgs([]byte("a"), int64(i), 1, tc.store.StoreID())
+
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+			t.Fatal(err)
+		}
+		idx, err := tc.rng.LastIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, idx)
+	}
+
+	// Discard the first half of the log
+	truncateArgs := truncateLogArgs(indexes[5], 1, tc.store.StoreID())
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &truncateArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// FirstIndex has changed.
+	firstIndex, err := tc.rng.FirstIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstIndex != indexes[5] {
+		t.Errorf("expected firstIndex == %d, got %d", indexes[5], firstIndex)
+	}
+
+	// We can still get what remains of the log.
+	entries, err := tc.rng.Entries(indexes[5], indexes[9], math.MaxUint64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != int(indexes[9]-indexes[5]) {
+		t.Errorf("expected %d entries, got %d", indexes[9]-indexes[5], len(entries))
+	}
+
+	// But any range that includes the truncated entries returns an error.
+	_, err = tc.rng.Entries(indexes[4], indexes[9], math.MaxUint64)
+	if err != raft.ErrUnavailable {
+		t.Errorf("expected ErrUnavailable, got %s", err)
+	}
+
+	// The term of the last truncated entry is still available.
+	term, err := tc.rng.Term(indexes[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if term == 0 {
+		t.Errorf("invalid term 0 for truncated entry")
+	}
+
+	// The terms of older entries are gone.
+	_, err = tc.rng.Term(indexes[3])
+	if err != raft.ErrUnavailable {
+		t.Errorf("expected ErrUnavailable, got %s", err)
+	}
+}
+
+// TestRaftLogSize verifies that RaftLogSize grows as entries are appended
+// to the log and shrinks once the log is truncated.
+func TestRaftLogSize(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	appendEntries := func(n int) int64 {
+		for i := 0; i < n; i++ {
+			args := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+				t.Fatal(err)
+			}
+		}
+		size, err := tc.rng.RaftLogSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return size
+	}
+
+	size0 := appendEntries(0)
+	size1 := appendEntries(10)
+	size2 := appendEntries(10)
+	if size1 <= size0 || size2 <= size1 {
+		t.Fatalf("expected raft log size to grow: %d, %d, %d", size0, size1, size2)
+	}
+	// The two batches of entries are of identical shape, so they should
+	// account for (almost) the same number of bytes.
+	delta1, delta2 := size1-size0, size2-size1
+	if diff := delta2 - delta1; diff > delta1/10 || diff < -delta1/10 {
+		t.Errorf("expected proportional growth, got deltas %d and %d", delta1, delta2)
+	}
+
+	// Truncating the log must reduce the reported size.
+	lastIndex, err := tc.rng.LastIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncateArgs := truncateLogArgs(lastIndex, 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &truncateArgs); err != nil {
+		t.Fatal(err)
+	}
+	size3, err := tc.rng.RaftLogSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size3 >= size2 {
+		t.Errorf("expected raft log size to shrink after truncation: %d >= %d", size3, size2)
+	}
+}
+
+// TestRaftLogEntries verifies that RaftLogEntries returns the entries
+// in the requested index range, clamped to the untruncated log.
+func TestRaftLogEntries(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	for i := 0; i < 5; i++ {
+		args := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lastIndex, err := tc.rng.LastIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(lo, hi, expLo, expHi uint64) {
+		ents, err := tc.rng.RaftLogEntries(lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ents) != int(expHi-expLo) {
+			t.Fatalf("[%d, %d): expected %d entries, got %d", lo, hi, expHi-expLo, len(ents))
+		}
+		for i, ent := range ents {
+			if ent.Index != expLo+uint64(i) {
+				t.Errorf("[%d, %d): expected entry %d at index %d, got %d", lo, hi, i, expLo+uint64(i), ent.Index)
+			}
+		}
+	}
+
+	firstIndex, err := tc.rng.FirstIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(lastIndex-2, lastIndex+1, lastIndex-2, lastIndex+1)
+	verify(0, lastIndex+100, firstIndex, lastIndex+1)
+	verify(lastIndex+1, lastIndex+10, 0, 0)
+
+	// Truncated entries are not returned.
+	truncateArgs := truncateLogArgs(lastIndex-1, 1, tc.store.StoreID())
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &truncateArgs); err != nil {
+		t.Fatal(err)
+	}
+	verify(0, lastIndex+1, lastIndex-1, lastIndex+1)
+}
+
+func TestRaftStorage(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	var eng engine.Engine
+	storagetest.RunTests(t,
+		func(t *testing.T) storagetest.WriteableStorage {
+			eng = engine.NewInMem(proto.Attributes{Attrs: []string{"dc1", "mem"}}, 1<<20)
+			// Fake store to house the engine.
+			store := &Store{
+				ctx: StoreContext{
+					Clock: hlc.NewClock(hlc.UnixNano),
+				},
+				engine: eng,
+			}
+			rng, err := NewReplica(&proto.RangeDescriptor{
+				RangeID:  1,
+				StartKey: proto.KeyMin,
+				EndKey:   proto.KeyMax,
+			}, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return rng
+		},
+		func(t *testing.T, r storagetest.WriteableStorage) {
+			eng.Close()
+		})
+}
+
+// TestConditionFailedError tests that a ConditionFailedError correctly
+// bubbles up from MVCC to Range.
+func TestConditionFailedError(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := []byte("k")
+	value := []byte("quack")
+	pArgs := putArgs(key, value, 1, tc.store.StoreID())
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	args := proto.ConditionalPutRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       key,
+			Timestamp: proto.MinTimestamp,
+			RangeID:   1,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+		},
+		Value: proto.Value{
+			Bytes: value,
+		},
+		ExpValue: &proto.Value{
+			Bytes: []byte("moo"),
+		},
+	}
+
+	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+	if cErr, ok := err.(*proto.ConditionFailedError); err == nil || !ok {
+		t.Fatalf("expected ConditionFailedError, got %T with content %+v",
+			err, err)
+	} else if v := cErr.ActualValue; v == nil || !bytes.Equal(v.Bytes, value) {
+		t.Errorf("ConditionFailedError with bytes %q expected, but got %+v",
+			value, v)
+	}
+}
+
+// TestRangeCheckAndPut verifies that CheckAndPut succeeds if the key
+// hasn't been written since the expected timestamp and fails with a
+// ConditionFailedError if a concurrent write bumped its latest version.
+func TestRangeCheckAndPut(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := []byte("k")
+	pArgs := putArgs(key, []byte("v1"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the key to learn the timestamp of its latest version.
+	tc.manualClock.Increment(1)
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readTS := *reply.(*proto.GetResponse).Value.Timestamp
+
+	// The key is unchanged since the read, so the swap succeeds.
+	tc.manualClock.Increment(1)
+	cpArgs := proto.CheckAndPutRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       key,
+			Timestamp: tc.clock.Now(),
+			RangeID:   1,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+		},
+		Value:        proto.Value{Bytes: []byte("v2")},
+		ExpTimestamp: readTS,
+	}
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &cpArgs); err != nil {
+		t.Fatalf("expected swap of unchanged key to succeed: %s", err)
+	}
+
+	// A concurrent write bumps the latest version past the timestamp of
+	// the swap, so another swap expecting that version must fail.
+	tc.manualClock.Increment(1)
+	pArgs = putArgs(key, []byte("v3"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	tc.manualClock.Increment(1)
+	cpArgs.ExpTimestamp = cpArgs.Timestamp
+	cpArgs.Timestamp = tc.clock.Now()
+	cpArgs.Value = proto.Value{Bytes: []byte("v4")}
+	_, err = tc.rng.AddCmd(tc.rng.context(), &cpArgs)
+	if cErr, ok := err.(*proto.ConditionFailedError); !ok {
+		t.Fatalf("expected ConditionFailedError, got %T with content %+v", err, err)
+	} else if v := cErr.ActualValue; v == nil || !bytes.Equal(v.Bytes, []byte("v3")) {
+		t.Errorf("expected actual value %q, got %+v", "v3", v)
+	}
+
+	gArgs.Timestamp = tc.clock.Now()
+	if reply, err = tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+	if v := reply.(*proto.GetResponse).Value; !bytes.Equal(v.Bytes, []byte("v3")) {
+		t.Errorf("expected value %q after failed swap, got %q", "v3", v.Bytes)
+	}
+}
+
+// TestReplicaSetsEqual tests to ensure that intersectReplicaSets
+// returns the correct responses.
+func TestReplicaSetsEqual(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	testData := []struct {
+		expected bool
+		a        []proto.Replica
+		b        []proto.Replica
+	}{
+		{true, []proto.Replica{}, []proto.Replica{}},
+		{true, createReplicaSets([]proto.StoreID{1}), createReplicaSets([]proto.StoreID{1})},
+		{true, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{1, 2})},
+		{true, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{2, 1})},
+		{false, createReplicaSets([]proto.StoreID{1}), createReplicaSets([]proto.StoreID{2})},
+		{false, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{2})},
+		{false, createReplicaSets([]proto.StoreID{1, 2}), createReplicaSets([]proto.StoreID{1})},
+		{false, createReplicaSets([]proto.StoreID{}), createReplicaSets([]proto.StoreID{1})},
+		{true, createReplicaSets([]proto.StoreID{1, 2, 3}), createReplicaSets([]proto.StoreID{2, 3, 1})},
+		{true, createReplicaSets([]proto.StoreID{1, 1}), createReplicaSets([]proto.StoreID{1, 1})},
+		{false, createReplicaSets([]proto.StoreID{1, 1}), createReplicaSets([]proto.StoreID{1, 1, 1})},
+		{true, createReplicaSets([]proto.StoreID{1, 2, 3, 1, 2, 3}), createReplicaSets([]proto.StoreID{1, 1, 2, 2, 3, 3})},
+	}
+	for _, test := range testData {
+		if replicaSetsEqual(test.a, test.b) != test.expected {
+			t.Fatalf("unexpected replica intersection: %+v", test)
+		}
+	}
+}
+
+func TestAppliedIndex(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	var appliedIndex uint64
+	var sum int64
+	for i := int64(1); i <= 10; i++ {
+		args := incrementArgs([]byte("a"), i, 1, tc.store.StoreID())
+
+		resp, err := tc.rng.AddCmd(tc.rng.context(), &args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := resp.(*proto.IncrementResponse)
+		sum += i
+
+		if reply.NewValue != sum {
+			t.Errorf("expected %d, got %d", sum, reply.NewValue)
+		}
+
+		newAppliedIndex := atomic.LoadUint64(&tc.rng.appliedIndex)
+		if newAppliedIndex <= appliedIndex {
+			t.Errorf("appliedIndex did not advance. Was %d, now %d", appliedIndex, newAppliedIndex)
+		}
+		appliedIndex = newAppliedIndex
+	}
+}
+
+// TestReplicaCorruption verifies that a replicaCorruptionError correctly marks
+// the range as corrupt.
+func TestReplicaCorruption(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	args := putArgs(proto.Key("test"), []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Set the stored applied index sky high.
+	newIndex := 2*atomic.LoadUint64(&tc.rng.appliedIndex) + 1
+	atomic.StoreUint64(&tc.rng.appliedIndex, newIndex)
+	// Not really needed, but let's be thorough.
+	err = setAppliedIndex(tc.rng.rm.Engine(), tc.rng.Desc().RangeID, newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Should mark replica corrupt (and panic as a result) since we messed
+	// with the applied index.
+	_, err = tc.rng.AddCmd(tc.rng.context(), &args)
+
+	if err == nil || !strings.Contains(err.Error(), "replica corruption (processed=true)") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// failingCommitBatch is a batch whose first failures calls to Commit
+// fail with err.
+type failingCommitBatch struct {
+	engine.Engine
+	failures int
+	err      error
+	attempts int
+}
+
+func (b *failingCommitBatch) Commit() error {
+	b.attempts++
+	if b.attempts <= b.failures {
+		return b.err
+	}
+	return b.Engine.Commit()
+}
+
+// TestReplicaCommitAppliedBatchRetry verifies that committing the batch of
+// an applied command is retried after transient engine errors within the
+// commit grace period, and that other errors are returned right away.
+func TestReplicaCommitAppliedBatchRetry(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// A commit which fails transiently succeeds on retry.
+	key := engine.MVCCEncodeKey(proto.Key("a"))
+	batch := &failingCommitBatch{Engine: tc.engine.NewBatch(), failures: 2, err: syscall.ENOSPC}
+	defer batch.Close()
+	if err := batch.Put(key, []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.rng.commitAppliedBatch(batch); err != nil {
+		t.Fatalf("expected commit to succeed on retry; got %s", err)
+	}
+	if batch.attempts != 3 {
+		t.Errorf("expected 3 commit attempts; got %d", batch.attempts)
+	}
+	if val, err := tc.engine.Get(key); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(val, []byte("value")) {
+		t.Errorf("expected committed value; got %q", val)
+	}
+
+	// RocksDB reports errors by message only.
+	if !isTransientEngineError(errors.New("IO error: No space left on device")) {
+		t.Error("expected out of space error to be transient")
+	}
+
+	// Other errors aren't retried.
+	batch = &failingCommitBatch{Engine: tc.engine.NewBatch(), failures: 1, err: errors.New("Corruption: bad block")}
+	defer batch.Close()
+	if err := tc.rng.commitAppliedBatch(batch); err == nil {
+		t.Error("expected commit to fail")
+	}
+	if batch.attempts != 1 {
+		t.Errorf("expected a single commit attempt; got %d", batch.attempts)
+	}
+
+	// Transient errors fail the commit once the grace period has passed.
+	tc.store.ctx.CommitGracePeriod = 50 * time.Millisecond
+	batch = &failingCommitBatch{Engine: tc.engine.NewBatch(), failures: math.MaxInt32, err: syscall.ENOSPC}
+	defer batch.Close()
+	if err := tc.rng.commitAppliedBatch(batch); err != syscall.ENOSPC {
+		t.Errorf("expected out of space error; got %v", err)
+	}
+	if batch.attempts < 2 {
+		t.Errorf("expected commit to be retried; got %d attempts", batch.attempts)
+	}
+}
+
+// TestReplicaVerifyResponseCache verifies that in response cache
+// verification mode, a write whose response differs when executed again
+// is detected, while deterministic writes pass and writes with side
+// effects aren't executed again.
+func TestReplicaVerifyResponseCache(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Puts to "nondet" fail whenever they are executed a second time.
+	var mu sync.Mutex
+	executions := map[proto.ClientCmdID]int{}
+	var triggerExecutions int32
+	TestingCommandFilter = func(args proto.Request) error {
+		if et, ok := args.(*proto.EndTransactionRequest); ok && et.InternalCommitTrigger != nil {
+			atomic.AddInt32(&triggerExecutions, 1)
+		}
+		if args.Method() == proto.Put && args.Header().Key.Equal(proto.Key("nondet")) {
+			mu.Lock()
+			defer mu.Unlock()
+			if executions[args.Header().CmdID]++; executions[args.Header().CmdID] > 1 {
+				return util.Errorf("injected non-determinism")
+			}
+		}
+		return nil
+	}
+
+	put := func(key string, random int64) {
+		pArgs := putArgs(proto.Key(key), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		pArgs.CmdID = proto.ClientCmdID{WallTime: 1, Random: random}
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without verification, each put is executed once.
+	put("nondet", 1)
+	if n := atomic.LoadInt64(&tc.rng.respCacheDivergences); n != 0 {
+		t.Fatalf("expected no divergences; got %d", n)
+	}
+
+	tc.store.ctx.VerifyResponseCache = true
+	put("det", 2)
+	if n := atomic.LoadInt64(&tc.rng.respCacheDivergences); n != 0 {
+		t.Fatalf("expected no divergences for a deterministic write; got %d", n)
+	}
+	put("nondet", 3)
+	if n := atomic.LoadInt64(&tc.rng.respCacheDivergences); n != 1 {
+		t.Fatalf("expected the divergence to be detected; got %d divergences", n)
+	}
+
+	// An EndTransaction with a commit trigger isn't executed again.
+	txn := newTransaction("test", proto.Key("a"), 1, proto.SERIALIZABLE, tc.clock)
+	etArgs := endTxnArgs(txn, true, 1, tc.store.StoreID())
+	etArgs.Timestamp = txn.Timestamp
+	etArgs.CmdID = proto.ClientCmdID{WallTime: 1, Random: 4}
+	etArgs.InternalCommitTrigger = &proto.InternalCommitTrigger{}
+	tc.rng.verifyResponse(tc.rng.context(), &etArgs, etArgs.CreateReply(), nil)
+	if n := atomic.LoadInt32(&triggerExecutions); n != 0 {
+		t.Errorf("expected the commit trigger not to be executed again; got %d executions", n)
+	}
+}
+
+// TestReplicaHardenedModeNegativeStats verifies that in hardened mode,
+// a write which would make the MVCC stats negative is refused with a
+// replica corruption error before it is committed.
+func TestReplicaHardenedModeNegativeStats(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	tc.store.ctx.HardenedMode = true
+
+	key := proto.Key("a")
+	var executions int32
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.PutRequest); ok && args.Header().Key.Equal(key) {
+			atomic.AddInt32(&executions, 1)
+		}
+		return nil
+	}
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	// The checks don't execute the write a second time.
+	if n := atomic.LoadInt32(&executions); n != 1 {
+		t.Errorf("expected the put to be executed once; got %d", n)
+	}
+
+	// Lose track of the written value in the stats, so that deleting it
+	// would make the live bytes and count negative.
+	if err := tc.rng.stats.SetMVCCStats(tc.engine, engine.MVCCStats{}); err != nil {
+		t.Fatal(err)
+	}
+	dArgs := deleteArgs(key, 1, tc.store.StoreID())
+	dArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &dArgs); err == nil {
+		t.Fatal("expected the delete to be refused")
+	} else if _, ok := err.(*replicaCorruptionError); !ok {
+		t.Fatalf("expected a replica corruption error; got %T: %s", err, err)
+	}
+	if !tc.rng.HealthCheck().Corrupt {
+		t.Error("expected replica to be marked corrupt")
+	}
+
+	// Nothing was committed.
+	if stats := tc.rng.GetMVCCStats(); stats.LiveBytes != 0 || stats.LiveCount != 0 {
+		t.Errorf("expected live stats to be unchanged; got %+v", stats)
+	}
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	if reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	} else if v := reply.(*proto.GetResponse).Value; v == nil || string(v.Bytes) != "value" {
+		t.Errorf("expected value to remain; got %+v", v)
+	}
+}
+
+// TestReplicaHardenedModeByProposer verifies that hardened mode is
+// applied as decided by the proposer of a command, regardless of the
+// setting of the applying replica's store.
+func TestReplicaHardenedModeByProposer(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	// Lose track of the written value in the stats, so that deleting it
+	// would make the live bytes and count negative.
+	if err := tc.rng.stats.SetMVCCStats(tc.engine, engine.MVCCStats{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dArgs := deleteArgs(key, 1, tc.store.StoreID())
+	dArgs.Timestamp = tc.clock.Now()
+	for _, local := range []bool{false, true} {
+		tc.store.ctx.HardenedMode = local
+		for _, hardened := range []bool{false, true} {
+			raftCmd := proto.RaftCommand{
+				RangeID:      1,
+				OriginNodeID: tc.store.RaftNodeID(),
+				Hardened:     hardened,
+			}
+			raftCmd.Cmd.SetValue(&dArgs)
+			index := atomic.LoadUint64(&tc.rng.appliedIndex) + 1
+			ms := engine.MVCCStats{}
+			batch, _, err := tc.rng.applyRaftCommandInBatch(tc.rng.context(), index, raftCmd, &dArgs, &ms)
+			batch.Close()
+			if _, ok := err.(*replicaCorruptionError); ok != hardened {
+				t.Errorf("local=%t, hardened=%t: unexpected error %v", local, hardened, err)
+			}
+		}
+	}
+}
+
+// TestReplicaCorruptionRangeIDMismatch verifies that a raft command for
+// another range is not applied and marks the replica as corrupt.
+func TestReplicaCorruptionRangeIDMismatch(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("test")
+	args := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	args.Timestamp = tc.clock.Now()
+	raftCmd := proto.RaftCommand{
+		RangeID:      tc.rng.Desc().RangeID + 1,
+		OriginNodeID: tc.store.RaftNodeID(),
+		Version:      proto.RaftCommandVersion,
+	}
+	if !raftCmd.Cmd.SetValue(&args) {
+		t.Fatal("could not set put request on raft command")
+	}
+	appliedIndex := atomic.LoadUint64(&tc.rng.appliedIndex)
+	err := tc.rng.processRaftCommand(cmdIDKey("foreign"), appliedIndex+1, raftCmd)
+	if _, ok := err.(*replicaCorruptionError); !ok {
+		t.Fatalf("expected replica corruption error; got %v", err)
+	}
+	if !tc.rng.HealthCheck().Corrupt {
+		t.Error("expected replica to be marked corrupt")
+	}
+	if a := atomic.LoadUint64(&tc.rng.appliedIndex); a != appliedIndex {
+		t.Errorf("expected applied index to remain %d; got %d", appliedIndex, a)
+	}
+	if val, _, err := engine.MVCCGet(tc.engine, key, tc.clock.Now(), true, nil); err != nil {
+		t.Fatal(err)
+	} else if val != nil {
+		t.Errorf("expected foreign command not to be applied; found value %s", val)
+	}
+}
+
+// TestRangeContentionStats verifies that writes pushed by the timestamp
+// cache and WriteTooOldErrors are counted.
+func TestRangeContentionStats(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	defer func() { TestingCommandFilter = nil }()
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// A write following a read of the same key is pushed.
+	tc.manualClock.Set(time.Second.Nanoseconds())
+	gArgs := getArgs([]byte("a"), 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	}
+	pArgs := putArgs([]byte("a"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = gArgs.Timestamp
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	if stats := tc.rng.ContentionStats(); stats != (ContentionStats{TimestampCachePushes: 1}) {
+		t.Errorf("expected a single push; got %+v", stats)
+	}
+
+	// A transactional write which runs into a newer write fails.
+	key := proto.Key("b")
+	TestingCommandFilter = func(args proto.Request) error {
+		if _, ok := args.(*proto.PutRequest); !ok || !args.Header().Key.Equal(key) {
+			return nil
+		}
+		ts := args.Header().Timestamp
+		return &proto.WriteTooOldError{Timestamp: ts, ExistingTimestamp: ts.Add(10, 0)}
+	}
+	pArgs = putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	pArgs.Timestamp = pArgs.Txn.Timestamp
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Fatal("expected WriteTooOldError")
+	}
+	if stats := tc.rng.ContentionStats(); stats != (ContentionStats{TimestampCachePushes: 1, WriteTooOldErrors: 1}) {
+		t.Errorf("expected a push and a WriteTooOldError; got %+v", stats)
+	}
+}
+
+// TestRangeReplicationLatency verifies that the time between proposing
+// a command and applying it is recorded.
+func TestRangeReplicationLatency(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Apply the command directly, delaying it by advancing the clock
+	// between proposal and application.
+	const delay = 50 * time.Millisecond
+	args := putArgs(proto.Key("a"), []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	args.Timestamp = tc.clock.Now()
+	raftCmd := proto.RaftCommand{
+		RangeID:      tc.rng.Desc().RangeID,
+		OriginNodeID: tc.store.RaftNodeID(),
+		Version:      proto.RaftCommandVersion,
+	}
+	if !raftCmd.Cmd.SetValue(&args) {
+		t.Fatal("could not set put request on raft command")
+	}
+	idKey := cmdIDKey("delayed")
+	cmd := &pendingCmd{
+		ctx:        tc.rng.context(),
+		done:       make(chan proto.ResponseWithError, 1),
+		proposedAt: tc.clock.PhysicalNow(),
+	}
+	tc.rng.Lock()
+	tc.rng.pendingCmds[idKey] = cmd
+	tc.rng.Unlock()
+	before := tc.rng.ReplicationLatency()
+	tc.manualClock.Increment(delay.Nanoseconds())
+
+	appliedIndex := atomic.LoadUint64(&tc.rng.appliedIndex)
+	if err := tc.rng.processRaftCommand(idKey, appliedIndex+1, raftCmd); err != nil {
+		t.Fatal(err)
+	}
+	if resp := <-cmd.done; resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	stats := tc.rng.ReplicationLatency()
+	if stats.Count != before.Count+1 {
+		t.Errorf("expected %d samples; got %d", before.Count+1, stats.Count)
+	}
+	if stats.Last != delay {
+		t.Errorf("expected latency %s; got %s", delay, stats.Last)
+	}
+	if stats.Max < delay {
+		t.Errorf("expected max latency of at least %s; got %s", delay, stats.Max)
+	}
+}
+
+// TestRangeReplayCommand verifies that replaying a raft command returns
+// the result of its execution without modifying the live range.
+func TestRangeReplayCommand(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("replay")
+	args := putArgs(key, []byte("value"), tc.rng.Desc().RangeID, tc.store.StoreID())
+	args.Timestamp = tc.clock.Now()
+	raftCmd := proto.RaftCommand{
+		RangeID:      tc.rng.Desc().RangeID,
+		OriginNodeID: tc.store.RaftNodeID(),
+		Version:      proto.RaftCommandVersion,
+	}
+	if !raftCmd.Cmd.SetValue(&args) {
+		t.Fatal("could not set put request on raft command")
+	}
+
+	appliedIndex := atomic.LoadUint64(&tc.rng.appliedIndex)
+	stats := tc.rng.stats.GetMVCC()
+	br, err := tc.rng.ReplayCommand(raftCmd, appliedIndex+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(br.Responses) != 1 {
+		t.Fatalf("expected a single response; got %d", len(br.Responses))
+	}
+	if _, ok := br.Responses[0].GetValue().(*proto.PutResponse); !ok {
+		t.Fatalf("expected put response; got %T", br.Responses[0].GetValue())
+	}
+
+	// The live range is unaffected.
+	if a := atomic.LoadUint64(&tc.rng.appliedIndex); a != appliedIndex {
+		t.Errorf("expected applied index to remain %d; got %d", appliedIndex, a)
+	}
+	if a, err := tc.rng.loadAppliedIndex(tc.engine); err != nil {
+		t.Fatal(err)
+	} else if a != appliedIndex {
+		t.Errorf("expected persisted applied index to remain %d; got %d", appliedIndex, a)
+	}
+	if s := tc.rng.stats.GetMVCC(); !reflect.DeepEqual(s, stats) {
+		t.Errorf("expected stats to remain %+v; got %+v", stats, s)
+	}
+	if val, _, err := engine.MVCCGet(tc.engine, key, tc.clock.Now(), true, nil); err != nil {
+		t.Fatal(err)
+	} else if val != nil {
+		t.Errorf("expected replayed command not to be applied; found value %s", val)
+	}
+
+	// A command for another range is refused.
+	raftCmd.RangeID++
+	if _, err := tc.rng.ReplayCommand(raftCmd, appliedIndex+1); err == nil {
+		t.Error("expected error replaying command for another range")
+	}
+
+	// So are commands with side effects beyond the batch.
+	txn := newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	etArgs := endTxnArgs(txn, true, tc.rng.Desc().RangeID, tc.store.StoreID())
+	etArgs.InternalCommitTrigger = &proto.InternalCommitTrigger{
+		ChangeReplicasTrigger: &proto.ChangeReplicasTrigger{},
+	}
+	leaseArgs := &proto.LeaderLeaseRequest{
+		RequestHeader: proto.RequestHeader{Key: tc.rng.Desc().StartKey},
+	}
+	for _, args := range []proto.Request{&etArgs, leaseArgs} {
+		raftCmd := proto.RaftCommand{
+			RangeID:      tc.rng.Desc().RangeID,
+			OriginNodeID: tc.store.RaftNodeID(),
+			Version:      proto.RaftCommandVersion,
+		}
+		if !raftCmd.Cmd.SetValue(args) {
+			t.Fatalf("could not set %s request on raft command", args.Method())
+		}
+		if _, err := tc.rng.ReplayCommand(raftCmd, appliedIndex+1); !testutils.IsError(err, "side effects") {
+			t.Errorf("%s: expected error replaying command with side effects; got %v", args.Method(), err)
+		}
+	}
+}
+
+// TestChangeReplicasDuplicateError tests that a replica change that would
+// use a NodeID twice in the replica configuration fails.
+func TestChangeReplicasDuplicateError(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	if err := tc.rng.ChangeReplicas(proto.ADD_REPLICA, proto.Replica{
+		NodeID:  tc.store.Ident.NodeID,
+		StoreID: 9999,
+	}, tc.rng.Desc()); err == nil || !strings.Contains(err.Error(),
+		"already present") {
+		t.Fatalf("must not be able to add second replica to same node (err=%s)",
+			err)
+	}
+}
+
+// TestRangeDanglingMetaIntent creates a dangling intent on a meta2
+// record and verifies that RangeLookup requests behave
+// appropriately. Normally, the old value and a write intent error
+// should be returned. If IgnoreIntents is specified, then a random
+// choice of old or new is returned with no error.
+func TestRangeDanglingMetaIntent(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	// Test RangeLookup with Scan.
+	testRangeDanglingMetaIntent(t, false)
+	// Test RangeLookup with ReverseScan.
+	testRangeDanglingMetaIntent(t, true)
+}
+
+func testRangeDanglingMetaIntent(t *testing.T, isReverse bool) {
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	key := proto.Key("a")
+
+	// Get original meta2 descriptor.
+	rlArgs := &proto.RangeLookupRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:             keys.RangeMetaKey(key),
+			RangeID:         tc.rng.Desc().RangeID,
+			Replica:         proto.Replica{StoreID: tc.store.StoreID()},
+			ReadConsistency: proto.INCONSISTENT,
+		},
+		MaxRanges: 1,
+		Reverse:   isReverse,
+	}
+
+	var rlReply *proto.RangeLookupResponse
+
+	reply, err := tc.rng.AddCmd(tc.rng.context(), rlArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlReply = reply.(*proto.RangeLookupResponse)
+
+	origDesc := rlReply.Ranges[0]
+	newDesc := origDesc
+	newDesc.EndKey = key
+
+	// Write the new descriptor as an intent.
+	data, err := gogoproto.Marshal(&newDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pArgs := putArgs(keys.RangeMetaKey(key), data, 1, tc.store.StoreID())
+	pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
+	pArgs.Timestamp = pArgs.Txn.Timestamp
+
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Now lookup the range; should get the value. Since the lookup is
+	// inconsistent, there's no WriteIntentErorr.
+	rlArgs.Key = keys.RangeMetaKey(proto.Key("A"))
+	rlArgs.Timestamp = proto.ZeroTimestamp
+
+	reply, err = tc.rng.AddCmd(tc.rng.context(), rlArgs)
+	if err != nil {
+		t.Errorf("unexpected lookup error: %s", err)
+	}
+	rlReply = reply.(*proto.RangeLookupResponse)
+	if !reflect.DeepEqual(rlReply.Ranges[0], origDesc) {
+		t.Errorf("expected original descriptor %s; got %s", &origDesc, &rlReply.Ranges[0])
+	}
+
+	// Switch to consistent lookups, which should run into the intent.
+	rlArgs.ReadConsistency = proto.CONSISTENT
+	_, err = tc.rng.AddCmd(tc.rng.context(), rlArgs)
+	if _, ok := err.(*proto.WriteIntentError); !ok {
+		t.Fatalf("expected WriteIntentError, not %s", err)
+	}
+
+	// Try 100 lookups with IgnoreIntents. Expect to see each descriptor at least once.
+	// First, try this consistently, which should not be allowed.
+	rlArgs.IgnoreIntents = true
+	_, err = tc.rng.AddCmd(tc.rng.context(), rlArgs)
+	if !testutils.IsError(err, "can not read consistently and skip intents") {
+		t.Fatalf("wanted specific error, not %s", err)
+	}
+	// After changing back to inconsistent lookups, should be good to go.
+	rlArgs.ReadConsistency = proto.INCONSISTENT
+
+	var origSeen, newSeen bool
+	const count = 100
+
+	for i := 0; i < count && !(origSeen && newSeen); i++ {
+		clonedRLArgs := gogoproto.Clone(rlArgs).(*proto.RangeLookupRequest)
+		clonedRLArgs.Timestamp = proto.ZeroTimestamp
+
+		reply, err = tc.rng.AddCmd(tc.rng.context(), clonedRLArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rlReply = reply.(*proto.RangeLookupResponse)
+		seen := rlReply.Ranges[0]
+		if reflect.DeepEqual(seen, origDesc) {
+			origSeen = true
+		} else if reflect.DeepEqual(seen, newDesc) {
+			newSeen = true
+		} else {
+			t.Errorf("expected orig/new descriptor %s/%s; got %s", &origDesc, &newDesc, &seen)
+		}
+	}
+	if !(origSeen && newSeen) {
+		t.Errorf("didn't see both descriptors after %d attempts", count)
+	}
+}
+
+// TestRangeLookupUseReverseScan verifies the correctness of the results which are retrived
+// from RangeLookup by using ReverseScan.
+func TestRangeLookupUseReverseScan(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Test ranges: ["a","c"), ["c","f"), ["f","h") and ["h","y").
+	testRanges := []proto.RangeDescriptor{
+		{RangeID: 2, StartKey: proto.Key("a"), EndKey: proto.Key("c")},
+		{RangeID: 3, StartKey: proto.Key("c"), EndKey: proto.Key("f")},
+		{RangeID: 4, StartKey: proto.Key("f"), EndKey: proto.Key("h")},
+		{RangeID: 5, StartKey: proto.Key("h"), EndKey: proto.Key("y")},
+	}
+	// The range ["f","h") has dangling intent in meta2.
+	withIntentRangeIndex := 2
+
+	testCases := []struct {
+		key      string
+		expected proto.RangeDescriptor
+	}{
+		// For testRanges[0|1|3] there is no intent. A key in the middle
+		// and the end key should both give us the range itself.
+		{key: "b", expected: testRanges[0]},
+		{key: "c", expected: testRanges[0]},
+		{key: "d", expected: testRanges[1]},
+		{key: "f", expected: testRanges[1]},
+		{key: "j", expected: testRanges[3]},
+		// testRanges[2] has an intent, so the inconsistent scan will read
+		// an old value (nil). Since we're in reverse mode, testRanges[1]
+		// is the result.
+		{key: "g", expected: testRanges[1]},
+		{key: "h", expected: testRanges[1]},
+	}
+
+	txn := &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: tc.clock.Now()}
+	for i, r := range testRanges {
+		if i != withIntentRangeIndex {
+			// Write the new descriptor as an intent.
+			data, err := gogoproto.Marshal(&r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pArgs := putArgs(keys.RangeMetaKey(r.EndKey), data,
+				tc.rng.Desc().RangeID, tc.store.StoreID())
+			pArgs.Txn = txn
+
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Resolve the intents.
+	rArgs := &proto.ResolveIntentRangeRequest{
+		RequestHeader: proto.RequestHeader{
+			Timestamp: txn.Timestamp,
+			Key:       keys.RangeMetaKey(proto.Key("a")),
+			EndKey:    keys.RangeMetaKey(proto.Key("z")),
+			RangeID:   tc.rng.Desc().RangeID,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Txn:       txn,
+		},
+	}
+	rArgs.Txn.Status = proto.COMMITTED
+	if _, err := tc.rng.AddCmd(tc.rng.context(), rArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Get original meta2 descriptor.
+	rlArgs := &proto.RangeLookupRequest{
+		RequestHeader: proto.RequestHeader{
+			RangeID:         tc.rng.Desc().RangeID,
+			Replica:         proto.Replica{StoreID: tc.store.StoreID()},
+			ReadConsistency: proto.INCONSISTENT,
+		},
+		MaxRanges: 1,
+		Reverse:   true,
+	}
+	var rlReply *proto.RangeLookupResponse
+
+	// Test ReverseScan without intents.
+	for _, c := range testCases {
+		clonedRLArgs := gogoproto.Clone(rlArgs).(*proto.RangeLookupRequest)
+		clonedRLArgs.Timestamp = proto.ZeroTimestamp
+		clonedRLArgs.Key = keys.RangeMetaKey(proto.Key(c.key))
+		reply, err := tc.rng.AddCmd(tc.rng.context(), clonedRLArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rlReply = reply.(*proto.RangeLookupResponse)
+		seen := rlReply.Ranges[0]
+		if !(seen.StartKey.Equal(c.expected.StartKey) && seen.EndKey.Equal(c.expected.EndKey)) {
+			t.Errorf("expected descriptor %s; got %s", &c.expected, &seen)
+		}
+	}
+
+	// Write the new descriptor as an intent.
+	intentRange := testRanges[withIntentRangeIndex]
+	data, err := gogoproto.Marshal(&intentRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pArgs := putArgs(keys.RangeMetaKey(intentRange.EndKey), data, 1, tc.store.StoreID())
+	pArgs.Txn = &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: tc.clock.Now()}
+	pArgs.Timestamp = pArgs.Txn.Timestamp
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Test ReverseScan with intents.
+	for _, c := range testCases {
+		clonedRLArgs := gogoproto.Clone(rlArgs).(*proto.RangeLookupRequest)
+		clonedRLArgs.Timestamp = proto.ZeroTimestamp
+		clonedRLArgs.Key = keys.RangeMetaKey(proto.Key(c.key))
+		reply, err := tc.rng.AddCmd(tc.rng.context(), clonedRLArgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rlReply = reply.(*proto.RangeLookupResponse)
+		seen := rlReply.Ranges[0]
+		if !(seen.StartKey.Equal(c.expected.StartKey) && seen.EndKey.Equal(c.expected.EndKey)) {
+			t.Errorf("expected descriptor %s; got %s", &c.expected, &seen)
+		}
+	}
+}
+
+func TestRangeLookup(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+	for _, key := range []proto.Key{
+		// Test with the first range (StartKey==KeyMin). Normally we look
+		// up this range in gossip instead of executing the RPC, but
+		// RangeLookup is still used when up-to-date information is
+		// required.
+		proto.KeyMin,
+		// Test with the last key in a meta prefix. This is an edge case in the
+		// implementation.
+		proto.MakeKey(keys.Meta1Prefix, proto.KeyMax),
+	} {
+		resp, err := tc.store.ExecuteCmd(context.Background(), &proto.RangeLookupRequest{
+			RequestHeader: proto.RequestHeader{
+				RangeID: 1,
+				Key:     key,
+			},
+			MaxRanges: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := resp.(*proto.RangeLookupResponse)
+		expected := []proto.RangeDescriptor{*tc.rng.Desc()}
+		if !reflect.DeepEqual(reply.Ranges, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, reply.Ranges)
+		}
+	}
+}
+
+// benchmarkEvents is designed to determine the impact of sending events on the
+// performance of write commands. This benchmark can be run with or without
+// events, and with or without a consumer reading the events.
+func benchmarkEvents(b *testing.B, sendEvents, consumeEvents bool) {
+	defer leaktest.AfterTest(b)
+	tc := testContext{}
+
+	stopper := stop.NewStopper()
+	if sendEvents {
+		tc.feed = util.NewFeed(stopper)
+	}
+	eventC := 0
+	if consumeEvents {
+		tc.feed.Subscribe(func(_ interface{}) {
+			eventC++
+		})
+	}
+
+	tc.Start(b)
+	defer tc.Stop()
+
+	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	stopper.Stop()
+
+	if consumeEvents {
+		// The test expects exactly b.N update events to be sent on the feed.
+		// The '+5' is a constant number of non-update events sent when the
+		// store is first started.
+		if a, e := eventC, b.N+5; a != e {
+			b.Errorf("Unexpected number of events received %d, expected %d", a, e)
+		}
+	}
+}
+
+// BenchmarkWriteCmdNoEvents benchmarks write commands on a store that does not
+// publish events.
+func BenchmarkWriteCmdNoEvents(b *testing.B) {
+	benchmarkEvents(b, false, false)
+}
+
+// BenchmarkWriteCmdNoEvents benchmarks write commands on a store that publishes
+// events. There are no subscribers to the events, but they are still produced.
+func BenchmarkWriteCmdWithEvents(b *testing.B) {
+	benchmarkEvents(b, true, false)
+}
+
+// BenchmarkWriteConsumeEvents benchmarks write commands on a store that publishes
+// events. There is a single subscriber reading the events.
+func BenchmarkWriteCmdWithEventsAndConsumer(b *testing.B) {
+	benchmarkEvents(b, true, true)
+}
+
+// benchmarkMultiGet benchmarks reading a number of keys, either in a
+// single read-only batch or with one request per key.
+func benchmarkMultiGet(b *testing.B, batch bool) {
+	defer leaktest.AfterTest(b)
+	tc := testContext{}
+	tc.Start(b)
+	defer tc.Stop()
+
+	const numKeys = 100
+	var gets []proto.GetRequest
+	for i := 0; i < numKeys; i++ {
+		key := proto.Key(fmt.Sprintf("key%03d", i))
+		pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			b.Fatal(err)
+		}
+		gets = append(gets, getArgs(key, 1, tc.store.StoreID()))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			bArgs := &proto.BatchRequest{}
+			bArgs.RangeID = 1
+			bArgs.Replica = proto.Replica{StoreID: tc.store.StoreID()}
+			for j := range gets {
+				gArgs := gets[j]
+				bArgs.Add(&gArgs)
+			}
+			if _, err := tc.rng.AddCmd(tc.rng.context(), bArgs); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		for j := range gets {
+			gArgs := gets[j]
+			if _, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.StopTimer()
+}
+
+// BenchmarkMultiGetSequential benchmarks reading 100 keys with one
+// request per key.
+func BenchmarkMultiGetSequential(b *testing.B) {
+	benchmarkMultiGet(b, false)
+}
+
+// BenchmarkMultiGetBatch benchmarks reading 100 keys in a single
+// read-only batch, whose requests are executed concurrently.
+func BenchmarkMultiGetBatch(b *testing.B) {
+	benchmarkMultiGet(b, true)
+}
+
+type mockRangeManager struct {
+	*Store
+	mockProposeRaftCommand func(cmdIDKey, proto.RaftCommand) <-chan error
+}
+
+// ProposeRaftCommand mocks out the corresponding method on the Store.
+func (mrm *mockRangeManager) ProposeRaftCommand(idKey cmdIDKey, cmd proto.RaftCommand) <-chan error {
+	if mrm.mockProposeRaftCommand == nil {
+		return mrm.Store.ProposeRaftCommand(idKey, cmd)
+	}
+	return mrm.mockProposeRaftCommand(idKey, cmd)
+}
+
+// TestRequestLeaderEncounterGroupDeleteError verifies that a request leader proposal which fails with
+// multiraft.ErrGroupDeleted is converted to a RangeNotFoundError in the Store.
+func TestRequestLeaderEncounterGroupDeleteError(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{
+		rng: &Replica{},
+	}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Mock the RangeManager, simulate proposeRaftCommand return an ErrGroupDeleted error.
+	proposeRaftCommandFn := func(cmdIDKey, proto.RaftCommand) <-chan error {
+		ch := make(chan error, 1)
+		ch <- multiraft.ErrGroupDeleted
+		return ch
+	}
+	testRangeManager := &mockRangeManager{
+		Store: tc.store,
+		mockProposeRaftCommand: proposeRaftCommandFn,
+	}
+	rng, err := NewReplica(testRangeDescriptor(), testRangeManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.store.AddReplicaTest(rng); err != nil {
+		t.Fatal(err)
+	}
+
+	gArgs := getArgs(proto.Key("a"), 1, tc.store.StoreID())
+	// Force the read command request a new lease.
+	clock := tc.clock
+	gArgs.Header().Timestamp = clock.Update(clock.Now().Add(int64(DefaultLeaderLeaseDuration), 0))
+	_, err = tc.store.ExecuteCmd(context.Background(), &gArgs)
+	if _, ok := err.(*proto.RangeNotFoundError); !ok {
+		t.Fatalf("expected a RangeNotFoundError, get %s", err)
+	}
+}
+
+// TestRaftCommandFutureVersion verifies that a Raft command with a
+// version newer than the node supports is rejected with an error when
+// applied instead of being executed, and that the range continues to
+// apply subsequent commands.
+func TestRaftCommandFutureVersion(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{
+		rng: &Replica{},
+	}
+	tc.Start(t)
+	defer tc.Stop()
+
+	// Mock the RangeManager to propose commands with a future version
+	// while futureVersion is set.
+	var futureVersion int32
+	testRangeManager := &mockRangeManager{
+		Store: tc.store,
+		mockProposeRaftCommand: func(idKey cmdIDKey, cmd proto.RaftCommand) <-chan error {
+			if atomic.LoadInt32(&futureVersion) != 0 {
+				cmd.Version = proto.RaftCommandVersion + 1
+			}
+			return tc.store.ProposeRaftCommand(idKey, cmd)
+		},
+	}
+	rng, err := NewReplica(testRangeDescriptor(), testRangeManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.store.AddReplicaTest(rng); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key string) error {
+		pArgs := putArgs(proto.Key(key), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		_, err := rng.AddCmd(rng.context(), &pArgs)
+		return err
+	}
+	if err := put("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&futureVersion, 1)
+	if err := put("b"); !testutils.IsError(err, "cannot apply Put command of version") {
+		t.Fatalf("expected version error; got %v", err)
+	}
+	atomic.StoreInt32(&futureVersion, 0)
+
+	// The rejected put must not have been executed.
+	gArgs := getArgs(proto.Key("b"), 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	reply, err := rng.AddCmd(rng.context(), &gArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := reply.(*proto.GetResponse).Value; v != nil {
+		t.Errorf("expected rejected put not to be applied; got %s", v)
+	}
+	if err := put("c"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntentIntersect(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	iPt := proto.Intent{
+		Key:    proto.Key("asd"),
+		EndKey: nil,
+	}
+	iRn := proto.Intent{
+		Key:    proto.Key("c"),
+		EndKey: proto.Key("x"),
+	}
+
+	suffix := proto.Key("abcd")
+	iLc := proto.Intent{
+		Key:    keys.MakeRangeKey(proto.Key("c"), suffix, nil),
+		EndKey: keys.MakeRangeKey(proto.Key("x"), suffix, nil),
+	}
+	kl1 := string(iLc.Key)
+	kl2 := string(iLc.EndKey)
+
+	for i, tc := range []struct {
+		intent   proto.Intent
+		from, to string
+		exp      []string
+	}{
+		{intent: iPt, from: "", to: "z", exp: []string{"", ""}},
+
+		{intent: iRn, from: "", to: "a", exp: []string{"", "", "c", "x"}},
+		{intent: iRn, from: "", to: "c", exp: []string{"", "", "c", "x"}},
+		{intent: iRn, from: "a", to: "z", exp: []string{"c", "x"}},
+		{intent: iRn, from: "c", to: "d", exp: []string{"c", "d", "d", "x"}},
+		{intent: iRn, from: "c", to: "x", exp: []string{"c", "x"}},
+		{intent: iRn, from: "d", to: "x", exp: []string{"d", "x", "c", "d"}},
+		{intent: iRn, from: "d", to: "w", exp: []string{"d", "w", "c", "d", "w", "x"}},
+		{intent: iRn, from: "c", to: "w", exp: []string{"c", "w", "w", "x"}},
+		{intent: iRn, from: "w", to: "x", exp: []string{"w", "x", "c", "w"}},
+		{intent: iRn, from: "x", to: "z", exp: []string{"", "", "c", "x"}},
+		{intent: iRn, from: "y", to: "z", exp: []string{"", "", "c", "x"}},
+
+		// A local intent range always comes back in one piece, either inside
+		// or outside of the Range.
+		{intent: iLc, from: "a", to: "b", exp: []string{"", "", kl1, kl2}},
+		{intent: iLc, from: "d", to: "z", exp: []string{"", "", kl1, kl2}},
+		{intent: iLc, from: "f", to: "g", exp: []string{"", "", kl1, kl2}},
+		{intent: iLc, from: "c", to: "x", exp: []string{kl1, kl2}},
+		{intent: iLc, from: "a", to: "z", exp: []string{kl1, kl2}},
+	} {
+		var all []string
+		in, out := intersectIntent(tc.intent, proto.RangeDescriptor{
+			StartKey: proto.Key(tc.from),
+			EndKey:   proto.Key(tc.to),
+		})
+		if in != nil {
+			all = append(all, string(in.Key), string(in.EndKey))
+		} else {
+			all = append(all, "", "")
+		}
+		for _, o := range out {
+			all = append(all, string(o.Key), string(o.EndKey))
+		}
+		if !reflect.DeepEqual(all, tc.exp) {
+			t.Errorf("%d: wanted %v, got %v", i, tc.exp, all)
+		}
 	}
 }
 
-// TestChangeReplicasDuplicateError tests that a replica change that would
-// use a NodeID twice in the replica configuration fails.
-func TestChangeReplicasDuplicateError(t *testing.T) {
+// TestRangeReconcileDescriptor verifies that a stale local range
+// descriptor is replaced by the authoritative one read through the DB.
+func TestRangeReconcileDescriptor(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	if err := tc.rng.ChangeReplicas(proto.ADD_REPLICA, proto.Replica{
-		NodeID:  tc.store.Ident.NodeID,
-		StoreID: 9999,
-	}, tc.rng.Desc()); err == nil || !strings.Contains(err.Error(),
-		"already present") {
-		t.Fatalf("must not be able to add second replica to same node (err=%s)",
-			err)
+	// The authoritative descriptor has an additional replica which the
+	// local one is missing.
+	newDesc := *tc.rng.Desc()
+	newDesc.Replicas = append(append([]proto.Replica(nil), newDesc.Replicas...),
+		proto.Replica{NodeID: 2, StoreID: 2, ReplicaID: 2})
+	newDesc.NextReplicaID = 3
+	descKey := keys.RangeDescriptorKey(newDesc.StartKey)
+
+	origDB := tc.store.ctx.DB
+	defer func() { tc.store.ctx.DB = origDB }()
+	tc.store.ctx.DB = client.NewDB(client.SenderFunc(func(_ context.Context, call proto.Call) {
+		args, ok := call.Args.(*proto.GetRequest)
+		if !ok || !args.Key.Equal(descKey) {
+			call.Reply.Header().SetGoError(util.Errorf("unexpected call %s", call.Method()))
+			return
+		}
+		data, err := gogoproto.Marshal(&newDesc)
+		if err != nil {
+			call.Reply.Header().SetGoError(err)
+			return
+		}
+		call.Reply.(*proto.GetResponse).Value = &proto.Value{Bytes: data}
+	}))
+
+	changed, err := tc.rng.reconcileDescriptor(tc.rng.context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected the stale descriptor to be updated")
+	}
+	if desc := tc.rng.Desc(); !reflect.DeepEqual(desc, &newDesc) {
+		t.Errorf("expected descriptor %+v; got %+v", &newDesc, desc)
 	}
-}
 
-// TestRangeDanglingMetaIntent creates a dangling intent on a meta2
-// record and verifies that RangeLookup requests behave
-// appropriately. Normally, the old value and a write intent error
-// should be returned. If IgnoreIntents is specified, then a random
-// choice of old or new is returned with no error.
-func TestRangeDanglingMetaIntent(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	// Test RangeLookup with Scan.
-	testRangeDanglingMetaIntent(t, false)
-	// Test RangeLookup with ReverseScan.
-	testRangeDanglingMetaIntent(t, true)
+	// The descriptor is now current, so nothing changes.
+	if changed, err := tc.rng.reconcileDescriptor(tc.rng.context()); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Error("expected no change to an up-to-date descriptor")
+	}
+
+	// A descriptor with different bounds is refused, as the store's index
+	// of its replicas by key would no longer match.
+	newDesc.EndKey = proto.Key("m")
+	if changed, err := tc.rng.reconcileDescriptor(tc.rng.context()); err == nil {
+		t.Error("expected a change of bounds to be refused")
+	} else if changed {
+		t.Error("expected the descriptor not to be updated")
+	}
+	if desc := tc.rng.Desc(); desc.EndKey.Equal(newDesc.EndKey) {
+		t.Errorf("expected the range's bounds to be unchanged; got %+v", desc)
+	}
+	if rng := tc.store.LookupReplica(proto.Key("n"), nil); rng != tc.rng {
+		t.Errorf("expected the store to still map keys past the refused end key to the range")
+	}
 }
 
-func testRangeDanglingMetaIntent(t *testing.T, isReverse bool) {
+// TestRangeValueChecksums verifies that values written to a range whose
+// zone asks for value checksums are checksummed, and that reading a
+// value which was corrupted on disk fails with a replica corruption
+// error.
+func TestRangeValueChecksums(t *testing.T) {
+	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
+	tc.rng.SetValueChecksums(true)
 	key := proto.Key("a")
-
-	// Get original meta2 descriptor.
-	rlArgs := &proto.RangeLookupRequest{
-		RequestHeader: proto.RequestHeader{
-			Key:             keys.RangeMetaKey(key),
-			RangeID:         tc.rng.Desc().RangeID,
-			Replica:         proto.Replica{StoreID: tc.store.StoreID()},
-			ReadConsistency: proto.INCONSISTENT,
-		},
-		MaxRanges: 1,
-		Reverse:   isReverse,
-	}
-
-	var rlReply *proto.RangeLookupResponse
-
-	reply, err := tc.rng.AddCmd(tc.rng.context(), rlArgs)
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
 	if err != nil {
 		t.Fatal(err)
 	}
-	rlReply = reply.(*proto.RangeLookupResponse)
-
-	origDesc := rlReply.Ranges[0]
-	newDesc := origDesc
-	newDesc.EndKey = key
+	ts := reply.Header().Timestamp
 
-	// Write the new descriptor as an intent.
-	data, err := gogoproto.Marshal(&newDesc)
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	reply, err = tc.rng.AddCmd(tc.rng.context(), &gArgs)
 	if err != nil {
 		t.Fatal(err)
 	}
-	pArgs := putArgs(keys.RangeMetaKey(key), data, 1, tc.store.StoreID())
-	pArgs.Txn = newTransaction("test", key, 1, proto.SERIALIZABLE, tc.clock)
-	pArgs.Timestamp = pArgs.Txn.Timestamp
-
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
-		t.Fatal(err)
+	if v := reply.(*proto.GetResponse).Value; v == nil || v.Checksum == nil {
+		t.Fatalf("expected a checksummed value; got %+v", v)
 	}
 
-	// Now lookup the range; should get the value. Since the lookup is
-	// inconsistent, there's no WriteIntentErorr.
-	rlArgs.Key = keys.RangeMetaKey(proto.Key("A"))
-	rlArgs.Timestamp = proto.ZeroTimestamp
-
-	reply, err = tc.rng.AddCmd(tc.rng.context(), rlArgs)
+	// Flip a bit of the stored value, leaving its checksum alone.
+	versionKey := engine.MVCCEncodeVersionKey(key, ts)
+	data, err := tc.engine.Get(versionKey)
 	if err != nil {
-		t.Errorf("unexpected lookup error: %s", err)
+		t.Fatal(err)
 	}
-	rlReply = reply.(*proto.RangeLookupResponse)
-	if !reflect.DeepEqual(rlReply.Ranges[0], origDesc) {
-		t.Errorf("expected original descriptor %s; got %s", &origDesc, &rlReply.Ranges[0])
+	var mvccVal engine.MVCCValue
+	if err := gogoproto.Unmarshal(data, &mvccVal); err != nil {
+		t.Fatal(err)
 	}
-
-	// Switch to consistent lookups, which should run into the intent.
-	rlArgs.ReadConsistency = proto.CONSISTENT
-	_, err = tc.rng.AddCmd(tc.rng.context(), rlArgs)
-	if _, ok := err.(*proto.WriteIntentError); !ok {
-		t.Fatalf("expected WriteIntentError, not %s", err)
+	mvccVal.Value.Bytes[0] ^= 1
+	if data, err = gogoproto.Marshal(&mvccVal); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.engine.Put(versionKey, data); err != nil {
+		t.Fatal(err)
 	}
 
-	// Try 100 lookups with IgnoreIntents. Expect to see each descriptor at least once.
-	// First, try this consistently, which should not be allowed.
-	rlArgs.IgnoreIntents = true
-	_, err = tc.rng.AddCmd(tc.rng.context(), rlArgs)
-	if !testutils.IsError(err, "can not read consistently and skip intents") {
-		t.Fatalf("wanted specific error, not %s", err)
+	gArgs.Timestamp = tc.clock.Now()
+	sArgs := scanArgs(key, proto.Key("b"), 1, tc.store.StoreID())
+	sArgs.Timestamp = tc.clock.Now()
+	for _, args := range []proto.Request{&gArgs, &sArgs} {
+		if _, err := tc.rng.AddCmd(tc.rng.context(), args); err == nil {
+			t.Errorf("%s: expected the corrupted value to be detected", args.Method())
+		} else if _, ok := err.(*replicaCorruptionError); !ok {
+			t.Errorf("%s: expected a replica corruption error; got %T: %s", args.Method(), err, err)
+		}
 	}
-	// After changing back to inconsistent lookups, should be good to go.
-	rlArgs.ReadConsistency = proto.INCONSISTENT
+}
 
-	var origSeen, newSeen bool
-	const count = 100
+// TestRangeRejectsBadValueChecksum verifies that a put whose value
+// carries a checksum which doesn't match its contents is rejected
+// before it is proposed, so that it can't be mistaken for corruption
+// when it's read.
+func TestRangeRejectsBadValueChecksum(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-	for i := 0; i < count && !(origSeen && newSeen); i++ {
-		clonedRLArgs := gogoproto.Clone(rlArgs).(*proto.RangeLookupRequest)
-		clonedRLArgs.Timestamp = proto.ZeroTimestamp
+	key := proto.Key("a")
+	pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	pArgs.Value.InitChecksum(key)
+	pArgs.Value.Bytes = []byte("other")
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err == nil {
+		t.Fatal("expected the put to be rejected")
+	} else if _, ok := err.(*proto.ChecksumError); !ok {
+		t.Fatalf("expected a ChecksumError; got %T: %s", err, err)
+	}
 
-		reply, err = tc.rng.AddCmd(tc.rng.context(), clonedRLArgs)
-		if err != nil {
-			t.Fatal(err)
-		}
-		rlReply = reply.(*proto.RangeLookupResponse)
-		seen := rlReply.Ranges[0]
-		if reflect.DeepEqual(seen, origDesc) {
-			origSeen = true
-		} else if reflect.DeepEqual(seen, newDesc) {
-			newSeen = true
-		} else {
-			t.Errorf("expected orig/new descriptor %s/%s; got %s", &origDesc, &newDesc, &seen)
-		}
+	gArgs := getArgs(key, 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !(origSeen && newSeen) {
-		t.Errorf("didn't see both descriptors after %d attempts", count)
+	if v := reply.(*proto.GetResponse).Value; v != nil {
+		t.Errorf("expected no value to be written; got %+v", v)
 	}
 }
 
-// TestRangeLookupUseReverseScan verifies the correctness of the results which are retrived
-// from RangeLookup by using ReverseScan.
-func TestRangeLookupUseReverseScan(t *testing.T) {
+// TestRangeLastApplied verifies that the summary of the last applied
+// command reflects the most recent write, including its error.
+func TestRangeLastApplied(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
 
-	// Test ranges: ["a","c"), ["c","f"), ["f","h") and ["h","y").
-	testRanges := []proto.RangeDescriptor{
-		{RangeID: 2, StartKey: proto.Key("a"), EndKey: proto.Key("c")},
-		{RangeID: 3, StartKey: proto.Key("c"), EndKey: proto.Key("f")},
-		{RangeID: 4, StartKey: proto.Key("f"), EndKey: proto.Key("h")},
-		{RangeID: 5, StartKey: proto.Key("h"), EndKey: proto.Key("y")},
+	pArgs := putArgs(proto.Key("a"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+		t.Fatal(err)
+	}
+	info := tc.rng.LastApplied()
+	if index := atomic.LoadUint64(&tc.rng.appliedIndex); info.Index != index {
+		t.Errorf("expected index %d; got %d", index, info.Index)
+	}
+	if info.Method != proto.Put || !info.Key.Equal(pArgs.Key) || !info.Timestamp.Equal(pArgs.Timestamp) || info.Error != "" {
+		t.Errorf("unexpected summary of %s: %+v", pArgs.Method(), info)
 	}
-	// The range ["f","h") has dangling intent in meta2.
-	withIntentRangeIndex := 2
 
-	testCases := []struct {
-		key      string
-		expected proto.RangeDescriptor
-	}{
-		// For testRanges[0|1|3] there is no intent. A key in the middle
-		// and the end key should both give us the range itself.
-		{key: "b", expected: testRanges[0]},
-		{key: "c", expected: testRanges[0]},
-		{key: "d", expected: testRanges[1]},
-		{key: "f", expected: testRanges[1]},
-		{key: "j", expected: testRanges[3]},
-		// testRanges[2] has an intent, so the inconsistent scan will read
-		// an old value (nil). Since we're in reverse mode, testRanges[1]
-		// is the result.
-		{key: "g", expected: testRanges[1]},
-		{key: "h", expected: testRanges[1]},
+	// A failed command is applied as well.
+	cpArgs := cPutArgs(proto.Key("a"), []byte("new"), []byte("other"), 1, tc.store.StoreID())
+	cpArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &cpArgs); err == nil {
+		t.Fatal("expected the conditional put to fail")
+	}
+	prevIndex := info.Index
+	info = tc.rng.LastApplied()
+	if info.Index <= prevIndex || info.Method != proto.ConditionalPut || !info.Timestamp.Equal(cpArgs.Timestamp) || info.Error == "" {
+		t.Errorf("unexpected summary of %s: %+v", cpArgs.Method(), info)
 	}
+}
 
-	txn := &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: tc.clock.Now()}
-	for i, r := range testRanges {
-		if i != withIntentRangeIndex {
-			// Write the new descriptor as an intent.
-			data, err := gogoproto.Marshal(&r)
-			if err != nil {
-				t.Fatal(err)
-			}
-			pArgs := putArgs(keys.RangeMetaKey(r.EndKey), data,
-				tc.rng.Desc().RangeID, tc.store.StoreID())
-			pArgs.Txn = txn
+// TestRangeMetricsSnapshot verifies that a metrics snapshot reflects the
+// activity on the replica consistently with the individual accessors.
+func TestRangeMetricsSnapshot(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-			if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
-				t.Fatal(err)
-			}
+	// Move past any interval containing commands from the test setup.
+	tc.manualClock.Increment(int64(2 * qpsInterval))
+	const numPuts = 5
+	for i := 0; i < numPuts; i++ {
+		pArgs := putArgs(proto.Key(fmt.Sprintf("a%d", i)), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
 		}
 	}
 
-	// Resolve the intents.
-	rArgs := &proto.ResolveIntentRangeRequest{
-		RequestHeader: proto.RequestHeader{
-			Timestamp: txn.Timestamp,
-			Key:       keys.RangeMetaKey(proto.Key("a")),
-			EndKey:    keys.RangeMetaKey(proto.Key("z")),
-			RangeID:   tc.rng.Desc().RangeID,
-			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
-			Txn:       txn,
-		},
+	m := tc.rng.MetricsSnapshot()
+	if m.RangeID != tc.rng.Desc().RangeID {
+		t.Errorf("expected range ID %d; got %d", tc.rng.Desc().RangeID, m.RangeID)
 	}
-	rArgs.Txn.Status = proto.COMMITTED
-	if _, err := tc.rng.AddCmd(tc.rng.context(), rArgs); err != nil {
-		t.Fatal(err)
+	if stats := tc.rng.GetMVCCStats(); !reflect.DeepEqual(m.MVCCStats, stats) {
+		t.Errorf("expected MVCC stats %+v; got %+v", stats, m.MVCCStats)
+	}
+	if m.MVCCStats.LiveCount < numPuts {
+		t.Errorf("expected at least %d live keys; got %d", numPuts, m.MVCCStats.LiveCount)
+	}
+	if m.PendingCmds != 0 {
+		t.Errorf("expected no pending commands; got %d", m.PendingCmds)
+	}
+	if m.AppliedIndex == 0 || m.AppliedIndex != m.LastIndex {
+		t.Errorf("expected all of the log to be applied; got applied index %d, last index %d",
+			m.AppliedIndex, m.LastIndex)
+	}
+	if !m.LeaseValid || m.Lease.RaftNodeID != tc.store.RaftNodeID() {
+		t.Errorf("expected a valid lease held by this replica; got %+v (valid=%t)", m.Lease, m.LeaseValid)
+	}
+	if m.Contention != tc.rng.ContentionStats() {
+		t.Errorf("expected contention stats %+v; got %+v", tc.rng.ContentionStats(), m.Contention)
+	}
+	// The interval in which the puts happened hasn't completed yet.
+	if m.QPS != 0 {
+		t.Errorf("expected no rate before the interval completes; got %f", m.QPS)
 	}
 
-	// Get original meta2 descriptor.
-	rlArgs := &proto.RangeLookupRequest{
-		RequestHeader: proto.RequestHeader{
-			RangeID:         tc.rng.Desc().RangeID,
-			Replica:         proto.Replica{StoreID: tc.store.StoreID()},
-			ReadConsistency: proto.INCONSISTENT,
-		},
-		MaxRanges: 1,
-		Reverse:   true,
+	tc.manualClock.Increment(int64(qpsInterval))
+	if m := tc.rng.MetricsSnapshot(); m.QPS != numPuts/qpsInterval.Seconds() {
+		t.Errorf("expected a rate of %f; got %f", numPuts/qpsInterval.Seconds(), m.QPS)
 	}
-	var rlReply *proto.RangeLookupResponse
+}
 
-	// Test ReverseScan without intents.
-	for _, c := range testCases {
-		clonedRLArgs := gogoproto.Clone(rlArgs).(*proto.RangeLookupRequest)
-		clonedRLArgs.Timestamp = proto.ZeroTimestamp
-		clonedRLArgs.Key = keys.RangeMetaKey(proto.Key(c.key))
-		reply, err := tc.rng.AddCmd(tc.rng.context(), clonedRLArgs)
-		if err != nil {
-			t.Fatal(err)
-		}
-		rlReply = reply.(*proto.RangeLookupResponse)
-		seen := rlReply.Ranges[0]
-		if !(seen.StartKey.Equal(c.expected.StartKey) && seen.EndKey.Equal(c.expected.EndKey)) {
-			t.Errorf("expected descriptor %s; got %s", &c.expected, &seen)
-		}
+// TestRangeMultiIncrement verifies that a MultiIncrement command
+// increments each of its keys by the respective amount, and that it
+// leaves all keys untouched if any of the increments fails.
+func TestRangeMultiIncrement(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	iArgs := incrementArgs([]byte("b"), 10, 1, tc.store.StoreID())
+	iArgs.Timestamp = tc.clock.Now()
+	if _, err := tc.rng.AddCmd(tc.rng.context(), &iArgs); err != nil {
+		t.Fatal(err)
 	}
 
-	// Write the new descriptor as an intent.
-	intentRange := testRanges[withIntentRangeIndex]
-	data, err := gogoproto.Marshal(&intentRange)
+	keys := []proto.Key{proto.Key("c"), proto.Key("a"), proto.Key("b")}
+	call := proto.MultiIncrementCall(keys, []int64{3, 1, 2})
+	mArgs := call.Args.(*proto.MultiIncrementRequest)
+	mArgs.RangeID = 1
+	mArgs.Replica = proto.Replica{StoreID: tc.store.StoreID()}
+	mArgs.Timestamp = tc.clock.Now()
+	reply, err := tc.rng.AddCmd(tc.rng.context(), mArgs)
 	if err != nil {
 		t.Fatal(err)
 	}
-	pArgs := putArgs(keys.RangeMetaKey(intentRange.EndKey), data, 1, tc.store.StoreID())
-	pArgs.Txn = &proto.Transaction{ID: uuid.NewUUID4(), Timestamp: tc.clock.Now()}
-	pArgs.Timestamp = pArgs.Txn.Timestamp
-	if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
-		t.Fatal(err)
+	expValues := []int64{3, 1, 12}
+	if newValues := reply.(*proto.MultiIncrementResponse).NewValues; !reflect.DeepEqual(newValues, expValues) {
+		t.Errorf("expected new values %v; got %v", expValues, newValues)
 	}
 
-	// Test ReverseScan with intents.
-	for _, c := range testCases {
-		clonedRLArgs := gogoproto.Clone(rlArgs).(*proto.RangeLookupRequest)
-		clonedRLArgs.Timestamp = proto.ZeroTimestamp
-		clonedRLArgs.Key = keys.RangeMetaKey(proto.Key(c.key))
-		reply, err := tc.rng.AddCmd(tc.rng.context(), clonedRLArgs)
-		if err != nil {
-			t.Fatal(err)
-		}
-		rlReply = reply.(*proto.RangeLookupResponse)
-		seen := rlReply.Ranges[0]
-		if !(seen.StartKey.Equal(c.expected.StartKey) && seen.EndKey.Equal(c.expected.EndKey)) {
-			t.Errorf("expected descriptor %s; got %s", &c.expected, &seen)
+	verify := func(expValues []int64) {
+		for i, key := range keys {
+			gArgs := getArgs(key, 1, tc.store.StoreID())
+			gArgs.Timestamp = tc.clock.Now()
+			reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err := reply.(*proto.GetResponse).Value.GetInteger(); err != nil {
+				t.Fatal(err)
+			} else if v != expValues[i] {
+				t.Errorf("%s: expected %d; got %d", key, expValues[i], v)
+			}
 		}
 	}
+	verify(expValues)
+
+	// An increment of a key outside of the request's span fails after
+	// the first key was incremented, which must not take effect.
+	mArgs = &proto.MultiIncrementRequest{
+		RequestHeader: proto.RequestHeader{
+			Key:       proto.Key("a"),
+			EndKey:    proto.Key("b"),
+			RangeID:   1,
+			Replica:   proto.Replica{StoreID: tc.store.StoreID()},
+			Timestamp: tc.clock.Now(),
+		},
+		Keys:       []proto.Key{proto.Key("a"), proto.Key("c")},
+		Increments: []int64{1, 1},
+	}
+	if _, err := tc.rng.AddCmd(tc.rng.context(), mArgs); !testutils.IsError(err, "outside of the request span") {
+		t.Fatalf("expected an error for a key outside of the span; got %v", err)
+	}
+	verify(expValues)
 }
 
-func TestRangeLookup(t *testing.T) {
+// TestRangeTxnIntentLimit verifies that writes of a transaction which
+// has written the maximum number of intents fail, that such a
+// transaction can't commit once it wrote more, and that the intent
+// count is kept in the transaction record.
+func TestRangeTxnIntentLimit(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	tc := testContext{}
 	tc.Start(t)
 	defer tc.Stop()
-	for _, key := range []proto.Key{
-		// Test with the first range (StartKey==KeyMin). Normally we look
-		// up this range in gossip instead of executing the RPC, but
-		// RangeLookup is still used when up-to-date information is
-		// required.
-		proto.KeyMin,
-		// Test with the last key in a meta prefix. This is an edge case in the
-		// implementation.
-		proto.MakeKey(keys.Meta1Prefix, proto.KeyMax),
-	} {
-		resp, err := tc.store.ExecuteCmd(context.Background(), &proto.RangeLookupRequest{
-			RequestHeader: proto.RequestHeader{
-				RangeID: 1,
-				Key:     key,
-			},
-			MaxRanges: 1,
-		})
+	const maxIntents = 3
+	tc.store.ctx.MaxTxnIntents = maxIntents
+
+	newTxn := func(key string, intentCount int64) *proto.Transaction {
+		txn := newTransaction("test", proto.Key(key), 1, proto.SERIALIZABLE, tc.clock)
+		txn.IntentCount = intentCount
+		return txn
+	}
+	put := func(key string, txn *proto.Transaction) error {
+		pArgs := putArgs(proto.Key(key), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Txn = txn
+		if txn != nil {
+			pArgs.Timestamp = txn.Timestamp
+		} else {
+			pArgs.Timestamp = tc.clock.Now()
+		}
+		_, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+		return err
+	}
+	endTxn := func(txn *proto.Transaction, commit bool) (*proto.Transaction, error) {
+		etArgs := endTxnArgs(txn, commit, 1, tc.store.StoreID())
+		etArgs.Timestamp = txn.Timestamp
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &etArgs)
 		if err != nil {
-			t.Fatal(err)
+			return nil, err
 		}
-		reply := resp.(*proto.RangeLookupResponse)
-		expected := []proto.RangeDescriptor{*tc.rng.Desc()}
-		if !reflect.DeepEqual(reply.Ranges, expected) {
-			t.Fatalf("expected %+v, got %+v", expected, reply.Ranges)
+		return reply.(*proto.EndTransactionResponse).Txn, nil
+	}
+	expTooLarge := func(err error, intentCount int64) {
+		if tErr, ok := err.(*proto.TxnTooLargeError); !ok {
+			t.Fatalf("expected a TxnTooLargeError; got %v", err)
+		} else if tErr.IntentCount != intentCount || tErr.MaxIntents != maxIntents {
+			t.Errorf("expected %d of %d intents; got %+v", intentCount, maxIntents, tErr)
 		}
 	}
-}
-
-// benchmarkEvents is designed to determine the impact of sending events on the
-// performance of write commands. This benchmark can be run with or without
-// events, and with or without a consumer reading the events.
-func benchmarkEvents(b *testing.B, sendEvents, consumeEvents bool) {
-	defer leaktest.AfterTest(b)
-	tc := testContext{}
 
-	stopper := stop.NewStopper()
-	if sendEvents {
-		tc.feed = util.NewFeed(stopper)
+	// Writes up to the limit succeed; further writes fail.
+	if err := put("a", newTxn("a", maxIntents-1)); err != nil {
+		t.Fatal(err)
 	}
-	eventC := 0
-	if consumeEvents {
-		tc.feed.Subscribe(func(_ interface{}) {
-			eventC++
-		})
+	expTooLarge(put("b", newTxn("b", maxIntents)), maxIntents)
+	// Non-transactional writes are unaffected.
+	if err := put("c", nil); err != nil {
+		t.Fatal(err)
 	}
 
-	tc.Start(b)
-	defer tc.Stop()
-
-	args := incrementArgs([]byte("a"), 1, 1, tc.store.StoreID())
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := tc.rng.AddCmd(tc.rng.context(), &args)
+	// A transaction which wrote more intents can't commit, but it can be
+	// aborted.
+	txn := newTxn("d", maxIntents+1)
+	_, err := endTxn(txn, true)
+	expTooLarge(err, maxIntents+1)
+	if _, err := endTxn(txn, false); err != nil {
+		t.Fatal(err)
+	}
 
+	// A transaction within the limit commits, and its record keeps the
+	// count.
+	if record, err := endTxn(newTxn("e", maxIntents), true); err != nil {
+		t.Fatal(err)
+	} else if record.Status != proto.COMMITTED || record.IntentCount != maxIntents {
+		t.Errorf("expected a committed record with %d intents; got %+v", maxIntents, record)
+	}
+
+	// Heartbeats keep the largest count reported by the coordinator.
+	txn = newTxn("f", 0)
+	for _, intentCount := range []int64{maxIntents + 1, 1} {
+		hbTxn := gogoproto.Clone(txn).(*proto.Transaction)
+		hbTxn.IntentCount = intentCount
+		hbArgs := heartbeatArgs(hbTxn, 1, tc.store.StoreID())
+		hbArgs.Timestamp = tc.clock.Now()
+		reply, err := tc.rng.AddCmd(tc.rng.context(), &hbArgs)
 		if err != nil {
-			b.Fatal(err)
+			t.Fatal(err)
 		}
-	}
-	b.StopTimer()
-
-	stopper.Stop()
-
-	if consumeEvents {
-		// The test expects exactly b.N update events to be sent on the feed.
-		// The '+5' is a constant number of non-update events sent when the
-		// store is first started.
-		if a, e := eventC, b.N+5; a != e {
-			b.Errorf("Unexpected number of events received %d, expected %d", a, e)
+		if record := reply.(*proto.HeartbeatTxnResponse).Txn; record.Status != proto.PENDING ||
+			record.IntentCount != maxIntents+1 {
+			t.Errorf("expected a pending record with %d intents; got %+v", maxIntents+1, record)
 		}
 	}
 }
 
-// BenchmarkWriteCmdNoEvents benchmarks write commands on a store that does not
-// publish events.
-func BenchmarkWriteCmdNoEvents(b *testing.B) {
-	benchmarkEvents(b, false, false)
+// raftStatusStub is a rangeManager which reports a fixed Raft status.
+type raftStatusStub struct {
+	rangeManager
+	status *raft.Status
 }
 
-// BenchmarkWriteCmdNoEvents benchmarks write commands on a store that publishes
-// events. There are no subscribers to the events, but they are still produced.
-func BenchmarkWriteCmdWithEvents(b *testing.B) {
-	benchmarkEvents(b, true, false)
-}
+// RaftStatus implements the rangeManager interface.
+func (s raftStatusStub) RaftStatus(proto.RangeID) *raft.Status { return s.status }
 
-// BenchmarkWriteConsumeEvents benchmarks write commands on a store that publishes
-// events. There is a single subscriber reading the events.
-func BenchmarkWriteCmdWithEventsAndConsumer(b *testing.B) {
-	benchmarkEvents(b, true, true)
+// TestRangeFollowerProgress verifies that the progress of the followers
+// is reported from the Raft status of the leader, and only there.
+func TestRangeFollowerProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
+
+	status := &raft.Status{
+		ID:       1,
+		Progress: map[uint64]raft.Progress{1: {Match: 20}, 2: {Match: 18}, 3: {Match: 7}},
+	}
+	status.SoftState.RaftState = raft.StateLeader
+	rng, err := NewReplica(tc.rng.Desc(), raftStatusStub{rangeManager: tc.store, status: status})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expProgress := map[proto.RaftNodeID]uint64{2: 18, 3: 7}
+	if progress := rng.FollowerProgress(); !reflect.DeepEqual(progress, expProgress) {
+		t.Errorf("expected follower progress %v; got %v", expProgress, progress)
+	}
+
+	status.SoftState.RaftState = raft.StateFollower
+	if progress := rng.FollowerProgress(); progress != nil {
+		t.Errorf("expected no follower progress on a follower; got %v", progress)
+	}
 }
 
-type mockRangeManager struct {
-	*Store
-	mockProposeRaftCommand func(cmdIDKey, proto.RaftCommand) <-chan error
+// raftProgressStub is a rangeManager which reports a Raft status whose
+// follower progress may be updated concurrently.
+type raftProgressStub struct {
+	rangeManager
+	sync.Mutex
+	status raft.Status
 }
 
-// ProposeRaftCommand mocks out the corresponding method on the Store.
-func (mrm *mockRangeManager) ProposeRaftCommand(idKey cmdIDKey, cmd proto.RaftCommand) <-chan error {
-	if mrm.mockProposeRaftCommand == nil {
-		return mrm.Store.ProposeRaftCommand(idKey, cmd)
+// RaftStatus implements the rangeManager interface.
+func (s *raftProgressStub) RaftStatus(proto.RangeID) *raft.Status {
+	s.Lock()
+	defer s.Unlock()
+	status := s.status
+	status.Progress = map[uint64]raft.Progress{}
+	for id, p := range s.status.Progress {
+		status.Progress[id] = p
 	}
-	return mrm.mockProposeRaftCommand(idKey, cmd)
+	return &status
 }
 
-// TestRequestLeaderEncounterGroupDeleteError verifies that a request leader proposal which fails with
-// multiraft.ErrGroupDeleted is converted to a RangeNotFoundError in the Store.
-func TestRequestLeaderEncounterGroupDeleteError(t *testing.T) {
+// setMatch sets the last index persisted by the given follower.
+func (s *raftProgressStub) setMatch(id proto.RaftNodeID, match uint64) {
+	s.Lock()
+	defer s.Unlock()
+	s.status.Progress[uint64(id)] = raft.Progress{Match: match}
+}
+
+// TestRangeQuorumAttrs verifies that a write to a range whose zone sets
+// quorum attributes isn't acknowledged until a replica in the required
+// locality has persisted it, and fails if there's no such replica.
+func TestRangeQuorumAttrs(t *testing.T) {
 	defer leaktest.AfterTest(t)
-	tc := testContext{
-		rng: &Replica{},
-	}
+	tc := testContext{stopper: stop.NewStopper()}
+	rpcContext := rpc.NewContext(&base.Context{}, hlc.NewClock(hlc.UnixNano), tc.stopper)
+	tc.gossip = gossip.New(rpcContext, gossip.TestInterval, gossip.TestBootstrap)
+	tc.storePool = NewStorePool(tc.gossip, TestTimeUntilStoreDeadOff, tc.stopper)
 	tc.Start(t)
 	defer tc.Stop()
+	tc.store.replicateQueue.SetDisabled(true)
+
+	west := proto.Attributes{Attrs: []string{"us-west"}}
+	east := proto.Attributes{Attrs: []string{"us-east"}}
+	newStoreGossiper(tc.gossip).gossipStores([]*proto.StoreDescriptor{
+		{StoreID: 1, Node: proto.NodeDescriptor{NodeID: 1, Attrs: west}},
+		{StoreID: 2, Node: proto.NodeDescriptor{NodeID: 2, Attrs: east}},
+	}, t)
+
+	put := func(key string) error {
+		pArgs := putArgs(proto.Key(key), []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		_, err := tc.rng.AddCmd(tc.rng.context(), &pArgs)
+		return err
+	}
 
-	// Mock the RangeManager, simulate proposeRaftCommand return an ErrGroupDeleted error.
-	proposeRaftCommandFn := func(cmdIDKey, proto.RaftCommand) <-chan error {
-		ch := make(chan error, 1)
-		ch <- multiraft.ErrGroupDeleted
-		return ch
+	// A write is acknowledged right away if this replica is in the
+	// required locality, and fails if no replica is.
+	tc.rng.SetQuorumAttrs(west)
+	if err := put("a"); err != nil {
+		t.Fatal(err)
 	}
-	testRangeManager := &mockRangeManager{
-		Store: tc.store,
-		mockProposeRaftCommand: proposeRaftCommandFn,
+	tc.rng.SetQuorumAttrs(proto.Attributes{Attrs: []string{"eu"}})
+	if err := put("b"); err == nil {
+		t.Fatal("expected write to fail without a replica in the required locality")
+	} else if qErr, ok := err.(*QuorumAckError); !ok {
+		t.Fatalf("expected QuorumAckError; got %v", err)
+	} else if qErr.Replicas != 0 || qErr.CanRetry() {
+		t.Errorf("unexpected error contents: %+v", qErr)
 	}
-	rng, err := NewReplica(testRangeDescriptor(), testRangeManager)
+	// The failed write wasn't proposed.
+	gArgs := getArgs(proto.Key("b"), 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	if reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	} else if v := reply.(*proto.GetResponse).Value; v != nil {
+		t.Errorf("expected the failed write not to be applied; got %+v", v)
+	}
+
+	// On a leader with a follower in the required locality, the write
+	// waits until the follower has persisted it.
+	localID, eastID := proto.MakeRaftNodeID(1, 1), proto.MakeRaftNodeID(2, 2)
+	desc := *tc.rng.Desc()
+	desc.Replicas = append(append([]proto.Replica(nil), desc.Replicas...),
+		proto.Replica{NodeID: 2, StoreID: 2})
+	stub := &raftProgressStub{rangeManager: tc.store}
+	stub.status.ID = uint64(localID)
+	stub.status.SoftState.RaftState = raft.StateLeader
+	stub.status.Progress = map[uint64]raft.Progress{uint64(localID): {Match: 10}, uint64(eastID): {Match: 5}}
+	rng, err := NewReplica(&desc, stub)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := tc.store.AddReplicaTest(rng); err != nil {
+	rng.SetQuorumAttrs(east)
+	matching, err := rng.quorumReplicas()
+	if err != nil {
 		t.Fatal(err)
 	}
-
-	gArgs := getArgs(proto.Key("a"), 1, tc.store.StoreID())
-	// Force the read command request a new lease.
-	clock := tc.clock
-	gArgs.Header().Timestamp = clock.Update(clock.Now().Add(int64(DefaultLeaderLeaseDuration), 0))
-	_, err = tc.store.ExecuteCmd(context.Background(), &gArgs)
-	if _, ok := err.(*proto.RangeNotFoundError); !ok {
-		t.Fatalf("expected a RangeNotFoundError, get %s", err)
+	if !reflect.DeepEqual(matching, []proto.RaftNodeID{eastID}) {
+		t.Fatalf("expected the east replica to be required; got %v", matching)
 	}
-}
 
-func TestIntentIntersect(t *testing.T) {
-	defer leaktest.AfterTest(t)
-	iPt := proto.Intent{
-		Key:    proto.Key("asd"),
-		EndKey: nil,
+	const index = 10
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- rng.waitForQuorumAttrs(context.Background(), index, matching)
+	}()
+	select {
+	case err := <-errChan:
+		t.Fatalf("write acknowledged before the follower persisted it: %v", err)
+	case <-time.After(50 * time.Millisecond):
 	}
-	iRn := proto.Intent{
-		Key:    proto.Key("c"),
-		EndKey: proto.Key("x"),
+	stub.setMatch(eastID, index)
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
 	}
 
-	suffix := proto.Key("abcd")
-	iLc := proto.Intent{
-		Key:    keys.MakeRangeKey(proto.Key("c"), suffix, nil),
-		EndKey: keys.MakeRangeKey(proto.Key("x"), suffix, nil),
+	// A write which isn't persisted in time fails with a retryable error.
+	tc.store.ctx.QuorumAckTimeout = 10 * time.Millisecond
+	if err := rng.waitForQuorumAttrs(context.Background(), index+1, matching); err == nil {
+		t.Fatal("expected the wait to time out")
+	} else if qErr, ok := err.(*QuorumAckError); !ok {
+		t.Fatalf("expected QuorumAckError; got %v", err)
+	} else if qErr.Replicas != 1 || qErr.Index != index+1 || qErr.NotRaftLeader || !qErr.CanRetry() {
+		t.Errorf("unexpected error contents: %+v", qErr)
 	}
-	kl1 := string(iLc.Key)
-	kl2 := string(iLc.EndKey)
 
-	for i, tc := range []struct {
-		intent   proto.Intent
-		from, to string
-		exp      []string
-	}{
-		{intent: iPt, from: "", to: "z", exp: []string{"", ""}},
+	// A lease holder which isn't the Raft leader can't track the follower,
+	// so it refuses to propose the write, and a wait is cut short if the
+	// replica loses leadership. Either way, the error isn't retryable.
+	stub.Lock()
+	stub.status.SoftState.RaftState = raft.StateFollower
+	stub.Unlock()
+	if lease := rng.getLease(); lease.RaftNodeID != localID {
+		t.Fatalf("expected the replica to hold the leader lease; got %+v", lease)
+	}
+	pArgs := putArgs(proto.Key("c"), []byte("value"), 1, tc.store.StoreID())
+	pArgs.Timestamp = tc.clock.Now()
+	if _, err := rng.AddCmd(rng.context(), &pArgs); err == nil {
+		t.Fatal("expected a replica which isn't the Raft leader to refuse the write")
+	} else if qErr, ok := err.(*QuorumAckError); !ok {
+		t.Fatalf("expected QuorumAckError; got %v", err)
+	} else if !qErr.NotRaftLeader || qErr.Index != 0 || qErr.CanRetry() {
+		t.Errorf("unexpected error contents: %+v", qErr)
+	}
+	gArgs = getArgs(proto.Key("c"), 1, tc.store.StoreID())
+	gArgs.Timestamp = tc.clock.Now()
+	if reply, err := tc.rng.AddCmd(tc.rng.context(), &gArgs); err != nil {
+		t.Fatal(err)
+	} else if v := reply.(*proto.GetResponse).Value; v != nil {
+		t.Errorf("expected the refused write not to be applied; got %+v", v)
+	}
+	tc.store.ctx.QuorumAckTimeout = time.Minute
+	if err := rng.waitForQuorumAttrs(context.Background(), index+1, matching); err == nil {
+		t.Fatal("expected the wait to fail without Raft leadership")
+	} else if qErr, ok := err.(*QuorumAckError); !ok {
+		t.Fatalf("expected QuorumAckError; got %v", err)
+	} else if !qErr.NotRaftLeader || qErr.CanRetry() {
+		t.Errorf("unexpected error contents: %+v", qErr)
+	}
+}
 
-		{intent: iRn, from: "", to: "a", exp: []string{"", "", "c", "x"}},
-		{intent: iRn, from: "", to: "c", exp: []string{"", "", "c", "x"}},
-		{intent: iRn, from: "a", to: "z", exp: []string{"c", "x"}},
-		{intent: iRn, from: "c", to: "d", exp: []string{"c", "d", "d", "x"}},
-		{intent: iRn, from: "c", to: "x", exp: []string{"c", "x"}},
-		{intent: iRn, from: "d", to: "x", exp: []string{"d", "x", "c", "d"}},
-		{intent: iRn, from: "d", to: "w", exp: []string{"d", "w", "c", "d", "w", "x"}},
-		{intent: iRn, from: "c", to: "w", exp: []string{"c", "w", "w", "x"}},
-		{intent: iRn, from: "w", to: "x", exp: []string{"w", "x", "c", "w"}},
-		{intent: iRn, from: "x", to: "z", exp: []string{"", "", "c", "x"}},
-		{intent: iRn, from: "y", to: "z", exp: []string{"", "", "c", "x"}},
+// TestRangeSnapshotKeyCompression verifies that the keys of a Raft
+// snapshot are only prefix compressed if the store enables it, and
+// that a compressed snapshot decompresses to the range's data.
+func TestRangeSnapshotKeyCompression(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	tc := testContext{}
+	tc.Start(t)
+	defer tc.Stop()
 
-		// A local intent range always comes back in one piece, either inside
-		// or outside of the Range.
-		{intent: iLc, from: "a", to: "b", exp: []string{"", "", kl1, kl2}},
-		{intent: iLc, from: "d", to: "z", exp: []string{"", "", kl1, kl2}},
-		{intent: iLc, from: "f", to: "g", exp: []string{"", "", kl1, kl2}},
-		{intent: iLc, from: "c", to: "x", exp: []string{kl1, kl2}},
-		{intent: iLc, from: "a", to: "z", exp: []string{kl1, kl2}},
-	} {
-		var all []string
-		in, out := intersectIntent(tc.intent, proto.RangeDescriptor{
-			StartKey: proto.Key(tc.from),
-			EndKey:   proto.Key(tc.to),
-		})
-		if in != nil {
-			all = append(all, string(in.Key), string(in.EndKey))
-		} else {
-			all = append(all, "", "")
+	for _, key := range []proto.Key{proto.Key("table/a"), proto.Key("table/b")} {
+		pArgs := putArgs(key, []byte("value"), 1, tc.store.StoreID())
+		pArgs.Timestamp = tc.clock.Now()
+		if _, err := tc.rng.AddCmd(tc.rng.context(), &pArgs); err != nil {
+			t.Fatal(err)
 		}
-		for _, o := range out {
-			all = append(all, string(o.Key), string(o.EndKey))
+	}
+
+	snapshotData := func() proto.RaftSnapshotData {
+		snap, err := tc.rng.Snapshot()
+		if err != nil {
+			t.Fatal(err)
 		}
-		if !reflect.DeepEqual(all, tc.exp) {
-			t.Errorf("%d: wanted %v, got %v", i, tc.exp, all)
+		var snapData proto.RaftSnapshotData
+		if err := gogoproto.Unmarshal(snap.Data, &snapData); err != nil {
+			t.Fatal(err)
+		}
+		return snapData
+	}
+
+	if snapData := snapshotData(); snapData.PrefixCompressed {
+		t.Fatal("expected snapshot keys not to be compressed by default")
+	}
+
+	tc.store.ctx.CompressSnapshotKeys = true
+	snapData := snapshotData()
+	if !snapData.PrefixCompressed {
+		t.Fatal("expected snapshot keys to be compressed")
+	}
+	shared := 0
+	for _, kv := range snapData.KV {
+		shared += int(kv.SharedPrefixLen)
+	}
+	if shared == 0 {
+		t.Error("expected snapshot keys to share prefixes")
+	}
+	if err := snapData.DecompressKeys(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(snapData.KV); i++ {
+		if bytes.Compare(snapData.KV[i-1].Key, snapData.KV[i].Key) >= 0 {
+			t.Errorf("%d: expected decompressed keys in order; got %q after %q",
+				i, snapData.KV[i].Key, snapData.KV[i-1].Key)
 		}
 	}
 }
@@ -69,7 +69,7 @@ func (rq replicateQueue) shouldQueue(now proto.Timestamp, repl *Replica) (should
 	}
 
 	// Load the zone config to find the desired replica attributes.
-	zone, err := lookupZoneConfig(rq.gossip, repl)
+	zone, err := repl.effectiveZoneConfig()
 	if err != nil {
 		log.Error(err)
 		return
@@ -97,7 +97,7 @@ func (rq replicateQueue) shouldQueue(now proto.Timestamp, repl *Replica) (should
 	return true, float64(0 - delta)
 }
 
-func (rq *replicateQueue) replicaDelta(zone config.ZoneConfig, repl *Replica,
+func (rq *replicateQueue) replicaDelta(zone *config.ZoneConfig, repl *Replica,
 	desc *proto.RangeDescriptor) int {
 	// TODO(bdarnell): handle non-empty ReplicaAttrs.
 	need := len(zone.ReplicaAttrs)
@@ -107,7 +107,7 @@ func (rq *replicateQueue) replicaDelta(zone config.ZoneConfig, repl *Replica,
 }
 
 func (rq replicateQueue) process(now proto.Timestamp, repl *Replica) error {
-	zone, err := lookupZoneConfig(rq.gossip, repl)
+	zone, err := repl.effectiveZoneConfig()
 	if err != nil {
 		return err
 	}
@@ -19,6 +19,7 @@ package storage
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 
 	"github.com/cockroachdb/cockroach/keys"
@@ -163,6 +164,25 @@ func (rc *ResponseCache) CopyFrom(e engine.Engine, originRangeID proto.RangeID)
 	})
 }
 
+// Checksum returns a sha256 checksum over the raw keys and values of
+// all entries in the persistent cache, in key order. Replicas of the
+// same range are expected to produce identical checksums.
+func (rc *ResponseCache) Checksum(e engine.Engine) ([]byte, error) {
+	prefix := keys.ResponseCacheKey(rc.rangeID, nil) // response cache prefix
+	start := engine.MVCCEncodeKey(prefix)
+	end := engine.MVCCEncodeKey(prefix.PrefixEnd())
+
+	sha := sha256.New()
+	if err := e.Iterate(start, end, func(kv proto.RawKeyValue) (bool, error) {
+		sha.Write(kv.Key)
+		sha.Write(kv.Value)
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+	return sha.Sum(nil), nil
+}
+
 // PutResponse writes a response and an error associated with it to the
 // cache for the specified cmdID.
 func (rc *ResponseCache) PutResponse(e engine.Engine, cmdID proto.ClientCmdID, replyWithErr proto.ResponseWithError) error {
@@ -22,7 +22,6 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/client"
-	"github.com/cockroachdb/cockroach/config"
 	"github.com/cockroachdb/cockroach/gossip"
 	"github.com/cockroachdb/cockroach/proto"
 	"github.com/cockroachdb/cockroach/util"
@@ -70,7 +69,7 @@ func (sq *splitQueue) shouldQueue(now proto.Timestamp, rng *Replica) (shouldQ bo
 
 	// Add priority based on the size of range compared to the max
 	// size for the zone it's in.
-	zone, err := lookupZoneConfig(sq.gossip, rng)
+	zone, err := rng.effectiveZoneConfig()
 	if err != nil {
 		log.Error(err)
 		return
@@ -96,7 +95,7 @@ func (sq *splitQueue) process(now proto.Timestamp, rng *Replica) error {
 		return nil
 	}
 	// Next handle case of splitting due to size.
-	zone, err := lookupZoneConfig(sq.gossip, rng)
+	zone, err := rng.effectiveZoneConfig()
 	if err != nil {
 		return err
 	}
@@ -154,13 +153,3 @@ func computeSplitKeys(g *gossip.Gossip, repl *Replica) []proto.Key {
 	}
 	return unique
 }
-
-// lookupZoneConfig returns the zone config matching the range.
-func lookupZoneConfig(g *gossip.Gossip, repl *Replica) (config.ZoneConfig, error) {
-	zoneMap, err := g.GetZoneConfig()
-	if err != nil {
-		return config.ZoneConfig{}, util.Errorf("unable to lookup zone config for range %s: %s", repl, err)
-	}
-	prefixConfig := zoneMap.MatchByPrefix(repl.Desc().StartKey)
-	return *prefixConfig.Config.GetValue().(*config.ZoneConfig), nil
-}
@@ -57,6 +57,38 @@ const (
 	defaultRaftElectionTimeoutTicks = 15
 	// ttlStoreGossip is time-to-live for store-related info.
 	ttlStoreGossip = 2 * time.Minute
+	// minCompactionInterval is the minimum time between the end of one
+	// explicitly requested engine compaction and the start of the next.
+	minCompactionInterval = 1 * time.Second
+	// defaultIntentResolverTaskLimit is the default maximum number of
+	// asynchronous intent resolution tasks run concurrently by a store.
+	defaultIntentResolverTaskLimit = 100
+	// defaultMaxIntentsPerRead is the default maximum number of skipped
+	// intents collected by a single read.
+	defaultMaxIntentsPerRead = 1000
+	// defaultMaxTxnIntents is the default maximum number of intents a
+	// transaction may write.
+	defaultMaxTxnIntents = 1000000
+	// defaultMaxBatchRequests and defaultMaxBatchBytes are the default
+	// maximum number of requests and encoded size of a batch.
+	defaultMaxBatchRequests = 10000
+	defaultMaxBatchBytes    = 64 << 20 // 64M
+	// defaultMaxInflightProposals is the default maximum number of
+	// commands a replica has proposed to Raft but not yet applied.
+	defaultMaxInflightProposals = 1000
+	// defaultCommitGracePeriod is the default time for which committing
+	// the batch of an applied command is retried after transient engine
+	// errors before the replica is considered corrupt.
+	defaultCommitGracePeriod = 10 * time.Second
+	// leaseDrainTimeout is the maximum time spent transferring leader
+	// leases away when the store is stopped.
+	leaseDrainTimeout = 5 * time.Second
+	// defaultMergeIntentTimeout is the default time for which a merge
+	// waits for the intents on the subsumed range to be resolved.
+	defaultMergeIntentTimeout = 5 * time.Second
+	// defaultQuorumAckTimeout is the default time for which a write waits
+	// for a replica in the locality required by its zone to persist it.
+	defaultQuorumAckTimeout = 10 * time.Second
 )
 
 var (
@@ -269,6 +301,12 @@ type Store struct {
 	nodeDesc          *proto.NodeDescriptor
 	initComplete      sync.WaitGroup // Signaled by async init tasks
 
+	compactionMu   sync.Mutex // Serializes explicit compactions
+	lastCompaction time.Time  // End of the last explicit compaction; protected by compactionMu
+
+	// Semaphore bounding concurrent async intent resolution tasks.
+	intentResolverSem chan struct{}
+
 	mu             sync.RWMutex               // Protects variables below...
 	replicas       map[proto.RangeID]*Replica // Map of replicas by Range ID
 	replicasByKey  *btree.BTree               // btree keyed by ranges end keys.
@@ -277,6 +315,14 @@ type Store struct {
 
 var _ multiraft.Storage = &Store{}
 
+// An ApplyHook observes the write commands applied by the replicas of a
+// store, receiving the range ID, the Raft log index at which the command
+// was applied and the command itself. Hooks are invoked synchronously
+// from the Raft processing goroutine after the command has been
+// committed to the engine, in order of increasing index per range, and
+// must therefore not block. Failed commands and reads are not observed.
+type ApplyHook func(rangeID proto.RangeID, index uint64, args proto.Request)
+
 // A StoreContext encompasses the auxiliary objects and configuration
 // required to create a store.
 // All fields holding a pointer or an interface are required to create
@@ -317,11 +363,97 @@ type StoreContext struct {
 	// information about a store, it can be considered dead.
 	TimeUntilStoreDead time.Duration
 
+	// ClosedTimestampInterval is the interval at which replicas holding the
+	// leader lease close timestamps for follower reads. Each time, the
+	// timestamp which lags the current time by the interval is closed.
+	// Closing timestamps is disabled if zero.
+	ClosedTimestampInterval time.Duration
+
 	// EventFeed is a feed to which this store will publish events.
 	EventFeed *util.Feed
 
+	// ApplyHook, if set, is invoked for every write command successfully
+	// applied by a replica of this store.
+	ApplyHook ApplyHook
+
 	// Tracer is a request tracer.
 	Tracer *tracer.Tracer
+
+	// TimestampCacheExcludedPrefixes lists key prefixes which are not
+	// recorded in the timestamp cache. It is intended for high-churn
+	// internal keys whose reads don't need to be protected from later
+	// writes at lower timestamps, such as heartbeats.
+	TimestampCacheExcludedPrefixes []proto.Key
+
+	// IntentResolverTaskLimit is the maximum number of asynchronous
+	// intent resolution tasks this store runs concurrently. Further
+	// tasks wait for a running one to finish.
+	IntentResolverTaskLimit int
+
+	// MaxIntentsPerRead is the maximum number of intents a single read
+	// collects for asynchronous resolution. Further intents the read
+	// steps over are left in place.
+	MaxIntentsPerRead int
+
+	// MaxTxnIntents is the maximum number of intents a transaction may
+	// write, as counted in Transaction.IntentCount. Once a transaction
+	// has reached it, its further writes fail with a TxnTooLargeError,
+	// and it can only be aborted. A negative value removes the limit.
+	MaxTxnIntents int64
+
+	// MaxBatchRequests and MaxBatchBytes bound the number of requests
+	// and the encoded size of a batch. Larger batches are rejected with
+	// a BatchTooLargeError.
+	MaxBatchRequests int
+	MaxBatchBytes    int64
+
+	// MaxInflightProposals is the maximum number of commands each
+	// replica may have proposed to Raft without having applied them.
+	// Further commands fail with a ProposalQuotaError until earlier
+	// ones have been applied.
+	MaxInflightProposals int
+
+	// CommitGracePeriod is the time for which committing the batch of an
+	// applied command is retried after a transient engine error, such as
+	// the disk running out of space, before the replica is considered
+	// corrupt. A negative value disables retries.
+	CommitGracePeriod time.Duration
+
+	// MergeIntentTimeout is the time for which a merge attempts to
+	// resolve the open intents on the subsumed range before it is
+	// aborted with a MergeIntentsError.
+	MergeIntentTimeout time.Duration
+
+	// QuorumAckTimeout is the time for which a write to a range whose
+	// zone sets QuorumAttrs waits for a replica in that locality to
+	// persist it before failing with a QuorumAckError. The write may
+	// still have been committed in that case.
+	QuorumAckTimeout time.Duration
+
+	// VerifyResponseCache enables a debugging mode in which every write
+	// is executed a second time when its response cache entry is
+	// written, and divergent responses are reported. A divergence
+	// indicates a non-deterministic command, which could make a retried
+	// command observe a different response than the original one.
+	VerifyResponseCache bool
+
+	// HardenedMode enables safety checks which trade performance for
+	// protection against corruption. The keys written by every write
+	// proposed by this store are recorded, and the write is discarded
+	// with a replica corruption error before it's committed if its
+	// result would violate the replica's invariants, such as negative
+	// MVCC stats or keys written outside of the range. The setting is
+	// carried in each command, so that all replicas apply it alike.
+	// Commands with side effects beyond their batch can't be discarded;
+	// if their MVCC stats would become negative, the node halts instead.
+	HardenedMode bool
+
+	// CompressSnapshotKeys prefix compresses the keys of the Raft
+	// snapshots sent by the store's replicas. Replicas running a version
+	// which doesn't understand compressed snapshots would apply them with
+	// truncated keys, so this must only be enabled once every node
+	// decompresses snapshots.
+	CompressSnapshotKeys bool
 }
 
 // Valid returns true if the StoreContext is populated correctly.
@@ -348,6 +480,33 @@ func (sc *StoreContext) setDefaults() {
 	if sc.RaftElectionTimeoutTicks == 0 {
 		sc.RaftElectionTimeoutTicks = defaultRaftElectionTimeoutTicks
 	}
+	if sc.IntentResolverTaskLimit == 0 {
+		sc.IntentResolverTaskLimit = defaultIntentResolverTaskLimit
+	}
+	if sc.MaxIntentsPerRead == 0 {
+		sc.MaxIntentsPerRead = defaultMaxIntentsPerRead
+	}
+	if sc.MaxTxnIntents == 0 {
+		sc.MaxTxnIntents = defaultMaxTxnIntents
+	}
+	if sc.MaxBatchRequests == 0 {
+		sc.MaxBatchRequests = defaultMaxBatchRequests
+	}
+	if sc.MaxBatchBytes == 0 {
+		sc.MaxBatchBytes = defaultMaxBatchBytes
+	}
+	if sc.MaxInflightProposals == 0 {
+		sc.MaxInflightProposals = defaultMaxInflightProposals
+	}
+	if sc.CommitGracePeriod == 0 {
+		sc.CommitGracePeriod = defaultCommitGracePeriod
+	}
+	if sc.MergeIntentTimeout == 0 {
+		sc.MergeIntentTimeout = defaultMergeIntentTimeout
+	}
+	if sc.QuorumAckTimeout == 0 {
+		sc.QuorumAckTimeout = defaultQuorumAckTimeout
+	}
 }
 
 // NewStore returns a new instance of a store.
@@ -413,6 +572,7 @@ func (s *Store) StartedAt() int64 {
 // Start the engine, set the GC and read the StoreIdent.
 func (s *Store) Start(stopper *stop.Stopper) error {
 	s.stopper = stopper
+	s.intentResolverSem = make(chan struct{}, s.ctx.IntentResolverTaskLimit)
 
 	if s.Ident.NodeID == 0 {
 		// Open engine (i.e. initialize RocksDB database). "NodeID != 0"
@@ -554,6 +714,10 @@ func (s *Store) Start(stopper *stop.Stopper) error {
 
 	}
 
+	if s.ctx.ClosedTimestampInterval > 0 {
+		s.startClosingTimestamps()
+	}
+
 	// Set the started flag (for unittests).
 	atomic.StoreInt32(&s.started, 1)
 
@@ -635,6 +799,30 @@ func (s *Store) startGossip() {
 	})
 }
 
+// startClosingTimestamps runs an infinite loop in a goroutine which
+// regularly closes timestamps on all replicas holding the leader lease,
+// allowing the other replicas to serve reads at or below them.
+func (s *Store) startClosingTimestamps() {
+	ctx := s.Context(nil)
+	s.stopper.RunWorker(func() {
+		ticker := time.NewTicker(s.ctx.ClosedTimestampInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				newStoreRangeSet(s).Visit(func(r *Replica) bool {
+					if err := r.maybeCloseTimestamp(s.ctx.ClosedTimestampInterval); err != nil {
+						log.Warningc(ctx, "error closing timestamp on range %d: %s", r.Desc().RangeID, err)
+					}
+					return true
+				})
+			case <-s.stopper.ShouldStop():
+				return
+			}
+		}
+	})
+}
+
 // maybeGossipFirstRange checks whether the store has a replia of the first
 // range and if so, reminds it to gossip the first range descriptor and
 // sentinel gossip.
@@ -647,13 +835,14 @@ func (s *Store) maybeGossipFirstRange() error {
 }
 
 // maybeGossipConfigs checks which of the store's ranges contain config
-// descriptors and lets these ranges gossip them. Config gossip entries do not
-// expire, so this is a rarely needed action in a working cluster - if values
-// change, ranges will update gossip autonomously. However, the lease holder,
-// who is normally in charge of that might crash after updates before gossiping
-// and a new leader lease is only acquired if needed. To account for this rare
-// scenario, we activate the very few ranges that hold config maps
-// periodically.
+// descriptors and lets these ranges gossip them. Config gossip entries
+// usually do not expire, so this is a rarely needed action in a working
+// cluster - if values change, ranges will update gossip autonomously.
+// However, the lease holder, who is normally in charge of that might crash
+// after updates before gossiping and a new leader lease is only acquired if
+// needed. To account for this rare scenario, we activate the very few ranges
+// that hold config maps periodically. The lease holder also refreshes config
+// maps which are gossiped with a finite TTL before they expire.
 func (s *Store) maybeGossipConfigs() error {
 	for _, cd := range configDescriptors {
 		rng := s.LookupReplica(cd.keyPrefix, nil)
@@ -666,9 +855,16 @@ func (s *Store) maybeGossipConfigs() error {
 		// have an active lease but we still failed to obtain it), return
 		// that error. If we ignored it we would run the risk of running a
 		// cluster without configs gossiped.
-		if _, err := rng.getLeaseForGossip(s.Context(nil)); err != nil {
+		hasLease, err := rng.getLeaseForGossip(s.Context(nil))
+		if err != nil {
 			return err
 		}
+		if hasLease && cd.ttl != 0 {
+			keyPrefix := cd.keyPrefix
+			rng.maybeGossipConfigs(func(configPrefix proto.Key) bool {
+				return bytes.Equal(configPrefix, keyPrefix)
+			})
+		}
 	}
 	return nil
 }
@@ -773,8 +969,9 @@ func (s *Store) ForceRangeGCScan(t util.Tester) {
 	}
 }
 
-// setRangesMaxBytes sets the max bytes for every range according
-// to the zone configs.
+// setRangesMaxBytes sets the max bytes and max value bytes for every
+// range according to the zone configs. Ranges which exceed a lowered
+// max bytes are added to the split queue.
 //
 // TODO(spencer): scanning all ranges with the lock held could cause
 // perf issues if the number of ranges grows large enough.
@@ -791,7 +988,12 @@ func (s *Store) setRangesMaxBytes(zoneMap *config.PrefixConfigMap) {
 			idx++
 			zone = zoneMap.Configs[idx].Config.GetValue().(*config.ZoneConfig)
 		}
-		rng.SetMaxBytes(zone.RangeMaxBytes)
+		rng.updateMaxBytes(zone.RangeMaxBytes)
+		rng.SetMaxValueBytes(zone.MaxValueBytes)
+		rng.SetResponseCacheDisabled(zone.DisableResponseCache)
+		rng.SetLeasePreferences(zoneLeasePreferences(zone))
+		rng.SetValueChecksums(zone.ValueChecksums)
+		rng.SetQuorumAttrs(zone.QuorumAttrs)
 		return true
 	})
 }
@@ -998,9 +1200,99 @@ func (s *Store) Stopper() *stop.Stopper { return s.stopper }
 // EventFeed accessor.
 func (s *Store) EventFeed() StoreEventFeed { return s.feed }
 
+// applyHook accessor.
+func (s *Store) applyHook() ApplyHook { return s.ctx.ApplyHook }
+
 // Tracer accessor.
 func (s *Store) Tracer() *tracer.Tracer { return s.ctx.Tracer }
 
+// tsCacheExcludedPrefixes accessor.
+func (s *Store) tsCacheExcludedPrefixes() []proto.Key { return s.ctx.TimestampCacheExcludedPrefixes }
+
+// maxIntentsPerRead accessor.
+func (s *Store) maxIntentsPerRead() int { return s.ctx.MaxIntentsPerRead }
+
+// maxTxnIntents accessor.
+func (s *Store) maxTxnIntents() int64 { return s.ctx.MaxTxnIntents }
+
+// maxBatchRequests accessor.
+func (s *Store) maxBatchRequests() int { return s.ctx.MaxBatchRequests }
+
+// maxBatchBytes accessor.
+func (s *Store) maxBatchBytes() int64 { return s.ctx.MaxBatchBytes }
+
+// maxInflightProposals accessor.
+func (s *Store) maxInflightProposals() int { return s.ctx.MaxInflightProposals }
+
+// commitGracePeriod accessor.
+func (s *Store) commitGracePeriod() time.Duration { return s.ctx.CommitGracePeriod }
+
+// mergeIntentTimeout accessor.
+func (s *Store) mergeIntentTimeout() time.Duration { return s.ctx.MergeIntentTimeout }
+
+// quorumAckTimeout accessor.
+func (s *Store) quorumAckTimeout() time.Duration { return s.ctx.QuorumAckTimeout }
+
+// verifyResponseCache accessor.
+func (s *Store) verifyResponseCache() bool { return s.ctx.VerifyResponseCache }
+
+// hardenedMode accessor.
+func (s *Store) hardenedMode() bool { return s.ctx.HardenedMode }
+
+// compressSnapshotKeys accessor.
+func (s *Store) compressSnapshotKeys() bool { return s.ctx.CompressSnapshotKeys }
+
+// compactRange compacts the engine over the specified encoded key span
+// if the engine supports it. Compactions are serialized and spaced at
+// least minCompactionInterval apart to avoid I/O storms, so a caller
+// may block until earlier compactions have completed.
+func (s *Store) compactRange(start, end proto.EncodedKey) error {
+	c, ok := s.engine.(interface {
+		CompactRange(start, end proto.EncodedKey)
+	})
+	if !ok {
+		return util.Errorf("engine %T does not support compaction", s.engine)
+	}
+	s.compactionMu.Lock()
+	defer s.compactionMu.Unlock()
+	if wait := s.lastCompaction.Add(minCompactionInterval).Sub(time.Now()); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-s.stopper.ShouldStop():
+			return util.Errorf("store %d is stopping", s.StoreID())
+		}
+	}
+	c.CompactRange(start, end)
+	s.lastCompaction = time.Now()
+	return nil
+}
+
+// runAsyncIntentResolution runs the supplied intent resolution task
+// asynchronously once fewer than IntentResolverTaskLimit such tasks
+// are running, blocking the caller until then. Returns false without
+// running the task if the store is draining; the caller is expected to
+// run it synchronously instead.
+func (s *Store) runAsyncIntentResolution(action func()) bool {
+	sem := s.intentResolverSem
+	if sem == nil {
+		// The store hasn't been started.
+		return s.stopper.RunAsyncTask(action)
+	}
+	select {
+	case sem <- struct{}{}:
+	case <-s.stopper.ShouldStop():
+		return false
+	}
+	if !s.stopper.RunAsyncTask(func() {
+		defer func() { <-sem }()
+		action()
+	}) {
+		<-sem
+		return false
+	}
+	return true
+}
+
 // NewRangeDescriptor creates a new descriptor based on start and end
 // keys and the supplied proto.Replicas slice. It allocates new
 // replica IDs to fill out the supplied replicas.
@@ -1184,6 +1476,9 @@ func (s *Store) removeReplicaImpl(rep *Replica) error {
 		return util.Errorf("couldn't find range in replicasByKey btree")
 	}
 	s.scanner.RemoveReplica(rep)
+	// Commands proposed through this replica will not be applied here
+	// anymore; fail them so that their callers can retry elsewhere.
+	rep.drainPendingCmds(proto.NewRangeNotFoundError(rangeID))
 	return nil
 }
 
@@ -1242,6 +1537,7 @@ func (s *Store) Descriptor() (*proto.StoreDescriptor, error) {
 		return nil, err
 	}
 	capacity.RangeCount = int32(s.ReplicaCount())
+	capacity.LeaseCount = int32(s.LeaseCount())
 	// Initialize the store descriptor.
 	return &proto.StoreDescriptor{
 		StoreID:  s.Ident.StoreID,
@@ -1258,6 +1554,79 @@ func (s *Store) ReplicaCount() int {
 	return len(s.replicas)
 }
 
+// LeaseCount returns the number of replicas in this store which hold an
+// active leader lease.
+func (s *Store) LeaseCount() int {
+	raftNodeID := s.RaftNodeID()
+	now := s.ctx.Clock.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count int
+	for _, rng := range s.replicas {
+		if lease := rng.getLease(); lease.OwnedBy(raftNodeID) && lease.Covers(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// DrainLeases transfers the leader leases held by replicas in this
+// store to peer replicas on live stores, so that requests aren't
+// stalled until the leases expire once the store stops serving. Leases
+// of ranges without a live peer are left to expire. Transfers still
+// pending after leaseDrainTimeout are abandoned and the commands
+// pending on their replicas are failed with a NotLeaderError, which
+// clients retry. DrainLeases returns the number of leases transferred;
+// it is run when the node is shut down.
+func (s *Store) DrainLeases() int {
+	s.mu.RLock()
+	replicas := make([]*Replica, 0, len(s.replicas))
+	for _, rng := range s.replicas {
+		replicas = append(replicas, rng)
+	}
+	s.mu.RUnlock()
+
+	type drainResult struct {
+		rng         *Replica
+		transferred bool
+	}
+	results := make(chan drainResult, len(replicas))
+	pending := make(map[*Replica]struct{}, len(replicas))
+	for _, rng := range replicas {
+		pending[rng] = struct{}{}
+		go func(rng *Replica) {
+			transferred, err := rng.drainLeaderLease()
+			if err != nil {
+				log.Warningc(s.Context(nil), "%s: failed to transfer leader lease: %s", rng, err)
+				transferred = false
+			}
+			results <- drainResult{rng: rng, transferred: transferred}
+		}(rng)
+	}
+	var count int
+	timeout := time.After(leaseDrainTimeout)
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			delete(pending, res.rng)
+			if res.transferred {
+				count++
+			}
+		case <-timeout:
+			log.Warningc(s.Context(nil), "timed out transferring leader leases of %d replicas", len(pending))
+			// Fail the commands still waiting to be applied, so that the
+			// goroutines blocked on the abandoned transfers don't leak. The
+			// node is stopping, so their clients retry on other replicas;
+			// the next leader isn't known yet.
+			for rng := range pending {
+				rng.drainPendingCmds(&proto.NotLeaderError{RangeID: rng.Desc().RangeID})
+			}
+			return count
+		}
+	}
+	return count
+}
+
 // ExecuteCmd fetches a range based on the header's replica, assembles
 // method, args & reply into a Raft Cmd struct and executes the
 // command using the fetched range.
@@ -1559,7 +1928,7 @@ func (s *Store) processRaft() {
 					groupID = e.GroupID
 					commandID = e.CommandID
 					index = e.Index
-					err := gogoproto.Unmarshal(e.Command, &cmd)
+					err := proto.UnmarshalWithDetails(e.Command, &cmd)
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -1573,7 +1942,7 @@ func (s *Store) processRaft() {
 					commandID = e.CommandID
 					index = e.Index
 					callback = e.Callback
-					err := gogoproto.Unmarshal(e.Payload, &cmd)
+					err := proto.UnmarshalWithDetails(e.Payload, &cmd)
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -1656,7 +2025,7 @@ func raftEntryFormatter(data []byte) string {
 		return "[empty]"
 	}
 	var cmd proto.RaftCommand
-	if err := gogoproto.Unmarshal(data, &cmd); err != nil {
+	if err := proto.UnmarshalWithDetails(data, &cmd); err != nil {
 		return fmt.Sprintf("[error parsing entry: %s]", err)
 	}
 	s := cmd.String()
@@ -243,11 +243,11 @@ func (s *stat) update(x float64) {
 	s.mean += (x - s.mean) / s.n
 }
 
-// StoreList holds a list of store descriptors and associated count and used
-// stats for those stores.
+// StoreList holds a list of store descriptors and associated count, used
+// and lease stats for those stores.
 type StoreList struct {
-	stores      []*proto.StoreDescriptor
-	count, used stat
+	stores              []*proto.StoreDescriptor
+	count, used, leases stat
 }
 
 // add includes the store descriptor to the list of stores and updates
@@ -256,6 +256,7 @@ func (sl *StoreList) add(s *proto.StoreDescriptor) {
 	sl.stores = append(sl.stores, s)
 	sl.count.update(float64(s.Capacity.RangeCount))
 	sl.used.update(s.Capacity.FractionUsed())
+	sl.leases.update(float64(s.Capacity.LeaseCount))
 }
 
 // GetStoreList returns a storeList that contains all active stores that
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -350,6 +351,53 @@ func TestStoreAddRemoveRanges(t *testing.T) {
 	}
 }
 
+// TestStoreRemoveReplicaDrainsPendingCmds verifies that commands still
+// waiting for their result when a replica is removed are failed with a
+// RangeNotFoundError instead of blocking forever.
+func TestStoreRemoveReplicaDrainsPendingCmds(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, _, stopper := createTestStore(t)
+	defer stopper.Stop()
+
+	rng, err := store.GetReplica(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Register a command which will never be applied.
+	cmd := &pendingCmd{
+		ctx:  context.Background(),
+		done: make(chan proto.ResponseWithError, 1),
+	}
+	idKey := makeCmdIDKey(proto.ClientCmdID{WallTime: 1, Random: 1})
+	rng.Lock()
+	rng.pendingCmds[idKey] = cmd
+	rng.Unlock()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- (<-cmd.done).Err
+	}()
+
+	if err := store.RemoveReplica(rng); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errChan:
+		if _, ok := err.(*proto.RangeNotFoundError); !ok {
+			t.Fatalf("expected range not found error; got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending command was not drained on replica removal")
+	}
+
+	rng.Lock()
+	defer rng.Unlock()
+	if l := len(rng.pendingCmds); l != 0 {
+		t.Errorf("expected no pending commands; got %d", l)
+	}
+}
+
 func TestStoreRangeSet(t *testing.T) {
 	defer leaktest.AfterTest(t)
 	store, _, stopper := createTestStore(t)
@@ -472,6 +520,140 @@ func TestStoreExecuteCmd(t *testing.T) {
 	}
 }
 
+// TestStoreApplyHook verifies that the store's apply hook observes every
+// successfully applied write in order, but neither reads nor failed
+// writes.
+func TestStoreApplyHook(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, _, stopper := createTestStoreWithoutStart(t)
+	defer stopper.Stop()
+
+	var mu sync.Mutex
+	lastIndex := map[proto.RangeID]uint64{}
+	var observed []proto.Request
+	store.ctx.ApplyHook = func(rangeID proto.RangeID, index uint64, args proto.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if index <= lastIndex[rangeID] {
+			t.Errorf("expected increasing index; got %d after %d", index, lastIndex[rangeID])
+		}
+		lastIndex[rangeID] = index
+		if bytes.HasPrefix(args.Header().Key, proto.Key("hook-")) {
+			observed = append(observed, args)
+		}
+	}
+	if err := store.Start(stopper); err != nil {
+		t.Fatal(err)
+	}
+	store.WaitForInit()
+
+	pArgs := putArgs([]byte("hook-a"), []byte("value"), 1, store.StoreID())
+	gArgs := getArgs([]byte("hook-a"), 1, store.StoreID())
+	// Incrementing a non-integer value fails.
+	iArgs := incrementArgs([]byte("hook-a"), 1, 1, store.StoreID())
+	dArgs := deleteArgs(proto.Key("hook-a"), 1, store.StoreID())
+	for _, args := range []proto.Request{&pArgs, &gArgs, &iArgs, &dArgs} {
+		_, err := store.ExecuteCmd(context.Background(), args)
+		if _, ok := args.(*proto.IncrementRequest); ok != (err != nil) {
+			t.Fatalf("%s: unexpected error %v", args.Method(), err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(observed) != 2 || observed[0].Method() != proto.Put || observed[1].Method() != proto.Delete {
+		t.Errorf("expected to observe put and delete; got %v", observed)
+	}
+}
+
+// TestStoreTimestampCacheExcludedPrefixes verifies that commands on
+// keys under the store's excluded prefixes are not recorded in the
+// timestamp cache, while commands on other keys are.
+func TestStoreTimestampCacheExcludedPrefixes(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, _, stopper := createTestStoreWithoutStart(t)
+	defer stopper.Stop()
+	store.ctx.TimestampCacheExcludedPrefixes = []proto.Key{proto.Key("heartbeat-")}
+	if err := store.Start(stopper); err != nil {
+		t.Fatal(err)
+	}
+	store.WaitForInit()
+
+	rng := store.LookupReplica(proto.Key("a"), nil)
+	for _, test := range []struct {
+		key      proto.Key
+		excluded bool
+	}{
+		{proto.Key("heartbeat-1"), true},
+		{proto.Key("a"), false},
+	} {
+		gArgs := getArgs(test.key, 1, store.StoreID())
+		gArgs.Timestamp = store.ctx.Clock.Now()
+		if _, err := store.ExecuteCmd(context.Background(), &gArgs); err != nil {
+			t.Fatal(err)
+		}
+		pArgs := putArgs(test.key, []byte("value"), 1, store.StoreID())
+		pArgs.Timestamp = store.ctx.Clock.Now()
+		if _, err := store.ExecuteCmd(context.Background(), &pArgs); err != nil {
+			t.Fatal(err)
+		}
+		rng.RLock()
+		rTS, wTS := rng.tsCache.GetMax(test.key, nil, nil)
+		rng.RUnlock()
+		if recorded := rTS.Equal(gArgs.Timestamp); recorded == test.excluded {
+			t.Errorf("%s: expected read recorded=%t; got read timestamp %s for %s",
+				test.key, !test.excluded, rTS, gArgs.Timestamp)
+		}
+		if recorded := wTS.Equal(pArgs.Timestamp); recorded == test.excluded {
+			t.Errorf("%s: expected write recorded=%t; got write timestamp %s for %s",
+				test.key, !test.excluded, wTS, pArgs.Timestamp)
+		}
+	}
+}
+
+// TestStoreIntentResolverTaskLimit verifies that no more than the
+// configured number of async intent resolution tasks run concurrently.
+func TestStoreIntentResolverTaskLimit(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	store, _, stopper := createTestStoreWithoutStart(t)
+	defer stopper.Stop()
+	const limit = 3
+	store.ctx.IntentResolverTaskLimit = limit
+	if err := store.Start(stopper); err != nil {
+		t.Fatal(err)
+	}
+	store.WaitForInit()
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	var wg sync.WaitGroup
+	const numTasks = 20
+	for i := 0; i < numTasks; i++ {
+		wg.Add(1)
+		if !store.runAsyncIntentResolution(func() {
+			defer wg.Done()
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}) {
+			t.Fatalf("%d: failed to run task", i)
+		}
+	}
+	wg.Wait()
+	if maxRunning > limit {
+		t.Errorf("expected at most %d concurrent tasks, got %d", limit, maxRunning)
+	} else if maxRunning < 2 {
+		t.Errorf("expected tasks to run concurrently, got %d at a time", maxRunning)
+	}
+}
+
 // TestStoreVerifyKeys checks that key length is enforced and
 // that end keys must sort >= start.
 func TestStoreVerifyKeys(t *testing.T) {
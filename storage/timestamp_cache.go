@@ -18,6 +18,7 @@
 package storage
 
 import (
+	"sort"
 	"time"
 
 	"github.com/cockroachdb/cockroach/proto"
@@ -171,6 +172,54 @@ func (tc *TimestampCache) MergeInto(dest *TimestampCache, clear bool) {
 	})
 }
 
+// Export returns the low water mark and up to maxEntries of the most
+// recent entries of the cache. The timestamps of entries which are left
+// out are folded into the returned low water mark, so that importing
+// the result into an empty cache never yields a lower timestamp from
+// GetMax than this cache would.
+func (tc *TimestampCache) Export(maxEntries int) (proto.Timestamp, []proto.TimestampCacheEntry) {
+	var entries []proto.TimestampCacheEntry
+	tc.cache.Do(func(k, v interface{}) {
+		key := k.(*cache.IntervalKey)
+		ce := v.(cacheEntry)
+		entries = append(entries, proto.TimestampCacheEntry{
+			Key:       key.Start().(proto.Key),
+			EndKey:    key.End().(proto.Key),
+			Timestamp: ce.timestamp,
+			ReadOnly:  ce.readOnly,
+			TxnID:     ce.txnID,
+		})
+	})
+	lowWater := tc.lowWater
+	if len(entries) > maxEntries {
+		sort.Sort(tsCacheEntriesByTimestamp(entries))
+		for _, e := range entries[maxEntries:] {
+			lowWater.Forward(e.Timestamp)
+		}
+		entries = entries[:maxEntries]
+	}
+	return lowWater, entries
+}
+
+// Import clears the cache and replaces its contents with the supplied
+// low water mark and entries, as returned by Export.
+func (tc *TimestampCache) Import(lowWater proto.Timestamp, entries []proto.TimestampCacheEntry) {
+	tc.cache.Clear()
+	tc.lowWater = lowWater
+	tc.latest = lowWater
+	for _, e := range entries {
+		tc.Add(e.Key, e.EndKey, e.Timestamp, e.TxnID, e.ReadOnly)
+	}
+}
+
+// tsCacheEntriesByTimestamp sorts timestamp cache entries from the most
+// to the least recent.
+type tsCacheEntriesByTimestamp []proto.TimestampCacheEntry
+
+func (s tsCacheEntriesByTimestamp) Len() int           { return len(s) }
+func (s tsCacheEntriesByTimestamp) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s tsCacheEntriesByTimestamp) Less(i, j int) bool { return s[j].Timestamp.Less(s[i].Timestamp) }
+
 // shouldEvict returns true if the cache entry's timestamp is no
 // longer within the MinTSCacheWindow.
 func (tc *TimestampCache) shouldEvict(size int, key, value interface{}) bool {
@@ -236,6 +236,51 @@ func TestTimestampCacheMergeInto(t *testing.T) {
 	}
 }
 
+// TestTimestampCacheExportImport verifies that exported entries are
+// restored on import along with their transaction IDs, and that entries
+// exceeding the limit are folded into the exported low water mark.
+func TestTimestampCacheExportImport(t *testing.T) {
+	defer leaktest.AfterTest(t)
+	manual := hlc.NewManualClock(0)
+	clock := hlc.NewClock(manual.UnixNano)
+	tc1 := NewTimestampCache(clock)
+
+	aTS := clock.Now()
+	tc1.Add(proto.Key("a"), nil, aTS, nil, true)
+	bcTS := clock.Now()
+	tc1.Add(proto.Key("b"), proto.Key("c"), bcTS, nil, false)
+	dTS := clock.Now()
+	txnID := []byte("1234")
+	tc1.Add(proto.Key("d"), nil, dTS, txnID, true)
+
+	lowWater, entries := tc1.Export(2)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries; got %d", len(entries))
+	}
+	if !lowWater.Equal(aTS) {
+		t.Errorf("expected low water %s; got %s", aTS, lowWater)
+	}
+
+	manual.Set(maxClockOffset.Nanoseconds())
+	tc2 := NewTimestampCache(clock)
+	tc2.Import(lowWater, entries)
+	if rTS, _ := tc2.GetMax(proto.Key("a"), nil, nil); !rTS.Equal(aTS) {
+		t.Errorf("expected \"a\" to have low water timestamp %s; got %s", aTS, rTS)
+	}
+	if _, wTS := tc2.GetMax(proto.Key("b"), nil, nil); !wTS.Equal(bcTS) {
+		t.Errorf("expected \"b\" to have write timestamp %s; got %s", bcTS, wTS)
+	}
+	if rTS, _ := tc2.GetMax(proto.Key("d"), nil, nil); !rTS.Equal(dTS) {
+		t.Errorf("expected \"d\" to have read timestamp %s; got %s", dTS, rTS)
+	}
+	if rTS, _ := tc2.GetMax(proto.Key("d"), nil, txnID); !rTS.Equal(aTS) {
+		t.Errorf("expected \"d\" to ignore the read of its own txn and have low water timestamp %s; got %s", aTS, rTS)
+	}
+	if rTS, _ := tc2.GetMax(proto.Key("e"), nil, nil); !rTS.Equal(aTS) {
+		t.Errorf("expected \"e\" to have low water timestamp %s; got %s", aTS, rTS)
+	}
+}
+
 // TestTimestampCacheLayeredIntervals verifies the maximum timestamp
 // is chosen if previous entries have ranges which are layered over
 // each other.
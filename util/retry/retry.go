@@ -92,6 +92,20 @@ func (r Retry) retryIn() time.Duration {
 	return time.Duration(backoff - delta + rand.Float64()*(2*delta+1))
 }
 
+// NextBackoff advances the Retry like Next, but returns the time the
+// caller should wait before the next attempt instead of blocking for it.
+// The first call after a Reset returns zero. MaxRetries and the Stopper
+// are not consulted; the caller is responsible for waiting.
+func (r *Retry) NextBackoff() time.Duration {
+	if r.isReset {
+		r.isReset = false
+		return 0
+	}
+	backoff := r.retryIn()
+	r.currentAttempt++
+	return backoff
+}
+
 // Next returns whether the retry loop should continue, and blocks for the
 // appropriate length of time before yielding back to the caller. If a stopper
 // is present, Next will eagerly return false when the stopper is stopped.
@@ -114,3 +114,31 @@ func TestRetryStop(t *testing.T) {
 		t.Errorf("expected %d attempts, got %d", expAttempts, attempts)
 	}
 }
+
+func TestRetryNextBackoff(t *testing.T) {
+	opts := Options{
+		InitialBackoff: time.Second,
+		MaxBackoff:     4 * time.Second,
+		Multiplier:     2,
+	}
+
+	r := Start(opts)
+	if backoff := r.NextBackoff(); backoff != 0 {
+		t.Errorf("expected no backoff for the first attempt, got %s", backoff)
+	}
+	var prev time.Duration
+	for i := 1; i <= 3; i++ {
+		backoff := r.NextBackoff()
+		if backoff <= prev || backoff > 2*opts.MaxBackoff {
+			t.Errorf("%d: expected backoff to grow up to the maximum, got %s after %s", i, backoff, prev)
+		}
+		if a := r.CurrentAttempt(); a != i {
+			t.Errorf("%d: expected attempt %d, got %d", i, i, a)
+		}
+		prev = backoff
+	}
+	r.Reset()
+	if backoff := r.NextBackoff(); backoff != 0 {
+		t.Errorf("expected no backoff after a reset, got %s", backoff)
+	}
+}
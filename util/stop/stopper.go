@@ -47,7 +47,8 @@ type Closer interface {
 //
 // An arbitrary list of objects implementing the Closer interface may
 // be added to the stopper via AddCloser(), to be closed after the
-// stopper has stopped.
+// stopper has stopped. Functions added via AddDrainHook() are run
+// once, before the stopper starts draining.
 type Stopper struct {
 	stopper  chan struct{}  // Closed when stopping
 	stopped  chan struct{}  // Closed when stopped completely
@@ -58,6 +59,7 @@ type Stopper struct {
 	numTasks int            // number of outstanding tasks
 	tasks    map[string]int
 	closers  []Closer
+	hooks    []func() // Run at the start of Quiesce()
 }
 
 // NewStopper returns an instance of Stopper.
@@ -88,6 +90,16 @@ func (s *Stopper) AddCloser(c Closer) {
 	s.closers = append(s.closers, c)
 }
 
+// AddDrainHook adds a function to run when the stopper is asked to
+// stop, before it starts draining. Hooks run synchronously and in the
+// order added, while tasks may still be started, which allows them to
+// hand off work gracefully. Each hook runs at most once.
+func (s *Stopper) AddDrainHook(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, f)
+}
+
 // RunTask adds one to the count of tasks left to drain in the system. Any
 // worker which is a "first mover" when starting tasks must call this method
 // before starting work on a new task. First movers include
@@ -219,9 +231,18 @@ func (s *Stopper) IsStopped() <-chan struct{} {
 	return s.stopped
 }
 
-// Quiesce moves the stopper to state draining and waits until all
-// tasks complete. This is used from Stop() and unittests.
+// Quiesce runs the drain hooks, then moves the stopper to state
+// draining and waits until all tasks complete. This is used from Stop()
+// and unittests.
 func (s *Stopper) Quiesce() {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.hooks = nil
+	s.mu.Unlock()
+	for _, f := range hooks {
+		f()
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.draining = true
@@ -233,6 +233,26 @@ func TestStopperClosers(t *testing.T) {
 	}
 }
 
+func TestStopperDrainHooks(t *testing.T) {
+	s := stop.NewStopper()
+	var calls []int
+	for i := 0; i < 2; i++ {
+		i := i
+		s.AddDrainHook(func() {
+			// Tasks can still be run by the hooks.
+			if !s.RunTask(func() {}) {
+				t.Errorf("hook %d: expected task to run", i)
+			}
+			calls = append(calls, i)
+		})
+	}
+	s.Quiesce()
+	s.Stop()
+	if len(calls) != 2 || calls[0] != 0 || calls[1] != 1 {
+		t.Errorf("expected hooks to run once in order; got %v", calls)
+	}
+}
+
 func TestStopperNumTasks(t *testing.T) {
 	s := stop.NewStopper()
 	var tasks []chan bool